@@ -0,0 +1,68 @@
+package basetool
+
+import (
+	"sync"
+	"time"
+)
+
+// 集合类型的公共配置
+// safe: 是否需要加锁, 默认不加锁
+// ttl: 缓存的默认过期时间, 仅对cache生效
+type collectionOption struct {
+	safe bool
+	ttl  time.Duration
+}
+
+type collectionOptionFn func(*collectionOption)
+
+func newCollectionOption(opts ...collectionOptionFn) *collectionOption {
+	opt := &collectionOption{}
+	for _, item := range opts {
+		item(opt)
+	}
+	return opt
+}
+
+// WithSafe 开启并发安全
+func WithSafe() collectionOptionFn {
+	return func(o *collectionOption) {
+		o.safe = true
+	}
+}
+
+// WithTTL 设置缓存的默认过期时间, <=0表示不过期
+func WithTTL(ttl time.Duration) collectionOptionFn {
+	return func(o *collectionOption) {
+		o.ttl = ttl
+	}
+}
+
+// 可选的读写锁, 未开启safe时所有操作都是空操作
+type optionalLock struct {
+	safe bool
+	mu   sync.RWMutex
+}
+
+func (l *optionalLock) lock() {
+	if l.safe {
+		l.mu.Lock()
+	}
+}
+
+func (l *optionalLock) unlock() {
+	if l.safe {
+		l.mu.Unlock()
+	}
+}
+
+func (l *optionalLock) rlock() {
+	if l.safe {
+		l.mu.RLock()
+	}
+}
+
+func (l *optionalLock) runlock() {
+	if l.safe {
+		l.mu.RUnlock()
+	}
+}
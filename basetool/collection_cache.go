@@ -0,0 +1,453 @@
+package basetool
+
+import (
+	"container/list"
+	"time"
+)
+
+// EvictReason 缓存被移除的原因
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // 超出容量
+	EvictExpired                     // 过期
+	EvictDeleted                     // 主动删除
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// Cache LRU与LFU缓存的公共接口
+type Cache[K comparable, V any] interface {
+	Set(key K, value V)
+	// SetWithTTL ttl<=0表示不过期
+	SetWithTTL(key K, value V, ttl time.Duration)
+	Get(key K) (V, bool)
+	// Peek 获取值但是不影响淘汰顺序
+	Peek(key K) (V, bool)
+	Has(key K) bool
+	Delete(key K) bool
+	Len() int
+	Keys() []K
+	// DeleteExpired 清理所有过期的key, 返回清理的数量
+	DeleteExpired() int
+	Purge()
+	// OnEvict 设置移除回调, 回调在释放锁之后调用
+	OnEvict(fn func(key K, value V, reason EvictReason))
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key      K
+	value    V
+	expireAt time.Time
+	freq     int
+}
+
+func (e *cacheEntry[K, V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+type evicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+// lru与lfu共用的部分
+type cacheBase[K comparable, V any] struct {
+	lock     optionalLock
+	capacity int // <=0 表示不限制容量
+	ttl      time.Duration
+	now      func() time.Time
+	onEvict  func(key K, value V, reason EvictReason)
+}
+
+func newCacheBase[K comparable, V any](capacity int, opts ...collectionOptionFn) cacheBase[K, V] {
+	opt := newCollectionOption(opts...)
+	return cacheBase[K, V]{
+		lock:     optionalLock{safe: opt.safe},
+		capacity: capacity,
+		ttl:      opt.ttl,
+		now:      time.Now,
+	}
+}
+
+func (c *cacheBase[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	c.lock.lock()
+	defer c.lock.unlock()
+	c.onEvict = fn
+}
+
+func (c *cacheBase[K, V]) expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(ttl)
+}
+
+// notify 在释放锁之后调用, 避免回调中再次操作缓存导致死锁
+func (c *cacheBase[K, V]) notify(items []evicted[K, V]) {
+	c.lock.rlock()
+	fn := c.onEvict
+	c.lock.runlock()
+	if fn == nil {
+		return
+	}
+	for _, item := range items {
+		fn(item.key, item.value, item.reason)
+	}
+}
+
+////////
+// LRU
+////////
+
+// LRUCache 最近最少使用淘汰
+type LRUCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	items map[K]*list.Element
+	ll    *list.List // 最左边是最近使用的
+}
+
+var _ Cache[string, int] = (*LRUCache[string, int])(nil)
+
+func NewLRUCache[K comparable, V any](capacity int, opts ...collectionOptionFn) *LRUCache[K, V] {
+	return &LRUCache[K, V]{
+		cacheBase: newCacheBase[K, V](capacity, opts...),
+		items:     map[K]*list.Element{},
+		ll:        list.New(),
+	}
+}
+
+func (c *LRUCache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+func (c *LRUCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var removed []evicted[K, V]
+	c.lock.lock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry[K, V])
+		entry.value = value
+		entry.expireAt = c.expireAt(ttl)
+		c.ll.MoveToFront(el)
+	} else {
+		entry := &cacheEntry[K, V]{key: key, value: value, expireAt: c.expireAt(ttl)}
+		c.items[key] = c.ll.PushFront(entry)
+		for c.capacity > 0 && c.ll.Len() > c.capacity {
+			removed = append(removed, c.removeElement(c.ll.Back(), EvictCapacity))
+		}
+	}
+	c.lock.unlock()
+	c.notify(removed)
+}
+
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	return c.get(key, true)
+}
+
+func (c *LRUCache[K, V]) Peek(key K) (V, bool) {
+	return c.get(key, false)
+}
+
+func (c *LRUCache[K, V]) get(key K, touch bool) (V, bool) {
+	var zero V
+	c.lock.lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.lock.unlock()
+		return zero, false
+	}
+	entry := el.Value.(*cacheEntry[K, V])
+	if entry.expired(c.now()) {
+		removed := c.removeElement(el, EvictExpired)
+		c.lock.unlock()
+		c.notify([]evicted[K, V]{removed})
+		return zero, false
+	}
+	if touch {
+		c.ll.MoveToFront(el)
+	}
+	c.lock.unlock()
+	return entry.value, true
+}
+
+func (c *LRUCache[K, V]) Has(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+func (c *LRUCache[K, V]) Delete(key K) bool {
+	c.lock.lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.lock.unlock()
+		return false
+	}
+	removed := c.removeElement(el, EvictDeleted)
+	c.lock.unlock()
+	c.notify([]evicted[K, V]{removed})
+	return true
+}
+
+func (c *LRUCache[K, V]) Len() int {
+	c.lock.rlock()
+	defer c.lock.runlock()
+	return c.ll.Len()
+}
+
+// Keys 从最近使用到最久未使用排列
+func (c *LRUCache[K, V]) Keys() []K {
+	c.lock.rlock()
+	defer c.lock.runlock()
+
+	res := make([]K, 0, c.ll.Len())
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		res = append(res, el.Value.(*cacheEntry[K, V]).key)
+	}
+	return res
+}
+
+func (c *LRUCache[K, V]) DeleteExpired() int {
+	var removed []evicted[K, V]
+	c.lock.lock()
+	now := c.now()
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*cacheEntry[K, V]).expired(now) {
+			removed = append(removed, c.removeElement(el, EvictExpired))
+		}
+		el = next
+	}
+	c.lock.unlock()
+	c.notify(removed)
+	return len(removed)
+}
+
+// Purge 清空缓存, 不会触发移除回调
+func (c *LRUCache[K, V]) Purge() {
+	c.lock.lock()
+	defer c.lock.unlock()
+	c.items = map[K]*list.Element{}
+	c.ll.Init()
+}
+
+func (c *LRUCache[K, V]) removeElement(el *list.Element, reason EvictReason) evicted[K, V] {
+	entry := c.ll.Remove(el).(*cacheEntry[K, V])
+	delete(c.items, entry.key)
+	return evicted[K, V]{key: entry.key, value: entry.value, reason: reason}
+}
+
+////////
+// LFU
+////////
+
+// LFUCache 最不经常使用淘汰, 访问次数相同时淘汰最久未使用的
+type LFUCache[K comparable, V any] struct {
+	cacheBase[K, V]
+	items   map[K]*list.Element
+	freqs   map[int]*list.List // <访问次数: 该次数下的entry列表>
+	minFreq int
+}
+
+var _ Cache[string, int] = (*LFUCache[string, int])(nil)
+
+func NewLFUCache[K comparable, V any](capacity int, opts ...collectionOptionFn) *LFUCache[K, V] {
+	return &LFUCache[K, V]{
+		cacheBase: newCacheBase[K, V](capacity, opts...),
+		items:     map[K]*list.Element{},
+		freqs:     map[int]*list.List{},
+	}
+}
+
+func (c *LFUCache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+func (c *LFUCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	var removed []evicted[K, V]
+	c.lock.lock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry[K, V])
+		entry.value = value
+		entry.expireAt = c.expireAt(ttl)
+		c.touch(el)
+	} else {
+		if c.capacity > 0 && len(c.items) >= c.capacity {
+			// 优先淘汰已经过期的
+			removed = c.removeExpired(c.now())
+			if len(c.items) >= c.capacity {
+				removed = append(removed, c.removeElement(c.victim(), EvictCapacity))
+			}
+		}
+		entry := &cacheEntry[K, V]{key: key, value: value, expireAt: c.expireAt(ttl), freq: 1}
+		c.items[key] = c.freqList(1).PushFront(entry)
+		c.minFreq = 1
+	}
+	c.lock.unlock()
+	c.notify(removed)
+}
+
+func (c *LFUCache[K, V]) Get(key K) (V, bool) {
+	return c.get(key, true)
+}
+
+func (c *LFUCache[K, V]) Peek(key K) (V, bool) {
+	return c.get(key, false)
+}
+
+func (c *LFUCache[K, V]) get(key K, touch bool) (V, bool) {
+	var zero V
+	c.lock.lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.lock.unlock()
+		return zero, false
+	}
+	entry := el.Value.(*cacheEntry[K, V])
+	if entry.expired(c.now()) {
+		removed := c.removeElement(el, EvictExpired)
+		c.lock.unlock()
+		c.notify([]evicted[K, V]{removed})
+		return zero, false
+	}
+	if touch {
+		c.touch(el)
+	}
+	c.lock.unlock()
+	return entry.value, true
+}
+
+func (c *LFUCache[K, V]) Has(key K) bool {
+	_, ok := c.Peek(key)
+	return ok
+}
+
+func (c *LFUCache[K, V]) Delete(key K) bool {
+	c.lock.lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.lock.unlock()
+		return false
+	}
+	removed := c.removeElement(el, EvictDeleted)
+	c.lock.unlock()
+	c.notify([]evicted[K, V]{removed})
+	return true
+}
+
+func (c *LFUCache[K, V]) Len() int {
+	c.lock.rlock()
+	defer c.lock.runlock()
+	return len(c.items)
+}
+
+func (c *LFUCache[K, V]) Keys() []K {
+	c.lock.rlock()
+	defer c.lock.runlock()
+
+	res := make([]K, 0, len(c.items))
+	for key := range c.items {
+		res = append(res, key)
+	}
+	return res
+}
+
+// Frequency 返回key的访问次数, 不存在返回0
+func (c *LFUCache[K, V]) Frequency(key K) int {
+	c.lock.rlock()
+	defer c.lock.runlock()
+
+	if el, ok := c.items[key]; ok {
+		return el.Value.(*cacheEntry[K, V]).freq
+	}
+	return 0
+}
+
+func (c *LFUCache[K, V]) DeleteExpired() int {
+	c.lock.lock()
+	removed := c.removeExpired(c.now())
+	c.lock.unlock()
+	c.notify(removed)
+	return len(removed)
+}
+
+// Purge 清空缓存, 不会触发移除回调
+func (c *LFUCache[K, V]) Purge() {
+	c.lock.lock()
+	defer c.lock.unlock()
+	c.items = map[K]*list.Element{}
+	c.freqs = map[int]*list.List{}
+	c.minFreq = 0
+}
+
+func (c *LFUCache[K, V]) freqList(freq int) *list.List {
+	l, ok := c.freqs[freq]
+	if !ok {
+		l = list.New()
+		c.freqs[freq] = l
+	}
+	return l
+}
+
+// touch 访问次数+1, 移动到对应次数列表的最前面
+func (c *LFUCache[K, V]) touch(el *list.Element) {
+	entry := el.Value.(*cacheEntry[K, V])
+	c.detach(el)
+	if _, ok := c.freqs[c.minFreq]; !ok && c.minFreq == entry.freq {
+		c.minFreq++
+	}
+	entry.freq++
+	c.items[entry.key] = c.freqList(entry.freq).PushFront(entry)
+}
+
+// victim 找到需要淘汰的元素
+func (c *LFUCache[K, V]) victim() *list.Element {
+	if l, ok := c.freqs[c.minFreq]; ok {
+		return l.Back()
+	}
+	// minFreq因为删除操作失效了, 重新计算
+	c.minFreq = 0
+	for freq := range c.freqs {
+		if c.minFreq == 0 || freq < c.minFreq {
+			c.minFreq = freq
+		}
+	}
+	return c.freqs[c.minFreq].Back()
+}
+
+func (c *LFUCache[K, V]) detach(el *list.Element) {
+	entry := el.Value.(*cacheEntry[K, V])
+	l := c.freqs[entry.freq]
+	l.Remove(el)
+	if l.Len() == 0 {
+		delete(c.freqs, entry.freq)
+	}
+}
+
+func (c *LFUCache[K, V]) removeElement(el *list.Element, reason EvictReason) evicted[K, V] {
+	entry := el.Value.(*cacheEntry[K, V])
+	c.detach(el)
+	delete(c.items, entry.key)
+	return evicted[K, V]{key: entry.key, value: entry.value, reason: reason}
+}
+
+func (c *LFUCache[K, V]) removeExpired(now time.Time) []evicted[K, V] {
+	var removed []evicted[K, V]
+	for _, el := range c.items {
+		if el.Value.(*cacheEntry[K, V]).expired(now) {
+			removed = append(removed, c.removeElement(el, EvictExpired))
+		}
+	}
+	return removed
+}
@@ -0,0 +1,169 @@
+package basetool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// OrderedMap 按照插入顺序遍历的map
+// 重复Set同一个key不会改变其位置
+type OrderedMap[K comparable, V any] struct {
+	lock  optionalLock
+	data  map[K]*orderedEntry[K, V]
+	front *orderedEntry[K, V]
+	back  *orderedEntry[K, V]
+}
+
+type orderedEntry[K comparable, V any] struct {
+	key   K
+	value V
+	prev  *orderedEntry[K, V]
+	next  *orderedEntry[K, V]
+}
+
+func NewOrderedMap[K comparable, V any](opts ...collectionOptionFn) *OrderedMap[K, V] {
+	opt := newCollectionOption(opts...)
+	return &OrderedMap[K, V]{
+		lock: optionalLock{safe: opt.safe},
+		data: map[K]*orderedEntry[K, V]{},
+	}
+}
+
+// Set 设置值, 返回key是否已经存在
+func (m *OrderedMap[K, V]) Set(key K, value V) bool {
+	m.lock.lock()
+	defer m.lock.unlock()
+
+	if e, ok := m.data[key]; ok {
+		e.value = value
+		return true
+	}
+	e := &orderedEntry[K, V]{key: key, value: value, prev: m.back}
+	if m.back != nil {
+		m.back.next = e
+	} else {
+		m.front = e
+	}
+	m.back = e
+	m.data[key] = e
+	return false
+}
+
+func (m *OrderedMap[K, V]) Get(key K) (V, bool) {
+	m.lock.rlock()
+	defer m.lock.runlock()
+
+	if e, ok := m.data[key]; ok {
+		return e.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (m *OrderedMap[K, V]) Has(key K) bool {
+	m.lock.rlock()
+	defer m.lock.runlock()
+
+	_, ok := m.data[key]
+	return ok
+}
+
+// Delete 删除key, 返回key是否存在
+func (m *OrderedMap[K, V]) Delete(key K) bool {
+	m.lock.lock()
+	defer m.lock.unlock()
+
+	e, ok := m.data[key]
+	if !ok {
+		return false
+	}
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		m.front = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		m.back = e.prev
+	}
+	delete(m.data, key)
+	return true
+}
+
+func (m *OrderedMap[K, V]) Len() int {
+	m.lock.rlock()
+	defer m.lock.runlock()
+	return len(m.data)
+}
+
+func (m *OrderedMap[K, V]) Keys() []K {
+	m.lock.rlock()
+	defer m.lock.runlock()
+
+	res := make([]K, 0, len(m.data))
+	for e := m.front; e != nil; e = e.next {
+		res = append(res, e.key)
+	}
+	return res
+}
+
+func (m *OrderedMap[K, V]) Values() []V {
+	m.lock.rlock()
+	defer m.lock.runlock()
+
+	res := make([]V, 0, len(m.data))
+	for e := m.front; e != nil; e = e.next {
+		res = append(res, e.value)
+	}
+	return res
+}
+
+// Range 按插入顺序遍历, fn返回false时停止
+// 遍历期间持有读锁, fn中不能再修改map
+func (m *OrderedMap[K, V]) Range(fn func(key K, value V) bool) {
+	m.lock.rlock()
+	defer m.lock.runlock()
+
+	for e := m.front; e != nil; e = e.next {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+func (m *OrderedMap[K, V]) Clear() {
+	m.lock.lock()
+	defer m.lock.unlock()
+
+	m.data = map[K]*orderedEntry[K, V]{}
+	m.front, m.back = nil, nil
+}
+
+// MarshalJSON 按插入顺序输出json对象, key通过fmt.Sprint转为字符串
+func (m *OrderedMap[K, V]) MarshalJSON() ([]byte, error) {
+	m.lock.rlock()
+	defer m.lock.runlock()
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for e := m.front; e != nil; e = e.next {
+		key, err := json.Marshal(fmt.Sprint(e.key))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		if e != m.front {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+package basetool
+
+import "container/heap"
+
+// PriorityQueue 基于堆实现的优先队列
+// less(a, b)返回true时a先出队, 例如 a < b 为小顶堆
+type PriorityQueue[T any] struct {
+	lock optionalLock
+	heap *queueHeap[T]
+}
+
+type queueHeap[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+func (h queueHeap[T]) Len() int           { return len(h.items) }
+func (h queueHeap[T]) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h queueHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *queueHeap[T]) Push(x interface{}) {
+	h.items = append(h.items, x.(T))
+}
+
+func (h *queueHeap[T]) Pop() interface{} {
+	n := len(h.items)
+	item := h.items[n-1]
+	var zero T
+	h.items[n-1] = zero // 避免内存泄露
+	h.items = h.items[:n-1]
+	return item
+}
+
+func NewPriorityQueue[T any](less func(a, b T) bool, opts ...collectionOptionFn) *PriorityQueue[T] {
+	opt := newCollectionOption(opts...)
+	return &PriorityQueue[T]{
+		lock: optionalLock{safe: opt.safe},
+		heap: &queueHeap[T]{less: less},
+	}
+}
+
+func (q *PriorityQueue[T]) Push(items ...T) {
+	q.lock.lock()
+	defer q.lock.unlock()
+
+	for _, item := range items {
+		heap.Push(q.heap, item)
+	}
+}
+
+// Pop 弹出优先级最高的元素, 队列为空时返回false
+func (q *PriorityQueue[T]) Pop() (T, bool) {
+	q.lock.lock()
+	defer q.lock.unlock()
+
+	if q.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return heap.Pop(q.heap).(T), true
+}
+
+// Peek 查看优先级最高的元素但不弹出
+func (q *PriorityQueue[T]) Peek() (T, bool) {
+	q.lock.rlock()
+	defer q.lock.runlock()
+
+	if q.heap.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.heap.items[0], true
+}
+
+func (q *PriorityQueue[T]) Len() int {
+	q.lock.rlock()
+	defer q.lock.runlock()
+	return q.heap.Len()
+}
+
+// Remove 删除第一个满足match的元素
+func (q *PriorityQueue[T]) Remove(match func(item T) bool) (T, bool) {
+	q.lock.lock()
+	defer q.lock.unlock()
+
+	for i, item := range q.heap.items {
+		if match(item) {
+			return heap.Remove(q.heap, i).(T), true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Update 修改第一个满足match的元素并重新调整位置
+func (q *PriorityQueue[T]) Update(match func(item T) bool, value T) bool {
+	q.lock.lock()
+	defer q.lock.unlock()
+
+	for i, item := range q.heap.items {
+		if match(item) {
+			q.heap.items[i] = value
+			heap.Fix(q.heap, i)
+			return true
+		}
+	}
+	return false
+}
+
+// Items 返回队列中元素的拷贝, 不保证顺序
+func (q *PriorityQueue[T]) Items() []T {
+	q.lock.rlock()
+	defer q.lock.runlock()
+
+	res := make([]T, len(q.heap.items))
+	copy(res, q.heap.items)
+	return res
+}
@@ -0,0 +1,118 @@
+package basetool
+
+// RingBuffer 固定大小的环形缓冲区
+// 写满之后继续Push会覆盖最旧的数据
+type RingBuffer[T any] struct {
+	lock  optionalLock
+	items []T
+	head  int // 最旧元素的位置
+	size  int
+}
+
+func NewRingBuffer[T any](capacity int, opts ...collectionOptionFn) *RingBuffer[T] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	opt := newCollectionOption(opts...)
+	return &RingBuffer[T]{
+		lock:  optionalLock{safe: opt.safe},
+		items: make([]T, capacity),
+	}
+}
+
+// Push 写入数据, 如果覆盖了最旧的数据则返回被覆盖的值与true
+func (r *RingBuffer[T]) Push(item T) (T, bool) {
+	r.lock.lock()
+	defer r.lock.unlock()
+
+	var overwritten T
+	tail := (r.head + r.size) % len(r.items)
+	if r.size == len(r.items) {
+		overwritten = r.items[r.head]
+		r.items[r.head] = item
+		r.head = (r.head + 1) % len(r.items)
+		return overwritten, true
+	}
+	r.items[tail] = item
+	r.size++
+	return overwritten, false
+}
+
+// Pop 取出最旧的数据
+func (r *RingBuffer[T]) Pop() (T, bool) {
+	r.lock.lock()
+	defer r.lock.unlock()
+
+	var zero T
+	if r.size == 0 {
+		return zero, false
+	}
+	item := r.items[r.head]
+	r.items[r.head] = zero
+	r.head = (r.head + 1) % len(r.items)
+	r.size--
+	return item, true
+}
+
+// Peek 查看最旧的数据
+func (r *RingBuffer[T]) Peek() (T, bool) {
+	r.lock.rlock()
+	defer r.lock.runlock()
+
+	if r.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return r.items[r.head], true
+}
+
+// PeekLast 查看最新的数据
+func (r *RingBuffer[T]) PeekLast() (T, bool) {
+	r.lock.rlock()
+	defer r.lock.runlock()
+
+	if r.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return r.items[(r.head+r.size-1)%len(r.items)], true
+}
+
+func (r *RingBuffer[T]) Len() int {
+	r.lock.rlock()
+	defer r.lock.runlock()
+	return r.size
+}
+
+func (r *RingBuffer[T]) Cap() int {
+	return len(r.items)
+}
+
+func (r *RingBuffer[T]) IsFull() bool {
+	r.lock.rlock()
+	defer r.lock.runlock()
+	return r.size == len(r.items)
+}
+
+// Items 按照从旧到新的顺序返回数据
+func (r *RingBuffer[T]) Items() []T {
+	r.lock.rlock()
+	defer r.lock.runlock()
+
+	res := make([]T, 0, r.size)
+	for i := 0; i < r.size; i++ {
+		res = append(res, r.items[(r.head+i)%len(r.items)])
+	}
+	return res
+}
+
+func (r *RingBuffer[T]) Clear() {
+	r.lock.lock()
+	defer r.lock.unlock()
+
+	var zero T
+	for i := range r.items {
+		r.items[i] = zero
+	}
+	r.head, r.size = 0, 0
+}
@@ -0,0 +1,185 @@
+package basetool
+
+// Set 基于map实现的集合
+// 集合运算返回新的集合, 新集合沿用调用方的safe配置
+type Set[T comparable] struct {
+	lock optionalLock
+	data map[T]struct{}
+}
+
+func NewSet[T comparable](opts ...collectionOptionFn) *Set[T] {
+	opt := newCollectionOption(opts...)
+	return &Set[T]{
+		lock: optionalLock{safe: opt.safe},
+		data: map[T]struct{}{},
+	}
+}
+
+// NewSetFrom 使用已有的元素初始化集合
+func NewSetFrom[T comparable](items []T, opts ...collectionOptionFn) *Set[T] {
+	s := NewSet[T](opts...)
+	for _, item := range items {
+		s.data[item] = struct{}{}
+	}
+	return s
+}
+
+func (s *Set[T]) newLike() *Set[T] {
+	return &Set[T]{
+		lock: optionalLock{safe: s.lock.safe},
+		data: map[T]struct{}{},
+	}
+}
+
+func (s *Set[T]) Add(items ...T) {
+	s.lock.lock()
+	defer s.lock.unlock()
+
+	for _, item := range items {
+		s.data[item] = struct{}{}
+	}
+}
+
+func (s *Set[T]) Remove(items ...T) {
+	s.lock.lock()
+	defer s.lock.unlock()
+
+	for _, item := range items {
+		delete(s.data, item)
+	}
+}
+
+func (s *Set[T]) Has(item T) bool {
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	_, ok := s.data[item]
+	return ok
+}
+
+func (s *Set[T]) Len() int {
+	s.lock.rlock()
+	defer s.lock.runlock()
+	return len(s.data)
+}
+
+// Items 返回集合中的元素, 顺序不固定
+func (s *Set[T]) Items() []T {
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	res := make([]T, 0, len(s.data))
+	for item := range s.data {
+		res = append(res, item)
+	}
+	return res
+}
+
+func (s *Set[T]) Clone() *Set[T] {
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	res := s.newLike()
+	for item := range s.data {
+		res.data[item] = struct{}{}
+	}
+	return res
+}
+
+// snapshot 在自己的锁下复制元素
+// 两个集合的运算先复制other再锁s, 不同时持有两把锁, 避免a.Intersect(b)与b.Intersect(a)并发时死锁
+func (s *Set[T]) snapshot() map[T]struct{} {
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	res := make(map[T]struct{}, len(s.data))
+	for item := range s.data {
+		res[item] = struct{}{}
+	}
+	return res
+}
+
+// Union 并集
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	res := s.Clone()
+	other.lock.rlock()
+	defer other.lock.runlock()
+
+	for item := range other.data {
+		res.data[item] = struct{}{}
+	}
+	return res
+}
+
+// Intersect 交集
+func (s *Set[T]) Intersect(other *Set[T]) *Set[T] {
+	if s == other {
+		return s.Clone()
+	}
+	res := s.newLike()
+	items := other.snapshot()
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	for item := range s.data {
+		if _, ok := items[item]; ok {
+			res.data[item] = struct{}{}
+		}
+	}
+	return res
+}
+
+// Difference 差集, 在s中但不在other中的元素
+func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
+	res := s.newLike()
+	if s == other {
+		return res
+	}
+	items := other.snapshot()
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	for item := range s.data {
+		if _, ok := items[item]; !ok {
+			res.data[item] = struct{}{}
+		}
+	}
+	return res
+}
+
+// SymmetricDifference 对称差集, 只在其中一个集合中出现的元素
+func (s *Set[T]) SymmetricDifference(other *Set[T]) *Set[T] {
+	res := s.Difference(other)
+	for _, item := range other.Difference(s).Items() {
+		res.data[item] = struct{}{}
+	}
+	return res
+}
+
+// IsSubset s中的元素是否都在other中
+func (s *Set[T]) IsSubset(other *Set[T]) bool {
+	if s == other {
+		return true
+	}
+	items := other.snapshot()
+	s.lock.rlock()
+	defer s.lock.runlock()
+
+	if len(s.data) > len(items) {
+		return false
+	}
+	for item := range s.data {
+		if _, ok := items[item]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Set[T]) IsSuperset(other *Set[T]) bool {
+	return other.IsSubset(s)
+}
+
+func (s *Set[T]) Equal(other *Set[T]) bool {
+	return s.Len() == other.Len() && s.IsSubset(other)
+}
@@ -0,0 +1,263 @@
+package basetool
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap[string, int]()
+	m.Set("c", 3)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	if exist := m.Set("a", 10); !exist {
+		t.Error("a should exist")
+	}
+	if !reflect.DeepEqual(m.Keys(), []string{"c", "a", "b"}) {
+		t.Errorf("keys order error: %v", m.Keys())
+	}
+	if v, ok := m.Get("a"); !ok || v != 10 {
+		t.Errorf("get a = %v, %v", v, ok)
+	}
+
+	m.Delete("c")
+	m.Set("d", 4)
+	if !reflect.DeepEqual(m.Values(), []int{10, 2, 4}) {
+		t.Errorf("values order error: %v", m.Values())
+	}
+
+	data, err := m.MarshalJSON()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(data) != `{"a":10,"b":2,"d":4}` {
+		t.Errorf("json error: %s", data)
+	}
+
+	visited := []string{}
+	m.Range(func(key string, value int) bool {
+		visited = append(visited, key)
+		return key != "b"
+	})
+	if !reflect.DeepEqual(visited, []string{"a", "b"}) {
+		t.Errorf("range error: %v", visited)
+	}
+}
+
+func TestSet(t *testing.T) {
+	sorted := func(s *Set[int]) []int {
+		items := s.Items()
+		sort.Ints(items)
+		return items
+	}
+
+	a := NewSetFrom([]int{1, 2, 3, 4})
+	b := NewSetFrom([]int{3, 4, 5})
+
+	if res := sorted(a.Union(b)); !reflect.DeepEqual(res, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("union error: %v", res)
+	}
+	if res := sorted(a.Intersect(b)); !reflect.DeepEqual(res, []int{3, 4}) {
+		t.Errorf("intersect error: %v", res)
+	}
+	if res := sorted(a.Difference(b)); !reflect.DeepEqual(res, []int{1, 2}) {
+		t.Errorf("difference error: %v", res)
+	}
+	if res := sorted(a.SymmetricDifference(b)); !reflect.DeepEqual(res, []int{1, 2, 5}) {
+		t.Errorf("symmetric difference error: %v", res)
+	}
+	if !NewSetFrom([]int{3, 4}).IsSubset(a) || a.IsSubset(b) {
+		t.Error("subset error")
+	}
+	if !a.Equal(NewSetFrom([]int{4, 3, 2, 1})) {
+		t.Error("equal error")
+	}
+
+	a.Remove(1, 2)
+	if a.Has(1) || a.Len() != 2 {
+		t.Errorf("remove error: %v", a.Items())
+	}
+
+	// 两个集合互相运算, 同时有写入时不会死锁
+	// 依次构造: 持有y的读锁, y上等待的写锁, x.Intersect(y), x上等待的写锁, 再获取x的读锁
+	// 同时持有两把读锁时, 后两步因为等待中的写锁互相阻塞
+	x, y := NewSet[int](WithSafe()), NewSet[int](WithSafe())
+	done := make(chan struct{})
+	go func() {
+		y.lock.rlock()
+		go y.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		go x.Intersect(y)
+		time.Sleep(10 * time.Millisecond)
+		go x.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		x.lock.rlock()
+		x.lock.runlock()
+		y.lock.runlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("set operations deadlocked")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	evicts := map[string]EvictReason{}
+	c := NewLRUCache[string, int](2)
+	c.OnEvict(func(key string, value int, reason EvictReason) {
+		evicts[key] = reason
+	})
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a") // b变为最久未使用
+	c.Set("c", 3)
+	if c.Has("b") {
+		t.Error("b should be evicted")
+	}
+	if evicts["b"] != EvictCapacity {
+		t.Errorf("b evict reason error: %v", evicts["b"])
+	}
+	if !reflect.DeepEqual(c.Keys(), []string{"c", "a"}) {
+		t.Errorf("keys error: %v", c.Keys())
+	}
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.SetWithTTL("d", 4, time.Second)
+	if _, ok := c.Get("d"); !ok {
+		t.Error("d should exist")
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok := c.Get("d"); ok {
+		t.Error("d should be expired")
+	}
+	if evicts["d"] != EvictExpired {
+		t.Errorf("d evict reason error: %v", evicts["d"])
+	}
+
+	c.Delete("c")
+	if evicts["c"] != EvictDeleted || c.Len() != 0 {
+		t.Errorf("delete error, len=%d", c.Len())
+	}
+}
+
+func TestLFUCache(t *testing.T) {
+	evicts := []string{}
+	c := NewLFUCache[string, int](2)
+	c.OnEvict(func(key string, value int, reason EvictReason) {
+		evicts = append(evicts, key)
+	})
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	c.Set("c", 3) // b的访问次数少于a
+	if !reflect.DeepEqual(evicts, []string{"b"}) {
+		t.Errorf("evict error: %v", evicts)
+	}
+	if c.Frequency("a") != 3 || c.Frequency("c") != 1 {
+		t.Errorf("frequency error: a=%d c=%d", c.Frequency("a"), c.Frequency("c"))
+	}
+
+	c.Delete("c")
+	c.Set("d", 4)
+	c.Set("e", 5) // d的访问次数最少
+	if !c.Has("a") || c.Has("d") || !c.Has("e") {
+		t.Errorf("keys error: %v", c.Keys())
+	}
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	c.SetWithTTL("e", 5, time.Second)
+	now = now.Add(time.Minute)
+	if n := c.DeleteExpired(); n != 1 {
+		t.Errorf("delete expired error: %d", n)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	caches := []Cache[int, int]{
+		NewLRUCache[int, int](100, WithSafe(), WithTTL(time.Minute)),
+		NewLFUCache[int, int](100, WithSafe(), WithTTL(time.Minute)),
+	}
+	for _, c := range caches {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(base int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					c.Set(base*100+j, j)
+					c.Get(base*100 + j/2)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if c.Len() != 100 {
+			t.Errorf("cache len error: %d", c.Len())
+		}
+	}
+}
+
+func TestPriorityQueue(t *testing.T) {
+	type task struct {
+		name     string
+		priority int
+	}
+	q := NewPriorityQueue(func(a, b task) bool {
+		return a.priority > b.priority
+	})
+	q.Push(task{"low", 1}, task{"high", 10}, task{"mid", 5})
+
+	if item, _ := q.Peek(); item.name != "high" {
+		t.Errorf("peek error: %v", item)
+	}
+	q.Update(func(item task) bool { return item.name == "low" }, task{"low", 20})
+
+	res := []string{}
+	for q.Len() > 0 {
+		item, _ := q.Pop()
+		res = append(res, item.name)
+	}
+	if !reflect.DeepEqual(res, []string{"low", "high", "mid"}) {
+		t.Errorf("pop order error: %v", res)
+	}
+	if _, ok := q.Pop(); ok {
+		t.Error("empty queue should return false")
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := NewRingBuffer[int](3)
+	for i := 1; i <= 3; i++ {
+		if _, overwritten := r.Push(i); overwritten {
+			t.Error("should not overwrite")
+		}
+	}
+	if old, overwritten := r.Push(4); !overwritten || old != 1 {
+		t.Errorf("overwrite error: %v %v", old, overwritten)
+	}
+	if !reflect.DeepEqual(r.Items(), []int{2, 3, 4}) {
+		t.Errorf("items error: %v", r.Items())
+	}
+	if v, _ := r.Pop(); v != 2 {
+		t.Errorf("pop error: %v", v)
+	}
+	if v, _ := r.PeekLast(); v != 4 {
+		t.Errorf("peek last error: %v", v)
+	}
+	r.Push(5)
+	r.Push(6)
+	if !reflect.DeepEqual(r.Items(), []int{4, 5, 6}) || !r.IsFull() {
+		t.Errorf("items error: %v", r.Items())
+	}
+}
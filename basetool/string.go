@@ -81,18 +81,29 @@ func UnicodeIndex(str, substr string) int {
 	return result
 }
 
-// 字符串模板解析
+// 字符串模板解析, 出错时panic, 需要处理错误时使用TemplateResolveE
 func TemplateResolve(temp string, data interface{}) string {
-	t, _ := template.New("string-temp").Parse(temp)
-	var tmplBytes bytes.Buffer
-
-	err := t.Execute(&tmplBytes, data)
+	res, err := TemplateResolveE(temp, data)
 	if err != nil {
 		panic(err)
 	}
-	return tmplBytes.String()
+	return res
+}
+
+// TemplateResolveE 字符串模板解析, 返回解析与执行时的错误
+func TemplateResolveE(temp string, data interface{}) (string, error) {
+	t, err := template.New("string-temp").Parse(temp)
+	if err != nil {
+		return "", err
+	}
+	var tmplBytes bytes.Buffer
+	if err := t.Execute(&tmplBytes, data); err != nil {
+		return "", err
+	}
+	return tmplBytes.String(), nil
 }
 
+// Deprecated: 不支持类型与重复的分隔符, 使用CompilePattern
 func ReverStrTemplate(temp, str string, res map[string]interface{}) {
 	index := UnicodeIndex(temp, "{")
 	ei := UnicodeIndex(temp, "}") + 1
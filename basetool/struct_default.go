@@ -0,0 +1,363 @@
+package basetool
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+////////
+// default tag
+// default:"8080"              标量、time.Duration(1m30s)、time.Time(RFC3339或2006-01-02 15:04:05)
+// default:"a,b,c"             切片使用逗号分隔
+// default:"[1,2]" / "{..}"    切片、数组、map、struct使用json字面量
+// default:"${PORT:-8080}"     环境变量, ${VAR:-def}为空时使用def, ${VAR-def}未设置时使用def
+// default:"fn:uuid"           使用RegisterDefaultProvider注册的函数生成
+////////
+
+var ErrNoDefaultProvider = errors.New("default provider is not registered")
+
+// DefaultProvider 动态生成默认值
+// 返回值可以是能赋值给字段的值, 也可以是字符串, 字符串会按照字段类型解析
+type DefaultProvider func() (interface{}, error)
+
+var (
+	defaultProvidersMu sync.RWMutex
+	defaultProviders   = map[string]DefaultProvider{
+		"uuid": func() (interface{}, error) { return newUUID() },
+		"now":  func() (interface{}, error) { return time.Now(), nil },
+	}
+
+	defaultEnvRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?-)([^}]*))?\}`)
+
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	timeLayouts  = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+)
+
+// RegisterDefaultProvider 注册默认值函数, 同名会覆盖
+func RegisterDefaultProvider(name string, fn DefaultProvider) {
+	defaultProvidersMu.Lock()
+	defer defaultProvidersMu.Unlock()
+	defaultProviders[name] = fn
+}
+
+func lookupDefaultProvider(name string) (DefaultProvider, bool) {
+	defaultProvidersMu.RLock()
+	defer defaultProvidersMu.RUnlock()
+	fn, ok := defaultProviders[name]
+	return fn, ok
+}
+
+// LoadDefault parses a struct pointer for `default` tags. If the default tag is
+// set and the struct member is a zero value, the default value will be
+// set to the member. Nested structs and struct pointers are handled
+// recursively, nil struct pointers are allocated.
+func LoadDefault(t interface{}) error {
+	// Make sure we've been given a pointer.
+	val := reflect.ValueOf(t)
+	if val.Kind() != reflect.Ptr {
+		return newErrNotAStructPointer(t)
+	}
+
+	// Make sure the pointer is pointing to a struct.
+	ref := val.Elem()
+	if ref.Kind() != reflect.Struct {
+		return newErrNotAStructPointer(t)
+	}
+
+	return parseFields(ref)
+}
+
+func parseFields(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		// 未导出且没有tag的字段直接跳过
+		if field.PkgPath != "" && field.Tag.Get("default") == "" {
+			continue
+		}
+		if err := parseField(v.Field(i), field); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func parseField(value reflect.Value, field reflect.StructField) error {
+	tagVal := field.Tag.Get("default")
+	if tagVal == "-" {
+		return nil
+	}
+
+	// 不是空就不需要设置， 但是对于struct可能存在部分是空的，需要额外判断
+	if tagVal == "" || !value.IsZero() {
+		return loadNestedDefault(value)
+	}
+
+	if !value.CanSet() {
+		return ErrorUnsettable(field.Name)
+	}
+
+	if name := strings.TrimPrefix(tagVal, "fn:"); name != tagVal {
+		if err := setProviderValue(value, name); err != nil {
+			return err
+		}
+	} else if err := setValueFromString(value, expandDefaultEnv(tagVal)); err != nil {
+		return err
+	}
+	return loadNestedDefault(value)
+}
+
+// loadNestedDefault 递归处理struct与struct指针, nil指针会被初始化
+func loadNestedDefault(value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return nil
+		}
+		return parseFields(value)
+
+	case reflect.Ptr:
+		ref := value.Type().Elem()
+		if ref.Kind() != reflect.Struct || ref == timeType || ref.NumField() == 0 {
+			return nil
+		}
+		if value.IsNil() {
+			if !value.CanSet() {
+				return nil
+			}
+			value.Set(reflect.New(ref))
+		}
+		return parseFields(value.Elem())
+	}
+	return nil
+}
+
+func setProviderValue(value reflect.Value, name string) error {
+	provider, ok := lookupDefaultProvider(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoDefaultProvider, name)
+	}
+	res, err := provider()
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(res)
+	typ := value.Type()
+	switch {
+	case !rv.IsValid():
+		return nil
+	case rv.Type().AssignableTo(typ):
+		value.Set(rv)
+	case typ.Kind() == reflect.Ptr && rv.Type().AssignableTo(typ.Elem()):
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(rv)
+		value.Set(ptr)
+	case rv.Kind() == reflect.String:
+		return setValueFromString(value, rv.String())
+	case typ.Kind() == reflect.String:
+		value.SetString(ToString(res))
+	case rv.Type().ConvertibleTo(typ):
+		value.Set(rv.Convert(typ))
+	default:
+		return ErrMismatchValue
+	}
+	return nil
+}
+
+func expandDefaultEnv(str string) string {
+	if !strings.Contains(str, "${") {
+		return str
+	}
+	return defaultEnvRe.ReplaceAllStringFunc(str, func(item string) string {
+		groups := defaultEnvRe.FindStringSubmatch(item)
+		val, ok := os.LookupEnv(groups[1])
+		switch {
+		case groups[2] == ":-" && val == "":
+			return groups[3]
+		case groups[2] == "-" && !ok:
+			return groups[3]
+		}
+		return val
+	})
+}
+
+// setValueFromString 根据字段的类型把字符串转换后赋值
+func setValueFromString(v reflect.Value, str string) error {
+	if !v.CanSet() {
+		return ErrorUnsettable(v.Type().String())
+	}
+
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+
+	case timeType:
+		var err error
+		for _, layout := range timeLayouts {
+			var t time.Time
+			if t, err = time.ParseInLocation(layout, str, time.Local); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.ToLower(str))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	// NB: int32 is also an alias for a rune
+	case reflect.Int32:
+		i, err := parseInt32(str)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(i))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(str, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		// a []uint8 is a an alias for a []byte
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(str))
+			return nil
+		}
+		if isJSONLiteral(str, '[') {
+			return setValueFromJSON(v, str)
+		}
+		return setSliceFromString(v, str)
+
+	case reflect.Array:
+		if !isJSONLiteral(str, '[') {
+			return ErrorUnsupportedType{v.Type()}
+		}
+		return setValueFromJSON(v, str)
+
+	case reflect.Map, reflect.Struct:
+		if !isJSONLiteral(str, '{') {
+			return ErrorUnsupportedType{v.Type()}
+		}
+		return setValueFromJSON(v, str)
+
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setValueFromString(elem.Elem(), str); err != nil {
+			return err
+		}
+		v.Set(elem)
+
+	case reflect.Interface:
+		if isJSONLiteral(str, '[') || isJSONLiteral(str, '{') {
+			return setValueFromJSON(v, str)
+		}
+		v.Set(reflect.ValueOf(str))
+
+	default:
+		return ErrorUnsupportedType{v.Type()}
+	}
+	return nil
+}
+
+// setSliceFromString 逗号分隔的切片
+func setSliceFromString(v reflect.Value, str string) error {
+	items := []string{}
+	if str != "" {
+		items = strings.Split(str, ",")
+	}
+	res := reflect.MakeSlice(v.Type(), len(items), len(items))
+	trim := v.Type().Elem().Kind() != reflect.String
+	for i, item := range items {
+		if trim {
+			item = strings.TrimSpace(item)
+		}
+		if err := setValueFromString(res.Index(i), item); err != nil {
+			return err
+		}
+	}
+	v.Set(res)
+	return nil
+}
+
+func setValueFromJSON(v reflect.Value, str string) error {
+	ptr := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(str), ptr.Interface()); err != nil {
+		return err
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+func isJSONLiteral(str string, start byte) bool {
+	str = strings.TrimSpace(str)
+	return len(str) >= 2 && str[0] == start && json.Valid([]byte(str))
+}
+
+// Attempt to parse a string as an int32 and, failing that, a rune.
+func parseInt32(s string) (int32, error) {
+	// Try parsing it as an int.
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err == nil {
+		return int32(i), nil
+	}
+
+	// We couldn't parse it as an int, maybe it's a rune.
+	runes := []rune(s)
+	if len(runes) == 1 {
+		return runes[0], nil
+	} else {
+		return 0, err
+	}
+}
+
+// newUUID 生成uuid v4
+func newUUID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:]), nil
+}
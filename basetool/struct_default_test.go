@@ -0,0 +1,106 @@
+package basetool
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDefaultDB struct {
+	Host    string        `default:"${TEST_DEFAULT_HOST:-localhost}"`
+	Port    int           `default:"${TEST_DEFAULT_PORT:-3306}"`
+	Timeout time.Duration `default:"1m30s"`
+}
+
+type testDefaultConfig struct {
+	Name     string            `default:"app"`
+	Debug    *bool             `default:"true"`
+	Ratio    float32           `default:"0.5"`
+	Tags     []string          `default:"a,b"`
+	Ports    []uint16          `default:"80, 443"`
+	Matrix   [][]int           `default:"[[1,2],[3]]"`
+	Labels   map[string]string `default:"{\"env\":\"dev\"}"`
+	Start    time.Time         `default:"2024-01-02 15:04:05"`
+	ID       string            `default:"fn:uuid"`
+	Created  *time.Time        `default:"fn:now"`
+	DB       testDefaultDB
+	Replica  *testDefaultDB
+	Skip     string `default:"-"`
+	internal string
+}
+
+func TestLoadDefault(t *testing.T) {
+	t.Setenv("TEST_DEFAULT_PORT", "5432")
+
+	cfg := testDefaultConfig{Name: "custom"}
+	if err := LoadDefault(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Name != "custom" {
+		t.Errorf("non-zero field should not change: %s", cfg.Name)
+	}
+	if cfg.Debug == nil || !*cfg.Debug || cfg.Ratio != 0.5 {
+		t.Errorf("scalar error: %v %v", cfg.Debug, cfg.Ratio)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || !reflect.DeepEqual(cfg.Ports, []uint16{80, 443}) {
+		t.Errorf("slice error: %v %v", cfg.Tags, cfg.Ports)
+	}
+	if !reflect.DeepEqual(cfg.Matrix, [][]int{{1, 2}, {3}}) || cfg.Labels["env"] != "dev" {
+		t.Errorf("json error: %v %v", cfg.Matrix, cfg.Labels)
+	}
+	if want := time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local); !cfg.Start.Equal(want) {
+		t.Errorf("time error: %v", cfg.Start)
+	}
+	if len(cfg.ID) != 36 || strings.Count(cfg.ID, "-") != 4 || cfg.Created == nil || cfg.Created.IsZero() {
+		t.Errorf("provider error: %v %v", cfg.ID, cfg.Created)
+	}
+	if cfg.DB.Host != "localhost" || cfg.DB.Port != 5432 || cfg.DB.Timeout != 90*time.Second {
+		t.Errorf("nested error: %+v", cfg.DB)
+	}
+	if cfg.Replica == nil || cfg.Replica.Port != 5432 {
+		t.Errorf("pointer struct error: %+v", cfg.Replica)
+	}
+	if cfg.Skip != "" || cfg.internal != "" {
+		t.Error("skipped field should be empty")
+	}
+}
+
+func TestLoadDefaultProvider(t *testing.T) {
+	RegisterDefaultProvider("test_port", func() (interface{}, error) { return 8080, nil })
+	RegisterDefaultProvider("test_fail", func() (interface{}, error) { return nil, errors.New("fail") })
+
+	var ok struct {
+		Port  int64  `default:"fn:test_port"`
+		Label string `default:"fn:test_port"`
+	}
+	if err := LoadDefault(&ok); err != nil || ok.Port != 8080 || ok.Label != "8080" {
+		t.Errorf("provider error: %+v %v", ok, err)
+	}
+
+	var missing struct {
+		Value string `default:"fn:not_exist"`
+	}
+	if err := LoadDefault(&missing); !errors.Is(err, ErrNoDefaultProvider) {
+		t.Errorf("missing provider error: %v", err)
+	}
+
+	var fail struct {
+		Value string `default:"fn:test_fail"`
+	}
+	if err := LoadDefault(&fail); err == nil {
+		t.Error("provider error should be returned")
+	}
+
+	var bad struct {
+		Timeout time.Duration `default:"abc"`
+	}
+	if err := LoadDefault(&bad); err == nil {
+		t.Error("invalid duration should return error")
+	}
+	if err := LoadDefault(bad); err == nil {
+		t.Error("non pointer should return error")
+	}
+}
@@ -0,0 +1,746 @@
+package basetool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+////////
+// 结构体对比与补丁
+// 路径使用json tag中的名字, 没有tag时使用字段名, 切片使用下标, map使用key
+// 可以输出 RFC 6902 JSON Patch 与 RFC 7396 merge patch, 也可以把它们应用到结构体上
+////////
+
+var (
+	ErrDiffTypeMismatch = errors.New("values to diff are not the same type")
+	ErrPatchPath        = errors.New("patch path is invalid")
+	ErrPatchOp          = errors.New("patch operation is invalid")
+	ErrPatchTest        = errors.New("patch test operation failed")
+)
+
+// merge patch中的null, 与remove不同的是目标不存在时忽略
+const mergeRemoveOp = "merge-remove"
+
+type DiffOp string
+
+const (
+	DiffAdd     DiffOp = "add"
+	DiffRemove  DiffOp = "remove"
+	DiffReplace DiffOp = "replace"
+)
+
+// FieldChange 一个字段的变化
+type FieldChange struct {
+	Op   DiffOp
+	Path []string
+	From interface{}
+	To   interface{}
+
+	// merge patch中数组只能整体替换, 记录第一个数组所在的路径以及它的新值
+	mergePath  []string
+	mergeValue interface{}
+}
+
+// Pointer 返回 RFC 6901 JSON Pointer, 例如 /user/tags/0
+func (c FieldChange) Pointer() string {
+	return jsonPointer(c.Path)
+}
+
+func (c FieldChange) String() string {
+	switch c.Op {
+	case DiffAdd:
+		return fmt.Sprintf("add %s: %v", c.Pointer(), c.To)
+	case DiffRemove:
+		return fmt.Sprintf("remove %s: %v", c.Pointer(), c.From)
+	default:
+		return fmt.Sprintf("replace %s: %v -> %v", c.Pointer(), c.From, c.To)
+	}
+}
+
+// Changes 按照字段顺序排列的变化
+type Changes []FieldChange
+
+// DiffStruct 对比两个相同类型的结构体(或者*Instance), 返回从a变为b的所有变化
+func DiffStruct(a, b interface{}) (Changes, error) {
+	av, err := diffReflectValue(a)
+	if err != nil {
+		return nil, err
+	}
+	bv, err := diffReflectValue(b)
+	if err != nil {
+		return nil, err
+	}
+	if av.Type() != bv.Type() {
+		return nil, ErrDiffTypeMismatch
+	}
+
+	d := &differ{}
+	d.diff(nil, av, bv)
+	return d.changes, nil
+}
+
+func diffReflectValue(obj interface{}) (reflect.Value, error) {
+	if in, ok := obj.(*Instance); ok {
+		return in.instance, nil
+	}
+	return getReflectValue(obj)
+}
+
+type differ struct {
+	changes   Changes
+	mergePath []string
+	mergeTo   reflect.Value
+}
+
+func (d *differ) add(op DiffOp, path []string, from, to reflect.Value) {
+	change := FieldChange{Op: op, Path: append([]string{}, path...)}
+	if from.IsValid() {
+		change.From = from.Interface()
+	}
+	if to.IsValid() {
+		change.To = to.Interface()
+	}
+	if d.mergePath != nil {
+		change.mergePath = d.mergePath
+		change.mergeValue = d.mergeTo.Interface()
+	} else {
+		change.mergePath = change.Path
+		change.mergeValue = change.To
+	}
+	d.changes = append(d.changes, change)
+}
+
+func (d *differ) diff(path []string, a, b reflect.Value) {
+	if isDiffLeaf(a.Type()) {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(DiffReplace, path, a, b)
+		}
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr:
+		switch {
+		case a.IsNil() && b.IsNil():
+		case a.IsNil() || b.IsNil():
+			d.add(DiffReplace, path, a, b)
+		default:
+			d.diff(path, a.Elem(), b.Elem())
+		}
+
+	case reflect.Struct:
+		for _, field := range jsonFields(a.Type()) {
+			d.diff(append(path, field.name), a.FieldByIndex(field.index), b.FieldByIndex(field.index))
+		}
+
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() && (a.Len() == 0 || b.Len() == 0) {
+			d.add(DiffReplace, path, a, b)
+			return
+		}
+		keys := map[string]reflect.Value{}
+		for _, key := range a.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		for _, key := range b.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			av, bv := a.MapIndex(keys[name]), b.MapIndex(keys[name])
+			switch {
+			case !av.IsValid():
+				d.add(DiffAdd, append(path, name), av, bv)
+			case !bv.IsValid():
+				d.add(DiffRemove, append(path, name), av, bv)
+			default:
+				d.diff(append(path, name), av, bv)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice && a.IsNil() != b.IsNil() && (a.Len() == 0 || b.Len() == 0) {
+			d.add(DiffReplace, path, a, b)
+			return
+		}
+		if d.mergePath == nil {
+			d.mergePath, d.mergeTo = append([]string{}, path...), b
+			defer func() { d.mergePath = nil }()
+		}
+		n := a.Len()
+		if b.Len() < n {
+			n = b.Len()
+		}
+		for i := 0; i < n; i++ {
+			d.diff(append(path, strconv.Itoa(i)), a.Index(i), b.Index(i))
+		}
+		for i := n; i < b.Len(); i++ {
+			d.add(DiffAdd, append(path, strconv.Itoa(i)), reflect.Value{}, b.Index(i))
+		}
+		// 从后往前删除, 保证下标有效
+		for i := a.Len() - 1; i >= n; i-- {
+			d.add(DiffRemove, append(path, strconv.Itoa(i)), a.Index(i), reflect.Value{})
+		}
+
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(DiffReplace, path, a, b)
+		}
+	}
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// isDiffLeaf 不需要继续展开对比的类型
+func isDiffLeaf(t reflect.Type) bool {
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Struct, reflect.Map, reflect.Array:
+		return false
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return true
+}
+
+type jsonField struct {
+	name  string
+	index []int
+}
+
+// jsonFields 与encoding/json一致的字段名, 会展开匿名的struct
+func jsonFields(t reflect.Type) []jsonField {
+	res := []jsonField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for _, item := range jsonFields(field.Type) {
+				item.index = append([]int{i}, item.index...)
+				res = append(res, item)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		res = append(res, jsonField{name: name, index: []int{i}})
+	}
+	return res
+}
+
+func lookupJSONField(t reflect.Type, name string) (jsonField, bool) {
+	fields := jsonFields(t)
+	for _, field := range fields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(field.name, name) {
+			return field, true
+		}
+	}
+	return jsonField{}, false
+}
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch 生成 RFC 6902 JSON Patch 文档
+func (c Changes) JSONPatch() ([]byte, error) {
+	ops := make([]jsonPatchOperation, 0, len(c))
+	for _, change := range c {
+		op := jsonPatchOperation{Op: string(change.Op), Path: change.Pointer()}
+		if change.Op != DiffRemove {
+			value, err := json.Marshal(change.To)
+			if err != nil {
+				return nil, err
+			}
+			op.Value = value
+		}
+		ops = append(ops, op)
+	}
+	return json.Marshal(ops)
+}
+
+// MergePatch 生成 RFC 7396 merge patch 文档, 数组会整体替换
+func (c Changes) MergePatch() ([]byte, error) {
+	doc := map[string]interface{}{}
+	for _, change := range c {
+		path := change.mergePath
+		if len(path) == 0 {
+			continue
+		}
+		cur := doc
+		for _, part := range path[:len(path)-1] {
+			next, ok := cur[part].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				cur[part] = next
+			}
+			cur = next
+		}
+		last := path[len(path)-1]
+		if change.Op == DiffRemove && len(path) == len(change.Path) {
+			cur[last] = nil
+		} else {
+			cur[last] = change.mergeValue
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func jsonPointer(path []string) string {
+	var buf strings.Builder
+	for _, part := range path {
+		buf.WriteByte('/')
+		part = strings.ReplaceAll(part, "~", "~0")
+		buf.WriteString(strings.ReplaceAll(part, "/", "~1"))
+	}
+	return buf.String()
+}
+
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: %s", ErrPatchPath, pointer)
+	}
+	parts := strings.Split(pointer[1:], "/")
+	for i, part := range parts {
+		part = strings.ReplaceAll(part, "~1", "/")
+		parts[i] = strings.ReplaceAll(part, "~0", "~")
+	}
+	return parts, nil
+}
+
+// ApplyJSONPatch 把 RFC 6902 JSON Patch 应用到结构体指针(或者*Instance)上
+// 所有操作都成功后才会通过SetValue写回, 任意操作失败时obj不会被修改
+func ApplyJSONPatch(obj interface{}, patch []byte) error {
+	var ops []jsonPatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return err
+	}
+	return applyPatch(obj, func(doc reflect.Value, touched map[string]bool) error {
+		for _, op := range ops {
+			if err := applyPatchOperation(doc, op, touched); err != nil {
+				return fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ApplyMergePatch 把 RFC 7396 merge patch 应用到结构体指针(或者*Instance)上
+// null表示删除, 对于结构体字段会设置为零值
+func ApplyMergePatch(obj interface{}, patch []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &doc); err != nil {
+		return err
+	}
+	return applyPatch(obj, func(target reflect.Value, touched map[string]bool) error {
+		ops, err := mergePatchOperations(target.Type(), nil, doc)
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if err := applyPatchOperation(target, op, touched); err != nil {
+				return fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// mergePatchOperations 把merge patch转换为JSON Patch操作
+func mergePatchOperations(t reflect.Type, path []string, doc map[string]json.RawMessage) ([]jsonPatchOperation, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := []jsonPatchOperation{}
+	for _, key := range keys {
+		raw := doc[key]
+		cur := append(append([]string{}, path...), key)
+
+		var child reflect.Type
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := lookupJSONField(t, key)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrPatchPath, jsonPointer(cur))
+			}
+			child = t.FieldByIndex(field.index).Type
+		case reflect.Map:
+			child = t.Elem()
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrPatchPath, jsonPointer(cur))
+		}
+
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			op := mergeRemoveOp
+			if t.Kind() == reflect.Struct {
+				op = "replace"
+			}
+			res = append(res, jsonPatchOperation{Op: op, Path: jsonPointer(cur), Value: raw})
+			continue
+		}
+
+		var sub map[string]json.RawMessage
+		base := child
+		for base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+		if (base.Kind() == reflect.Struct && !isDiffLeaf(base) || base.Kind() == reflect.Map) && json.Unmarshal(raw, &sub) == nil {
+			ops, err := mergePatchOperations(child, cur, sub)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, ops...)
+			continue
+		}
+
+		op := "replace"
+		if t.Kind() == reflect.Map {
+			op = "add"
+		}
+		res = append(res, jsonPatchOperation{Op: op, Path: jsonPointer(cur), Value: raw})
+	}
+	return res, nil
+}
+
+// applyPatch 在obj的深拷贝上执行修改, 成功后再把修改过的字段写回
+func applyPatch(obj interface{}, fn func(doc reflect.Value, touched map[string]bool) error) error {
+	if in, ok := obj.(*Instance); ok {
+		obj = in.Addr()
+	}
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr {
+		return ErrNotPtr
+	}
+	if val.Elem().Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+
+	doc := deepCopyValue(val.Elem())
+	touched := map[string]bool{}
+	if err := fn(doc, touched); err != nil {
+		return err
+	}
+	for i := 0; i < doc.NumField(); i++ {
+		field := doc.Type().Field(i)
+		if !touched[field.Name] {
+			continue
+		}
+		newValue := doc.Field(i)
+		if field.Type.Kind() == reflect.Interface {
+			val.Elem().Field(i).Set(newValue)
+			continue
+		}
+		if err := SetValue(obj, field.Name, newValue.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyPatchOperation(doc reflect.Value, op jsonPatchOperation, touched map[string]bool) error {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		return fmt.Errorf("%w: can not patch the whole document", ErrPatchPath)
+	}
+	if field, ok := lookupJSONField(doc.Type(), path[0]); ok {
+		touched[doc.Type().Field(field.index[0]).Name] = true
+	}
+
+	switch op.Op {
+	case "add", "replace", "remove", mergeRemoveOp:
+		return patchValue(doc, path, op.Op, op.Value)
+
+	case "move", "copy":
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return err
+		}
+		if len(from) == 0 {
+			return fmt.Errorf("%w: %s need from", ErrPatchPath, op.Op)
+		}
+		// RFC 6902 4.4: 不能移动到自己的子路径
+		if op.Op == "move" && len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return fmt.Errorf("%w: can not move %s into its child %s", ErrPatchPath, op.From, op.Path)
+		}
+		src, err := lookupPatchValue(doc, from)
+		if err != nil {
+			return err
+		}
+		raw, err := json.Marshal(src.Interface())
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			if field, ok := lookupJSONField(doc.Type(), from[0]); ok {
+				touched[doc.Type().Field(field.index[0]).Name] = true
+			}
+			if err := patchValue(doc, from, "remove", nil); err != nil {
+				return err
+			}
+		}
+		return patchValue(doc, path, "add", raw)
+
+	case "test":
+		cur, err := lookupPatchValue(doc, path)
+		if err != nil {
+			return err
+		}
+		expect := reflect.New(cur.Type())
+		if err := json.Unmarshal(op.Value, expect.Interface()); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(cur.Interface(), expect.Elem().Interface()) {
+			return ErrPatchTest
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPatchOp, op.Op)
+}
+
+func lookupPatchValue(v reflect.Value, path []string) (reflect.Value, error) {
+	for _, part := range path {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("%w: %s is nil", ErrPatchPath, part)
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			field, ok := lookupJSONField(v.Type(), part)
+			if !ok {
+				return reflect.Value{}, fmt.Errorf("%w: no field %s", ErrPatchPath, part)
+			}
+			v = v.FieldByIndex(field.index)
+		case reflect.Map:
+			key, err := patchMapKey(v.Type(), part)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v = v.MapIndex(key)
+			if !v.IsValid() {
+				return reflect.Value{}, fmt.Errorf("%w: no key %s", ErrPatchPath, part)
+			}
+		case reflect.Slice, reflect.Array:
+			idx, err := patchIndex(part, v.Len()-1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v = v.Index(idx)
+		default:
+			return reflect.Value{}, fmt.Errorf("%w: can not index %s", ErrPatchPath, v.Type())
+		}
+	}
+	return v, nil
+}
+
+// patchValue 在可以设置的v上执行add/replace/remove
+func patchValue(v reflect.Value, path []string, op string, raw json.RawMessage) error {
+	if len(path) == 0 {
+		if op == "remove" || op == mergeRemoveOp {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		newValue := reflect.New(v.Type())
+		if err := json.Unmarshal(raw, newValue.Interface()); err != nil {
+			return err
+		}
+		v.Set(newValue.Elem())
+		return nil
+	}
+
+	part, last := path[0], len(path) == 1
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			if op == mergeRemoveOp {
+				return nil
+			}
+			if op == "remove" {
+				return fmt.Errorf("%w: %s is nil", ErrPatchPath, part)
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return patchValue(v.Elem(), path, op, raw)
+
+	case reflect.Struct:
+		field, ok := lookupJSONField(v.Type(), part)
+		if !ok {
+			return fmt.Errorf("%w: no field %s", ErrPatchPath, part)
+		}
+		return patchValue(v.FieldByIndex(field.index), path[1:], op, raw)
+
+	case reflect.Map:
+		key, err := patchMapKey(v.Type(), part)
+		if err != nil {
+			return err
+		}
+		cur := v.MapIndex(key)
+		if last && op == mergeRemoveOp {
+			v.SetMapIndex(key, reflect.Value{})
+			return nil
+		}
+		if !cur.IsValid() && (op != "add" || !last) {
+			return fmt.Errorf("%w: no key %s", ErrPatchPath, part)
+		}
+		if last && op == "remove" {
+			v.SetMapIndex(key, reflect.Value{})
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		// map中的元素不可寻址, 修改副本后写回
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if cur.IsValid() {
+			elem.Set(deepCopyValue(cur))
+		}
+		if err := patchValue(elem, path[1:], op, raw); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+
+	case reflect.Slice:
+		if last && op == "add" {
+			idx := v.Len()
+			if part != "-" {
+				var err error
+				if idx, err = patchIndex(part, v.Len()); err != nil {
+					return err
+				}
+			}
+			elem := reflect.New(v.Type().Elem())
+			if err := json.Unmarshal(raw, elem.Interface()); err != nil {
+				return err
+			}
+			res := reflect.MakeSlice(v.Type(), 0, v.Len()+1)
+			res = reflect.AppendSlice(res, v.Slice(0, idx))
+			res = reflect.Append(res, elem.Elem())
+			res = reflect.AppendSlice(res, v.Slice(idx, v.Len()))
+			v.Set(res)
+			return nil
+		}
+		idx, err := patchIndex(part, v.Len()-1)
+		if err != nil {
+			return err
+		}
+		if last && op == "remove" {
+			res := reflect.MakeSlice(v.Type(), 0, v.Len()-1)
+			res = reflect.AppendSlice(res, v.Slice(0, idx))
+			res = reflect.AppendSlice(res, v.Slice(idx+1, v.Len()))
+			v.Set(res)
+			return nil
+		}
+		return patchValue(v.Index(idx), path[1:], op, raw)
+
+	case reflect.Array:
+		if last && op != "replace" {
+			return fmt.Errorf("%w: array length is fixed", ErrPatchOp)
+		}
+		idx, err := patchIndex(part, v.Len()-1)
+		if err != nil {
+			return err
+		}
+		return patchValue(v.Index(idx), path[1:], op, raw)
+	}
+	return fmt.Errorf("%w: can not index %s", ErrPatchPath, v.Type())
+}
+
+func patchIndex(part string, max int) (int, error) {
+	idx, err := strconv.Atoi(part)
+	if err != nil || idx < 0 || idx > max || (len(part) > 1 && part[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid index %s", ErrPatchPath, part)
+	}
+	return idx, nil
+}
+
+func patchMapKey(t reflect.Type, part string) (reflect.Value, error) {
+	key := reflect.New(t.Key()).Elem()
+	if err := setValueFromString(key, part); err != nil {
+		return reflect.Value{}, fmt.Errorf("%w: invalid key %s", ErrPatchPath, part)
+	}
+	return key, nil
+}
+
+// deepCopyValue 拷贝导出字段以及map、切片、指针, 未导出字段为浅拷贝
+func deepCopyValue(v reflect.Value) reflect.Value {
+	res := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			elem := reflect.New(v.Type().Elem())
+			elem.Elem().Set(deepCopyValue(v.Elem()))
+			res.Set(elem)
+		}
+	case reflect.Map:
+		if !v.IsNil() {
+			res.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+			iter := v.MapRange()
+			for iter.Next() {
+				res.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+			}
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			res.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				res.Index(i).Set(deepCopyValue(v.Index(i)))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+	case reflect.Struct:
+		res.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				res.Field(i).Set(deepCopyValue(v.Field(i)))
+			}
+		}
+	default:
+		res.Set(v)
+	}
+	return res
+}
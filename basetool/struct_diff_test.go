@@ -0,0 +1,167 @@
+package basetool
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testDiffAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type testDiffUser struct {
+	Name    string            `json:"name"`
+	Age     int               `json:"age"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Address *testDiffAddress  `json:"address"`
+	Secret  string            `json:"-"`
+}
+
+func TestDiffStruct(t *testing.T) {
+	a := testDiffUser{
+		Name:    "alice",
+		Age:     18,
+		Tags:    []string{"a", "b", "c"},
+		Labels:  map[string]string{"role": "admin", "team": "x"},
+		Address: &testDiffAddress{City: "sh"},
+		Secret:  "1",
+	}
+	b := testDiffUser{
+		Name:    "alice",
+		Age:     19,
+		Tags:    []string{"a", "d"},
+		Labels:  map[string]string{"role": "user", "env": "dev"},
+		Address: &testDiffAddress{City: "bj"},
+		Secret:  "2",
+	}
+
+	changes, err := DiffStruct(a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.String())
+	}
+	want := []string{
+		"replace /age: 18 -> 19",
+		"replace /tags/1: b -> d",
+		"remove /tags/2: c",
+		"add /labels/env: dev",
+		"replace /labels/role: admin -> user",
+		"remove /labels/team: x",
+		"replace /address/city: sh -> bj",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diff error:\n%v\n%v", got, want)
+	}
+
+	patch, err := changes.JSONPatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := a
+	c.Tags = append([]string{}, a.Tags...)
+	c.Labels = map[string]string{"role": "admin", "team": "x"}
+	c.Address = &testDiffAddress{City: "sh"}
+	if err := ApplyJSONPatch(&c, patch); err != nil {
+		t.Fatal(err)
+	}
+	c.Secret = b.Secret
+	if !reflect.DeepEqual(c, b) {
+		t.Errorf("json patch error: %+v", c)
+	}
+	if a.Address.City != "sh" || len(a.Tags) != 3 {
+		t.Errorf("source should not change: %+v", a)
+	}
+
+	merge, err := changes.MergePatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(merge, &doc)
+	wantDoc := map[string]interface{}{
+		"age":     19.0,
+		"tags":    []interface{}{"a", "d"},
+		"labels":  map[string]interface{}{"env": "dev", "role": "user", "team": nil},
+		"address": map[string]interface{}{"city": "bj"},
+	}
+	if !reflect.DeepEqual(doc, wantDoc) {
+		t.Errorf("merge patch error: %s", merge)
+	}
+
+	d := a
+	d.Labels = map[string]string{"role": "admin", "team": "x"}
+	d.Address = &testDiffAddress{City: "sh"}
+	if err := ApplyMergePatch(&d, merge); err != nil {
+		t.Fatal(err)
+	}
+	d.Secret = b.Secret
+	if !reflect.DeepEqual(d, b) {
+		t.Errorf("apply merge patch error: %+v", d)
+	}
+
+	if _, err := DiffStruct(a, testDiffAddress{}); !errors.Is(err, ErrDiffTypeMismatch) {
+		t.Errorf("type mismatch error: %v", err)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	u := testDiffUser{Name: "bob", Tags: []string{"a"}}
+	patch := `[
+		{"op": "test", "path": "/name", "value": "bob"},
+		{"op": "add", "path": "/tags/0", "value": "first"},
+		{"op": "add", "path": "/tags/-", "value": "last"},
+		{"op": "add", "path": "/labels/k", "value": "v"},
+		{"op": "copy", "from": "/name", "path": "/address/city"},
+		{"op": "move", "from": "/labels/k", "path": "/labels/m"}
+	]`
+	if err := ApplyJSONPatch(&u, []byte(patch)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(u.Tags, []string{"first", "a", "last"}) || u.Address.City != "bob" ||
+		!reflect.DeepEqual(u.Labels, map[string]string{"m": "v"}) {
+		t.Errorf("apply error: %+v", u)
+	}
+
+	// 失败时不修改
+	failed := `[
+		{"op": "replace", "path": "/name", "value": "tom"},
+		{"op": "test", "path": "/age", "value": 100}
+	]`
+	if err := ApplyJSONPatch(&u, []byte(failed)); !errors.Is(err, ErrPatchTest) || u.Name != "bob" {
+		t.Errorf("atomic error: %v %s", err, u.Name)
+	}
+	for _, item := range []string{
+		`[{"op": "remove", "path": "/tags/5"}]`,
+		`[{"op": "replace", "path": "/unknown", "value": 1}]`,
+		`[{"op": "replace", "path": "/age", "value": "x"}]`,
+		`[{"op": "unknown", "path": "/age"}]`,
+		`[{"op": "move", "path": "/name"}]`,
+		`[{"op": "copy", "from": "", "path": "/name"}]`,
+		`[{"op": "move", "from": "/address", "path": "/address/city"}]`,
+	} {
+		if err := ApplyJSONPatch(&u, []byte(item)); err == nil {
+			t.Errorf("%s should fail", item)
+		}
+	}
+
+	ins := NewBuilder().AddString("Name", `json:"name"`).AddInt64("Age", `json:"age"`).Build().New()
+	if err := ApplyMergePatch(ins, []byte(`{"name": "x", "age": 3}`)); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ins.GetValue("name"); v != "x" {
+		t.Errorf("instance patch error: %v", v)
+	}
+	other := NewBuilder().AddString("Name", `json:"name"`).AddInt64("Age", `json:"age"`).Build().New()
+	other.SetValue("Name", "x")
+	changes, err := DiffStruct(other, ins)
+	if err != nil || len(changes) != 1 || changes[0].Pointer() != "/age" || changes[0].To != int64(3) {
+		t.Errorf("instance diff error: %v %v", changes, err)
+	}
+}
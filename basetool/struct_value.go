@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 )
 
@@ -276,390 +275,3 @@ type ErrorUnsupportedType struct {
 func (e ErrorUnsupportedType) Error() string {
 	return fmt.Sprintf("unsupported type %v", e.t)
 }
-
-// Apply parses a struct pointer for `default` tags. If the default tag is
-// set and the struct member has a default value, the default value will be
-// set no the member. Parse expects a struct pointer.
-func LoadDefault(t interface{}) error {
-	// Make sure we've been given a pointer.
-	val := reflect.ValueOf(t)
-	if val.Kind() != reflect.Ptr {
-		return newErrNotAStructPointer(t)
-	}
-
-	// Make sure the pointer is pointing to a struct.
-	ref := val.Elem()
-	if ref.Kind() != reflect.Struct {
-		return newErrNotAStructPointer(t)
-	}
-
-	return parseFields(ref)
-}
-
-func parseFields(v reflect.Value) error {
-	for i := 0; i < v.NumField(); i++ {
-		err := parseField(v.Field(i), v.Type().Field(i))
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func parseField(value reflect.Value, field reflect.StructField) error {
-	tagVal := field.Tag.Get("default")
-
-	isStruct := value.Kind() == reflect.Struct
-	isStructPointer := value.Kind() == reflect.Ptr && value.Type().Elem().Kind() == reflect.Struct
-
-	if (tagVal == "" || tagVal == "-") && !(isStruct || isStructPointer) {
-		return nil
-	}
-
-	// 不是空就不需要设置， 但是对于struct可能存在部分是空的，需要额外判断
-	if !value.IsZero() && value.Kind() != reflect.Struct {
-		return nil
-	}
-
-	if !value.CanSet() {
-		return ErrorUnsettable(field.Name)
-	}
-
-	switch value.Kind() {
-	case reflect.String:
-		value.SetString(tagVal)
-		return nil
-
-	case reflect.Bool:
-		b, err := strconv.ParseBool(tagVal)
-		if err != nil {
-			return err
-		}
-		value.SetBool(b)
-		return nil
-
-	case reflect.Int:
-		i, err := strconv.ParseInt(tagVal, 10, 32)
-		if err != nil {
-			return err
-		}
-		value.SetInt(i)
-		return nil
-
-	case reflect.Int8:
-		i, err := strconv.ParseInt(tagVal, 10, 8)
-		if err != nil {
-			return err
-		}
-		value.SetInt(i)
-		return nil
-
-	case reflect.Int16:
-		i, err := strconv.ParseInt(tagVal, 10, 16)
-		if err != nil {
-			return err
-		}
-		value.SetInt(i)
-		return nil
-
-	// NB: int32 is also an alias for a rune
-	case reflect.Int32:
-		i, err := parseInt32(tagVal)
-		if err != nil {
-			return err
-		}
-		value.SetInt(int64(i))
-		return nil
-
-	case reflect.Int64:
-		i, err := strconv.ParseInt(tagVal, 10, 64)
-		if err != nil {
-			return err
-		}
-		value.SetInt(i)
-		return nil
-
-	case reflect.Uint:
-		i, err := strconv.ParseInt(tagVal, 10, 32)
-		if err != nil {
-			return err
-		}
-		value.SetUint(uint64(i))
-		return nil
-
-	case reflect.Uint8:
-		i, err := strconv.ParseInt(tagVal, 10, 8)
-		if err != nil {
-			return err
-		}
-		value.SetUint(uint64(i))
-		return nil
-
-	case reflect.Uint16:
-		i, err := strconv.ParseInt(tagVal, 10, 16)
-		if err != nil {
-			return err
-		}
-		value.SetUint(uint64(i))
-		return nil
-
-	case reflect.Uint32:
-		i, err := strconv.ParseInt(tagVal, 10, 32)
-		if err != nil {
-			return err
-		}
-		value.SetUint(uint64(i))
-		return nil
-
-	case reflect.Uint64:
-		i, err := strconv.ParseInt(tagVal, 10, 64)
-		if err != nil {
-			return err
-		}
-		value.SetUint(uint64(i))
-		return nil
-
-	case reflect.Float32:
-		f, err := strconv.ParseFloat(tagVal, 32)
-		if err != nil {
-			return err
-		}
-		value.SetFloat(f)
-		return nil
-
-	case reflect.Float64:
-		f, err := strconv.ParseFloat(tagVal, 64)
-		if err != nil {
-			return err
-		}
-		value.SetFloat(f)
-		return nil
-
-	case reflect.Slice:
-		switch value.Type().Elem().Kind() {
-		// a []uint8 is a an alias for a []byte
-		case reflect.Uint8:
-			value.SetBytes([]byte(tagVal))
-			return nil
-		case reflect.Int64:
-			res := []int64{}
-			for _, item := range strings.Split(tagVal, ",") {
-				v, err := strconv.ParseInt(item, 10, 64)
-				if err != nil {
-					continue
-				}
-				res = append(res, v)
-			}
-			val := reflect.MakeSlice(reflect.TypeOf([]int64{}), len(res), len(res))
-			for i, v := range res {
-				val.Index(i).Set(reflect.ValueOf(v))
-			}
-			value.Set(val)
-			return nil
-
-		case reflect.Int:
-			res := []int{}
-			for _, item := range strings.Split(tagVal, ",") {
-				v, err := strconv.ParseInt(item, 10, 64)
-				if err != nil {
-					continue
-				}
-				res = append(res, int(v))
-			}
-			val := reflect.MakeSlice(reflect.TypeOf([]int{}), len(res), len(res))
-			for i, v := range res {
-				val.Index(i).Set(reflect.ValueOf(v))
-			}
-			value.Set(val)
-			return nil
-
-		case reflect.String:
-			res := strings.Split(tagVal, ",")
-			val := reflect.MakeSlice(reflect.TypeOf([]string{}), len(res), len(res))
-			for i, v := range res {
-				val.Index(i).Set(reflect.ValueOf(v))
-			}
-			value.Set(val)
-			return nil
-
-		default:
-			return ErrorUnsupportedType{value.Type()}
-		}
-
-	case reflect.Struct:
-		if value.NumField() == 0 {
-			return nil
-		}
-		return parseFields(value) // recurse
-
-	case reflect.Ptr:
-		ref := value.Type().Elem()
-
-		switch ref.Kind() {
-		case reflect.String:
-			value.Set(reflect.ValueOf(&tagVal))
-			return nil
-
-		case reflect.Bool:
-			b, err := strconv.ParseBool(tagVal)
-			if err != nil {
-				return err
-			}
-			value.Set(reflect.ValueOf(&b))
-			return nil
-
-		case reflect.Int:
-			n, err := strconv.ParseInt(tagVal, 10, 32)
-			if err != nil {
-				return err
-			}
-			i := int(n)
-			value.Set(reflect.ValueOf(&i))
-			return nil
-
-		case reflect.Int8:
-			n, err := strconv.ParseInt(tagVal, 10, 8)
-			if err != nil {
-				return err
-			}
-			i := int8(n)
-			value.Set(reflect.ValueOf(&i))
-			return nil
-
-		case reflect.Int16:
-			n, err := strconv.ParseInt(tagVal, 10, 16)
-			if err != nil {
-				return err
-			}
-			i := int16(n)
-			value.Set(reflect.ValueOf(&i))
-			return nil
-
-		case reflect.Int32:
-			// NB: *int32 is an alias for a *rune
-			i, err := parseInt32(tagVal)
-			if err != nil {
-				return err
-			}
-			value.Set(reflect.ValueOf(&i))
-			return nil
-
-		case reflect.Int64:
-			i, err := strconv.ParseInt(tagVal, 10, 64)
-			if err != nil {
-				return err
-			}
-			value.Set(reflect.ValueOf(&i))
-			return nil
-
-		case reflect.Uint:
-			n, err := strconv.ParseInt(tagVal, 10, 32)
-			if err != nil {
-				return err
-			}
-			u := uint(n)
-			value.Set(reflect.ValueOf(&u))
-			return nil
-
-		case reflect.Uint8:
-			n, err := strconv.ParseInt(tagVal, 10, 8)
-			if err != nil {
-				return err
-			}
-			u := uint8(n)
-			value.Set(reflect.ValueOf(&u))
-			return nil
-
-		case reflect.Uint16:
-			n, err := strconv.ParseInt(tagVal, 10, 16)
-			if err != nil {
-				return err
-			}
-			u := uint16(n)
-			value.Set(reflect.ValueOf(&u))
-			return nil
-
-		case reflect.Uint32:
-			n, err := strconv.ParseInt(tagVal, 10, 32)
-			if err != nil {
-				return err
-			}
-			u := uint32(n)
-			value.Set(reflect.ValueOf(&u))
-			return nil
-
-		case reflect.Uint64:
-			n, err := strconv.ParseInt(tagVal, 10, 64)
-			if err != nil {
-				return err
-			}
-			u := uint64(n)
-			value.Set(reflect.ValueOf(&u))
-			return nil
-
-		case reflect.Float32:
-			f, err := strconv.ParseFloat(tagVal, 32)
-			if err != nil {
-				return err
-			}
-			f32 := float32(f)
-			value.Set(reflect.ValueOf(&f32))
-			return nil
-
-		case reflect.Float64:
-			f, err := strconv.ParseFloat(tagVal, 64)
-			if err != nil {
-				return err
-			}
-			value.Set(reflect.ValueOf(&f))
-			return nil
-
-		case reflect.Slice:
-			switch ref.Elem().Kind() {
-			// a *[]uint is an alias for *[]byte
-			case reflect.Uint8:
-				b := []byte(tagVal)
-				value.Set(reflect.ValueOf(&b))
-				return nil
-
-			default:
-				return ErrorUnsupportedType{value.Type()}
-			}
-
-		case reflect.Struct:
-			if ref.NumField() == 0 {
-				return nil
-			}
-
-			// If it's nil set it to it's default value so we can set the
-			// children if we need to.
-			if value.IsNil() {
-				value.Set(reflect.New(ref))
-			}
-			return parseFields(value.Elem()) // recurse
-
-		default:
-			return ErrorUnsupportedType{value.Type()}
-		}
-
-	default:
-		return ErrorUnsupportedType{value.Type()}
-	}
-}
-
-// Attempt to parse a string as an int32 and, failing that, a rune.
-func parseInt32(s string) (int32, error) {
-	// Try parsing it as an int.
-	i, err := strconv.ParseInt(s, 10, 32)
-	if err == nil {
-		return int32(i), nil
-	}
-
-	// We couldn't parse it as an int, maybe it's a rune.
-	runes := []rune(s)
-	if len(runes) == 1 {
-		return runes[0], nil
-	} else {
-		return 0, err
-	}
-}
@@ -0,0 +1,393 @@
+package basetool
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+////////
+// 双向字符串模板
+// {name}        非贪婪匹配任意非空字符串
+// {name:int}    指定类型, 内置 string int uint float bool, 可以通过RegisterPatternType扩展
+// {name...}     贪婪匹配剩余内容, 可以为空
+// {{ 与 }}      表示字面量的 { 与 }
+////////
+
+var (
+	ErrPatternSyntax   = errors.New("pattern syntax error")
+	ErrPatternNotMatch = errors.New("string does not match pattern")
+	ErrPatternValue    = errors.New("invalid value for pattern field")
+)
+
+// PatternType 占位符的类型
+// Regexp 不能包含捕获分组, 需要分组时使用 (?:...)
+type PatternType struct {
+	Name   string
+	Regexp string
+	Parse  func(string) (interface{}, error)
+
+	re *regexp.Regexp
+}
+
+var (
+	patternTypesMu sync.RWMutex
+	patternTypes   = map[string]*PatternType{}
+)
+
+func init() {
+	for _, item := range []*PatternType{
+		{Name: "string", Regexp: `.+?`, Parse: func(s string) (interface{}, error) { return s, nil }},
+		{Name: "int", Regexp: `[-+]?\d+`, Parse: func(s string) (interface{}, error) { return strconv.ParseInt(s, 10, 64) }},
+		{Name: "uint", Regexp: `\d+`, Parse: func(s string) (interface{}, error) { return strconv.ParseUint(s, 10, 64) }},
+		{Name: "float", Regexp: `[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`, Parse: func(s string) (interface{}, error) { return strconv.ParseFloat(s, 64) }},
+		{Name: "bool", Regexp: `(?i:true|false|1|0)`, Parse: func(s string) (interface{}, error) { return strconv.ParseBool(strings.ToLower(s)) }},
+	} {
+		if err := RegisterPatternType(item); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterPatternType 注册自定义的占位符类型, 同名会覆盖
+func RegisterPatternType(t *PatternType) error {
+	if t == nil || t.Name == "" || t.Parse == nil {
+		return fmt.Errorf("%w: pattern type need name and parse function", ErrPatternSyntax)
+	}
+	re, err := regexp.Compile(`^(?:` + t.Regexp + `)$`)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPatternSyntax, err)
+	}
+	if re.NumSubexp() != 0 {
+		return fmt.Errorf("%w: pattern type %s must not contain capture groups", ErrPatternSyntax, t.Name)
+	}
+	t.re = re
+
+	patternTypesMu.Lock()
+	defer patternTypesMu.Unlock()
+	patternTypes[t.Name] = t
+	return nil
+}
+
+func lookupPatternType(name string) (*PatternType, bool) {
+	patternTypesMu.RLock()
+	defer patternTypesMu.RUnlock()
+	t, ok := patternTypes[name]
+	return t, ok
+}
+
+type patternSegment struct {
+	literal string
+	name    string
+	typ     *PatternType
+	rest    bool
+}
+
+func (s patternSegment) isField() bool {
+	return s.name != ""
+}
+
+// Pattern 编译后的模板, 可以并发使用
+type Pattern struct {
+	raw      string
+	segments []patternSegment
+	fields   []string
+	re       *regexp.Regexp
+}
+
+var patternNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CompilePattern 编译模板, 例如 {host}:{port:int}/{path...}
+func CompilePattern(temp string) (*Pattern, error) {
+	p := &Pattern{raw: temp}
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			p.segments = append(p.segments, patternSegment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < len(temp); i++ {
+		ch := temp[i]
+		switch {
+		case ch == '{' && i+1 < len(temp) && temp[i+1] == '{':
+			literal.WriteByte('{')
+			i++
+		case ch == '}' && i+1 < len(temp) && temp[i+1] == '}':
+			literal.WriteByte('}')
+			i++
+		case ch == '}':
+			return nil, fmt.Errorf("%w: unexpected } at %d", ErrPatternSyntax, i)
+		case ch == '{':
+			end := strings.IndexByte(temp[i:], '}')
+			if end == -1 {
+				return nil, fmt.Errorf("%w: unclosed { at %d", ErrPatternSyntax, i)
+			}
+			seg, err := parsePatternField(temp[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("%w at %d", err, i)
+			}
+			if seen[seg.name] {
+				return nil, fmt.Errorf("%w: duplicate field %s", ErrPatternSyntax, seg.name)
+			}
+			seen[seg.name] = true
+			flush()
+			p.segments = append(p.segments, seg)
+			p.fields = append(p.fields, seg.name)
+			i += end
+		default:
+			literal.WriteByte(ch)
+		}
+	}
+	flush()
+
+	var expr strings.Builder
+	expr.WriteString(`(?s)^`)
+	for _, seg := range p.segments {
+		switch {
+		case !seg.isField():
+			expr.WriteString(regexp.QuoteMeta(seg.literal))
+		case seg.rest:
+			expr.WriteString(`(.*)`)
+		default:
+			expr.WriteString(`(` + seg.typ.Regexp + `)`)
+		}
+	}
+	expr.WriteString(`$`)
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPatternSyntax, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// MustCompilePattern 编译失败时panic, 用于初始化全局变量
+func MustCompilePattern(temp string) *Pattern {
+	p, err := CompilePattern(temp)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// name / name:type / name... / name:type...
+func parsePatternField(body string) (patternSegment, error) {
+	seg := patternSegment{}
+	if strings.HasSuffix(body, "...") {
+		seg.rest = true
+		body = strings.TrimSuffix(body, "...")
+	}
+	name, typeName := body, "string"
+	if idx := strings.IndexByte(body, ':'); idx != -1 {
+		name, typeName = body[:idx], body[idx+1:]
+	}
+	name, typeName = strings.TrimSpace(name), strings.TrimSpace(typeName)
+	if !patternNameRe.MatchString(name) {
+		return seg, fmt.Errorf("%w: invalid field name %q", ErrPatternSyntax, name)
+	}
+	typ, ok := lookupPatternType(typeName)
+	if !ok {
+		return seg, fmt.Errorf("%w: unknown type %q", ErrPatternSyntax, typeName)
+	}
+	if seg.rest && typ.Name != "string" {
+		return seg, fmt.Errorf("%w: %s... only support string type", ErrPatternSyntax, name)
+	}
+	seg.name = name
+	seg.typ = typ
+	return seg, nil
+}
+
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Fields 返回模板中的字段名, 按出现顺序
+func (p *Pattern) Fields() []string {
+	res := make([]string, len(p.fields))
+	copy(res, p.fields)
+	return res
+}
+
+func (p *Pattern) matchRaw(str string) ([]string, error) {
+	groups := p.re.FindStringSubmatch(str)
+	if groups == nil {
+		return nil, fmt.Errorf("%w: %q with %q", ErrPatternNotMatch, str, p.raw)
+	}
+	return groups[1:], nil
+}
+
+// Match 解析字符串, 值按照字段类型转换, 例如int字段为int64
+func (p *Pattern) Match(str string) (map[string]interface{}, error) {
+	groups, err := p.matchRaw(str)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]interface{}, len(groups))
+	i := 0
+	for _, seg := range p.segments {
+		if !seg.isField() {
+			continue
+		}
+		val, err := seg.typ.Parse(groups[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrPatternValue, seg.name, err)
+		}
+		res[seg.name] = val
+		i++
+	}
+	return res, nil
+}
+
+// MatchTo 解析字符串并写入dst, dst可以是*struct或者*map[string]interface{}
+// struct字段按照 pattern tag、json tag、字段名(忽略大小写) 的顺序匹配
+func (p *Pattern) MatchTo(str string, dst interface{}) error {
+	if m, ok := dst.(*map[string]interface{}); ok {
+		res, err := p.Match(str)
+		if err != nil {
+			return err
+		}
+		if *m == nil {
+			*m = res
+			return nil
+		}
+		for key, val := range res {
+			(*m)[key] = val
+		}
+		return nil
+	}
+
+	val := reflect.ValueOf(dst)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return newErrNotAStructPointer(dst)
+	}
+	// 值由类型的Parse得到, 再转换为字段类型
+	values, err := p.Match(str)
+	if err != nil {
+		return err
+	}
+	// 在副本上赋值, 出错时不会修改dst
+	target := reflect.New(val.Elem().Type()).Elem()
+	target.Set(val.Elem())
+	fields := patternStructFields(target)
+	for _, seg := range p.segments {
+		if !seg.isField() {
+			continue
+		}
+		field, ok := fields[strings.ToLower(seg.name)]
+		if !ok {
+			continue
+		}
+		if err := setPatternValue(field, values[seg.name]); err != nil {
+			return fmt.Errorf("%w %s: %v", ErrPatternValue, seg.name, err)
+		}
+	}
+	val.Elem().Set(target)
+	return nil
+}
+
+// Render 使用values生成字符串, values可以是map[string]interface{}或者struct
+// 生成的值需要满足字段的类型, 否则返回错误
+func (p *Pattern) Render(values interface{}) (string, error) {
+	getter, err := patternValueGetter(values)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	for _, seg := range p.segments {
+		if !seg.isField() {
+			buf.WriteString(seg.literal)
+			continue
+		}
+		val, ok := getter(seg.name)
+		if !ok {
+			if seg.rest {
+				continue
+			}
+			return "", fmt.Errorf("%w %s: missing value", ErrPatternValue, seg.name)
+		}
+		str := ToString(val)
+		if !seg.rest && !seg.typ.re.MatchString(str) {
+			return "", fmt.Errorf("%w %s: %q is not %s", ErrPatternValue, seg.name, str, seg.typ.Name)
+		}
+		buf.WriteString(str)
+	}
+	return buf.String(), nil
+}
+
+func patternValueGetter(values interface{}) (func(string) (interface{}, bool), error) {
+	switch v := values.(type) {
+	case map[string]interface{}:
+		return func(name string) (interface{}, bool) {
+			val, ok := v[name]
+			return val, ok && val != nil
+		}, nil
+	case map[string]string:
+		return func(name string) (interface{}, bool) {
+			val, ok := v[name]
+			return val, ok
+		}, nil
+	}
+
+	val, err := getReflectValue(values)
+	if err != nil {
+		return nil, err
+	}
+	fields := patternStructFields(val)
+	return func(name string) (interface{}, bool) {
+		field, ok := fields[strings.ToLower(name)]
+		if !ok || !field.CanInterface() {
+			return nil, false
+		}
+		return field.Interface(), true
+	}, nil
+}
+
+// setPatternValue 将Parse的结果写入字段, 类型不能直接赋值时按字符串转换, 例如int64写入uint32字段会检查溢出
+func setPatternValue(v reflect.Value, val interface{}) error {
+	if val != nil {
+		typ := reflect.TypeOf(val)
+		switch {
+		case typ.AssignableTo(v.Type()):
+			v.Set(reflect.ValueOf(val))
+			return nil
+		case v.Kind() == reflect.Ptr && typ.AssignableTo(v.Type().Elem()):
+			ptr := reflect.New(v.Type().Elem())
+			ptr.Elem().Set(reflect.ValueOf(val))
+			v.Set(ptr)
+			return nil
+		}
+	}
+	return setValueFromString(v, ToString(val))
+}
+
+// patternStructFields <小写的字段名: 字段值>
+func patternStructFields(v reflect.Value) map[string]reflect.Value {
+	res := map[string]reflect.Value{}
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Tag.Get("pattern")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.Split(field.Tag.Get("json"), ",")[0]
+		}
+		if name == "" || name == "-" {
+			name = field.Name
+		}
+		res[strings.ToLower(name)] = v.Field(i)
+	}
+	return res
+}
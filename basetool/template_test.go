@@ -1,6 +1,12 @@
 package basetool
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 // TestTemplateParse tests the TemplateParse function
 func TestTemplateParse(t *testing.T) {
@@ -51,3 +57,139 @@ func TestTemplateParse(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateResolveE(t *testing.T) {
+	if _, err := TemplateResolveE("{{.Name", nil); err == nil {
+		t.Error("parse error should be returned")
+	}
+	res, err := TemplateResolveE("Hello, {{.Name}}", map[string]string{"Name": "Alice"})
+	if err != nil || res != "Hello, Alice" {
+		t.Errorf("resolve error: %q %v", res, err)
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	p, err := CompilePattern("{host}:{port:int}/{path...}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Fields(), []string{"host", "port", "path"}) {
+		t.Errorf("fields error: %v", p.Fields())
+	}
+
+	res, err := p.Match("localhost:8080/api/v1/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"host": "localhost", "port": int64(8080), "path": "api/v1/users"}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("match error: %v", res)
+	}
+
+	if _, err := p.Match("localhost:abc/api"); !errors.Is(err, ErrPatternNotMatch) {
+		t.Errorf("type mismatch should not match: %v", err)
+	}
+	if _, err := p.Match("localhost"); !errors.Is(err, ErrPatternNotMatch) {
+		t.Errorf("short string should not match: %v", err)
+	}
+
+	// 重复的分隔符
+	p = MustCompilePattern("{date} {level} {msg...}")
+	res, err = p.Match("2024-01-01 INFO  server  started")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["level"] != "INFO" || res["msg"] != " server  started" {
+		t.Errorf("repeated delimiter error: %#v", res)
+	}
+
+	p = MustCompilePattern("{{{name}}}")
+	if res, err := p.Match("{abc}"); err != nil || res["name"] != "abc" {
+		t.Errorf("escape error: %v %v", res, err)
+	}
+}
+
+func TestPatternMatchTo(t *testing.T) {
+	type device struct {
+		Vendor string
+		Model  string `pattern:"model"`
+		Serial uint32 `json:"sn"`
+		Online *bool
+	}
+	p := MustCompilePattern("{vendor}-{model}-{sn:uint}-{online:bool}")
+
+	var d device
+	if err := p.MatchTo("acme-cam-x-1024-true", &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Vendor != "acme" || d.Model != "cam-x" || d.Serial != 1024 || d.Online == nil || !*d.Online {
+		t.Errorf("match struct error: %+v", d)
+	}
+	if err := p.MatchTo("acme-cam-99999999999-true", &d); err == nil {
+		t.Error("overflow should return error")
+	}
+	if err := p.MatchTo("acme-cam-1-true", d); err == nil {
+		t.Error("non pointer should return error")
+	}
+
+	m := map[string]interface{}{}
+	if err := p.MatchTo("acme-cam-1-false", &m); err != nil || m["online"] != false {
+		t.Errorf("match map error: %v %v", m, err)
+	}
+
+	str, err := p.Render(d)
+	if err != nil || str != "acme-cam-x-1024-true" {
+		t.Errorf("render struct error: %q %v", str, err)
+	}
+	// 自定义类型的字段使用Parse的结果, 测试结束后删除注册的类型
+	t.Cleanup(func() {
+		patternTypesMu.Lock()
+		defer patternTypesMu.Unlock()
+		delete(patternTypes, "hex")
+		delete(patternTypes, "upper")
+	})
+	if err := RegisterPatternType(&PatternType{Name: "hex", Regexp: `[0-9a-fA-F]+`, Parse: func(s string) (interface{}, error) {
+		return strconv.ParseInt(s, 16, 64)
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterPatternType(&PatternType{Name: "upper", Regexp: `[a-z]+`, Parse: func(s string) (interface{}, error) {
+		return strings.ToUpper(s), nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	var reg struct {
+		Name  string
+		Addr  uint16
+		Value *int64
+	}
+	if err := MustCompilePattern("{name:upper}@{addr:hex}={value:hex}").MatchTo("temp@ff=1a", &reg); err != nil {
+		t.Fatal(err)
+	}
+	if reg.Name != "TEMP" || reg.Addr != 255 || reg.Value == nil || *reg.Value != 26 {
+		t.Errorf("match custom type error: %+v", reg)
+	}
+	if err := MustCompilePattern("{addr:hex}").MatchTo("10000", &reg); err == nil {
+		t.Error("overflow of a custom type should return error")
+	}
+}
+
+func TestPatternRender(t *testing.T) {
+	p := MustCompilePattern("{host}:{port:int}/{path...}")
+	str, err := p.Render(map[string]interface{}{"host": "localhost", "port": 80})
+	if err != nil || str != "localhost:80/" {
+		t.Errorf("render error: %q %v", str, err)
+	}
+	if _, err := p.Render(map[string]interface{}{"host": "localhost", "port": "http"}); !errors.Is(err, ErrPatternValue) {
+		t.Errorf("render type error: %v", err)
+	}
+	if _, err := p.Render(map[string]string{"port": "80"}); !errors.Is(err, ErrPatternValue) {
+		t.Errorf("render missing error: %v", err)
+	}
+
+	for _, temp := range []string{"{host", "host}", "{}", "{a}{a}", "{a:unknown}", "{a:int...}"} {
+		if _, err := CompilePattern(temp); !errors.Is(err, ErrPatternSyntax) {
+			t.Errorf("compile %q should fail: %v", temp, err)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package basetool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	ErrTreeDuplicateID = errors.New("tree node id is duplicated")
+	ErrTreeNotFound    = errors.New("tree node is not found")
+	ErrTreeCycle       = errors.New("tree node would form a cycle")
+)
+
+// TreeNode 泛型树的节点
+type TreeNode[K comparable, T any] struct {
+	ID       K
+	ParentID K
+	Data     T
+	Children []*TreeNode[K, T]
+	parent   *TreeNode[K, T]
+}
+
+// Parent 返回父节点, 根节点返回nil
+func (n *TreeNode[K, T]) Parent() *TreeNode[K, T] {
+	return n.parent
+}
+
+func (n *TreeNode[K, T]) IsRoot() bool {
+	return n.parent == nil
+}
+
+func (n *TreeNode[K, T]) IsLeaf() bool {
+	return len(n.Children) == 0
+}
+
+// MarshalJSON 如果Data序列化后是json对象, 则把children合并到对象中
+// 否则输出 {"id": .., "data": .., "children": [..]}
+func (n *TreeNode[K, T]) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(n.Data)
+	if err != nil {
+		return nil, err
+	}
+	children := n.Children
+	if children == nil {
+		children = []*TreeNode[K, T]{}
+	}
+	childrenData, err := json.Marshal(children)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) >= 2 && data[0] == '{' {
+		var buf bytes.Buffer
+		buf.Write(data[:len(data)-1])
+		if len(bytes.TrimSpace(data[1:len(data)-1])) > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"children":`)
+		buf.Write(childrenData)
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	}
+
+	return json.Marshal(struct {
+		ID       K                 `json:"id"`
+		Data     json.RawMessage   `json:"data"`
+		Children []*TreeNode[K, T] `json:"children"`
+	}{n.ID, data, children})
+}
+
+// Tree 泛型树, 相比GenerateTree支持任意可比较的id类型
+type Tree[K comparable, T any] struct {
+	Roots []*TreeNode[K, T]
+	nodes map[K]*TreeNode[K, T]
+}
+
+// BuildTree 根据扁平数据生成森林
+// 父id不存在于items中的节点会作为根节点, 子节点的顺序与items中的顺序一致
+func BuildTree[K comparable, T any](items []T, getID func(T) K, getPID func(T) K) (*Tree[K, T], error) {
+	tree := &Tree[K, T]{nodes: make(map[K]*TreeNode[K, T], len(items))}
+	ordered := make([]*TreeNode[K, T], 0, len(items))
+	for _, item := range items {
+		node := &TreeNode[K, T]{ID: getID(item), ParentID: getPID(item), Data: item}
+		if _, ok := tree.nodes[node.ID]; ok {
+			return nil, ErrTreeDuplicateID
+		}
+		tree.nodes[node.ID] = node
+		ordered = append(ordered, node)
+	}
+
+	for _, node := range ordered {
+		parent, ok := tree.nodes[node.ParentID]
+		if !ok || parent == node {
+			tree.Roots = append(tree.Roots, node)
+			continue
+		}
+		node.parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	// 存在环的节点无法从根节点访问到
+	count := 0
+	tree.WalkDepthFirst(func(*TreeNode[K, T], int) bool {
+		count++
+		return true
+	})
+	if count != len(tree.nodes) {
+		return nil, ErrTreeCycle
+	}
+	return tree, nil
+}
+
+func (t *Tree[K, T]) Len() int {
+	return len(t.nodes)
+}
+
+func (t *Tree[K, T]) Node(id K) (*TreeNode[K, T], bool) {
+	node, ok := t.nodes[id]
+	return node, ok
+}
+
+// Insert 插入一个节点, 父节点不存在时作为根节点
+// 父节点是自身或者自身的子孙节点时返回ErrTreeCycle
+func (t *Tree[K, T]) Insert(id, pid K, data T) (*TreeNode[K, T], error) {
+	if t.nodes == nil {
+		t.nodes = map[K]*TreeNode[K, T]{}
+	}
+	if _, ok := t.nodes[id]; ok {
+		return nil, ErrTreeDuplicateID
+	}
+	if id == pid {
+		return nil, ErrTreeCycle
+	}
+	parent := t.nodes[pid]
+	for p := parent; p != nil; p = p.parent {
+		if p.ID == id {
+			return nil, ErrTreeCycle
+		}
+	}
+	node := &TreeNode[K, T]{ID: id, ParentID: pid, Data: data}
+	t.nodes[id] = node
+	t.attach(node, parent)
+	return node, nil
+}
+
+// Remove 删除节点以及它的所有子孙节点
+func (t *Tree[K, T]) Remove(id K) error {
+	node, ok := t.nodes[id]
+	if !ok {
+		return ErrTreeNotFound
+	}
+	t.detach(node)
+	walkDepthFirst([]*TreeNode[K, T]{node}, 0, func(n *TreeNode[K, T], _ int) bool {
+		delete(t.nodes, n.ID)
+		return true
+	})
+	return nil
+}
+
+// Move 把节点移动到新的父节点下, 不能移动到自身或者子孙节点下
+// 注意: 只会修改节点的ParentID, 不会修改Data中的字段
+func (t *Tree[K, T]) Move(id, newPID K) error {
+	node, ok := t.nodes[id]
+	if !ok {
+		return ErrTreeNotFound
+	}
+	parent, ok := t.nodes[newPID]
+	if !ok {
+		return ErrTreeNotFound
+	}
+	for p := parent; p != nil; p = p.parent {
+		if p == node {
+			return ErrTreeCycle
+		}
+	}
+	t.detach(node)
+	node.ParentID = newPID
+	t.attach(node, parent)
+	return nil
+}
+
+// MoveToRoot 把节点移动为根节点
+func (t *Tree[K, T]) MoveToRoot(id K) error {
+	node, ok := t.nodes[id]
+	if !ok {
+		return ErrTreeNotFound
+	}
+	t.detach(node)
+	var zero K
+	node.ParentID = zero
+	t.attach(node, nil)
+	return nil
+}
+
+func (t *Tree[K, T]) attach(node, parent *TreeNode[K, T]) {
+	node.parent = parent
+	if parent == nil {
+		t.Roots = append(t.Roots, node)
+	} else {
+		parent.Children = append(parent.Children, node)
+	}
+}
+
+func (t *Tree[K, T]) detach(node *TreeNode[K, T]) {
+	siblings := &t.Roots
+	if node.parent != nil {
+		siblings = &node.parent.Children
+	}
+	for i, item := range *siblings {
+		if item == node {
+			*siblings = append((*siblings)[:i], (*siblings)[i+1:]...)
+			break
+		}
+	}
+	node.parent = nil
+}
+
+// WalkDepthFirst 深度优先(先序)遍历, fn返回false时停止遍历
+func (t *Tree[K, T]) WalkDepthFirst(fn func(node *TreeNode[K, T], depth int) bool) {
+	walkDepthFirst(t.Roots, 0, fn)
+}
+
+func walkDepthFirst[K comparable, T any](nodes []*TreeNode[K, T], depth int, fn func(*TreeNode[K, T], int) bool) bool {
+	for _, node := range nodes {
+		if !fn(node, depth) {
+			return false
+		}
+		if !walkDepthFirst(node.Children, depth+1, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// WalkBreadthFirst 广度优先遍历, fn返回false时停止遍历
+func (t *Tree[K, T]) WalkBreadthFirst(fn func(node *TreeNode[K, T], depth int) bool) {
+	type item struct {
+		node  *TreeNode[K, T]
+		depth int
+	}
+	queue := make([]item, 0, len(t.Roots))
+	for _, root := range t.Roots {
+		queue = append(queue, item{root, 0})
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if !fn(cur.node, cur.depth) {
+			return
+		}
+		for _, child := range cur.node.Children {
+			queue = append(queue, item{child, cur.depth + 1})
+		}
+	}
+}
+
+// Flatten 按照深度优先的顺序还原为扁平数据
+func (t *Tree[K, T]) Flatten() []T {
+	res := make([]T, 0, len(t.nodes))
+	t.WalkDepthFirst(func(node *TreeNode[K, T], _ int) bool {
+		res = append(res, node.Data)
+		return true
+	})
+	return res
+}
+
+// PathToRoot 返回从该节点到根节点的路径, 第一个元素是节点本身
+func (t *Tree[K, T]) PathToRoot(id K) ([]*TreeNode[K, T], error) {
+	node, ok := t.nodes[id]
+	if !ok {
+		return nil, ErrTreeNotFound
+	}
+	res := []*TreeNode[K, T]{}
+	for p := node; p != nil; p = p.parent {
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// Filter 返回满足条件的节点组成的新树, 为了保持结构会保留它们的祖先节点
+func (t *Tree[K, T]) Filter(match func(node *TreeNode[K, T]) bool) *Tree[K, T] {
+	keep := map[K]bool{}
+	t.WalkDepthFirst(func(node *TreeNode[K, T], _ int) bool {
+		if !match(node) {
+			return true
+		}
+		for p := node; p != nil && !keep[p.ID]; p = p.parent {
+			keep[p.ID] = true
+		}
+		return true
+	})
+
+	res := &Tree[K, T]{nodes: map[K]*TreeNode[K, T]{}}
+	t.WalkDepthFirst(func(node *TreeNode[K, T], _ int) bool {
+		if !keep[node.ID] {
+			return true
+		}
+		item := &TreeNode[K, T]{ID: node.ID, ParentID: node.ParentID, Data: node.Data}
+		res.nodes[item.ID] = item
+		var parent *TreeNode[K, T]
+		if node.parent != nil {
+			parent = res.nodes[node.parent.ID]
+		}
+		res.attach(item, parent)
+		return true
+	})
+	return res
+}
+
+func (t *Tree[K, T]) MarshalJSON() ([]byte, error) {
+	roots := t.Roots
+	if roots == nil {
+		roots = []*TreeNode[K, T]{}
+	}
+	return json.Marshal(roots)
+}
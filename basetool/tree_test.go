@@ -1,6 +1,11 @@
 package basetool
 
-import "testing"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
 
 // 定义一个实现 INode 接口的结构体，用于测试
 type testNode struct {
@@ -71,3 +76,141 @@ func TestGenerateTree(t *testing.T) {
 		t.Errorf("Expected 1 child for node 8, but got %d", len(trees[1].(*testNode).children[0].(*testNode).children))
 	}
 }
+
+type testMenu struct {
+	Code   string `json:"code"`
+	Parent string `json:"parent"`
+	Name   string `json:"name"`
+}
+
+func buildTestMenuTree(t *testing.T) *Tree[string, testMenu] {
+	menus := []testMenu{
+		{Code: "sys", Name: "系统"},
+		{Code: "user", Parent: "sys", Name: "用户"},
+		{Code: "role", Parent: "sys", Name: "角色"},
+		{Code: "user.add", Parent: "user", Name: "新增用户"},
+		{Code: "device", Name: "设备"},
+		{Code: "camera", Parent: "device", Name: "摄像头"},
+	}
+	tree, err := BuildTree(menus,
+		func(m testMenu) string { return m.Code },
+		func(m testMenu) string { return m.Parent },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestGenericTreeBuild(t *testing.T) {
+	tree := buildTestMenuTree(t)
+	if len(tree.Roots) != 2 || tree.Len() != 6 {
+		t.Errorf("expected 2 roots and 6 nodes, got %d %d", len(tree.Roots), tree.Len())
+	}
+
+	dfs := []string{}
+	tree.WalkDepthFirst(func(node *TreeNode[string, testMenu], depth int) bool {
+		dfs = append(dfs, node.ID)
+		return true
+	})
+	if strings.Join(dfs, ",") != "sys,user,user.add,role,device,camera" {
+		t.Errorf("dfs order error: %v", dfs)
+	}
+
+	bfs := []string{}
+	tree.WalkBreadthFirst(func(node *TreeNode[string, testMenu], depth int) bool {
+		bfs = append(bfs, fmt.Sprintf("%s:%d", node.ID, depth))
+		return true
+	})
+	if strings.Join(bfs, ",") != "sys:0,device:0,user:1,role:1,camera:1,user.add:2" {
+		t.Errorf("bfs order error: %v", bfs)
+	}
+
+	if rows := tree.Flatten(); len(rows) != 6 || rows[2].Code != "user.add" {
+		t.Errorf("flatten error: %v", rows)
+	}
+
+	path, err := tree.PathToRoot("user.add")
+	if err != nil || len(path) != 3 || path[2].ID != "sys" {
+		t.Errorf("path error: %v %v", path, err)
+	}
+
+	_, err = BuildTree([]testMenu{{Code: "a", Parent: "b"}, {Code: "b", Parent: "a"}},
+		func(m testMenu) string { return m.Code },
+		func(m testMenu) string { return m.Parent },
+	)
+	if err != ErrTreeCycle {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
+
+func TestGenericTreeModify(t *testing.T) {
+	tree := buildTestMenuTree(t)
+
+	if err := tree.Move("sys", "user.add"); err != ErrTreeCycle {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+	if _, err := tree.Insert("menu", "menu", testMenu{Code: "menu", Parent: "menu"}); err != ErrTreeCycle {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+	if _, ok := tree.Node("menu"); ok || tree.Len() != 6 {
+		t.Error("failed insert should not change the tree")
+	}
+	if _, err := tree.Insert("user", "sys", testMenu{}); err != ErrTreeDuplicateID {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+	if node, err := tree.Insert("menu", "sys", testMenu{Code: "menu", Parent: "sys"}); err != nil || node.Parent().ID != "sys" {
+		t.Errorf("insert error: %v", err)
+	}
+	if err := tree.Remove("menu"); err != nil {
+		t.Error(err)
+	}
+	if err := tree.Move("user", "device"); err != nil {
+		t.Error(err)
+	}
+	node, _ := tree.Node("user")
+	if node.ParentID != "device" || node.Parent().ID != "device" {
+		t.Error("move error")
+	}
+	sys, _ := tree.Node("sys")
+	if len(sys.Children) != 1 {
+		t.Errorf("sys should have 1 child, got %d", len(sys.Children))
+	}
+
+	if err := tree.Remove("user"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := tree.Node("user.add"); ok || tree.Len() != 4 {
+		t.Error("remove should delete the subtree")
+	}
+}
+
+func TestGenericTreeFilterAndJSON(t *testing.T) {
+	tree := buildTestMenuTree(t)
+	filtered := tree.Filter(func(node *TreeNode[string, testMenu]) bool {
+		return strings.HasPrefix(node.Data.Name, "新增")
+	})
+	if filtered.Len() != 3 || len(filtered.Roots) != 1 {
+		t.Errorf("filter should keep ancestors, got %d nodes", filtered.Len())
+	}
+
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := `[{"code":"sys","parent":"","name":"系统","children":[{"code":"user","parent":"sys","name":"用户","children":[{"code":"user.add","parent":"user","name":"新增用户","children":[]}]}]}]`
+	if string(data) != expected {
+		t.Errorf("json error: %s", data)
+	}
+
+	ids, err := BuildTree([]int{1, 2}, func(i int) int { return i }, func(i int) int { return i - 1 })
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data, _ = json.Marshal(ids)
+	if string(data) != `[{"id":1,"data":1,"children":[{"id":2,"data":2,"children":[]}]}]` {
+		t.Errorf("json error: %s", data)
+	}
+}
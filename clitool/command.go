@@ -249,13 +249,18 @@ func (cm *Command) echo() error {
 }
 
 func (c *Command) Run() {
+	// process will hang here
+	if err := c.Execute(); err != nil {
+		logger.DefaultLogger.Error("Exit: " + err.Error())
+	}
+}
+
+// Execute runs the command like Run and returns its error, e.g. to choose the exit code
+func (c *Command) Execute() error {
 	c.Builder()
 
 	c.Cmd.SetHelpCommand(&cobra.Command{Hidden: true})
 	c.Cmd.SilenceUsage = true
 	c.Cmd.SilenceErrors = true
-	// process will hang here
-	if err := c.Cmd.Execute(); err != nil {
-		logger.DefaultLogger.Error("Exit: " + err.Error())
-	}
+	return c.Cmd.Execute()
 }
@@ -0,0 +1,33 @@
+module github.com/wwqdrh/gokit/dbparser/cmd/dblint
+
+go 1.24.13
+
+require (
+	github.com/spf13/cobra v1.6.1
+	github.com/wwqdrh/gokit/clitool v0.0.0
+	github.com/wwqdrh/gokit/dbparser v0.0.0
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/gorilla/websocket v1.5.1 // indirect
+	github.com/hpcloud/tail v1.0.0 // indirect
+	github.com/inconshreveable/mousetrap v1.0.1 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/wwqdrh/gokit/logger v0.0.0-20240409160118-3e98bed40929 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.27.0 // indirect
+	golang.org/x/net v0.24.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+	gopkg.in/fsnotify.v1 v1.4.7 // indirect
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
+	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace (
+	github.com/wwqdrh/gokit/clitool => ../../../clitool
+	github.com/wwqdrh/gokit/dbparser => ../..
+	github.com/wwqdrh/gokit/logger => ../../../logger
+)
@@ -0,0 +1,153 @@
+// dblint checks SQL files with the rules of dbparser.Linter.
+//
+//	dblint [--format text|json] [--schema schema.sql] [--config lint.json] [--disable rule,...] [file...]
+//
+// It reads stdin when no file is given. It exits with 1 when an issue of severity error is found,
+// with 2 when it can't run, e.g. on a missing file or a bad config.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wwqdrh/gokit/clitool"
+	"github.com/wwqdrh/gokit/dbparser"
+)
+
+type options struct {
+	Format  string   `name:"format" alias:"f" desc:"output format, text or json"`
+	Schema  string   `name:"schema" alias:"s" desc:"CREATE TABLE statements of the database, for the rules checking columns and indexes"`
+	Config  string   `name:"config" alias:"c" desc:"JSON config, e.g. {\"disable\": [\"select-star\"], \"severity\": {\"nullable-column\": \"warning\"}}"`
+	Disable []string `name:"disable" alias:"d" desc:"rules not to run"`
+}
+
+// fileIssue is an issue of the JSON output
+type fileIssue struct {
+	File string `json:"file"`
+	*dbparser.Issue
+}
+
+func main() {
+	failed, err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dblint: "+err.Error())
+		os.Exit(2)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// run runs dblint with the arguments, failed reports an issue of severity error
+func run(args []string, stdin io.Reader, stdout io.Writer) (failed bool, err error) {
+	opt := &options{Format: "text", Disable: []string{}}
+	cmd := clitool.Command{
+		Cmd: &cobra.Command{
+			Use:     "dblint [file...]",
+			Short:   "Check SQL files for dangerous statements and schema smells",
+			Example: "dblint --schema schema.sql migrations/*.sql",
+			// the files aren't subcommands
+			Args: cobra.ArbitraryArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if opt.Format != "text" && opt.Format != "json" {
+					return fmt.Errorf("unknown format %q, text or json", opt.Format)
+				}
+				linter, err := newLinter(opt)
+				if err != nil {
+					return err
+				}
+				issues := []fileIssue{}
+				if len(args) == 0 {
+					issues, err = lint(linter, "-", stdin)
+					if err != nil {
+						return err
+					}
+				}
+				for _, name := range args {
+					f, err := os.Open(name)
+					if err != nil {
+						return err
+					}
+					res, err := lint(linter, name, f)
+					f.Close()
+					if err != nil {
+						return err
+					}
+					issues = append(issues, res...)
+				}
+
+				for _, issue := range issues {
+					failed = failed || issue.Severity == dbparser.SeverityError
+				}
+				if opt.Format == "json" {
+					enc := json.NewEncoder(stdout)
+					enc.SetIndent("", "  ")
+					return enc.Encode(issues)
+				}
+				for _, issue := range issues {
+					fmt.Fprintf(stdout, "%s:%s\n", issue.File, issue.Issue)
+				}
+				return nil
+			},
+		},
+		Values: opt,
+	}
+	cmd.Add(&clitool.Command{
+		Cmd: &cobra.Command{
+			Use:   "rules",
+			Short: "List the rules",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				for _, rule := range dbparser.DefaultRules() {
+					fmt.Fprintf(stdout, "%-20s %-8s %s\n", rule.Name, rule.Severity, rule.Description)
+				}
+				return nil
+			},
+		},
+		Values: &struct{}{},
+	})
+	cmd.Cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		return false, err
+	}
+	return failed, nil
+}
+
+func newLinter(opt *options) (*dbparser.Linter, error) {
+	cfg := dbparser.LintConfig{}
+	if opt.Config != "" {
+		data, err := os.ReadFile(opt.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("read %s: %w", opt.Config, err)
+		}
+	}
+	cfg.Disable = append(cfg.Disable, opt.Disable...)
+	if opt.Schema != "" {
+		data, err := os.ReadFile(opt.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Schema, err = dbparser.ParseSchema(string(data)); err != nil {
+			return nil, fmt.Errorf("read %s: %w", opt.Schema, err)
+		}
+	}
+	return dbparser.NewLinter(cfg), nil
+}
+
+func lint(linter *dbparser.Linter, name string, r io.Reader) ([]fileIssue, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	res := []fileIssue{}
+	for _, issue := range linter.Lint(string(data)) {
+		res = append(res, fileIssue{File: name, Issue: issue})
+	}
+	return res, nil
+}
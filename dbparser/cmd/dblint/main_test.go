@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.sql")
+	a := filepath.Join(dir, "a.sql")
+	b := filepath.Join(dir, "b.sql")
+	os.WriteFile(schema, []byte("CREATE TABLE t (id INT PRIMARY KEY, name VARCHAR(20) NOT NULL);"), 0644)
+	os.WriteFile(a, []byte("DELETE FROM t;\n"), 0644)
+	os.WriteFile(b, []byte("SELECT id FROM t WHERE id = 1;\n"), 0644)
+
+	// the files are linted, not taken for subcommands
+	var out bytes.Buffer
+	failed, err := run([]string{"--schema", schema, a, b}, strings.NewReader(""), &out)
+	if err != nil || !failed {
+		t.Fatalf("Expected an error issue, got %v %v", failed, err)
+	}
+	if got := out.String(); got != a+":1:1: error: DELETE without WHERE changes every row of t (no-where)\n" {
+		t.Errorf("Unexpected output %q", got)
+	}
+
+	out.Reset()
+	failed, err = run([]string{"--format", "json", b}, strings.NewReader(""), &out)
+	var issues []fileIssue
+	if err != nil || failed || json.Unmarshal(out.Bytes(), &issues) != nil || len(issues) != 0 {
+		t.Errorf("Expected no issue, got %v %v %s", failed, err, out.String())
+	}
+
+	// stdin without files
+	out.Reset()
+	if failed, err = run(nil, strings.NewReader("UPDATE t SET name = 'a';"), &out); err != nil || !failed || !strings.HasPrefix(out.String(), "-:1:1: error") {
+		t.Errorf("Unexpected result of stdin %v %v %q", failed, err, out.String())
+	}
+
+	for _, args := range [][]string{
+		{filepath.Join(dir, "missing.sql")},
+		{"--format", "xml", a},
+		{"--config", schema, a},
+	} {
+		if _, err := run(args, strings.NewReader(""), &out); err == nil {
+			t.Errorf("Expected an error with %v", args)
+		}
+	}
+
+	out.Reset()
+	if _, err := run([]string{"rules"}, strings.NewReader(""), &out); err != nil || !strings.Contains(out.String(), "no-where") {
+		t.Errorf("Unexpected rules %v %q", err, out.String())
+	}
+}
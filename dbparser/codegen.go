@@ -0,0 +1,329 @@
+package dbparser
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelConfig configures the generation of Go models
+type ModelConfig struct {
+	// Package is the package of the generated file, default "model"
+	Package string
+	// Dialect chooses the Go type of the columns, e.g. INTEGER is int64 for SQLite
+	Dialect Dialect
+	// NullTypes uses sql.NullString, sql.NullInt64... for nullable columns instead of pointers
+	NullTypes bool
+	// DecimalType is the Go type of DECIMAL and NUMERIC columns, "string" by default to keep their precision.
+	// A type of another package is given with its import path, e.g. "github.com/shopspring/decimal.Decimal"
+	DecimalType string
+}
+
+// GenModelsFromSQL parses the CREATE TABLE statements of a dump and generates the Go models of its tables
+func GenModelsFromSQL(sql string, cfg ModelConfig) ([]byte, error) {
+	tables, err := GetTables(sql)
+	if err != nil {
+		return nil, err
+	}
+	return GenModels(tables, cfg)
+}
+
+// GenModels generates the gofmt-ed source of a Go struct with gorm and json tags for each table.
+// Columns without NOT NULL are pointers (or sql.Null* with NullTypes), "--" and COMMENT comments
+// are kept, and each model has TableName and the statements of GenListStatement and GenAddStatement as methods,
+// plus GenGetStatement, GenUpdateStatement and GenDeleteStatement when the primary key is a single column.
+// Names which would collide get a suffix: the model of "users" is Users when "user" is a table too,
+// and the field of "userID" is UserID2 after "user_id", or of "table_name" TableName2 beside the method.
+func GenModels(tables []Table, cfg ModelConfig) ([]byte, error) {
+	if cfg.Package == "" {
+		cfg.Package = "model"
+	}
+	imports := map[string]bool{}
+	models := map[string]bool{}
+	body := &bytes.Buffer{}
+	for _, table := range tables {
+		genModel(body, table, cfg, imports, models)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Code generated by dbparser. DO NOT EDIT.\n\n")
+	buf.WriteString("package " + cfg.Package + "\n\n")
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		buf.WriteString("import (\n")
+		for _, path := range paths {
+			buf.WriteString("\t\"" + path + "\"\n")
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.Write(body.Bytes())
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated models: %w", err)
+	}
+	return src, nil
+}
+
+// modelMethods are the methods generated for the models, which no field may be named as
+var modelMethods = []string{"TableName", "ListStatement", "AddStatement", "GetStatement", "UpdateStatement", "DeleteStatement"}
+
+func genModel(buf *bytes.Buffer, table Table, cfg ModelConfig, imports map[string]bool, models map[string]bool) {
+	tableName := strings.Trim(table.Name, "`\"'")
+	name := modelName(table.Name)
+	if models[name] {
+		// the table name unchanged, e.g. Users after User
+		name = fieldName(tableName)
+	}
+	name = uniqueName(name, models)
+	if table.Comment != "" {
+		writeComment(buf, "", name+" "+table.Comment)
+	} else {
+		writeComment(buf, "", name+" is the model of the table "+tableName)
+	}
+	buf.WriteString("type " + name + " struct {\n")
+
+	pk := map[string]bool{}
+	for _, column := range table.PrimaryKey {
+		pk[strings.ToLower(column)] = true
+	}
+	fields := map[string]bool{}
+	for _, method := range modelMethods {
+		fields[method] = true
+	}
+	columns := []string{}
+	updates := []string{}
+	for _, column := range table.Columns {
+		columnName := strings.Trim(column.Name, "`\"'")
+		columns = append(columns, columnName)
+		if !pk[strings.ToLower(column.Name)] {
+			updates = append(updates, columnName)
+		}
+		goType, path := columnGoType(column, cfg, pk[strings.ToLower(column.Name)])
+		if path != "" {
+			imports[path] = true
+		}
+		tag := fmt.Sprintf("`gorm:\"%s\" json:\"%s\"`", gormTag(column, table, pk), columnName)
+		buf.WriteString("\t" + uniqueName(fieldName(columnName), fields) + " " + goType + " " + tag)
+		if column.Comment != "" {
+			buf.WriteString(" // " + strings.ReplaceAll(column.Comment, "\n", " "))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n\n")
+
+	method := func(doc, fn, value string) {
+		writeComment(buf, "", fn+" "+doc)
+		fmt.Fprintf(buf, "func (%s) %s() string {\n\treturn %q\n}\n\n", name, fn, value)
+	}
+	method("returns the name of the table for gorm", "TableName", tableName)
+	method("returns the statement listing a page of rows", "ListStatement", GenListStatement(tableName))
+	method("returns the statement inserting a row", "AddStatement", GenAddStatement(tableName, columns))
+	// the statements by primary key take a single column
+	if len(table.PrimaryKey) == 1 {
+		pkName := strings.Trim(table.PrimaryKey[0], "`\"'")
+		method("returns the statement selecting a row by "+pkName, "GetStatement", GenGetStatement(tableName, pkName))
+		if len(updates) > 0 {
+			method("returns the statement updating a row by "+pkName, "UpdateStatement", GenUpdateStatement(tableName, pkName, updates))
+		}
+		method("returns the statement deleting a row by "+pkName, "DeleteStatement", GenDeleteStatement(tableName, pkName))
+	}
+}
+
+func writeComment(buf *bytes.Buffer, indent, comment string) {
+	for _, line := range strings.Split(comment, "\n") {
+		buf.WriteString(indent + "// " + line + "\n")
+	}
+}
+
+// gormTag returns the gorm tag of the column, in the format read by GenCreateTableStatement
+func gormTag(column *Column, table Table, pk map[string]bool) string {
+	options := []string{"column:" + strings.Trim(column.Name, "`\"'"), "type:" + column.Type}
+	if pk[strings.ToLower(column.Name)] {
+		options = append(options, "primaryKey")
+	}
+	items := column.Constraints
+	for i, item := range items {
+		switch strings.ToUpper(item) {
+		case "AUTO_INCREMENT", "AUTOINCREMENT":
+			options = append(options, "autoIncrement")
+		case "NOT NULL":
+			options = append(options, "not null")
+		case "UNIQUE":
+			options = append(options, "unique")
+		case "DEFAULT":
+			if i+1 < len(items) {
+				options = append(options, "default:"+items[i+1])
+			}
+		}
+	}
+	if strings.HasSuffix(strings.ToUpper(column.Type), "SERIAL") {
+		options = append(options, "autoIncrement")
+	}
+	for _, index := range table.Indexes {
+		for _, name := range index.Columns {
+			if !strings.EqualFold(name, column.Name) {
+				continue
+			}
+			kind := "index"
+			if index.Unique {
+				kind = "uniqueIndex"
+			}
+			if index.Name != "" {
+				kind += ":" + index.Name
+			}
+			options = append(options, kind)
+		}
+	}
+	if column.Comment != "" {
+		options = append(options, "comment:"+strings.NewReplacer(";", ",", "\n", " ", `"`, "'").Replace(column.Comment))
+	}
+	return strings.Join(options, ";")
+}
+
+// columnGoType returns the Go type of the column and the package it needs
+func columnGoType(column *Column, cfg ModelConfig, pk bool) (string, string) {
+	typ := strings.ToUpper(column.Type)
+	unsigned := strings.Contains(typ, "UNSIGNED")
+	for _, item := range column.Constraints {
+		unsigned = unsigned || strings.EqualFold(item, "UNSIGNED")
+	}
+	base, args, _ := strings.Cut(typ, "(")
+	base = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(base), "UNSIGNED"))
+
+	goType, path := "string", ""
+	switch base {
+	case "BOOL", "BOOLEAN", "BIT":
+		goType = "bool"
+	case "TINYINT":
+		switch {
+		case cfg.Dialect == MySQL && args == "1)":
+			goType = "bool"
+		case unsigned:
+			goType = "uint8"
+		default:
+			goType = "int8"
+		}
+	case "SMALLINT", "INT2", "SMALLSERIAL":
+		goType = "int16"
+	case "MEDIUMINT", "INT", "INT4", "SERIAL":
+		goType = "int32"
+	case "INTEGER":
+		// SQLite integers are 64 bit
+		goType = "int32"
+		if cfg.Dialect == SQLite {
+			goType = "int64"
+		}
+	case "BIGINT", "INT8", "BIGSERIAL":
+		goType = "int64"
+	case "FLOAT", "FLOAT4":
+		goType = "float32"
+	case "REAL":
+		goType = "float32"
+		if cfg.Dialect == SQLite {
+			goType = "float64"
+		}
+	case "DOUBLE", "DOUBLE PRECISION", "FLOAT8":
+		goType = "float64"
+	case "DECIMAL", "NUMERIC":
+		goType, path = decimalGoType(cfg.DecimalType)
+	case "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		goType, path = "time.Time", "time"
+	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BYTEA":
+		// nil is NULL already
+		return "[]byte", ""
+	case "JSON", "JSONB":
+		return "json.RawMessage", "encoding/json"
+	}
+	if unsigned && strings.HasPrefix(goType, "int") {
+		goType = "u" + goType
+	}
+
+	if pk || hasConstraint(column, "NOT NULL") {
+		return goType, path
+	}
+	if cfg.NullTypes {
+		nullTypes := map[string]string{
+			"string":    "sql.NullString",
+			"bool":      "sql.NullBool",
+			"int8":      "sql.NullInt16",
+			"int16":     "sql.NullInt16",
+			"int32":     "sql.NullInt32",
+			"int64":     "sql.NullInt64",
+			"float32":   "sql.NullFloat64",
+			"float64":   "sql.NullFloat64",
+			"time.Time": "sql.NullTime",
+		}
+		if nullType, ok := nullTypes[goType]; ok {
+			return nullType, "database/sql"
+		}
+	}
+	return "*" + goType, path
+}
+
+// decimalGoType returns the type named by ModelConfig.DecimalType and the package it needs
+func decimalGoType(typ string) (string, string) {
+	if typ == "" {
+		return "string", ""
+	}
+	dot := strings.LastIndex(typ, ".")
+	if dot == -1 {
+		return typ, ""
+	}
+	return path.Base(typ[:dot]) + typ[dot:], typ[:dot]
+}
+
+// uniqueName returns name, or name with the first number from 2 making it unused, and marks it used
+func uniqueName(name string, used map[string]bool) string {
+	res := name
+	for i := 2; used[res]; i++ {
+		res = name + strconv.Itoa(i)
+	}
+	used[res] = true
+	return res
+}
+
+var commonInitialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "UID": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// fieldName converts a column name to an exported Go name, e.g. user_id => UserID
+func fieldName(name string) string {
+	res := ""
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		if upper := strings.ToUpper(part); commonInitialisms[upper] {
+			res += upper
+		} else {
+			res += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	if res == "" || res[0] >= '0' && res[0] <= '9' {
+		res = "X" + res
+	}
+	return res
+}
+
+// modelName converts a table name to the name of its model, e.g. user_roles => UserRole
+func modelName(table string) string {
+	name := strings.Trim(table, "`\"'")
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies"):
+		name = name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") &&
+		!strings.HasSuffix(lower, "us") && !strings.HasSuffix(lower, "is"):
+		name = name[:len(name)-1]
+	}
+	return fieldName(name)
+}
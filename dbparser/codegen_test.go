@@ -0,0 +1,135 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testModelSQL = `
+-- users of the shop
+CREATE TABLE users (
+	id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+	user_name VARCHAR(20) NOT NULL DEFAULT 'bob', -- login name
+	-- shown on the profile
+	avatar_url VARCHAR(255),
+	score DECIMAL(10,2) NOT NULL,
+	is_admin TINYINT(1) NOT NULL DEFAULT 0,
+	extra JSON,
+	created_at DATETIME COMMENT 'creation time',
+	UNIQUE KEY uk_user_name (user_name)
+);
+CREATE TABLE user_roles (user_id BIGINT NOT NULL, role VARCHAR(10) NOT NULL, PRIMARY KEY (user_id, role)) COMMENT='roles of a user';
+`
+
+func TestGenModels(t *testing.T) {
+	src, err := GenModelsFromSQL(testModelSQL, ModelConfig{Dialect: MySQL})
+	require.NoError(t, err)
+	code := string(src)
+	for _, expected := range []string{
+		"// Code generated by dbparser. DO NOT EDIT.\n\npackage model\n\nimport (\n\t\"encoding/json\"\n\t\"time\"\n)\n",
+		"// User users of the shop\ntype User struct {\n",
+		"\tID        uint64          `gorm:\"column:id;type:BIGINT;primaryKey;autoIncrement\" json:\"id\"`\n",
+		"\tUserName  string          `gorm:\"column:user_name;type:VARCHAR(20);not null;default:'bob';uniqueIndex:uk_user_name;comment:login name\" json:\"user_name\"` // login name\n",
+		"\tAvatarURL *string ",
+		"\tScore     string ",
+		"\tIsAdmin   bool ",
+		"\tExtra     json.RawMessage ",
+		"\tCreatedAt *time.Time      `gorm:\"column:created_at;type:DATETIME;comment:creation time\" json:\"created_at\"` // creation time\n",
+		"func (User) TableName() string {\n\treturn \"users\"\n}\n",
+		"func (User) GetStatement() string {\n\treturn \"select * from users where id = :id\"\n}\n",
+		"func (User) UpdateStatement() string {\n\treturn \"update users set user_name = :user_name,avatar_url = :avatar_url,score = :score,is_admin = :is_admin,extra = :extra,created_at = :created_at where id = :id\"\n}\n",
+		"func (User) DeleteStatement() string {\n\treturn \"delete from users where id = :id\"\n}\n",
+		"// UserRole roles of a user\ntype UserRole struct {\n\tUserID int64  `gorm:\"column:user_id;type:BIGINT;primaryKey;not null\" json:\"user_id\"`\n",
+		"func (UserRole) AddStatement() string {\n\treturn \"insert into user_roles (user_id,role) values (:user_id,:role)\"\n}\n",
+	} {
+		require.Contains(t, code, expected)
+	}
+	// no statements by primary key for a composite key
+	require.NotContains(t, code, "func (UserRole) GetStatement")
+}
+
+func TestGenModelsTypes(t *testing.T) {
+	tables, err := GetTables(`CREATE TABLE items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		price REAL,
+		flag TINYINT(1),
+		data BLOB,
+		seen TIMESTAMP NOT NULL,
+		note TEXT
+	)`)
+	require.NoError(t, err)
+
+	src, err := GenModels(tables, ModelConfig{Package: "db", Dialect: SQLite, NullTypes: true})
+	require.NoError(t, err)
+	code := string(src)
+	require.Contains(t, code, "package db\n\nimport (\n\t\"database/sql\"\n\t\"time\"\n)\n")
+	require.Contains(t, code, "type Item struct {\n")
+	require.Contains(t, code, "\tID    int64 ")
+	require.Contains(t, code, "\tPrice sql.NullFloat64 ")
+	require.Contains(t, code, "\tFlag  sql.NullInt16 ")
+	require.Contains(t, code, "\tData  []byte ")
+	require.Contains(t, code, "\tSeen  time.Time ")
+	require.Contains(t, code, "\tNote  sql.NullString ")
+
+	src, err = GenModels(tables, ModelConfig{Dialect: PostgreSQL})
+	require.NoError(t, err)
+	require.Contains(t, string(src), "\tID    int32 ")
+	require.Contains(t, string(src), "\tPrice *float32 ")
+}
+
+func TestGenModelsCollisions(t *testing.T) {
+	tables, err := GetTables(`CREATE TABLE user (id INT PRIMARY KEY, user_id INT, userID INT, table_name TEXT, amount DECIMAL(10,2));
+	CREATE TABLE users (id INT PRIMARY KEY);
+	CREATE TABLE users_ (id INT PRIMARY KEY)`)
+	require.NoError(t, err)
+	src, err := GenModels(tables, ModelConfig{Package: "db"})
+	require.NoError(t, err)
+	code := string(src)
+	for _, expected := range []string{
+		"type User struct {\n",
+		"\tUserID     *int32 ",
+		"\tUserID2    *int32 ",
+		"\tTableName2 *string ",
+		"\tAmount     *string ",
+		"func (User) TableName() string {\n\treturn \"user\"\n}\n",
+		"type Users struct {\n",
+		"func (Users) TableName() string {\n\treturn \"users\"\n}\n",
+		"type Users2 struct {\n",
+		"func (Users2) TableName() string {\n\treturn \"users_\"\n}\n",
+	} {
+		require.Contains(t, code, expected)
+	}
+
+	src, err = GenModels(tables[:1], ModelConfig{DecimalType: "github.com/shopspring/decimal.Decimal"})
+	require.NoError(t, err)
+	require.Contains(t, string(src), "import (\n\t\"github.com/shopspring/decimal\"\n)\n")
+	require.Contains(t, string(src), "\tAmount     *decimal.Decimal ")
+	src, err = GenModels(tables[:1], ModelConfig{DecimalType: "float64", NullTypes: true})
+	require.NoError(t, err)
+	require.Contains(t, string(src), "\tAmount     sql.NullFloat64 ")
+}
+
+func TestModelNames(t *testing.T) {
+	require.Equal(t, "UserID", fieldName("user_id"))
+	require.Equal(t, "APIURL", fieldName("api_url"))
+	require.Equal(t, "X2fa", fieldName("2fa"))
+	require.Equal(t, "Category", modelName("categories"))
+	require.Equal(t, "Status", modelName("status"))
+	require.Equal(t, "Address", modelName("`address`"))
+	require.Equal(t, "OrderItem", modelName("order_items"))
+}
+
+func TestColumnComments(t *testing.T) {
+	table, err := ParserSql(`-- a table
+	-- of things
+	CREATE TABLE t (
+		a INT, -- first
+		-- second
+		b INT,
+		c INT -- third
+	)`)
+	require.NoError(t, err)
+	require.Equal(t, "a table\nof things", table.Comment)
+	require.Equal(t, []string{"first", "second", "third"}, []string{table.Columns[0].Comment, table.Columns[1].Comment, table.Columns[2].Comment})
+}
@@ -16,21 +16,33 @@ var (
 type Token struct {
 	Type  TokenType // The type of the token
 	Value string    // The value of the token
+	// Comment is the "--" comment on the line of the token, or on the lines before it
+	Comment string
+	Span    Span // The position of the token in the source
 }
 
 // Table is a struct that represents a create table statement
 type Table struct {
-	Name    string // The name of the table
-	PkName  string
+	Node
+	Name    string    // The name of the table
+	Comment string    // The comment before CREATE TABLE or the MySQL COMMENT option
+	PkName  string    // The first column of the primary key
 	Columns []*Column // The slice of columns in the table
 	Options []string  // The slice of options for the table
+
+	PrimaryKey  []string      // The columns of the primary key
+	Indexes     []*Index      // Indexes and UNIQUE constraints
+	ForeignKeys []*ForeignKey // Table level and inline (REFERENCES) foreign keys
+	Checks      []*Check      // CHECK constraints
 }
 
 // Column is a struct that represents a column in a table
 type Column struct {
+	Node
 	Name        string   `json:"name"`        // The name of the column
 	Type        string   `json:"type"`        // The type of the column
 	Constraints []string `json:"constraints"` // The slice of constraints for the column
+	Comment     string   `json:"comment"`     // The "--" comment of the column or its MySQL COMMENT
 }
 
 func (c *Column) ToMap() map[string]interface{} {
@@ -41,15 +53,9 @@ func (c *Column) ToMap() map[string]interface{} {
 	}
 }
 
+// ToSchema formats the table back to a CREATE TABLE statement, see Printer.Table for dialects
 func (t Table) ToSchema() string {
-	sql := fmt.Sprintf("CREATE TABLE %s (\n", t.Name) // start with the table name
-
-	for _, item := range t.Columns {
-		sql += fmt.Sprintf("\t%s %s %s,\n", item.Name, item.Type, strings.Join(item.Constraints, " ")) // add each column with its type and extra information
-	}
-	sql += fmt.Sprintf("\tPRIMARY KEY (%s)\n", t.PkName)
-	sql += ");"
-	return sql
+	return genericPrinter.Table(t)
 }
 
 // TokenType is an enum that defines the possible types of tokens
@@ -98,7 +104,7 @@ var keywords = map[string]TokenType{
 
 func ParseMultiSql(allSql string) ([]Table, []string, error) {
 	lex := NewLexer(allSql)
-	tables, err := NewParser(lex.Tokenize()).Parse()
+	tables, err := lex.Parser().Parse()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -106,8 +112,7 @@ func ParseMultiSql(allSql string) ([]Table, []string, error) {
 }
 
 func ParserSql(sql string) (Table, error) {
-	lex := NewLexer(sql)
-	tables, err := NewParser(lex.Tokenize()).Parse()
+	tables, err := NewLexer(sql).Parser().Parse()
 	if err != nil {
 		return Table{}, err
 	}
@@ -118,8 +123,7 @@ func ParserSql(sql string) (Table, error) {
 }
 
 func GetTables(sql string) ([]Table, error) {
-	lex := NewLexer(sql)
-	tables, err := NewParser(lex.Tokenize()).Parse()
+	tables, err := NewLexer(sql).Parser().Parse()
 	if err != nil {
 		return nil, err
 	}
@@ -130,8 +134,7 @@ func GetTables(sql string) ([]Table, error) {
 // It filters out comments and non-CREATE TABLE statements, and adapts to both SQLite3 and MySQL syntax
 func GetTableFromSQL(sql string, tableName string) (Table, error) {
 	// Tokenize and parse the SQL
-	lex := NewLexer(sql)
-	tables, err := NewParser(lex.Tokenize()).Parse()
+	tables, err := NewLexer(sql).Parser().Parse()
 	if err != nil {
 		return Table{}, err
 	}
@@ -143,7 +146,7 @@ func GetTableFromSQL(sql string, tableName string) (Table, error) {
 		}
 	}
 
-	return Table{}, errors.New("table not found")
+	return Table{}, ErrTableNotFound
 }
 
 // LookupIdent returns the token type for an identifier, or ILLEGAL if it's not a keyword
@@ -162,6 +165,11 @@ type Lexer struct {
 	ch       byte    // The current character under examination
 	tokens   []Token // The slice of tokens produced by the lexer
 	comments []string
+	pending  string // comments of the next token
+	tokenEnd int    // The position after the last token
+	lines    *lineIndex
+	base     int // The offset of input in the source, input is trimmed
+	size     int // The size of input without the semicolon appended
 }
 
 // NewLexer returns a new instance of a lexer given an input string
@@ -169,11 +177,14 @@ func NewLexer(input string) *Lexer {
 	if input == "" {
 		return &Lexer{}
 	}
+	lines := newLineIndex(input)
+	base := len(input) - len(strings.TrimLeft(input, " \t\r\n"))
 	input = strings.TrimSpace(input)
+	size := len(input)
 	if input[len(input)-1] != ';' {
 		input = input + ";"
 	}
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, lines: lines, base: base, size: size}
 	l.readChar() // Initialize the lexer state by reading the first character
 	return l
 }
@@ -252,7 +263,46 @@ func (l *Lexer) skipAndStoreComment() {
 		}
 
 		l.comments = append(l.comments, strings.TrimSpace(string(comment)[2:]))
+		l.skipWhitespace() // the next line may be indented
+	}
+}
+
+// span converts offsets of the trimmed input to a span of the source
+func (l *Lexer) span(start, end int) Span {
+	if l.lines == nil {
+		return Span{}
+	}
+	return l.lines.span(l.base+start, l.base+end)
+}
+
+// Parser tokenizes the input and returns a parser whose errors quote the source
+func (l *Lexer) Parser() *Parser {
+	p := NewParser(l.Tokenize())
+	p.lines = l.lines
+	return p
+}
+
+// attachComments gives a comment on the line of the previous token to it, other comments to the next token
+func (l *Lexer) attachComments(comments []string, newline bool) {
+	for _, comment := range comments {
+		switch {
+		case comment == "":
+		case !newline && len(l.tokens) > 0:
+			last := &l.tokens[len(l.tokens)-1]
+			last.Comment = joinComment(last.Comment, comment)
+		default:
+			l.pending = joinComment(l.pending, comment)
+		}
+		// the comment ends its line
+		newline = true
+	}
+}
+
+func joinComment(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
 	}
+	return a + "\n" + b
 }
 
 func (l *Lexer) peekIdent() string {
@@ -312,9 +362,14 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-// 是否是转义付
+// isOperator returns true for the characters of comparison operators
+func isOperator(ch byte) bool {
+	return ch == '<' || ch == '>' || ch == '=' || ch == '!'
+}
+
+// 是否是转义付, 标识符的引号直接跳过
 func isSpecial(ch byte) bool {
-	return ch == '`'
+	return ch == '`' || ch == '"'
 }
 
 // Tokenize performs the lexical analysis on the input and returns a slice of tokens
@@ -322,7 +377,10 @@ func (l *Lexer) Tokenize() []Token {
 	for l.ch != 0 { // Loop until end of input
 		l.skipWhitespace() // Skip any whitespace
 		// l.skipComment()    // Skip any comment
+		stored, newline := len(l.comments), strings.Contains(l.input[l.tokenEnd:l.pos], "\n")
 		l.skipAndStoreComment()
+		l.attachComments(l.comments[stored:], newline)
+		start := l.pos
 
 		var tok Token // Declare a token variable
 
@@ -361,11 +419,20 @@ func (l *Lexer) Tokenize() []Token {
 					}
 				} else if (ident == "DECIMAL" || ident == "decimal") && l.peekChar() == '(' {
 					l.skipSpaceAndChar()
+					l.skipSpace()
 					digit := l.readInt()
-					l.skipSpaceAndChar()
-					fix := l.readInt()
-					l.skipSpaceAndChar()
-					ident = fmt.Sprintf("DECIMAL(%s,%s)", digit, fix)
+					l.readChar()
+					l.skipSpace()
+					ident = fmt.Sprintf("DECIMAL(%s)", digit)
+					// the scale is optional, the token ends at the closing parens
+					if l.ch == ',' {
+						l.readChar()
+						l.skipSpace()
+						fix := l.readInt()
+						l.readChar()
+						l.skipSpace()
+						ident = fmt.Sprintf("DECIMAL(%s,%s)", digit, fix)
+					}
 				} else if ident == "primary" || ident == "PRIMARY" { // // 3、primary key
 					l.skipChar()
 					if l.peekIdent() == "key" || l.peekIdent() == "KEY" {
@@ -381,18 +448,31 @@ func (l *Lexer) Tokenize() []Token {
 			} else if isSpecial(l.ch) {
 				l.readChar()
 				continue
+			} else if isOperator(l.ch) { // Comparison operators are kept together, e.g. >=
+				start := l.pos
+				for isOperator(l.peekChar()) {
+					l.readChar()
+				}
+				tok = Token{Type: ILLEGAL, Value: l.input[start : l.pos+1]}
 			} else { // Anything else, illegal or unknown token
 				tok = Token{Type: ILLEGAL, Value: string(l.ch)}
 			}
 		}
 
+		tok.Comment, l.pending = l.pending, ""
 		l.tokens = append(l.tokens, tok) // Append the token to the slice of tokens
 
 		l.readChar() // Read the next character
+		l.tokenEnd = l.pos
+		l.tokens[len(l.tokens)-1].Span = l.span(start, l.pos)
+		if start >= l.size {
+			// The semicolon appended by NewLexer is not in the source
+			l.tokens[len(l.tokens)-1].Value = ""
+		}
 
 	}
 
-	l.tokens = append(l.tokens, Token{Type: EOF}) // Append the end of file token to the slice of tokens
+	l.tokens = append(l.tokens, Token{Type: EOF, Span: l.span(len(l.input), len(l.input))}) // Append the end of file token to the slice of tokens
 
 	return l.tokens // Return the slice of tokens
 
@@ -400,8 +480,10 @@ func (l *Lexer) Tokenize() []Token {
 
 // Parser is a struct that holds the state of the syntactic analysis process
 type Parser struct {
-	tokens []Token // The slice of tokens to parse
-	pos    int     // The current position in the tokens (points to current token)
+	tokens    []Token // The slice of tokens to parse
+	pos       int     // The current position in the tokens (points to current token)
+	lines     *lineIndex
+	statement int // The index of the statement being parsed
 }
 
 // NewParser returns a new instance of a parser given a slice of tokens
@@ -411,161 +493,78 @@ func NewParser(tokens []Token) *Parser {
 	return p
 }
 
-// Parse performs the syntactic analysis on the tokens and returns a table struct or an error
+// Parse performs the syntactic analysis on the tokens and returns the tables created by the statements.
+// ALTER TABLE, CREATE INDEX and DROP statements are applied to those tables in order,
+// statements on tables that are not created in the input and other statements (e.g. INSERT) are skipped.
+// Use ParseStatements and Schema.Apply for a strict result.
 func (p *Parser) Parse() ([]Table, error) {
-	tables := []Table{}
-	for {
-		// Check if we've reached the end
-		if p.expect(EOF) {
-			break
-		}
-
-		if t, err := p.parseTable(); err == nil {
-			tables = append(tables, t)
-		} else if errors.Is(err, ErrNotCreateTable) {
-			// Skip non-CREATE TABLE statements until semicolon
-			for !p.expect(SEMI) && !p.expect(EOF) {
-				p.next()
-			}
-			if p.expect(SEMI) {
-				p.next()
-			}
-		} else {
-			if err != io.EOF {
-				return nil, errors.Wrapf(err, "解析失败")
-			}
-			break
-		}
+	stmts, err := p.ParseStatements()
+	if err != nil {
+		return nil, err
+	}
+	schema := NewSchema()
+	for _, stmt := range stmts {
+		_ = schema.Apply(stmt)
+	}
+	tables := make([]Table, 0, len(schema.Tables))
+	for _, table := range schema.Tables {
+		tables = append(tables, *table)
 	}
 	return tables, nil
 }
 
-func (p *Parser) parseTable() (Table, error) {
-	var table Table
+func (p *Parser) parseCreateTable() (*CreateTable, error) {
+	stmt := &CreateTable{}
+	table := &stmt.Table
 
 	if p.expect(EOF) {
-		return table, io.EOF
+		return stmt, io.EOF
 	}
 
 	if !p.expect(CREATE) { // Expect a create keyword token
-		return table, errors.WithStack(ErrNotCreateTable)
+		return stmt, errors.WithStack(ErrNotCreateTable)
 	}
+	table.Comment = p.current().Comment
 	p.next() // Advance to next token
 
 	if !p.expect(TABLE) { // Expect a table keyword token
-		// Skip non-CREATE TABLE statements (e.g., CREATE INDEX)
-		return table, errors.WithStack(ErrNotCreateTable)
+		return stmt, errors.WithStack(ErrNotCreateTable)
 	}
 	p.next() // Advance to next token
 
-	if p.accept(IF) { // Accept an if keyword token
-		p.next()            // Advance to next token
-		if !p.expect(NOT) { // Expect a not keyword token
-			return Table{}, p.error("expected NOT")
-		}
-		p.next()               // Advance to next token
-		if !p.expect(EXISTS) { // Expect an exists keyword token
-			return Table{}, p.error("expected EXISTS")
-		}
-		p.next() // Advance to next token
+	ifNotExists, err := p.parseIfNotExists()
+	if err != nil {
+		return stmt, err
 	}
+	stmt.IfNotExists = ifNotExists
 
 	if !p.expect(IDENT) { // Expect an identifier token for the table name
-		return Table{}, p.error("expected table name")
+		return stmt, p.error("expected table name")
 	}
 	table.Name = p.current().Value // Set the table name in the table struct
 	p.next()                       // Advance to next token
 
 	if !p.expect(LPAREN) { // Expect a left parenthesis token for the column list
-		return table, p.error("expected (")
+		return stmt, p.error("expected (")
 	}
 	p.next() // Advance to next token
 
 	for { // Loop until end of column list or end of input
-		// Check if it's a table-level PRIMARY KEY constraint
-		v := strings.ToUpper(p.current().Value)
-		if strings.Contains(v, "PRIMARY KEY") {
-			// Handle table-level PRIMARY KEY
-			p.next()
-			if !p.expect(LPAREN) {
-				return table, p.error("expected (")
-			}
-			p.next()
-			if p.expect(IDENT) {
-				table.PkName = p.current().Value
-				p.next()
-			}
-			if p.expect(RPAREN) {
-				p.next()
+		if p.isTableConstraint() {
+			// Table level PRIMARY KEY, UNIQUE, KEY/INDEX, FOREIGN KEY and CHECK
+			constraint, err := p.parseConstraint()
+			if err != nil {
+				return stmt, err
 			}
-			if p.accept(COMMA) {
-				p.next()
-				continue
-			}
-			if p.expect(RPAREN) {
-				break
-			}
-			return table, p.error("expected )")
-		}
-
-		if !p.expect(IDENT) { // Expect an identifier token for the column name
-			return table, p.error("expected column name")
-		}
-		column := &Column{Name: p.current().Value} // Create a column struct with the column name
-		p.next()                                   // Advance to next token
-
-		if !p.expect(IDENT) { // Expect an identifier token for the column type
-			return Table{}, p.error("expected column type")
-		}
-		column.Type = p.current().Value // Set the column type in the column struct
-		p.next()                        // Advance to next token
-
-		// Accept any tokens for the column constraints, including identifiers, numbers, strings, and complex constraints with parentheses
-		for {
-			// Check if we've reached the end of constraints
-			if p.expect(COMMA) || p.expect(RPAREN) {
-				break
-			}
-
-			// Handle complex constraints with parentheses
-			if p.accept(IDENT) {
-				v := strings.ToUpper(p.current().Value)
-				if strings.Contains(v, "PRIMARY KEY") {
-					table.PkName = column.Name
-				}
-				column.Constraints = append(column.Constraints, p.current().Value)
-				p.next()
-
-				// Handle constraints with parentheses like FOREIGN KEY (col) REFERENCES table(col)
-				if p.expect(LPAREN) {
-					// Collect all tokens until matching RPAREN
-					parenthesisCount := 1
-					constraintPart := "("
-					p.next()
-
-					for parenthesisCount > 0 && !p.expect(EOF) {
-						if p.expect(LPAREN) {
-							parenthesisCount++
-						} else if p.expect(RPAREN) {
-							parenthesisCount--
-						}
-						constraintPart += p.current().Value
-						p.next()
-					}
-					column.Constraints = append(column.Constraints, constraintPart)
-				}
-			} else if p.accept(INT) || p.accept(STRING) {
-				// Handle numbers and strings in constraints
-				column.Constraints = append(column.Constraints, p.current().Value)
-				p.next()
-			} else {
-				// Skip any other tokens
-				p.next()
+			table.addConstraint(constraint)
+		} else {
+			column, err := p.parseColumn()
+			if err != nil {
+				return stmt, err
 			}
+			table.addColumn(column)
 		}
 
-		table.Columns = append(table.Columns, column) // Append the column to the table struct
-
 		if p.accept(COMMA) { // Accept a comma token as a separator for multiple columns
 			p.next() // Advance to next token
 			continue // Continue with the next column
@@ -575,7 +574,7 @@ func (p *Parser) parseTable() (Table, error) {
 			break // Break the loop
 		}
 
-		return table, p.error("expected , or )") // Unexpected token, return an error
+		return stmt, p.error("expected , or )") // Unexpected token, return an error
 	}
 
 	p.next() // Advance to next token
@@ -584,16 +583,90 @@ func (p *Parser) parseTable() (Table, error) {
 		if p.accept(IDENT) {
 			table.Options = append(table.Options, p.current().Value) // Append the option to the table struct
 		}
+		if isWord(p.current(), "COMMENT") {
+			// COMMENT [=] 'text'
+			if next := p.peekToken(1); next.Type == STRING {
+				table.Comment = next.Value
+			} else if next = p.peekToken(2); next.Type == STRING && p.peekToken(1).Value == "=" {
+				table.Comment = next.Value
+			}
+		}
 		p.next() // Advance to next token
 	}
-	if p.expect(SEMI) {
-		p.next()
+	return stmt, nil
+}
+
+// parseColumn parses a column definition: name type [constraints...]
+func (p *Parser) parseColumn() (*Column, error) {
+	if !p.expect(IDENT) { // Expect an identifier token for the column name
+		return nil, p.error("expected column name")
+	}
+	column := &Column{Name: p.current().Value} // Create a column struct with the column name
+	start := p.pos
+	p.next() // Advance to next token
+
+	if !p.expect(IDENT) { // Expect an identifier token for the column type
+		return nil, p.error("expected column type")
+	}
+	column.Type = p.current().Value // Set the column type in the column struct
+	p.next()                        // Advance to next token
+	if p.expect(LPAREN) {
+		// Type with parameters, e.g. CHAR(10), INT(11)
+		params, err := p.readParens()
+		if err != nil {
+			return nil, err
+		}
+		column.Type += params
 	}
 
-	// if !p.expect(EOF) { // Expect an end of file token as the end of the statement
-	// 	return nil, p.error("expected EOF")
-	// }
-	return table, nil
+	// Accept any tokens for the column constraints, including identifiers, numbers, strings, and complex constraints with parentheses
+	for {
+		// Check if we've reached the end of constraints
+		if p.accept(COMMA, RPAREN, SEMI, EOF) {
+			break
+		}
+
+		// Handle complex constraints with parentheses
+		if p.accept(IDENT) {
+			column.Constraints = append(column.Constraints, p.current().Value)
+			p.next()
+
+			// Handle constraints with parentheses like REFERENCES table(col) or CHECK (a > 0)
+			if p.expect(LPAREN) {
+				params, err := p.readParens()
+				if err != nil {
+					return nil, err
+				}
+				column.Constraints = append(column.Constraints, params)
+			}
+		} else if p.accept(INT) {
+			// Handle numbers in constraints
+			column.Constraints = append(column.Constraints, p.current().Value)
+			p.next()
+		} else if p.accept(STRING) {
+			// Strings keep their quotes, e.g. DEFAULT 'active'
+			column.Constraints = append(column.Constraints, "'"+p.current().Value+"'")
+			p.next()
+		} else {
+			// Skip any other tokens
+			p.next()
+		}
+	}
+
+	// "--" comments before the column and on its line, including after the comma
+	for i := start; i < p.pos; i++ {
+		column.Comment = joinComment(column.Comment, p.tokens[i].Comment)
+	}
+	if p.expect(COMMA) {
+		column.Comment = joinComment(column.Comment, p.current().Comment)
+	}
+	column.Span = p.spanFrom(start)
+	for i, item := range column.Constraints {
+		if strings.EqualFold(item, "COMMENT") && i+1 < len(column.Constraints) && strings.HasPrefix(column.Constraints[i+1], "'") {
+			column.Comment = strings.Trim(column.Constraints[i+1], "'")
+		}
+	}
+	return column, nil
 }
 
 // current returns the current token in the tokens slice
@@ -623,5 +696,17 @@ func (p *Parser) accept(types ...TokenType) bool {
 
 // error returns an error with a formatted message that includes the current token value and type
 func (p *Parser) error(msg string) error {
-	return fmt.Errorf("%s, got %s", msg, p.current().Value)
+	got := p.current().Value
+	if p.expect(EOF) || p.expect(SEMI) && got == "" {
+		got = "end of input"
+	}
+	err := p.lines.errorAt(p.current().Span.Start, fmt.Sprintf("%s, got %s", msg, got))
+	err.Statement = p.statement
+	return err
+}
+
+// spanFrom returns the span from the token at start to the last token read
+func (p *Parser) spanFrom(start int) Span {
+	end := p.tokens[max(start, p.pos-1)]
+	return Span{Start: p.tokens[start].Span.Start, End: end.Span.End}
 }
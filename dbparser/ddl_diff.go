@@ -0,0 +1,346 @@
+package dbparser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeType is the type of a schema change, changes are applied in the order of their types
+type ChangeType int
+
+const (
+	// DropForeignKeyChange drops ForeignKey, first so that the tables and columns it references can change
+	DropForeignKeyChange ChangeType = iota
+	// DropCheckChange drops Check
+	DropCheckChange
+	// DropIndexChange drops Index, a unique constraint when Index.Constraint is set
+	DropIndexChange
+	// DropPrimaryKeyChange drops the primary key of From
+	DropPrimaryKeyChange
+	// CreateTableChange creates To
+	CreateTableChange
+	// AddColumnChange adds Column
+	AddColumnChange
+	// AlterColumnChange changes OldColumn to Column
+	AlterColumnChange
+	// AddPrimaryKeyChange adds the primary key of To
+	AddPrimaryKeyChange
+	// DropColumnChange drops OldColumn
+	DropColumnChange
+	// DropTableChange drops From
+	DropTableChange
+	// AddIndexChange adds Index
+	AddIndexChange
+	// AddCheckChange adds Check
+	AddCheckChange
+	// AddForeignKeyChange adds ForeignKey, last so that the tables and columns it references exist
+	AddForeignKeyChange
+)
+
+// ChangeTypeString is a string slice with the names of all change types in order
+var ChangeTypeString = []string{
+	"drop foreign key",
+	"drop check",
+	"drop index",
+	"drop primary key",
+	"create table",
+	"add column",
+	"alter column",
+	"add primary key",
+	"drop column",
+	"drop table",
+	"add index",
+	"add check",
+	"add foreign key",
+}
+
+func (t ChangeType) String() string {
+	return enumName(ChangeTypeString, int(t), "ChangeType")
+}
+
+// Change is one difference between two schemas
+type Change struct {
+	Type ChangeType
+	// Table is the name of the table in the new schema, or in the old one for DropTableChange
+	Table string
+	// From and To are the table before and after, From is nil for CreateTableChange and To is nil for DropTableChange
+	From, To   *Table
+	Column     *Column
+	OldColumn  *Column
+	Index      *Index
+	ForeignKey *ForeignKey
+	Check      *Check
+}
+
+// Destructive reports whether the change may lose data: dropping a table or a column,
+// or changing the type of a column unless only its length grows, e.g. VARCHAR(20) to VARCHAR(50)
+func (c *Change) Destructive() bool {
+	switch c.Type {
+	case DropTableChange, DropColumnChange:
+		return true
+	case AlterColumnChange:
+		return !strings.EqualFold(c.OldColumn.Type, c.Column.Type) && !widensType(c.OldColumn.Type, c.Column.Type)
+	}
+	return false
+}
+
+// widensType reports whether to is from with larger or equal length / precision
+func widensType(from, to string) bool {
+	fromName, fromArgs, _ := strings.Cut(from, "(")
+	toName, toArgs, _ := strings.Cut(to, "(")
+	if !strings.EqualFold(fromName, toName) || fromArgs == "" || toArgs == "" {
+		return false
+	}
+	x, y := strings.Split(strings.TrimSuffix(fromArgs, ")"), ","), strings.Split(strings.TrimSuffix(toArgs, ")"), ",")
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		a, errA := strconv.Atoi(strings.TrimSpace(x[i]))
+		b, errB := strconv.Atoi(strings.TrimSpace(y[i]))
+		if errA != nil || errB != nil || b < a {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Change) String() string {
+	res := c.Type.String() + " " + c.Table
+	switch {
+	case c.Column != nil:
+		res += "." + c.Column.Name
+	case c.OldColumn != nil:
+		res += "." + c.OldColumn.Name
+	case c.Index != nil:
+		res += " (" + strings.Join(c.Index.Columns, ", ") + ")"
+	case c.ForeignKey != nil:
+		res += " (" + strings.Join(c.ForeignKey.Columns, ", ") + ") -> " + c.ForeignKey.RefTable
+	case c.Check != nil:
+		res += " (" + c.Check.Expr + ")"
+	case c.Type == AddPrimaryKeyChange:
+		res += " (" + strings.Join(c.To.PrimaryKey, ", ") + ")"
+	}
+	return res
+}
+
+// DiffSchema returns the changes turning from into to, sorted in the order they must be applied.
+// Tables, columns and indexes are matched by name (case-insensitive), a renamed one is dropped and added again.
+// Created tables are sorted so that referenced tables come first, dropped tables the other way around.
+func DiffSchema(from, to *Schema) []*Change {
+	changes := []*Change{}
+	for _, newTable := range sortByReferences(to.Tables) {
+		oldTable := from.Table(newTable.Name)
+		if oldTable == nil {
+			changes = append(changes, &Change{Type: CreateTableChange, Table: newTable.Name, To: newTable})
+			for _, index := range newTable.Indexes {
+				if !index.Constraint {
+					changes = append(changes, &Change{Type: AddIndexChange, Table: newTable.Name, To: newTable, Index: index})
+				}
+			}
+			continue
+		}
+		changes = append(changes, diffTable(oldTable, newTable)...)
+	}
+
+	dropped := sortByReferences(from.Tables)
+	for i := len(dropped) - 1; i >= 0; i-- {
+		if to.Table(dropped[i].Name) == nil {
+			changes = append(changes, &Change{Type: DropTableChange, Table: dropped[i].Name, From: dropped[i]})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Type < changes[j].Type
+	})
+	return changes
+}
+
+func diffTable(from, to *Table) []*Change {
+	changes := []*Change{}
+	change := func(typ ChangeType) *Change {
+		c := &Change{Type: typ, Table: to.Name, From: from, To: to}
+		changes = append(changes, c)
+		return c
+	}
+
+	for _, column := range to.Columns {
+		old := from.Column(column.Name)
+		switch {
+		case old == nil:
+			change(AddColumnChange).Column = column
+		case !sameColumn(old, column):
+			c := change(AlterColumnChange)
+			c.Column, c.OldColumn = column, old
+		}
+	}
+	for _, old := range from.Columns {
+		if to.Column(old.Name) == nil {
+			change(DropColumnChange).OldColumn = old
+		}
+	}
+
+	if !sameNames(from.PrimaryKey, to.PrimaryKey) {
+		if len(from.PrimaryKey) > 0 {
+			change(DropPrimaryKeyChange)
+		}
+		if len(to.PrimaryKey) > 0 {
+			change(AddPrimaryKeyChange)
+		}
+	}
+
+	for _, index := range from.Indexes {
+		if findIndex(to.Indexes, index) == nil {
+			change(DropIndexChange).Index = index
+		}
+	}
+	for _, index := range to.Indexes {
+		if findIndex(from.Indexes, index) == nil {
+			change(AddIndexChange).Index = index
+		}
+	}
+	for _, fk := range from.ForeignKeys {
+		if findForeignKey(to.ForeignKeys, fk) == nil {
+			change(DropForeignKeyChange).ForeignKey = fk
+		}
+	}
+	for _, fk := range to.ForeignKeys {
+		if findForeignKey(from.ForeignKeys, fk) == nil {
+			change(AddForeignKeyChange).ForeignKey = fk
+		}
+	}
+	for _, check := range from.Checks {
+		if findCheck(to.Checks, check) == nil {
+			change(DropCheckChange).Check = check
+		}
+	}
+	for _, check := range to.Checks {
+		if findCheck(from.Checks, check) == nil {
+			change(AddCheckChange).Check = check
+		}
+	}
+	return changes
+}
+
+// sortByReferences sorts the tables so that a table comes after the tables it references,
+// tables in a reference cycle keep their order
+func sortByReferences(tables []*Table) []*Table {
+	res := make([]*Table, 0, len(tables))
+	done := map[*Table]bool{}
+	visiting := map[*Table]bool{}
+	var visit func(t *Table)
+	visit = func(t *Table) {
+		if done[t] || visiting[t] {
+			return
+		}
+		visiting[t] = true
+		for _, fk := range t.ForeignKeys {
+			for _, ref := range tables {
+				if ref != t && strings.EqualFold(ref.Name, fk.RefTable) {
+					visit(ref)
+				}
+			}
+		}
+		done[t] = true
+		res = append(res, t)
+	}
+	for _, t := range tables {
+		visit(t)
+	}
+	return res
+}
+
+func sameColumn(a, b *Column) bool {
+	if !strings.EqualFold(a.Type, b.Type) {
+		return false
+	}
+	x, y := columnConstraints(a), columnConstraints(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if normalizeConstraint(x[i]) != normalizeConstraint(y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeConstraint(item string) string {
+	if strings.HasPrefix(item, "'") {
+		return item
+	}
+	item = strings.ToUpper(item)
+	if item == "AUTOINCREMENT" {
+		return "AUTO_INCREMENT"
+	}
+	return item
+}
+
+// columnConstraints returns the constraints of the column without PRIMARY KEY and REFERENCES,
+// they are compared and changed at table level
+func columnConstraints(column *Column) []string {
+	res := []string{}
+	items := column.Constraints
+	for i := 0; i < len(items); i++ {
+		switch {
+		case strings.EqualFold(items[i], "PRIMARY KEY"):
+		case strings.EqualFold(items[i], "REFERENCES"):
+			i++ // table
+			if i+1 < len(items) && strings.HasPrefix(items[i+1], "(") {
+				i++
+			}
+			for i+2 < len(items) && strings.EqualFold(items[i+1], "ON") {
+				i += 3
+				if action := strings.ToUpper(items[i]); (action == "SET" || action == "NO") && i+1 < len(items) {
+					i++
+				}
+			}
+		default:
+			res = append(res, items[i])
+		}
+	}
+	return res
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func findIndex(indexes []*Index, index *Index) *Index {
+	for _, item := range indexes {
+		if strings.EqualFold(item.Name, index.Name) && sameNames(item.Columns, index.Columns) &&
+			item.Unique == index.Unique && item.Constraint == index.Constraint {
+			return item
+		}
+	}
+	return nil
+}
+
+func findForeignKey(fks []*ForeignKey, fk *ForeignKey) *ForeignKey {
+	for _, item := range fks {
+		if strings.EqualFold(item.Name, fk.Name) && sameNames(item.Columns, fk.Columns) &&
+			strings.EqualFold(item.RefTable, fk.RefTable) && sameNames(item.RefColumns, fk.RefColumns) &&
+			strings.EqualFold(item.OnDelete, fk.OnDelete) && strings.EqualFold(item.OnUpdate, fk.OnUpdate) {
+			return item
+		}
+	}
+	return nil
+}
+
+func findCheck(checks []*Check, check *Check) *Check {
+	for _, item := range checks {
+		if strings.EqualFold(item.Name, check.Name) && item.Expr == check.Expr {
+			return item
+		}
+	}
+	return nil
+}
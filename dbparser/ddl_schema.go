@@ -0,0 +1,410 @@
+package dbparser
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrTableNotFound  = errors.New("table not found")
+	ErrTableExists    = errors.New("table already exists")
+	ErrColumnNotFound = errors.New("column not found")
+	ErrColumnExists   = errors.New("column already exists")
+	ErrIndexNotFound  = errors.New("index or constraint not found")
+	ErrIndexExists    = errors.New("index already exists")
+)
+
+// Schema is the set of tables built by applying DDL statements in order
+type Schema struct {
+	Tables []*Table
+}
+
+// NewSchema returns an empty schema
+func NewSchema() *Schema {
+	return &Schema{Tables: []*Table{}}
+}
+
+// ParseSchema parses the DDL statements of sql and applies them to an empty schema.
+// Unlike ParseMultiSql it fails when a statement can't be applied, e.g. ALTER TABLE on an unknown table.
+func ParseSchema(sql string) (*Schema, error) {
+	stmts, err := NewLexer(sql).Parser().ParseStatements()
+	if err != nil {
+		return nil, err
+	}
+	schema := NewSchema()
+	if err := schema.Apply(stmts...); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Table returns the table with the given name (case-insensitive) or nil
+func (s *Schema) Table(name string) *Table {
+	if i := s.tableIndex(name); i != -1 {
+		return s.Tables[i]
+	}
+	return nil
+}
+
+func (s *Schema) tableIndex(name string) int {
+	for i, table := range s.Tables {
+		if strings.EqualFold(table.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Apply applies the statements in order, it stops at the first error
+func (s *Schema) Apply(stmts ...Statement) error {
+	for _, stmt := range stmts {
+		if err := s.apply(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) apply(stmt Statement) error {
+	switch stmt := stmt.(type) {
+	case *CreateTable:
+		if s.Table(stmt.Table.Name) != nil {
+			if stmt.IfNotExists {
+				return nil
+			}
+			return errors.Wrap(ErrTableExists, stmt.Table.Name)
+		}
+		table := stmt.Table.Clone()
+		s.Tables = append(s.Tables, &table)
+
+	case *CreateIndex:
+		table := s.Table(stmt.Table)
+		if table == nil {
+			return errors.Wrap(ErrTableNotFound, stmt.Table)
+		}
+		if table.indexIndex(stmt.Index.Name) != -1 {
+			if stmt.IfNotExists {
+				return nil
+			}
+			return errors.Wrapf(ErrIndexExists, "%s.%s", table.Name, stmt.Index.Name)
+		}
+		index := stmt.Index
+		index.Columns = append([]string(nil), index.Columns...)
+		table.Indexes = append(table.Indexes, &index)
+
+	case *AlterTable:
+		table := s.Table(stmt.Table)
+		if table == nil {
+			return errors.Wrap(ErrTableNotFound, stmt.Table)
+		}
+		for _, action := range stmt.Actions {
+			if action.Type == RenameTable {
+				if s.Table(action.NewName) != nil {
+					return errors.Wrap(ErrTableExists, action.NewName)
+				}
+				table.Name = action.NewName
+				continue
+			}
+			if err := table.alter(action); err != nil {
+				return err
+			}
+		}
+
+	case *DropTable:
+		for _, name := range stmt.Tables {
+			i := s.tableIndex(name)
+			if i == -1 {
+				if stmt.IfExists {
+					continue
+				}
+				return errors.Wrap(ErrTableNotFound, name)
+			}
+			s.Tables = append(s.Tables[:i], s.Tables[i+1:]...)
+		}
+
+	case *DropIndex:
+		for _, table := range s.Tables {
+			if stmt.Table != "" && !strings.EqualFold(table.Name, stmt.Table) {
+				continue
+			}
+			if i := table.indexIndex(stmt.Name); i != -1 {
+				table.Indexes = append(table.Indexes[:i], table.Indexes[i+1:]...)
+				return nil
+			}
+		}
+		if !stmt.IfExists {
+			return errors.Wrap(ErrIndexNotFound, stmt.Name)
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the table, applying statements to a schema never changes them
+func (t Table) Clone() Table {
+	res := t
+	res.Options = append([]string(nil), t.Options...)
+	res.PrimaryKey = append([]string(nil), t.PrimaryKey...)
+	res.Columns = make([]*Column, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		res.Columns = append(res.Columns, column.Clone())
+	}
+	res.Indexes = make([]*Index, 0, len(t.Indexes))
+	for _, index := range t.Indexes {
+		item := *index
+		item.Columns = append([]string(nil), index.Columns...)
+		res.Indexes = append(res.Indexes, &item)
+	}
+	res.ForeignKeys = make([]*ForeignKey, 0, len(t.ForeignKeys))
+	for _, fk := range t.ForeignKeys {
+		item := *fk
+		item.Columns = append([]string(nil), fk.Columns...)
+		item.RefColumns = append([]string(nil), fk.RefColumns...)
+		res.ForeignKeys = append(res.ForeignKeys, &item)
+	}
+	res.Checks = make([]*Check, 0, len(t.Checks))
+	for _, check := range t.Checks {
+		item := *check
+		res.Checks = append(res.Checks, &item)
+	}
+	return res
+}
+
+// Clone returns a copy of the column
+func (c *Column) Clone() *Column {
+	res := *c
+	res.Constraints = append([]string(nil), c.Constraints...)
+	return &res
+}
+
+// Column returns the column with the given name (case-insensitive) or nil
+func (t *Table) Column(name string) *Column {
+	if i := t.columnIndex(name); i != -1 {
+		return t.Columns[i]
+	}
+	return nil
+}
+
+func (t *Table) columnIndex(name string) int {
+	for i, column := range t.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Table) indexIndex(name string) int {
+	for i, index := range t.Indexes {
+		if name != "" && strings.EqualFold(index.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// addColumn appends the column, inline PRIMARY KEY and REFERENCES are added to the table
+func (t *Table) addColumn(column *Column) {
+	t.Columns = append(t.Columns, column)
+	for _, item := range column.Constraints {
+		if strings.EqualFold(item, "PRIMARY KEY") {
+			t.PkName = column.Name
+			t.PrimaryKey = []string{column.Name}
+		}
+	}
+	if fk := inlineForeignKey(column); fk != nil {
+		t.ForeignKeys = append(t.ForeignKeys, fk)
+	}
+}
+
+func (t *Table) addConstraint(c *Constraint) {
+	switch c.Type {
+	case PrimaryKeyConstraint:
+		t.PrimaryKey = c.Columns
+		t.PkName = c.Columns[0]
+	case UniqueConstraint:
+		t.Indexes = append(t.Indexes, &Index{Name: c.Name, Columns: c.Columns, Unique: true, Constraint: true})
+	case IndexConstraint:
+		t.Indexes = append(t.Indexes, &Index{Name: c.Name, Columns: c.Columns})
+	case ForeignKeyConstraint:
+		t.ForeignKeys = append(t.ForeignKeys, c.ForeignKey)
+	case CheckConstraint:
+		t.Checks = append(t.Checks, &Check{Name: c.Name, Expr: c.Check})
+	}
+}
+
+func (t *Table) alter(action *AlterAction) error {
+	if action.Type == AddConstraint {
+		c := *action.Constraint
+		c.Columns = append([]string(nil), c.Columns...)
+		if c.ForeignKey != nil {
+			fk := *c.ForeignKey
+			fk.Columns = append([]string(nil), fk.Columns...)
+			fk.RefColumns = append([]string(nil), fk.RefColumns...)
+			c.ForeignKey = &fk
+		}
+		t.addConstraint(&c)
+		return nil
+	}
+	if action.Type == DropConstraint {
+		return t.dropConstraint(action.Name, action.IfExists)
+	}
+	if action.Type == AddColumn {
+		if t.Column(action.Column.Name) != nil {
+			return errors.Wrapf(ErrColumnExists, "%s.%s", t.Name, action.Column.Name)
+		}
+		t.addColumn(action.Column.Clone())
+		return nil
+	}
+
+	i := t.columnIndex(action.Name)
+	if i == -1 {
+		if action.IfExists {
+			return nil
+		}
+		return errors.Wrapf(ErrColumnNotFound, "%s.%s", t.Name, action.Name)
+	}
+	column := t.Columns[i]
+	switch action.Type {
+	case DropColumn:
+		t.Columns = append(t.Columns[:i], t.Columns[i+1:]...)
+		t.renameColumnRefs(column.Name, "")
+	case ModifyColumn:
+		t.Columns[i] = action.Column.Clone()
+		if action.Column.Name != column.Name {
+			t.renameColumnRefs(column.Name, action.Column.Name)
+		}
+	case RenameColumn:
+		if t.Column(action.NewName) != nil {
+			return errors.Wrapf(ErrColumnExists, "%s.%s", t.Name, action.NewName)
+		}
+		column.Name = action.NewName
+		t.renameColumnRefs(action.Name, action.NewName)
+	case AlterColumnType:
+		column.Type = action.Column.Type
+	case SetColumnDefault:
+		column.Constraints = append(removeConstraint(column.Constraints, "DEFAULT", 1), "DEFAULT", action.Default)
+	case DropColumnDefault:
+		column.Constraints = removeConstraint(column.Constraints, "DEFAULT", 1)
+	case SetColumnNotNull:
+		column.Constraints = append(removeConstraint(column.Constraints, "NOT NULL", 0), "NOT NULL")
+	case DropColumnNotNull:
+		column.Constraints = removeConstraint(column.Constraints, "NOT NULL", 0)
+	}
+	return nil
+}
+
+// dropConstraint drops an index, foreign key or check by name, an empty name drops the primary key
+func (t *Table) dropConstraint(name string, ifExists bool) error {
+	if name == "" {
+		t.PkName, t.PrimaryKey = "", nil
+		for _, column := range t.Columns {
+			column.Constraints = removeConstraint(column.Constraints, "PRIMARY KEY", 0)
+		}
+		return nil
+	}
+	if i := t.indexIndex(name); i != -1 {
+		t.Indexes = append(t.Indexes[:i], t.Indexes[i+1:]...)
+		return nil
+	}
+	for i, fk := range t.ForeignKeys {
+		if strings.EqualFold(fk.Name, name) {
+			t.ForeignKeys = append(t.ForeignKeys[:i], t.ForeignKeys[i+1:]...)
+			return nil
+		}
+	}
+	for i, check := range t.Checks {
+		if strings.EqualFold(check.Name, name) {
+			t.Checks = append(t.Checks[:i], t.Checks[i+1:]...)
+			return nil
+		}
+	}
+	if ifExists {
+		return nil
+	}
+	return errors.Wrapf(ErrIndexNotFound, "%s.%s", t.Name, name)
+}
+
+// renameColumnRefs renames the column in the primary key, indexes and foreign keys,
+// an empty name removes it and drops the indexes and foreign keys left without columns
+func (t *Table) renameColumnRefs(name, newName string) {
+	t.PrimaryKey = renameInList(t.PrimaryKey, name, newName)
+	t.PkName = ""
+	if len(t.PrimaryKey) > 0 {
+		t.PkName = t.PrimaryKey[0]
+	}
+
+	indexes := t.Indexes[:0]
+	for _, index := range t.Indexes {
+		if index.Columns = renameInList(index.Columns, name, newName); len(index.Columns) > 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	t.Indexes = indexes
+
+	fks := t.ForeignKeys[:0]
+	for _, fk := range t.ForeignKeys {
+		if fk.Columns = renameInList(fk.Columns, name, newName); len(fk.Columns) > 0 {
+			fks = append(fks, fk)
+		}
+	}
+	t.ForeignKeys = fks
+}
+
+func renameInList(list []string, name, newName string) []string {
+	res := list[:0:0]
+	for _, item := range list {
+		if !strings.EqualFold(item, name) {
+			res = append(res, item)
+		} else if newName != "" {
+			res = append(res, newName)
+		}
+	}
+	return res
+}
+
+// removeConstraint removes the constraint word and the n items following it
+func removeConstraint(constraints []string, word string, n int) []string {
+	res := make([]string, 0, len(constraints))
+	for i := 0; i < len(constraints); i++ {
+		if strings.EqualFold(constraints[i], word) {
+			i += n
+			continue
+		}
+		res = append(res, constraints[i])
+	}
+	return res
+}
+
+// inlineForeignKey returns the foreign key of "REFERENCES table (column) [ON DELETE ...]" in the column constraints
+func inlineForeignKey(column *Column) *ForeignKey {
+	items := column.Constraints
+	for i, item := range items {
+		if !strings.EqualFold(item, "REFERENCES") || i+1 >= len(items) {
+			continue
+		}
+		fk := &ForeignKey{Columns: []string{column.Name}, RefTable: items[i+1]}
+		j := i + 2
+		if j < len(items) && strings.HasPrefix(items[j], "(") {
+			for _, name := range strings.Split(strings.Trim(items[j], "()"), ",") {
+				fk.RefColumns = append(fk.RefColumns, strings.TrimSpace(name))
+			}
+			j++
+		}
+		for j+2 < len(items) && strings.EqualFold(items[j], "ON") {
+			kind, action := items[j+1], strings.ToUpper(items[j+2])
+			j += 3
+			if (action == "SET" || action == "NO") && j < len(items) {
+				action += " " + strings.ToUpper(items[j])
+				j++
+			}
+			if strings.EqualFold(kind, "DELETE") {
+				fk.OnDelete = action
+			} else {
+				fk.OnUpdate = action
+			}
+		}
+		return fk
+	}
+	return nil
+}
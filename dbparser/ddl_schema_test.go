@@ -0,0 +1,129 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = `
+CREATE TABLE users (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	name VARCHAR(20) NOT NULL,
+	email VARCHAR(100)
+);
+CREATE TABLE orders (
+	id BIGINT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	code VARCHAR(32),
+	CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+	UNIQUE (code)
+);
+CREATE INDEX idx_email ON users (email);
+`
+
+func TestSchemaApply(t *testing.T) {
+	schema, err := ParseSchema(testSchema + `
+		ALTER TABLE users ADD age INT DEFAULT 0, RENAME COLUMN email TO mail, MODIFY name VARCHAR(50) NOT NULL;
+		ALTER TABLE users ALTER COLUMN age SET NOT NULL, ALTER age DROP DEFAULT;
+		ALTER TABLE orders DROP CONSTRAINT fk_user, ADD CHECK (code <> '');
+		ALTER TABLE orders RENAME TO purchases;
+		DROP INDEX idx_mail;
+	`)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrIndexNotFound))
+
+	schema, err = ParseSchema(testSchema + `
+		ALTER TABLE users ADD age INT DEFAULT 0, RENAME COLUMN email TO mail, MODIFY name VARCHAR(50) NOT NULL;
+		ALTER TABLE users ALTER COLUMN age SET NOT NULL, ALTER age DROP DEFAULT;
+		ALTER TABLE orders DROP CONSTRAINT fk_user, ADD CHECK (code <> '');
+		ALTER TABLE orders RENAME TO purchases;
+	`)
+	require.NoError(t, err)
+	require.Len(t, schema.Tables, 2)
+	require.Nil(t, schema.Table("orders"))
+
+	users := schema.Table("USERS")
+	require.NotNil(t, users)
+	require.Equal(t, []string{"id", "name", "mail", "age"}, []string{users.Columns[0].Name, users.Columns[1].Name, users.Columns[2].Name, users.Columns[3].Name})
+	require.Equal(t, "VARCHAR(50)", users.Column("name").Type)
+	require.Equal(t, []string{"NOT NULL"}, users.Column("age").Constraints)
+	require.Equal(t, []*Index{{Name: "idx_email", Columns: []string{"mail"}}}, users.Indexes)
+
+	purchases := schema.Table("purchases")
+	require.Empty(t, purchases.ForeignKeys)
+	require.Equal(t, []*Check{{Expr: "code <> ''"}}, purchases.Checks)
+
+	require.NoError(t, schema.Apply(&AlterTable{Table: "users", Actions: []*AlterAction{{Type: DropColumn, Name: "mail"}}}))
+	require.Empty(t, users.Indexes)
+	require.NoError(t, schema.Apply(&DropTable{Tables: []string{"purchases", "nope"}, IfExists: true}))
+	require.Len(t, schema.Tables, 1)
+}
+
+func TestSchemaApplyError(t *testing.T) {
+	ts := []struct {
+		SQL string
+		Err error
+	}{
+		{SQL: "ALTER TABLE nope ADD a INT", Err: ErrTableNotFound},
+		{SQL: "CREATE TABLE users (id INT)", Err: ErrTableExists},
+		{SQL: "ALTER TABLE users ADD name TEXT", Err: ErrColumnExists},
+		{SQL: "ALTER TABLE users DROP COLUMN nope", Err: ErrColumnNotFound},
+		{SQL: "ALTER TABLE users RENAME COLUMN name TO email", Err: ErrColumnExists},
+		{SQL: "CREATE INDEX idx_email ON users (name)", Err: ErrIndexExists},
+		{SQL: "DROP INDEX nope", Err: ErrIndexNotFound},
+		{SQL: "DROP TABLE nope", Err: ErrTableNotFound},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := ParseSchema(testSchema + tc.SQL)
+			require.True(t, errors.Is(err, tc.Err), err)
+		})
+	}
+
+	for _, sql := range []string{
+		"ALTER TABLE users DROP COLUMN IF EXISTS nope",
+		"CREATE TABLE IF NOT EXISTS users (id INT)",
+		"DROP INDEX IF EXISTS nope",
+		"DROP TABLE IF EXISTS nope",
+	} {
+		_, err := ParseSchema(testSchema + sql)
+		require.NoError(t, err, sql)
+	}
+}
+
+func TestSchemaApplyKeepsStatements(t *testing.T) {
+	stmts := parseStatements(t, testSchema+"ALTER TABLE users RENAME COLUMN email TO mail;")
+	for i := 0; i < 2; i++ {
+		schema := NewSchema()
+		require.NoError(t, schema.Apply(stmts...))
+		require.NotNil(t, schema.Table("users").Column("mail"))
+	}
+	require.Equal(t, "email", stmts[0].(*CreateTable).Table.Columns[2].Name)
+}
+
+func TestParseLenient(t *testing.T) {
+	// Parse keeps ignoring statements which can't be applied
+	tables, err := NewParser(NewLexer("ALTER TABLE nope ADD a INT;" + testSchema + "DROP INDEX nope;").Tokenize()).Parse()
+	require.NoError(t, err)
+	require.Len(t, tables, 2)
+	require.Equal(t, []string{"id"}, tables[1].PrimaryKey)
+	require.Len(t, tables[1].ForeignKeys, 1)
+}
+
+func TestPrinterSchema(t *testing.T) {
+	schema, err := ParseSchema(testSchema + "CREATE TABLE tags (post_id INT, tag VARCHAR(10), CHECK (tag <> ''), PRIMARY KEY (post_id, tag));")
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE orders (\n\tid BIGINT PRIMARY KEY,\n\tuser_id BIGINT NOT NULL,\n\tcode VARCHAR(32),\n\tUNIQUE (code),\n\tCONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE\n);", schema.Table("orders").ToSchema())
+	require.Equal(t, "CREATE TABLE tags (\n\tpost_id INT,\n\ttag VARCHAR(10),\n\tPRIMARY KEY (post_id, tag),\n\tCHECK (tag <> '')\n);", schema.Table("tags").ToSchema())
+	require.Equal(t, "CREATE UNIQUE INDEX idx_tags_tag ON tags (tag);", NewPrinter(MySQL).Index("tags", &Index{Columns: []string{"tag"}, Unique: true}))
+
+	for _, dialect := range []Dialect{Generic, MySQL, PostgreSQL, SQLite} {
+		pr := NewPrinter(dialect)
+		printed := pr.Schema(schema)
+		again, err := ParseSchema(printed)
+		require.NoError(t, err, printed)
+		require.Equal(t, printed, pr.Schema(again))
+	}
+}
@@ -0,0 +1,766 @@
+package dbparser
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnsupportedStatement = errors.New("unsupported statement")
+)
+
+// Statement is a parsed DDL statement: *CreateTable, *CreateIndex, *AlterTable, *DropTable or *DropIndex
+type Statement interface {
+	statementNode()
+	node() *Node
+}
+
+// Node is embedded in the parsed statements, tables, columns, actions and constraints
+type Node struct {
+	// Span is the source of the node, it is empty for a node not built by the parser
+	Span Span `json:"-"`
+}
+
+func (n *Node) node() *Node {
+	return n
+}
+
+// StatementSpan returns the source span of a parsed statement
+func StatementSpan(stmt Statement) Span {
+	return stmt.node().Span
+}
+
+// CreateTable is CREATE TABLE [IF NOT EXISTS] ...
+type CreateTable struct {
+	Node
+	Table       Table
+	IfNotExists bool
+}
+
+// CreateIndex is CREATE [UNIQUE] INDEX [IF NOT EXISTS] name ON table (columns)
+type CreateIndex struct {
+	Node
+	Table       string
+	Index       Index
+	IfNotExists bool
+}
+
+// AlterTable is ALTER TABLE name action [, action...]
+type AlterTable struct {
+	Node
+	Table   string
+	Actions []*AlterAction
+}
+
+// DropTable is DROP TABLE [IF EXISTS] name [, name...]
+type DropTable struct {
+	Node
+	Tables   []string
+	IfExists bool
+}
+
+// DropIndex is DROP INDEX [IF EXISTS] name [ON table]
+type DropIndex struct {
+	Node
+	Name string
+	// Table is only set by the MySQL form "DROP INDEX name ON table"
+	Table    string
+	IfExists bool
+}
+
+func (*CreateTable) statementNode() {}
+func (*CreateIndex) statementNode() {}
+func (*AlterTable) statementNode()  {}
+func (*DropTable) statementNode()   {}
+func (*DropIndex) statementNode()   {}
+
+// Index is an index of a table, table level UNIQUE constraints are unique indexes too
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	// Constraint is true for UNIQUE declared as a constraint of the table instead of CREATE INDEX / KEY
+	Constraint bool `json:"constraint"`
+}
+
+// ForeignKey is FOREIGN KEY (Columns) REFERENCES RefTable (RefColumns)
+type ForeignKey struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	OnDelete   string   `json:"on_delete"` // e.g. CASCADE, SET NULL
+	OnUpdate   string   `json:"on_update"`
+}
+
+// Check is a CHECK constraint, Expr is the SQL between the parens
+type Check struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+// ConstraintType is the type of a table level constraint
+type ConstraintType int
+
+const (
+	// PrimaryKeyConstraint is PRIMARY KEY (columns)
+	PrimaryKeyConstraint ConstraintType = iota
+	// UniqueConstraint is UNIQUE (columns)
+	UniqueConstraint
+	// IndexConstraint is the MySQL KEY / INDEX name (columns)
+	IndexConstraint
+	// ForeignKeyConstraint is FOREIGN KEY (columns) REFERENCES ...
+	ForeignKeyConstraint
+	// CheckConstraint is CHECK (expr)
+	CheckConstraint
+)
+
+// Constraint is a table level constraint of CREATE TABLE or ALTER TABLE ADD
+type Constraint struct {
+	Node
+	Type       ConstraintType
+	Name       string
+	Columns    []string
+	ForeignKey *ForeignKey
+	Check      string
+}
+
+// AlterActionType is the type of an ALTER TABLE action
+type AlterActionType int
+
+const (
+	// AddColumn is ADD [COLUMN] definition
+	AddColumn AlterActionType = iota
+	// DropColumn is DROP [COLUMN] name
+	DropColumn
+	// ModifyColumn is MODIFY [COLUMN] definition or CHANGE [COLUMN] name definition
+	ModifyColumn
+	// RenameColumn is RENAME COLUMN name TO new_name
+	RenameColumn
+	// RenameTable is RENAME [TO] new_name
+	RenameTable
+	// AddConstraint is ADD [CONSTRAINT name] PRIMARY KEY / UNIQUE / INDEX / FOREIGN KEY / CHECK
+	AddConstraint
+	// DropConstraint is DROP CONSTRAINT / INDEX / KEY / FOREIGN KEY name or DROP PRIMARY KEY
+	DropConstraint
+	// AlterColumnType is ALTER [COLUMN] name [SET DATA] TYPE type
+	AlterColumnType
+	// SetColumnDefault is ALTER [COLUMN] name SET DEFAULT value
+	SetColumnDefault
+	// DropColumnDefault is ALTER [COLUMN] name DROP DEFAULT
+	DropColumnDefault
+	// SetColumnNotNull is ALTER [COLUMN] name SET NOT NULL
+	SetColumnNotNull
+	// DropColumnNotNull is ALTER [COLUMN] name DROP NOT NULL
+	DropColumnNotNull
+)
+
+// AlterAction is one action of ALTER TABLE
+type AlterAction struct {
+	Node
+	Type AlterActionType
+	// Name is the column of the action, or the constraint / index for DropConstraint (empty for DROP PRIMARY KEY)
+	Name string
+	// NewName is set for RenameColumn and RenameTable
+	NewName string
+	// Column is the new definition for AddColumn and ModifyColumn, only Type is set for AlterColumnType
+	Column *Column
+	// Constraint is set for AddConstraint
+	Constraint *Constraint
+	// Default is the value of SetColumnDefault
+	Default  string
+	IfExists bool
+}
+
+// ParseStatements parses CREATE TABLE, CREATE INDEX, ALTER TABLE, DROP TABLE and DROP INDEX statements.
+// Other statements (INSERT, CREATE VIEW...) are skipped.
+func (p *Parser) ParseStatements() ([]Statement, error) {
+	stmts := []Statement{}
+	errs := ErrorList{}
+	for !p.expect(EOF) {
+		if p.expect(SEMI) {
+			p.next()
+			continue
+		}
+		start := p.pos
+		stmt, err := p.parseStatement()
+		if errors.Is(err, ErrUnsupportedStatement) || errors.Is(err, ErrNotCreateTable) {
+			err = nil
+		} else if err == nil && !p.accept(SEMI, EOF) {
+			err = p.error("expected ;")
+		}
+		if err != nil {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				syntaxErr = p.lines.errorAt(p.current().Span.Start, err.Error())
+				syntaxErr.Statement = p.statement
+			}
+			errs = append(errs, syntaxErr)
+		} else if stmt != nil {
+			stmt.node().Span = p.spanFrom(start)
+			if create, ok := stmt.(*CreateTable); ok {
+				create.Table.Span = create.Span
+			}
+			stmts = append(stmts, stmt)
+		}
+		// Skip the rest of the statement, a bad one is reported and parsing goes on with the next
+		for !p.accept(SEMI, EOF) {
+			p.next()
+		}
+		if p.expect(SEMI) {
+			p.next()
+		}
+		p.statement++
+	}
+	if len(errs) > 0 {
+		return stmts, errors.Wrap(errs, "解析失败")
+	}
+	return stmts, nil
+}
+
+func (p *Parser) parseStatement() (Statement, error) {
+	next := p.peekToken(1)
+	switch {
+	case p.expect(CREATE) && next.Type == TABLE:
+		return p.parseCreateTable()
+	case p.expect(CREATE) && (isWord(next, "INDEX") || isWord(next, "UNIQUE")):
+		return p.parseCreateIndex()
+	case p.isWord("ALTER") && next.Type == TABLE:
+		return p.parseAlterTable()
+	case p.isWord("DROP") && next.Type == TABLE:
+		return p.parseDropTable()
+	case p.isWord("DROP") && isWord(next, "INDEX"):
+		return p.parseDropIndex()
+	}
+	return nil, errors.WithStack(ErrUnsupportedStatement)
+}
+
+func (p *Parser) parseCreateIndex() (*CreateIndex, error) {
+	stmt := &CreateIndex{}
+	p.next() // CREATE
+	stmt.Index.Unique = p.acceptWord("UNIQUE")
+	if !p.acceptWord("INDEX") {
+		return nil, p.error("expected INDEX")
+	}
+	ifNotExists, err := p.parseIfNotExists()
+	if err != nil {
+		return nil, err
+	}
+	stmt.IfNotExists = ifNotExists
+	// PostgreSQL allows omitting the name of the index
+	if !p.isWord("ON") {
+		if stmt.Index.Name, err = p.parseName("expected index name"); err != nil {
+			return nil, err
+		}
+	}
+	if !p.acceptWord("ON") {
+		return nil, p.error("expected ON")
+	}
+	if stmt.Table, err = p.parseName("expected table name"); err != nil {
+		return nil, err
+	}
+	if p.acceptWord("USING") {
+		p.next() // btree, hash...
+	}
+	if stmt.Index.Columns, err = p.parseNameList(); err != nil {
+		return nil, err
+	}
+	// Skip the rest, e.g. the WHERE of a partial index
+	for !p.accept(SEMI, EOF) {
+		p.next()
+	}
+	return stmt, nil
+}
+
+func (p *Parser) parseDropTable() (*DropTable, error) {
+	stmt := &DropTable{}
+	p.next() // DROP
+	p.next() // TABLE
+	stmt.IfExists = p.parseIfExists()
+	for {
+		name, err := p.parseName("expected table name")
+		if err != nil {
+			return nil, err
+		}
+		stmt.Tables = append(stmt.Tables, name)
+		if !p.expect(COMMA) {
+			break
+		}
+		p.next()
+	}
+	// Skip CASCADE / RESTRICT
+	for p.isWord("CASCADE") || p.isWord("RESTRICT") {
+		p.next()
+	}
+	return stmt, nil
+}
+
+func (p *Parser) parseDropIndex() (*DropIndex, error) {
+	stmt := &DropIndex{}
+	p.next() // DROP
+	p.next() // INDEX
+	stmt.IfExists = p.parseIfExists()
+	var err error
+	if stmt.Name, err = p.parseName("expected index name"); err != nil {
+		return nil, err
+	}
+	if p.acceptWord("ON") {
+		if stmt.Table, err = p.parseName("expected table name"); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *Parser) parseAlterTable() (*AlterTable, error) {
+	stmt := &AlterTable{}
+	p.next() // ALTER
+	p.next() // TABLE
+	p.parseIfExists()
+	p.acceptWord("ONLY")
+	var err error
+	if stmt.Table, err = p.parseName("expected table name"); err != nil {
+		return nil, err
+	}
+	for {
+		start := p.pos
+		action, err := p.parseAlterAction()
+		if err != nil {
+			return nil, err
+		}
+		action.Span = p.spanFrom(start)
+		stmt.Actions = append(stmt.Actions, action)
+		if !p.expect(COMMA) {
+			break
+		}
+		p.next()
+	}
+	return stmt, nil
+}
+
+func (p *Parser) parseAlterAction() (*AlterAction, error) {
+	action := &AlterAction{}
+	var err error
+	switch {
+	case p.acceptWord("ADD"):
+		if p.isTableConstraint() {
+			action.Type = AddConstraint
+			if action.Constraint, err = p.parseConstraint(); err != nil {
+				return nil, err
+			}
+			action.Name = action.Constraint.Name
+			return action, nil
+		}
+		action.Type = AddColumn
+		p.acceptWord("COLUMN")
+		if _, err = p.parseIfNotExists(); err != nil {
+			return nil, err
+		}
+		if action.Column, err = p.parseColumn(); err != nil {
+			return nil, err
+		}
+		action.Name = action.Column.Name
+		return action, nil
+
+	case p.acceptWord("DROP"):
+		return p.parseDropAction()
+
+	case p.acceptWord("MODIFY"):
+		action.Type = ModifyColumn
+		p.acceptWord("COLUMN")
+		if action.Column, err = p.parseColumn(); err != nil {
+			return nil, err
+		}
+		action.Name = action.Column.Name
+		return action, nil
+
+	case p.acceptWord("CHANGE"):
+		action.Type = ModifyColumn
+		p.acceptWord("COLUMN")
+		if action.Name, err = p.parseName("expected column name"); err != nil {
+			return nil, err
+		}
+		action.Column, err = p.parseColumn()
+		return action, err
+
+	case p.acceptWord("ALTER"):
+		p.acceptWord("COLUMN")
+		if action.Name, err = p.parseName("expected column name"); err != nil {
+			return nil, err
+		}
+		return p.parseAlterColumn(action)
+
+	case p.acceptWord("RENAME"):
+		if p.acceptWord("COLUMN") {
+			action.Type = RenameColumn
+			if action.Name, err = p.parseName("expected column name"); err != nil {
+				return nil, err
+			}
+			if !p.acceptWord("TO") {
+				return nil, p.error("expected TO")
+			}
+		} else {
+			action.Type = RenameTable
+			if !p.acceptWord("TO") {
+				p.acceptWord("AS")
+			}
+		}
+		action.NewName, err = p.parseName("expected new name")
+		return action, err
+	}
+	return nil, p.error("expected ADD, DROP, MODIFY, CHANGE, ALTER or RENAME")
+}
+
+func (p *Parser) parseDropAction() (*AlterAction, error) {
+	action := &AlterAction{Type: DropConstraint}
+	var err error
+	switch {
+	case p.acceptWord("PRIMARY KEY"):
+		return action, nil
+	case p.acceptWord("CONSTRAINT"), p.acceptWord("INDEX"), p.acceptWord("KEY"), p.acceptWord("CHECK"):
+	case p.acceptWord("FOREIGN"):
+		if !p.acceptWord("KEY") {
+			return nil, p.error("expected KEY")
+		}
+	default:
+		action.Type = DropColumn
+		p.acceptWord("COLUMN")
+	}
+	action.IfExists = p.parseIfExists()
+	if action.Name, err = p.parseName("expected name"); err != nil {
+		return nil, err
+	}
+	// Skip CASCADE / RESTRICT
+	for p.isWord("CASCADE") || p.isWord("RESTRICT") {
+		p.next()
+	}
+	return action, nil
+}
+
+// parseAlterColumn parses the PostgreSQL ALTER COLUMN forms after the column name
+func (p *Parser) parseAlterColumn(action *AlterAction) (*AlterAction, error) {
+	switch {
+	case p.isWord("SET") && isWord(p.peekToken(1), "DATA"):
+		p.next()
+		p.next()
+		if !p.isWord("TYPE") {
+			return nil, p.error("expected TYPE")
+		}
+		fallthrough
+	case p.isWord("TYPE"):
+		p.next()
+		if !p.expect(IDENT) {
+			return nil, p.error("expected column type")
+		}
+		action.Type = AlterColumnType
+		action.Column = &Column{Name: action.Name, Type: p.current().Value}
+		p.next()
+		if p.expect(LPAREN) {
+			params, err := p.readParens()
+			if err != nil {
+				return nil, err
+			}
+			action.Column.Type += params
+		}
+		// Skip USING expr
+		for !p.accept(COMMA, SEMI, EOF) {
+			p.next()
+		}
+	case p.acceptWord("SET"):
+		switch {
+		case p.acceptWord("NOT NULL"):
+			action.Type = SetColumnNotNull
+		case p.acceptWord("DEFAULT"):
+			action.Type = SetColumnDefault
+			def, err := p.readUntilComma()
+			if err != nil {
+				return nil, err
+			}
+			action.Default = def
+		default:
+			return nil, p.error("expected NOT NULL or DEFAULT")
+		}
+	case p.acceptWord("DROP"):
+		switch {
+		case p.acceptWord("NOT NULL"):
+			action.Type = DropColumnNotNull
+		case p.acceptWord("DEFAULT"):
+			action.Type = DropColumnDefault
+		default:
+			return nil, p.error("expected NOT NULL or DEFAULT")
+		}
+	default:
+		return nil, p.error("expected TYPE, SET or DROP")
+	}
+	return action, nil
+}
+
+// isTableConstraint reports whether the current token starts a table level constraint
+func (p *Parser) isTableConstraint() bool {
+	switch {
+	case p.isWord("CONSTRAINT"), p.isWord("PRIMARY KEY"), p.isWord("UNIQUE"), p.isWord("CHECK"):
+		return true
+	case p.isWord("FOREIGN"):
+		return isWord(p.peekToken(1), "KEY")
+	case p.isWord("KEY"), p.isWord("INDEX"):
+		// KEY name (columns) or KEY (columns), a column may be named key as well
+		next := p.peekToken(1)
+		return next.Type == LPAREN || next.Type == IDENT && p.peekToken(2).Type == LPAREN
+	}
+	return false
+}
+
+// parseConstraint parses a table level constraint, e.g. CONSTRAINT fk FOREIGN KEY (a) REFERENCES t (id)
+func (p *Parser) parseConstraint() (c *Constraint, err error) {
+	c = &Constraint{}
+	start := p.pos
+	defer func() {
+		if err == nil {
+			c.Span = p.spanFrom(start)
+		}
+	}()
+	if p.acceptWord("CONSTRAINT") {
+		if c.Name, err = p.parseName("expected constraint name"); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case p.acceptWord("PRIMARY KEY"):
+		c.Type = PrimaryKeyConstraint
+	case p.acceptWord("UNIQUE"):
+		c.Type = UniqueConstraint
+		if !p.acceptWord("KEY") {
+			p.acceptWord("INDEX")
+		}
+		c.Name = p.parseOptionalName(c.Name)
+	case p.acceptWord("KEY"), p.acceptWord("INDEX"):
+		c.Type = IndexConstraint
+		c.Name = p.parseOptionalName(c.Name)
+	case p.acceptWord("FOREIGN"):
+		if !p.acceptWord("KEY") {
+			return nil, p.error("expected KEY")
+		}
+		c.Type = ForeignKeyConstraint
+		c.Name = p.parseOptionalName(c.Name)
+	case p.acceptWord("CHECK"):
+		if !p.expect(LPAREN) {
+			return nil, p.error("expected (")
+		}
+		c.Type = CheckConstraint
+		check, err := p.readParens()
+		if err != nil {
+			return nil, err
+		}
+		c.Check = check[1 : len(check)-1]
+		return c, nil
+	default:
+		return nil, p.error("expected PRIMARY KEY, UNIQUE, FOREIGN KEY or CHECK")
+	}
+
+	if c.Columns, err = p.parseNameList(); err != nil {
+		return nil, err
+	}
+	if c.Type != ForeignKeyConstraint {
+		return c, nil
+	}
+
+	fk := &ForeignKey{Name: c.Name, Columns: c.Columns}
+	if !p.acceptWord("REFERENCES") {
+		return nil, p.error("expected REFERENCES")
+	}
+	if fk.RefTable, err = p.parseName("expected table name"); err != nil {
+		return nil, err
+	}
+	if p.expect(LPAREN) {
+		if fk.RefColumns, err = p.parseNameList(); err != nil {
+			return nil, err
+		}
+	}
+	for p.acceptWord("ON") {
+		switch {
+		case p.acceptWord("DELETE"):
+			fk.OnDelete = p.readReferentialAction()
+		case p.acceptWord("UPDATE"):
+			fk.OnUpdate = p.readReferentialAction()
+		default:
+			return nil, p.error("expected DELETE or UPDATE")
+		}
+	}
+	c.ForeignKey = fk
+	return c, nil
+}
+
+// readReferentialAction reads CASCADE, RESTRICT, SET NULL, SET DEFAULT or NO ACTION
+func (p *Parser) readReferentialAction() string {
+	if !p.expect(IDENT) {
+		return ""
+	}
+	action := strings.ToUpper(p.current().Value)
+	p.next()
+	if (action == "SET" || action == "NO") && p.accept(IDENT) {
+		action += " " + strings.ToUpper(p.current().Value)
+		p.next()
+	}
+	return action
+}
+
+// parseIfNotExists accepts an optional IF NOT EXISTS
+func (p *Parser) parseIfNotExists() (bool, error) {
+	if !p.accept(IF) { // Accept an if keyword token
+		return false, nil
+	}
+	p.next()            // Advance to next token
+	if !p.expect(NOT) { // Expect a not keyword token
+		return false, p.error("expected NOT")
+	}
+	p.next()               // Advance to next token
+	if !p.expect(EXISTS) { // Expect an exists keyword token
+		return false, p.error("expected EXISTS")
+	}
+	p.next() // Advance to next token
+	return true, nil
+}
+
+// parseIfExists accepts an optional IF EXISTS
+func (p *Parser) parseIfExists() bool {
+	if p.expect(IF) && p.peekToken(1).Type == EXISTS {
+		p.next()
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseName(msg string) (string, error) {
+	if !p.expect(IDENT) {
+		return "", p.error(msg)
+	}
+	name := p.current().Value
+	p.next()
+	return name, nil
+}
+
+// parseOptionalName reads the name in "KEY name (columns)", it returns name when there is none
+func (p *Parser) parseOptionalName(name string) string {
+	if p.expect(IDENT) {
+		name = p.current().Value
+		p.next()
+	}
+	return name
+}
+
+// parseNameList parses "(a, b(10) DESC, ...)" and returns the names
+func (p *Parser) parseNameList() ([]string, error) {
+	if !p.expect(LPAREN) {
+		return nil, p.error("expected (")
+	}
+	p.next()
+	names := []string{}
+	for {
+		if !p.expect(IDENT) {
+			return nil, p.error("expected column name")
+		}
+		names = append(names, p.current().Value)
+		p.next()
+		// Skip prefix lengths, ASC / DESC... up to the end of the input
+		for depth := 0; !p.expect(EOF) && (depth > 0 || !p.accept(COMMA, RPAREN, SEMI)); p.next() {
+			if p.expect(LPAREN) {
+				depth++
+			} else if p.expect(RPAREN) {
+				depth--
+			}
+		}
+		if p.expect(RPAREN) {
+			p.next()
+			return names, nil
+		}
+		if !p.expect(COMMA) {
+			return nil, p.error("expected )")
+		}
+		p.next()
+	}
+}
+
+// readParens reads the tokens from the current ( to the matching ) and returns them as SQL,
+// the input ending before it is an error
+func (p *Parser) readParens() (string, error) {
+	tokens := []Token{}
+	for depth := 0; ; {
+		if p.expect(EOF) {
+			return "", p.error("expected )")
+		}
+		if p.expect(LPAREN) {
+			depth++
+		} else if p.expect(RPAREN) {
+			depth--
+		}
+		tokens = append(tokens, p.current())
+		p.next()
+		if depth == 0 {
+			return joinTokens(tokens), nil
+		}
+	}
+}
+
+// readUntilComma reads the tokens until the end of the ALTER action and returns them as SQL
+func (p *Parser) readUntilComma() (string, error) {
+	tokens := []Token{}
+	for !p.accept(COMMA, SEMI, EOF) {
+		if p.expect(LPAREN) {
+			params, err := p.readParens()
+			if err != nil {
+				return "", err
+			}
+			tokens = append(tokens, Token{Type: IDENT, Value: params})
+			continue
+		}
+		tokens = append(tokens, p.current())
+		p.next()
+	}
+	return joinTokens(tokens), nil
+}
+
+// joinTokens formats tokens back to SQL, strings are quoted again
+func joinTokens(tokens []Token) string {
+	var sb strings.Builder
+	prev := ""
+	for _, tok := range tokens {
+		value := tok.Value
+		if tok.Type == STRING {
+			value = "'" + value + "'"
+		}
+		if sb.Len() > 0 && prev != "(" && value != ")" && value != "," {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(value)
+		prev = value
+	}
+	return sb.String()
+}
+
+// peekToken returns the token n positions after the current one
+func (p *Parser) peekToken(n int) Token {
+	if p.pos+n >= len(p.tokens) {
+		return Token{Type: EOF}
+	}
+	return p.tokens[p.pos+n]
+}
+
+// isWord returns true if the current token is the given keyword, case-insensitively
+func (p *Parser) isWord(word string) bool {
+	return isWord(p.current(), word)
+}
+
+// acceptWord advances and returns true if the current token is the given keyword
+func (p *Parser) acceptWord(word string) bool {
+	if !p.isWord(word) {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func isWord(tok Token, word string) bool {
+	return tok.Type != STRING && tok.Type != EOF && strings.EqualFold(tok.Value, word)
+}
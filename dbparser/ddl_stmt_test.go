@@ -0,0 +1,189 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parseStatements(t *testing.T, sql string) []Statement {
+	stmts, err := NewParser(NewLexer(sql).Tokenize()).ParseStatements()
+	require.NoError(t, err, sql)
+	for _, stmt := range stmts {
+		clearSpans(stmt)
+	}
+	return stmts
+}
+
+// clearSpans clears the source spans of a statement so that it can be compared with a literal
+func clearSpans(stmt Statement) {
+	stmt.node().Span = Span{}
+	switch stmt := stmt.(type) {
+	case *CreateTable:
+		stmt.Table.Span = Span{}
+		for _, column := range stmt.Table.Columns {
+			column.Span = Span{}
+		}
+	case *AlterTable:
+		for _, action := range stmt.Actions {
+			action.Span = Span{}
+			if action.Column != nil {
+				action.Column.Span = Span{}
+			}
+			if action.Constraint != nil {
+				action.Constraint.Span = Span{}
+			}
+		}
+	}
+}
+
+func TestParseStatements(t *testing.T) {
+	stmts := parseStatements(t, `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_email ON users (email, tenant_id DESC);
+		CREATE INDEX ON users USING btree (name);
+		INSERT INTO users (id) VALUES (1);
+		DROP TABLE IF EXISTS a, b;
+		DROP INDEX idx_email ON users;
+		DROP INDEX IF EXISTS idx_name
+	`)
+	require.Equal(t, []Statement{
+		&CreateIndex{Table: "users", Index: Index{Name: "idx_email", Columns: []string{"email", "tenant_id"}, Unique: true}, IfNotExists: true},
+		&CreateIndex{Table: "users", Index: Index{Columns: []string{"name"}}},
+		&DropTable{Tables: []string{"a", "b"}, IfExists: true},
+		&DropIndex{Name: "idx_email", Table: "users"},
+		&DropIndex{Name: "idx_name", IfExists: true},
+	}, stmts)
+}
+
+func TestParseAlterTable(t *testing.T) {
+	ts := []struct {
+		SQL      string
+		Expected []*AlterAction
+	}{
+		{
+			SQL: "ALTER TABLE users ADD COLUMN age INT NOT NULL DEFAULT 0, ADD email VARCHAR(100)",
+			Expected: []*AlterAction{
+				{Type: AddColumn, Name: "age", Column: &Column{Name: "age", Type: "INT", Constraints: []string{"NOT NULL", "DEFAULT", "0"}}},
+				{Type: AddColumn, Name: "email", Column: &Column{Name: "email", Type: "VARCHAR(100)", Constraints: nil}},
+			},
+		},
+		{
+			SQL: "ALTER TABLE users DROP COLUMN IF EXISTS age, DROP email, DROP PRIMARY KEY, DROP INDEX idx_email, DROP FOREIGN KEY fk_user",
+			Expected: []*AlterAction{
+				{Type: DropColumn, Name: "age", IfExists: true},
+				{Type: DropColumn, Name: "email"},
+				{Type: DropConstraint},
+				{Type: DropConstraint, Name: "idx_email"},
+				{Type: DropConstraint, Name: "fk_user"},
+			},
+		},
+		{
+			SQL: "ALTER TABLE users MODIFY name VARCHAR(50) NOT NULL, CHANGE COLUMN phone mobile VARCHAR(11)",
+			Expected: []*AlterAction{
+				{Type: ModifyColumn, Name: "name", Column: &Column{Name: "name", Type: "VARCHAR(50)", Constraints: []string{"NOT NULL"}}},
+				{Type: ModifyColumn, Name: "phone", Column: &Column{Name: "mobile", Type: "VARCHAR(11)", Constraints: nil}},
+			},
+		},
+		{
+			SQL: "ALTER TABLE users RENAME COLUMN name TO full_name, RENAME TO members",
+			Expected: []*AlterAction{
+				{Type: RenameColumn, Name: "name", NewName: "full_name"},
+				{Type: RenameTable, NewName: "members"},
+			},
+		},
+		{
+			SQL: "ALTER TABLE ONLY users ALTER COLUMN age SET DATA TYPE BIGINT USING age::bigint, ALTER name TYPE TEXT, ALTER age SET DEFAULT 1, ALTER age DROP DEFAULT, ALTER age SET NOT NULL, ALTER age DROP NOT NULL",
+			Expected: []*AlterAction{
+				{Type: AlterColumnType, Name: "age", Column: &Column{Name: "age", Type: "BIGINT"}},
+				{Type: AlterColumnType, Name: "name", Column: &Column{Name: "name", Type: "TEXT"}},
+				{Type: SetColumnDefault, Name: "age", Default: "1"},
+				{Type: DropColumnDefault, Name: "age"},
+				{Type: SetColumnNotNull, Name: "age"},
+				{Type: DropColumnNotNull, Name: "age"},
+			},
+		},
+		{
+			SQL: "ALTER TABLE orders ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL, ADD UNIQUE (code), ADD CHECK (total > 0)",
+			Expected: []*AlterAction{
+				{Type: AddConstraint, Name: "fk_user", Constraint: &Constraint{Type: ForeignKeyConstraint, Name: "fk_user", Columns: []string{"user_id"}, ForeignKey: &ForeignKey{
+					Name: "fk_user", Columns: []string{"user_id"}, RefTable: "users", RefColumns: []string{"id"}, OnDelete: "SET NULL",
+				}}},
+				{Type: AddConstraint, Constraint: &Constraint{Type: UniqueConstraint, Columns: []string{"code"}}},
+				{Type: AddConstraint, Constraint: &Constraint{Type: CheckConstraint, Check: "total > 0"}},
+			},
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			stmts := parseStatements(t, tc.SQL)
+			require.Len(t, stmts, 1)
+			alter, ok := stmts[0].(*AlterTable)
+			require.True(t, ok)
+			require.Equal(t, tc.Expected, alter.Actions)
+		})
+	}
+}
+
+func TestParseTableConstraints(t *testing.T) {
+	stmts := parseStatements(t, `
+		CREATE TABLE device_extra (
+			id BIGINT NOT NULL AUTO_INCREMENT,
+			device_id BIGINT NOT NULL,
+			tenant_id BIGINT NOT NULL,
+			serial VARCHAR(64),
+			owner_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+			PRIMARY KEY (id, tenant_id),
+			UNIQUE KEY uk_serial (serial),
+			KEY idx_device (device_id),
+			CONSTRAINT fk_device FOREIGN KEY (device_id, tenant_id) REFERENCES device (id, tenant_id) ON UPDATE NO ACTION,
+			CHECK (tenant_id > 0)
+		) ENGINE=InnoDB;
+	`)
+	require.Len(t, stmts, 1)
+	table := stmts[0].(*CreateTable).Table
+	require.Len(t, table.Columns, 5)
+	require.Equal(t, []string{"id", "tenant_id"}, table.PrimaryKey)
+	require.Equal(t, "id", table.PkName)
+	require.Equal(t, []*Index{
+		{Name: "uk_serial", Columns: []string{"serial"}, Unique: true, Constraint: true},
+		{Name: "idx_device", Columns: []string{"device_id"}},
+	}, table.Indexes)
+	require.Equal(t, []*ForeignKey{
+		{Columns: []string{"owner_id"}, RefTable: "users", RefColumns: []string{"id"}, OnDelete: "CASCADE"},
+		{Name: "fk_device", Columns: []string{"device_id", "tenant_id"}, RefTable: "device", RefColumns: []string{"id", "tenant_id"}, OnUpdate: "NO ACTION"},
+	}, table.ForeignKeys)
+	require.Equal(t, []*Check{{Expr: "tenant_id > 0"}}, table.Checks)
+}
+
+func TestParseStatementsError(t *testing.T) {
+	for _, sql := range []string{
+		"ALTER TABLE users",
+		"ALTER TABLE users RENAME COLUMN a b",
+		"CREATE INDEX idx ON users",
+		"DROP TABLE a b",
+	} {
+		_, err := NewParser(NewLexer(sql).Tokenize()).ParseStatements()
+		require.Error(t, err, sql)
+	}
+
+	// the input ending inside parentheses is an error at its end
+	for _, sql := range []string{
+		"CREATE TABLE t (a INT, CHECK (",
+		"CREATE INDEX ON A(A(",
+		"CREATE UNIQUE INDEX i ON t (a DESC",
+		"CREATE TABLE t (a CHAR(",
+		"CREATE TABLE t (a INT REFERENCES u(",
+		"CREATE TABLE t (a INT, PRIMARY KEY (a(10",
+		"ALTER TABLE t ALTER COLUMN a TYPE CHAR(",
+		"ALTER TABLE t ALTER COLUMN a SET DEFAULT (1",
+	} {
+		_, err := NewParser(NewLexer(sql).Tokenize()).ParseStatements()
+		var syntaxErr *SyntaxError
+		require.ErrorAs(t, err, &syntaxErr, sql)
+		require.Equal(t, len(sql)+1, syntaxErr.Pos.Column, sql)
+		require.Contains(t, syntaxErr.Msg, "got end of input", sql)
+		_, err = ParseSchema(sql)
+		require.Error(t, err, sql)
+		require.NotEmpty(t, NewLinter(LintConfig{}).Lint(sql), sql)
+	}
+}
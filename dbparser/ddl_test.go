@@ -68,6 +68,15 @@ func TestParserCreateSchema(t *testing.T) {
 	if len(res[0].Columns) != 2 {
 		t.Error()
 	}
+
+	// DECIMAL as the last column, with spaces and without the scale
+	res, err = NewParser(NewLexer("CREATE TABLE t (a DECIMAL( 10 , 2 ), b decimal(8), c DECIMAL(10,2))").Tokenize()).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res[0].Columns) != 3 || res[0].Columns[0].Type != "DECIMAL(10,2)" || res[0].Columns[1].Type != "DECIMAL(8)" || res[0].Columns[2].Type != "DECIMAL(10,2)" {
+		t.Errorf("decimal columns error: %v", res[0].Columns)
+	}
 }
 
 func TestGetTableFromSQL(t *testing.T) {
@@ -10,13 +10,48 @@ import (
 
 // Query represents a parsed query
 type Query struct {
-	Type       Type
-	TableName  string
+	Type      Type
+	TableName string
+	// Conditions is the flat form of Where, kept for compatibility.
+	// It is only filled when Where is a chain of simple comparisons joined by AND.
 	Conditions []Condition
-	Updates    map[string]string
-	Inserts    [][]string
-	Fields     []string // Used for SELECT (i.e. SELECTed field names) and INSERT (INSERTEDed field names)
-	Aliases    map[string]string
+	// Where is the expression tree of the WHERE clause, nil when there is no WHERE
+	Where Expr
+	// Updates and Inserts are the values of UPDATE SET and INSERT VALUES, kept for compatibility:
+	// the content of string literals, the value of other literals and the SQL of expressions.
+	// Use Assignments and Values for the expressions and the order of the assignments.
+	Updates map[string]string
+	Inserts [][]string
+	Fields  []string // Used for SELECT (i.e. SELECTed field names) and INSERT (INSERTEDed field names)
+	Aliases map[string]string
+
+	// Distinct is true for SELECT DISTINCT
+	Distinct bool
+	// Columns are the SELECTed expressions, Fields holds their String form
+	Columns []SelectColumn
+	// TableAlias is the alias of the FROM table, e.g. "u" in "FROM users u"
+	TableAlias string
+	// Subquery is set for SELECT ... FROM (SELECT ...), TableName is empty then
+	Subquery *Query
+	Joins    []Join
+	GroupBy  []Expr
+	Having   Expr
+	OrderBy  []OrderBy
+	Limit    Expr
+	Offset   Expr
+
+	// Assignments are the SET of UPDATE in order
+	Assignments []Assignment
+	// Values are the rows of INSERT ... VALUES
+	Values [][]Expr
+	// Source is the SELECT of INSERT ... SELECT
+	Source *Query
+	// OnConflict is ON CONFLICT / ON DUPLICATE KEY UPDATE of INSERT
+	OnConflict *OnConflict
+	// Returning are the expressions of RETURNING of INSERT, UPDATE and DELETE
+	Returning []SelectColumn
+	// From are the tables of UPDATE ... FROM and DELETE ... USING
+	From []TableRef
 }
 
 // Type is the type of SQL query, e.g. SELECT/UPDATE
@@ -62,6 +97,10 @@ const (
 	Gte
 	// Lte -> "<="
 	Lte
+	// Like -> "LIKE"
+	Like
+	// ILike -> "ILIKE"
+	ILike
 )
 
 // OperatorString is a string slice with the names of all operators in order
@@ -73,6 +112,16 @@ var OperatorString = []string{
 	"Lt",
 	"Gte",
 	"Lte",
+	"Like",
+	"ILike",
+}
+
+// enumName returns names[i], or e.g. "Dialect(9)" for a value out of the range of names
+func enumName(names []string, i int, typ string) string {
+	if i < 0 || i >= len(names) {
+		return fmt.Sprintf("%s(%d)", typ, i)
+	}
+	return names[i]
 }
 
 // Condition is a single boolean condition in a WHERE clause
@@ -90,30 +139,42 @@ type Condition struct {
 }
 
 // Parse takes a string representing a SQL query and parses it into a Query struct. It may fail.
+// The error is a *SyntaxError with the position of the failure.
 func Parse(sqls string) (Query, error) {
-	qs, err := ParseMany([]string{sqls})
-	if len(qs) == 0 {
+	q, err := parse(sqls)
+	if err != nil {
 		return Query{}, err
 	}
-	return qs[0], err
+	return q, nil
 }
 
 // ParseMany takes a string slice representing many SQL queries and parses them into a Query struct slice.
-// It may fail. If it fails, it will stop at the first failure.
+// A failing query doesn't stop the others: qs[i] is the query of sqls[i], the zero Query when it failed,
+// and the failures are returned in an ErrorList, their Statement is the index of the query in sqls.
 func ParseMany(sqls []string) ([]Query, error) {
-	qs := []Query{}
-	for _, sql := range sqls {
+	qs := make([]Query, len(sqls))
+	errs := ErrorList{}
+	for i, sql := range sqls {
 		q, err := parse(sql)
 		if err != nil {
-			return qs, err
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				// an error without position is reported at the start of the query
+				syntaxErr = &SyntaxError{Pos: Pos{Line: 1, Column: 1}, Msg: err.Error()}
+			}
+			syntaxErr.Statement = i
+			errs = append(errs, syntaxErr)
+			continue
 		}
-		qs = append(qs, q)
+		qs[i] = q
 	}
-	return qs, nil
+	return qs, errs.err()
 }
 
 func parse(sql string) (Query, error) {
-	return (&parser{0, strings.TrimSpace(sql), stepType, Query{}, nil, ""}).parse()
+	offset := len(sql) - len(strings.TrimLeft(sql, " \t\r\n"))
+	p := &parser{sql: strings.TrimSpace(sql), step: stepType, lines: newLineIndex(sql), offset: offset}
+	return p.parse()
 }
 
 type step int
@@ -124,6 +185,7 @@ const (
 	stepSelectFrom
 	stepSelectComma
 	stepSelectFromTable
+	stepSelectClauses
 	stepInsertTable
 	stepInsertFieldsOpeningParens
 	stepInsertFields
@@ -133,39 +195,58 @@ const (
 	stepInsertValues
 	stepInsertValuesCommaOrClosingParens
 	stepInsertValuesCommaBeforeOpeningParens
+	stepInsertEnd
 	stepUpdateTable
 	stepUpdateSet
 	stepUpdateField
-	stepUpdateEquals
-	stepUpdateValue
-	stepUpdateComma
+	stepUpdateEnd
 	stepDeleteFromTable
+	stepDeleteUsing
 	stepWhere
-	stepWhereField
-	stepWhereOperator
-	stepWhereValue
-	stepWhereAnd
+	stepWhereExpr
+	stepWhereEnd
+	stepReturning
 )
 
 type parser struct {
-	i               int
-	sql             string
-	step            step
-	query           Query
-	err             error
-	nextUpdateField string
+	i     int
+	sql   string
+	step  step
+	query Query
+	err   error
+	// clause is the clause being parsed, used in error messages, e.g. "WHERE"
+	clause string
+	// clauseRank makes sure that SELECT clauses appear in order
+	clauseRank int
+	// placeholders counts the placeholders read so far, subqueries included
+	placeholders int
+	// lines is the whole source, sql starts at offset in it
+	lines  *lineIndex
+	offset int
+	// lint accepts UPDATE / DELETE without WHERE and doesn't print errors, the linter reports them
+	lint bool
 }
 
 func (p *parser) parse() (Query, error) {
 	q, err := p.doParse()
-	p.err = err
-	if p.err == nil {
-		p.err = p.validate()
+	if err == nil {
+		err = p.validate()
+	}
+	if err != nil {
+		p.err = p.syntaxError(err)
 	}
 	p.logError()
 	return q, p.err
 }
 
+// syntaxError returns err at the current position, errors of subqueries have their position already
+func (p *parser) syntaxError(err error) error {
+	if syntaxErr, ok := err.(*SyntaxError); ok {
+		return syntaxErr
+	}
+	return p.lines.errorAt(p.lines.pos(p.offset+min(p.i, len(p.sql))), err.Error())
+}
+
 func (p *parser) doParse() (Query, error) {
 	for {
 		if p.i >= len(p.sql) {
@@ -178,9 +259,12 @@ func (p *parser) doParse() (Query, error) {
 				p.query.Type = Select
 				p.pop()
 				p.step = stepSelectField
-			case "INSERT INTO":
+			case "INSERT INTO", "INSERT IGNORE INTO":
 				p.query.Type = Insert
-				p.pop()
+				if p.pop() == "INSERT IGNORE INTO" {
+					// MySQL form of ON CONFLICT DO NOTHING
+					p.query.OnConflict = &OnConflict{DoNothing: true}
+				}
 				p.step = stepInsertTable
 			case "UPDATE":
 				p.query.Type = Update
@@ -195,15 +279,24 @@ func (p *parser) doParse() (Query, error) {
 				return p.query, fmt.Errorf("invalid query type")
 			}
 		case stepSelectField:
-			identifier := p.peek()
-			if !isIdentifierOrAsterisk(identifier) {
-				return p.query, fmt.Errorf("at SELECT: expected field to SELECT")
+			if len(p.query.Fields) == 0 && p.peek() == "DISTINCT" {
+				p.query.Distinct = true
+				p.pop()
 			}
+			column, err := p.parseSelectColumn()
+			if err != nil {
+				return p.query, err
+			}
+			identifier := column.Expr.String()
 			p.query.Fields = append(p.query.Fields, identifier)
-			p.pop()
 			maybeFrom := p.peek()
-			if strings.ToUpper(maybeFrom) == "AS" {
+			hasAlias := strings.ToUpper(maybeFrom) == "AS"
+			if hasAlias {
 				p.pop()
+			} else if p.i < len(p.sql) && p.sql[p.i] != '\'' && isIdentifier(maybeFrom) {
+				hasAlias = true // alias without AS, e.g. "SELECT count(*) total"
+			}
+			if hasAlias {
 				alias := p.peek()
 				if !isIdentifier(alias) {
 					return p.query, errors.New("at SELECT: expected field alias for \"" + identifier + " as\" to SELECT")
@@ -212,9 +305,11 @@ func (p *parser) doParse() (Query, error) {
 					p.query.Aliases = make(map[string]string)
 				}
 				p.query.Aliases[identifier] = alias
+				column.Alias = alias
 				p.pop()
 				maybeFrom = p.peek()
 			}
+			p.query.Columns = append(p.query.Columns, column)
 			if strings.ToUpper(maybeFrom) == "FROM" {
 				p.step = stepSelectFrom
 				continue
@@ -235,13 +330,17 @@ func (p *parser) doParse() (Query, error) {
 			p.pop()
 			p.step = stepSelectFromTable
 		case stepSelectFromTable:
-			tableName := p.peek()
-			if len(tableName) == 0 {
-				return p.query, fmt.Errorf("at SELECT: expected quoted table name")
+			p.clause = "SELECT"
+			ref, err := p.parseTableRef()
+			if err != nil {
+				return p.query, err
+			}
+			p.query.TableName, p.query.TableAlias, p.query.Subquery = ref.Name, ref.Alias, ref.Subquery
+			p.step = stepSelectClauses
+		case stepSelectClauses:
+			if err := p.parseSelectClause(); err != nil {
+				return p.query, err
 			}
-			p.query.TableName = tableName
-			p.pop()
-			p.step = stepWhere
 		case stepInsertTable:
 			tableName := p.peek()
 			if len(tableName) == 0 {
@@ -257,6 +356,17 @@ func (p *parser) doParse() (Query, error) {
 			}
 			p.query.TableName = tableName
 			p.pop()
+			p.step = stepDeleteUsing
+		case stepDeleteUsing:
+			if p.peekKeyword() == "USING" {
+				p.pop()
+				p.clause = "DELETE FROM"
+				from, err := p.parseTableRefs()
+				if err != nil {
+					return p.query, err
+				}
+				p.query.From = from
+			}
 			p.step = stepWhere
 		case stepUpdateTable:
 			tableName := p.peek()
@@ -274,102 +384,60 @@ func (p *parser) doParse() (Query, error) {
 			p.pop()
 			p.step = stepUpdateField
 		case stepUpdateField:
-			identifier := p.peek()
-			if !isIdentifier(identifier) {
-				return p.query, fmt.Errorf("at UPDATE: expected at least one field to update")
-			}
-			p.nextUpdateField = identifier
-			p.pop()
-			p.step = stepUpdateEquals
-		case stepUpdateEquals:
-			equalsRWord := p.peek()
-			if equalsRWord != "=" {
-				return p.query, fmt.Errorf("at UPDATE: expected '='")
-			}
-			p.pop()
-			p.step = stepUpdateValue
-		case stepUpdateValue:
-			quotedValue, ln := p.peekQuotedStringWithLength()
-			if ln == 0 {
-				return p.query, fmt.Errorf("at UPDATE: expected quoted value")
-			}
-			p.query.Updates[p.nextUpdateField] = quotedValue
-			p.nextUpdateField = ""
-			p.pop()
-			maybeWhere := p.peek()
-			if strings.ToUpper(maybeWhere) == "WHERE" {
+			p.clause = "UPDATE"
+			assignments, err := p.parseAssignments()
+			if err != nil {
+				return p.query, err
+			}
+			p.setAssignments(assignments)
+			p.step = stepUpdateEnd
+		case stepUpdateEnd:
+			switch p.peekKeyword() {
+			case "FROM":
+				p.pop()
+				from, err := p.parseTableRefs()
+				if err != nil {
+					return p.query, err
+				}
+				p.query.From = from
 				p.step = stepWhere
-				continue
-			}
-			p.step = stepUpdateComma
-		case stepUpdateComma:
-			commaRWord := p.peek()
-			if commaRWord != "," {
+			case "WHERE":
+				p.step = stepWhere
+			case "RETURNING":
+				p.step = stepReturning
+			default:
 				return p.query, fmt.Errorf("at UPDATE: expected ','")
 			}
-			p.pop()
-			p.step = stepUpdateField
 		case stepWhere:
 			whereRWord := p.peek()
+			if strings.ToUpper(whereRWord) == "RETURNING" {
+				p.step = stepReturning
+				continue
+			}
 			if strings.ToUpper(whereRWord) != "WHERE" {
 				return p.query, fmt.Errorf("expected WHERE")
 			}
 			p.pop()
-			p.step = stepWhereField
-		case stepWhereField:
-			identifier := p.peek()
-			if !isIdentifier(identifier) {
-				return p.query, fmt.Errorf("at WHERE: expected field")
-			}
-			p.query.Conditions = append(p.query.Conditions, Condition{Operand1: identifier, Operand1IsField: true})
-			p.pop()
-			p.step = stepWhereOperator
-		case stepWhereOperator:
-			operator := p.peek()
-			currentCondition := p.query.Conditions[len(p.query.Conditions)-1]
-			switch operator {
-			case "=":
-				currentCondition.Operator = Eq
-			case ">":
-				currentCondition.Operator = Gt
-			case ">=":
-				currentCondition.Operator = Gte
-			case "<":
-				currentCondition.Operator = Lt
-			case "<=":
-				currentCondition.Operator = Lte
-			case "!=":
-				currentCondition.Operator = Ne
-			default:
-				return p.query, fmt.Errorf("at WHERE: unknown operator")
-			}
-			p.query.Conditions[len(p.query.Conditions)-1] = currentCondition
-			p.pop()
-			p.step = stepWhereValue
-		case stepWhereValue:
-			currentCondition := p.query.Conditions[len(p.query.Conditions)-1]
-			identifier := p.peek()
-			if isIdentifier(identifier) {
-				currentCondition.Operand2 = identifier
-				currentCondition.Operand2IsField = true
-			} else {
-				quotedValue, ln := p.peekQuotedStringWithLength()
-				if ln == 0 {
-					return p.query, fmt.Errorf("at WHERE: expected quoted value")
-				}
-				currentCondition.Operand2 = quotedValue
-				currentCondition.Operand2IsField = false
+			p.clause = "WHERE"
+			p.step = stepWhereExpr
+		case stepWhereExpr:
+			where, err := p.parseExpr()
+			if err != nil {
+				return p.query, err
+			}
+			p.query.Where = where
+			p.query.Conditions, _ = p.query.FlatConditions()
+			p.step = stepWhereEnd
+		case stepWhereEnd:
+			if p.peekKeyword() == "RETURNING" && p.query.Type != Select {
+				p.step = stepReturning
+				continue
 			}
-			p.query.Conditions[len(p.query.Conditions)-1] = currentCondition
-			p.pop()
-			p.step = stepWhereAnd
-		case stepWhereAnd:
-			andRWord := p.peek()
-			if strings.ToUpper(andRWord) != "AND" {
-				return p.query, fmt.Errorf("expected AND")
+			return p.query, fmt.Errorf("at WHERE: unexpected '%s'", p.peek())
+		case stepReturning:
+			if err := p.parseReturning(); err != nil {
+				return p.query, err
 			}
-			p.pop()
-			p.step = stepWhereField
 		case stepInsertFieldsOpeningParens:
 			openingParens := p.peek()
 			if len(openingParens) != 1 || openingParens != "(" {
@@ -398,6 +466,17 @@ func (p *parser) doParse() (Query, error) {
 			p.step = stepInsertValuesRWord
 		case stepInsertValuesRWord:
 			valuesRWord := p.peek()
+			if strings.ToUpper(valuesRWord) == "SELECT" {
+				end := p.findClause("ON CONFLICT", "ON DUPLICATE KEY UPDATE", "RETURNING")
+				source, err := p.parseNested(p.i, end)
+				if err != nil {
+					return p.query, err
+				}
+				p.query.Source = source
+				p.i = end
+				p.step = stepInsertEnd
+				continue
+			}
 			if strings.ToUpper(valuesRWord) != "VALUES" {
 				return p.query, fmt.Errorf("at INSERT INTO: expected 'VALUES'")
 			}
@@ -409,15 +488,18 @@ func (p *parser) doParse() (Query, error) {
 				return p.query, fmt.Errorf("at INSERT INTO: expected opening parens")
 			}
 			p.query.Inserts = append(p.query.Inserts, []string{})
+			p.query.Values = append(p.query.Values, []Expr{})
 			p.pop()
 			p.step = stepInsertValues
 		case stepInsertValues:
-			quotedValue, ln := p.peekQuotedStringWithLength()
-			if ln == 0 {
-				return p.query, fmt.Errorf("at INSERT INTO: expected quoted value")
-			}
-			p.query.Inserts[len(p.query.Inserts)-1] = append(p.query.Inserts[len(p.query.Inserts)-1], quotedValue)
-			p.pop()
+			p.clause = "INSERT INTO"
+			value, err := p.parseValue()
+			if err != nil {
+				return p.query, err
+			}
+			row := len(p.query.Values) - 1
+			p.query.Values[row] = append(p.query.Values[row], value)
+			p.query.Inserts[row] = append(p.query.Inserts[row], exprValue(value))
 			p.step = stepInsertValuesCommaOrClosingParens
 		case stepInsertValuesCommaOrClosingParens:
 			commaOrClosingParens := p.peek()
@@ -437,10 +519,25 @@ func (p *parser) doParse() (Query, error) {
 		case stepInsertValuesCommaBeforeOpeningParens:
 			commaRWord := p.peek()
 			if strings.ToUpper(commaRWord) != "," {
-				return p.query, fmt.Errorf("at INSERT INTO: expected comma")
+				p.step = stepInsertEnd
+				continue
 			}
 			p.pop()
 			p.step = stepInsertValuesOpeningParens
+		case stepInsertEnd:
+			p.clause = "INSERT INTO"
+			switch token := p.peekKeyword(); {
+			case token == "RETURNING":
+				p.step = stepReturning
+			case p.query.OnConflict == nil && (token == "ON CONFLICT" || token == "ON DUPLICATE KEY UPDATE"):
+				if err := p.parseOnConflict(); err != nil {
+					return p.query, err
+				}
+			case p.query.OnConflict == nil && p.query.Source == nil:
+				return p.query, fmt.Errorf("at INSERT INTO: expected comma")
+			default:
+				return p.query, fmt.Errorf("at INSERT INTO: unexpected '%s'", p.peek())
+			}
 		}
 	}
 }
@@ -458,13 +555,20 @@ func (p *parser) pop() string {
 }
 
 func (p *parser) popWhitespace() {
-	for ; p.i < len(p.sql) && p.sql[p.i] == ' '; p.i++ {
+	for ; p.i < len(p.sql) && isWhitespace(p.sql[p.i]); p.i++ {
 	}
 }
 
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
 var reservedWords = []string{
-	"(", ")", ">=", "<=", "!=", ",", "=", ">", "<", "SELECT", "INSERT INTO", "VALUES", "UPDATE", "DELETE FROM",
-	"WHERE", "FROM", "SET", "AS",
+	"(", ")", ">=", "<=", "!=", "<>", ",", "=", ">", "<", "-", "SELECT", "INSERT INTO", "INSERT IGNORE INTO", "VALUES", "UPDATE", "DELETE FROM",
+	"WHERE", "FROM", "SET", "AS", "AND", "OR", "NOT", "IN", "LIKE", "ILIKE", "BETWEEN", "IS", "NULL", "TRUE", "FALSE",
+	"DISTINCT", "EXISTS", "JOIN", "INNER JOIN", "LEFT OUTER JOIN", "LEFT JOIN", "RIGHT OUTER JOIN", "RIGHT JOIN",
+	"FULL OUTER JOIN", "FULL JOIN", "CROSS JOIN", "ON CONFLICT", "ON DUPLICATE KEY UPDATE", "ON", "GROUP BY", "HAVING", "ORDER BY", "ASC", "DESC", "LIMIT", "OFFSET",
+	"DO NOTHING", "DO UPDATE SET", "RETURNING", "USING",
 }
 
 func (p *parser) peekWithLength() (string, int) {
@@ -472,49 +576,150 @@ func (p *parser) peekWithLength() (string, int) {
 		return "", 0
 	}
 	for _, rWord := range reservedWords {
-		token := strings.ToUpper(p.sql[p.i:min(len(p.sql), p.i+len(rWord))])
-		if token == rWord {
-			return token, len(token)
+		end := p.matchReservedWord(rWord)
+		if end == -1 {
+			continue
+		}
+		// keywords must not be the prefix of an identifier, e.g. "AS" and "assets"
+		if isLetter(rWord[len(rWord)-1]) && end < len(p.sql) && isIdentifierChar(p.sql[end]) {
+			continue
 		}
+		return rWord, end - p.i
 	}
 	if p.sql[p.i] == '\'' { // Quoted string
 		return p.peekQuotedStringWithLength()
 	}
+	if ln := p.peekPlaceholderLength(); ln > 0 {
+		return p.sql[p.i : p.i+ln], ln
+	}
+	if p.sql[p.i] == '"' || p.sql[p.i] == '`' { // Quoted identifier
+		if end := strings.IndexByte(p.sql[p.i+1:], p.sql[p.i]); end != -1 {
+			return p.sql[p.i : p.i+end+2], end + 2
+		}
+	}
 	return p.peekIdentifierWithLength()
 }
 
+// matchReservedWord returns the end position of rWord at p.i or -1.
+// Words of multi-word keywords like "GROUP BY" may be separated by any whitespace.
+func (p *parser) matchReservedWord(rWord string) int {
+	i := p.i
+	for j, word := range strings.Split(rWord, " ") {
+		if j > 0 {
+			start := i
+			for ; i < len(p.sql) && isWhitespace(p.sql[i]); i++ {
+			}
+			if i == start {
+				return -1
+			}
+		}
+		if len(p.sql)-i < len(word) || strings.ToUpper(p.sql[i:i+len(word)]) != word {
+			return -1
+		}
+		i += len(word)
+	}
+	return i
+}
+
 func (p *parser) peekQuotedStringWithLength() (string, int) {
 	if len(p.sql) < p.i || p.sql[p.i] != '\'' {
 		return "", 0
 	}
 	for i := p.i + 1; i < len(p.sql); i++ {
-		if p.sql[i] == '\'' && p.sql[i-1] != '\\' {
+		// '' and \' are escaped quotes
+		if p.sql[i] == '\\' || p.sql[i] == '\'' && i+1 < len(p.sql) && p.sql[i+1] == '\'' {
+			i++
+			continue
+		}
+		if p.sql[i] == '\'' {
 			return p.sql[p.i+1 : i], len(p.sql[p.i+1:i]) + 2 // +2 for the two quotes
 		}
 	}
 	return "", 0
 }
 
+// unquoteString returns the value of the content of a quoted string, undoing the escapes
+// ” and the backslash escapes of MySQL, e.g. 'O\'Brien'. \% and \_ are kept for LIKE patterns
+func unquoteString(s string) string {
+	if !strings.ContainsAny(s, "'\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (ch == '\'' || ch == '\\') && i+1 < len(s) {
+			i++
+			switch next := s[i]; {
+			case ch == '\'':
+			case next == 'n':
+				ch = '\n'
+			case next == 't':
+				ch = '\t'
+			case next == 'r':
+				ch = '\r'
+			case next == '0':
+				ch = 0
+			case next == '%' || next == '_':
+				b.WriteByte(ch)
+				ch = next
+			default:
+				ch = next
+			}
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// peekPlaceholderLength returns the length of a "?", "$1" or ":name" placeholder at p.i, or 0
+func (p *parser) peekPlaceholderLength() int {
+	switch p.sql[p.i] {
+	case '?':
+		return 1
+	case '$', ':':
+		i := p.i + 1
+		for ; i < len(p.sql) && (isDigit(p.sql[i]) || p.sql[p.i] == ':' && isIdentifierChar(p.sql[i])); i++ {
+		}
+		if i == p.i+1 {
+			return 0
+		}
+		return i - p.i
+	}
+	return 0
+}
+
+// peekIdentifierWithLength returns a dotted name, "*" is a token of its own or ends a name after a dot as in "t.*"
 func (p *parser) peekIdentifierWithLength() (string, int) {
-	for i := p.i; i < len(p.sql); i++ {
-		if matched, _ := regexp.MatchString(`[a-zA-Z0-9_*]`, string(p.sql[i])); !matched {
-			return p.sql[p.i:i], len(p.sql[p.i:i])
+	i := p.i
+	for ; i < len(p.sql); i++ {
+		if p.sql[i] == '*' {
+			if i == p.i || p.sql[i-1] == '.' {
+				i++
+			}
+			break
+		}
+		if !isIdentifierChar(p.sql[i]) && p.sql[i] != '.' {
+			break
 		}
 	}
-	return p.sql[p.i:], len(p.sql[p.i:])
+	return p.sql[p.i:i], i - p.i
+}
+
+func isIdentifierChar(ch byte) bool {
+	return isLetter(ch) || isDigit(ch) || ch == '_'
 }
 
 func (p *parser) validate() error {
-	if len(p.query.Conditions) == 0 && p.step == stepWhereField {
+	if p.query.Where == nil && p.step == stepWhereExpr {
 		return fmt.Errorf("at WHERE: empty WHERE clause")
 	}
 	if p.query.Type == UnknownType {
 		return fmt.Errorf("query type cannot be empty")
 	}
-	if p.query.TableName == "" {
+	if p.query.TableName == "" && p.query.Subquery == nil {
 		return fmt.Errorf("table name cannot be empty")
 	}
-	if len(p.query.Conditions) == 0 && (p.query.Type == Update || p.query.Type == Delete) {
+	if p.query.Where == nil && (p.query.Type == Update || p.query.Type == Delete) && !p.lint {
 		return fmt.Errorf("at WHERE: WHERE clause is mandatory for UPDATE & DELETE")
 	}
 	for _, c := range p.query.Conditions {
@@ -528,7 +733,7 @@ func (p *parser) validate() error {
 			return fmt.Errorf("at WHERE: condition with empty right side operand")
 		}
 	}
-	if p.query.Type == Insert && len(p.query.Inserts) == 0 {
+	if p.query.Type == Insert && len(p.query.Inserts) == 0 && p.query.Source == nil {
 		return fmt.Errorf("at INSERT INTO: need at least one row to insert")
 	}
 	if p.query.Type == Insert {
@@ -542,12 +747,10 @@ func (p *parser) validate() error {
 }
 
 func (p *parser) logError() {
-	if p.err == nil {
+	if p.err == nil || p.lint {
 		return
 	}
-	fmt.Println(p.sql)
-	fmt.Println(strings.Repeat(" ", p.i) + "^")
-	fmt.Println(p.err)
+	fmt.Println(FormatError(p.err))
 }
 
 func isIdentifier(s string) bool {
@@ -560,10 +763,6 @@ func isIdentifier(s string) bool {
 	return matched
 }
 
-func isIdentifierOrAsterisk(s string) bool {
-	return isIdentifier(s) || s == "*"
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,210 @@
+package dbparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Assignment is "Field = Value" of UPDATE SET and ON CONFLICT DO UPDATE SET
+type Assignment struct {
+	Field string
+	Value Expr
+}
+
+// OnConflict is ON CONFLICT [(Columns)] DO NOTHING / DO UPDATE SET ... of PostgreSQL and SQLite,
+// or ON DUPLICATE KEY UPDATE ... of MySQL, which has no Columns
+type OnConflict struct {
+	Columns     []string
+	DoNothing   bool
+	Assignments []Assignment
+}
+
+// exprValue returns the compatible form of a value for Updates and Inserts:
+// the content of a string literal, the value of another literal or the SQL of an expression
+func exprValue(e Expr) string {
+	if literal, ok := e.(*Literal); ok {
+		return literal.Value
+	}
+	return e.String()
+}
+
+// parseValue parses a value of INSERT VALUES or UPDATE SET
+func (p *parser) parseValue() (Expr, error) {
+	switch token := p.peekKeyword(); {
+	case p.i >= len(p.sql), token == ",", token == ")",
+		token == "WHERE", token == "FROM", token == "RETURNING", token == "ON CONFLICT", token == "ON DUPLICATE KEY UPDATE":
+		return nil, fmt.Errorf("at %s: expected value", p.clauseName())
+	}
+	return p.parseOperand()
+}
+
+// parseAssignments parses "a = value [, b = value...]"
+func (p *parser) parseAssignments() ([]Assignment, error) {
+	res := []Assignment{}
+	for {
+		field := p.peek()
+		if p.i < len(p.sql) && p.sql[p.i] == '\'' || !isIdentifier(field) && !isQuotedIdentifier(field) {
+			return nil, fmt.Errorf("at %s: expected at least one field to update", p.clauseName())
+		}
+		p.pop()
+		if p.peek() != "=" {
+			return nil, fmt.Errorf("at %s: expected '='", p.clauseName())
+		}
+		p.pop()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Assignment{Field: field, Value: value})
+		if p.peek() != "," {
+			return res, nil
+		}
+		p.pop()
+	}
+}
+
+// setAssignments sets the assignments of UPDATE and their compatible form in Updates
+func (p *parser) setAssignments(assignments []Assignment) {
+	p.query.Assignments = assignments
+	for _, item := range assignments {
+		p.query.Updates[item.Field] = exprValue(item.Value)
+	}
+}
+
+// parseTableRefs parses the tables of UPDATE ... FROM and DELETE ... USING, after the keyword
+func (p *parser) parseTableRefs() ([]TableRef, error) {
+	res := []TableRef{}
+	for {
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ref)
+		if p.peek() != "," {
+			return res, nil
+		}
+		p.pop()
+	}
+}
+
+// parseOnConflict parses ON CONFLICT or ON DUPLICATE KEY UPDATE
+func (p *parser) parseOnConflict() error {
+	oc := &OnConflict{}
+	p.query.OnConflict = oc
+	if p.pop() == "ON DUPLICATE KEY UPDATE" {
+		assignments, err := p.parseAssignments()
+		oc.Assignments = assignments
+		return err
+	}
+
+	if p.peek() == "(" {
+		p.pop()
+		for {
+			column := p.peek()
+			if !isIdentifier(column) && !isQuotedIdentifier(column) {
+				return fmt.Errorf("at %s: expected conflict column", p.clauseName())
+			}
+			oc.Columns = append(oc.Columns, p.pop())
+			if p.peek() == ")" {
+				p.pop()
+				break
+			}
+			if p.peek() != "," {
+				return fmt.Errorf("at %s: expected comma or closing parens", p.clauseName())
+			}
+			p.pop()
+		}
+	}
+	switch p.peekKeyword() {
+	case "DO NOTHING":
+		p.pop()
+		oc.DoNothing = true
+		return nil
+	case "DO UPDATE SET":
+		p.pop()
+		assignments, err := p.parseAssignments()
+		oc.Assignments = assignments
+		return err
+	}
+	return fmt.Errorf("at %s: expected DO NOTHING or DO UPDATE SET", p.clauseName())
+}
+
+// parseReturning parses "RETURNING expr [[AS] alias], ..." which ends the statement
+func (p *parser) parseReturning() error {
+	p.pop()
+	p.clause = "RETURNING"
+	for {
+		column := SelectColumn{}
+		if p.peek() == "*" {
+			p.pop()
+			column.Expr = &ColumnRef{Name: "*"}
+		} else {
+			e, err := p.parseOperand()
+			if err != nil {
+				return err
+			}
+			column.Expr = e
+		}
+		if p.peekKeyword() == "AS" {
+			p.pop()
+			if alias := p.peek(); !isIdentifier(alias) {
+				return fmt.Errorf("at RETURNING: expected alias")
+			}
+			column.Alias = p.pop()
+		}
+		p.query.Returning = append(p.query.Returning, column)
+		switch p.peek() {
+		case ",":
+			p.pop()
+		case "":
+			return nil
+		default:
+			return fmt.Errorf("at RETURNING: unexpected '%s'", p.peek())
+		}
+	}
+}
+
+// findClause returns the position of the first of the keywords at p.i or after, out of parens and quotes,
+// or the end of the statement
+func (p *parser) findClause(keywords ...string) int {
+	i, depth := p.i, 0
+	defer func() { p.i = i }()
+	for ; p.i < len(p.sql); p.i++ {
+		switch ch := p.sql[p.i]; ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\'', '"', '`':
+			if end := strings.IndexByte(p.sql[p.i+1:], ch); end != -1 {
+				p.i += end + 1
+			}
+		default:
+			if depth > 0 || p.i > 0 && isIdentifierChar(p.sql[p.i-1]) {
+				continue
+			}
+			for _, keyword := range keywords {
+				if end := p.matchReservedWord(keyword); end != -1 && (end == len(p.sql) || !isIdentifierChar(p.sql[end])) {
+					return p.i
+				}
+			}
+		}
+	}
+	return len(p.sql)
+}
+
+// parseNested parses the query sql[start:end], e.g. a subquery or the SELECT of INSERT ... SELECT
+func (p *parser) parseNested(start, end int) (*Query, error) {
+	src := p.sql[start:end]
+	offset := p.offset + start + len(src) - len(strings.TrimLeft(src, " \t\r\n"))
+	sub := &parser{sql: strings.TrimSpace(src), step: stepType, placeholders: p.placeholders, lines: p.lines, offset: offset}
+	q, err := sub.doParse()
+	if err == nil {
+		err = sub.validate()
+	}
+	if err != nil {
+		return nil, sub.syntaxError(err)
+	}
+	p.placeholders = sub.placeholders
+	return &q, nil
+}
@@ -0,0 +1,148 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDML(t *testing.T) {
+	q, err := Parse("UPDATE t SET count = count + 1, updated_at = NOW(), b = 'x' WHERE id = ?")
+	require.NoError(t, err)
+	require.Equal(t, []Assignment{
+		{Field: "count", Value: &BinaryExpr{Left: &ColumnRef{Name: "count"}, Operator: "+", Right: &Literal{Kind: NumberLiteral, Value: "1"}}},
+		{Field: "updated_at", Value: &FuncCall{Name: "NOW", Args: []Expr{}}},
+		{Field: "b", Value: &Literal{Kind: StringLiteral, Value: "x"}},
+	}, q.Assignments)
+	require.Equal(t, map[string]string{"count": "count + 1", "updated_at": "NOW()", "b": "x"}, q.Updates)
+
+	q, err = Parse("INSERT INTO t (a, b) VALUES (1, 'x') ON CONFLICT (a) DO UPDATE SET b = 'y', n = n + 1 RETURNING id, n AS total")
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"1", "x"}}, q.Inserts)
+	require.Equal(t, []string{"a"}, q.OnConflict.Columns)
+	require.False(t, q.OnConflict.DoNothing)
+	require.Len(t, q.OnConflict.Assignments, 2)
+	require.Equal(t, []SelectColumn{
+		{Expr: &ColumnRef{Name: "id"}},
+		{Expr: &ColumnRef{Name: "n"}, Alias: "total"},
+	}, q.Returning)
+
+	q, err = Parse("INSERT IGNORE INTO t (a) VALUES (1)")
+	require.NoError(t, err)
+	require.Equal(t, &OnConflict{DoNothing: true}, q.OnConflict)
+
+	q, err = Parse("INSERT INTO t (a, b) SELECT a, b FROM u WHERE c = 1")
+	require.NoError(t, err)
+	require.Equal(t, "u", q.Source.TableName)
+	require.Empty(t, q.Inserts)
+	require.Equal(t, []string{"t", "u"}, q.Tables())
+
+	q, err = Parse("DELETE FROM t USING u WHERE t.uid = u.id RETURNING *")
+	require.NoError(t, err)
+	require.Equal(t, []TableRef{{Name: "u"}}, q.From)
+	require.Equal(t, []string{"t", "u"}, q.Tables())
+
+	q, err = Parse("UPDATE t SET total = o.amount * 2 FROM orders o WHERE o.id = t.order_id")
+	require.NoError(t, err)
+	require.Equal(t, []TableRef{{Name: "orders", Alias: "o"}}, q.From)
+	require.Equal(t, []string{"t", "orders"}, q.Tables())
+}
+
+func TestParseDMLErrors(t *testing.T) {
+	ts := []struct {
+		SQL string
+		Err string
+	}{
+		{SQL: "UPDATE t SET a = WHERE id = 1", Err: "at UPDATE: expected value"},
+		{SQL: "INSERT INTO t (a) VALUES (1) ON CONFLICT (a) DO", Err: "at INSERT INTO: expected DO NOTHING or DO UPDATE SET"},
+		{SQL: "INSERT INTO t (a) VALUES (1) ON CONFLICT (a, DO NOTHING", Err: "at INSERT INTO: expected conflict column"},
+		{SQL: "DELETE FROM t RETURNING id,", Err: "at RETURNING: expected field"},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := Parse(tc.SQL)
+			require.Error(t, err)
+			require.Equal(t, tc.Err, errorMsg(err))
+		})
+	}
+}
+
+func TestPrinterDML(t *testing.T) {
+	ts := []struct {
+		SQL      string
+		Expected map[Dialect]string
+	}{
+		{
+			SQL: "INSERT INTO t (a, b) VALUES (?, NOW()) ON CONFLICT (a) DO UPDATE SET b = NOW() RETURNING id",
+			Expected: map[Dialect]string{
+				MySQL:      "INSERT INTO t (a, b) VALUES (?, NOW()) ON DUPLICATE KEY UPDATE b = NOW()",
+				PostgreSQL: "INSERT INTO t (a, b) VALUES ($1, NOW()) ON CONFLICT (a) DO UPDATE SET b = NOW() RETURNING id",
+				SQLite:     "INSERT INTO t (a, b) VALUES (?, NOW()) ON CONFLICT (a) DO UPDATE SET b = NOW() RETURNING id",
+			},
+		},
+		{
+			SQL: "INSERT INTO t (a, b) VALUES (1, 2) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b + t.b",
+			Expected: map[Dialect]string{
+				MySQL:      "INSERT INTO t (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE b = VALUES(b) + t.b",
+				PostgreSQL: "INSERT INTO t (a, b) VALUES (1, 2) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b + t.b",
+			},
+		},
+		{
+			SQL: "INSERT INTO t (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE b = VALUES(b)",
+			Expected: map[Dialect]string{
+				Generic:    "INSERT INTO t (a, b) VALUES (1, 2) ON CONFLICT DO UPDATE SET b = VALUES(b)",
+				MySQL:      "INSERT INTO t (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE b = VALUES(b)",
+				PostgreSQL: "INSERT INTO t (a, b) VALUES (1, 2) ON CONFLICT DO UPDATE SET b = EXCLUDED.b",
+			},
+		},
+		{
+			SQL: "INSERT INTO t (a) VALUES (1) ON CONFLICT DO NOTHING",
+			Expected: map[Dialect]string{
+				MySQL:      "INSERT IGNORE INTO t (a) VALUES (1)",
+				PostgreSQL: "INSERT INTO t (a) VALUES (1) ON CONFLICT DO NOTHING",
+			},
+		},
+		{
+			SQL: "UPDATE t SET n = n + ?, b = 'x' FROM u WHERE t.uid = u.id AND u.c = ? RETURNING t.id AS id",
+			Expected: map[Dialect]string{
+				Generic:    "UPDATE t SET n = n + ?, b = 'x' FROM u WHERE t.uid = u.id AND u.c = ? RETURNING t.id AS id",
+				PostgreSQL: "UPDATE t SET n = n + $1, b = 'x' FROM u WHERE t.uid = u.id AND u.c = $2 RETURNING t.id AS id",
+			},
+		},
+		{
+			SQL: "DELETE FROM t USING u WHERE t.uid = u.id",
+			Expected: map[Dialect]string{
+				PostgreSQL: "DELETE FROM t USING u WHERE t.uid = u.id",
+			},
+		},
+		{
+			SQL: "INSERT INTO t (a) SELECT a FROM u WHERE b = ?",
+			Expected: map[Dialect]string{
+				PostgreSQL: "INSERT INTO t (a) SELECT a FROM u WHERE b = $1",
+			},
+		},
+	}
+	for _, tc := range ts {
+		q, err := Parse(tc.SQL)
+		require.NoError(t, err)
+		for dialect, expected := range tc.Expected {
+			t.Run(dialect.String()+" "+tc.SQL, func(t *testing.T) {
+				sql := NewPrinter(dialect).Query(q)
+				require.Equal(t, expected, sql)
+				_, err := Parse(sql)
+				require.NoError(t, err)
+			})
+		}
+	}
+
+	// MySQL has no RETURNING
+	q, err := Parse("DELETE FROM t WHERE a = ? RETURNING id")
+	require.NoError(t, err)
+	_, _, err = NewPrinter(MySQL).QueryParams(q)
+	require.ErrorIs(t, err, ErrUnsupportedClause)
+	require.Equal(t, "DELETE FROM t WHERE a = ?", NewPrinter(MySQL).Query(q))
+	sql, params, err := NewPrinter(SQLite).QueryParams(q)
+	require.NoError(t, err)
+	require.Equal(t, "DELETE FROM t WHERE a = ? RETURNING id", sql)
+	require.Equal(t, []Param{{Index: 1}}, params)
+}
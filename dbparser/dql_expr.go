@@ -0,0 +1,703 @@
+package dbparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a node of a WHERE expression tree
+type Expr interface {
+	String() string
+	exprNode()
+}
+
+// LogicalOperator joins two boolean expressions
+type LogicalOperator int
+
+const (
+	// And -> "AND"
+	And LogicalOperator = iota
+	// Or -> "OR"
+	Or
+)
+
+func (o LogicalOperator) String() string {
+	if o == Or {
+		return "OR"
+	}
+	return "AND"
+}
+
+// LiteralKind is the type of a literal value
+type LiteralKind int
+
+const (
+	// StringLiteral is a single quoted string, e.g. 'a'
+	StringLiteral LiteralKind = iota
+	// NumberLiteral is an integer or decimal number, e.g. -1.5
+	NumberLiteral
+	// BoolLiteral is TRUE or FALSE
+	BoolLiteral
+	// NullLiteral is NULL
+	NullLiteral
+)
+
+// LogicalExpr is "Left AND Right" or "Left OR Right"
+type LogicalExpr struct {
+	Operator LogicalOperator
+	Left     Expr
+	Right    Expr
+}
+
+// NotExpr is "NOT Expr"
+type NotExpr struct {
+	Expr Expr
+}
+
+// ParenExpr is an expression wrapped by parentheses
+type ParenExpr struct {
+	Expr Expr
+}
+
+// ComparisonExpr is a binary comparison such as "a = 1" or "name NOT LIKE 'a%'"
+type ComparisonExpr struct {
+	Left     Expr
+	Operator Operator
+	// Not negates LIKE / ILIKE
+	Not   bool
+	Right Expr
+}
+
+// InExpr is "Expr [NOT] IN (List...)" or "Expr [NOT] IN (SELECT ...)"
+type InExpr struct {
+	Expr     Expr
+	Not      bool
+	List     []Expr
+	Subquery *Query
+}
+
+// BetweenExpr is "Expr [NOT] BETWEEN Low AND High"
+type BetweenExpr struct {
+	Expr Expr
+	Not  bool
+	Low  Expr
+	High Expr
+}
+
+// IsNullExpr is "Expr IS [NOT] NULL"
+type IsNullExpr struct {
+	Expr Expr
+	Not  bool
+}
+
+// ColumnRef is a reference to a column, optionally qualified by a table, e.g. u.name
+type ColumnRef struct {
+	Table string
+	Name  string
+}
+
+// Literal is a constant value. For strings Value is the content between the quotes with the escapes undone
+type Literal struct {
+	Kind  LiteralKind
+	Value string
+}
+
+// FuncCall is a function call such as lower(name), COUNT(*) or COUNT(DISTINCT id)
+type FuncCall struct {
+	Name string
+	Args []Expr
+	// Star is true for calls like COUNT(*)
+	Star     bool
+	Distinct bool
+}
+
+// SubqueryExpr is a scalar subquery, e.g. the right side of "a = (SELECT max(a) FROM t)"
+type SubqueryExpr struct {
+	Query *Query
+}
+
+// ExistsExpr is "EXISTS (SELECT ...)", NOT EXISTS is a NotExpr around it
+type ExistsExpr struct {
+	Query *Query
+}
+
+// BinaryExpr is an arithmetic or string concatenation, e.g. "count + 1" or "first || last"
+type BinaryExpr struct {
+	Left Expr
+	// Operator is "+", "-", "*", "/", "%" or "||"
+	Operator string
+	Right    Expr
+}
+
+// UnaryExpr is a negated operand, e.g. "-price" or "-(a + b)". A negative number is a Literal
+type UnaryExpr struct {
+	// Operator is "-"
+	Operator string
+	Expr     Expr
+}
+
+// Placeholder is a bind parameter: "?", "$1" or ":name"
+type Placeholder struct {
+	Style PlaceholderStyle
+	// Index is N for "$N", otherwise the 1-based position of the placeholder in the statement
+	Index int
+	// Name is set for ":name"
+	Name string
+}
+
+func (*LogicalExpr) exprNode()    {}
+func (*NotExpr) exprNode()        {}
+func (*ParenExpr) exprNode()      {}
+func (*ComparisonExpr) exprNode() {}
+func (*InExpr) exprNode()         {}
+func (*BetweenExpr) exprNode()    {}
+func (*IsNullExpr) exprNode()     {}
+func (*ColumnRef) exprNode()      {}
+func (*Literal) exprNode()        {}
+func (*FuncCall) exprNode()       {}
+func (*SubqueryExpr) exprNode()   {}
+func (*ExistsExpr) exprNode()     {}
+func (*Placeholder) exprNode()    {}
+func (*BinaryExpr) exprNode()     {}
+func (*UnaryExpr) exprNode()      {}
+
+// String methods render the expression with the Generic printer, see Printer.Expr
+
+func (e *LogicalExpr) String() string    { return genericPrinter.Expr(e) }
+func (e *NotExpr) String() string        { return genericPrinter.Expr(e) }
+func (e *ParenExpr) String() string      { return genericPrinter.Expr(e) }
+func (e *ComparisonExpr) String() string { return genericPrinter.Expr(e) }
+func (e *InExpr) String() string         { return genericPrinter.Expr(e) }
+func (e *BetweenExpr) String() string    { return genericPrinter.Expr(e) }
+func (e *IsNullExpr) String() string     { return genericPrinter.Expr(e) }
+func (e *ColumnRef) String() string      { return genericPrinter.Expr(e) }
+func (e *Literal) String() string        { return genericPrinter.Expr(e) }
+func (e *FuncCall) String() string       { return genericPrinter.Expr(e) }
+func (e *SubqueryExpr) String() string   { return genericPrinter.Expr(e) }
+func (e *ExistsExpr) String() string     { return genericPrinter.Expr(e) }
+func (e *Placeholder) String() string    { return genericPrinter.Expr(e) }
+func (e *BinaryExpr) String() string     { return genericPrinter.Expr(e) }
+func (e *UnaryExpr) String() string      { return genericPrinter.Expr(e) }
+
+// WalkExpr visits e and its children depth first, fn returning false skips the children.
+// Subqueries are not entered, use Query.Tables or walk their clauses explicitly.
+func WalkExpr(e Expr, fn func(Expr) bool) {
+	if e == nil || !fn(e) {
+		return
+	}
+	switch e := e.(type) {
+	case *LogicalExpr:
+		WalkExpr(e.Left, fn)
+		WalkExpr(e.Right, fn)
+	case *NotExpr:
+		WalkExpr(e.Expr, fn)
+	case *ParenExpr:
+		WalkExpr(e.Expr, fn)
+	case *ComparisonExpr:
+		WalkExpr(e.Left, fn)
+		WalkExpr(e.Right, fn)
+	case *InExpr:
+		WalkExpr(e.Expr, fn)
+		for _, item := range e.List {
+			WalkExpr(item, fn)
+		}
+	case *BetweenExpr:
+		WalkExpr(e.Expr, fn)
+		WalkExpr(e.Low, fn)
+		WalkExpr(e.High, fn)
+	case *IsNullExpr:
+		WalkExpr(e.Expr, fn)
+	case *BinaryExpr:
+		WalkExpr(e.Left, fn)
+		WalkExpr(e.Right, fn)
+	case *UnaryExpr:
+		WalkExpr(e.Expr, fn)
+	case *FuncCall:
+		for _, item := range e.Args {
+			WalkExpr(item, fn)
+		}
+	}
+}
+
+var operatorSymbol = map[Operator]string{
+	Eq:    "=",
+	Ne:    "!=",
+	Gt:    ">",
+	Lt:    "<",
+	Gte:   ">=",
+	Lte:   "<=",
+	Like:  "LIKE",
+	ILike: "ILIKE",
+}
+
+var comparisonOperators = map[string]Operator{
+	"=":     Eq,
+	"!=":    Ne,
+	"<>":    Ne,
+	">":     Gt,
+	"<":     Lt,
+	">=":    Gte,
+	"<=":    Lte,
+	"LIKE":  Like,
+	"ILIKE": ILike,
+}
+
+// FlatConditions returns the WHERE clause as a list of simple conditions joined by AND.
+// ok is false when the expression uses anything else (OR, NOT, IN, functions...),
+// in which case Where must be used instead.
+func (q Query) FlatConditions() ([]Condition, bool) {
+	if q.Where == nil {
+		return nil, true
+	}
+	return flattenConditions(q.Where, nil)
+}
+
+func flattenConditions(e Expr, res []Condition) ([]Condition, bool) {
+	switch e := e.(type) {
+	case *LogicalExpr:
+		if e.Operator != And {
+			return nil, false
+		}
+		res, ok := flattenConditions(e.Left, res)
+		if !ok {
+			return nil, false
+		}
+		return flattenConditions(e.Right, res)
+	case *ComparisonExpr:
+		if e.Not || e.Operator == Like || e.Operator == ILike {
+			return nil, false
+		}
+		left, ok := e.Left.(*ColumnRef)
+		if !ok {
+			return nil, false
+		}
+		cond := Condition{Operand1: left.String(), Operand1IsField: true, Operator: e.Operator}
+		switch right := e.Right.(type) {
+		case *ColumnRef:
+			cond.Operand2, cond.Operand2IsField = right.String(), true
+		case *Literal:
+			if right.Kind == NullLiteral {
+				return nil, false
+			}
+			cond.Operand2 = right.Value
+		case *Placeholder:
+			cond.Operand2 = right.String()
+		default:
+			return nil, false
+		}
+		return append(res, cond), true
+	}
+	return nil, false
+}
+
+// parseExpr parses a boolean expression with the precedence OR < AND < NOT < predicate
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseExprFrom(nil)
+}
+
+// parseExprFrom is parseExpr when the operand starting the expression has already been parsed,
+// first is nil when it has not
+func (p *parser) parseExprFrom(first Expr) (Expr, error) {
+	left, err := p.parseAndFrom(first)
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword() == "OR" {
+		p.pop()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Operator: Or, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	return p.parseAndFrom(nil)
+}
+
+func (p *parser) parseAndFrom(first Expr) (Expr, error) {
+	var left Expr
+	var err error
+	if first != nil {
+		left, err = p.parsePredicateFrom(first)
+	} else {
+		left, err = p.parseNot()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword() == "AND" {
+		p.pop()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Operator: And, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	switch p.peekKeyword() {
+	case "NOT":
+		p.pop()
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: e}, nil
+	case "EXISTS":
+		p.pop()
+		if !p.peekSubquery() {
+			return nil, fmt.Errorf("at %s: expected subquery after EXISTS", p.clauseName())
+		}
+		sub, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+		return &ExistsExpr{Query: sub}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return p.parsePredicateFrom(left)
+}
+
+// parsePredicateFrom parses the operator and the right side of a predicate whose left operand has been parsed
+func (p *parser) parsePredicateFrom(left Expr) (Expr, error) {
+	token := p.peekKeyword()
+	not := false
+	if token == "NOT" {
+		not = true
+		p.pop()
+		token = p.peekKeyword()
+		if token != "LIKE" && token != "ILIKE" && token != "IN" && token != "BETWEEN" {
+			return nil, fmt.Errorf("at %s: expected LIKE, IN or BETWEEN after NOT", p.clauseName())
+		}
+	}
+
+	if op, ok := comparisonOperators[token]; ok {
+		if not && op != Like && op != ILike {
+			return nil, fmt.Errorf("at %s: unknown operator", p.clauseName())
+		}
+		p.pop()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &ComparisonExpr{Left: left, Operator: op, Not: not, Right: right}, nil
+	}
+
+	switch token {
+	case "IN":
+		p.pop()
+		if p.peekSubquery() {
+			sub, err := p.parseSubquery()
+			if err != nil {
+				return nil, err
+			}
+			return &InExpr{Expr: left, Not: not, Subquery: sub}, nil
+		}
+		if p.peek() != "(" {
+			return nil, fmt.Errorf("at %s: expected opening parens after IN", p.clauseName())
+		}
+		p.pop()
+		list, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("at %s: empty IN list", p.clauseName())
+		}
+		return &InExpr{Expr: left, Not: not, List: list}, nil
+
+	case "BETWEEN":
+		p.pop()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if p.peekKeyword() != "AND" {
+			return nil, fmt.Errorf("at %s: expected AND in BETWEEN", p.clauseName())
+		}
+		p.pop()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Expr: left, Not: not, Low: low, High: high}, nil
+
+	case "IS":
+		p.pop()
+		if p.peekKeyword() == "NOT" {
+			not = true
+			p.pop()
+		}
+		if p.peekKeyword() != "NULL" {
+			return nil, fmt.Errorf("at %s: expected NULL after IS", p.clauseName())
+		}
+		p.pop()
+		return &IsNullExpr{Expr: left, Not: not}, nil
+	}
+
+	// 单独的操作数只有函数、括号中的条件以及布尔值可以作为条件
+	if isCondition(left) {
+		return left, nil
+	}
+	if token == "" || token == ")" || token == "AND" || token == "OR" {
+		return nil, fmt.Errorf("at %s: condition without operator", p.clauseName())
+	}
+	return nil, fmt.Errorf("at %s: unknown operator", p.clauseName())
+}
+
+// isCondition reports whether an operand standing alone is a condition, see parsePredicateFrom
+func isCondition(e Expr) bool {
+	switch e := e.(type) {
+	case *FuncCall, *LogicalExpr, *NotExpr, *ComparisonExpr, *InExpr, *BetweenExpr, *IsNullExpr, *ExistsExpr:
+		return true
+	case *ParenExpr:
+		return isCondition(e.Expr)
+	case *Literal:
+		return e.Kind == BoolLiteral
+	}
+	return false
+}
+
+// parseExprList parses "a, b, c)" after the opening parens has been popped
+func (p *parser) parseExprList() ([]Expr, error) {
+	list := []Expr{}
+	if p.peek() == ")" {
+		p.pop()
+		return list, nil
+	}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		switch p.peek() {
+		case ",":
+			p.pop()
+		case ")":
+			p.pop()
+			return list, nil
+		default:
+			return nil, fmt.Errorf("at %s: expected comma or closing parens", p.clauseName())
+		}
+	}
+}
+
+// binaryPrecedence is the precedence of the arithmetic operators, higher binds tighter
+var binaryPrecedence = map[string]int{"||": 1, "+": 1, "-": 1, "*": 2, "/": 2, "%": 2}
+
+// parseOperand parses primaries joined by arithmetic operators, e.g. "price * (1 - discount)"
+func (p *parser) parseOperand() (Expr, error) {
+	return p.parseBinary(1)
+}
+
+// parseBinary parses the operators of precedence minPrec or higher, left-associative
+func (p *parser) parseBinary(minPrec int) (Expr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peekBinaryOperator()
+		prec, ok := binaryPrecedence[op]
+		if !ok || prec < minPrec {
+			return left, nil
+		}
+		p.i += len(op)
+		p.popWhitespace()
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Left: left, Operator: op, Right: right}
+	}
+}
+
+// peekBinaryOperator returns the arithmetic operator at p.i or ""
+func (p *parser) peekBinaryOperator() string {
+	if p.i >= len(p.sql) {
+		return ""
+	}
+	if strings.HasPrefix(p.sql[p.i:], "||") {
+		return "||"
+	}
+	switch ch := p.sql[p.i]; ch {
+	case '+', '-', '*', '/', '%':
+		return string(ch)
+	}
+	return ""
+}
+
+// parsePrimary parses a literal, a column, a function call or a parenthesized expression
+func (p *parser) parsePrimary() (Expr, error) {
+	token, ln := p.peekWithLength()
+	switch {
+	case ln == 0:
+		return nil, fmt.Errorf("at %s: expected field", p.clauseName())
+
+	case p.sql[p.i] == '\'':
+		p.pop()
+		return &Literal{Kind: StringLiteral, Value: unquoteString(token)}, nil
+
+	case token == "(" && p.peekSubquery():
+		sub, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+		return &SubqueryExpr{Query: sub}, nil
+
+	case token == "(":
+		p.pop()
+		// the parens hold either an operand, e.g. "(a + b) * 2", or a condition starting with one,
+		// e.g. "(a = 1 OR b = 2)", which is known only after the operand
+		var e Expr
+		var err error
+		if kw := p.peekKeyword(); kw == "NOT" || kw == "EXISTS" {
+			e, err = p.parseExpr()
+		} else if e, err = p.parseOperand(); err == nil && p.peek() != ")" {
+			e, err = p.parseExprFrom(e)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("at %s: expected closing parens", p.clauseName())
+		}
+		p.pop()
+		return &ParenExpr{Expr: e}, nil
+
+	case token == "-":
+		p.pop()
+		if next := p.peek(); isNumber(next) {
+			p.pop()
+			return &Literal{Kind: NumberLiteral, Value: token + next}, nil
+		}
+		e, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Operator: token, Expr: e}, nil
+
+	case isNumber(token):
+		p.pop()
+		return &Literal{Kind: NumberLiteral, Value: token}, nil
+
+	case isPlaceholder(token):
+		p.pop()
+		return p.newPlaceholder(token), nil
+
+	case token == "NULL":
+		p.pop()
+		return &Literal{Kind: NullLiteral, Value: token}, nil
+
+	case token == "TRUE" || token == "FALSE":
+		p.pop()
+		return &Literal{Kind: BoolLiteral, Value: token}, nil
+	}
+
+	// VALUES(column) is the value proposed for insertion in the ON DUPLICATE KEY UPDATE of MySQL
+	values := token == "VALUES" && strings.HasPrefix(strings.TrimLeft(p.sql[p.i+ln:], " \t\r\n"), "(")
+	if !values && !isIdentifier(token) && !isQuotedIdentifier(token) {
+		return nil, fmt.Errorf("at %s: expected field", p.clauseName())
+	}
+	p.pop()
+	if p.peek() == "(" && !isQuotedIdentifier(token) {
+		p.pop()
+		call := &FuncCall{Name: token}
+		if p.peek() == "*" {
+			p.pop()
+			if p.peek() != ")" {
+				return nil, fmt.Errorf("at %s: expected closing parens", p.clauseName())
+			}
+			p.pop()
+			call.Star = true
+			return call, nil
+		}
+		if p.peek() == "DISTINCT" {
+			p.pop()
+			call.Distinct = true
+		}
+		args, err := p.parseExprList()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = args
+		return call, nil
+	}
+	return newColumnRef(token), nil
+}
+
+// peekKeyword is peek for keywords, quoted strings are never keywords
+func (p *parser) peekKeyword() string {
+	if p.i < len(p.sql) && p.sql[p.i] == '\'' {
+		return ""
+	}
+	return strings.ToUpper(p.peek())
+}
+
+func (p *parser) clauseName() string {
+	if p.clause == "" {
+		return "WHERE"
+	}
+	return p.clause
+}
+
+func newColumnRef(token string) *ColumnRef {
+	ref := &ColumnRef{Name: token}
+	if !isQuotedIdentifier(token) {
+		if idx := strings.LastIndexByte(token, '.'); idx != -1 {
+			ref.Table, ref.Name = token[:idx], token[idx+1:]
+		}
+	}
+	return ref
+}
+
+func (p *parser) newPlaceholder(token string) *Placeholder {
+	p.placeholders++
+	ph := &Placeholder{Style: QuestionPlaceholder, Index: p.placeholders}
+	switch token[0] {
+	case '$':
+		ph.Style = DollarPlaceholder
+		ph.Index, _ = strconv.Atoi(token[1:])
+	case ':':
+		ph.Style, ph.Name = NamedPlaceholder, token[1:]
+	}
+	return ph
+}
+
+func isPlaceholder(s string) bool {
+	return s == "?" || len(s) > 1 && (s[0] == '$' || s[0] == ':')
+}
+
+func isNumber(s string) bool {
+	if s == "" || s == "." {
+		return false
+	}
+	dot := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '.' && !dot:
+			dot = true
+		case !isDigit(s[i]):
+			return false
+		}
+	}
+	return true
+}
+
+func isQuotedIdentifier(s string) bool {
+	return len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0]
+}
@@ -0,0 +1,148 @@
+package dbparser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereExpr(t *testing.T) {
+	q, err := Parse("SELECT a FROM 'b' WHERE a = 1 OR b = 'x' AND NOT c > 2")
+	require.NoError(t, err)
+	require.Equal(t, &LogicalExpr{
+		Operator: Or,
+		Left:     &ComparisonExpr{Left: &ColumnRef{Name: "a"}, Operator: Eq, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+		Right: &LogicalExpr{
+			Operator: And,
+			Left:     &ComparisonExpr{Left: &ColumnRef{Name: "b"}, Operator: Eq, Right: &Literal{Kind: StringLiteral, Value: "x"}},
+			Right:    &NotExpr{Expr: &ComparisonExpr{Left: &ColumnRef{Name: "c"}, Operator: Gt, Right: &Literal{Kind: NumberLiteral, Value: "2"}}},
+		},
+	}, q.Where)
+	require.Nil(t, q.Conditions)
+
+	q, err = Parse("SELECT a FROM 'b' WHERE (a = 1 OR b = 2) AND u.id IN (1, -2, '3')")
+	require.NoError(t, err)
+	require.Equal(t, &LogicalExpr{
+		Operator: And,
+		Left: &ParenExpr{Expr: &LogicalExpr{
+			Operator: Or,
+			Left:     &ComparisonExpr{Left: &ColumnRef{Name: "a"}, Operator: Eq, Right: &Literal{Kind: NumberLiteral, Value: "1"}},
+			Right:    &ComparisonExpr{Left: &ColumnRef{Name: "b"}, Operator: Eq, Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+		}},
+		Right: &InExpr{Expr: &ColumnRef{Table: "u", Name: "id"}, List: []Expr{
+			&Literal{Kind: NumberLiteral, Value: "1"},
+			&Literal{Kind: NumberLiteral, Value: "-2"},
+			&Literal{Kind: StringLiteral, Value: "3"},
+		}},
+	}, q.Where)
+}
+
+func TestWherePredicates(t *testing.T) {
+	ts := []struct {
+		SQL   string
+		Where string
+		Err   error
+	}{
+		{SQL: "a LIKE 'x%'", Where: "a LIKE 'x%'"},
+		{SQL: "a not ilike 'x%'", Where: "a NOT ILIKE 'x%'"},
+		{SQL: "a NOT IN ('x', 'y')", Where: "a NOT IN ('x', 'y')"},
+		{SQL: "age BETWEEN 18 AND 30 AND ok = TRUE", Where: "age BETWEEN 18 AND 30 AND ok = TRUE"},
+		{SQL: "a NOT BETWEEN 1.5 AND 2", Where: "a NOT BETWEEN 1.5 AND 2"},
+		{SQL: "deleted_at IS NULL OR deleted_at IS NOT NULL", Where: "deleted_at IS NULL OR deleted_at IS NOT NULL"},
+		{SQL: "lower(name) = 'bob' AND is_admin(id)", Where: "lower(name) = 'bob' AND is_admin(id)"},
+		{SQL: "coalesce(a, b, 0) > 1", Where: "coalesce(a, b, 0) > 1"},
+		{SQL: "order_id <> 1 AND assets = notes AND `index` = 'it''s'", Where: "order_id != 1 AND assets = notes AND `index` = 'it''s'"},
+		{SQL: "NOT (a = 1)", Where: "NOT (a = 1)"},
+		{SQL: "a IN ()", Err: fmt.Errorf("at WHERE: empty IN list")},
+		{SQL: "a IN 1", Err: fmt.Errorf("at WHERE: expected opening parens after IN")},
+		{SQL: "a BETWEEN 1", Err: fmt.Errorf("at WHERE: expected AND in BETWEEN")},
+		{SQL: "a IS 1", Err: fmt.Errorf("at WHERE: expected NULL after IS")},
+		{SQL: "a NOT = 1", Err: fmt.Errorf("at WHERE: expected LIKE, IN or BETWEEN after NOT")},
+		{SQL: "(a = 1", Err: fmt.Errorf("at WHERE: expected closing parens")},
+		{SQL: "a = 1 OR", Err: fmt.Errorf("at WHERE: expected field")},
+		{SQL: "a = 1 b = 2", Err: fmt.Errorf("at WHERE: unexpected 'b'")},
+		{SQL: "a b", Err: fmt.Errorf("at WHERE: unknown operator")},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			q, err := Parse("SELECT a FROM 'b' WHERE " + tc.SQL)
+			if tc.Err != nil {
+				require.Equal(t, tc.Err.Error(), errorMsg(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.Where, q.Where.String())
+		})
+	}
+}
+
+func TestFlatConditions(t *testing.T) {
+	q, err := Parse("DELETE FROM 'a' WHERE a = 1 AND t.b != c")
+	require.NoError(t, err)
+	conds, ok := q.FlatConditions()
+	require.True(t, ok)
+	require.Equal(t, []Condition{
+		{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1"},
+		{Operand1: "t.b", Operand1IsField: true, Operator: Ne, Operand2: "c", Operand2IsField: true},
+	}, conds)
+	require.Equal(t, conds, q.Conditions)
+
+	for _, where := range []string{"a = 1 OR b = 2", "a IS NULL", "a LIKE 'x'", "a = NULL", "lower(a) = 'x'"} {
+		q, err := Parse("DELETE FROM 'a' WHERE " + where)
+		require.NoError(t, err)
+		_, ok := q.FlatConditions()
+		require.False(t, ok, where)
+		require.Nil(t, q.Conditions, where)
+	}
+}
+
+func TestStarOperand(t *testing.T) {
+	q, err := Parse("SELECT a*b, t.*, count(*) FROM t WHERE price*2 > 10")
+	require.NoError(t, err)
+	require.Equal(t, []SelectColumn{
+		{Expr: &BinaryExpr{Left: &ColumnRef{Name: "a"}, Operator: "*", Right: &ColumnRef{Name: "b"}}},
+		{Expr: &ColumnRef{Table: "t", Name: "*"}},
+		{Expr: &FuncCall{Name: "count", Star: true}},
+	}, q.Columns)
+	require.Equal(t, &ComparisonExpr{
+		Left:     &BinaryExpr{Left: &ColumnRef{Name: "price"}, Operator: "*", Right: &Literal{Kind: NumberLiteral, Value: "2"}},
+		Operator: Gt,
+		Right:    &Literal{Kind: NumberLiteral, Value: "10"},
+	}, q.Where)
+	require.Equal(t, `SELECT a * b, t.*, count(*) FROM t WHERE price * 2 > 10`, NewPrinter(PostgreSQL).Query(q))
+
+	_, err = Parse("SELECT a FROM t WHERE * > 10")
+	require.Error(t, err)
+}
+
+func TestParenOperand(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT * FROM t WHERE (a) = 3",
+		"SELECT (a + b) AS s FROM t",
+		"SELECT * FROM t WHERE price * (1 - discount) > 10",
+		"SELECT * FROM t WHERE (a = 1 OR b = 2) AND (a + 1) * 2 > b",
+		"SELECT * FROM t WHERE ((a = 1) OR b = 2)",
+		"SELECT * FROM t WHERE (NOT a = 1)",
+		"SELECT -a, -(1), - -1, -lower(b) FROM t WHERE -a < -(b + 1)",
+		"UPDATE t SET n = (n + 1) WHERE id = 1",
+		"INSERT INTO t (a) VALUES ((1 + 2))",
+	} {
+		q, err := Parse(sql)
+		require.NoError(t, err, sql)
+		require.Equal(t, sql, NewPrinter(PostgreSQL).Query(q))
+	}
+
+	q, err := Parse("SELECT * FROM t WHERE -a = (1)")
+	require.NoError(t, err)
+	require.Equal(t, &ComparisonExpr{
+		Left:     &UnaryExpr{Operator: "-", Expr: &ColumnRef{Name: "a"}},
+		Operator: Eq,
+		Right:    &ParenExpr{Expr: &Literal{Kind: NumberLiteral, Value: "1"}},
+	}, q.Where)
+
+	for _, where := range []string{"(a + 1)", "(a AND b = 1)", "(a = 1", "-"} {
+		_, err := Parse("SELECT * FROM t WHERE " + where)
+		require.Error(t, err, where)
+	}
+}
@@ -0,0 +1,354 @@
+package dbparser
+
+import (
+	"fmt"
+)
+
+// SelectColumn is one expression of the SELECT list, e.g. "COUNT(*) AS total"
+type SelectColumn struct {
+	Expr  Expr
+	Alias string
+}
+
+// TableRef is a table or a subquery in FROM / JOIN
+type TableRef struct {
+	Name     string
+	Alias    string
+	Subquery *Query
+}
+
+// JoinType is the type of a JOIN, e.g. LEFT JOIN
+type JoinType int
+
+const (
+	// InnerJoin is JOIN / INNER JOIN
+	InnerJoin JoinType = iota
+	// LeftJoin is LEFT [OUTER] JOIN
+	LeftJoin
+	// RightJoin is RIGHT [OUTER] JOIN
+	RightJoin
+	// FullJoin is FULL [OUTER] JOIN
+	FullJoin
+	// CrossJoin is CROSS JOIN or a comma separated table list
+	CrossJoin
+)
+
+// JoinTypeString is a string slice with the names of all join types in order
+var JoinTypeString = []string{
+	"INNER JOIN",
+	"LEFT JOIN",
+	"RIGHT JOIN",
+	"FULL JOIN",
+	"CROSS JOIN",
+}
+
+func (t JoinType) String() string {
+	return enumName(JoinTypeString, int(t), "JoinType")
+}
+
+// Join is a JOIN clause, On is nil for CROSS JOIN
+type Join struct {
+	Type  JoinType
+	Table TableRef
+	On    Expr
+}
+
+// OrderBy is one item of ORDER BY
+type OrderBy struct {
+	Expr Expr
+	Desc bool
+}
+
+var joinTypes = map[string]JoinType{
+	"JOIN":             InnerJoin,
+	"INNER JOIN":       InnerJoin,
+	"LEFT JOIN":        LeftJoin,
+	"LEFT OUTER JOIN":  LeftJoin,
+	"RIGHT JOIN":       RightJoin,
+	"RIGHT OUTER JOIN": RightJoin,
+	"FULL JOIN":        FullJoin,
+	"FULL OUTER JOIN":  FullJoin,
+	"CROSS JOIN":       CrossJoin,
+	",":                CrossJoin,
+}
+
+// selectClauseRank is the order in which SELECT clauses must appear
+var selectClauseRank = map[string]int{
+	"WHERE":    2,
+	"GROUP BY": 3,
+	"HAVING":   4,
+	"ORDER BY": 5,
+	"LIMIT":    6,
+	"OFFSET":   7,
+}
+
+const joinClauseRank = 1
+
+// Tables returns the names of all tables the query reads or writes,
+// including JOINs and subqueries, in order of appearance and without duplicates
+func (q Query) Tables() []string {
+	res := []string{}
+	seen := map[string]bool{}
+	q.collectTables(func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			res = append(res, name)
+		}
+	})
+	return res
+}
+
+func (q *Query) collectTables(add func(string)) {
+	add(q.TableName)
+	if q.Subquery != nil {
+		q.Subquery.collectTables(add)
+	}
+	for _, join := range q.Joins {
+		add(join.Table.Name)
+		if join.Table.Subquery != nil {
+			join.Table.Subquery.collectTables(add)
+		}
+	}
+	for _, ref := range q.From {
+		add(ref.Name)
+		if ref.Subquery != nil {
+			ref.Subquery.collectTables(add)
+		}
+	}
+	if q.Source != nil {
+		q.Source.collectTables(add)
+	}
+
+	visit := func(e Expr) bool {
+		switch e := e.(type) {
+		case *SubqueryExpr:
+			e.Query.collectTables(add)
+		case *ExistsExpr:
+			e.Query.collectTables(add)
+		case *InExpr:
+			if e.Subquery != nil {
+				e.Subquery.collectTables(add)
+			}
+		}
+		return true
+	}
+	for _, column := range q.Columns {
+		WalkExpr(column.Expr, visit)
+	}
+	for _, join := range q.Joins {
+		WalkExpr(join.On, visit)
+	}
+	WalkExpr(q.Where, visit)
+	for _, item := range q.GroupBy {
+		WalkExpr(item, visit)
+	}
+	WalkExpr(q.Having, visit)
+	for _, item := range q.OrderBy {
+		WalkExpr(item.Expr, visit)
+	}
+	for _, item := range q.Assignments {
+		WalkExpr(item.Value, visit)
+	}
+	for _, row := range q.Values {
+		for _, item := range row {
+			WalkExpr(item, visit)
+		}
+	}
+	for _, column := range q.Returning {
+		WalkExpr(column.Expr, visit)
+	}
+}
+
+// parseSelectColumn parses one item of the SELECT list, without its alias
+func (p *parser) parseSelectColumn() (SelectColumn, error) {
+	token := p.peekKeyword()
+	if token == "" && p.i < len(p.sql) && p.sql[p.i] == '\'' {
+		token = "'"
+	}
+	if token == "" || token == "," || token == "FROM" {
+		return SelectColumn{}, fmt.Errorf("at SELECT: expected field to SELECT")
+	}
+	if token == "*" {
+		p.pop()
+		return SelectColumn{Expr: &ColumnRef{Name: "*"}}, nil
+	}
+	p.clause = "SELECT"
+	e, err := p.parseOperand()
+	if err != nil {
+		return SelectColumn{}, err
+	}
+	return SelectColumn{Expr: e}, nil
+}
+
+// parseTableRef parses "table [[AS] alias]" or "(SELECT ...) [AS] alias"
+func (p *parser) parseTableRef() (TableRef, error) {
+	ref := TableRef{}
+	token, ln := p.peekWithLength()
+	switch {
+	case ln == 0:
+		return ref, fmt.Errorf("at %s: expected quoted table name", p.clause)
+	case p.peekSubquery():
+		sub, err := p.parseSubquery()
+		if err != nil {
+			return ref, err
+		}
+		ref.Subquery = sub
+	case p.sql[p.i] == '\'' || isIdentifier(token) || isQuotedIdentifier(token):
+		ref.Name = token
+		p.pop()
+	default:
+		return ref, fmt.Errorf("at %s: expected quoted table name", p.clause)
+	}
+
+	alias := p.peekKeyword()
+	if alias == "AS" {
+		p.pop()
+		if alias = p.peek(); !isIdentifier(alias) {
+			return ref, fmt.Errorf("at %s: expected table alias", p.clause)
+		}
+	} else if alias == "" || !isIdentifier(alias) {
+		return ref, nil
+	}
+	ref.Alias = p.pop()
+	return ref, nil
+}
+
+// parseSelectClause parses one clause after FROM: a JOIN, WHERE, GROUP BY, HAVING, ORDER BY, LIMIT or OFFSET
+func (p *parser) parseSelectClause() error {
+	token := p.peekKeyword()
+	rank, ok := selectClauseRank[token]
+	if _, isJoin := joinTypes[token]; isJoin {
+		rank, ok = joinClauseRank, true
+	}
+	if !ok || rank < p.clauseRank || (rank == p.clauseRank && rank != joinClauseRank) {
+		return fmt.Errorf("at %s: unexpected '%s'", p.clauseName(), p.peek())
+	}
+	p.clauseRank = rank
+	p.pop()
+
+	if joinType, ok := joinTypes[token]; ok {
+		return p.parseJoin(joinType)
+	}
+
+	p.clause = token
+	if p.i >= len(p.sql) {
+		return fmt.Errorf("at %s: empty %s clause", token, token)
+	}
+	var err error
+	switch token {
+	case "WHERE":
+		if p.query.Where, err = p.parseExpr(); err == nil {
+			p.query.Conditions, _ = p.query.FlatConditions()
+		}
+	case "GROUP BY":
+		for err == nil {
+			var item Expr
+			if item, err = p.parseOperand(); err == nil {
+				p.query.GroupBy = append(p.query.GroupBy, item)
+				if p.peek() != "," {
+					break
+				}
+				p.pop()
+			}
+		}
+	case "HAVING":
+		p.query.Having, err = p.parseExpr()
+	case "ORDER BY":
+		for err == nil {
+			var item Expr
+			if item, err = p.parseOperand(); err == nil {
+				order := OrderBy{Expr: item}
+				switch p.peekKeyword() {
+				case "DESC":
+					order.Desc = true
+					p.pop()
+				case "ASC":
+					p.pop()
+				}
+				p.query.OrderBy = append(p.query.OrderBy, order)
+				if p.peek() != "," {
+					break
+				}
+				p.pop()
+			}
+		}
+	case "LIMIT":
+		if p.query.Limit, err = p.parseOperand(); err == nil && p.peek() == "," {
+			// MySQL: LIMIT offset, count
+			p.pop()
+			p.query.Offset = p.query.Limit
+			p.query.Limit, err = p.parseOperand()
+			p.clauseRank = selectClauseRank["OFFSET"]
+		}
+	case "OFFSET":
+		p.query.Offset, err = p.parseOperand()
+	}
+	return err
+}
+
+func (p *parser) parseJoin(joinType JoinType) error {
+	p.clause = "JOIN"
+	ref, err := p.parseTableRef()
+	if err != nil {
+		return err
+	}
+	join := Join{Type: joinType, Table: ref}
+	if joinType != CrossJoin {
+		if p.peekKeyword() != "ON" {
+			return fmt.Errorf("at JOIN: expected ON")
+		}
+		p.pop()
+		if join.On, err = p.parseExpr(); err != nil {
+			return err
+		}
+	}
+	p.query.Joins = append(p.query.Joins, join)
+	return nil
+}
+
+// peekSubquery reports whether the next tokens are "(SELECT"
+func (p *parser) peekSubquery() bool {
+	if p.peek() != "(" {
+		return false
+	}
+	i := p.i
+	p.pop()
+	ok := p.peekKeyword() == "SELECT"
+	p.i = i
+	return ok
+}
+
+// parseSubquery parses "(SELECT ...)", the current token must be the opening parens
+func (p *parser) parseSubquery() (*Query, error) {
+	end := p.closingParens()
+	if end == -1 {
+		return nil, fmt.Errorf("at %s: expected closing parens", p.clauseName())
+	}
+	q, err := p.parseNested(p.i+1, end)
+	if err != nil {
+		return nil, err
+	}
+	p.i = end + 1
+	p.popWhitespace()
+	return q, nil
+}
+
+// closingParens returns the position of the parens closing the one at p.i, or -1
+func (p *parser) closingParens() int {
+	depth := 0
+	for i := p.i; i < len(p.sql); i++ {
+		switch ch := p.sql[i]; ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '\'', '"', '`':
+			for i++; i < len(p.sql) && (p.sql[i] != ch || ch == '\'' && p.sql[i-1] == '\\'); i++ {
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,153 @@
+package dbparser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectJoin(t *testing.T) {
+	q, err := Parse("SELECT u.name, o.total FROM users u LEFT JOIN orders AS o ON o.user_id = u.id JOIN items i ON i.order_id = o.id")
+	require.NoError(t, err)
+	require.Equal(t, "users", q.TableName)
+	require.Equal(t, "u", q.TableAlias)
+	require.Equal(t, []string{"u.name", "o.total"}, q.Fields)
+	require.Equal(t, []Join{
+		{
+			Type:  LeftJoin,
+			Table: TableRef{Name: "orders", Alias: "o"},
+			On:    &ComparisonExpr{Left: &ColumnRef{Table: "o", Name: "user_id"}, Operator: Eq, Right: &ColumnRef{Table: "u", Name: "id"}},
+		},
+		{
+			Type:  InnerJoin,
+			Table: TableRef{Name: "items", Alias: "i"},
+			On:    &ComparisonExpr{Left: &ColumnRef{Table: "i", Name: "order_id"}, Operator: Eq, Right: &ColumnRef{Table: "o", Name: "id"}},
+		},
+	}, q.Joins)
+
+	q, err = Parse("SELECT * FROM a, b CROSS JOIN c RIGHT OUTER JOIN d ON d.id = a.id")
+	require.NoError(t, err)
+	require.Len(t, q.Joins, 3)
+	require.Equal(t, CrossJoin, q.Joins[0].Type)
+	require.Equal(t, CrossJoin, q.Joins[1].Type)
+	require.Nil(t, q.Joins[1].On)
+	require.Equal(t, RightJoin, q.Joins[2].Type)
+}
+
+func TestSelectClauses(t *testing.T) {
+	q, err := Parse(`SELECT DISTINCT dept, COUNT(*) AS total, MAX(salary) top
+		FROM employees
+		WHERE active = TRUE
+		GROUP BY dept
+		HAVING COUNT(*) > 10
+		ORDER BY total DESC, dept
+		LIMIT 20 OFFSET 40`)
+	require.NoError(t, err)
+	require.True(t, q.Distinct)
+	require.Equal(t, []string{"dept", "COUNT(*)", "MAX(salary)"}, q.Fields)
+	require.Equal(t, map[string]string{"COUNT(*)": "total", "MAX(salary)": "top"}, q.Aliases)
+	require.Equal(t, []Condition{{Operand1: "active", Operand1IsField: true, Operator: Eq, Operand2: "TRUE"}}, q.Conditions)
+	require.Equal(t, []Expr{&ColumnRef{Name: "dept"}}, q.GroupBy)
+	require.Equal(t, "COUNT(*) > 10", q.Having.String())
+	require.Equal(t, []OrderBy{
+		{Expr: &ColumnRef{Name: "total"}, Desc: true},
+		{Expr: &ColumnRef{Name: "dept"}},
+	}, q.OrderBy)
+	require.Equal(t, &Literal{Kind: NumberLiteral, Value: "20"}, q.Limit)
+	require.Equal(t, &Literal{Kind: NumberLiteral, Value: "40"}, q.Offset)
+
+	// MySQL: LIMIT offset, count
+	q, err = Parse("SELECT a FROM b LIMIT 5, 10")
+	require.NoError(t, err)
+	require.Equal(t, "10", q.Limit.String())
+	require.Equal(t, "5", q.Offset.String())
+}
+
+func TestSelectSubquery(t *testing.T) {
+	q, err := Parse("SELECT t.n FROM (SELECT COUNT(DISTINCT user_id) AS n FROM orders) t")
+	require.NoError(t, err)
+	require.Equal(t, "", q.TableName)
+	require.Equal(t, "t", q.TableAlias)
+	require.NotNil(t, q.Subquery)
+	require.Equal(t, "orders", q.Subquery.TableName)
+	require.Equal(t, []string{"COUNT(DISTINCT user_id)"}, q.Subquery.Fields)
+
+	q, err = Parse("SELECT name FROM users WHERE id IN (SELECT user_id FROM orders WHERE total > 100) AND EXISTS (SELECT 1 FROM bans WHERE bans.user_id = users.id)")
+	require.NoError(t, err)
+	require.Equal(t, "name", q.Fields[0])
+	require.Equal(t, "id IN (SELECT user_id FROM orders WHERE total > 100) AND EXISTS (SELECT 1 FROM bans WHERE bans.user_id = users.id)", q.Where.String())
+	require.Nil(t, q.Conditions)
+}
+
+func TestQueryTables(t *testing.T) {
+	q, err := Parse(`SELECT u.name, (SELECT MAX(total) FROM orders o WHERE o.user_id = u.id) AS max_total
+		FROM users u
+		JOIN (SELECT user_id FROM profiles) p ON p.user_id = u.id
+		LEFT JOIN users m ON m.id = u.manager_id
+		WHERE u.id IN (SELECT user_id FROM admins) OR EXISTS (SELECT 1 FROM bans WHERE bans.user_id = u.id)`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"users", "profiles", "orders", "admins", "bans"}, q.Tables())
+
+	q, err = Parse("DELETE FROM 'a' WHERE b = 1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, q.Tables())
+}
+
+func TestQueryString(t *testing.T) {
+	ts := []struct {
+		SQL      string
+		Expected string
+	}{
+		{
+			SQL:      "select distinct a as x, count(*) from t left outer join u on u.id = t.id where a = 1 group by a having count(*) > 1 order by a desc limit 1 offset 2",
+			Expected: "SELECT DISTINCT a AS x, count(*) FROM t LEFT JOIN u ON u.id = t.id WHERE a = 1 GROUP BY a HAVING count(*) > 1 ORDER BY a DESC LIMIT 1 OFFSET 2",
+		},
+		{
+			SQL:      "SELECT a FROM (SELECT a FROM b) s",
+			Expected: "SELECT a FROM (SELECT a FROM b) s",
+		},
+		{
+			SQL:      "UPDATE 'a' SET c = 'it''s', b = 'x' WHERE id = 1",
+			Expected: "UPDATE a SET c = 'it''s', b = 'x' WHERE id = 1",
+		},
+		{
+			SQL:      "INSERT INTO 'a' (b, c) VALUES ('1', '2'), ('3', '4')",
+			Expected: "INSERT INTO a (b, c) VALUES ('1', '2'), ('3', '4')",
+		},
+		{
+			SQL:      "DELETE FROM 'a' WHERE b IS NULL",
+			Expected: "DELETE FROM a WHERE b IS NULL",
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			q, err := Parse(tc.SQL)
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, q.String())
+		})
+	}
+}
+
+func TestSelectClauseErrors(t *testing.T) {
+	ts := []struct {
+		SQL string
+		Err error
+	}{
+		{SQL: "SELECT a FROM b ORDER BY a WHERE a = 1", Err: fmt.Errorf("at ORDER BY: unexpected 'WHERE'")},
+		{SQL: "SELECT a FROM b LIMIT 1 LIMIT 2", Err: fmt.Errorf("at LIMIT: unexpected 'LIMIT'")},
+		{SQL: "SELECT a FROM b WHERE a = 1 JOIN c ON c.a = b.a", Err: fmt.Errorf("at WHERE: unexpected 'JOIN'")},
+		{SQL: "SELECT a FROM b JOIN c", Err: fmt.Errorf("at JOIN: expected ON")},
+		{SQL: "SELECT a FROM b JOIN ON a = 1", Err: fmt.Errorf("at JOIN: expected quoted table name")},
+		{SQL: "SELECT a FROM b GROUP BY", Err: fmt.Errorf("at GROUP BY: empty GROUP BY clause")},
+		{SQL: "SELECT a FROM b c d", Err: fmt.Errorf("at SELECT: unexpected 'd'")},
+		{SQL: "SELECT a FROM (SELECT a FROM b", Err: fmt.Errorf("at SELECT: expected closing parens")},
+		{SQL: "SELECT a FROM b WHERE a IN (SELECT FROM c)", Err: fmt.Errorf("at SELECT: expected field to SELECT")},
+	}
+	for _, tc := range ts {
+		t.Run(tc.SQL, func(t *testing.T) {
+			_, err := Parse(tc.SQL)
+			require.Equal(t, tc.Err.Error(), errorMsg(err))
+		})
+	}
+}
@@ -11,7 +11,9 @@ type testCase struct {
 	Name     string
 	SQL      string
 	Expected Query
-	Err      error
+	// Where is the expected Query.Where in its String form
+	Where string
+	Err   error
 }
 
 type output struct {
@@ -50,19 +52,19 @@ func TestSQL(t *testing.T) {
 		{
 			Name:     "SELECT works",
 			SQL:      "SELECT a FROM 'b'",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}},
+			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}, Columns: cols("a")},
 			Err:      nil,
 		},
 		{
 			Name:     "SELECT works with lowercase",
 			SQL:      "select a fRoM 'b'",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}},
+			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a"}, Columns: cols("a")},
 			Err:      nil,
 		},
 		{
 			Name:     "SELECT many fields works",
 			SQL:      "SELECT a, c, d FROM 'b'",
-			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}},
+			Expected: Query{Type: Select, TableName: "b", Fields: []string{"a", "c", "d"}, Columns: cols("a", "c", "d")},
 			Err:      nil,
 		},
 		{
@@ -72,6 +74,11 @@ func TestSQL(t *testing.T) {
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "b", "c"},
+				Columns: []SelectColumn{
+					{Expr: &ColumnRef{Name: "a"}, Alias: "z"},
+					{Expr: &ColumnRef{Name: "b"}, Alias: "y"},
+					{Expr: &ColumnRef{Name: "c"}},
+				},
 				Aliases: map[string]string{
 					"a": "z",
 					"b": "y",
@@ -93,12 +100,14 @@ func TestSQL(t *testing.T) {
 			Err:      fmt.Errorf("at WHERE: condition without operator"),
 		},
 		{
-			Name: "SELECT with WHERE with = works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a = ''",
+			Name:  "SELECT with WHERE with = works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a = ''",
+			Where: "a = ''",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "", Operand2IsField: false},
 				},
@@ -106,12 +115,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with < works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a < '1'",
+			Name:  "SELECT with WHERE with < works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a < '1'",
+			Where: "a < '1'",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Lt, Operand2: "1", Operand2IsField: false},
 				},
@@ -119,12 +130,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with <= works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a <= '1'",
+			Name:  "SELECT with WHERE with <= works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a <= '1'",
+			Where: "a <= '1'",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Lte, Operand2: "1", Operand2IsField: false},
 				},
@@ -132,12 +145,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with > works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a > '1'",
+			Name:  "SELECT with WHERE with > works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a > '1'",
+			Where: "a > '1'",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Gt, Operand2: "1", Operand2IsField: false},
 				},
@@ -145,12 +160,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with >= works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a >= '1'",
+			Name:  "SELECT with WHERE with >= works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a >= '1'",
+			Where: "a >= '1'",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Gte, Operand2: "1", Operand2IsField: false},
 				},
@@ -158,12 +175,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with != works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a != '1'",
+			Name:  "SELECT with WHERE with != works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a != '1'",
+			Where: "a != '1'",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "1", Operand2IsField: false},
 				},
@@ -171,12 +190,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with != works (comparing field against another field)",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a != b",
+			Name:  "SELECT with WHERE with != works (comparing field against another field)",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a != b",
+			Where: "a != b",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "b", Operand2IsField: true},
 				},
@@ -190,6 +211,7 @@ func TestSQL(t *testing.T) {
 				Type:       Select,
 				TableName:  "b",
 				Fields:     []string{"*"},
+				Columns:    cols("*"),
 				Conditions: nil,
 			},
 			Err: nil,
@@ -201,17 +223,20 @@ func TestSQL(t *testing.T) {
 				Type:       Select,
 				TableName:  "b",
 				Fields:     []string{"a", "*"},
+				Columns:    cols("a", "*"),
 				Conditions: nil,
 			},
 			Err: nil,
 		},
 		{
-			Name: "SELECT with WHERE with two conditions using AND works",
-			SQL:  "SELECT a, c, d FROM 'b' WHERE a != '1' AND b = '2'",
+			Name:  "SELECT with WHERE with two conditions using AND works",
+			SQL:   "SELECT a, c, d FROM 'b' WHERE a != '1' AND b = '2'",
+			Where: "a != '1' AND b = '2'",
 			Expected: Query{
 				Type:      Select,
 				TableName: "b",
 				Fields:    []string{"a", "c", "d"},
+				Columns:   cols("a", "c", "d"),
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Ne, Operand2: "1", Operand2IsField: false},
 					{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "2", Operand2IsField: false},
@@ -247,7 +272,7 @@ func TestSQL(t *testing.T) {
 			Name:     "Incomplete UPDATE with table name, SET with a field and = but no value and WHERE fails",
 			SQL:      "UPDATE 'a' SET b = WHERE",
 			Expected: Query{},
-			Err:      fmt.Errorf("at UPDATE: expected quoted value"),
+			Err:      fmt.Errorf("at UPDATE: expected value"),
 		},
 		{
 			Name:     "Incomplete UPDATE due to no WHERE clause fails",
@@ -262,12 +287,14 @@ func TestSQL(t *testing.T) {
 			Err:      fmt.Errorf("at WHERE: condition without operator"),
 		},
 		{
-			Name: "UPDATE works",
-			SQL:  "UPDATE 'a' SET b = 'hello' WHERE a = '1'",
+			Name:  "UPDATE works",
+			SQL:   "UPDATE 'a' SET b = 'hello' WHERE a = '1'",
+			Where: "a = '1'",
 			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello"},
+				Type:        Update,
+				TableName:   "a",
+				Updates:     map[string]string{"b": "hello"},
+				Assignments: []Assignment{{Field: "b", Value: strs("hello")[0]}},
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 				},
@@ -275,12 +302,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "UPDATE works with simple quote inside",
-			SQL:  "UPDATE 'a' SET b = 'hello\\'world' WHERE a = '1'",
+			Name:  "UPDATE works with simple quote inside",
+			SQL:   "UPDATE 'a' SET b = 'hello\\'world' WHERE a = '1'",
+			Where: "a = '1'",
 			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello\\'world"},
+				Type:        Update,
+				TableName:   "a",
+				Updates:     map[string]string{"b": "hello'world"},
+				Assignments: []Assignment{{Field: "b", Value: strs("hello'world")[0]}},
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 				},
@@ -288,12 +317,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "UPDATE with multiple SETs works",
-			SQL:  "UPDATE 'a' SET b = 'hello', c = 'bye' WHERE a = '1'",
+			Name:  "UPDATE with multiple SETs works",
+			SQL:   "UPDATE 'a' SET b = 'hello', c = 'bye' WHERE a = '1'",
+			Where: "a = '1'",
 			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello", "c": "bye"},
+				Type:        Update,
+				TableName:   "a",
+				Updates:     map[string]string{"b": "hello", "c": "bye"},
+				Assignments: []Assignment{{Field: "b", Value: strs("hello")[0]}, {Field: "c", Value: strs("bye")[0]}},
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 				},
@@ -301,12 +332,14 @@ func TestSQL(t *testing.T) {
 			Err: nil,
 		},
 		{
-			Name: "UPDATE with multiple SETs and multiple conditions works",
-			SQL:  "UPDATE 'a' SET b = 'hello', c = 'bye' WHERE a = '1' AND b = '789'",
+			Name:  "UPDATE with multiple SETs and multiple conditions works",
+			SQL:   "UPDATE 'a' SET b = 'hello', c = 'bye' WHERE a = '1' AND b = '789'",
+			Where: "a = '1' AND b = '789'",
 			Expected: Query{
-				Type:      Update,
-				TableName: "a",
-				Updates:   map[string]string{"b": "hello", "c": "bye"},
+				Type:        Update,
+				TableName:   "a",
+				Updates:     map[string]string{"b": "hello", "c": "bye"},
+				Assignments: []Assignment{{Field: "b", Value: strs("hello")[0]}, {Field: "c", Value: strs("bye")[0]}},
 				Conditions: []Condition{
 					{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "1", Operand2IsField: false},
 					{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "789", Operand2IsField: false},
@@ -339,8 +372,9 @@ func TestSQL(t *testing.T) {
 			Err:      fmt.Errorf("at WHERE: condition without operator"),
 		},
 		{
-			Name: "DELETE with WHERE works",
-			SQL:  "DELETE FROM 'a' WHERE b = '1'",
+			Name:  "DELETE with WHERE works",
+			SQL:   "DELETE FROM 'a' WHERE b = '1'",
+			Where: "b = '1'",
 			Expected: Query{
 				Type:      Delete,
 				TableName: "a",
@@ -400,6 +434,7 @@ func TestSQL(t *testing.T) {
 				TableName: "a",
 				Fields:    []string{"b"},
 				Inserts:   [][]string{{"1"}},
+				Values:    [][]Expr{strs("1")},
 			},
 			Err: nil,
 		},
@@ -417,6 +452,7 @@ func TestSQL(t *testing.T) {
 				TableName: "a",
 				Fields:    []string{"b", "c", "d"},
 				Inserts:   [][]string{{"1", "2", "3"}},
+				Values:    [][]Expr{strs("1", "2", "3")},
 			},
 			Err: nil,
 		},
@@ -428,6 +464,7 @@ func TestSQL(t *testing.T) {
 				TableName: "a",
 				Fields:    []string{"b", "c", "d"},
 				Inserts:   [][]string{{"1", "2", "3"}, {"4", "5", "6"}},
+				Values:    [][]Expr{strs("1", "2", "3"), strs("4", "5", "6")},
 			},
 			Err: nil,
 		},
@@ -444,9 +481,18 @@ func TestSQL(t *testing.T) {
 				t.Errorf("Error should have been nil but was %v", err)
 			}
 			if tc.Err != nil && err != nil {
-				require.Equal(t, tc.Err, err, "Unexpected error")
+				require.Equal(t, tc.Err.Error(), errorMsg(err), "Unexpected error")
 			}
-			if len(actual) > 0 {
+			require.Len(t, actual, 1)
+			if tc.Err != nil {
+				// a failed query is the zero Query at its index
+				require.Equal(t, Query{}, actual[0])
+			} else {
+				if tc.Where != "" {
+					require.NotNil(t, actual[0].Where)
+					require.Equal(t, tc.Where, actual[0].Where.String())
+					actual[0].Where = nil
+				}
 				require.Equal(t, tc.Expected, actual[0], "Query didn't match expectation")
 			}
 			if tc.Err != nil {
@@ -457,3 +503,21 @@ func TestSQL(t *testing.T) {
 		})
 	}
 }
+
+// cols builds the expected Columns of a SELECT with plain fields
+func cols(names ...string) []SelectColumn {
+	res := make([]SelectColumn, 0, len(names))
+	for _, name := range names {
+		res = append(res, SelectColumn{Expr: newColumnRef(name)})
+	}
+	return res
+}
+
+// strs builds the expected string literals of INSERT values
+func strs(values ...string) []Expr {
+	res := make([]Expr, 0, len(values))
+	for _, value := range values {
+		res = append(res, &Literal{Kind: StringLiteral, Value: value})
+	}
+	return res
+}
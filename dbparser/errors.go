@@ -0,0 +1,149 @@
+package dbparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Pos is a position in the source, Line and Column are 1-based and Column counts bytes
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the part [Start, End) of the source of a token or a node
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+// SyntaxError is a parse error at a position of the source
+type SyntaxError struct {
+	Pos Pos
+	Msg string
+	// Statement is the 0-based index of the statement in the source, or in the slice of ParseMany
+	Statement int
+	// Line is the source line of Pos, empty when the parser has no source
+	Line string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Snippet returns the line of the error and a caret under its column
+func (e *SyntaxError) Snippet() string {
+	if e.Line == "" {
+		return ""
+	}
+	// keep tabs so that the caret lines up
+	indent := []byte(e.Line[:min(e.Pos.Column-1, len(e.Line))])
+	for i, ch := range indent {
+		if ch != '\t' {
+			indent[i] = ' '
+		}
+	}
+	return e.Line + "\n" + string(indent) + "^"
+}
+
+// ErrorList is the errors of all bad statements of a source, in order
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Unwrap returns the errors for errors.Is and errors.As
+func (l ErrorList) Unwrap() []error {
+	res := make([]error, len(l))
+	for i, err := range l {
+		res[i] = err
+	}
+	return res
+}
+
+// err returns nil for an empty list
+func (l ErrorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// FormatError formats each syntax error of err as "line:col: message" followed by its snippet
+func FormatError(err error) string {
+	var list ErrorList
+	var syntaxErr *SyntaxError
+	switch {
+	case errors.As(err, &list):
+	case errors.As(err, &syntaxErr):
+		list = ErrorList{syntaxErr}
+	default:
+		return err.Error()
+	}
+	res := []string{}
+	for _, e := range list {
+		item := e.Error()
+		if snippet := e.Snippet(); snippet != "" {
+			item += "\n" + snippet
+		}
+		res = append(res, item)
+	}
+	return strings.Join(res, "\n")
+}
+
+// lineIndex converts offsets of a source to positions
+type lineIndex struct {
+	src    string
+	starts []int
+}
+
+func newLineIndex(src string) *lineIndex {
+	x := &lineIndex{src: src, starts: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			x.starts = append(x.starts, i+1)
+		}
+	}
+	return x
+}
+
+func (x *lineIndex) pos(offset int) Pos {
+	offset = max(0, min(offset, len(x.src)))
+	line := len(x.starts) - 1
+	for line > 0 && x.starts[line] > offset {
+		line--
+	}
+	return Pos{Offset: offset, Line: line + 1, Column: offset - x.starts[line] + 1}
+}
+
+func (x *lineIndex) span(start, end int) Span {
+	return Span{Start: x.pos(start), End: x.pos(end)}
+}
+
+// errorAt returns a SyntaxError at pos with its source line
+func (x *lineIndex) errorAt(pos Pos, msg string) *SyntaxError {
+	err := &SyntaxError{Pos: pos, Msg: msg}
+	if x != nil && pos.Line > 0 {
+		start := x.starts[pos.Line-1]
+		end := strings.IndexByte(x.src[start:], '\n')
+		if end == -1 {
+			end = len(x.src) - start
+		}
+		err.Line = strings.TrimRight(x.src[start:start+end], "\r")
+	}
+	return err
+}
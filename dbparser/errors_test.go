@@ -0,0 +1,84 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// errorMsg returns the message of a syntax error without its position
+func errorMsg(err error) string {
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Msg
+	}
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestParseStatementsRecover(t *testing.T) {
+	sql := `CREATE TABLE users (
+	id INT PRIMARY KEY,
+	name VARCHAR(20)
+);
+CREATE TABLE bad (
+	id INT,
+	(name)
+);
+CREATE INDEX idx_name ON users (name);
+ALTER TABLE users FROB name;
+DROP TABLE orders`
+	stmts, err := NewLexer(sql).Parser().ParseStatements()
+	require.Len(t, stmts, 3)
+
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	require.Len(t, list, 2)
+	require.Equal(t, "7:2: expected column name, got (", list[0].Error())
+	require.Equal(t, 1, list[0].Statement)
+	require.Equal(t, "\t(name)\n\t^", list[0].Snippet())
+	require.Equal(t, "10:19: expected ADD, DROP, MODIFY, CHANGE, ALTER or RENAME, got FROB", list[1].Error())
+	require.Equal(t, 3, list[1].Statement)
+	require.Equal(t, "7:2: expected column name, got (\n\t(name)\n\t^\n"+
+		"10:19: expected ADD, DROP, MODIFY, CHANGE, ALTER or RENAME, got FROB\n"+
+		"ALTER TABLE users FROB name;\n                  ^", FormatError(err))
+
+	create := stmts[0].(*CreateTable)
+	require.Equal(t, Span{Start: Pos{Offset: 0, Line: 1, Column: 1}, End: Pos{Offset: 61, Line: 4, Column: 2}}, StatementSpan(create))
+	require.Equal(t, Span{Start: Pos{Offset: 43, Line: 3, Column: 2}, End: Pos{Offset: 59, Line: 3, Column: 18}}, create.Table.Columns[1].Span)
+	require.Equal(t, Pos{Offset: 170, Line: 11, Column: 1}, StatementSpan(stmts[2]).Start)
+}
+
+func TestParseStatementsEndOfInput(t *testing.T) {
+	_, err := NewLexer("\n\nCREATE INDEX idx ON").Parser().ParseStatements()
+	var syntaxErr *SyntaxError
+	require.True(t, errors.As(err, &syntaxErr))
+	require.Equal(t, "3:20: expected table name, got end of input", syntaxErr.Error())
+}
+
+func TestParseManyErrors(t *testing.T) {
+	qs, err := ParseMany([]string{
+		"SELECT a FROM 'b'",
+		"SELECT a FROM 'b' WHERE a IN 1",
+		"  SELECT a\nFROM 'b'\nWHERE a IN (SELECT FROM c)",
+	})
+	// the queries stay at the index of their SQL
+	require.Len(t, qs, 3)
+	require.Equal(t, "b", qs[0].TableName)
+	require.Equal(t, Query{}, qs[1])
+	require.Equal(t, Query{}, qs[2])
+
+	var list ErrorList
+	require.True(t, errors.As(err, &list))
+	require.Len(t, list, 2)
+	require.Equal(t, "1:30: at WHERE: expected opening parens after IN", list[0].Error())
+	require.Equal(t, 1, list[0].Statement)
+	// the position of an error of a subquery is in the whole source
+	require.Equal(t, "3:20: at SELECT: expected field to SELECT", list[1].Error())
+	require.Equal(t, 2, list[1].Statement)
+	require.Equal(t, "WHERE a IN (SELECT FROM c)\n                   ^", list[1].Snippet())
+	require.Equal(t, "1:30: at WHERE: expected opening parens after IN (and 1 more errors)", err.Error())
+}
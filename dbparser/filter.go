@@ -0,0 +1,484 @@
+package dbparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrUnknownField  = errors.New("unknown field")
+)
+
+// Fields maps the field names clients may filter and sort on to their columns
+type Fields map[string]string
+
+// FieldsFromTable allows every column of the table
+func FieldsFromTable(t Table) Fields {
+	fields := Fields{}
+	for _, column := range t.Columns {
+		name := strings.Trim(column.Name, "`\"'")
+		fields[name] = name
+	}
+	return fields
+}
+
+// FieldsFromStruct allows the exported fields of a gorm model: the name is the json tag (or the field name)
+// and the column is the gorm column tag (or the field name, like GenCreateTableStatement).
+// Fields tagged json:"-" or gorm:"-" are left out.
+func FieldsFromStruct(model interface{}) (Fields, error) {
+	t := reflect.TypeOf(model)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("model is not a struct")
+	}
+	fields := Fields{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		options := parseTagOptions(field.Tag.Get("gorm"))
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if _, ok := options["miss"]; ok || jsonName == "-" {
+			continue
+		}
+		column := options["column"]
+		if column == "" {
+			column = field.Name
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		fields[jsonName] = column
+	}
+	return fields, nil
+}
+
+// Filter is a validated filter of a list: WHERE with placeholders, ORDER BY and pagination
+type Filter struct {
+	Where   Expr
+	OrderBy []OrderBy
+	// Limit and Offset are 0 when not set
+	Limit  int
+	Offset int
+	// Args are the values of the placeholders of Where in order
+	Args []interface{}
+}
+
+// Select returns "SELECT * FROM table" with the filter
+func (f *Filter) Select(table string) Query {
+	q := Query{Type: Select, TableName: table, Fields: []string{"*"}, Where: f.Where, OrderBy: f.OrderBy}
+	if f.Limit > 0 {
+		q.Limit = &Literal{Kind: NumberLiteral, Value: strconv.Itoa(f.Limit)}
+	}
+	if f.Offset > 0 {
+		q.Offset = &Literal{Kind: NumberLiteral, Value: strconv.Itoa(f.Offset)}
+	}
+	return q
+}
+
+// Filter renders the WHERE, ORDER BY, LIMIT and OFFSET clauses of f, e.g.
+// "WHERE age > $1 ORDER BY created DESC LIMIT 20", to append to a SELECT.
+// The values of the placeholders are f.Args.
+func (pr *Printer) Filter(f *Filter) string {
+	w := pr.newWriter()
+	q := f.Select("")
+	w.where(&q)
+	w.orderLimit(&q)
+	return strings.TrimPrefix(w.String(), " ")
+}
+
+// FilterBuilder builds filters from client input, only on its Fields
+type FilterBuilder struct {
+	Fields Fields
+	// DefaultLimit is the limit without "$limit" / "limit", 0 for none
+	DefaultLimit int
+	// MaxLimit caps the limit, 0 for no cap
+	MaxLimit int
+}
+
+// NewFilterBuilder returns a FilterBuilder with pages of 20 rows and at most 100
+func NewFilterBuilder(fields Fields) *FilterBuilder {
+	return &FilterBuilder{Fields: fields, DefaultLimit: 20, MaxLimit: 100}
+}
+
+// filterOperators are the operators of a field, "$op" in JSON and "field__op" in a query string
+var filterOperators = map[string]Operator{
+	"eq":    Eq,
+	"ne":    Ne,
+	"gt":    Gt,
+	"gte":   Gte,
+	"lt":    Lt,
+	"lte":   Lte,
+	"like":  Like,
+	"ilike": ILike,
+}
+
+type filterBuild struct {
+	b      *FilterBuilder
+	filter *Filter
+}
+
+// FromJSON builds a filter from a JSON object such as
+//
+//	{"age": {"$gt": 18}, "name": {"$like": "a%"}, "$or": [{"role": "admin"}, {"vip": true}],
+//	 "$sort": "-created,name", "$limit": 10, "$page": 2}
+//
+// A field value is compared with "=", null is IS NULL, an object holds operators: $eq, $ne, $gt, $gte,
+// $lt, $lte, $like, $ilike, $in, $nin, $between ([low, high]) and $null (true / false).
+// "$and" / "$or" take a list of objects and "$not" an object. "$offset" may replace "$page".
+func (b *FilterBuilder) FromJSON(data []byte) (*Filter, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	obj := map[string]interface{}{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, errors.Wrap(ErrInvalidFilter, err.Error())
+	}
+
+	fb := &filterBuild{b: b, filter: &Filter{}}
+	page := map[string]string{}
+	for _, key := range []string{"$sort", "$limit", "$page", "$offset"} {
+		value, ok := obj[key]
+		if !ok {
+			continue
+		}
+		delete(obj, key)
+		switch value := value.(type) {
+		case string:
+			page[key[1:]] = value
+		case json.Number:
+			page[key[1:]] = value.String()
+		case []interface{}:
+			// "$sort": ["-created", "name"]
+			items := []string{}
+			for _, item := range value {
+				s, ok := item.(string)
+				if !ok {
+					return nil, errors.Wrapf(ErrInvalidFilter, "%s: expected strings", key)
+				}
+				items = append(items, s)
+			}
+			page[key[1:]] = strings.Join(items, ",")
+		default:
+			return nil, errors.Wrapf(ErrInvalidFilter, "%s: expected a string or a number", key)
+		}
+	}
+
+	where, err := fb.object(obj)
+	if err != nil {
+		return nil, err
+	}
+	fb.filter.Where = where
+	if err := fb.page(page); err != nil {
+		return nil, err
+	}
+	return fb.filter, nil
+}
+
+// FromValues builds a filter from a query string such as
+//
+//	?age__gt=18&name__like=a%25&status__in=new,paid&deleted_at__null=true&sort=-created&page=2&limit=10
+//
+// "field=value" is "=", a repeated field is IN. The operators are eq, ne, gt, gte, lt, lte,
+// like, ilike, in, nin, between (low,high) and null (true / false). "offset" may replace "page".
+func (b *FilterBuilder) FromValues(values url.Values) (*Filter, error) {
+	fb := &filterBuild{b: b, filter: &Filter{}}
+	page := map[string]string{}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var where Expr
+	for _, key := range keys {
+		items := values[key]
+		switch key {
+		case "sort", "limit", "page", "offset":
+			page[key] = strings.Join(items, ",")
+			continue
+		}
+		field, op, _ := strings.Cut(key, "__")
+		if op == "" {
+			op = "eq"
+			if len(items) > 1 {
+				op = "in"
+			}
+		}
+		var value interface{} = items[len(items)-1]
+		switch op {
+		case "in", "nin", "between":
+			list := []interface{}{}
+			for _, item := range items {
+				for _, part := range strings.Split(item, ",") {
+					list = append(list, part)
+				}
+			}
+			value = list
+		case "null":
+			null, err := strconv.ParseBool(items[len(items)-1])
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidFilter, "%s: expected true or false", key)
+			}
+			value = null
+		}
+		e, err := fb.condition(field, "$"+op, value)
+		if err != nil {
+			return nil, err
+		}
+		where = and(where, e)
+	}
+	fb.filter.Where = where
+	if err := fb.page(page); err != nil {
+		return nil, err
+	}
+	return fb.filter, nil
+}
+
+// object builds the conditions of a JSON object, joined by AND in the order of the keys
+func (fb *filterBuild) object(obj map[string]interface{}) (Expr, error) {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var res Expr
+	for _, key := range keys {
+		var e Expr
+		var err error
+		switch value := obj[key]; key {
+		case "$and", "$or":
+			e, err = fb.list(key, value)
+		case "$not":
+			sub, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.Wrapf(ErrInvalidFilter, "$not: expected an object")
+			}
+			if e, err = fb.object(sub); e != nil {
+				e = &NotExpr{Expr: &ParenExpr{Expr: e}}
+			}
+		default:
+			e, err = fb.field(key, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = and(res, e)
+	}
+	return res, nil
+}
+
+func (fb *filterBuild) list(key string, value interface{}) (Expr, error) {
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, errors.Wrapf(ErrInvalidFilter, "%s: expected a list of objects", key)
+	}
+	var res Expr
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidFilter, "%s: expected a list of objects", key)
+		}
+		e, err := fb.object(obj)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			continue
+		}
+		if _, ok := e.(*LogicalExpr); ok {
+			e = &ParenExpr{Expr: e}
+		}
+		if res == nil {
+			res = e
+		} else if key == "$or" {
+			res = &LogicalExpr{Operator: Or, Left: res, Right: e}
+		} else {
+			res = &LogicalExpr{Operator: And, Left: res, Right: e}
+		}
+	}
+	if key == "$or" && res != nil {
+		res = &ParenExpr{Expr: res}
+	}
+	return res, nil
+}
+
+// field builds the conditions of one field: a value or an object of operators
+func (fb *filterBuild) field(name string, value interface{}) (Expr, error) {
+	ops, ok := value.(map[string]interface{})
+	if !ok {
+		return fb.condition(name, "$eq", value)
+	}
+	keys := make([]string, 0, len(ops))
+	for key := range ops {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var res Expr
+	for _, op := range keys {
+		e, err := fb.condition(name, op, ops[op])
+		if err != nil {
+			return nil, err
+		}
+		res = and(res, e)
+	}
+	return res, nil
+}
+
+func (fb *filterBuild) condition(name, op string, value interface{}) (Expr, error) {
+	column, err := fb.column(name)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "$null":
+		null, ok := value.(bool)
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidFilter, "%s: $null expects true or false", name)
+		}
+		return &IsNullExpr{Expr: column, Not: !null}, nil
+	case "$in", "$nin":
+		items, ok := value.([]interface{})
+		if !ok || len(items) == 0 {
+			return nil, errors.Wrapf(ErrInvalidFilter, "%s: %s expects a non empty list", name, op)
+		}
+		e := &InExpr{Expr: column, Not: op == "$nin"}
+		for _, item := range items {
+			arg, err := fb.arg(name, item)
+			if err != nil {
+				return nil, err
+			}
+			e.List = append(e.List, arg)
+		}
+		return e, nil
+	case "$between":
+		items, ok := value.([]interface{})
+		if !ok || len(items) != 2 {
+			return nil, errors.Wrapf(ErrInvalidFilter, "%s: $between expects [low, high]", name)
+		}
+		low, err := fb.arg(name, items[0])
+		if err != nil {
+			return nil, err
+		}
+		high, err := fb.arg(name, items[1])
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Expr: column, Low: low, High: high}, nil
+	}
+
+	operator, ok := filterOperators[strings.TrimPrefix(op, "$")]
+	if !ok || !strings.HasPrefix(op, "$") {
+		return nil, errors.Wrapf(ErrInvalidFilter, "%s: unknown operator %s", name, op)
+	}
+	if value == nil && (operator == Eq || operator == Ne) {
+		return &IsNullExpr{Expr: column, Not: operator == Ne}, nil
+	}
+	arg, err := fb.arg(name, value)
+	if err != nil {
+		return nil, err
+	}
+	return &ComparisonExpr{Left: column, Operator: operator, Right: arg}, nil
+}
+
+func (fb *filterBuild) column(name string) (*ColumnRef, error) {
+	column, ok := fb.b.Fields[name]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownField, name)
+	}
+	return &ColumnRef{Name: column}, nil
+}
+
+// arg adds a value to the args and returns its placeholder
+func (fb *filterBuild) arg(name string, value interface{}) (*Placeholder, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			value = i
+		} else if value, err = v.Float64(); err != nil {
+			return nil, errors.Wrapf(ErrInvalidFilter, "%s: invalid number %s", name, v)
+		}
+	case string, bool:
+	default:
+		return nil, errors.Wrapf(ErrInvalidFilter, "%s: expected a string, a number or a boolean", name)
+	}
+	fb.filter.Args = append(fb.filter.Args, value)
+	return &Placeholder{Style: QuestionPlaceholder, Index: len(fb.filter.Args)}, nil
+}
+
+// page sets ORDER BY and the pagination from sort, limit, page and offset
+func (fb *filterBuild) page(page map[string]string) error {
+	if s := page["sort"]; s != "" {
+		for _, item := range strings.Split(s, ",") {
+			item = strings.TrimSpace(item)
+			order := OrderBy{}
+			if strings.HasPrefix(item, "-") {
+				order.Desc = true
+				item = item[1:]
+			}
+			column, err := fb.column(strings.TrimPrefix(item, "+"))
+			if err != nil {
+				return err
+			}
+			order.Expr = column
+			fb.filter.OrderBy = append(fb.filter.OrderBy, order)
+		}
+	}
+
+	number := func(key string) (int, error) {
+		if page[key] == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(page[key])
+		if err != nil || n < 0 {
+			return 0, errors.Wrapf(ErrInvalidFilter, "%s: expected a positive integer", key)
+		}
+		return n, nil
+	}
+	limit, err := number("limit")
+	if err != nil {
+		return err
+	}
+	if limit == 0 {
+		limit = fb.b.DefaultLimit
+	}
+	if fb.b.MaxLimit > 0 && (limit == 0 || limit > fb.b.MaxLimit) {
+		limit = fb.b.MaxLimit
+	}
+	fb.filter.Limit = limit
+
+	offset, err := number("offset")
+	if err != nil {
+		return err
+	}
+	n, err := number("page")
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		offset = (n - 1) * limit
+	}
+	fb.filter.Offset = offset
+	return nil
+}
+
+func and(left, right Expr) Expr {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	}
+	return &LogicalExpr{Operator: And, Left: left, Right: right}
+}
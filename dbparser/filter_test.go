@@ -0,0 +1,99 @@
+package dbparser
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type testFilterModel struct {
+	ID        uint   `gorm:"primaryKey;column:id" json:"id"`
+	Name      string `gorm:"column:name" json:"name"`
+	Age       int    `json:"age"`
+	CreatedAt string `gorm:"column:created_at" json:"created"`
+	Password  string `json:"-"`
+	secret    string
+}
+
+func testFilterBuilder(t *testing.T) *FilterBuilder {
+	fields, err := FieldsFromStruct(&testFilterModel{})
+	require.NoError(t, err)
+	require.Equal(t, Fields{"id": "id", "name": "name", "age": "Age", "created": "created_at"}, fields)
+	return NewFilterBuilder(fields)
+}
+
+func TestFilterFromJSON(t *testing.T) {
+	b := testFilterBuilder(t)
+	f, err := b.FromJSON([]byte(`{
+		"age": {"$gt": 18, "$lte": 60.5},
+		"name": {"$ilike": "a%"},
+		"$or": [{"id": {"$in": [1, 2]}}, {"created": null, "id": {"$nin": [3]}}],
+		"$not": {"name": "bob"},
+		"$sort": "-created,name", "$limit": 500, "$page": 3
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"bob", int64(1), int64(2), int64(3), int64(18), 60.5, "a%"}, f.Args)
+	require.Equal(t, 100, f.Limit)
+	require.Equal(t, 200, f.Offset)
+
+	require.Equal(t, "WHERE NOT (name = ?) AND (id IN (?, ?) OR (created_at IS NULL AND id NOT IN (?))) AND Age > ? AND Age <= ? AND name ILIKE ? ORDER BY created_at DESC, name LIMIT 100 OFFSET 200", genericPrinter.Filter(f))
+	require.Equal(t, "WHERE NOT (name = $1) AND (id IN ($2, $3) OR (created_at IS NULL AND id NOT IN ($4))) AND Age > $5 AND Age <= $6 AND name ILIKE $7 ORDER BY created_at DESC, name LIMIT 100 OFFSET 200", NewPrinter(PostgreSQL).Filter(f))
+	require.Equal(t, "SELECT * FROM users WHERE NOT (name = ?) AND (id IN (?, ?) OR (created_at IS NULL AND id NOT IN (?))) AND Age > ? AND Age <= ? AND LOWER(name) LIKE LOWER(?) ORDER BY created_at DESC, name LIMIT 100 OFFSET 200", NewPrinter(MySQL).Query(f.Select("users")))
+
+	f, err = b.FromJSON([]byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, "LIMIT 20", genericPrinter.Filter(f))
+}
+
+func TestFilterFromValues(t *testing.T) {
+	b := testFilterBuilder(t)
+	values, err := url.ParseQuery("age__between=18,30&name=a&name=b&created__null=false&id__ne=7&sort=-id&page=2&limit=10")
+	require.NoError(t, err)
+	f, err := b.FromValues(values)
+	require.NoError(t, err)
+	require.Equal(t, "WHERE Age BETWEEN ? AND ? AND created_at IS NOT NULL AND id != ? AND name IN (?, ?) ORDER BY id DESC LIMIT 10 OFFSET 10", genericPrinter.Filter(f))
+	require.Equal(t, []interface{}{"18", "30", "7", "a", "b"}, f.Args)
+}
+
+func TestFilterErrors(t *testing.T) {
+	b := testFilterBuilder(t)
+	for _, data := range []string{
+		`{"password": "x"}`,
+		`{"name; DROP TABLE users": "x"}`,
+		`{"$sort": "secret"}`,
+		`{"$or": [{"Password": 1}]}`,
+	} {
+		_, err := b.FromJSON([]byte(data))
+		require.True(t, errors.Is(err, ErrUnknownField), data)
+	}
+	for _, data := range []string{
+		`[]`,
+		`{"age": {"$regex": "1"}}`,
+		`{"age": {"gt": 1}}`,
+		`{"age": {"$in": []}}`,
+		`{"age": {"$between": [1]}}`,
+		`{"age": {"$null": "yes"}}`,
+		`{"age": [1, 2]}`,
+		`{"$or": {"age": 1}}`,
+		`{"$limit": -1}`,
+		`{"$page": "x"}`,
+	} {
+		_, err := b.FromJSON([]byte(data))
+		require.True(t, errors.Is(err, ErrInvalidFilter), data)
+	}
+
+	_, err := b.FromValues(url.Values{"age__foo": {"1"}})
+	require.True(t, errors.Is(err, ErrInvalidFilter))
+	_, err = b.FromValues(url.Values{"nope": {"1"}})
+	require.True(t, errors.Is(err, ErrUnknownField))
+}
+
+func TestFieldsFromTable(t *testing.T) {
+	table, err := ParserSql("CREATE TABLE t (`id` INT, name TEXT)")
+	require.NoError(t, err)
+	require.Equal(t, Fields{"id": "id", "name": "name"}, FieldsFromTable(table))
+	_, err = FieldsFromStruct(1)
+	require.Error(t, err)
+}
@@ -0,0 +1,527 @@
+package dbparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Severity is the level of a lint issue
+type Severity int
+
+const (
+	// SeverityInfo is a hint, e.g. a nullable column
+	SeverityInfo Severity = iota
+	// SeverityWarning is a likely problem, e.g. SELECT *
+	SeverityWarning
+	// SeverityError must be fixed, e.g. DELETE without WHERE
+	SeverityError
+)
+
+// SeverityString is a string slice with the names of all severities in order
+var SeverityString = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	return enumName(SeverityString, int(s), "Severity")
+}
+
+// MarshalText writes the name of the severity, for JSON output and configs
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText reads the name of a severity
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range SeverityString {
+		if strings.EqualFold(name, string(text)) {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return errors.Errorf("unknown severity %q", text)
+}
+
+// Issue is a problem found by a lint rule
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	// Statement is the 0-based index of the statement in the source
+	Statement int `json:"statement"`
+}
+
+func (i *Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", i.Line, i.Column, i.Severity, i.Message, i.Rule)
+}
+
+// LintStatement is a statement of the linted source given to the rules,
+// Statement is set for DDL and Query for DQL
+type LintStatement struct {
+	SQL       string
+	Index     int
+	Pos       Pos
+	Statement Statement
+	Query     *Query
+	// Schema has the tables of LintConfig.Schema and of the DDL statements before this one
+	Schema *Schema
+
+	offset int
+	lines  *lineIndex
+}
+
+// PosOf returns the position in the source of a span of Statement
+func (s *LintStatement) PosOf(span Span) Pos {
+	if span.Start.Line == 0 {
+		return s.Pos
+	}
+	return s.lines.pos(s.offset + span.Start.Offset)
+}
+
+// Rule is a lint rule, Check calls report for each problem of the statement
+type Rule struct {
+	Name        string
+	Description string
+	Severity    Severity
+	Check       func(s *LintStatement, report func(pos Pos, msg string))
+}
+
+// LintConfig configures a Linter, it can be read from JSON
+type LintConfig struct {
+	// Disable is the names of the rules not to run
+	Disable []string `json:"disable"`
+	// Severity overrides the severity of rules by name
+	Severity map[string]Severity `json:"severity"`
+	// Schema enables the rules which need the tables, e.g. unindexed-where.
+	// The tables created by the linted source are added to it.
+	Schema *Schema `json:"-"`
+}
+
+// Linter runs rules on the statements of SQL sources
+type Linter struct {
+	Rules  []*Rule
+	Config LintConfig
+}
+
+// NewLinter returns a linter with DefaultRules
+func NewLinter(cfg LintConfig) *Linter {
+	return &Linter{Rules: DefaultRules(), Config: cfg}
+}
+
+// SyntaxRule is the name of the issues of statements which don't parse
+const SyntaxRule = "syntax"
+
+// Lint checks each statement of src, sorted by position.
+// A statement which doesn't parse is reported as a SyntaxRule error and the others are still checked.
+func (l *Linter) Lint(src string) []*Issue {
+	rules := []*Rule{}
+	for _, rule := range l.Rules {
+		if !l.disabled(rule.Name) {
+			rules = append(rules, rule)
+		}
+	}
+	schema := NewSchema()
+	if l.Config.Schema != nil {
+		for _, table := range l.Config.Schema.Tables {
+			clone := table.Clone()
+			schema.Tables = append(schema.Tables, &clone)
+		}
+	}
+
+	issues := []*Issue{}
+	lines := newLineIndex(src)
+	for i, part := range splitStatements(src) {
+		s := &LintStatement{SQL: part.sql, Index: i, Pos: lines.pos(part.offset), Schema: schema, offset: part.offset, lines: lines}
+		add := func(rule string, severity Severity, pos Pos, msg string) {
+			issues = append(issues, &Issue{Rule: rule, Severity: severity, Message: msg, Line: pos.Line, Column: pos.Column, Statement: i})
+		}
+
+		stmts, err := s.parse()
+		if err != nil {
+			if !l.disabled(SyntaxRule) {
+				var syntaxErr *SyntaxError
+				pos := s.Pos
+				if errors.As(err, &syntaxErr) {
+					pos = s.lines.pos(s.offset + syntaxErr.Pos.Offset)
+					err = errors.New(syntaxErr.Msg)
+				}
+				add(SyntaxRule, l.severity(SyntaxRule, SeverityError), pos, err.Error())
+			}
+			continue
+		}
+		for _, stmt := range stmts {
+			s.Statement = stmt
+			for _, rule := range rules {
+				rule.Check(s, func(pos Pos, msg string) {
+					add(rule.Name, l.severity(rule.Name, rule.Severity), pos, msg)
+				})
+			}
+			// later statements see the tables of this one, a failure is not a lint issue
+			_ = schema.Apply(stmt)
+		}
+		if s.Query != nil {
+			for _, rule := range rules {
+				rule.Check(s, func(pos Pos, msg string) {
+					add(rule.Name, l.severity(rule.Name, rule.Severity), pos, msg)
+				})
+			}
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues
+}
+
+func (l *Linter) disabled(name string) bool {
+	for _, item := range l.Config.Disable {
+		if strings.EqualFold(item, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Linter) severity(name string, severity Severity) Severity {
+	if s, ok := l.Config.Severity[name]; ok {
+		return s
+	}
+	return severity
+}
+
+// parse parses the statement with the DDL or the DQL parser, see GetSqlType.
+// DDL returns the statements and DQL sets Query, other statements are ignored.
+func (s *LintStatement) parse() ([]Statement, error) {
+	switch GetSqlType(s.SQL) {
+	case DDL:
+		return NewLexer(s.SQL).Parser().ParseStatements()
+	case DQL:
+		p := &parser{sql: s.SQL, step: stepType, lines: newLineIndex(s.SQL), lint: true}
+		q, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		s.Query = &q
+	}
+	return nil, nil
+}
+
+type sourceStatement struct {
+	sql    string
+	offset int
+}
+
+// splitStatements splits src at the semicolons out of quotes and comments,
+// the statements are trimmed and empty ones are skipped
+func splitStatements(src string) []sourceStatement {
+	res := []sourceStatement{}
+	add := func(start, end int) {
+		sql := src[start:end]
+		trimmed := strings.TrimLeft(sql, " \t\r\n")
+		offset := start + len(sql) - len(trimmed)
+		// skip the leading comments, GetSqlType reads the first word
+		for strings.HasPrefix(trimmed, "--") {
+			end := strings.IndexByte(trimmed, '\n')
+			if end == -1 {
+				return
+			}
+			rest := strings.TrimLeft(trimmed[end:], " \t\r\n")
+			offset += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+		if trimmed = strings.TrimRight(trimmed, " \t\r\n"); trimmed != "" {
+			res = append(res, sourceStatement{sql: trimmed, offset: offset})
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(src); i++ {
+		switch ch := src[i]; {
+		case ch == '\'' || ch == '"' || ch == '`':
+			if end := strings.IndexByte(src[i+1:], ch); end != -1 {
+				i += end + 1
+			} else {
+				i = len(src)
+			}
+		case ch == '-' && strings.HasPrefix(src[i:], "--"):
+			if end := strings.IndexByte(src[i:], '\n'); end != -1 {
+				i += end
+			} else {
+				i = len(src)
+			}
+		case ch == ';':
+			add(start, i)
+			start = i + 1
+		}
+	}
+	if start < len(src) {
+		add(start, len(src))
+	}
+	return res
+}
+
+// DefaultRules returns the rules run by NewLinter:
+//
+//	no-where             UPDATE / DELETE without WHERE (error)
+//	select-star          SELECT * (warning)
+//	missing-primary-key  CREATE TABLE without primary key (error)
+//	nullable-column      column without NOT NULL or DEFAULT (info)
+//	implicit-conversion  a column compared with a literal of another type, needs the table (warning)
+//	unindexed-where      a column in WHERE without index, needs the table (warning)
+//	reserved-word        a table, column or index named by a keyword (warning)
+func DefaultRules() []*Rule {
+	return []*Rule{
+		{Name: "no-where", Description: "UPDATE or DELETE without WHERE changes every row", Severity: SeverityError, Check: checkNoWhere},
+		{Name: "select-star", Description: "SELECT * reads columns the caller doesn't need and breaks when the table changes", Severity: SeverityWarning, Check: checkSelectStar},
+		{Name: "missing-primary-key", Description: "a table without primary key can't be updated row by row", Severity: SeverityError, Check: checkPrimaryKey},
+		{Name: "nullable-column", Description: "a column without NOT NULL or DEFAULT is NULL when omitted", Severity: SeverityInfo, Check: checkNullable},
+		{Name: "implicit-conversion", Description: "comparing a column with a literal of another type converts every row and skips the index", Severity: SeverityWarning, Check: checkConversion},
+		{Name: "unindexed-where", Description: "a column in WHERE which starts no index scans the table", Severity: SeverityWarning, Check: checkIndexedWhere},
+		{Name: "reserved-word", Description: "an identifier named by a keyword must be quoted everywhere", Severity: SeverityWarning, Check: checkReservedWord},
+	}
+}
+
+func checkNoWhere(s *LintStatement, report func(Pos, string)) {
+	if q := s.Query; q != nil && q.Where == nil && (q.Type == Update || q.Type == Delete) {
+		report(s.Pos, strings.ToUpper(TypeString[q.Type])+" without WHERE changes every row of "+unquote(q.TableName))
+	}
+}
+
+func checkSelectStar(s *LintStatement, report func(Pos, string)) {
+	if s.Query == nil {
+		return
+	}
+	walkQueries(s.Query, func(q *Query) {
+		for _, column := range q.Columns {
+			if ref, ok := column.Expr.(*ColumnRef); ok && ref.Name == "*" {
+				report(s.Pos, "SELECT "+ref.String()+", list the columns instead")
+			}
+		}
+	})
+}
+
+func checkPrimaryKey(s *LintStatement, report func(Pos, string)) {
+	if stmt, ok := s.Statement.(*CreateTable); ok && len(stmt.Table.PrimaryKey) == 0 && stmt.Table.PkName == "" {
+		report(s.PosOf(stmt.Span), "table "+unquote(stmt.Table.Name)+" has no primary key")
+	}
+}
+
+func checkNullable(s *LintStatement, report func(Pos, string)) {
+	check := func(table *Table, column *Column) {
+		if hasConstraint(column, "NOT NULL") || hasConstraint(column, "PRIMARY KEY") {
+			return
+		}
+		if _, ok := constraintValue(column, "DEFAULT"); ok {
+			return
+		}
+		if table != nil {
+			for _, name := range table.PrimaryKey {
+				if strings.EqualFold(name, column.Name) {
+					return
+				}
+			}
+		}
+		report(s.PosOf(column.Span), "column "+unquote(column.Name)+" has neither NOT NULL nor DEFAULT")
+	}
+	switch stmt := s.Statement.(type) {
+	case *CreateTable:
+		for _, column := range stmt.Table.Columns {
+			check(&stmt.Table, column)
+		}
+	case *AlterTable:
+		for _, action := range stmt.Actions {
+			if action.Type == AddColumn {
+				check(s.Schema.Table(stmt.Table), action.Column)
+			}
+		}
+	}
+}
+
+func checkConversion(s *LintStatement, report func(Pos, string)) {
+	if s.Query == nil {
+		return
+	}
+	walkQueries(s.Query, func(q *Query) {
+		check := func(left, right Expr) {
+			ref, ok := left.(*ColumnRef)
+			literal, isLiteral := right.(*Literal)
+			if !ok || !isLiteral {
+				return
+			}
+			table, column := s.resolveColumn(q, ref)
+			if column == nil {
+				return
+			}
+			kind := typeKind(column.Type)
+			switch {
+			case kind == NumberLiteral && literal.Kind == StringLiteral:
+				report(s.Pos, fmt.Sprintf("%s column %s.%s is compared with the string %s", column.Type, unquote(table.Name), unquote(column.Name), literal))
+			case kind == StringLiteral && literal.Kind == NumberLiteral:
+				report(s.Pos, fmt.Sprintf("%s column %s.%s is compared with the number %s", column.Type, unquote(table.Name), unquote(column.Name), literal))
+			}
+		}
+		WalkExpr(q.Where, func(e Expr) bool {
+			switch e := e.(type) {
+			case *ComparisonExpr:
+				check(e.Left, e.Right)
+				check(e.Right, e.Left)
+			case *InExpr:
+				for _, item := range e.List {
+					check(e.Expr, item)
+				}
+			case *BetweenExpr:
+				check(e.Expr, e.Low)
+				check(e.Expr, e.High)
+			}
+			return true
+		})
+	})
+}
+
+func checkIndexedWhere(s *LintStatement, report func(Pos, string)) {
+	if s.Query == nil {
+		return
+	}
+	walkQueries(s.Query, func(q *Query) {
+		reported := map[*Column]bool{}
+		WalkExpr(q.Where, func(e Expr) bool {
+			ref, ok := e.(*ColumnRef)
+			if !ok {
+				return true
+			}
+			table, column := s.resolveColumn(q, ref)
+			if column == nil || reported[column] || isIndexed(table, column.Name) {
+				return true
+			}
+			reported[column] = true
+			report(s.Pos, "column "+unquote(table.Name)+"."+unquote(column.Name)+" in WHERE starts no index")
+			return true
+		})
+	})
+}
+
+func checkReservedWord(s *LintStatement, report func(Pos, string)) {
+	check := func(pos Pos, kind, name string) {
+		if sqlKeywords[strings.ToUpper(unquote(name))] {
+			report(pos, kind+" "+unquote(name)+" is a reserved word")
+		}
+	}
+	switch stmt := s.Statement.(type) {
+	case *CreateTable:
+		check(s.PosOf(stmt.Span), "table", stmt.Table.Name)
+		for _, column := range stmt.Table.Columns {
+			check(s.PosOf(column.Span), "column", column.Name)
+		}
+	case *CreateIndex:
+		check(s.PosOf(stmt.Span), "index", stmt.Index.Name)
+	case *AlterTable:
+		for _, action := range stmt.Actions {
+			switch action.Type {
+			case AddColumn, ModifyColumn:
+				check(s.PosOf(action.Span), "column", action.Column.Name)
+			case RenameColumn:
+				check(s.PosOf(action.Span), "column", action.NewName)
+			case RenameTable:
+				check(s.PosOf(action.Span), "table", action.NewName)
+			}
+		}
+	}
+}
+
+// resolveColumn returns the table and the column of a reference in q, nil when the schema doesn't have them
+func (s *LintStatement) resolveColumn(q *Query, ref *ColumnRef) (*Table, *Column) {
+	name := ""
+	switch {
+	case ref.Table == "" || strings.EqualFold(ref.Table, q.TableAlias) || strings.EqualFold(ref.Table, unquote(q.TableName)):
+		name = q.TableName
+	default:
+		for _, join := range q.Joins {
+			if strings.EqualFold(ref.Table, join.Table.Alias) || strings.EqualFold(ref.Table, unquote(join.Table.Name)) {
+				name = join.Table.Name
+			}
+		}
+	}
+	table := s.Schema.Table(unquote(name))
+	if table == nil {
+		return nil, nil
+	}
+	column := table.Column(unquote(ref.Name))
+	if column == nil {
+		return nil, nil
+	}
+	return table, column
+}
+
+// walkQueries calls fn with q and its subqueries
+func walkQueries(q *Query, fn func(*Query)) {
+	fn(q)
+	if q.Subquery != nil {
+		walkQueries(q.Subquery, fn)
+	}
+	for _, join := range q.Joins {
+		if join.Table.Subquery != nil {
+			walkQueries(join.Table.Subquery, fn)
+		}
+	}
+	visit := func(e Expr) bool {
+		switch e := e.(type) {
+		case *SubqueryExpr:
+			walkQueries(e.Query, fn)
+		case *ExistsExpr:
+			walkQueries(e.Query, fn)
+		case *InExpr:
+			if e.Subquery != nil {
+				walkQueries(e.Subquery, fn)
+			}
+		}
+		return true
+	}
+	for _, column := range q.Columns {
+		WalkExpr(column.Expr, visit)
+	}
+	WalkExpr(q.Where, visit)
+	WalkExpr(q.Having, visit)
+}
+
+// isIndexed reports whether the column is the first column of the primary key or of an index of the table
+func isIndexed(table *Table, column string) bool {
+	if len(table.PrimaryKey) > 0 && strings.EqualFold(table.PrimaryKey[0], column) {
+		return true
+	}
+	for _, index := range table.Indexes {
+		if len(index.Columns) > 0 && strings.EqualFold(index.Columns[0], column) {
+			return true
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		// MySQL indexes foreign keys
+		if len(fk.Columns) > 0 && strings.EqualFold(fk.Columns[0], column) {
+			return true
+		}
+	}
+	col := table.Column(column)
+	return col != nil && (hasConstraint(col, "UNIQUE") || hasConstraint(col, "PRIMARY KEY"))
+}
+
+// typeKind returns NumberLiteral for numeric types, StringLiteral for text types and NullLiteral for the others
+func typeKind(typ string) LiteralKind {
+	base, _, _ := strings.Cut(strings.ToUpper(typ), "(")
+	switch strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(base), "UNSIGNED")) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "INT2", "INT4", "INT8",
+		"SERIAL", "SMALLSERIAL", "BIGSERIAL", "DECIMAL", "NUMERIC", "FLOAT", "DOUBLE", "REAL":
+		return NumberLiteral
+	case "CHAR", "VARCHAR", "NCHAR", "NVARCHAR", "TEXT", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "CHARACTER VARYING":
+		return StringLiteral
+	}
+	return NullLiteral
+}
+
+func unquote(name string) string {
+	return strings.Trim(name, "`\"'")
+}
@@ -0,0 +1,76 @@
+package dbparser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func lintMessages(issues []*Issue) []string {
+	res := []string{}
+	for _, issue := range issues {
+		res = append(res, issue.String())
+	}
+	return res
+}
+
+func TestLint(t *testing.T) {
+	src := `-- schema
+CREATE TABLE users (
+	id INT PRIMARY KEY,
+	email VARCHAR(100) NOT NULL,
+	age INT NOT NULL DEFAULT 0,
+	nickname VARCHAR(20)
+);
+CREATE INDEX idx_email ON users (email);
+CREATE TABLE logs (msg TEXT NOT NULL, ` + "`order`" + ` INT NOT NULL);
+
+SELECT * FROM users WHERE email = 'a@b.c';
+SELECT id FROM users WHERE age = '18' AND nickname = 1;
+UPDATE users SET nickname = 'x';
+DELETE FROM users WHERE id = 1;
+SELECT id FROM;
+SELECT msg FROM logs WHERE msg LIKE 'a%'`
+
+	issues := NewLinter(LintConfig{}).Lint(src)
+	require.Equal(t, []string{
+		"6:2: info: column nickname has neither NOT NULL nor DEFAULT (nullable-column)",
+		"9:1: error: table logs has no primary key (missing-primary-key)",
+		"9:40: warning: column order is a reserved word (reserved-word)",
+		"11:1: warning: SELECT *, list the columns instead (select-star)",
+		"12:1: warning: INT column users.age is compared with the string '18' (implicit-conversion)",
+		"12:1: warning: VARCHAR(20) column users.nickname is compared with the number 1 (implicit-conversion)",
+		"12:1: warning: column users.age in WHERE starts no index (unindexed-where)",
+		"12:1: warning: column users.nickname in WHERE starts no index (unindexed-where)",
+		"13:1: error: UPDATE without WHERE changes every row of users (no-where)",
+		"15:15: error: table name cannot be empty (syntax)",
+		"16:1: warning: column logs.msg in WHERE starts no index (unindexed-where)",
+	}, lintMessages(issues))
+	require.Equal(t, 2, issues[2].Statement)
+}
+
+func TestLintConfig(t *testing.T) {
+	cfg := LintConfig{}
+	require.NoError(t, json.Unmarshal([]byte(`{"disable": ["unindexed-where"], "severity": {"select-star": "error"}}`), &cfg))
+	schema, err := ParseSchema(testSchema)
+	require.NoError(t, err)
+	cfg.Schema = schema
+
+	issues := NewLinter(cfg).Lint("SELECT * FROM users WHERE name = 'a'")
+	require.Equal(t, []string{"1:1: error: SELECT *, list the columns instead (select-star)"}, lintMessages(issues))
+
+	data, err := json.Marshal(issues)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"rule": "select-star", "severity": "error", "message": "SELECT *, list the columns instead", "line": 1, "column": 1, "statement": 0}]`, string(data))
+
+	require.Error(t, json.Unmarshal([]byte(`{"severity": {"select-star": "fatal"}}`), &cfg))
+}
+
+func TestSplitStatements(t *testing.T) {
+	src := "SELECT ';' FROM a; -- x;\n\n SELECT \"b;\" FROM c;;"
+	require.Equal(t, []sourceStatement{
+		{sql: "SELECT ';' FROM a", offset: 0},
+		{sql: "SELECT \"b;\" FROM c", offset: 27},
+	}, splitStatements(src))
+}
@@ -0,0 +1,241 @@
+package dbparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Migration is the SQL turning a schema into another one and back
+type Migration struct {
+	Up   []string
+	Down []string
+	// Destructive are the changes of Up which may lose data, they should be reviewed before running it
+	Destructive []*Change
+}
+
+// Migration returns the statements migrating from into to (Up) and back (Down) for the dialect of the printer.
+// SQLite can't alter columns or constraints, such a table is rebuilt: created under a new name,
+// filled with the columns kept, dropped and renamed.
+func (pr *Printer) Migration(from, to *Schema) *Migration {
+	m := &Migration{}
+	up := DiffSchema(from, to)
+	m.Up = pr.changes(up)
+	m.Down = pr.changes(DiffSchema(to, from))
+	for _, c := range up {
+		if c.Destructive() {
+			m.Destructive = append(m.Destructive, c)
+		}
+	}
+	return m
+}
+
+// Change returns the statements applying one change
+func (pr *Printer) Change(c *Change) []string {
+	w := pr.newWriter()
+	alter := "ALTER TABLE " + w.ident(c.Table) + " "
+	switch c.Type {
+	case CreateTableChange:
+		return pr.tableStatements(*c.To)
+	case DropTableChange:
+		return []string{"DROP TABLE " + w.ident(c.Table) + ";"}
+	case AddColumnChange:
+		res := []string{alter + "ADD COLUMN " + w.columnDef(c.Column) + ";"}
+		if comment := pr.columnComment(w, c.Table, c.Column); comment != "" {
+			res = append(res, comment)
+		}
+		return res
+	case DropColumnChange:
+		return []string{alter + "DROP COLUMN " + w.ident(c.OldColumn.Name) + ";"}
+	case AlterColumnChange:
+		return pr.alterColumn(w, alter, c)
+	case AddPrimaryKeyChange:
+		return []string{alter + "ADD PRIMARY KEY (" + w.idents(c.To.PrimaryKey) + ");"}
+	case DropPrimaryKeyChange:
+		if pr.Dialect == MySQL {
+			return []string{alter + "DROP PRIMARY KEY;"}
+		}
+		return []string{alter + "DROP CONSTRAINT " + w.ident(pr.constraintName(c.From, "", "pkey", nil, 0)) + ";"}
+	case AddIndexChange:
+		if !c.Index.Constraint {
+			return []string{pr.Index(c.Table, c.Index)}
+		}
+		return []string{alter + "ADD " + w.constraintName(c.Index.Name) + "UNIQUE (" + w.idents(c.Index.Columns) + ");"}
+	case DropIndexChange:
+		switch {
+		case !c.Index.Constraint && pr.Dialect == MySQL:
+			return []string{"DROP INDEX " + w.ident(indexName(c.Table, c.Index)) + " ON " + w.ident(c.Table) + ";"}
+		case !c.Index.Constraint:
+			return []string{"DROP INDEX " + w.ident(indexName(c.Table, c.Index)) + ";"}
+		case pr.Dialect == MySQL:
+			return []string{alter + "DROP INDEX " + w.ident(pr.constraintName(c.From, c.Index.Name, "key", c.Index.Columns, 0)) + ";"}
+		}
+		return []string{alter + "DROP CONSTRAINT " + w.ident(pr.constraintName(c.From, c.Index.Name, "key", c.Index.Columns, 0)) + ";"}
+	case AddForeignKeyChange:
+		return []string{alter + "ADD " + w.foreignKey(c.ForeignKey) + ";"}
+	case DropForeignKeyChange:
+		name := pr.constraintName(c.From, c.ForeignKey.Name, "fkey", c.ForeignKey.Columns, position(c.From.ForeignKeys, c.ForeignKey))
+		if pr.Dialect == MySQL {
+			return []string{alter + "DROP FOREIGN KEY " + w.ident(name) + ";"}
+		}
+		return []string{alter + "DROP CONSTRAINT " + w.ident(name) + ";"}
+	case AddCheckChange:
+		return []string{alter + "ADD " + w.constraintName(c.Check.Name) + "CHECK (" + c.Check.Expr + ");"}
+	case DropCheckChange:
+		name := pr.constraintName(c.From, c.Check.Name, "check", nil, position(c.From.Checks, c.Check))
+		if pr.Dialect == MySQL {
+			return []string{alter + "DROP CHECK " + w.ident(name) + ";"}
+		}
+		return []string{alter + "DROP CONSTRAINT " + w.ident(name) + ";"}
+	}
+	return nil
+}
+
+func (pr *Printer) changes(changes []*Change) []string {
+	res := []string{}
+	rebuilt := map[string]bool{}
+	for _, c := range changes {
+		if pr.Dialect == SQLite && c.From != nil && c.To != nil && needsRebuild(c) {
+			rebuilt[strings.ToLower(c.Table)] = false
+		}
+	}
+	for _, c := range changes {
+		done, ok := rebuilt[strings.ToLower(c.Table)]
+		switch {
+		case !ok || c.Type == CreateTableChange || c.Type == DropTableChange:
+			res = append(res, pr.Change(c)...)
+		case !done:
+			res = append(res, pr.rebuildTable(c.From, c.To)...)
+			rebuilt[strings.ToLower(c.Table)] = true
+		}
+	}
+	return res
+}
+
+// needsRebuild reports whether SQLite has no ALTER TABLE for the change
+func needsRebuild(c *Change) bool {
+	switch c.Type {
+	case AddColumnChange, DropColumnChange:
+		return false
+	case AddIndexChange, DropIndexChange:
+		return c.Index.Constraint
+	}
+	return true
+}
+
+func (pr *Printer) rebuildTable(from, to *Table) []string {
+	w := pr.newWriter()
+	tmp := to.Clone()
+	tmp.Name = "_" + strings.Trim(to.Name, "`\"'") + "_new"
+	columns := []string{}
+	for _, column := range to.Columns {
+		if from.Column(column.Name) != nil {
+			columns = append(columns, w.ident(column.Name))
+		}
+	}
+	res := append(pr.tableStatements(tmp),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s;", w.ident(tmp.Name), strings.Join(columns, ", "), strings.Join(columns, ", "), w.ident(from.Name)),
+		"DROP TABLE "+w.ident(from.Name)+";",
+		"ALTER TABLE "+w.ident(tmp.Name)+" RENAME TO "+w.ident(to.Name)+";",
+	)
+	for _, index := range to.Indexes {
+		if !index.Constraint {
+			res = append(res, pr.Index(to.Name, index))
+		}
+	}
+	return res
+}
+
+func (pr *Printer) alterColumn(w *printWriter, alter string, c *Change) []string {
+	if pr.Dialect == MySQL {
+		return []string{alter + "MODIFY COLUMN " + w.columnDef(c.Column) + ";"}
+	}
+	res := []string{}
+	prefix := alter + "ALTER COLUMN " + w.ident(c.Column.Name) + " "
+	if !strings.EqualFold(c.OldColumn.Type, c.Column.Type) {
+		typ, _ := pr.columnDef(c.Column)
+		// SERIAL is only a type in CREATE TABLE / ADD COLUMN
+		switch strings.ToUpper(typ) {
+		case "SERIAL":
+			typ = "INTEGER"
+		case "BIGSERIAL":
+			typ = "BIGINT"
+		}
+		res = append(res, prefix+"TYPE "+typ+";")
+	}
+	oldDefault, oldOk := constraintValue(c.OldColumn, "DEFAULT")
+	newDefault, newOk := constraintValue(c.Column, "DEFAULT")
+	switch {
+	case newOk && (!oldOk || oldDefault != newDefault):
+		res = append(res, prefix+"SET DEFAULT "+newDefault+";")
+	case oldOk && !newOk:
+		res = append(res, prefix+"DROP DEFAULT;")
+	}
+	switch oldNotNull, newNotNull := hasConstraint(c.OldColumn, "NOT NULL"), hasConstraint(c.Column, "NOT NULL"); {
+	case newNotNull && !oldNotNull:
+		res = append(res, prefix+"SET NOT NULL;")
+	case oldNotNull && !newNotNull:
+		res = append(res, prefix+"DROP NOT NULL;")
+	}
+	return res
+}
+
+// columnDef returns the definition of the column for ADD / MODIFY COLUMN,
+// its primary key and foreign key are changed by their own statements
+func (w *printWriter) columnDef(column *Column) string {
+	c := *column
+	c.Constraints = columnConstraints(column)
+	typ, constraints := w.pr.columnDef(&c)
+	res := w.ident(column.Name) + " " + typ
+	for _, item := range constraints {
+		res += " " + item
+	}
+	return res
+}
+
+// constraintName returns the name of a constraint, or the name the database gives it when it has none
+func (pr *Printer) constraintName(table *Table, name, kind string, columns []string, n int) string {
+	if name != "" {
+		return name
+	}
+	t := strings.Trim(table.Name, "`\"'")
+	switch {
+	case pr.Dialect == MySQL && kind == "key":
+		return columns[0]
+	case pr.Dialect == MySQL && kind == "fkey":
+		return fmt.Sprintf("%s_ibfk_%d", t, n)
+	case pr.Dialect == MySQL && kind == "check":
+		return fmt.Sprintf("%s_chk_%d", t, n)
+	case kind == "pkey" || kind == "check":
+		return t + "_" + kind
+	}
+	return t + "_" + strings.Join(columns, "_") + "_" + kind
+}
+
+// constraintValue returns the item following a word of the column constraints, e.g. the value of DEFAULT
+func constraintValue(column *Column, word string) (string, bool) {
+	for i, item := range column.Constraints {
+		if strings.EqualFold(item, word) && i+1 < len(column.Constraints) {
+			return column.Constraints[i+1], true
+		}
+	}
+	return "", false
+}
+
+func hasConstraint(column *Column, word string) bool {
+	for _, item := range column.Constraints {
+		if strings.EqualFold(item, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// position returns the 1-based position of item in list
+func position[T any](list []*T, item *T) int {
+	for i, x := range list {
+		if x == item {
+			return i + 1
+		}
+	}
+	return 0
+}
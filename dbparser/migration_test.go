@@ -0,0 +1,175 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testSchemaV2 = `
+CREATE TABLE users (
+	id BIGINT PRIMARY KEY AUTO_INCREMENT,
+	name VARCHAR(50) NOT NULL DEFAULT '',
+	age INT
+);
+CREATE TABLE posts (
+	id BIGINT PRIMARY KEY,
+	user_id BIGINT NOT NULL,
+	title TEXT,
+	CONSTRAINT fk_author FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX idx_title ON posts (title);
+`
+
+func TestDiffSchema(t *testing.T) {
+	from, err := ParseSchema(testSchema)
+	require.NoError(t, err)
+	to, err := ParseSchema(testSchemaV2)
+	require.NoError(t, err)
+
+	changes := DiffSchema(from, to)
+	res := []string{}
+	for _, c := range changes {
+		res = append(res, c.String())
+	}
+	require.Equal(t, []string{
+		"drop index users (email)",
+		"create table posts",
+		"add column users.age",
+		"alter column users.name",
+		"drop column users.email",
+		"drop table orders",
+		"add index posts (title)",
+	}, res)
+	require.False(t, changes[3].Destructive())
+	require.True(t, changes[4].Destructive())
+	require.True(t, changes[5].Destructive())
+
+	require.Empty(t, DiffSchema(to, to))
+}
+
+func TestDiffSchemaOrder(t *testing.T) {
+	to, err := ParseSchema(`
+		CREATE TABLE c (id INT, b_id INT REFERENCES b (id));
+		CREATE TABLE b (id INT, a_id INT REFERENCES a (id));
+		CREATE TABLE a (id INT);
+	`)
+	require.NoError(t, err)
+	tables := func(changes []*Change) []string {
+		res := []string{}
+		for _, c := range changes {
+			res = append(res, c.Table)
+		}
+		return res
+	}
+	require.Equal(t, []string{"a", "b", "c"}, tables(DiffSchema(NewSchema(), to)))
+	require.Equal(t, []string{"c", "b", "a"}, tables(DiffSchema(to, NewSchema())))
+}
+
+func TestPrinterMigration(t *testing.T) {
+	from, err := ParseSchema(testSchema)
+	require.NoError(t, err)
+	to, err := ParseSchema(testSchemaV2)
+	require.NoError(t, err)
+
+	m := NewPrinter(MySQL).Migration(from, to)
+	require.Equal(t, []string{
+		"DROP INDEX idx_email ON users;",
+		"CREATE TABLE posts (\n\tid BIGINT PRIMARY KEY,\n\tuser_id BIGINT NOT NULL,\n\ttitle TEXT,\n\tCONSTRAINT fk_author FOREIGN KEY (user_id) REFERENCES users (id)\n);",
+		"ALTER TABLE users ADD COLUMN age INT;",
+		"ALTER TABLE users MODIFY COLUMN name VARCHAR(50) NOT NULL DEFAULT '';",
+		"ALTER TABLE users DROP COLUMN email;",
+		"DROP TABLE orders;",
+		"CREATE INDEX idx_title ON posts (title);",
+	}, m.Up)
+	require.Equal(t, []string{
+		"CREATE TABLE orders (\n\tid BIGINT PRIMARY KEY,\n\tuser_id BIGINT NOT NULL,\n\tcode VARCHAR(32),\n\tUNIQUE (code),\n\tCONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE\n);",
+		"ALTER TABLE users ADD COLUMN email VARCHAR(100);",
+		"ALTER TABLE users MODIFY COLUMN name VARCHAR(20) NOT NULL;",
+		"ALTER TABLE users DROP COLUMN age;",
+		"DROP TABLE posts;",
+		"CREATE INDEX idx_email ON users (email);",
+	}, m.Down)
+	require.Len(t, m.Destructive, 2)
+
+	m = NewPrinter(PostgreSQL).Migration(from, to)
+	require.Equal(t, []string{
+		`ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(50);`,
+		`ALTER TABLE users ALTER COLUMN name SET DEFAULT '';`,
+	}, m.Up[3:5])
+	require.Equal(t, `DROP INDEX idx_email;`, m.Up[0])
+}
+
+func TestPrinterMigrationConstraints(t *testing.T) {
+	from, err := ParseSchema(`CREATE TABLE t (id INT PRIMARY KEY, a INT, b INT REFERENCES u (id), UNIQUE (a), CHECK (a > 0))`)
+	require.NoError(t, err)
+	to, err := ParseSchema(`CREATE TABLE t (id INT, a INT NOT NULL, b INT, PRIMARY KEY (id, a))`)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"ALTER TABLE t DROP FOREIGN KEY t_ibfk_1;",
+		"ALTER TABLE t DROP CHECK t_chk_1;",
+		"ALTER TABLE t DROP INDEX a;",
+		"ALTER TABLE t DROP PRIMARY KEY;",
+		"ALTER TABLE t MODIFY COLUMN a INT NOT NULL;",
+		"ALTER TABLE t ADD PRIMARY KEY (id, a);",
+	}, NewPrinter(MySQL).Migration(from, to).Up)
+
+	require.Equal(t, []string{
+		"ALTER TABLE t DROP CONSTRAINT t_b_fkey;",
+		"ALTER TABLE t DROP CONSTRAINT t_check;",
+		"ALTER TABLE t DROP CONSTRAINT t_a_key;",
+		"ALTER TABLE t DROP CONSTRAINT t_pkey;",
+		"ALTER TABLE t ALTER COLUMN a SET NOT NULL;",
+		"ALTER TABLE t ADD PRIMARY KEY (id, a);",
+	}, NewPrinter(PostgreSQL).Migration(from, to).Up)
+
+	m := NewPrinter(SQLite).Migration(from, to)
+	require.Equal(t, []string{
+		"CREATE TABLE _t_new (\n\tid INT,\n\ta INT NOT NULL,\n\tb INT,\n\tPRIMARY KEY (id, a)\n);",
+		"INSERT INTO _t_new (id, a, b) SELECT id, a, b FROM t;",
+		"DROP TABLE t;",
+		"ALTER TABLE _t_new RENAME TO t;",
+	}, m.Up)
+	require.Empty(t, m.Destructive)
+	require.Equal(t, "CREATE TABLE _t_new (\n\tid INT PRIMARY KEY,\n\ta INT,\n\tb INT REFERENCES u (id),\n\tUNIQUE (a),\n\tCHECK (a > 0)\n);", m.Down[0])
+}
+
+func TestPrinterMigrationRebuild(t *testing.T) {
+	from, err := ParseSchema(`CREATE TABLE users (id BIGINT NOT NULL AUTO_INCREMENT, name VARCHAR(20) COMMENT 'the name', PRIMARY KEY (id))`)
+	require.NoError(t, err)
+	to, err := ParseSchema(`CREATE TABLE users (id BIGINT NOT NULL AUTO_INCREMENT, name VARCHAR(20) NOT NULL COMMENT 'the name', PRIMARY KEY (id))`)
+	require.NoError(t, err)
+
+	// SQLite only accepts AUTOINCREMENT on an inline INTEGER PRIMARY KEY and has no column comments
+	m := NewPrinter(SQLite).Migration(from, to)
+	require.Equal(t, []string{
+		"CREATE TABLE _users_new (\n\tid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,\n\tname VARCHAR(20) NOT NULL\n);",
+		"INSERT INTO _users_new (id, name) SELECT id, name FROM users;",
+		"DROP TABLE users;",
+		"ALTER TABLE _users_new RENAME TO users;",
+	}, m.Up)
+	require.Equal(t, "CREATE TABLE _users_new (\n\tid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,\n\tname VARCHAR(20)\n);", m.Down[0])
+
+	m = NewPrinter(PostgreSQL).Migration(NewSchema(), to)
+	require.Equal(t, []string{
+		"CREATE TABLE users (\n\tid BIGSERIAL NOT NULL,\n\tname VARCHAR(20) NOT NULL,\n\tPRIMARY KEY (id)\n);",
+		"COMMENT ON COLUMN users.name IS 'the name';",
+	}, m.Up)
+}
+
+func TestMigrationFromModel(t *testing.T) {
+	sql, err := GenCreateTableStatement(TestUserModel{}, "test_user", "sqlite")
+	require.NoError(t, err)
+	model, err := ParseSchema(sql)
+	require.NoError(t, err)
+	live, err := ParseSchema("CREATE TABLE test_user (ID INTEGER PRIMARY KEY, CreatedAt DATETIME, Name TEXT, Old TEXT)")
+	require.NoError(t, err)
+
+	m := NewPrinter(SQLite).Migration(live, model)
+	require.Equal(t, []string{
+		"ALTER TABLE test_user ADD COLUMN UpdatedAt DATETIME;",
+		"ALTER TABLE test_user DROP COLUMN Old;",
+	}, m.Up)
+	require.Equal(t, []string{"drop column test_user.Old"}, []string{m.Destructive[0].String()})
+}
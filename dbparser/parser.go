@@ -15,7 +15,9 @@ const (
 
 func GetSqlType(sql string) SQLType {
 	sql = strings.TrimSpace(sql)
-	if strings.HasPrefix(sql, "create") || strings.HasPrefix(sql, "CREATE") {
+	if strings.HasPrefix(sql, "create") || strings.HasPrefix(sql, "CREATE") ||
+		strings.HasPrefix(sql, "alter") || strings.HasPrefix(sql, "ALTER") ||
+		strings.HasPrefix(sql, "drop") || strings.HasPrefix(sql, "DROP") {
 		return DDL
 	} else if strings.HasPrefix(sql, "insert") || strings.HasPrefix(sql, "INSERT") ||
 		strings.HasPrefix(sql, "delete") || strings.HasPrefix(sql, "DELETE") ||
@@ -30,7 +32,7 @@ func GetSqlType(sql string) SQLType {
 func ParseDDL(sql string) ([]Table, error) {
 	sql = strings.TrimSpace(sql)
 	if strings.HasPrefix(sql, "create") || strings.HasPrefix(sql, "CREATE") {
-		return NewParser(NewLexer(sql).Tokenize()).Parse()
+		return NewLexer(sql).Parser().Parse()
 	} else {
 		return nil, errors.New("传入ParseDDL中的不是create语句")
 	}
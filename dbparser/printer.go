@@ -0,0 +1,801 @@
+package dbparser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Dialect is the SQL dialect a Printer renders
+type Dialect int
+
+const (
+	// Generic keeps identifiers and placeholders as they were parsed
+	Generic Dialect = iota
+	// MySQL quotes identifiers with backticks
+	MySQL
+	// PostgreSQL quotes identifiers with double quotes
+	PostgreSQL
+	// SQLite quotes identifiers with double quotes
+	SQLite
+)
+
+// DialectString is a string slice with the names of all dialects in order
+var DialectString = []string{
+	"Generic",
+	"MySQL",
+	"PostgreSQL",
+	"SQLite",
+}
+
+func (d Dialect) String() string {
+	return enumName(DialectString, int(d), "Dialect")
+}
+
+// PlaceholderStyle is the syntax of bind parameters
+type PlaceholderStyle int
+
+const (
+	// KeepPlaceholder prints every placeholder in the style it was parsed
+	KeepPlaceholder PlaceholderStyle = iota
+	// QuestionPlaceholder -> "?"
+	QuestionPlaceholder
+	// DollarPlaceholder -> "$1"
+	DollarPlaceholder
+	// NamedPlaceholder -> ":name"
+	NamedPlaceholder
+)
+
+var (
+	// ErrMixedPlaceholders is returned for a statement with placeholders of different styles,
+	// their arguments have no common order
+	ErrMixedPlaceholders = errors.New("placeholders of different styles")
+	// ErrUnsupportedClause is returned for a clause the dialect doesn't have, e.g. RETURNING in MySQL
+	ErrUnsupportedClause = errors.New("clause not supported by the dialect")
+)
+
+// Printer renders a parsed Query, Expr or Table back to SQL.
+// Keywords are upper case and clauses are separated by single spaces.
+type Printer struct {
+	Dialect Dialect
+	// Placeholder is the style placeholders are rewritten to.
+	// "?" placeholders become $1, $2... in order, named ones keep one number per name.
+	// Rewriting changes the order of the arguments, QueryParams returns it.
+	Placeholder PlaceholderStyle
+	// QuoteAll quotes every identifier, otherwise only keywords,
+	// identifiers with special characters and those that were quoted are
+	QuoteAll bool
+}
+
+// NewPrinter returns a Printer with the placeholder style of the dialect
+func NewPrinter(dialect Dialect) *Printer {
+	pr := &Printer{Dialect: dialect}
+	switch dialect {
+	case MySQL, SQLite:
+		pr.Placeholder = QuestionPlaceholder
+	case PostgreSQL:
+		pr.Placeholder = DollarPlaceholder
+	}
+	return pr
+}
+
+var genericPrinter = &Printer{}
+
+// String formats the query back to SQL with the Generic printer
+func (q Query) String() string {
+	return genericPrinter.Query(q)
+}
+
+// Param is the argument of a bind parameter of a printed statement: Index is the 1-based position
+// of the argument of a "?" or the N of a "$N" in the parsed statement, Name the name of a ":name"
+type Param struct {
+	Index int
+	Name  string
+}
+
+// Query renders q, see QueryParams for the arguments of the placeholders
+func (pr *Printer) Query(q Query) string {
+	w := pr.newWriter()
+	w.query(&q)
+	return w.String()
+}
+
+// QueryParams renders q and returns the arguments to bind in order: one per "?", one per number of "$N"
+// and one per distinct ":name". It fails with ErrMixedPlaceholders when q has placeholders of different styles
+// and with ErrUnsupportedClause when the dialect can't express q, Query leaves such clauses out
+func (pr *Printer) QueryParams(q Query) (string, []Param, error) {
+	w := pr.newWriter()
+	w.query(&q)
+	if w.err != nil {
+		return "", nil, w.err
+	}
+	return w.String(), w.params, nil
+}
+
+// Expr renders e, placeholders are numbered from 1
+func (pr *Printer) Expr(e Expr) string {
+	w := pr.newWriter()
+	w.expr(e)
+	return w.String()
+}
+
+// Table renders t as a CREATE TABLE statement. For a specific dialect
+// AUTO_INCREMENT / AUTOINCREMENT is translated (SERIAL for PostgreSQL, an inline INTEGER PRIMARY KEY for SQLite)
+// and DATETIME becomes TIMESTAMP for PostgreSQL. The table level PRIMARY KEY is only printed when no column
+// already declares it. The MySQL COMMENT of the columns follow as COMMENT ON COLUMN statements for PostgreSQL,
+// SQLite has no column comments.
+func (pr *Printer) Table(t Table) string {
+	return strings.Join(pr.tableStatements(t), "\n")
+}
+
+// tableStatements returns CREATE TABLE followed by the COMMENT ON COLUMN statements of PostgreSQL
+func (pr *Printer) tableStatements(t Table) []string {
+	w := pr.newWriter()
+	w.WriteString("CREATE TABLE " + w.ident(t.Name) + " (\n")
+	lines := []string{}
+	hasPk := false
+	inlineFk := map[string]bool{}
+	comments := []string{}
+	for _, column := range t.Columns {
+		typ, constraints := pr.columnDef(column)
+		line := "\t" + w.ident(column.Name) + " " + typ
+		for _, item := range constraints {
+			hasPk = hasPk || strings.EqualFold(item, "PRIMARY KEY")
+			inlineFk[column.Name] = inlineFk[column.Name] || strings.EqualFold(item, "REFERENCES")
+			line += " " + item
+		}
+		lines = append(lines, line)
+		if comment := pr.columnComment(w, t.Name, column); comment != "" {
+			comments = append(comments, comment)
+		}
+	}
+	pk := t.PrimaryKey
+	if len(pk) == 0 && t.PkName != "" {
+		pk = []string{t.PkName}
+	}
+	if len(pk) > 0 && !hasPk {
+		lines = append(lines, "\tPRIMARY KEY ("+w.idents(pk)+")")
+	}
+	for _, index := range t.Indexes {
+		if index.Constraint {
+			lines = append(lines, "\t"+w.constraintName(index.Name)+"UNIQUE ("+w.idents(index.Columns)+")")
+		}
+	}
+	for _, fk := range t.ForeignKeys {
+		if len(fk.Columns) == 1 && inlineFk[fk.Columns[0]] {
+			continue
+		}
+		lines = append(lines, "\t"+w.foreignKey(fk))
+	}
+	for _, check := range t.Checks {
+		lines = append(lines, "\t"+w.constraintName(check.Name)+"CHECK ("+check.Expr+")")
+	}
+	w.WriteString(strings.Join(lines, ",\n"))
+	w.WriteString("\n);")
+	return append([]string{w.String()}, comments...)
+}
+
+// columnComment returns the COMMENT ON COLUMN statement of the MySQL COMMENT of a column for PostgreSQL, or ""
+func (pr *Printer) columnComment(w *printWriter, table string, column *Column) string {
+	comment, ok := constraintValue(column, "COMMENT")
+	if pr.Dialect != PostgreSQL || !ok {
+		return ""
+	}
+	return "COMMENT ON COLUMN " + w.identPath(table) + "." + w.ident(column.Name) + " IS " + comment + ";"
+}
+
+// Index returns CREATE [UNIQUE] INDEX name ON table (columns);
+// an index without name is named after the table and its columns
+func (pr *Printer) Index(table string, index *Index) string {
+	w := pr.newWriter()
+	w.WriteString("CREATE ")
+	if index.Unique {
+		w.WriteString("UNIQUE ")
+	}
+	w.WriteString("INDEX " + w.ident(indexName(table, index)) + " ON " + w.ident(table) + " (" + w.idents(index.Columns) + ");")
+	return w.String()
+}
+
+func indexName(table string, index *Index) string {
+	if index.Name != "" {
+		return index.Name
+	}
+	return "idx_" + strings.Trim(table, "`\"'") + "_" + strings.Join(index.Columns, "_")
+}
+
+// Schema returns the CREATE TABLE statements of all tables, each followed by the
+// CREATE INDEX statements of its indexes which are not table constraints
+func (pr *Printer) Schema(s *Schema) string {
+	stmts := []string{}
+	for _, t := range s.Tables {
+		stmts = append(stmts, pr.Table(*t))
+		for _, index := range t.Indexes {
+			if !index.Constraint {
+				stmts = append(stmts, pr.Index(t.Name, index))
+			}
+		}
+	}
+	return strings.Join(stmts, "\n\n")
+}
+
+func (pr *Printer) columnDef(column *Column) (string, []string) {
+	if pr.Dialect == Generic {
+		return column.Type, column.Constraints
+	}
+	typ := column.Type
+	constraints := make([]string, 0, len(column.Constraints))
+	autoIncrement := false
+	items := column.Constraints
+	for i := 0; i < len(items); i++ {
+		switch strings.ToUpper(items[i]) {
+		case "AUTO_INCREMENT", "AUTOINCREMENT":
+			autoIncrement = true
+		case "COMMENT":
+			// the comment is a statement of its own in PostgreSQL, SQLite has none
+			if pr.Dialect != MySQL && i+1 < len(items) && strings.HasPrefix(items[i+1], "'") {
+				i++
+			} else {
+				constraints = append(constraints, items[i])
+			}
+		default:
+			constraints = append(constraints, items[i])
+		}
+	}
+
+	upper := strings.ToUpper(typ)
+	switch pr.Dialect {
+	case MySQL:
+		if autoIncrement {
+			constraints = append(constraints, "AUTO_INCREMENT")
+		}
+	case SQLite:
+		if autoIncrement {
+			// only INTEGER PRIMARY KEY may be AUTOINCREMENT, and the primary key must be declared with it
+			typ = "INTEGER"
+			constraints = append([]string{"PRIMARY KEY", "AUTOINCREMENT"}, removeConstraint(constraints, "PRIMARY KEY", 0)...)
+		}
+	case PostgreSQL:
+		switch {
+		case autoIncrement && upper == "BIGINT":
+			typ = "BIGSERIAL"
+		case autoIncrement:
+			typ = "SERIAL"
+		case upper == "DATETIME":
+			typ = "TIMESTAMP"
+		}
+	}
+	return typ, constraints
+}
+
+type printWriter struct {
+	strings.Builder
+	pr *Printer
+	// count and named number placeholders for DollarPlaceholder
+	count int
+	named map[string]int
+	// params are the arguments of the placeholders printed, first is the first placeholder
+	params []Param
+	first  *Placeholder
+	// upsert rewrites the values proposed for insertion, EXCLUDED.column or VALUES(column) for MySQL
+	upsert bool
+	// err is the first statement the printer can't render faithfully
+	err error
+}
+
+func (pr *Printer) newWriter() *printWriter {
+	return &printWriter{pr: pr, named: map[string]int{}}
+}
+
+func (w *printWriter) query(q *Query) {
+	switch q.Type {
+	case Select:
+		w.WriteString("SELECT ")
+		if q.Distinct {
+			w.WriteString("DISTINCT ")
+		}
+		w.selectList(q)
+		w.WriteString(" FROM ")
+		w.tableRef(TableRef{Name: q.TableName, Alias: q.TableAlias, Subquery: q.Subquery})
+		for _, join := range q.Joins {
+			w.WriteString(" " + join.Type.String() + " ")
+			w.tableRef(join.Table)
+			if join.On != nil {
+				w.WriteString(" ON ")
+				w.expr(join.On)
+			}
+		}
+		w.where(q)
+		if len(q.GroupBy) > 0 {
+			w.WriteString(" GROUP BY ")
+			w.exprs(q.GroupBy)
+		}
+		if q.Having != nil {
+			w.WriteString(" HAVING ")
+			w.expr(q.Having)
+		}
+		w.orderLimit(q)
+	case Insert:
+		w.WriteString("INSERT ")
+		// MySQL has no ON CONFLICT DO NOTHING
+		if q.OnConflict != nil && q.OnConflict.DoNothing && w.pr.Dialect == MySQL {
+			w.WriteString("IGNORE ")
+		}
+		w.WriteString("INTO " + w.ident(q.TableName))
+		if len(q.Fields) > 0 {
+			w.WriteString(" (" + w.idents(q.Fields) + ")")
+		}
+		switch {
+		case q.Source != nil:
+			w.WriteString(" ")
+			w.query(q.Source)
+		case q.Values != nil:
+			w.WriteString(" VALUES ")
+			for i, row := range q.Values {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				w.WriteString("(")
+				w.exprs(row)
+				w.WriteString(")")
+			}
+		default:
+			w.WriteString(" VALUES ")
+			for i, row := range q.Inserts {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				values := make([]string, 0, len(row))
+				for _, value := range row {
+					values = append(values, w.quoteString(value))
+				}
+				w.WriteString("(" + strings.Join(values, ", ") + ")")
+			}
+		}
+		w.onConflict(q.OnConflict)
+		w.returning(q)
+	case Update:
+		w.WriteString("UPDATE " + w.ident(q.TableName) + " SET ")
+		if q.Assignments != nil {
+			w.assignments(q.Assignments)
+		} else {
+			fields := make([]string, 0, len(q.Updates))
+			for field := range q.Updates {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for i, field := range fields {
+				if i > 0 {
+					w.WriteString(", ")
+				}
+				w.WriteString(w.ident(field) + " = " + w.quoteString(q.Updates[field]))
+			}
+		}
+		w.tableRefs(" FROM ", q.From)
+		w.where(q)
+		w.returning(q)
+	case Delete:
+		w.WriteString("DELETE FROM " + w.ident(q.TableName))
+		w.tableRefs(" USING ", q.From)
+		w.where(q)
+		w.returning(q)
+	}
+}
+
+func (w *printWriter) assignments(assignments []Assignment) {
+	for i, item := range assignments {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteString(w.identPath(item.Field) + " = ")
+		w.expr(item.Value)
+	}
+}
+
+// onConflict writes ON DUPLICATE KEY UPDATE for MySQL and ON CONFLICT for the others.
+// The values proposed for insertion are VALUES(column) in MySQL, its row alias needs 8.0.19
+func (w *printWriter) onConflict(oc *OnConflict) {
+	w.upsert = w.pr.Dialect != Generic
+	defer func() { w.upsert = false }()
+	switch {
+	case oc == nil:
+	case w.pr.Dialect == MySQL:
+		if !oc.DoNothing {
+			w.WriteString(" ON DUPLICATE KEY UPDATE ")
+			w.assignments(oc.Assignments)
+		}
+	default:
+		w.WriteString(" ON CONFLICT")
+		if len(oc.Columns) > 0 {
+			w.WriteString(" (" + w.idents(oc.Columns) + ")")
+		}
+		if oc.DoNothing {
+			w.WriteString(" DO NOTHING")
+		} else {
+			w.WriteString(" DO UPDATE SET ")
+			w.assignments(oc.Assignments)
+		}
+	}
+}
+
+func (w *printWriter) returning(q *Query) {
+	if len(q.Returning) > 0 && w.pr.Dialect == MySQL {
+		w.fail(errors.Wrap(ErrUnsupportedClause, "RETURNING"))
+		return
+	}
+	for i, column := range q.Returning {
+		if i == 0 {
+			w.WriteString(" RETURNING ")
+		} else {
+			w.WriteString(", ")
+		}
+		w.expr(column.Expr)
+		if column.Alias != "" {
+			w.WriteString(" AS " + w.ident(column.Alias))
+		}
+	}
+}
+
+func (w *printWriter) tableRefs(keyword string, refs []TableRef) {
+	for i, ref := range refs {
+		if i == 0 {
+			w.WriteString(keyword)
+		} else {
+			w.WriteString(", ")
+		}
+		w.tableRef(ref)
+	}
+}
+
+func (w *printWriter) orderLimit(q *Query) {
+	for i, item := range q.OrderBy {
+		if i == 0 {
+			w.WriteString(" ORDER BY ")
+		} else {
+			w.WriteString(", ")
+		}
+		w.expr(item.Expr)
+		if item.Desc {
+			w.WriteString(" DESC")
+		}
+	}
+	if q.Limit != nil {
+		w.WriteString(" LIMIT ")
+		w.expr(q.Limit)
+	}
+	if q.Offset != nil {
+		w.WriteString(" OFFSET ")
+		w.expr(q.Offset)
+	}
+}
+
+func (w *printWriter) selectList(q *Query) {
+	if len(q.Columns) == 0 {
+		for i, field := range q.Fields {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.WriteString(w.ident(field))
+		}
+		return
+	}
+	for i, column := range q.Columns {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.expr(column.Expr)
+		if column.Alias != "" {
+			w.WriteString(" AS " + w.ident(column.Alias))
+		}
+	}
+}
+
+func (w *printWriter) where(q *Query) {
+	if q.Where != nil {
+		w.WriteString(" WHERE ")
+		w.expr(q.Where)
+	}
+}
+
+func (w *printWriter) tableRef(t TableRef) {
+	if t.Subquery != nil {
+		w.WriteString("(")
+		w.query(t.Subquery)
+		w.WriteString(")")
+	} else {
+		w.WriteString(w.identPath(t.Name))
+	}
+	if t.Alias != "" {
+		w.WriteString(" " + w.ident(t.Alias))
+	}
+}
+
+func (w *printWriter) exprs(exprs []Expr) {
+	for i, item := range exprs {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.expr(item)
+	}
+}
+
+func (w *printWriter) expr(e Expr) {
+	switch e := e.(type) {
+	case *LogicalExpr:
+		w.expr(e.Left)
+		w.WriteString(" " + e.Operator.String() + " ")
+		w.expr(e.Right)
+	case *NotExpr:
+		w.WriteString("NOT ")
+		w.expr(e.Expr)
+	case *ParenExpr:
+		w.WriteString("(")
+		w.expr(e.Expr)
+		w.WriteString(")")
+	case *ComparisonExpr:
+		w.comparison(e)
+	case *InExpr:
+		w.expr(e.Expr)
+		if e.Not {
+			w.WriteString(" NOT")
+		}
+		w.WriteString(" IN (")
+		if e.Subquery != nil {
+			w.query(e.Subquery)
+		} else {
+			w.exprs(e.List)
+		}
+		w.WriteString(")")
+	case *BetweenExpr:
+		w.expr(e.Expr)
+		if e.Not {
+			w.WriteString(" NOT")
+		}
+		w.WriteString(" BETWEEN ")
+		w.expr(e.Low)
+		w.WriteString(" AND ")
+		w.expr(e.High)
+	case *IsNullExpr:
+		w.expr(e.Expr)
+		if e.Not {
+			w.WriteString(" IS NOT NULL")
+		} else {
+			w.WriteString(" IS NULL")
+		}
+	case *ColumnRef:
+		if w.upsert && w.pr.Dialect == MySQL && strings.EqualFold(e.Table, "EXCLUDED") {
+			w.WriteString("VALUES(" + w.ident(e.Name) + ")")
+			return
+		}
+		if e.Table != "" {
+			w.WriteString(w.identPath(e.Table) + ".")
+		}
+		w.WriteString(w.ident(e.Name))
+	case *Literal:
+		if e.Kind == StringLiteral {
+			w.WriteString(w.quoteString(e.Value))
+		} else {
+			w.WriteString(e.Value)
+		}
+	case *FuncCall:
+		if column, ok := w.insertedValue(e); ok {
+			w.WriteString("EXCLUDED." + w.ident(column.Name))
+			return
+		}
+		w.WriteString(e.Name + "(")
+		switch {
+		case e.Star:
+			w.WriteString("*")
+		case e.Distinct:
+			w.WriteString("DISTINCT ")
+			w.exprs(e.Args)
+		default:
+			w.exprs(e.Args)
+		}
+		w.WriteString(")")
+	case *SubqueryExpr:
+		w.WriteString("(")
+		w.query(e.Query)
+		w.WriteString(")")
+	case *ExistsExpr:
+		w.WriteString("EXISTS (")
+		w.query(e.Query)
+		w.WriteString(")")
+	case *Placeholder:
+		w.placeholder(e)
+	case *BinaryExpr:
+		w.expr(e.Left)
+		w.WriteString(" " + e.Operator + " ")
+		w.expr(e.Right)
+	case *UnaryExpr:
+		w.WriteString(e.Operator)
+		// "--" would start a comment
+		if l, ok := e.Expr.(*Literal); ok && strings.HasPrefix(l.Value, "-") && l.Kind == NumberLiteral {
+			w.WriteString(" ")
+		} else if _, ok := e.Expr.(*UnaryExpr); ok {
+			w.WriteString(" ")
+		}
+		w.expr(e.Expr)
+	}
+}
+
+func (w *printWriter) comparison(e *ComparisonExpr) {
+	op := operatorSymbol[e.Operator]
+	if e.Not {
+		op = "NOT " + op
+	}
+	// ILIKE only exists in PostgreSQL
+	if e.Operator == ILike && (w.pr.Dialect == MySQL || w.pr.Dialect == SQLite) {
+		w.WriteString("LOWER(")
+		w.expr(e.Left)
+		w.WriteString(") " + strings.Replace(op, "ILIKE", "LIKE", 1) + " LOWER(")
+		w.expr(e.Right)
+		w.WriteString(")")
+		return
+	}
+	w.expr(e.Left)
+	w.WriteString(" " + op + " ")
+	w.expr(e.Right)
+}
+
+func (w *printWriter) placeholder(e *Placeholder) {
+	if w.first == nil {
+		w.first = e
+	} else if e.Style != w.first.Style {
+		w.fail(errors.Wrapf(ErrMixedPlaceholders, "%s and %s", w.first, e))
+	}
+	param := Param{Index: e.Index, Name: e.Name}
+	if e.Name != "" {
+		param.Index = 0
+	}
+	style := w.pr.Placeholder
+	if style == KeepPlaceholder {
+		style = e.Style
+	}
+	switch style {
+	case QuestionPlaceholder:
+		w.WriteString("?")
+		w.params = append(w.params, param)
+	case DollarPlaceholder:
+		index := e.Index
+		switch {
+		case e.Style == DollarPlaceholder:
+		case e.Name != "":
+			if _, ok := w.named[e.Name]; !ok {
+				w.count++
+				w.named[e.Name] = w.count
+			}
+			index = w.named[e.Name]
+		default:
+			w.count++
+			index = w.count
+		}
+		w.WriteString("$" + strconv.Itoa(index))
+		// the numbers of "$N" are kept, the arguments of the missing ones are still expected
+		for len(w.params) < index {
+			w.params = append(w.params, Param{Index: len(w.params) + 1})
+		}
+		if index > 0 {
+			w.params[index-1] = param
+		}
+	case NamedPlaceholder:
+		name := e.Name
+		if name == "" {
+			name = "p" + strconv.Itoa(e.Index)
+		}
+		if _, ok := w.named[name]; !ok {
+			w.named[name] = len(w.params)
+			w.params = append(w.params, param)
+		}
+		w.WriteString(":" + name)
+	}
+}
+
+// insertedValue returns the column of the VALUES(column) of MySQL to write as EXCLUDED.column for the other dialects
+func (w *printWriter) insertedValue(e *FuncCall) (*ColumnRef, bool) {
+	if !w.upsert || w.pr.Dialect == MySQL || !strings.EqualFold(e.Name, "VALUES") || len(e.Args) != 1 {
+		return nil, false
+	}
+	column, ok := e.Args[0].(*ColumnRef)
+	return column, ok && column.Table == ""
+}
+
+// fail keeps the first error of the statement
+func (w *printWriter) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// identPath quotes each part of a dotted name such as schema.table
+func (w *printWriter) identPath(s string) string {
+	if w.pr.Dialect == Generic || isQuotedIdentifier(s) || !strings.Contains(s, ".") {
+		return w.ident(s)
+	}
+	parts := strings.Split(s, ".")
+	for i, part := range parts {
+		parts[i] = w.ident(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// ident quotes an identifier for the dialect, Generic leaves it untouched
+func (w *printWriter) ident(s string) string {
+	if w.pr.Dialect == Generic || s == "" || s == "*" {
+		return s
+	}
+	quoted := isQuotedIdentifier(s)
+	if quoted {
+		s = s[1 : len(s)-1]
+	}
+	if !quoted && !w.pr.QuoteAll && isPlainIdentifier(s) && !sqlKeywords[strings.ToUpper(s)] {
+		return s
+	}
+	quote := `"`
+	if w.pr.Dialect == MySQL {
+		quote = "`"
+	}
+	return quote + strings.ReplaceAll(s, quote, quote+quote) + quote
+}
+
+// sqlKeywords are words that must be quoted when used as identifiers
+var sqlKeywords = map[string]bool{}
+
+func (w *printWriter) idents(names []string) string {
+	res := make([]string, len(names))
+	for i, name := range names {
+		res[i] = w.ident(name)
+	}
+	return strings.Join(res, ", ")
+}
+
+func (w *printWriter) constraintName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "CONSTRAINT " + w.ident(name) + " "
+}
+
+func (w *printWriter) foreignKey(fk *ForeignKey) string {
+	res := w.constraintName(fk.Name) + "FOREIGN KEY (" + w.idents(fk.Columns) + ") REFERENCES " + w.ident(fk.RefTable)
+	if len(fk.RefColumns) > 0 {
+		res += " (" + w.idents(fk.RefColumns) + ")"
+	}
+	if fk.OnDelete != "" {
+		res += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		res += " ON UPDATE " + fk.OnUpdate
+	}
+	return res
+}
+
+func init() {
+	for _, word := range strings.Fields(`ADD ALL ALTER AND ANY AS ASC BETWEEN BY CASE CHECK COLUMN CONSTRAINT CREATE
+		CROSS DEFAULT DELETE DESC DISTINCT DROP ELSE END EXISTS FALSE FOREIGN FROM FULL GROUP HAVING ILIKE IN INDEX
+		INNER INSERT INTO IS JOIN KEY LEFT LIKE LIMIT NOT NULL OFFSET ON OR ORDER OUTER PRIMARY REFERENCES RIGHT
+		SELECT SET TABLE THEN TO TRUE UNION UNIQUE UPDATE USER USING VALUES WHEN WHERE`) {
+		sqlKeywords[word] = true
+	}
+}
+
+func isPlainIdentifier(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentifierChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteString quotes a string value, quotes are doubled and MySQL also escapes backslashes
+func (w *printWriter) quoteString(s string) string {
+	if w.pr.Dialect == MySQL {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
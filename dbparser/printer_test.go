@@ -0,0 +1,198 @@
+package dbparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrinterQuery(t *testing.T) {
+	sql := `select u.id, "Name" as n, count(*) total from users u
+		left join "order" o on o.user_id = u.id
+		where u.email ilike ? and o.status in (?, ?) and u.id > :min
+		group by u.id, "Name" order by total desc limit 10`
+	ts := []struct {
+		Printer  *Printer
+		Expected string
+	}{
+		{
+			Printer:  &Printer{},
+			Expected: `SELECT u.id, "Name" AS n, count(*) AS total FROM users u LEFT JOIN "order" o ON o.user_id = u.id WHERE u.email ILIKE ? AND o.status IN (?, ?) AND u.id > :min GROUP BY u.id, "Name" ORDER BY total DESC LIMIT 10`,
+		},
+		{
+			Printer:  NewPrinter(MySQL),
+			Expected: "SELECT u.id, `Name` AS n, count(*) AS total FROM users u LEFT JOIN `order` o ON o.user_id = u.id WHERE LOWER(u.email) LIKE LOWER(?) AND o.status IN (?, ?) AND u.id > ? GROUP BY u.id, `Name` ORDER BY total DESC LIMIT 10",
+		},
+		{
+			Printer:  NewPrinter(PostgreSQL),
+			Expected: `SELECT u.id, "Name" AS n, count(*) AS total FROM users u LEFT JOIN "order" o ON o.user_id = u.id WHERE u.email ILIKE $1 AND o.status IN ($2, $3) AND u.id > $4 GROUP BY u.id, "Name" ORDER BY total DESC LIMIT 10`,
+		},
+		{
+			Printer:  &Printer{Dialect: SQLite, Placeholder: NamedPlaceholder, QuoteAll: true},
+			Expected: `SELECT "u"."id", "Name" AS "n", count(*) AS "total" FROM "users" "u" LEFT JOIN "order" "o" ON "o"."user_id" = "u"."id" WHERE LOWER("u"."email") LIKE LOWER(:p1) AND "o"."status" IN (:p2, :p3) AND "u"."id" > :min GROUP BY "u"."id", "Name" ORDER BY "total" DESC LIMIT 10`,
+		},
+	}
+	q, err := Parse(sql)
+	require.NoError(t, err)
+	for _, tc := range ts {
+		t.Run(tc.Printer.Dialect.String(), func(t *testing.T) {
+			require.Equal(t, tc.Expected, tc.Printer.Query(q))
+		})
+	}
+}
+
+func TestPrinterPlaceholders(t *testing.T) {
+	ts := []struct {
+		SQL      string
+		Printer  *Printer
+		Expected string
+		Params   []Param
+	}{
+		{
+			SQL:      "SELECT a FROM t WHERE a = $2 AND b = $1",
+			Printer:  NewPrinter(MySQL),
+			Expected: "SELECT a FROM t WHERE a = ? AND b = ?",
+			Params:   []Param{{Index: 2}, {Index: 1}},
+		},
+		{
+			SQL:      "SELECT a FROM t WHERE a = $3 AND b = $1",
+			Printer:  NewPrinter(PostgreSQL),
+			Expected: "SELECT a FROM t WHERE a = $3 AND b = $1",
+			Params:   []Param{{Index: 1}, {Index: 2}, {Index: 3}},
+		},
+		{
+			SQL:      "SELECT a FROM t WHERE a = :id OR b = :name OR c IN (SELECT c FROM u WHERE d = :id)",
+			Printer:  NewPrinter(PostgreSQL),
+			Expected: "SELECT a FROM t WHERE a = $1 OR b = $2 OR c IN (SELECT c FROM u WHERE d = $1)",
+			Params:   []Param{{Name: "id"}, {Name: "name"}},
+		},
+		{
+			SQL:      "SELECT a FROM t WHERE a = :id OR b = :name OR c IN (SELECT c FROM u WHERE d = :id)",
+			Printer:  NewPrinter(MySQL),
+			Expected: "SELECT a FROM t WHERE a = ? OR b = ? OR c IN (SELECT c FROM u WHERE d = ?)",
+			Params:   []Param{{Name: "id"}, {Name: "name"}, {Name: "id"}},
+		},
+		{
+			SQL:      "SELECT a FROM t WHERE a = ? OR b IN (?, ?)",
+			Printer:  &Printer{Placeholder: NamedPlaceholder},
+			Expected: "SELECT a FROM t WHERE a = :p1 OR b IN (:p2, :p3)",
+			Params:   []Param{{Index: 1}, {Index: 2}, {Index: 3}},
+		},
+		{
+			SQL:      "SELECT a FROM t WHERE a = ? OR b IN (?, ?)",
+			Printer:  NewPrinter(PostgreSQL),
+			Expected: "SELECT a FROM t WHERE a = $1 OR b IN ($2, $3)",
+			Params:   []Param{{Index: 1}, {Index: 2}, {Index: 3}},
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.Printer.Dialect.String()+": "+tc.SQL, func(t *testing.T) {
+			q, err := Parse(tc.SQL)
+			require.NoError(t, err)
+			printed, params, err := tc.Printer.QueryParams(q)
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, printed)
+			require.Equal(t, tc.Params, params)
+			require.Equal(t, tc.Expected, tc.Printer.Query(q))
+		})
+	}
+
+	// the arguments of mixed styles have no order
+	q, err := Parse("SELECT a FROM t WHERE a = :id OR c IN (SELECT c FROM u WHERE d = $7) OR e = ?")
+	require.NoError(t, err)
+	for _, pr := range []*Printer{genericPrinter, NewPrinter(MySQL), NewPrinter(PostgreSQL)} {
+		_, _, err = pr.QueryParams(q)
+		require.ErrorIs(t, err, ErrMixedPlaceholders)
+		require.EqualError(t, err, ":id and $7: placeholders of different styles")
+	}
+
+	q, err = Parse("SELECT a FROM t WHERE a = ? AND b = $2")
+	require.NoError(t, err)
+	require.Equal(t, []Condition{
+		{Operand1: "a", Operand1IsField: true, Operator: Eq, Operand2: "?"},
+		{Operand1: "b", Operand1IsField: true, Operator: Eq, Operand2: "$2"},
+	}, q.Conditions)
+}
+
+func TestPrinterRoundTrip(t *testing.T) {
+	sqls := []string{
+		"SELECT DISTINCT a, b AS `select` FROM `t` WHERE a NOT LIKE 'x%' AND b IS NOT NULL ORDER BY a LIMIT 5 OFFSET 10",
+		`SELECT t.n FROM (SELECT COUNT(DISTINCT user_id) AS n FROM orders WHERE total BETWEEN 1 AND 9) t`,
+		`SELECT * FROM a CROSS JOIN b WHERE EXISTS (SELECT 1 FROM c WHERE c.id = a.id) AND a.x = (SELECT max(y) FROM d)`,
+		`SELECT a FROM "user" WHERE name ILIKE :name OR (id IN (1, 2) AND NOT flag = TRUE)`,
+		`UPDATE 'a' SET b = 'x', "key" = 'it''s' WHERE id = ?`,
+		`INSERT INTO 'a' (b, c) VALUES ('1', '2'), ('3', '4')`,
+		`DELETE FROM 'a' WHERE b IS NULL`,
+	}
+	for _, dialect := range []Dialect{Generic, MySQL, PostgreSQL, SQLite} {
+		pr := NewPrinter(dialect)
+		for _, sql := range sqls {
+			t.Run(dialect.String()+": "+sql, func(t *testing.T) {
+				q, err := Parse(sql)
+				require.NoError(t, err)
+				printed := pr.Query(q)
+				again, err := Parse(printed)
+				require.NoError(t, err, printed)
+				require.Equal(t, printed, pr.Query(again))
+			})
+		}
+	}
+}
+
+func TestPrinterString(t *testing.T) {
+	q, err := Parse(`SELECT * FROM t WHERE a = 'O\'Brien' AND b = 'it''s' AND c LIKE 'a\%\\b'`)
+	require.NoError(t, err)
+	require.Equal(t, "O'Brien", q.Where.(*LogicalExpr).Left.(*LogicalExpr).Left.(*ComparisonExpr).Right.(*Literal).Value)
+	require.Equal(t, `SELECT * FROM t WHERE a = 'O''Brien' AND b = 'it''s' AND c LIKE 'a\%\b'`, NewPrinter(PostgreSQL).Query(q))
+	require.Equal(t, `SELECT * FROM t WHERE a = 'O''Brien' AND b = 'it''s' AND c LIKE 'a\%\b'`, NewPrinter(SQLite).Query(q))
+	require.Equal(t, `SELECT * FROM t WHERE a = 'O''Brien' AND b = 'it''s' AND c LIKE 'a\\%\\b'`, NewPrinter(MySQL).Query(q))
+
+	// the values of the legacy fields are escaped too
+	update := Query{Type: Update, TableName: "t", Updates: map[string]string{"a": "x' OR '1"}}
+	require.Equal(t, "UPDATE t SET a = 'x'' OR ''1'", NewPrinter(PostgreSQL).Query(update))
+	insert := Query{Type: Insert, TableName: "t", Fields: []string{"a"}, Inserts: [][]string{{`a\'`}}}
+	require.Equal(t, `INSERT INTO t (a) VALUES ('a\\''')`, NewPrinter(MySQL).Query(insert))
+
+	require.Equal(t, "Dialect(9)", Dialect(9).String())
+	require.Equal(t, "JoinType(-1)", JoinType(-1).String())
+	require.Equal(t, "Severity(3)", Severity(3).String())
+	require.Equal(t, "ChangeType(99)", ChangeType(99).String())
+}
+
+func TestPrinterTable(t *testing.T) {
+	table, err := ParserSql(`CREATE TABLE user (
+		id INT PRIMARY KEY AUTO_INCREMENT,
+		name VARCHAR(20) NOT NULL DEFAULT 'bob',
+		created_at DATETIME
+	);`)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE user (\n\tid INT PRIMARY KEY AUTO_INCREMENT,\n\tname VARCHAR(20) NOT NULL DEFAULT 'bob',\n\tcreated_at DATETIME\n);", table.ToSchema())
+	require.Equal(t, "CREATE TABLE \"user\" (\n\tid SERIAL PRIMARY KEY,\n\tname VARCHAR(20) NOT NULL DEFAULT 'bob',\n\tcreated_at TIMESTAMP\n);", NewPrinter(PostgreSQL).Table(table))
+	require.Equal(t, "CREATE TABLE \"user\" (\n\tid INTEGER PRIMARY KEY AUTOINCREMENT,\n\tname VARCHAR(20) NOT NULL DEFAULT 'bob',\n\tcreated_at DATETIME\n);", NewPrinter(SQLite).Table(table))
+
+	// PostgreSQL comments the columns with their own statements, SQLite declares an AUTOINCREMENT key inline
+	commented, err := ParserSql(`CREATE TABLE user (
+		id INT NOT NULL AUTO_INCREMENT COMMENT 'the id',
+		name VARCHAR(20) COMMENT 'the name',
+		PRIMARY KEY (id)
+	);`)
+	require.NoError(t, err)
+	require.Equal(t, "CREATE TABLE `user` (\n\tid INT NOT NULL COMMENT 'the id' AUTO_INCREMENT,\n\tname VARCHAR(20) COMMENT 'the name',\n\tPRIMARY KEY (id)\n);", NewPrinter(MySQL).Table(commented))
+	require.Equal(t, "CREATE TABLE \"user\" (\n\tid SERIAL NOT NULL,\n\tname VARCHAR(20),\n\tPRIMARY KEY (id)\n);\n"+
+		"COMMENT ON COLUMN \"user\".id IS 'the id';\nCOMMENT ON COLUMN \"user\".name IS 'the name';", NewPrinter(PostgreSQL).Table(commented))
+	require.Equal(t, "CREATE TABLE \"user\" (\n\tid INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,\n\tname VARCHAR(20)\n);", NewPrinter(SQLite).Table(commented))
+	require.Equal(t, []string{"ALTER TABLE \"user\" ADD COLUMN name VARCHAR(20);", "COMMENT ON COLUMN \"user\".name IS 'the name';"},
+		NewPrinter(PostgreSQL).Change(&Change{Type: AddColumnChange, Table: "user", Column: commented.Column("name")}))
+
+	// no PRIMARY KEY line without PkName
+	require.Equal(t, "CREATE TABLE t (\n\ta TEXT\n);", Table{Name: "t", Columns: []*Column{{Name: "a", Type: "TEXT"}}}.ToSchema())
+	require.Equal(t, "CREATE TABLE t (\n\ta TEXT,\n\tPRIMARY KEY (a)\n);", Table{Name: "t", PkName: "a", Columns: []*Column{{Name: "a", Type: "TEXT"}}}.ToSchema())
+
+	for _, dialect := range []Dialect{Generic, MySQL, PostgreSQL, SQLite} {
+		pr := NewPrinter(dialect)
+		printed := pr.Table(table)
+		again, err := ParserSql(printed)
+		require.NoError(t, err, printed)
+		require.Equal(t, printed, pr.Table(again))
+	}
+}
@@ -9,6 +9,8 @@ import (
 )
 
 // {name: 1, age: 18} => "name = ?, age = ?", 1, 18
+//
+// Deprecated: the keys are not checked, use FilterBuilder.FromJSON for a WHERE clause
 func GenQueryFromJson(data []byte) (string, []interface{}) {
 	args := map[string]interface{}{}
 	if err := json.Unmarshal(data, &args); err != nil {
@@ -23,6 +25,9 @@ func GenQueryFromJson(data []byte) (string, []interface{}) {
 	return strings.Join(parts, ","), res
 }
 
+// GenQueryFromQuery is GenQueryFromJson for url.Values
+//
+// Deprecated: the keys are not checked, use FilterBuilder.FromValues for a WHERE clause
 func GenQueryFromQuery(data url.Values) (string, []interface{}) {
 	res := []interface{}{}
 	parts := []string{}
@@ -100,7 +100,7 @@ func main() {
 	fmt.Println("Available endpoints:")
 	fmt.Println("  FLV stream: http://localhost:8080/stream/flv")
 	fmt.Println("  HLS stream: http://localhost:8080/hls/playlist.m3u8")
-	fmt.Println("  WebRTC stream: http://localhost:8080/webrtc/")
+	fmt.Println("  WebRTC stream (WHEP, POST an SDP offer): http://localhost:8080/webrtc/")
 	fmt.Println("  Status: http://localhost:8080/status")
 
 	// 10. 等待中断信号
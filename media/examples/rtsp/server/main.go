@@ -18,50 +18,14 @@ type CustomHandler struct {
 
 // HandleDESCRIBE overrides the default DESCRIBE handler
 func (h *CustomHandler) HandleDESCRIBE(session *server.Session, request *rtsp.Request) (*rtsp.Response, error) {
-	response := &rtsp.Response{
-		Version:    "RTSP/1.0",
-		StatusCode: rtsp.StatusOK,
-		StatusText: rtsp.StatusText(rtsp.StatusOK),
-		Header:     make(rtsp.Header),
-	}
-	response.Header.Set("Content-Type", "application/sdp")
-
-	// Custom SDP for our test stream
-	sdp := `v=0
-` +
-		`o=- 12345 12345 IN IP4 127.0.0.1
-` +
-		`s=GoRTSP Test Server
-` +
-		`t=0 0
-` +
-		`m=video 0 RTP/AVP 96
-` +
-		`c=IN IP4 0.0.0.0
-` +
-		`a=rtpmap:96 H264/90000
-` +
-		`a=control:streamid=0
-`
-	response.Body = []byte(sdp)
-
 	fmt.Printf("Custom DESCRIBE handler called for URI: %s\n", request.URI)
-	return response, nil
+	return h.DefaultHandler.HandleDESCRIBE(session, request)
 }
 
 // HandlePLAY overrides the default PLAY handler
 func (h *CustomHandler) HandlePLAY(session *server.Session, request *rtsp.Request) (*rtsp.Response, error) {
-	response := &rtsp.Response{
-		Version:    "RTSP/1.0",
-		StatusCode: rtsp.StatusOK,
-		StatusText: rtsp.StatusText(rtsp.StatusOK),
-		Header:     make(rtsp.Header),
-	}
-	response.Header.Set("Session", session.ID)
-	response.Header.Set("RTP-Info", "url=rtsp://localhost:554/test;seq=0;rtptime=0")
-
 	fmt.Printf("Custom PLAY handler called for session: %s\n", session.ID)
-	return response, nil
+	return h.DefaultHandler.HandlePLAY(session, request)
 }
 
 func main() {
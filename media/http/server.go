@@ -128,7 +128,8 @@ func (s *VideoServer) serveDirectory(w http.ResponseWriter, r *http.Request, dir
 		`, urlPath, urlPath)
 
 	for _, entry := range entries {
-		entryPath := filepath.Join(urlPath, entry.Name())
+		// 使用绝对路径，避免子目录中的相对链接重复拼接路径
+		entryPath := "/" + filepath.ToSlash(filepath.Join(urlPath, entry.Name()))
 		if entry.IsDir() {
 			fmt.Fprintf(w, "<li><a href=\"%s/\">%s/</a></li>\n", entryPath, entry.Name())
 		} else {
@@ -194,6 +195,17 @@ func (s *VideoServer) serveVideoFile(w http.ResponseWriter, r *http.Request, fil
 		return
 	}
 
+	// 后缀范围 bytes=-N 表示最后N个字节
+	if parts[0] == "" {
+		var suffix int64
+		if _, err := fmt.Sscanf(parts[1], "%d", &suffix); err != nil || suffix <= 0 {
+			http.Error(w, "无效的范围请求", http.StatusBadRequest)
+			return
+		}
+		start = max(fileSize-suffix, 0)
+		parts[1] = ""
+	}
+
 	// 解析起始位置
 	if parts[0] != "" {
 		if _, err := fmt.Sscanf(parts[0], "%d", &start); err != nil || start < 0 {
@@ -334,6 +346,8 @@ func getContentType(filePath string) string {
 		return "video/x-flv"
 	case ".mkv":
 		return "video/x-matroska"
+	case ".json":
+		return "application/json"
 	default:
 		return "application/octet-stream"
 	}
@@ -0,0 +1,284 @@
+package rtsp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Authentication schemes
+const (
+	AuthBasic  = "Basic"
+	AuthDigest = "Digest"
+)
+
+// Digest algorithms (RFC 7616 3.3)
+const (
+	AlgorithmMD5        = "MD5"
+	AlgorithmMD5Sess    = "MD5-sess"
+	AlgorithmSHA256     = "SHA-256"
+	AlgorithmSHA256Sess = "SHA-256-sess"
+)
+
+// Challenge is a challenge of the WWW-Authenticate header
+type Challenge struct {
+	Scheme string
+	Realm  string
+	// Nonce, Opaque, Algorithm, QOP and Stale are Digest parameters
+	Nonce     string
+	Opaque    string
+	Algorithm string
+	QOP       string
+	Stale     bool
+}
+
+// Authorization is the value of the Authorization header
+type Authorization struct {
+	Scheme   string
+	Username string
+	// Password is sent by Basic only
+	Password string
+	// Realm, Nonce, URI, Response, Algorithm, Opaque, QOP, NC and CNonce are Digest parameters
+	Realm     string
+	Nonce     string
+	URI       string
+	Response  string
+	Algorithm string
+	Opaque    string
+	QOP       string
+	NC        string
+	CNonce    string
+}
+
+// ParseChallenge parses a WWW-Authenticate header
+func ParseChallenge(header string) (*Challenge, error) {
+	scheme, params, err := parseAuthParams(header)
+	if err != nil {
+		return nil, err
+	}
+	c := &Challenge{
+		Scheme:    scheme,
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		Opaque:    params["opaque"],
+		Algorithm: params["algorithm"],
+		QOP:       params["qop"],
+		Stale:     strings.EqualFold(params["stale"], "true"),
+	}
+	if c.Scheme == AuthDigest {
+		if c.Nonce == "" {
+			return nil, fmt.Errorf("digest challenge without nonce")
+		}
+		if newDigestHash(c.Algorithm) == nil {
+			return nil, fmt.Errorf("unsupported digest algorithm: %s", c.Algorithm)
+		}
+	}
+	return c, nil
+}
+
+// ParseAuthorization parses an Authorization header
+func ParseAuthorization(header string) (*Authorization, error) {
+	scheme, params, err := parseAuthParams(header)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == AuthBasic {
+		data, err := base64.StdEncoding.DecodeString(params[""])
+		if err != nil {
+			return nil, fmt.Errorf("invalid basic credentials: %v", err)
+		}
+		username, password, ok := strings.Cut(string(data), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid basic credentials")
+		}
+		return &Authorization{Scheme: scheme, Username: username, Password: password}, nil
+	}
+	return &Authorization{
+		Scheme:    scheme,
+		Username:  params["username"],
+		Realm:     params["realm"],
+		Nonce:     params["nonce"],
+		URI:       params["uri"],
+		Response:  params["response"],
+		Algorithm: params["algorithm"],
+		Opaque:    params["opaque"],
+		QOP:       params["qop"],
+		NC:        params["nc"],
+		CNonce:    params["cnonce"],
+	}, nil
+}
+
+// parseAuthParams parses `scheme a=b, c="d"`, the token68 of Basic is the parameter without name
+func parseAuthParams(header string) (string, map[string]string, error) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	switch {
+	case strings.EqualFold(scheme, AuthBasic):
+		scheme = AuthBasic
+	case strings.EqualFold(scheme, AuthDigest):
+		scheme = AuthDigest
+	default:
+		return "", nil, fmt.Errorf("unsupported authentication scheme: %s", scheme)
+	}
+	params := map[string]string{}
+	rest = strings.TrimSpace(rest)
+	if scheme == AuthBasic && !strings.Contains(rest, "=\"") && !strings.Contains(rest, "realm=") {
+		params[""] = rest
+		return scheme, params, nil
+	}
+	for rest != "" {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid authentication parameter: %s", rest)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			end := strings.IndexByte(value[1:], '"')
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated quoted value of %s", key)
+			}
+			params[key] = value[1 : end+1]
+			value = value[end+2:]
+		} else {
+			end := strings.IndexByte(value, ',')
+			if end < 0 {
+				end = len(value)
+			}
+			params[key] = strings.TrimSpace(value[:end])
+			value = value[end:]
+		}
+		rest = strings.TrimLeft(value, ", ")
+	}
+	return scheme, params, nil
+}
+
+// String serializes the challenge
+func (c *Challenge) String() string {
+	if c.Scheme == AuthBasic {
+		return fmt.Sprintf(`Basic realm="%s"`, c.Realm)
+	}
+	parts := []string{fmt.Sprintf(`realm="%s"`, c.Realm), fmt.Sprintf(`nonce="%s"`, c.Nonce)}
+	if c.Opaque != "" {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, c.Opaque))
+	}
+	if c.Algorithm != "" {
+		parts = append(parts, "algorithm="+c.Algorithm)
+	}
+	if c.QOP != "" {
+		parts = append(parts, fmt.Sprintf(`qop="%s"`, c.QOP))
+	}
+	if c.Stale {
+		parts = append(parts, "stale=true")
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+// String serializes the authorization
+func (a *Authorization) String() string {
+	if a.Scheme == AuthBasic {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password))
+	}
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, a.Username),
+		fmt.Sprintf(`realm="%s"`, a.Realm),
+		fmt.Sprintf(`nonce="%s"`, a.Nonce),
+		fmt.Sprintf(`uri="%s"`, a.URI),
+		fmt.Sprintf(`response="%s"`, a.Response),
+	}
+	if a.Algorithm != "" {
+		parts = append(parts, "algorithm="+a.Algorithm)
+	}
+	if a.Opaque != "" {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, a.Opaque))
+	}
+	if a.QOP != "" {
+		parts = append(parts, "qop="+a.QOP, "nc="+a.NC, fmt.Sprintf(`cnonce="%s"`, a.CNonce))
+	}
+	return "Digest " + strings.Join(parts, ", ")
+}
+
+// Authorize answers the challenge for a request, nc counts the requests with the nonce starting from 1
+func (c *Challenge) Authorize(username, password string, method Method, uri string, nc int) *Authorization {
+	if c.Scheme == AuthBasic {
+		return &Authorization{Scheme: AuthBasic, Username: username, Password: password}
+	}
+	a := &Authorization{
+		Scheme:    AuthDigest,
+		Username:  username,
+		Realm:     c.Realm,
+		Nonce:     c.Nonce,
+		URI:       uri,
+		Algorithm: c.Algorithm,
+		Opaque:    c.Opaque,
+	}
+	// auth-int would need the body, only auth is answered
+	for _, qop := range strings.Split(c.QOP, ",") {
+		if strings.TrimSpace(qop) == "auth" {
+			a.QOP = "auth"
+			a.NC = fmt.Sprintf("%08x", nc)
+			a.CNonce = newNonce(8)
+		}
+	}
+	a.Response = a.digest(password, method)
+	return a
+}
+
+// Verify reports whether the authorization carries the password for the method
+func (a *Authorization) Verify(password string, method Method) bool {
+	if a.Scheme == AuthBasic {
+		return subtle.ConstantTimeCompare([]byte(a.Password), []byte(password)) == 1
+	}
+	expected := a.digest(password, method)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(a.Response))) == 1
+}
+
+// digest computes the Digest response (RFC 7616 3.4.1)
+func (a *Authorization) digest(password string, method Method) string {
+	h := newDigestHash(a.Algorithm)
+	if h == nil {
+		return ""
+	}
+	sum := func(parts ...string) string {
+		h.Reset()
+		h.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	ha1 := sum(a.Username, a.Realm, password)
+	if strings.HasSuffix(strings.ToLower(a.Algorithm), "-sess") {
+		ha1 = sum(ha1, a.Nonce, a.CNonce)
+	}
+	ha2 := sum(string(method), a.URI)
+	if a.QOP == "" {
+		return sum(ha1, a.Nonce, ha2)
+	}
+	return sum(ha1, a.Nonce, a.NC, a.CNonce, a.QOP, ha2)
+}
+
+// newDigestHash returns the hash of an algorithm, MD5 when empty, nil when unsupported
+func newDigestHash(algorithm string) hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case "", strings.ToUpper(AlgorithmMD5), strings.ToUpper(AlgorithmMD5Sess):
+		return md5.New()
+	case AlgorithmSHA256, strings.ToUpper(AlgorithmSHA256Sess):
+		return sha256.New()
+	}
+	return nil
+}
+
+// newNonce returns n random bytes in hex
+func newNonce(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewNonce returns a random nonce for Digest challenges
+func NewNonce() string {
+	return newNonce(16)
+}
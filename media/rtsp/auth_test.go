@@ -0,0 +1,72 @@
+package rtsp
+
+import (
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	// examples of RFC 2617 3.5 and RFC 7616 3.9.1
+	tests := []struct {
+		Challenge string
+		Password  string
+		CNonce    string
+		Response  string
+	}{
+		{
+			`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			"Circle Of Life", "0a4f113b", "6629fae49393a05397450978507c4ef1",
+		},
+		{
+			`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+			"Circle of Life", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		},
+	}
+	for _, tt := range tests {
+		c, err := ParseChallenge(tt.Challenge)
+		if err != nil {
+			t.Fatalf("Failed to parse challenge: %v", err)
+		}
+		if c.Scheme != AuthDigest || c.Opaque == "" || c.QOP == "" {
+			t.Errorf("Unexpected challenge %+v", c)
+		}
+		a := c.Authorize("Mufasa", tt.Password, "GET", "/dir/index.html", 1)
+		if a.QOP != "auth" || a.NC != "00000001" {
+			t.Errorf("Unexpected qop %s nc %s", a.QOP, a.NC)
+		}
+		a.CNonce = tt.CNonce
+		a.Response = a.digest(tt.Password, "GET")
+		if a.Response != tt.Response {
+			t.Errorf("Expected response %s, got %s", tt.Response, a.Response)
+		}
+
+		parsed, err := ParseAuthorization(a.String())
+		if err != nil {
+			t.Fatalf("Failed to parse authorization: %v", err)
+		}
+		if *parsed != *a {
+			t.Errorf("Expected %+v, got %+v", a, parsed)
+		}
+		if !parsed.Verify(tt.Password, "GET") || parsed.Verify("wrong", "GET") || parsed.Verify(tt.Password, "POST") {
+			t.Error("Unexpected verification")
+		}
+	}
+
+	if _, err := ParseChallenge(`Digest realm="r", nonce="n", algorithm=SHA-512-256`); err == nil {
+		t.Error("Expected an error for an unsupported algorithm")
+	}
+}
+
+func TestBasic(t *testing.T) {
+	c, err := ParseChallenge(`Basic realm="camera"`)
+	if err != nil || c.Scheme != AuthBasic || c.Realm != "camera" {
+		t.Fatalf("Unexpected challenge %+v %v", c, err)
+	}
+	header := c.Authorize("admin", "12345", MethodDESCRIBE, "rtsp://camera/live", 1).String()
+	if header != "Basic YWRtaW46MTIzNDU=" {
+		t.Errorf("Unexpected authorization %s", header)
+	}
+	a, err := ParseAuthorization(header)
+	if err != nil || a.Username != "admin" || !a.Verify("12345", MethodDESCRIBE) {
+		t.Errorf("Unexpected authorization %+v %v", a, err)
+	}
+}
@@ -2,20 +2,47 @@ package client
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/wwqdrh/gokit/media/rtsp"
 )
 
+// DefaultTimeout is the time to wait for a response once the client reads in the background
+const DefaultTimeout = 10 * time.Second
+
 // Client represents an RTSP client
 type Client struct {
 	conn       net.Conn
 	cseq       int
 	sessionID  string
+	// sessionTimeout is the timeout of the Session header, 0 when unspecified
+	sessionTimeout time.Duration
 	baseURI    string
 	transport  string
 	bufferedReader *bufio.Reader
+
+	// Timeout is the time to wait for the connection and for a response after StartReading, DefaultTimeout when 0
+	Timeout time.Duration
+
+	// requestMu allows one request at a time, writeMu serializes requests and interleaved frames
+	requestMu    sync.Mutex
+	writeMu      sync.Mutex
+	frameHandler func(*rtsp.InterleavedFrame)
+	responses    chan *rtsp.Response
+	readErr      error
+
+	// challenge is the last challenge of the server, nc counts the requests answering it
+	username  string
+	password  string
+	challenge *rtsp.Challenge
+	nc        int
 }
 
 // NewClient creates a new RTSP client
@@ -25,9 +52,15 @@ func NewClient() *Client {
 	}
 }
 
-// Connect establishes a connection to the RTSP server
+// Connect establishes a connection to the RTSP server, waiting for Timeout or DefaultTimeout
 func (c *Client) Connect(addr string) error {
-	conn, err := net.Dial("tcp", addr)
+	return c.ConnectContext(context.Background(), addr)
+}
+
+// ConnectContext establishes a connection to the RTSP server like Connect, giving up when ctx is done
+func (c *Client) ConnectContext(ctx context.Context, addr string) error {
+	dialer := net.Dialer{Timeout: c.timeout()}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return err
 	}
@@ -47,6 +80,26 @@ func (c *Client) Close() error {
 
 // SendRequest sends an RTSP request and returns the response
 func (c *Client) SendRequest(method rtsp.Method, uri string, header rtsp.Header, body []byte) (*rtsp.Response, error) {
+	c.requestMu.Lock()
+	defer c.requestMu.Unlock()
+
+	response, err := c.do(method, uri, header, body)
+	if err != nil || response.StatusCode != rtsp.StatusUnauthorized || c.username == "" {
+		return response, err
+	}
+
+	// Answer the challenge once, the credentials sent with the same nonce are wrong unless it's stale
+	challenge := selectChallenge(response.Header.Values("WWW-Authenticate"))
+	if challenge == nil || (c.challenge != nil && c.challenge.Nonce == challenge.Nonce && !challenge.Stale) {
+		return response, nil
+	}
+	c.challenge = challenge
+	c.nc = 0
+	return c.do(method, uri, header, body)
+}
+
+// do sends a request once and reads its response
+func (c *Client) do(method rtsp.Method, uri string, header rtsp.Header, body []byte) (*rtsp.Response, error) {
 	// Create request
 	request := &rtsp.Request{
 		Method:  method,
@@ -65,6 +118,12 @@ func (c *Client) SendRequest(method rtsp.Method, uri string, header rtsp.Header,
 		request.Header.Set("Session", c.sessionID)
 	}
 
+	// Authorize with the last challenge
+	if c.challenge != nil {
+		c.nc++
+		request.Header.Set("Authorization", c.challenge.Authorize(c.username, c.password, method, uri, c.nc).String())
+	}
+
 	// Add user-provided headers
 	for key, values := range header {
 		for _, value := range values {
@@ -78,8 +137,7 @@ func (c *Client) SendRequest(method rtsp.Method, uri string, header rtsp.Header,
 		return nil, err
 	}
 
-	_, err = c.conn.Write(data)
-	if err != nil {
+	if err := c.write(data); err != nil {
 		return nil, err
 	}
 
@@ -87,19 +145,150 @@ func (c *Client) SendRequest(method rtsp.Method, uri string, header rtsp.Header,
 	c.cseq++
 
 	// Read and parse response
-	response, err := rtsp.ParseResponse(c.bufferedReader)
+	response, err := c.readResponse()
 	if err != nil {
 		return nil, err
 	}
 
-	// Update session ID if present
-	if sessionID := response.Header.Get("Session"); sessionID != "" {
-		c.sessionID = sessionID
+	// Update session ID if present, without its parameters
+	if session := response.Header.Get("Session"); session != "" {
+		id, params, _ := strings.Cut(session, ";")
+		c.sessionID = strings.TrimSpace(id)
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if seconds, err := strconv.Atoi(value); strings.EqualFold(key, "timeout") && err == nil && seconds > 0 {
+				c.sessionTimeout = time.Duration(seconds) * time.Second
+			}
+		}
 	}
 
 	return response, nil
 }
 
+// SetCredentials sets the credentials answering the Basic or Digest challenges of the server
+func (c *Client) SetCredentials(username, password string) {
+	c.requestMu.Lock()
+	defer c.requestMu.Unlock()
+	c.username = username
+	c.password = password
+	c.challenge = nil
+}
+
+// selectChallenge returns the strongest supported challenge: Digest with SHA-256, Digest then Basic
+func selectChallenge(headers []string) *rtsp.Challenge {
+	var res *rtsp.Challenge
+	rank := func(c *rtsp.Challenge) int {
+		switch {
+		case c.Scheme == rtsp.AuthBasic:
+			return 1
+		case strings.HasPrefix(strings.ToUpper(c.Algorithm), rtsp.AlgorithmSHA256):
+			return 3
+		default:
+			return 2
+		}
+	}
+	for _, header := range headers {
+		challenge, err := rtsp.ParseChallenge(header)
+		if err != nil {
+			continue
+		}
+		if res == nil || rank(challenge) > rank(res) {
+			res = challenge
+		}
+	}
+	return res
+}
+
+func (c *Client) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return errors.New("not connected")
+	}
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// WriteFrame sends an RTP or RTCP packet on an interleaved channel
+func (c *Client) WriteFrame(channel uint8, payload []byte) error {
+	frame := &rtsp.InterleavedFrame{Channel: channel, Payload: payload}
+	return c.write(frame.Marshal())
+}
+
+// SetFrameHandler sets the handler of the interleaved frames received on the connection,
+// frames are dropped without a handler. It must be set before StartReading
+func (c *Client) SetFrameHandler(handler func(*rtsp.InterleavedFrame)) {
+	c.frameHandler = handler
+}
+
+// StartReading reads the connection in the background, passing interleaved frames to the frame handler
+// as they arrive while SendRequest waits for responses. Use it once RTP is interleaved, after PLAY or RECORD
+func (c *Client) StartReading() {
+	if c.responses != nil {
+		return
+	}
+	responses := make(chan *rtsp.Response, 1)
+	c.responses = responses
+	go func() {
+		defer close(responses)
+		for {
+			response, err := c.readMessage()
+			if err != nil {
+				// readErr is read once responses is closed
+				c.readErr = err
+				return
+			}
+			responses <- response
+		}
+	}()
+}
+
+// readResponse returns the next response, from the background reader after StartReading
+func (c *Client) readResponse() (*rtsp.Response, error) {
+	if c.responses == nil {
+		return c.readMessage()
+	}
+	timeout := c.timeout()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case response, ok := <-c.responses:
+		if !ok {
+			return nil, fmt.Errorf("connection closed: %v", c.readErr)
+		}
+		return response, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("no response in %v", timeout)
+	}
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// readMessage reads the next response, handling the interleaved frames before it
+func (c *Client) readMessage() (*rtsp.Response, error) {
+	for {
+		isFrame, err := rtsp.IsInterleavedFrame(c.bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		if !isFrame {
+			return rtsp.ParseResponse(c.bufferedReader)
+		}
+		frame, err := rtsp.ReadInterleavedFrame(c.bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		if c.frameHandler != nil {
+			c.frameHandler(frame)
+		}
+	}
+}
+
 // Options sends an OPTIONS request
 func (c *Client) Options() (*rtsp.Response, error) {
 	return c.SendRequest(rtsp.MethodOPTIONS, c.baseURI, nil, nil)
@@ -117,7 +306,11 @@ func (c *Client) Setup(uri string, transport string) (*rtsp.Response, error) {
 	header := make(rtsp.Header)
 	header.Set("Transport", transport)
 	response, err := c.SendRequest(rtsp.MethodSETUP, uri, header, nil)
-	if err == nil {
+	if err != nil {
+		return nil, err
+	}
+	c.transport = response.Header.Get("Transport")
+	if c.transport == "" {
 		c.transport = transport
 	}
 	return response, nil
@@ -136,9 +329,10 @@ func (c *Client) Pause(uri string) (*rtsp.Response, error) {
 // Teardown sends a TEARDOWN request
 func (c *Client) Teardown(uri string) (*rtsp.Response, error) {
 	response, err := c.SendRequest(rtsp.MethodTEARDOWN, uri, nil, nil)
-	if err == nil {
-		c.sessionID = ""
+	if err != nil {
+		return nil, err
 	}
+	c.sessionID = ""
 	return response, nil
 }
 
@@ -169,6 +363,12 @@ func (c *Client) SessionID() string {
 	return c.sessionID
 }
 
+// SessionTimeout returns the timeout of the session given by the server, 0 when unspecified.
+// The session is kept alive by a request within it
+func (c *Client) SessionTimeout() time.Duration {
+	return c.sessionTimeout
+}
+
 // CSeq returns the current CSeq value
 func (c *Client) CSeq() int {
 	return c.cseq
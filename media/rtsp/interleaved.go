@@ -0,0 +1,55 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// InterleavedMagic starts an interleaved frame on the RTSP connection (RFC 2326 10.12)
+const InterleavedMagic = '$'
+
+// InterleavedFrame is an RTP or RTCP packet sent on the RTSP connection
+type InterleavedFrame struct {
+	Channel uint8
+	Payload []byte
+}
+
+// Marshal serializes the frame as "$" channel length payload
+func (f *InterleavedFrame) Marshal() []byte {
+	data := make([]byte, 4+len(f.Payload))
+	data[0] = InterleavedMagic
+	data[1] = f.Channel
+	binary.BigEndian.PutUint16(data[2:], uint16(len(f.Payload)))
+	copy(data[4:], f.Payload)
+	return data
+}
+
+// IsInterleavedFrame reports whether the next message of the reader is an interleaved frame
+func IsInterleavedFrame(reader *bufio.Reader) (bool, error) {
+	b, err := reader.Peek(1)
+	if err != nil {
+		return false, err
+	}
+	return b[0] == InterleavedMagic, nil
+}
+
+// ReadInterleavedFrame reads an interleaved frame
+func ReadInterleavedFrame(reader *bufio.Reader) (*InterleavedFrame, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[0] != InterleavedMagic {
+		return nil, fmt.Errorf("invalid interleaved frame magic %q", header[0])
+	}
+	f := &InterleavedFrame{
+		Channel: header[1],
+		Payload: make([]byte, binary.BigEndian.Uint16(header[2:])),
+	}
+	if _, err := io.ReadFull(reader, f.Payload); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"net/textproto"
 	"strconv"
 	"strings"
@@ -49,7 +50,7 @@ func ParseRequest(reader *bufio.Reader) (*Request, error) {
 		length, err := strconv.Atoi(contentLength)
 		if err == nil && length > 0 {
 			request.Body = make([]byte, length)
-			_, err = reader.Read(request.Body)
+			_, err = io.ReadFull(reader, request.Body)
 			if err != nil {
 				return nil, err
 			}
@@ -100,7 +101,7 @@ func ParseResponse(reader *bufio.Reader) (*Response, error) {
 		length, err := strconv.Atoi(contentLength)
 		if err == nil && length > 0 {
 			response.Body = make([]byte, length)
-			_, err = reader.Read(response.Body)
+			_, err = io.ReadFull(reader, response.Body)
 			if err != nil {
 				return nil, err
 			}
@@ -123,3 +123,29 @@ func TestHeaderOperations(t *testing.T) {
 		t.Errorf("Expected first Accept value to be application/sdp, got %s", header.Get("Accept"))
 	}
 }
+
+func TestInterleavedFrame(t *testing.T) {
+	frame := &InterleavedFrame{Channel: 1, Payload: []byte{0x80, 0x60, 0, 1}}
+	response := "RTSP/1.0 200 OK\r\nCSeq: 3\r\n\r\n"
+	reader := bufio.NewReader(bytes.NewReader(append(frame.Marshal(), response...)))
+
+	isFrame, err := IsInterleavedFrame(reader)
+	if err != nil || !isFrame {
+		t.Fatalf("Expected an interleaved frame, got %v %v", isFrame, err)
+	}
+	parsed, err := ReadInterleavedFrame(reader)
+	if err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	if parsed.Channel != 1 || !bytes.Equal(parsed.Payload, frame.Payload) {
+		t.Errorf("Expected %+v, got %+v", frame, parsed)
+	}
+
+	// the response follows the frame
+	if isFrame, _ := IsInterleavedFrame(reader); isFrame {
+		t.Fatal("Expected a response after the frame")
+	}
+	if parsedResponse, err := ParseResponse(reader); err != nil || parsedResponse.Header.Get("CSeq") != "3" {
+		t.Errorf("Failed to parse response after the frame: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// box is the header of an ISO BMFF box
+type box struct {
+	typ    string
+	offset int64 // offset of the header
+	size   int64 // size including the header
+	header int64 // size of the header, 8 or 16
+}
+
+// body returns the offset and size of the content
+func (b box) body() (int64, int64) {
+	return b.offset + b.header, b.size - b.header
+}
+
+// readBoxes reads the headers of the boxes in [offset, end)
+func readBoxes(r io.ReaderAt, offset, end int64) ([]box, error) {
+	res := []box{}
+	buf := make([]byte, 16)
+	for offset+8 <= end {
+		if _, err := r.ReadAt(buf[:8], offset); err != nil {
+			return nil, fmt.Errorf("read box header at %d: %v", offset, err)
+		}
+		b := box{
+			typ:    string(buf[4:8]),
+			offset: offset,
+			size:   int64(binary.BigEndian.Uint32(buf)),
+			header: 8,
+		}
+		switch b.size {
+		case 0:
+			// the box extends to the end
+			b.size = end - offset
+		case 1:
+			if _, err := r.ReadAt(buf[8:16], offset+8); err != nil {
+				return nil, fmt.Errorf("read box size at %d: %v", offset, err)
+			}
+			b.size = int64(binary.BigEndian.Uint64(buf[8:16]))
+			b.header = 16
+		}
+		if b.size < b.header || offset+b.size > end {
+			return nil, fmt.Errorf("invalid size %d of box %q at %d", b.size, b.typ, offset)
+		}
+		res = append(res, b)
+		offset += b.size
+	}
+	return res, nil
+}
+
+// findBox returns the first box of the type
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// readBody reads the content of a box which is not a container, e.g. stts
+func readBody(r io.ReaderAt, b box) ([]byte, error) {
+	offset, size := b.body()
+	if size > maxBoxBody {
+		return nil, fmt.Errorf("box %q is too large: %d bytes", b.typ, size)
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, offset); err != nil {
+		return nil, fmt.Errorf("read box %q: %v", b.typ, err)
+	}
+	return data, nil
+}
+
+// maxBoxBody limits the size of a box read into memory, sample tables of long files are a few MB
+const maxBoxBody = 256 << 20
+
+// reader reads big endian fields of a box body and remembers the first error
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	res := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return res
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// fullBox reads the version and flags of a full box
+func (r *reader) fullBox() (uint8, uint32) {
+	v := r.u32()
+	return uint8(v >> 24), v & 0xffffff
+}
+
+// count reads an entry count and checks that the remaining data can hold entries of the size
+func (r *reader) count(entrySize int) int {
+	n := int(r.u32())
+	if r.err == nil && n*entrySize > len(r.data)-r.pos {
+		r.err = io.ErrUnexpectedEOF
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
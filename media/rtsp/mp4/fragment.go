@@ -0,0 +1,498 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// sample flags of trun (ISO/IEC 14496-12 8.8.3.1)
+const (
+	sampleFlagsSync    = 0x02000000 // depends on no other sample
+	sampleFlagsNonSync = 0x01010000 // depends on others, not a sync sample
+	sampleIsNonSync    = 0x00010000
+)
+
+// movieTimeScale is the timescale of mvhd written by FragmentWriter
+const movieTimeScale = 1000
+
+// FragmentSample is a sample to write with its data, Offset and Size are ignored
+type FragmentSample struct {
+	Sample
+	Data []byte
+}
+
+// FragmentWriter writes a fragmented MP4 (ISO/IEC 14496-12 8.8): ftyp and moov without samples,
+// then a moof and an mdat per fragment. The file is playable up to its last complete fragment
+type FragmentWriter struct {
+	w      io.Writer
+	tracks []*Track
+	seq    uint32
+	// lastDuration is the duration of the last sample of each track, repeated for the last sample of a fragment
+	lastDuration []uint32
+	written      int64
+}
+
+// NewFragmentWriter writes the initialization segment of H.264 and AAC tracks,
+// the IDs of the tracks are their index from 1 and video samples are NALUs with 4 bytes lengths
+func NewFragmentWriter(w io.Writer, tracks []*Track) (*FragmentWriter, error) {
+	f := &FragmentWriter{w: w, tracks: tracks, lastDuration: make([]uint32, len(tracks))}
+	var traks [][]byte
+	for i, t := range tracks {
+		t.ID = uint32(i + 1)
+		trak, err := f.trak(t)
+		if err != nil {
+			return nil, err
+		}
+		traks = append(traks, trak)
+	}
+	if len(traks) == 0 {
+		return nil, fmt.Errorf("no track to write")
+	}
+
+	ftyp := makeBox("ftyp", []byte("iso5"), u32(512), []byte("iso5iso6mp41avc1"))
+	mvhd := makeFullBox("mvhd", 0, 0, u32(0), u32(0), u32(movieTimeScale), u32(0), u32(0x00010000), u16(0x0100),
+		make([]byte, 10), unityMatrix(), make([]byte, 24), u32(uint32(len(tracks)+1)))
+	var trexs [][]byte
+	for _, t := range tracks {
+		trexs = append(trexs, makeFullBox("trex", 0, 0, u32(t.ID), u32(1), u32(0), u32(0), u32(0)))
+	}
+	moov := makeBox("moov", append(append([][]byte{mvhd}, traks...), makeBox("mvex", trexs...))...)
+	return f, f.write(append(ftyp, moov...))
+}
+
+// Written returns the number of bytes written
+func (f *FragmentWriter) Written() int64 {
+	return f.written
+}
+
+func (f *FragmentWriter) write(data []byte) error {
+	n, err := f.w.Write(data)
+	f.written += int64(n)
+	return err
+}
+
+// trak builds the trak box of a track without samples
+func (f *FragmentWriter) trak(t *Track) ([]byte, error) {
+	var handler string
+	var header, entry []byte
+	switch t.Codec {
+	case CodecH264:
+		if len(t.SPS) == 0 || len(t.PPS) == 0 || len(t.SPS[0]) < 4 {
+			return nil, fmt.Errorf("H264 track without parameter sets")
+		}
+		if t.Width == 0 || t.Height == 0 {
+			t.Width, t.Height, _ = spsDimensions(t.SPS[0])
+		}
+		avcC := []byte{1, t.SPS[0][1], t.SPS[0][2], t.SPS[0][3], 0xff, 0xe0 | byte(len(t.SPS))}
+		for _, sps := range t.SPS {
+			avcC = append(append(avcC, u16(uint16(len(sps)))...), sps...)
+		}
+		avcC = append(avcC, byte(len(t.PPS)))
+		for _, pps := range t.PPS {
+			avcC = append(append(avcC, u16(uint16(len(pps)))...), pps...)
+		}
+		t.NALULengthSize = 4
+		handler, header = "vide", makeFullBox("vmhd", 0, 1, make([]byte, 8))
+		entry = makeBox("avc1", make([]byte, 6), u16(1), make([]byte, 16), u16(t.Width), u16(t.Height),
+			u32(0x00480000), u32(0x00480000), u32(0), u16(1), make([]byte, 32), u16(0x0018), u16(0xffff),
+			makeBox("avcC", avcC))
+	case CodecAAC:
+		if len(t.Config) == 0 || len(t.Config) > 127 {
+			return nil, fmt.Errorf("AAC track without a valid config")
+		}
+		if rate, channels, ok := parseAudioSpecificConfig(t.Config); ok && t.SampleRate == 0 {
+			t.SampleRate, t.Channels = rate, channels
+		}
+		// ES_Descriptor with a DecoderConfigDescriptor of MPEG-4 audio and the SLConfigDescriptor
+		decoderConfig := append([]byte{0x40, 0x15, 0, 0, 0}, make([]byte, 8)...)
+		decoderConfig = append(append(decoderConfig, 0x05, byte(len(t.Config))), t.Config...)
+		es := append([]byte{0, 0, 0, 0x04, byte(len(decoderConfig))}, decoderConfig...)
+		es = append(es, 0x06, 1, 0x02)
+		handler, header = "soun", makeFullBox("smhd", 0, 0, make([]byte, 4))
+		entry = makeBox("mp4a", make([]byte, 6), u16(1), make([]byte, 8), u16(t.Channels), u16(16), u32(0),
+			u32(t.SampleRate<<16), makeFullBox("esds", 0, 0, append([]byte{0x03, byte(len(es))}, es...)))
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", t.Codec)
+	}
+	if t.TimeScale == 0 {
+		return nil, fmt.Errorf("track %d: timescale is 0", t.ID)
+	}
+
+	volume := uint16(0)
+	if handler == "soun" {
+		volume = 0x0100
+	}
+	tkhd := makeFullBox("tkhd", 0, 3, u32(0), u32(0), u32(t.ID), u32(0), u32(0), make([]byte, 8), u16(0), u16(0),
+		u16(volume), u16(0), unityMatrix(), u32(uint32(t.Width)<<16), u32(uint32(t.Height)<<16))
+	mdhd := makeFullBox("mdhd", 0, 0, u32(0), u32(0), u32(t.TimeScale), u32(0), u16(0x55c4), u16(0))
+	hdlr := makeFullBox("hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 12), []byte("gokit\x00"))
+	dinf := makeBox("dinf", makeFullBox("dref", 0, 0, u32(1), makeFullBox("url ", 0, 1)))
+	stbl := makeBox("stbl",
+		makeFullBox("stsd", 0, 0, u32(1), entry),
+		makeFullBox("stts", 0, 0, u32(0)),
+		makeFullBox("stsc", 0, 0, u32(0)),
+		makeFullBox("stsz", 0, 0, u32(0), u32(0)),
+		makeFullBox("stco", 0, 0, u32(0)))
+	return makeBox("trak", tkhd, makeBox("mdia", mdhd, hdlr, makeBox("minf", header, dinf, stbl))), nil
+}
+
+// WriteFragment writes a fragment with the samples of each track in decoding order, samples[i] are
+// the samples of the track i. The duration of a sample is the difference with the next DTS,
+// the last sample of a track lasts like the one before
+func (f *FragmentWriter) WriteFragment(samples [][]FragmentSample) error {
+	if len(samples) != len(f.tracks) {
+		return fmt.Errorf("expected the samples of %d tracks, got %d", len(f.tracks), len(samples))
+	}
+
+	type run struct {
+		track   *Track
+		samples []FragmentSample
+		// offsetPos is the position of the data offset of trun in the moof
+		offsetPos int
+	}
+	var runs []*run
+	var trafs [][]byte
+	size := 8 + 16 // moof header and mfhd
+	for i, track := range f.tracks {
+		if len(samples[i]) == 0 {
+			continue
+		}
+		r := &run{track: track, samples: samples[i]}
+		var entries []byte
+		for j, s := range samples[i] {
+			duration := f.lastDuration[i]
+			if j+1 < len(samples[i]) {
+				duration = uint32(samples[i][j+1].DTS - s.DTS)
+				f.lastDuration[i] = duration
+			}
+			flags := uint32(sampleFlagsSync)
+			if track.IsVideo() && !s.Keyframe {
+				flags = sampleFlagsNonSync
+			}
+			entries = append(entries, u32(duration)...)
+			entries = append(entries, u32(uint32(len(s.Data)))...)
+			entries = append(entries, u32(flags)...)
+			entries = append(entries, u32(uint32(s.CTSOffset))...)
+		}
+		tfhd := makeFullBox("tfhd", 0, 0x020000, u32(track.ID))
+		tfdt := makeFullBox("tfdt", 1, 0, u64(samples[i][0].DTS))
+		// data offset, duration, size, flags and composition time offset
+		trun := makeFullBox("trun", 1, 0x000f01, u32(uint32(len(samples[i]))), u32(0), entries)
+		r.offsetPos = size + 8 + len(tfhd) + len(tfdt) + 16
+		trafs = append(trafs, makeBox("traf", tfhd, tfdt, trun))
+		size += 8 + len(tfhd) + len(tfdt) + len(trun)
+		runs = append(runs, r)
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	f.seq++
+	moof := makeBox("moof", append([][]byte{makeFullBox("mfhd", 0, 0, u32(f.seq))}, trafs...)...)
+	dataOffset := len(moof) + 8
+	var mdat []byte
+	for _, r := range runs {
+		binary.BigEndian.PutUint32(moof[r.offsetPos:], uint32(dataOffset+len(mdat)))
+		for _, s := range r.samples {
+			mdat = append(mdat, s.Data...)
+		}
+	}
+	return f.write(append(moof, makeBox("mdat", mdat)...))
+}
+
+// parseFragments appends the samples of the moof boxes to the tracks, using the defaults of trex
+func (f *File) parseFragments(boxes []box, trex map[uint32][]byte) error {
+	tracks := map[uint32]*Track{}
+	for _, t := range f.Tracks {
+		tracks[t.ID] = t
+	}
+	for _, moof := range boxes {
+		if moof.typ != "moof" {
+			continue
+		}
+		var track *Track
+		var tfhd, defaults []byte
+		var dts uint64
+		err := walkBoxes(f.r, moof, func(b box) (bool, error) {
+			if b.typ == "traf" {
+				track, tfhd, dts = nil, nil, 0
+				return true, nil
+			}
+			if b.typ != "tfhd" && b.typ != "tfdt" && b.typ != "trun" {
+				return false, nil
+			}
+			data, err := readBody(f.r, b)
+			if err != nil {
+				return false, err
+			}
+			r := &reader{data: data}
+			version, flags := r.fullBox()
+			switch b.typ {
+			case "tfhd":
+				tfhd = data
+				id := r.u32()
+				track, defaults = tracks[id], trex[id]
+				if track != nil {
+					dts = track.Duration
+				}
+			case "tfdt":
+				if version == 1 {
+					dts = r.u64()
+				} else {
+					dts = uint64(r.u32())
+				}
+			case "trun":
+				if track == nil {
+					return false, nil
+				}
+				end, err := track.parseTrun(r, flags, tfhd, defaults, moof.offset, dts)
+				if err != nil {
+					return false, fmt.Errorf("track %d: %v", track.ID, err)
+				}
+				dts = end
+			}
+			return false, r.err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTrun appends the samples of a trun, returning the DTS after them
+func (t *Track) parseTrun(r *reader, flags uint32, tfhd, defaults []byte, moofOffset int64, dts uint64) (uint64, error) {
+	// defaults of trex, overridden by tfhd
+	var defaultDuration, defaultSize, defaultFlags uint32
+	if len(defaults) >= 24 {
+		defaultDuration = binary.BigEndian.Uint32(defaults[12:])
+		defaultSize = binary.BigEndian.Uint32(defaults[16:])
+		defaultFlags = binary.BigEndian.Uint32(defaults[20:])
+	}
+	base := moofOffset
+	hr := &reader{data: tfhd}
+	_, tfhdFlags := hr.fullBox()
+	hr.skip(4)
+	if tfhdFlags&0x01 != 0 {
+		base = int64(hr.u64())
+	}
+	if tfhdFlags&0x02 != 0 {
+		hr.skip(4)
+	}
+	if tfhdFlags&0x08 != 0 {
+		defaultDuration = hr.u32()
+	}
+	if tfhdFlags&0x10 != 0 {
+		defaultSize = hr.u32()
+	}
+	if tfhdFlags&0x20 != 0 {
+		defaultFlags = hr.u32()
+	}
+	if hr.err != nil {
+		return 0, fmt.Errorf("invalid tfhd")
+	}
+
+	count := int(r.u32())
+	offset := base
+	if flags&0x01 != 0 {
+		offset += int64(int32(r.u32()))
+	}
+	firstFlags, hasFirstFlags := uint32(0), flags&0x04 != 0
+	if hasFirstFlags {
+		firstFlags = r.u32()
+	}
+	entrySize := 0
+	for _, bit := range []uint32{0x100, 0x200, 0x400, 0x800} {
+		if flags&bit != 0 {
+			entrySize += 4
+		}
+	}
+	if r.err != nil || count*entrySize > len(r.data)-r.pos {
+		return 0, fmt.Errorf("invalid trun")
+	}
+	for i := 0; i < count; i++ {
+		s := Sample{Offset: offset, DTS: dts, Size: defaultSize}
+		duration, sampleFlags := defaultDuration, defaultFlags
+		if i == 0 && hasFirstFlags {
+			sampleFlags = firstFlags
+		}
+		if flags&0x100 != 0 {
+			duration = r.u32()
+		}
+		if flags&0x200 != 0 {
+			s.Size = r.u32()
+		}
+		if flags&0x400 != 0 {
+			sampleFlags = r.u32()
+		}
+		if flags&0x800 != 0 {
+			s.CTSOffset = int32(r.u32())
+		}
+		s.Keyframe = sampleFlags&sampleIsNonSync == 0
+		t.Samples = append(t.Samples, s)
+		offset += int64(s.Size)
+		dts += uint64(duration)
+	}
+	t.Duration = dts
+	return dts, nil
+}
+
+func makeBox(typ string, parts ...[]byte) []byte {
+	size := 8
+	for _, p := range parts {
+		size += len(p)
+	}
+	b := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(b, uint32(size))
+	copy(b[4:], typ)
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func makeFullBox(typ string, version uint8, flags uint32, parts ...[]byte) []byte {
+	return makeBox(typ, append([][]byte{u32(uint32(version)<<24 | flags&0xffffff)}, parts...)...)
+}
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+func unityMatrix() []byte {
+	m := make([]byte, 0, 36)
+	for _, v := range []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000} {
+		m = append(m, u32(v)...)
+	}
+	return m
+}
+
+// spsDimensions returns the size of the pictures of an H.264 SPS (ITU-T H.264 7.3.2.1.1)
+func spsDimensions(sps []byte) (uint16, uint16, bool) {
+	// remove the emulation prevention bytes
+	var rbsp []byte
+	for i := 1; i < len(sps); i++ {
+		if i >= 3 && sps[i] == 3 && sps[i-1] == 0 && sps[i-2] == 0 {
+			continue
+		}
+		rbsp = append(rbsp, sps[i])
+	}
+	if len(rbsp) < 4 {
+		return 0, 0, false
+	}
+	r := &bitReader{data: rbsp, pos: 24}
+	profile := rbsp[0]
+	r.ue() // seq_parameter_set_id
+	chromaFormat := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.ue()
+		if chromaFormat == 3 {
+			r.bit() // separate_colour_plane_flag
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		if r.bit() == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.bit() == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := int32(8), int32(8)
+				for j := 0; j < size && next != 0; j++ {
+					next = (last + r.se() + 256) % 256
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit() // delta_pic_order_always_zero_flag
+		r.se()  // offset_for_non_ref_pic
+		r.se()  // offset_for_top_to_bottom_field
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se()
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+	width := (r.ue() + 1) * 16
+	mapUnits := r.ue() + 1
+	frameMbsOnly := r.bit()
+	height := (2 - frameMbsOnly) * mapUnits * 16
+	if frameMbsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+	if r.bit() == 1 {
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		cropX, cropY := uint32(1), 2-frameMbsOnly
+		if chromaFormat == 1 || chromaFormat == 2 {
+			cropX = 2
+		}
+		if chromaFormat == 1 {
+			cropY *= 2
+		}
+		width -= (left + right) * cropX
+		height -= (top + bottom) * cropY
+	}
+	if r.err != nil || width > 0xffff || height > 0xffff {
+		return 0, 0, false
+	}
+	return uint16(width), uint16(height), true
+}
+
+// bitReader reads the Exp-Golomb codes of an RBSP and remembers the first error
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bitReader) bit() uint32 {
+	if r.pos >= len(r.data)*8 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	b := uint32(r.data[r.pos/8]>>(7-r.pos%8)) & 1
+	r.pos++
+	return b
+}
+
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.bit() == 0 && r.err == nil {
+		if zeros++; zeros > 31 {
+			r.err = fmt.Errorf("invalid Exp-Golomb code")
+			return 0
+		}
+	}
+	v := uint32(0)
+	for i := 0; i < zeros; i++ {
+		v = v<<1 | r.bit()
+	}
+	return 1<<zeros - 1 + v
+}
+
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v&1 == 1 {
+		return int32(v/2 + 1)
+	}
+	return -int32(v / 2)
+}
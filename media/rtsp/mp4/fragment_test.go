@@ -0,0 +1,88 @@
+package mp4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFragmentWriter(t *testing.T) {
+	f, err := Open("testdata/sample.mp4")
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+	// the SPS of the sample is 1280x720 while tkhd says 640x360
+	if width, height, ok := spsDimensions(f.Tracks[0].SPS[0]); !ok || width != 1280 || height != 720 {
+		t.Errorf("Expected 1280x720, got %dx%d", width, height)
+	}
+	// baseline 1920x1088 cropped by 8 lines
+	sps := []byte{0x67, 0x42, 0xc0, 0x28, 0xda, 0x01, 0xe0, 0x08, 0x9f, 0x95}
+	if width, height, ok := spsDimensions(sps); !ok || width != 1920 || height != 1080 {
+		t.Errorf("Expected 1920x1080, got %dx%d", width, height)
+	}
+
+	video := &Track{Codec: CodecH264, TimeScale: 90000, SPS: f.Tracks[0].SPS, PPS: f.Tracks[0].PPS}
+	audio := &Track{Codec: CodecAAC, TimeScale: 44100, Config: []byte{0x12, 0x10}}
+	var buf bytes.Buffer
+	w, err := NewFragmentWriter(&buf, []*Track{video, audio})
+	if err != nil {
+		t.Fatalf("Failed to create the writer: %v", err)
+	}
+	// two fragments of a keyframe and two frames, the second frame is a B-frame
+	for i := 0; i < 2; i++ {
+		base := uint64(i * 9000)
+		err := w.WriteFragment([][]FragmentSample{
+			{
+				{Sample: Sample{DTS: base, CTSOffset: 3000, Keyframe: true}, Data: []byte{0, 0, 0, 2, 0x65, byte(i)}},
+				{Sample: Sample{DTS: base + 3000, CTSOffset: 3000}, Data: []byte{0, 0, 0, 2, 0x41, byte(i)}},
+				{Sample: Sample{DTS: base + 6000, CTSOffset: -3000}, Data: []byte{0, 0, 0, 2, 0x01, byte(i)}},
+			},
+			{
+				{Sample: Sample{DTS: uint64(i * 4410), Keyframe: true}, Data: []byte{0x21, byte(i)}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to write fragment %d: %v", i, err)
+		}
+	}
+	if w.Written() != int64(buf.Len()) {
+		t.Errorf("Expected %d bytes written, got %d", buf.Len(), w.Written())
+	}
+
+	file, err := NewFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to parse the fragmented file: %v", err)
+	}
+	if len(file.Tracks) != 2 {
+		t.Fatalf("Expected 2 tracks, got %d", len(file.Tracks))
+	}
+	v, a := file.Tracks[0], file.Tracks[1]
+	if v.Codec != CodecH264 || v.Width != 1280 || v.Height != 720 || v.TimeScale != 90000 || !bytes.Equal(v.SPS[0], video.SPS[0]) {
+		t.Errorf("Unexpected video track %+v", v)
+	}
+	if a.Codec != CodecAAC || a.SampleRate != 44100 || a.Channels != 2 || !bytes.Equal(a.Config, audio.Config) {
+		t.Errorf("Unexpected audio track %+v", a)
+	}
+	if len(v.Samples) != 6 || len(a.Samples) != 2 {
+		t.Fatalf("Expected 6 video and 2 audio samples, got %d and %d", len(v.Samples), len(a.Samples))
+	}
+	for i, s := range v.Samples {
+		if s.DTS != uint64(i*3000) || s.Keyframe != (i%3 == 0) {
+			t.Errorf("Video sample %d: unexpected DTS %d or keyframe %v", i, s.DTS, s.Keyframe)
+		}
+		data, err := file.ReadSample(s)
+		if err != nil || !bytes.Equal(data[4:], []byte{[]byte{0x65, 0x41, 0x01}[i%3], byte(i / 3)}) {
+			t.Errorf("Video sample %d: unexpected data %x (%v)", i, data, err)
+		}
+	}
+	if v.Samples[2].PTS() != 3000 || v.Duration != 18000 {
+		t.Errorf("Unexpected PTS %d or duration %d", v.Samples[2].PTS(), v.Duration)
+	}
+	if data, err := file.ReadSample(a.Samples[1]); err != nil || !bytes.Equal(data, []byte{0x21, 1}) || a.Samples[1].DTS != 4410 {
+		t.Errorf("Unexpected audio sample %x (%v)", data, err)
+	}
+
+	if _, err := NewFragmentWriter(&buf, []*Track{{Codec: CodecH265, TimeScale: 90000}}); err == nil {
+		t.Error("Expected an error for H265")
+	}
+}
@@ -0,0 +1,611 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Codec defines the codec of a track
+type Codec string
+
+const (
+	CodecH264 Codec = "H264"
+	CodecH265 Codec = "H265"
+	CodecAAC  Codec = "AAC"
+)
+
+// Sample is a frame of a track
+type Sample struct {
+	Offset int64
+	Size   uint32
+	// DTS is the decoding time in the timescale of the track
+	DTS uint64
+	// CTSOffset is PTS - DTS
+	CTSOffset int32
+	Keyframe  bool
+}
+
+// PTS returns the presentation time in the timescale of the track
+func (s Sample) PTS() int64 {
+	return int64(s.DTS) + int64(s.CTSOffset)
+}
+
+// Track represents a trak box with its sample table
+type Track struct {
+	ID        uint32
+	Codec     Codec
+	TimeScale uint32
+	// Duration is the duration in the timescale of the track
+	Duration uint64
+	Samples  []Sample
+
+	// Video
+	Width  uint16
+	Height uint16
+	// VPS, SPS and PPS are the parameter sets of avcC or hvcC, VPS only exists for H.265
+	VPS [][]byte
+	SPS [][]byte
+	PPS [][]byte
+	// NALULengthSize is the size of the length prefix of the NALUs in a sample
+	NALULengthSize int
+
+	// Audio
+	SampleRate uint32
+	Channels   uint16
+	// Config is the AudioSpecificConfig of AAC
+	Config []byte
+}
+
+// IsVideo returns whether the track is H.264 or H.265
+func (t *Track) IsVideo() bool {
+	return t.Codec == CodecH264 || t.Codec == CodecH265
+}
+
+// NALUs splits a sample of a video track into NALUs
+func (t *Track) NALUs(data []byte) ([][]byte, error) {
+	res := [][]byte{}
+	for len(data) > 0 {
+		if len(data) < t.NALULengthSize {
+			return nil, fmt.Errorf("truncated NALU length")
+		}
+		size := 0
+		for _, b := range data[:t.NALULengthSize] {
+			size = size<<8 | int(b)
+		}
+		data = data[t.NALULengthSize:]
+		if size > len(data) {
+			return nil, fmt.Errorf("NALU size %d exceeds sample size %d", size, len(data))
+		}
+		if size > 0 {
+			res = append(res, data[:size])
+		}
+		data = data[size:]
+	}
+	return res, nil
+}
+
+// File is an MP4 file with the tracks of moov
+type File struct {
+	// TimeScale and Duration come from mvhd
+	TimeScale uint32
+	Duration  uint64
+	Tracks    []*Track
+
+	r      io.ReaderAt
+	closer io.Closer
+}
+
+// Open opens and parses an MP4 file
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	file, err := NewFile(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	file.closer = f
+	return file, nil
+}
+
+// NewFile parses an MP4 file from r, tracks of unsupported codecs are skipped
+func NewFile(r io.ReaderAt, size int64) (*File, error) {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	moov, ok := findBox(boxes, "moov")
+	if !ok {
+		return nil, fmt.Errorf("no moov box")
+	}
+	offset, size := moov.body()
+	children, err := readBoxes(r, offset, offset+size)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{r: r}
+	trex := map[uint32][]byte{}
+	for _, b := range children {
+		switch b.typ {
+		case "mvex":
+			err := walkBoxes(r, b, func(b box) (bool, error) {
+				if b.typ == "trex" {
+					data, err := readBody(r, b)
+					if err != nil || len(data) < 24 {
+						return false, fmt.Errorf("invalid trex")
+					}
+					trex[binary.BigEndian.Uint32(data[4:])] = data
+				}
+				return false, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		case "mvhd":
+			data, err := readBody(r, b)
+			if err != nil {
+				return nil, err
+			}
+			f.TimeScale, f.Duration = parseTimes(data, 8)
+		case "trak":
+			t, err := parseTrak(r, b)
+			if err != nil {
+				return nil, err
+			}
+			if t != nil {
+				f.Tracks = append(f.Tracks, t)
+			}
+		}
+	}
+	if len(f.Tracks) == 0 {
+		return nil, fmt.Errorf("no H.264, H.265 or AAC track")
+	}
+	// the samples of a fragmented file follow moov in moof boxes
+	if len(trex) > 0 {
+		if err := f.parseFragments(boxes, trex); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Close closes the file opened by Open
+func (f *File) Close() error {
+	if f.closer != nil {
+		return f.closer.Close()
+	}
+	return nil
+}
+
+// ReadSample reads the data of a sample
+func (f *File) ReadSample(s Sample) ([]byte, error) {
+	data := make([]byte, s.Size)
+	if _, err := f.r.ReadAt(data, s.Offset); err != nil {
+		return nil, fmt.Errorf("read sample at %d: %v", s.Offset, err)
+	}
+	return data, nil
+}
+
+// parseTimes parses the timescale and duration of mvhd and mdhd,
+// skip is the size of the creation and modification times of version 0
+func parseTimes(data []byte, skip int) (uint32, uint64) {
+	r := &reader{data: data}
+	if version, _ := r.fullBox(); version == 1 {
+		r.skip(skip * 2)
+		return r.u32(), r.u64()
+	}
+	r.skip(skip)
+	return r.u32(), uint64(r.u32())
+}
+
+// parseTrak parses a trak box, returning nil for tracks which are neither video nor audio
+func parseTrak(r io.ReaderAt, trak box) (*Track, error) {
+	t := &Track{}
+	var stbl box
+	var handler string
+	err := walkBoxes(r, trak, func(b box) (bool, error) {
+		switch b.typ {
+		case "mdia", "minf":
+			return true, nil
+		case "tkhd":
+			data, err := readBody(r, b)
+			if err != nil {
+				return false, err
+			}
+			tr := &reader{data: data}
+			if version, _ := tr.fullBox(); version == 1 {
+				tr.skip(16)
+			} else {
+				tr.skip(8)
+			}
+			t.ID = tr.u32()
+		case "mdhd":
+			data, err := readBody(r, b)
+			if err != nil {
+				return false, err
+			}
+			t.TimeScale, t.Duration = parseTimes(data, 8)
+		case "hdlr":
+			data, err := readBody(r, b)
+			if err != nil {
+				return false, err
+			}
+			if len(data) >= 12 {
+				handler = string(data[8:12])
+			}
+		case "stbl":
+			stbl = b
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if handler != "vide" && handler != "soun" || stbl.typ == "" {
+		return nil, nil
+	}
+	if t.TimeScale == 0 {
+		return nil, fmt.Errorf("track %d: timescale is 0", t.ID)
+	}
+
+	tables := map[string][]byte{}
+	err = walkBoxes(r, stbl, func(b box) (bool, error) {
+		switch b.typ {
+		case "stsd", "stts", "ctts", "stsc", "stsz", "stco", "co64", "stss":
+			data, err := readBody(r, b)
+			if err != nil {
+				return false, err
+			}
+			tables[b.typ] = data
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := t.parseStsd(tables["stsd"]); err != nil {
+		return nil, fmt.Errorf("track %d: %v", t.ID, err)
+	}
+	if t.Codec == "" {
+		return nil, nil
+	}
+	if err := t.parseSamples(tables); err != nil {
+		return nil, fmt.Errorf("track %d: %v", t.ID, err)
+	}
+	return t, nil
+}
+
+// walkBoxes calls fn for the children of parent, and for their children when fn returns true
+func walkBoxes(r io.ReaderAt, parent box, fn func(box) (bool, error)) error {
+	offset, size := parent.body()
+	children, err := readBoxes(r, offset, offset+size)
+	if err != nil {
+		return err
+	}
+	for _, b := range children {
+		descend, err := fn(b)
+		if err != nil {
+			return err
+		}
+		if descend {
+			if err := walkBoxes(r, b, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseStsd parses the first sample entry, leaving Codec empty for unsupported codecs
+func (t *Track) parseStsd(data []byte) error {
+	r := &reader{data: data}
+	r.fullBox()
+	if r.u32() == 0 || r.err != nil {
+		return fmt.Errorf("empty stsd")
+	}
+	size := int(r.u32())
+	typ := string(r.bytes(4))
+	entry := r.bytes(size - 8)
+	if r.err != nil {
+		return fmt.Errorf("invalid sample entry: %v", r.err)
+	}
+
+	switch typ {
+	case "avc1", "avc3", "hvc1", "hev1":
+		// visual sample entry, then the child boxes
+		er := &reader{data: entry}
+		er.skip(24)
+		t.Width = er.u16()
+		t.Height = er.u16()
+		er.skip(50)
+		if er.err != nil {
+			return fmt.Errorf("invalid %s entry", typ)
+		}
+		config, ok := childBox(entry[er.pos:], map[string]string{"avc1": "avcC", "avc3": "avcC", "hvc1": "hvcC", "hev1": "hvcC"}[typ])
+		if !ok {
+			return fmt.Errorf("no decoder configuration in %s", typ)
+		}
+		if typ[0] == 'a' {
+			t.Codec = CodecH264
+			return t.parseAvcC(config)
+		}
+		t.Codec = CodecH265
+		return t.parseHvcC(config)
+	case "mp4a":
+		// audio sample entry, then the child boxes
+		er := &reader{data: entry}
+		er.skip(16)
+		t.Channels = er.u16()
+		er.skip(6)
+		t.SampleRate = er.u32() >> 16
+		if er.err != nil {
+			return fmt.Errorf("invalid mp4a entry")
+		}
+		esds, ok := childBox(entry[er.pos:], "esds")
+		if !ok {
+			return fmt.Errorf("no esds in mp4a")
+		}
+		config, err := parseEsds(esds)
+		if err != nil {
+			return err
+		}
+		t.Codec = CodecAAC
+		t.Config = config
+		if rate, channels, ok := parseAudioSpecificConfig(config); ok {
+			t.SampleRate, t.Channels = rate, channels
+		}
+	}
+	return nil
+}
+
+// childBox returns the body of the first child box of the type in data
+func childBox(data []byte, typ string) ([]byte, bool) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		if size < 8 || size > len(data) {
+			return nil, false
+		}
+		if string(data[4:8]) == typ {
+			return data[8:size], true
+		}
+		data = data[size:]
+	}
+	return nil, false
+}
+
+// parseAvcC parses an AVCDecoderConfigurationRecord
+func (t *Track) parseAvcC(data []byte) error {
+	r := &reader{data: data}
+	r.skip(4)
+	t.NALULengthSize = int(r.u8()&3) + 1
+	for i, n := 0, int(r.u8()&0x1f); i < n; i++ {
+		t.SPS = append(t.SPS, r.bytes(int(r.u16())))
+	}
+	for i, n := 0, int(r.u8()); i < n; i++ {
+		t.PPS = append(t.PPS, r.bytes(int(r.u16())))
+	}
+	if r.err != nil {
+		return fmt.Errorf("invalid avcC")
+	}
+	return nil
+}
+
+// parseHvcC parses an HEVCDecoderConfigurationRecord
+func (t *Track) parseHvcC(data []byte) error {
+	r := &reader{data: data}
+	r.skip(21)
+	t.NALULengthSize = int(r.u8()&3) + 1
+	for i, n := 0, int(r.u8()); i < n; i++ {
+		typ := r.u8() & 0x3f
+		for j, m := 0, int(r.u16()); j < m; j++ {
+			nalu := r.bytes(int(r.u16()))
+			switch typ {
+			case 32:
+				t.VPS = append(t.VPS, nalu)
+			case 33:
+				t.SPS = append(t.SPS, nalu)
+			case 34:
+				t.PPS = append(t.PPS, nalu)
+			}
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("invalid hvcC")
+	}
+	return nil
+}
+
+// parseEsds returns the DecoderSpecificInfo of an ES_Descriptor
+func parseEsds(data []byte) ([]byte, error) {
+	r := &reader{data: data}
+	r.fullBox()
+	for r.err == nil && r.pos < len(r.data) {
+		tag := r.u8()
+		size := 0
+		for i := 0; i < 4; i++ {
+			b := r.u8()
+			size = size<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		switch tag {
+		case 0x03:
+			// ES_ID, flags and the optional fields
+			r.skip(2)
+			flags := r.u8()
+			if flags&0x80 != 0 {
+				r.skip(2)
+			}
+			if flags&0x40 != 0 {
+				r.skip(int(r.u8()))
+			}
+			if flags&0x20 != 0 {
+				r.skip(2)
+			}
+		case 0x04:
+			// objectTypeIndication, streamType, bufferSizeDB, maxBitrate and avgBitrate
+			r.skip(13)
+		case 0x05:
+			if config := r.bytes(size); r.err == nil {
+				return config, nil
+			}
+		default:
+			r.skip(size)
+		}
+	}
+	return nil, fmt.Errorf("no DecoderSpecificInfo in esds")
+}
+
+var aacSampleRates = []uint32{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseAudioSpecificConfig returns the sample rate and channel count of an AudioSpecificConfig
+func parseAudioSpecificConfig(config []byte) (uint32, uint16, bool) {
+	if len(config) < 2 {
+		return 0, 0, false
+	}
+	index := (config[0]&0x07)<<1 | config[1]>>7
+	channels := uint16(config[1]>>3) & 0x0f
+	if int(index) >= len(aacSampleRates) {
+		return 0, 0, false
+	}
+	return aacSampleRates[index], channels, true
+}
+
+// parseSamples builds the samples from stts, ctts, stsc, stsz, stco or co64 and stss
+func (t *Track) parseSamples(tables map[string][]byte) error {
+	// stsz
+	r := &reader{data: tables["stsz"]}
+	r.fullBox()
+	fixedSize := r.u32()
+	count := int(r.u32())
+	if r.err != nil {
+		return fmt.Errorf("invalid stsz")
+	}
+	if fixedSize == 0 && count*4 > len(r.data)-r.pos {
+		return fmt.Errorf("invalid stsz")
+	}
+	t.Samples = make([]Sample, count)
+	for i := range t.Samples {
+		if fixedSize != 0 {
+			t.Samples[i].Size = fixedSize
+		} else {
+			t.Samples[i].Size = r.u32()
+		}
+	}
+
+	// stts
+	r = &reader{data: tables["stts"]}
+	r.fullBox()
+	dts, i := uint64(0), 0
+	for n := r.count(8); n > 0; n-- {
+		sampleCount, delta := int(r.u32()), uint64(r.u32())
+		for ; sampleCount > 0 && i < count; sampleCount-- {
+			t.Samples[i].DTS = dts
+			dts += delta
+			i++
+		}
+	}
+	if r.err != nil || i != count {
+		return fmt.Errorf("invalid stts")
+	}
+	if t.Duration == 0 {
+		t.Duration = dts
+	}
+
+	// ctts
+	if data, ok := tables["ctts"]; ok {
+		r = &reader{data: data}
+		r.fullBox()
+		i = 0
+		for n := r.count(8); n > 0; n-- {
+			sampleCount, offset := int(r.u32()), int32(r.u32())
+			for ; sampleCount > 0 && i < count; sampleCount-- {
+				t.Samples[i].CTSOffset = offset
+				i++
+			}
+		}
+		if r.err != nil {
+			return fmt.Errorf("invalid ctts")
+		}
+	}
+
+	// stss
+	if data, ok := tables["stss"]; ok {
+		r = &reader{data: data}
+		r.fullBox()
+		for n := r.count(4); n > 0; n-- {
+			if number := int(r.u32()); number >= 1 && number <= count {
+				t.Samples[number-1].Keyframe = true
+			}
+		}
+		if r.err != nil {
+			return fmt.Errorf("invalid stss")
+		}
+	} else {
+		for i := range t.Samples {
+			t.Samples[i].Keyframe = true
+		}
+	}
+
+	// stco or co64
+	chunks := []int64{}
+	if data, ok := tables["co64"]; ok {
+		r = &reader{data: data}
+		r.fullBox()
+		for n := r.count(8); n > 0; n-- {
+			chunks = append(chunks, int64(r.u64()))
+		}
+	} else {
+		r = &reader{data: tables["stco"]}
+		r.fullBox()
+		for n := r.count(4); n > 0; n-- {
+			chunks = append(chunks, int64(r.u32()))
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("invalid chunk offsets")
+	}
+
+	// stsc maps chunks to samples
+	r = &reader{data: tables["stsc"]}
+	r.fullBox()
+	type stscEntry struct{ firstChunk, samplesPerChunk int }
+	entries := []stscEntry{}
+	for n := r.count(12); n > 0; n-- {
+		entries = append(entries, stscEntry{int(r.u32()), int(r.u32())})
+		r.skip(4)
+	}
+	if r.err != nil || len(entries) == 0 && count > 0 {
+		return fmt.Errorf("invalid stsc")
+	}
+	i = 0
+	for e, entry := range entries {
+		last := len(chunks)
+		if e+1 < len(entries) && entries[e+1].firstChunk-1 < last {
+			last = entries[e+1].firstChunk - 1
+		}
+		for chunk := entry.firstChunk; chunk <= last && chunk >= 1; chunk++ {
+			offset := chunks[chunk-1]
+			for s := 0; s < entry.samplesPerChunk && i < count; s++ {
+				t.Samples[i].Offset = offset
+				offset += int64(t.Samples[i].Size)
+				i++
+			}
+		}
+	}
+	if i != count {
+		return fmt.Errorf("chunks hold %d of %d samples", i, count)
+	}
+	return nil
+}
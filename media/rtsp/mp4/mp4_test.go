@@ -0,0 +1,97 @@
+package mp4
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	f, err := Open("testdata/sample.mp4")
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	if f.TimeScale != 1000 || f.Duration != 800 {
+		t.Errorf("Expected movie timescale 1000 and duration 800, got %d and %d", f.TimeScale, f.Duration)
+	}
+	// the text track is skipped
+	if len(f.Tracks) != 2 {
+		t.Fatalf("Expected 2 tracks, got %d", len(f.Tracks))
+	}
+
+	video := f.Tracks[0]
+	if video.ID != 1 || video.Codec != CodecH264 || video.TimeScale != 12800 || video.Width != 640 || video.Height != 360 {
+		t.Errorf("Unexpected video track: %+v", video)
+	}
+	if len(video.SPS) != 1 || video.SPS[0][0] != 0x67 || len(video.PPS) != 1 || video.PPS[0][0] != 0x68 || video.NALULengthSize != 4 {
+		t.Errorf("Unexpected parameter sets: SPS %x, PPS %x", video.SPS, video.PPS)
+	}
+	if len(video.Samples) != 10 {
+		t.Fatalf("Expected 10 video samples, got %d", len(video.Samples))
+	}
+	for i, s := range video.Samples {
+		if s.DTS != uint64(i*512) {
+			t.Errorf("Sample %d: expected DTS %d, got %d", i, i*512, s.DTS)
+		}
+		if s.Keyframe != (i == 0 || i == 5) {
+			t.Errorf("Sample %d: unexpected keyframe flag %v", i, s.Keyframe)
+		}
+	}
+	if video.Samples[0].PTS() != 1024 || video.Samples[1].PTS() != 1024 {
+		t.Errorf("Unexpected PTS %d and %d", video.Samples[0].PTS(), video.Samples[1].PTS())
+	}
+
+	data, err := f.ReadSample(video.Samples[1])
+	if err != nil {
+		t.Fatalf("Failed to read sample: %v", err)
+	}
+	nalus, err := video.NALUs(data)
+	if err != nil {
+		t.Fatalf("Failed to split NALUs: %v", err)
+	}
+	if len(nalus) != 2 || len(nalus[0]) != 200 || nalus[0][0] != 0x41 || len(nalus[1]) != 50 {
+		t.Errorf("Unexpected NALUs of sample 1")
+	}
+	// the last chunk holds a single sample
+	data, err = f.ReadSample(video.Samples[9])
+	if err != nil {
+		t.Fatalf("Failed to read sample: %v", err)
+	}
+	if nalus, err := video.NALUs(data); err != nil || len(nalus) != 2 {
+		t.Errorf("Unexpected NALUs of sample 9: %v", err)
+	}
+
+	audio := f.Tracks[1]
+	if audio.Codec != CodecAAC || audio.SampleRate != 44100 || audio.Channels != 2 || !bytes.Equal(audio.Config, []byte{0x12, 0x10}) {
+		t.Errorf("Unexpected audio track: %+v", audio)
+	}
+	if len(audio.Samples) != 20 || audio.Samples[19].DTS != 19*1024 {
+		t.Fatalf("Unexpected audio samples")
+	}
+	for i, s := range audio.Samples {
+		data, err := f.ReadSample(s)
+		if err != nil {
+			t.Fatalf("Failed to read audio sample %d: %v", i, err)
+		}
+		if len(data) != 100+i || data[0] != byte(0x21+i) || data[len(data)-1] != byte(0x21+i) {
+			t.Errorf("Unexpected data of audio sample %d", i)
+		}
+	}
+}
+
+func TestNewFileErrors(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without moov
+	if _, err := NewFile(bytes.NewReader(data[:100]), 100); err == nil {
+		t.Error("Expected an error for a file without moov")
+	}
+	// the size of the last box exceeds the file
+	if _, err := NewFile(bytes.NewReader(data[:len(data)-1]), int64(len(data)-1)); err == nil {
+		t.Error("Expected an error for a truncated file")
+	}
+}
@@ -0,0 +1,152 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxAccessUnitSize bounds the size of a reassembled access unit
+const maxAccessUnitSize = 8 << 20
+
+// errAccessUnitTooLarge is returned when an access unit exceeds maxAccessUnitSize, which is dropped
+var errAccessUnitTooLarge = fmt.Errorf("rtp: access unit exceeds %d bytes", maxAccessUnitSize)
+
+// ErrPacketLost is returned when a sequence gap breaks a fragmented NALU, which is dropped
+var ErrPacketLost = errors.New("rtp: packet lost")
+
+// AccessUnit is the NALUs of a picture sharing an RTP timestamp
+type AccessUnit struct {
+	Timestamp uint32
+	NALUs     [][]byte
+}
+
+// H264Depacketizer reassembles the access units of H.264 RTP packets (RFC 6184),
+// single NAL unit packets, STAP-A and FU-A, in non-interleaved mode
+type H264Depacketizer struct {
+	au      *AccessUnit
+	size    int
+	fu      []byte
+	lastSeq uint16
+	started bool
+}
+
+// Depacketize adds a packet, returning the access units it completes: the current one at the marker bit,
+// and the previous one when the timestamp changes without a marker
+func (d *H264Depacketizer) Depacketize(p *Packet) ([]*AccessUnit, error) {
+	var res []*AccessUnit
+	var err error
+	if d.started && p.SequenceNumber != d.lastSeq+1 && d.fu != nil {
+		d.fu = nil
+		err = ErrPacketLost
+	}
+	d.started, d.lastSeq = true, p.SequenceNumber
+
+	if d.au != nil && d.au.Timestamp != p.Timestamp {
+		res = append(res, d.flush()...)
+	}
+	if d.au == nil {
+		d.au = &AccessUnit{Timestamp: p.Timestamp}
+	}
+
+	nalus, e := d.unpack(p.Payload)
+	if e != nil {
+		err = e
+	}
+	for _, nalu := range nalus {
+		d.size += len(nalu)
+		if d.size > maxAccessUnitSize {
+			d.au, d.size, d.fu = nil, 0, nil
+			return res, errAccessUnitTooLarge
+		}
+		d.au.NALUs = append(d.au.NALUs, nalu)
+	}
+
+	if p.Marker {
+		res = append(res, d.flush()...)
+	}
+	return res, err
+}
+
+// flush returns the pending access unit unless it's empty
+func (d *H264Depacketizer) flush() []*AccessUnit {
+	au := d.au
+	d.au, d.size, d.fu = nil, 0, nil
+	if au == nil || len(au.NALUs) == 0 {
+		return nil
+	}
+	return []*AccessUnit{au}
+}
+
+// unpack returns the NALUs completed by a payload
+func (d *H264Depacketizer) unpack(payload []byte) ([][]byte, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("rtp: empty H264 payload")
+	}
+	switch typ := payload[0] & 0x1f; {
+	case typ >= 1 && typ <= 23:
+		return [][]byte{append([]byte{}, payload...)}, nil
+	case typ == H264NALUTypeSTAPA:
+		nalus := [][]byte{}
+		for data := payload[1:]; len(data) > 0; {
+			if len(data) < 2 {
+				return nalus, fmt.Errorf("rtp: truncated STAP-A")
+			}
+			size := int(data[0])<<8 | int(data[1])
+			if size == 0 || len(data) < 2+size {
+				return nalus, fmt.Errorf("rtp: invalid STAP-A NALU size %d", size)
+			}
+			nalus = append(nalus, append([]byte{}, data[2:2+size]...))
+			data = data[2+size:]
+		}
+		return nalus, nil
+	case typ == H264NALUTypeFUA:
+		if len(payload) < 3 {
+			return nil, fmt.Errorf("rtp: truncated FU-A")
+		}
+		start, end := payload[1]&0x80 != 0, payload[1]&0x40 != 0
+		if start {
+			d.fu = append([]byte{payload[0]&0xe0 | payload[1]&0x1f}, payload[2:]...)
+		} else if d.fu != nil {
+			// the fragments are bounded as they arrive, before the NALU they make is added
+			if d.size+len(d.fu)+len(payload)-2 > maxAccessUnitSize {
+				d.au, d.size, d.fu = nil, 0, nil
+				return nil, errAccessUnitTooLarge
+			}
+			d.fu = append(d.fu, payload[2:]...)
+		} else {
+			// the start was lost
+			return nil, nil
+		}
+		if !end {
+			return nil, nil
+		}
+		nalu := d.fu
+		d.fu = nil
+		return [][]byte{nalu}, nil
+	default:
+		return nil, fmt.Errorf("rtp: unsupported H264 packetization type %d", typ)
+	}
+}
+
+// AACFrames returns the access units of an MPEG4-GENERIC AAC-hbr payload (RFC 3640),
+// with 13 bits of size and 3 bits of index in the AU headers
+func AACFrames(payload []byte) ([][]byte, error) {
+	if len(payload) < 2 {
+		return nil, fmt.Errorf("rtp: truncated AAC payload")
+	}
+	headersLength := (int(payload[0])<<8 | int(payload[1])) / 8
+	if headersLength%2 != 0 || len(payload) < 2+headersLength {
+		return nil, fmt.Errorf("rtp: invalid AU headers length %d", headersLength)
+	}
+	headers, data := payload[2:2+headersLength], payload[2+headersLength:]
+	frames := [][]byte{}
+	for i := 0; i < len(headers); i += 2 {
+		size := (int(headers[i])<<8 | int(headers[i+1])) >> 3
+		if len(data) < size {
+			return frames, fmt.Errorf("rtp: AU size %d exceeds payload", size)
+		}
+		frames = append(frames, data[:size])
+		data = data[size:]
+	}
+	return frames, nil
+}
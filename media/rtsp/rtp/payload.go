@@ -0,0 +1,182 @@
+package rtp
+
+import (
+	"strings"
+)
+
+// DefaultPayloadSize is the payload size which keeps an RTP packet below a typical MTU
+const DefaultPayloadSize = 1400
+
+// NAL unit types used by the payload formats
+const (
+	H264NALUTypeIDR   = 5
+	H264NALUTypeSPS   = 7
+	H264NALUTypeSTAPA = 24
+	H264NALUTypeFUA   = 28
+
+	// H.265 IRAP pictures are the types 16 to 23
+	H265NALUTypeBLAWLP    = 16
+	H265NALUTypeRSVIRAP23 = 23
+	H265NALUTypeVPS       = 32
+	H265NALUTypeSPS       = 33
+	H265NALUTypeAP        = 48
+	H265NALUTypeFU        = 49
+)
+
+// IsKeyframe reports whether an RTP payload of an SDP encoding starts a keyframe, with a parameter set
+// or the first fragment of an IDR picture. Payloads of encodings other than H264 and H265 are all keyframes
+func IsKeyframe(encoding string, payload []byte) bool {
+	switch strings.ToUpper(encoding) {
+	case "H264":
+		return h264IsKeyframe(payload)
+	case "H265":
+		return h265IsKeyframe(payload)
+	}
+	return true
+}
+
+func h264IsKeyframe(payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+	isKey := func(typ byte) bool { return typ == H264NALUTypeIDR || typ == H264NALUTypeSPS }
+	switch typ := payload[0] & 0x1f; typ {
+	case H264NALUTypeSTAPA:
+		for data := payload[1:]; len(data) > 2; {
+			size := int(data[0])<<8 | int(data[1])
+			if isKey(data[2] & 0x1f) {
+				return true
+			}
+			data = data[min(2+size, len(data)):]
+		}
+		return false
+	case H264NALUTypeFUA:
+		return payload[1]&0x80 != 0 && isKey(payload[1]&0x1f)
+	default:
+		return isKey(typ)
+	}
+}
+
+func h265IsKeyframe(payload []byte) bool {
+	if len(payload) < 3 {
+		return false
+	}
+	isKey := func(typ byte) bool {
+		return typ >= H265NALUTypeBLAWLP && typ <= H265NALUTypeRSVIRAP23 || typ == H265NALUTypeVPS || typ == H265NALUTypeSPS
+	}
+	switch typ := payload[0] >> 1 & 0x3f; typ {
+	case H265NALUTypeAP:
+		for data := payload[2:]; len(data) > 2; {
+			size := int(data[0])<<8 | int(data[1])
+			if isKey(data[2] >> 1 & 0x3f) {
+				return true
+			}
+			data = data[min(2+size, len(data)):]
+		}
+		return false
+	case H265NALUTypeFU:
+		return payload[2]&0x80 != 0 && isKey(payload[2]&0x3f)
+	default:
+		return isKey(typ)
+	}
+}
+
+// H264Payloads packetizes the NALUs of an access unit (RFC 6184), using single NAL unit
+// packets and FU-A for NALUs larger than size
+func H264Payloads(nalus [][]byte, size int) [][]byte {
+	res := [][]byte{}
+	for _, nalu := range nalus {
+		if len(nalu) == 0 {
+			continue
+		}
+		if len(nalu) <= size {
+			res = append(res, nalu)
+			continue
+		}
+		indicator := nalu[0]&0xe0 | H264NALUTypeFUA
+		typ := nalu[0] & 0x1f
+		res = append(res, fragment(nalu[1:], size-2, func(start, end bool) []byte {
+			header := typ
+			if start {
+				header |= 0x80
+			}
+			if end {
+				header |= 0x40
+			}
+			return []byte{indicator, header}
+		})...)
+	}
+	return res
+}
+
+// H265Payloads packetizes the NALUs of an access unit (RFC 7798), using single NAL unit
+// packets and fragmentation units for NALUs larger than size
+func H265Payloads(nalus [][]byte, size int) [][]byte {
+	res := [][]byte{}
+	for _, nalu := range nalus {
+		if len(nalu) < 2 {
+			continue
+		}
+		if len(nalu) <= size {
+			res = append(res, nalu)
+			continue
+		}
+		// the payload header keeps F, LayerId and TID of the NALU
+		header0 := nalu[0]&0x81 | H265NALUTypeFU<<1
+		typ := nalu[0] >> 1 & 0x3f
+		res = append(res, fragment(nalu[2:], size-3, func(start, end bool) []byte {
+			header := typ
+			if start {
+				header |= 0x80
+			}
+			if end {
+				header |= 0x40
+			}
+			return []byte{header0, nalu[1], header}
+		})...)
+	}
+	return res
+}
+
+// fragment splits data into chunks of at most size bytes, each prefixed by the header
+func fragment(data []byte, size int, header func(start, end bool) []byte) [][]byte {
+	res := [][]byte{}
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		h := header(start == 0, end == len(data))
+		payload := make([]byte, 0, len(h)+end-start)
+		payload = append(payload, h...)
+		res = append(res, append(payload, data[start:end]...))
+	}
+	return res
+}
+
+// AACPayloads packetizes AAC frames in the AAC-hbr mode of RFC 3640 (sizelength=13, indexlength=3,
+// indexdeltalength=3), putting as many frames into a packet as size allows.
+// A frame larger than size is sent alone in a larger packet
+func AACPayloads(frames [][]byte, size int) [][]byte {
+	res := [][]byte{}
+	for len(frames) > 0 {
+		n, total := 0, 2
+		for n < len(frames) && (n == 0 || total+2+len(frames[n]) <= size) {
+			total += 2 + len(frames[n])
+			n++
+		}
+		payload := make([]byte, 2+2*n, total)
+		// AU-headers-length in bits
+		payload[0], payload[1] = byte(16*n>>8), byte(16*n)
+		for i, frame := range frames[:n] {
+			payload[2+2*i] = byte(len(frame) >> 5)
+			payload[3+2*i] = byte(len(frame) << 3)
+		}
+		for _, frame := range frames[:n] {
+			payload = append(payload, frame...)
+		}
+		res = append(res, payload)
+		frames = frames[n:]
+	}
+	return res
+}
@@ -0,0 +1,197 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// RTCP packet types (RFC 3550, RFC 4585)
+const (
+	RTCPTypeSR    = 200
+	RTCPTypeRR    = 201
+	RTCPTypeSDES  = 202
+	RTCPTypeBYE   = 203
+	RTCPTypeRTPFB = 205
+	RTCPTypePSFB  = 206
+)
+
+// Feedback message types in the count field of RTPFB and PSFB packets (RFC 4585, RFC 5104)
+const (
+	RTCPFormatNACK = 1
+	RTCPFormatPLI  = 1
+	RTCPFormatFIR  = 4
+)
+
+// RTCPPacket is a packet of a compound RTCP packet
+type RTCPPacket struct {
+	Type uint8
+	// Count is the report count or the feedback message type
+	Count uint8
+	// Payload follows the 4 bytes header, padding removed
+	Payload []byte
+}
+
+// UnmarshalRTCP splits a compound RTCP packet, the payloads share the memory of data
+func UnmarshalRTCP(data []byte) ([]RTCPPacket, error) {
+	var packets []RTCPPacket
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("RTCP packet too short: %d bytes", len(data))
+		}
+		if data[0]>>6 != 2 {
+			return nil, fmt.Errorf("unsupported RTCP version %d", data[0]>>6)
+		}
+		size := 4 + 4*int(binary.BigEndian.Uint16(data[2:]))
+		if size > len(data) {
+			return nil, fmt.Errorf("RTCP length %d exceeds packet size %d", size, len(data))
+		}
+		payload := data[4:size]
+		if data[0]&0x20 != 0 && len(payload) > 0 {
+			padding := int(payload[len(payload)-1])
+			if padding > len(payload) {
+				return nil, fmt.Errorf("invalid RTCP padding")
+			}
+			payload = payload[:len(payload)-padding]
+		}
+		packets = append(packets, RTCPPacket{Type: data[1], Count: data[0] & 0x1f, Payload: payload})
+		data = data[size:]
+	}
+	return packets, nil
+}
+
+// Marshal serializes the packet, the payload is a multiple of 4 bytes
+func (p *RTCPPacket) Marshal() []byte {
+	data := make([]byte, 4, 4+len(p.Payload))
+	data[0] = 0x80 | p.Count&0x1f
+	data[1] = p.Type
+	binary.BigEndian.PutUint16(data[2:], uint16(len(p.Payload)/4))
+	return append(data, p.Payload...)
+}
+
+// MediaSSRC returns the SSRC of the media source of a feedback packet
+func (p *RTCPPacket) MediaSSRC() (uint32, error) {
+	if (p.Type != RTCPTypeRTPFB && p.Type != RTCPTypePSFB) || len(p.Payload) < 8 {
+		return 0, fmt.Errorf("not a feedback packet")
+	}
+	return binary.BigEndian.Uint32(p.Payload[4:]), nil
+}
+
+// NACKs returns the sequence numbers lost according to a generic NACK
+func (p *RTCPPacket) NACKs() ([]uint16, error) {
+	if p.Type != RTCPTypeRTPFB || p.Count != RTCPFormatNACK || len(p.Payload) < 8 {
+		return nil, fmt.Errorf("not a generic NACK")
+	}
+	var seqs []uint16
+	for fci := p.Payload[8:]; len(fci) >= 4; fci = fci[4:] {
+		pid, blp := binary.BigEndian.Uint16(fci), binary.BigEndian.Uint16(fci[2:])
+		seqs = append(seqs, pid)
+		for i := uint16(0); i < 16; i++ {
+			if blp&(1<<i) != 0 {
+				seqs = append(seqs, pid+i+1)
+			}
+		}
+	}
+	return seqs, nil
+}
+
+// IsKeyframeRequest reports whether the packet is a PLI or a FIR
+func (p *RTCPPacket) IsKeyframeRequest() bool {
+	return p.Type == RTCPTypePSFB && (p.Count == RTCPFormatPLI || p.Count == RTCPFormatFIR)
+}
+
+// MarshalPLI returns a Picture Loss Indication of a media source
+func MarshalPLI(senderSSRC, mediaSSRC uint32) []byte {
+	payload := binary.BigEndian.AppendUint32(nil, senderSSRC)
+	payload = binary.BigEndian.AppendUint32(payload, mediaSSRC)
+	packet := RTCPPacket{Type: RTCPTypePSFB, Count: RTCPFormatPLI, Payload: payload}
+	return packet.Marshal()
+}
+
+// ReceptionReport is a report block of a sender or receiver report (RFC 3550 6.4.1)
+type ReceptionReport struct {
+	SSRC uint32
+	// FractionLost is the fraction of packets lost since the previous report, out of 256
+	FractionLost uint8
+	// TotalLost is the cumulative number of packets lost on 24 bits, negative with duplicates
+	TotalLost int32
+	// HighestSequence is the highest sequence number received extended with the cycles count
+	HighestSequence uint32
+	// Jitter is the interarrival jitter in timestamp units
+	Jitter uint32
+	// LastSR is the middle 32 bits of the NTP time of the last sender report, 0 without one
+	LastSR uint32
+	// DelaySinceLastSR is the delay since the last sender report in 1/65536 seconds
+	DelaySinceLastSR uint32
+}
+
+// SenderReport is the content of an SR packet
+type SenderReport struct {
+	SSRC uint32
+	// NTPTime is the wallclock time of RTPTime in the NTP timestamp format
+	NTPTime     uint64
+	RTPTime     uint32
+	PacketCount uint32
+	OctetCount  uint32
+	Reports     []ReceptionReport
+}
+
+// SenderReport parses an SR packet
+func (p *RTCPPacket) SenderReport() (*SenderReport, error) {
+	if p.Type != RTCPTypeSR || len(p.Payload) < 24+24*int(p.Count) {
+		return nil, fmt.Errorf("not a sender report")
+	}
+	sr := &SenderReport{
+		SSRC:        binary.BigEndian.Uint32(p.Payload),
+		NTPTime:     binary.BigEndian.Uint64(p.Payload[4:]),
+		RTPTime:     binary.BigEndian.Uint32(p.Payload[12:]),
+		PacketCount: binary.BigEndian.Uint32(p.Payload[16:]),
+		OctetCount:  binary.BigEndian.Uint32(p.Payload[20:]),
+	}
+	for block := p.Payload[24:]; len(sr.Reports) < int(p.Count); block = block[24:] {
+		sr.Reports = append(sr.Reports, ReceptionReport{
+			SSRC:         binary.BigEndian.Uint32(block),
+			FractionLost: block[4],
+			// the sign of the 24 bits is extended
+			TotalLost:        int32(binary.BigEndian.Uint32(block[4:])<<8) >> 8,
+			HighestSequence:  binary.BigEndian.Uint32(block[8:]),
+			Jitter:           binary.BigEndian.Uint32(block[12:]),
+			LastSR:           binary.BigEndian.Uint32(block[16:]),
+			DelaySinceLastSR: binary.BigEndian.Uint32(block[20:]),
+		})
+	}
+	return sr, nil
+}
+
+// MarshalReceiverReport returns an RR packet of a receiver with report blocks, 31 at most
+func MarshalReceiverReport(ssrc uint32, reports []ReceptionReport) []byte {
+	reports = reports[:min(len(reports), 31)]
+	payload := binary.BigEndian.AppendUint32(nil, ssrc)
+	for _, report := range reports {
+		payload = binary.BigEndian.AppendUint32(payload, report.SSRC)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(report.FractionLost)<<24|uint32(report.TotalLost)&0xffffff)
+		payload = binary.BigEndian.AppendUint32(payload, report.HighestSequence)
+		payload = binary.BigEndian.AppendUint32(payload, report.Jitter)
+		payload = binary.BigEndian.AppendUint32(payload, report.LastSR)
+		payload = binary.BigEndian.AppendUint32(payload, report.DelaySinceLastSR)
+	}
+	packet := RTCPPacket{Type: RTCPTypeRR, Count: uint8(len(reports)), Payload: payload}
+	return packet.Marshal()
+}
+
+// ntpUnixOffset is the number of seconds from the NTP epoch, 1900, to the Unix epoch
+const ntpUnixOffset = 2208988800
+
+// NTPTime converts a time to the NTP timestamp format, seconds since 1900 in 32.32 fixed point
+func NTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpUnixOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// NTPToTime converts an NTP timestamp to a time
+func NTPToTime(ntp uint64) time.Time {
+	seconds := int64(ntp >> 32)
+	nanoseconds := int64((ntp & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds-ntpUnixOffset, nanoseconds)
+}
@@ -0,0 +1,93 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// HeaderSize is the size of an RTP header without CSRCs and extension
+const HeaderSize = 12
+
+// Packet represents an RTP packet (RFC 3550)
+type Packet struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	Payload        []byte
+}
+
+// Marshal serializes the packet
+func (p *Packet) Marshal() []byte {
+	data := make([]byte, HeaderSize+len(p.Payload))
+	data[0] = 0x80 // Version 2, padding 0, extension 0, CSRC count 0
+	data[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		data[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(data[2:], p.SequenceNumber)
+	binary.BigEndian.PutUint32(data[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(data[8:], p.SSRC)
+	copy(data[HeaderSize:], p.Payload)
+	return data
+}
+
+// Unmarshal parses an RTP packet, skipping CSRCs, the header extension and padding.
+// The payload shares the memory of data
+func Unmarshal(data []byte) (*Packet, error) {
+	if len(data) < HeaderSize {
+		return nil, fmt.Errorf("RTP packet too short: %d bytes", len(data))
+	}
+	if data[0]>>6 != 2 {
+		return nil, fmt.Errorf("unsupported RTP version %d", data[0]>>6)
+	}
+	p := &Packet{
+		Marker:         data[1]&0x80 != 0,
+		PayloadType:    data[1] & 0x7f,
+		SequenceNumber: binary.BigEndian.Uint16(data[2:]),
+		Timestamp:      binary.BigEndian.Uint32(data[4:]),
+		SSRC:           binary.BigEndian.Uint32(data[8:]),
+	}
+	offset := HeaderSize + int(data[0]&0x0f)*4
+	if data[0]&0x10 != 0 {
+		if len(data) < offset+4 {
+			return nil, fmt.Errorf("RTP header extension truncated")
+		}
+		offset += 4 + int(binary.BigEndian.Uint16(data[offset+2:]))*4
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return nil, fmt.Errorf("RTP header exceeds packet size")
+	}
+	p.Payload = data[offset:end]
+	return p, nil
+}
+
+// Packetizer turns the payloads of a frame into packets with increasing sequence numbers,
+// marking the last packet of the frame
+type Packetizer struct {
+	PayloadType    uint8
+	SSRC           uint32
+	SequenceNumber uint16
+}
+
+// Packetize returns the packets of a frame, the sequence number continues with the next frame
+func (p *Packetizer) Packetize(payloads [][]byte, timestamp uint32) []*Packet {
+	res := make([]*Packet, 0, len(payloads))
+	for i, payload := range payloads {
+		res = append(res, &Packet{
+			Marker:         i == len(payloads)-1,
+			PayloadType:    p.PayloadType,
+			SequenceNumber: p.SequenceNumber,
+			Timestamp:      timestamp,
+			SSRC:           p.SSRC,
+			Payload:        payload,
+		})
+		p.SequenceNumber++
+	}
+	return res
+}
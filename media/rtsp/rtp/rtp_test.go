@@ -0,0 +1,279 @@
+package rtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestPacketMarshal(t *testing.T) {
+	p := &Packet{Marker: true, PayloadType: 96, SequenceNumber: 65535, Timestamp: 90000, SSRC: 0x12345678, Payload: []byte{1, 2, 3}}
+	data := p.Marshal()
+	if len(data) != HeaderSize+3 || data[0] != 0x80 || data[1] != 0x80|96 {
+		t.Fatalf("Unexpected header %x", data[:2])
+	}
+	parsed, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if parsed.Marker != p.Marker || parsed.PayloadType != p.PayloadType || parsed.SequenceNumber != p.SequenceNumber ||
+		parsed.Timestamp != p.Timestamp || parsed.SSRC != p.SSRC || !bytes.Equal(parsed.Payload, p.Payload) {
+		t.Errorf("Expected %+v, got %+v", p, parsed)
+	}
+
+	// one CSRC, a one word extension and 2 bytes of padding
+	data = []byte{0xb1, 96, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4, 0xbe, 0xde, 0, 1, 9, 9, 9, 9, 7, 8, 0, 2}
+	parsed, err = Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if !bytes.Equal(parsed.Payload, []byte{7, 8}) {
+		t.Errorf("Expected payload 0708, got %x", parsed.Payload)
+	}
+	if _, err := Unmarshal(data[:10]); err == nil {
+		t.Error("Expected an error for a short packet")
+	}
+}
+
+func TestPacketize(t *testing.T) {
+	p := &Packetizer{PayloadType: 97, SSRC: 1, SequenceNumber: 65535}
+	packets := p.Packetize([][]byte{{1}, {2}}, 3000)
+	if len(packets) != 2 || packets[0].Marker || !packets[1].Marker || packets[1].SequenceNumber != 0 || p.SequenceNumber != 1 {
+		t.Errorf("Unexpected packets %+v %+v", packets[0], packets[1])
+	}
+}
+
+func TestH264Payloads(t *testing.T) {
+	nalu := make([]byte, 3000)
+	nalu[0] = 0x65
+	for i := 1; i < len(nalu); i++ {
+		nalu[i] = byte(i)
+	}
+	payloads := H264Payloads([][]byte{{0x67, 1}, {0x68, 2}, nalu}, 1400)
+	if len(payloads) != 5 {
+		t.Fatalf("Expected 5 payloads, got %d", len(payloads))
+	}
+	if !bytes.Equal(payloads[0], []byte{0x67, 1}) {
+		t.Errorf("Expected single NAL unit packet, got %x", payloads[0])
+	}
+	fus := payloads[2:]
+	if fus[0][0] != 0x60|H264NALUTypeFUA || fus[0][1] != 0x85 || fus[1][1] != 0x05 || fus[2][1] != 0x45 {
+		t.Errorf("Unexpected FU-A headers %x %x %x", fus[0][:2], fus[1][:2], fus[2][:2])
+	}
+	// reassemble
+	res := []byte{fus[0][0]&0xe0 | fus[0][1]&0x1f}
+	for _, fu := range fus {
+		if len(fu) > 1400 {
+			t.Errorf("Payload size %d exceeds 1400", len(fu))
+		}
+		res = append(res, fu[2:]...)
+	}
+	if !bytes.Equal(res, nalu) {
+		t.Error("Reassembled NALU differs")
+	}
+}
+
+func TestH265Payloads(t *testing.T) {
+	nalu := make([]byte, 2000)
+	nalu[0], nalu[1] = 19<<1, 1 // IDR_W_RADL
+	payloads := H265Payloads([][]byte{nalu}, 1400)
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 payloads, got %d", len(payloads))
+	}
+	if payloads[0][0]>>1 != H265NALUTypeFU || payloads[0][1] != 1 || payloads[0][2] != 0x80|19 || payloads[1][2] != 0x40|19 {
+		t.Errorf("Unexpected FU headers %x %x", payloads[0][:3], payloads[1][:3])
+	}
+	if len(payloads[0])+len(payloads[1])-6 != len(nalu)-2 {
+		t.Error("Fragments don't add up to the NALU")
+	}
+}
+
+func TestAACPayloads(t *testing.T) {
+	payloads := AACPayloads([][]byte{make([]byte, 300), make([]byte, 400), make([]byte, 1000)}, 1400)
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 payloads, got %d", len(payloads))
+	}
+	// two AU headers of 16 bits, sizes 300 and 400
+	if !bytes.Equal(payloads[0][:6], []byte{0, 32, 300 >> 5, 300 << 3 & 0xff, 400 >> 5, 400 << 3 & 0xff}) || len(payloads[0]) != 6+700 {
+		t.Errorf("Unexpected AU headers %x", payloads[0][:6])
+	}
+	if !bytes.Equal(payloads[1][:4], []byte{0, 16, 1000 >> 5, 1000 << 3 & 0xff}) {
+		t.Errorf("Unexpected AU headers %x", payloads[1][:4])
+	}
+}
+
+func TestIsKeyframe(t *testing.T) {
+	tests := []struct {
+		Encoding string
+		Payload  []byte
+		Keyframe bool
+	}{
+		{"H264", []byte{0x67, 0x64}, true},                                // SPS
+		{"H264", []byte{0x41, 0x9a}, false},                               // non-IDR slice
+		{"H264", []byte{0x7c, 0x85, 0}, true},                             // FU-A start of IDR
+		{"H264", []byte{0x7c, 0x05, 0}, false},                            // FU-A middle of IDR
+		{"H264", []byte{0x78, 0, 2, 0x09, 0x10, 0, 2, 0x67, 0x64}, true},  // STAP-A of AUD and SPS
+		{"H264", []byte{0x78, 0, 2, 0x09, 0x10, 0, 2, 0x41, 0x9a}, false}, // STAP-A of AUD and slice
+		{"H265", []byte{32 << 1, 1, 0}, true},                             // VPS
+		{"H265", []byte{H265NALUTypeFU << 1, 1, 0x80 | 19}, true},         // FU start of IDR_W_RADL
+		{"H265", []byte{H265NALUTypeFU << 1, 1, 0x80 | 1}, false},         // FU start of TRAIL_R
+		{"MPEG4-GENERIC", []byte{0, 16, 0, 0}, true},
+	}
+	for i, tt := range tests {
+		if IsKeyframe(tt.Encoding, tt.Payload) != tt.Keyframe {
+			t.Errorf("%d: expected keyframe %v for %s %x", i, tt.Keyframe, tt.Encoding, tt.Payload)
+		}
+	}
+}
+
+func TestH264Depacketizer(t *testing.T) {
+	idr := make([]byte, 3000)
+	idr[0] = 0x65
+	for i := 1; i < len(idr); i++ {
+		idr[i] = byte(i)
+	}
+	sps, pps := []byte{0x67, 0x64, 0, 0x1f}, []byte{0x68, 0xee}
+	p := &Packetizer{PayloadType: 96, SequenceNumber: 65534}
+	packets := p.Packetize(H264Payloads([][]byte{idr}, 1400), 3000)
+	// SPS and PPS in a STAP-A ahead of the IDR
+	stap := &Packet{SequenceNumber: 65533, Timestamp: 3000, Payload: []byte{0x78, 0, 4, 0x67, 0x64, 0, 0x1f, 0, 2, 0x68, 0xee}}
+	packets = append([]*Packet{stap}, packets...)
+
+	d := &H264Depacketizer{}
+	var aus []*AccessUnit
+	for _, packet := range packets {
+		res, err := d.Depacketize(packet)
+		if err != nil {
+			t.Fatalf("Failed to depacketize: %v", err)
+		}
+		aus = append(aus, res...)
+	}
+	if len(aus) != 1 || aus[0].Timestamp != 3000 || len(aus[0].NALUs) != 3 {
+		t.Fatalf("Expected an access unit of 3 NALUs, got %+v", aus)
+	}
+	if !bytes.Equal(aus[0].NALUs[0], sps) || !bytes.Equal(aus[0].NALUs[1], pps) || !bytes.Equal(aus[0].NALUs[2], idr) {
+		t.Error("Unexpected NALUs")
+	}
+
+	// a slice without marker is completed by the next timestamp
+	aus, _ = d.Depacketize(&Packet{SequenceNumber: 2, Timestamp: 6000, Payload: []byte{0x41, 1}})
+	if len(aus) != 0 {
+		t.Fatalf("Unexpected access units %+v", aus)
+	}
+	aus, _ = d.Depacketize(&Packet{SequenceNumber: 3, Timestamp: 9000, Marker: true, Payload: []byte{0x41, 2}})
+	if len(aus) != 2 || aus[0].Timestamp != 6000 || aus[1].Timestamp != 9000 {
+		t.Errorf("Expected 2 access units, got %+v", aus)
+	}
+
+	// a lost fragment drops the NALU
+	packets = p.Packetize(H264Payloads([][]byte{idr}, 1400), 12000)
+	for i, packet := range packets {
+		packet.SequenceNumber = uint16(4 + i)
+	}
+	if _, err := d.Depacketize(packets[0]); err != nil {
+		t.Fatalf("Failed to depacketize: %v", err)
+	}
+	if _, err := d.Depacketize(packets[2]); err != ErrPacketLost {
+		t.Errorf("Expected ErrPacketLost, got %v", err)
+	}
+	if aus, _ := d.Depacketize(&Packet{SequenceNumber: 7, Timestamp: 15000, Marker: true, Payload: []byte{0x41, 3}}); len(aus) != 1 || aus[0].Timestamp != 15000 {
+		t.Errorf("Expected only the next access unit, got %+v", aus)
+	}
+
+	// fragments are bounded before the end of the NALU
+	fragment := make([]byte, 1<<16)
+	fragment[0], fragment[1] = H264NALUTypeFUA, 0x85
+	seq := uint16(8)
+	var err error
+	for i := 0; err == nil && i <= maxAccessUnitSize/len(fragment); i++ {
+		_, err = d.Depacketize(&Packet{SequenceNumber: seq, Timestamp: 18000, Payload: fragment})
+		fragment[1], seq = 0x05, seq+1
+		if len(d.fu) > maxAccessUnitSize {
+			t.Fatalf("Fragments of %d bytes kept", len(d.fu))
+		}
+	}
+	if err != errAccessUnitTooLarge || d.fu != nil {
+		t.Errorf("Expected the fragments to be dropped, got %v", err)
+	}
+}
+
+func TestAACFrames(t *testing.T) {
+	frames := [][]byte{bytes.Repeat([]byte{1}, 300), bytes.Repeat([]byte{2}, 400)}
+	payloads := AACPayloads(frames, 1400)
+	res, err := AACFrames(payloads[0])
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if len(res) != 2 || !bytes.Equal(res[0], frames[0]) || !bytes.Equal(res[1], frames[1]) {
+		t.Errorf("Unexpected frames %d", len(res))
+	}
+	if _, err := AACFrames(payloads[0][:500]); err == nil {
+		t.Error("Expected an error for a truncated payload")
+	}
+}
+
+func TestRTCP(t *testing.T) {
+	// a receiver report followed by a PLI and a NACK of 10, 11 and 13
+	data := MarshalPLI(1, 2)
+	rr := RTCPPacket{Type: RTCPTypeRR, Payload: []byte{0, 0, 0, 1}}
+	nack := RTCPPacket{Type: RTCPTypeRTPFB, Count: RTCPFormatNACK, Payload: []byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 10, 0, 0x05}}
+	data = append(append(rr.Marshal(), data...), nack.Marshal()...)
+	if !bytes.Equal(data[8:20], []byte{0x81, 206, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2}) {
+		t.Errorf("Unexpected PLI %x", data[8:20])
+	}
+
+	packets, err := UnmarshalRTCP(data)
+	if err != nil || len(packets) != 3 {
+		t.Fatalf("Expected 3 packets, got %d (%v)", len(packets), err)
+	}
+	if packets[0].IsKeyframeRequest() || !packets[1].IsKeyframeRequest() || packets[2].IsKeyframeRequest() {
+		t.Error("Expected the PLI only to request a keyframe")
+	}
+	if ssrc, err := packets[1].MediaSSRC(); err != nil || ssrc != 2 {
+		t.Errorf("Expected media SSRC 2, got %d (%v)", ssrc, err)
+	}
+	seqs, err := packets[2].NACKs()
+	if err != nil || len(seqs) != 3 || seqs[0] != 10 || seqs[1] != 11 || seqs[2] != 13 {
+		t.Errorf("Expected NACKs of 10, 11 and 13, got %v (%v)", seqs, err)
+	}
+	if _, err := packets[1].NACKs(); err == nil {
+		t.Error("Expected an error for the NACKs of a PLI")
+	}
+
+	if _, err := UnmarshalRTCP(data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for a truncated packet")
+	}
+}
+
+func TestReports(t *testing.T) {
+	rr := MarshalReceiverReport(1, []ReceptionReport{{SSRC: 2, FractionLost: 64, TotalLost: -3, HighestSequence: 1<<16 | 5, Jitter: 90, LastSR: 7, DelaySinceLastSR: 65536}})
+	expected := []byte{0x81, 201, 0, 7, 0, 0, 0, 1, 0, 0, 0, 2, 64, 0xff, 0xff, 0xfd, 0, 1, 0, 5, 0, 0, 0, 90, 0, 0, 0, 7, 0, 1, 0, 0}
+	if !bytes.Equal(rr, expected) {
+		t.Errorf("Unexpected receiver report %x", rr)
+	}
+
+	// a sender report has the same report blocks after the sender info
+	now := time.Date(2024, 5, 6, 7, 8, 9, 500000000, time.UTC)
+	ntp := NTPTime(now)
+	if ntp != uint64(3923968089)<<32|1<<31 || !NTPToTime(ntp).Equal(now) {
+		t.Errorf("Unexpected NTP time %x of %v", ntp, now)
+	}
+	payload := binary.BigEndian.AppendUint32(nil, 2)
+	payload = binary.BigEndian.AppendUint64(payload, ntp)
+	payload = append(payload, 0, 0, 0x03, 0xe8, 0, 0, 0, 10, 0, 0, 0x10, 0)
+	payload = append(payload, rr[8:]...)
+	sr := RTCPPacket{Type: RTCPTypeSR, Count: 1, Payload: payload}
+	packets, err := UnmarshalRTCP(sr.Marshal())
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	report, err := packets[0].SenderReport()
+	if err != nil || report.SSRC != 2 || report.NTPTime != ntp || report.RTPTime != 1000 || report.PacketCount != 10 || report.OctetCount != 4096 ||
+		len(report.Reports) != 1 || report.Reports[0] != (ReceptionReport{SSRC: 2, FractionLost: 64, TotalLost: -3, HighestSequence: 1<<16 | 5, Jitter: 90, LastSR: 7, DelaySinceLastSR: 65536}) {
+		t.Errorf("Unexpected sender report %+v (%v)", report, err)
+	}
+	if _, err := (&RTCPPacket{Type: RTCPTypeRR, Payload: rr[4:]}).SenderReport(); err == nil {
+		t.Error("Expected an error for a receiver report")
+	}
+}
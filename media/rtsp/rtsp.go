@@ -68,6 +68,9 @@ const (
 	StatusUnsupportedMediaType StatusCode = 415
 	StatusRangeNotSatisfiable StatusCode = 416
 	StatusExpectationFailed   StatusCode = 417
+	StatusSessionNotFound     StatusCode = 454
+	StatusMethodNotValidInThisState StatusCode = 455
+	StatusAggregateOperationNotAllowed StatusCode = 459
 	StatusUnsupportedTransport StatusCode = 461
 	StatusInternalServerError StatusCode = 500
 	StatusNotImplemented      StatusCode = 501
@@ -147,6 +150,12 @@ func StatusText(code StatusCode) string {
 		return "Range Not Satisfiable"
 	case StatusExpectationFailed:
 		return "Expectation Failed"
+	case StatusSessionNotFound:
+		return "Session Not Found"
+	case StatusMethodNotValidInThisState:
+		return "Method Not Valid in This State"
+	case StatusAggregateOperationNotAllowed:
+		return "Aggregate Operation Not Allowed"
 	case StatusUnsupportedTransport:
 		return "Unsupported Transport"
 	case StatusInternalServerError:
@@ -199,6 +208,18 @@ func (h Header) Add(key, value string) {
 	h[titleKey] = append(h[titleKey], value)
 }
 
+// Values returns all values for the given key (case-insensitive)
+func (h Header) Values(key string) []string {
+	res := []string{}
+	lowerKey := strings.ToLower(key)
+	for k, values := range h {
+		if strings.ToLower(k) == lowerKey {
+			res = append(res, values...)
+		}
+	}
+	return res
+}
+
 // Message represents an RTSP message
 type Message struct {
 	Type      MessageType
@@ -0,0 +1,168 @@
+package rtsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SessionDescription represents the subset of SDP (RFC 4566) used by RTSP
+type SessionDescription struct {
+	Origin string
+	Name   string
+	// Control is the session level a=control, e.g. "*"
+	Control string
+	Medias  []MediaDescription
+}
+
+// MediaDescription represents an m= section with a single payload format
+type MediaDescription struct {
+	// Type is "video" or "audio"
+	Type        string
+	PayloadType uint8
+	// Encoding is the encoding name of a=rtpmap, e.g. H264, H265 or MPEG4-GENERIC
+	Encoding  string
+	ClockRate uint32
+	Channels  int
+	// Fmtp is the format parameters of a=fmtp without the payload type
+	Fmtp    string
+	Control string
+}
+
+// FmtpParams returns the parameters of a=fmtp, keys are in lower case
+func (m *MediaDescription) FmtpParams() map[string]string {
+	res := map[string]string{}
+	for _, param := range strings.Split(m.Fmtp, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key != "" {
+			res[strings.ToLower(key)] = value
+		}
+	}
+	return res
+}
+
+// Marshal serializes the session description
+func (s *SessionDescription) Marshal() []byte {
+	var b strings.Builder
+	origin := s.Origin
+	if origin == "" {
+		origin = "- 0 0 IN IP4 127.0.0.1"
+	}
+	name := s.Name
+	if name == "" {
+		name = "-"
+	}
+	fmt.Fprintf(&b, "v=0\r\no=%s\r\ns=%s\r\nc=IN IP4 0.0.0.0\r\nt=0 0\r\n", origin, name)
+	if s.Control != "" {
+		fmt.Fprintf(&b, "a=control:%s\r\n", s.Control)
+	}
+	for _, m := range s.Medias {
+		fmt.Fprintf(&b, "m=%s 0 RTP/AVP %d\r\n", m.Type, m.PayloadType)
+		rtpmap := fmt.Sprintf("%s/%d", m.Encoding, m.ClockRate)
+		if m.Channels > 0 {
+			rtpmap += "/" + strconv.Itoa(m.Channels)
+		}
+		fmt.Fprintf(&b, "a=rtpmap:%d %s\r\n", m.PayloadType, rtpmap)
+		if m.Fmtp != "" {
+			fmt.Fprintf(&b, "a=fmtp:%d %s\r\n", m.PayloadType, m.Fmtp)
+		}
+		if m.Control != "" {
+			fmt.Fprintf(&b, "a=control:%s\r\n", m.Control)
+		}
+	}
+	return []byte(b.String())
+}
+
+// ParseSDP parses a session description, only the first payload format of each media is kept
+func ParseSDP(data []byte) (*SessionDescription, error) {
+	s := &SessionDescription{}
+	var media *MediaDescription
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		key, value := line[0], line[2:]
+		switch {
+		case key == 'o':
+			s.Origin = value
+		case key == 's':
+			s.Name = value
+		case key == 'm':
+			fields := strings.Fields(value)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid media line: %s", line)
+			}
+			pt, err := strconv.ParseUint(fields[3], 10, 7)
+			if err != nil {
+				return nil, fmt.Errorf("invalid payload type in media line: %s", line)
+			}
+			s.Medias = append(s.Medias, MediaDescription{Type: fields[0], PayloadType: uint8(pt)})
+			media = &s.Medias[len(s.Medias)-1]
+		case key == 'a':
+			name, attr, _ := strings.Cut(value, ":")
+			switch {
+			case name == "control" && media == nil:
+				s.Control = attr
+			case name == "control":
+				media.Control = attr
+			case name == "rtpmap" && media != nil:
+				pt, format, _ := strings.Cut(attr, " ")
+				if pt != strconv.Itoa(int(media.PayloadType)) {
+					continue
+				}
+				parts := strings.Split(format, "/")
+				media.Encoding = parts[0]
+				if len(parts) > 1 {
+					rate, err := strconv.ParseUint(parts[1], 10, 32)
+					if err != nil {
+						return nil, fmt.Errorf("invalid clock rate in rtpmap: %s", line)
+					}
+					media.ClockRate = uint32(rate)
+				}
+				if len(parts) > 2 {
+					media.Channels, _ = strconv.Atoi(parts[2])
+				}
+			case name == "fmtp" && media != nil:
+				pt, params, _ := strings.Cut(attr, " ")
+				if pt == strconv.Itoa(int(media.PayloadType)) {
+					media.Fmtp = params
+				}
+			}
+		}
+	}
+	// static payload types have no rtpmap
+	for i := range s.Medias {
+		m := &s.Medias[i]
+		if m.Encoding == "" && m.PayloadType < 96 {
+			if static, ok := staticPayloadTypes[m.PayloadType]; ok {
+				m.Encoding, m.ClockRate, m.Channels = static.Encoding, static.ClockRate, static.Channels
+			}
+		}
+	}
+	return s, nil
+}
+
+var staticPayloadTypes = map[uint8]MediaDescription{
+	0:  {Encoding: "PCMU", ClockRate: 8000, Channels: 1},
+	8:  {Encoding: "PCMA", ClockRate: 8000, Channels: 1},
+	14: {Encoding: "MPA", ClockRate: 90000},
+	26: {Encoding: "JPEG", ClockRate: 90000},
+	32: {Encoding: "MPV", ClockRate: 90000},
+	33: {Encoding: "MP2T", ClockRate: 90000},
+}
+
+// ControlURL resolves the control attribute of a media against the base URL of the session,
+// which is Content-Base or the request URL of DESCRIBE
+func ControlURL(base, control string) string {
+	switch {
+	case control == "" || control == "*":
+		return base
+	case strings.Contains(control, "://"):
+		return control
+	case strings.HasSuffix(base, "/"):
+		return base + control
+	default:
+		return base + "/" + control
+	}
+}
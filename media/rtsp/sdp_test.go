@@ -0,0 +1,84 @@
+package rtsp
+
+import (
+	"testing"
+)
+
+func TestParseSDP(t *testing.T) {
+	// SDP of a typical IP camera
+	data := "v=0\r\n" +
+		"o=- 1700000000 1 IN IP4 192.168.1.64\r\n" +
+		"s=Media Presentation\r\n" +
+		"t=0 0\r\n" +
+		"a=control:*\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"a=fmtp:96 profile-level-id=420029; packetization-mode=1; sprop-parameter-sets=Z00AKp2oHgCJ+WbgICAgQA==,aO48gA==\r\n" +
+		"a=control:rtsp://192.168.1.64/Streaming/Channels/101/trackID=1\r\n" +
+		"m=audio 0 RTP/AVP 8\r\n" +
+		"a=control:trackID=2\r\n"
+	sdp, err := ParseSDP([]byte(data))
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+	if sdp.Name != "Media Presentation" || sdp.Control != "*" || len(sdp.Medias) != 2 {
+		t.Fatalf("Unexpected session %+v", sdp)
+	}
+	video := sdp.Medias[0]
+	if video.Encoding != "H264" || video.ClockRate != 90000 || video.FmtpParams()["packetization-mode"] != "1" ||
+		video.FmtpParams()["sprop-parameter-sets"] != "Z00AKp2oHgCJ+WbgICAgQA==,aO48gA==" {
+		t.Errorf("Unexpected video %+v", video)
+	}
+	audio := sdp.Medias[1]
+	if audio.Encoding != "PCMA" || audio.ClockRate != 8000 || audio.Channels != 1 {
+		t.Errorf("Unexpected audio %+v", audio)
+	}
+
+	base := "rtsp://192.168.1.64/Streaming/Channels/101/"
+	if url := ControlURL(base, video.Control); url != video.Control {
+		t.Errorf("Expected the absolute control URL, got %s", url)
+	}
+	if url := ControlURL(base, audio.Control); url != base+"trackID=2" {
+		t.Errorf("Unexpected control URL %s", url)
+	}
+
+	// Marshal then parse again
+	again, err := ParseSDP(sdp.Marshal())
+	if err != nil {
+		t.Fatalf("Failed to parse marshaled SDP: %v", err)
+	}
+	if len(again.Medias) != 2 || again.Medias[0] != sdp.Medias[0] || again.Medias[1].Encoding != "PCMA" {
+		t.Errorf("Unexpected SDP after marshaling %+v", again)
+	}
+}
+
+func TestParseTransport(t *testing.T) {
+	tr, err := ParseTransport("RTP/AVP;unicast;client_port=5000-5001;mode=\"PLAY\", RTP/AVP/TCP;unicast")
+	if err != nil {
+		t.Fatalf("Failed to parse transport: %v", err)
+	}
+	if tr.Protocol != "RTP/AVP" || tr.ClientPort != [2]int{5000, 5001} || tr.Mode != "PLAY" {
+		t.Errorf("Unexpected transport %+v", tr)
+	}
+	tr.ServerPort = [2]int{8000, 8001}
+	if s := tr.String(); s != "RTP/AVP;unicast;client_port=5000-5001;server_port=8000-8001;mode=PLAY" {
+		t.Errorf("Unexpected transport string %s", s)
+	}
+	if _, err := ParseTransport("RAW/RAW/UDP;unicast"); err == nil {
+		t.Error("Expected an error for an unsupported protocol")
+	}
+
+	transports, err := ParseTransports("RAW/RAW/UDP;unicast, RTP/AVP;unicast;client_port=5000, RTP/AVP/TCP;unicast;interleaved=2-3")
+	if err != nil || len(transports) != 2 {
+		t.Fatalf("Expected 2 transports, got %v %v", transports, err)
+	}
+	if transports[0].IsTCP() || transports[0].ClientPort != [2]int{5000, 5001} || transports[0].Interleaved != [2]int{-1, -1} {
+		t.Errorf("Unexpected UDP transport %+v", transports[0])
+	}
+	if tcp := transports[1]; !tcp.IsTCP() || tcp.Interleaved != [2]int{2, 3} || tcp.String() != "RTP/AVP/TCP;unicast;interleaved=2-3" {
+		t.Errorf("Unexpected TCP transport %+v", tcp)
+	}
+	if _, err := ParseTransport("RTP/AVP/TCP;interleaved=256-257"); err == nil {
+		t.Error("Expected an error for a channel out of range")
+	}
+}
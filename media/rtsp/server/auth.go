@@ -0,0 +1,192 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+)
+
+// DefaultNonceTimeout is the lifetime of Digest nonces
+const DefaultNonceTimeout = 5 * time.Minute
+
+// Authenticator checks the credentials of requests before they are handled
+type Authenticator interface {
+	// Authenticate returns nil to accept the request, or the response refusing it, usually 401 with challenges
+	Authenticate(session *Session, request *rtsp.Request) *rtsp.Response
+}
+
+// PasswordAuthenticator authenticates users by password with Digest, and Basic when enabled
+type PasswordAuthenticator struct {
+	// Realm is the protection space of the challenges
+	Realm string
+	// Algorithms are the Digest algorithms offered in the order of preference, SHA-256 then MD5 when empty
+	Algorithms []string
+	// Basic offers Basic authentication too, which sends the password in clear
+	Basic bool
+	// NonceTimeout is the lifetime of nonces, DefaultNonceTimeout when 0
+	NonceTimeout time.Duration
+	// Password returns the password of a user allowed to send the method to the URI, false to refuse
+	Password func(username string, method rtsp.Method, uri string) (string, bool)
+	// Public reports whether a request is allowed without credentials, none when nil
+	Public func(method rtsp.Method, uri string) bool
+
+	mu sync.Mutex
+	// key signs the nonces, which are verified without keeping them
+	key []byte
+}
+
+// NewPasswordAuthenticator creates a Digest authenticator
+func NewPasswordAuthenticator(realm string, password func(username string, method rtsp.Method, uri string) (string, bool)) *PasswordAuthenticator {
+	return &PasswordAuthenticator{
+		Realm:    realm,
+		Password: password,
+	}
+}
+
+// Authenticate implements Authenticator
+func (a *PasswordAuthenticator) Authenticate(session *Session, request *rtsp.Request) *rtsp.Response {
+	if a.Public != nil && a.Public(request.Method, request.URI) {
+		return nil
+	}
+	header := request.Header.Get("Authorization")
+	if header == "" {
+		return a.unauthorized(session, false)
+	}
+	auth, err := rtsp.ParseAuthorization(header)
+	if err != nil {
+		return a.unauthorized(session, false)
+	}
+	password, ok := "", false
+	if a.Password != nil {
+		password, ok = a.Password(auth.Username, request.Method, request.URI)
+	}
+
+	if auth.Scheme == rtsp.AuthBasic {
+		if !a.Basic || !ok || !auth.Verify(password, request.Method) {
+			return a.unauthorized(session, false)
+		}
+		return nil
+	}
+
+	// the digest covers the request URI, so it can't be replayed for another one
+	if !ok || auth.Realm != a.Realm || auth.URI != request.URI || !a.offers(auth.Algorithm) ||
+		!auth.Verify(password, request.Method) {
+		return a.unauthorized(session, false)
+	}
+	if fresh, known := a.checkNonce(auth.Nonce); !fresh {
+		// known but expired nonces were answered with the right password
+		return a.unauthorized(session, known)
+	}
+	return nil
+}
+
+// algorithms returns the offered Digest algorithms
+func (a *PasswordAuthenticator) algorithms() []string {
+	if len(a.Algorithms) == 0 {
+		return []string{rtsp.AlgorithmSHA256, rtsp.AlgorithmMD5}
+	}
+	return a.Algorithms
+}
+
+// offers reports whether an algorithm is offered, MD5 is the algorithm when absent
+func (a *PasswordAuthenticator) offers(algorithm string) bool {
+	if algorithm == "" {
+		algorithm = rtsp.AlgorithmMD5
+	}
+	for _, offered := range a.algorithms() {
+		if strings.EqualFold(offered, algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+// unauthorized returns 401 with a challenge per algorithm sharing the nonce of the session
+func (a *PasswordAuthenticator) unauthorized(session *Session, stale bool) *rtsp.Response {
+	response := &rtsp.Response{
+		Version:    "RTSP/1.0",
+		StatusCode: rtsp.StatusUnauthorized,
+		StatusText: rtsp.StatusText(rtsp.StatusUnauthorized),
+		Header:     make(rtsp.Header),
+	}
+	nonce := a.sessionNonce(session)
+	for _, algorithm := range a.algorithms() {
+		challenge := &rtsp.Challenge{
+			Scheme:    rtsp.AuthDigest,
+			Realm:     a.Realm,
+			Nonce:     nonce,
+			Algorithm: algorithm,
+			QOP:       "auth",
+			Stale:     stale,
+		}
+		response.Header.Add("WWW-Authenticate", challenge.String())
+	}
+	if a.Basic {
+		challenge := &rtsp.Challenge{Scheme: rtsp.AuthBasic, Realm: a.Realm}
+		response.Header.Add("WWW-Authenticate", challenge.String())
+	}
+	return response
+}
+
+// sessionNonce returns the nonce issued to the session, a new one once it has expired
+func (a *PasswordAuthenticator) sessionNonce(session *Session) string {
+	if session == nil {
+		return a.newNonce()
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if fresh, _ := a.checkNonce(session.nonce); !fresh {
+		session.nonce = a.newNonce()
+	}
+	return session.nonce
+}
+
+// newNonce issues a nonce made of the time of issue and its signature
+func (a *PasswordAuthenticator) newNonce() string {
+	issued := strconv.FormatInt(time.Now().UnixNano(), 16)
+	return issued + "-" + hex.EncodeToString(a.sign(issued))
+}
+
+// checkNonce reports whether a nonce was issued and whether it's still valid
+func (a *PasswordAuthenticator) checkNonce(nonce string) (fresh bool, known bool) {
+	issued, signature, ok := strings.Cut(nonce, "-")
+	if !ok {
+		return false, false
+	}
+	mac, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, a.sign(issued)) {
+		return false, false
+	}
+	ns, err := strconv.ParseInt(issued, 16, 64)
+	if err != nil {
+		return false, false
+	}
+	return time.Since(time.Unix(0, ns)) < a.nonceTimeout(), true
+}
+
+// sign returns the HMAC of the time of issue of a nonce, with a key random per authenticator
+func (a *PasswordAuthenticator) sign(issued string) []byte {
+	a.mu.Lock()
+	if a.key == nil {
+		a.key = make([]byte, 32)
+		rand.Read(a.key)
+	}
+	mac := hmac.New(sha256.New, a.key)
+	a.mu.Unlock()
+	mac.Write([]byte(issued))
+	return mac.Sum(nil)[:16]
+}
+
+func (a *PasswordAuthenticator) nonceTimeout() time.Duration {
+	if a.NonceTimeout == 0 {
+		return DefaultNonceTimeout
+	}
+	return a.NonceTimeout
+}
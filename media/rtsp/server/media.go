@@ -0,0 +1,321 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/mp4"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+// trackControl is the prefix of the control URL of a track, e.g. rtsp://host/video/trackID=0
+const trackControl = "trackID="
+
+// splitURI returns the media name and the track of a request URI,
+// track is -1 when the URI doesn't point to a track
+func splitURI(uri string) (string, int) {
+	p := uri
+	if u, err := url.Parse(uri); err == nil {
+		p = u.Path
+	}
+	p = strings.Trim(p, "/")
+	track := -1
+	if i := strings.LastIndex(p, "/"); strings.HasPrefix(p[i+1:], trackControl) {
+		if n, err := strconv.Atoi(p[i+1+len(trackControl):]); err == nil {
+			track = n
+			p = p[:max(i, 0)]
+		}
+	}
+	if p == "" {
+		p = "test"
+	}
+	return p, track
+}
+
+// resolveVideo returns the path of the video file of a media name in the video directory,
+// trying the name itself and then the name with .mp4
+func resolveVideo(dir, name string) (string, bool) {
+	// path.Clean on a rooted path drops any ".." which would leave dir
+	name = filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+name), "/"))
+	for _, candidate := range []string{name + ".mp4", name} {
+		p := filepath.Join(dir, candidate)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// videoDir returns the video directory of the server of the session
+func (s *Session) videoDir() string {
+	if s.Server != nil && s.Server.VideoDir != "" {
+		return s.Server.VideoDir
+	}
+	return "."
+}
+
+// openVideo opens the video file of a request URI, returning the status code on failure
+func (s *Session) openVideo(uri string) (*mp4.File, string, rtsp.StatusCode) {
+	name, _ := splitURI(uri)
+	videoPath, ok := resolveVideo(s.videoDir(), name)
+	if !ok {
+		return nil, "", rtsp.StatusNotFound
+	}
+	f, err := mp4.Open(videoPath)
+	if err != nil {
+		fmt.Printf("Failed to open video %s: %v\n", videoPath, err)
+		return nil, "", rtsp.StatusUnsupportedMediaType
+	}
+	return f, videoPath, rtsp.StatusOK
+}
+
+// statusError is an error with the status code of the response
+type statusError rtsp.StatusCode
+
+func (e statusError) Error() string {
+	return rtsp.StatusText(rtsp.StatusCode(e))
+}
+
+// statusOf returns the status code of an error, 500 when it's not a statusError
+func statusOf(err error) rtsp.StatusCode {
+	var status statusError
+	if errors.As(err, &status) {
+		return rtsp.StatusCode(status)
+	}
+	return rtsp.StatusInternalServerError
+}
+
+// setupFileTrack sets up a track of the MP4 file of a URI
+func (s *Session) setupFileTrack(uri string, transport *rtsp.Transport) (*SessionTrack, error) {
+	f, videoPath, status := s.openVideo(uri)
+	if status != rtsp.StatusOK {
+		return nil, statusError(status)
+	}
+	f.Close()
+	_, id := splitURI(uri)
+	if id == -1 && len(f.Tracks) == 1 {
+		id = 0
+	}
+	if id < 0 || id >= len(f.Tracks) {
+		return nil, statusError(rtsp.StatusNotFound)
+	}
+	if s.Mount != nil || s.VideoPath != "" && s.VideoPath != videoPath {
+		// all tracks of a session belong to one file
+		return nil, statusError(rtsp.StatusAggregateOperationNotAllowed)
+	}
+
+	track, err := s.setupTrack(id, transport)
+	if err != nil {
+		fmt.Printf("Failed to set up track %d: %v\n", id, err)
+		return nil, err
+	}
+	s.VideoPath = videoPath
+	return track, nil
+}
+
+// describeFile builds the SDP of the tracks of a file, the control of each track is trackID=index
+func describeFile(f *mp4.File, name string) *rtsp.SessionDescription {
+	sdp := &rtsp.SessionDescription{
+		Origin:  fmt.Sprintf("- %d 1 IN IP4 127.0.0.1", time.Now().Unix()),
+		Name:    "GoRTSP Server - " + name,
+		Control: "*",
+	}
+	for i, t := range f.Tracks {
+		sdp.Medias = append(sdp.Medias, trackMedia(t, i))
+	}
+	return sdp
+}
+
+// trackMedia returns the media description of a track with the payload type 96+index
+func trackMedia(t *mp4.Track, index int) rtsp.MediaDescription {
+	m := rtsp.MediaDescription{
+		Type:        "video",
+		PayloadType: uint8(96 + index),
+		ClockRate:   clockRate(t),
+		Control:     trackControl + strconv.Itoa(index),
+	}
+	switch t.Codec {
+	case mp4.CodecH264:
+		m.Encoding = "H264"
+		params := []string{"packetization-mode=1"}
+		if len(t.SPS) > 0 && len(t.SPS[0]) >= 4 {
+			params = append(params, "profile-level-id="+strings.ToUpper(hex.EncodeToString(t.SPS[0][1:4])))
+		}
+		if sets := base64List(append(append([][]byte{}, t.SPS...), t.PPS...)); sets != "" {
+			params = append(params, "sprop-parameter-sets="+sets)
+		}
+		m.Fmtp = strings.Join(params, ";")
+	case mp4.CodecH265:
+		m.Encoding = "H265"
+		m.Fmtp = fmt.Sprintf("sprop-vps=%s;sprop-sps=%s;sprop-pps=%s", base64List(t.VPS), base64List(t.SPS), base64List(t.PPS))
+	case mp4.CodecAAC:
+		m.Type = "audio"
+		m.Encoding = "MPEG4-GENERIC"
+		m.Channels = int(t.Channels)
+		m.Fmtp = "streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=" +
+			hex.EncodeToString(t.Config)
+	}
+	return m
+}
+
+func base64List(nalus [][]byte) string {
+	res := make([]string, 0, len(nalus))
+	for _, nalu := range nalus {
+		res = append(res, base64.StdEncoding.EncodeToString(nalu))
+	}
+	return strings.Join(res, ",")
+}
+
+// clockRate returns the RTP clock rate of a track, 90000 for video and the sample rate for audio
+func clockRate(t *mp4.Track) uint32 {
+	if t.IsVideo() || t.SampleRate == 0 {
+		return 90000
+	}
+	return t.SampleRate
+}
+
+// convertTime converts v from the timescale from to the timescale to without overflowing
+func convertTime(v uint64, to, from uint32) uint64 {
+	return v/uint64(from)*uint64(to) + v%uint64(from)*uint64(to)/uint64(from)
+}
+
+// playTrack is the state of a track being played
+type playTrack struct {
+	*SessionTrack
+	track *mp4.Track
+	clock uint32
+	// period is the length of one loop of the file in the timescale of the track
+	period uint64
+	loops  uint64
+	next   int
+}
+
+// position returns the time of the next sample since the start of the stream
+func (p *playTrack) position() time.Duration {
+	pos := p.loops*p.period + p.track.Samples[p.next].DTS
+	return time.Duration(convertTime(pos, 1000000, p.track.TimeScale)) * time.Microsecond
+}
+
+// timestamp returns the RTP timestamp of a sample of the current loop
+func (p *playTrack) timestamp(sample mp4.Sample) uint32 {
+	pts := int64(p.loops*p.period) + sample.PTS()
+	if pts < 0 {
+		pts = 0
+	}
+	return p.timestampBase + uint32(convertTime(uint64(pts), p.clock, p.track.TimeScale))
+}
+
+// playFile sends the samples of the tracks set up in the session at their pace,
+// starting over at the end of the file until ctx is done
+func (s *Session) playFile(ctx context.Context, f *mp4.File, tracks []*SessionTrack) error {
+	// one loop lasts as long as the longest track
+	var length time.Duration
+	for _, t := range f.Tracks {
+		if d := time.Duration(convertTime(t.Duration, 1000000, t.TimeScale)) * time.Microsecond; d > length {
+			length = d
+		}
+	}
+	states := []*playTrack{}
+	for _, st := range tracks {
+		t := f.Tracks[st.ID]
+		if len(t.Samples) == 0 {
+			continue
+		}
+		states = append(states, &playTrack{
+			SessionTrack: st,
+			track:        t,
+			clock:        clockRate(t),
+			period:       uint64(length/time.Microsecond) * uint64(t.TimeScale) / 1000000,
+		})
+	}
+	if len(states) == 0 || length <= 0 {
+		return fmt.Errorf("nothing to play")
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		// the track whose next sample is due first
+		p := states[0]
+		for _, state := range states[1:] {
+			if state.position() < p.position() {
+				p = state
+			}
+		}
+		if wait := time.Until(start.Add(p.position())); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-timer.C:
+			}
+		} else if ctx.Err() != nil {
+			return nil
+		}
+
+		sample := p.track.Samples[p.next]
+		data, err := f.ReadSample(sample)
+		if err != nil {
+			return err
+		}
+		payloads, err := samplePayloads(p.track, sample, data)
+		if err != nil {
+			return err
+		}
+		for _, packet := range p.packetizer.Packetize(payloads, p.timestamp(sample)) {
+			if err := p.writeRTP(packet.Marshal()); err != nil {
+				return err
+			}
+		}
+
+		if p.next++; p.next == len(p.track.Samples) {
+			p.next = 0
+			p.loops++
+		}
+	}
+}
+
+// samplePayloads returns the RTP payloads of a sample, keyframes of H.264 and H.265 get
+// the parameter sets in-band so that decoders can join at any keyframe
+func samplePayloads(t *mp4.Track, sample mp4.Sample, data []byte) ([][]byte, error) {
+	if t.Codec == mp4.CodecAAC {
+		return rtp.AACPayloads([][]byte{data}, rtp.DefaultPayloadSize), nil
+	}
+	nalus, err := t.NALUs(data)
+	if err != nil {
+		return nil, err
+	}
+	if sample.Keyframe {
+		nalus = withParameterSets(t, nalus)
+	}
+	if t.Codec == mp4.CodecH265 {
+		return rtp.H265Payloads(nalus, rtp.DefaultPayloadSize), nil
+	}
+	return rtp.H264Payloads(nalus, rtp.DefaultPayloadSize), nil
+}
+
+// withParameterSets prepends the parameter sets of the track unless the NALUs contain them
+func withParameterSets(t *mp4.Track, nalus [][]byte) [][]byte {
+	for _, nalu := range nalus {
+		if t.Codec == mp4.CodecH264 && nalu[0]&0x1f == 7 || t.Codec == mp4.CodecH265 && nalu[0]>>1&0x3f == 32 {
+			return nalus
+		}
+	}
+	res := [][]byte{}
+	res = append(res, t.VPS...)
+	res = append(res, t.SPS...)
+	res = append(res, t.PPS...)
+	return append(res, nalus...)
+}
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+// viewerQueueSize is the number of packets queued for a viewer, the packets of a slower viewer are dropped
+const viewerQueueSize = 512
+
+// Mount is a path published with ANNOUNCE and RECORD, relaying the RTP of the publisher to the viewers
+type Mount struct {
+	Path string
+	// SDP is the session description announced by the publisher
+	SDP *rtsp.SessionDescription
+	// Publisher is the session which announced the mount
+	Publisher *Session
+	CreatedAt time.Time
+
+	// uri is the announced URI, the base of the controls of the SDP
+	uri       string
+	mu        sync.Mutex
+	recording bool
+	closed    bool
+	viewers   map[*viewer]struct{}
+	dropped   uint64
+}
+
+// viewer is a PLAY session of a mount
+type viewer struct {
+	session *Session
+	queue   chan viewerPacket
+	// tracks are indexed by the media index of the SDP, nil when not set up
+	tracks []*viewerTrack
+}
+
+// viewerTrack rewrites the packets of a media for a viewer
+type viewerTrack struct {
+	*SessionTrack
+	// started is set by the first packet, which gives the offsets from the packets of the publisher
+	started   bool
+	seqOffset uint16
+	tsOffset  uint32
+	// waitKeyframe drops packets until a keyframe, initially and after dropping a packet
+	waitKeyframe bool
+}
+
+type viewerPacket struct {
+	track *viewerTrack
+	data  []byte
+}
+
+// Mounts returns the published mounts by path
+func (s *Server) Mounts() map[string]*Mount {
+	s.Lock()
+	defer s.Unlock()
+	res := make(map[string]*Mount, len(s.mounts))
+	for k, v := range s.mounts {
+		res[k] = v
+	}
+	return res
+}
+
+// mount returns the mount of a path, nil when it's not published
+func (s *Server) mount(path string) *Mount {
+	s.Lock()
+	defer s.Unlock()
+	return s.mounts[path]
+}
+
+// IsRecording reports whether the publisher sent RECORD
+func (m *Mount) IsRecording() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.recording
+}
+
+// ViewerCount returns the number of sessions playing the mount
+func (m *Mount) ViewerCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.viewers)
+}
+
+// Dropped returns the number of packets dropped for slow viewers
+func (m *Mount) Dropped() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dropped
+}
+
+// describe returns the SDP of the mount for viewers, the control of each media is trackID=index
+func (m *Mount) describe() *rtsp.SessionDescription {
+	sdp := &rtsp.SessionDescription{
+		Origin:  fmt.Sprintf("- %d 1 IN IP4 127.0.0.1", m.CreatedAt.Unix()),
+		Name:    m.SDP.Name,
+		Control: "*",
+	}
+	if sdp.Name == "" {
+		sdp.Name = "GoRTSP Server - " + m.Path
+	}
+	for i, media := range m.SDP.Medias {
+		media.Control = fmt.Sprintf("%s%d", trackControl, i)
+		sdp.Medias = append(sdp.Medias, media)
+	}
+	return sdp
+}
+
+// trackIndex returns the index of the media of a SETUP URI of the publisher, -1 when not found
+func (m *Mount) trackIndex(uri string) int {
+	for i, media := range m.SDP.Medias {
+		if uri == rtsp.ControlURL(m.uri, media.Control) ||
+			media.Control != "" && strings.HasSuffix(uri, "/"+media.Control) {
+			return i
+		}
+	}
+	if _, id := splitURI(uri); id >= 0 && id < len(m.SDP.Medias) {
+		return id
+	}
+	if len(m.SDP.Medias) == 1 {
+		return 0
+	}
+	return -1
+}
+
+// publish relays an RTP packet of a media to the viewers
+func (m *Mount) publish(index int, data []byte) {
+	p, err := rtp.Unmarshal(data)
+	if err != nil || index < 0 || index >= len(m.SDP.Medias) {
+		return
+	}
+	encoding := m.SDP.Medias[index].Encoding
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.recording {
+		return
+	}
+	for v := range m.viewers {
+		track := v.tracks[index]
+		if track == nil {
+			continue
+		}
+		// viewers start with a keyframe, and resume with one after a drop
+		if track.waitKeyframe {
+			if !rtp.IsKeyframe(encoding, p.Payload) {
+				continue
+			}
+			track.waitKeyframe = false
+		}
+		if !track.started {
+			track.started = true
+			track.seqOffset = track.packetizer.SequenceNumber - p.SequenceNumber
+			track.tsOffset = track.timestampBase - p.Timestamp
+		}
+		rewritten := *p
+		rewritten.SequenceNumber += track.seqOffset
+		rewritten.Timestamp += track.tsOffset
+		rewritten.SSRC = track.packetizer.SSRC
+		rewritten.PayloadType = track.packetizer.PayloadType
+		select {
+		case v.queue <- viewerPacket{track: track, data: rewritten.Marshal()}:
+		default:
+			// the viewer is slower than the publisher
+			m.dropped++
+			track.waitKeyframe = true
+		}
+	}
+}
+
+// addViewer adds the tracks of a session to the viewers, nil when the mount is closed
+func (m *Mount) addViewer(session *Session, tracks []*SessionTrack) *viewer {
+	v := &viewer{
+		session: session,
+		queue:   make(chan viewerPacket, viewerQueueSize),
+		tracks:  make([]*viewerTrack, len(m.SDP.Medias)),
+	}
+	for _, track := range tracks {
+		if track.ID < len(v.tracks) {
+			v.tracks[track.ID] = &viewerTrack{SessionTrack: track, waitKeyframe: true}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	if m.viewers == nil {
+		m.viewers = make(map[*viewer]struct{})
+	}
+	m.viewers[v] = struct{}{}
+	return v
+}
+
+// removeViewer removes a viewer which was not removed by close
+func (m *Mount) removeViewer(v *viewer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.viewers[v]; ok {
+		delete(m.viewers, v)
+		close(v.queue)
+	}
+}
+
+// close ends the viewers
+func (m *Mount) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for v := range m.viewers {
+		close(v.queue)
+	}
+	m.viewers = nil
+}
+
+// relay sends the packets of the mount to the session until ctx is canceled or the mount is closed
+func (s *Session) relay(ctx context.Context, m *Mount, tracks []*SessionTrack) error {
+	v := m.addViewer(s, tracks)
+	if v == nil {
+		return fmt.Errorf("mount %s is closed", m.Path)
+	}
+	defer m.removeViewer(v)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case p, ok := <-v.queue:
+			if !ok {
+				return nil
+			}
+			if err := p.track.writeRTP(p.data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publishedMount returns the mount published by the session, nil when it's not a publisher
+func (s *Session) publishedMount() *Mount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.Publishing {
+		return nil
+	}
+	return s.Mount
+}
+
+// announce publishes a mount at the path of a URI, failing when the path is already published
+func (s *Session) announce(uri string, sdp *rtsp.SessionDescription) (*Mount, bool) {
+	name, _ := splitURI(uri)
+	m := &Mount{
+		Path:      name,
+		SDP:       sdp,
+		Publisher: s,
+		CreatedAt: time.Now(),
+		uri:       strings.TrimSuffix(uri, "/"),
+	}
+	s.Server.Lock()
+	defer s.Server.Unlock()
+	if s.Server.mounts[name] != nil {
+		return nil, false
+	}
+	if s.Server.mounts == nil {
+		s.Server.mounts = make(map[string]*Mount)
+	}
+	s.Server.mounts[name] = m
+	s.mu.Lock()
+	s.Mount, s.Publishing = m, true
+	s.mu.Unlock()
+	return m, true
+}
+
+// unpublish removes the mount published by the session, ending its viewers
+func (s *Session) unpublish() {
+	s.mu.Lock()
+	m, publishing := s.Mount, s.Publishing
+	if publishing {
+		s.Mount, s.Publishing = nil, false
+	}
+	s.mu.Unlock()
+	if !publishing || m == nil {
+		return
+	}
+	s.Server.Lock()
+	if s.Server.mounts[m.Path] == m {
+		delete(s.Server.mounts, m.Path)
+	}
+	s.Server.Unlock()
+	m.close()
+}
+
+// setupRecordTrack sets up a media of the mount published by the session, receiving over UDP
+// or interleaved in the RTSP connection
+func (s *Session) setupRecordTrack(m *Mount, id int, transport *rtsp.Transport) (*SessionTrack, error) {
+	track := &SessionTrack{ID: id, session: s}
+	if transport.IsTCP() {
+		channels := transport.Interleaved
+		if channels[0] < 0 {
+			channels = [2]int{2 * id, 2*id + 1}
+		}
+		track.Transport = &rtsp.Transport{Protocol: transport.Protocol, Interleaved: channels, Mode: "RECORD"}
+	} else {
+		ip := s.Conn.LocalAddr().(*net.TCPAddr).IP
+		rtpConn, rtcpConn, err := listenUDPPair(ip)
+		if err != nil {
+			return nil, err
+		}
+		track.RTPConn, track.RTCPConn = rtpConn, rtcpConn
+		track.Transport = &rtsp.Transport{
+			Protocol:   transport.Protocol,
+			ClientPort: transport.ClientPort,
+			ServerPort: [2]int{rtpConn.LocalAddr().(*net.UDPAddr).Port, rtcpConn.LocalAddr().(*net.UDPAddr).Port},
+			Mode:       "RECORD",
+		}
+		go receiveUDP(rtpConn, func(data []byte) { m.publish(id, data) })
+		// RTCP of the publisher is consumed
+		go receiveUDP(rtcpConn, func([]byte) {})
+	}
+	track.recording = true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Tracks == nil {
+		s.Tracks = map[int]*SessionTrack{}
+	}
+	if old := s.Tracks[id]; old != nil {
+		old.close()
+	}
+	s.Tracks[id] = track
+	return track, nil
+}
+
+// handleFrame relays the interleaved RTP of the publisher
+func (s *Session) handleFrame(frame *rtsp.InterleavedFrame) {
+	s.mu.Lock()
+	m, publishing := s.Mount, s.Publishing
+	var id = -1
+	for _, track := range s.Tracks {
+		if track.recording && track.Transport.IsTCP() && track.Transport.Interleaved[0] == int(frame.Channel) {
+			id = track.ID
+		}
+	}
+	s.mu.Unlock()
+	if publishing && id >= 0 {
+		m.publish(id, frame.Payload)
+	}
+}
+
+// listenUDPPair listens on a pair of UDP ports, RTP on the even one
+func listenUDPPair(ip net.IP) (*net.UDPConn, *net.UDPConn, error) {
+	for i := 0; i < 100; i++ {
+		rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip})
+		if err != nil {
+			return nil, nil, err
+		}
+		port := rtpConn.LocalAddr().(*net.UDPAddr).Port
+		if port%2 != 0 {
+			rtpConn.Close()
+			continue
+		}
+		rtcpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port + 1})
+		if err != nil {
+			rtpConn.Close()
+			continue
+		}
+		return rtpConn, rtcpConn, nil
+	}
+	return nil, nil, fmt.Errorf("no available UDP ports found")
+}
+
+// receiveUDP passes the packets of a connection to handle until it's closed
+func receiveUDP(conn *net.UDPConn, handle func([]byte)) {
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		handle(append([]byte{}, buf[:n]...))
+	}
+}
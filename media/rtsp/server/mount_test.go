@@ -0,0 +1,216 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/client"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+	"github.com/wwqdrh/gokit/media/rtsp/stream"
+)
+
+func TestRelay(t *testing.T) {
+	s := startTestServer(t)
+	uri := fmt.Sprintf("rtsp://%s/live/cam", s.Addr())
+	sdp := &rtsp.SessionDescription{
+		Origin: "- 0 0 IN IP4 127.0.0.1",
+		Name:   "camera",
+		Medias: []rtsp.MediaDescription{
+			{Type: "video", PayloadType: 96, Encoding: "H264", ClockRate: 90000, Fmtp: "packetization-mode=1", Control: "streamid=0"},
+			{Type: "audio", PayloadType: 97, Encoding: "MPEG4-GENERIC", ClockRate: 8000, Channels: 1, Control: "streamid=1"},
+		},
+	}
+
+	publisher := client.NewClient()
+	if err := publisher.Connect(s.Addr().String()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer publisher.Close()
+	response, err := publisher.Announce(uri, sdp.Marshal())
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("ANNOUNCE failed: %v %v", response, err)
+	}
+
+	// video interleaved, audio over UDP
+	response, err = publisher.Setup(uri+"/streamid=0", "RTP/AVP/TCP;unicast;interleaved=0-1;mode=record")
+	if err != nil || response.StatusCode != rtsp.StatusOK || !strings.Contains(response.Header.Get("Transport"), "mode=RECORD") {
+		t.Fatalf("SETUP of video failed: %v %v", response, err)
+	}
+	audioConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audioConn.Close()
+	port := audioConn.LocalAddr().(*net.UDPAddr).Port
+	response, err = publisher.Setup(uri+"/streamid=1", fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d;mode=record", port, port+1))
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("SETUP of audio failed: %v %v", response, err)
+	}
+	tr, err := rtsp.ParseTransport(response.Header.Get("Transport"))
+	if err != nil || tr.ServerPort[0] == 0 {
+		t.Fatalf("Unexpected transport %s", response.Header.Get("Transport"))
+	}
+	serverAudio := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: tr.ServerPort[0]}
+
+	response, err = publisher.Record(uri)
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("RECORD failed: %v %v", response, err)
+	}
+	m := s.Mounts()["live/cam"]
+	if m == nil || !m.IsRecording() {
+		t.Fatalf("Expected a recording mount, got %v", s.Mounts())
+	}
+
+	// the path is taken
+	other := client.NewClient()
+	if err := other.Connect(s.Addr().String()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	response, err = other.Announce(uri, sdp.Marshal())
+	other.Close()
+	if err != nil || response.StatusCode != rtsp.StatusForbidden {
+		t.Errorf("Expected 403 for a published path, got %v %v", response, err)
+	}
+
+	// 200 packets per second, a keyframe every 10 video packets
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			payload := []byte{0x41, 0x9a, byte(i)}
+			if i%10 == 0 {
+				payload = []byte{0x67, 0x64, byte(i)}
+			}
+			video := &rtp.Packet{Marker: true, PayloadType: 96, SequenceNumber: uint16(65000 + i), Timestamp: uint32(3000 * i), SSRC: 0xdeadbeef, Payload: payload}
+			if err := publisher.WriteFrame(0, video.Marshal()); err != nil {
+				return
+			}
+			audio := &rtp.Packet{Marker: true, PayloadType: 97, SequenceNumber: uint16(i), Timestamp: uint32(160 * i), SSRC: 0xfeedbeef, Payload: []byte{0, 16, 0, 8, byte(i)}}
+			audioConn.WriteToUDP(audio.Marshal(), serverAudio)
+		}
+	}()
+
+	viewers := []*stream.RTSPStream{}
+	for _, transport := range []string{stream.TransportTCP, stream.TransportUDP} {
+		rs := stream.NewRTSPStream(stream.RTSPConfig{URL: uri, Transport: transport})
+		if err := rs.Start(); err != nil {
+			t.Fatalf("Failed to start the %s viewer: %v", transport, err)
+		}
+		defer rs.Stop()
+		viewers = append(viewers, rs)
+	}
+
+	for i, rs := range viewers {
+		videos := []stream.RTPInfo{}
+		audios := 0
+		timeout := time.After(3 * time.Second)
+		for len(videos) < 25 || audios < 5 {
+			select {
+			case p := <-rs.GetPacketChan():
+				if p.Track == 0 {
+					videos = append(videos, p)
+				} else if p.PayloadType == 97 && p.SSRC != 0xfeedbeef {
+					audios++
+				}
+			case <-timeout:
+				t.Fatalf("Viewer %d: timed out with %d video and %d audio packets", i, len(videos), audios)
+			}
+		}
+		if videos[0].Payload[0] != 0x67 {
+			t.Errorf("Viewer %d: expected to start with a keyframe, got %x", i, videos[0].Payload)
+		}
+		for j, p := range videos {
+			if p.PayloadType != 96 || p.SSRC == 0xdeadbeef {
+				t.Errorf("Viewer %d: unexpected payload type %d or SSRC %x", i, p.PayloadType, p.SSRC)
+			}
+			if j == 0 {
+				continue
+			}
+			if p.SequenceNumber != videos[j-1].SequenceNumber+1 || p.Timestamp != videos[j-1].Timestamp+3000 {
+				t.Errorf("Viewer %d: packet %d seq %d ts %d after seq %d ts %d", i, j, p.SequenceNumber, p.Timestamp,
+					videos[j-1].SequenceNumber, videos[j-1].Timestamp)
+			}
+		}
+	}
+
+	if m.ViewerCount() != 2 {
+		t.Errorf("Expected 2 viewers, got %d", m.ViewerCount())
+	}
+	publishers := 0
+	for _, session := range s.Sessions() {
+		if session.Publishing && session.Mount == m {
+			publishers++
+		}
+	}
+	if publishers != 1 {
+		t.Errorf("Expected the publisher in the sessions, got %d", publishers)
+	}
+
+	response, err = publisher.Teardown(uri)
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("TEARDOWN failed: %v %v", response, err)
+	}
+	if len(s.Mounts()) != 0 || m.ViewerCount() != 0 {
+		t.Errorf("Expected the mount and its viewers to be removed")
+	}
+}
+
+func TestSlowViewer(t *testing.T) {
+	m := &Mount{
+		SDP:       &rtsp.SessionDescription{Medias: []rtsp.MediaDescription{{Type: "video", PayloadType: 96, Encoding: "H264"}}},
+		recording: true,
+	}
+	track := &SessionTrack{ID: 0, packetizer: rtp.Packetizer{PayloadType: 96, SSRC: 1, SequenceNumber: 100}, timestampBase: 5000}
+	v := m.addViewer(&Session{}, []*SessionTrack{track})
+	publish := func(seq uint16, keyframe bool) {
+		payload := []byte{0x41, 0}
+		if keyframe {
+			payload = []byte{0x65, 0}
+		}
+		m.publish(0, (&rtp.Packet{PayloadType: 33, SequenceNumber: seq, Timestamp: uint32(seq) * 10, SSRC: 2, Payload: payload}).Marshal())
+	}
+
+	// nothing before a keyframe, then the queue fills up
+	publish(1, false)
+	for seq := uint16(2); seq < viewerQueueSize+20; seq++ {
+		publish(seq, seq == 2)
+	}
+	if len(v.queue) != viewerQueueSize || m.Dropped() != 1 {
+		t.Fatalf("Expected a full queue and 1 drop, got %d packets and %d drops", len(v.queue), m.Dropped())
+	}
+	first := <-v.queue
+	p, _ := rtp.Unmarshal(first.data)
+	if p.SequenceNumber != 100 || p.Timestamp != 5000 || p.SSRC != 1 || p.PayloadType != 96 {
+		t.Errorf("Unexpected rewritten packet %+v", p)
+	}
+	for len(v.queue) > 0 {
+		<-v.queue
+	}
+
+	// after the drop the viewer resumes with a keyframe, the gap shows the loss
+	publish(1000, false)
+	publish(1001, true)
+	if len(v.queue) != 1 {
+		t.Fatalf("Expected only the keyframe, got %d packets", len(v.queue))
+	}
+	p, _ = rtp.Unmarshal((<-v.queue).data)
+	if p.SequenceNumber != 100+1001-2 || p.Timestamp != 5000+(1001-2)*10 {
+		t.Errorf("Unexpected rewritten packet %+v", p)
+	}
+
+	m.close()
+	if _, ok := <-v.queue; ok {
+		t.Error("Expected the queue to be closed with the mount")
+	}
+}
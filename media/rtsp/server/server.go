@@ -2,17 +2,21 @@ package server
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/mp4"
 	"github.com/wwqdrh/gokit/media/rtsp/stream"
-) // Handler defines the interface for RTSP request handlers
+)
+
+// Handler defines the interface for RTSP request handlers
 type Handler interface {
 	HandleOPTIONS(*Session, *rtsp.Request) (*rtsp.Response, error)
 	HandleDESCRIBE(*Session, *rtsp.Request) (*rtsp.Response, error)
@@ -42,7 +46,8 @@ func (h *DefaultHandler) HandleOPTIONS(session *Session, request *rtsp.Request)
 	return response, nil
 }
 
-// HandleDESCRIBE handles DESCRIBE requests
+// HandleDESCRIBE handles DESCRIBE requests, describing the medias of a published mount
+// or the tracks of the MP4 file in Server.VideoDir
 func (h *DefaultHandler) HandleDESCRIBE(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
 	response := &rtsp.Response{
 		Version:    "RTSP/1.0",
@@ -50,39 +55,32 @@ func (h *DefaultHandler) HandleDESCRIBE(session *Session, request *rtsp.Request)
 		StatusText: rtsp.StatusText(rtsp.StatusOK),
 		Header:     make(rtsp.Header),
 	}
+
+	name, _ := splitURI(request.URI)
 	response.Header.Set("Content-Type", "application/sdp")
+	// track controls are relative to Content-Base
+	response.Header.Set("Content-Base", strings.TrimSuffix(request.URI, "/")+"/")
 
-	// Extract video filename from URI
-	videoFile := request.URI
-	if videoFile == "/" {
-		videoFile = "test"
-	} else {
-		// Remove leading slash
-		videoFile = videoFile[1:]
-	}
-
-	// Example SDP with proper stream information
-	sdp := `v=0
-` +
-		`o=- 12345 12345 IN IP4 127.0.0.1
-` +
-		`s=GoRTSP Server - ` + videoFile + `
-` +
-		`t=0 0
-` +
-		`m=video 0 RTP/AVP 96
-` +
-		`c=IN IP4 0.0.0.0
-` +
-		`a=rtpmap:96 H264/90000
-` +
-		`a=control:streamid=0
-`
-	response.Body = []byte(sdp)
+	// published mounts come before files
+	if m := session.Server.mount(name); m != nil {
+		response.Body = m.describe().Marshal()
+		return response, nil
+	}
+
+	f, _, status := session.openVideo(request.URI)
+	if status != rtsp.StatusOK {
+		response.Header = make(rtsp.Header)
+		response.StatusCode = status
+		response.StatusText = rtsp.StatusText(status)
+		return response, nil
+	}
+	defer f.Close()
+
+	response.Body = describeFile(f, name).Marshal()
 	return response, nil
 }
 
-// HandleSETUP handles SETUP requests
+// HandleSETUP handles SETUP requests of a track over UDP or interleaved in the RTSP connection
 func (h *DefaultHandler) HandleSETUP(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
 	response := &rtsp.Response{
 		Version:    "RTSP/1.0",
@@ -90,54 +88,71 @@ func (h *DefaultHandler) HandleSETUP(session *Session, request *rtsp.Request) (*
 		StatusText: rtsp.StatusText(rtsp.StatusOK),
 		Header:     make(rtsp.Header),
 	}
-
-	// Parse transport header
-	transport := request.Header.Get("Transport")
-	if transport == "" {
-		response.StatusCode = rtsp.StatusBadRequest
-		response.StatusText = rtsp.StatusText(rtsp.StatusBadRequest)
+	fail := func(status rtsp.StatusCode) (*rtsp.Response, error) {
+		response.StatusCode = status
+		response.StatusText = rtsp.StatusText(status)
 		return response, nil
 	}
 
-	// Extract client ports from transport header
-	// Example transport: RTP/AVP;unicast;client_port=5000-5001
-	clientPort := ""
-	parts := strings.Split(transport, ";")
-	for _, part := range parts {
-		if strings.HasPrefix(part, "client_port=") {
-			clientPort = strings.TrimPrefix(part, "client_port=")
+	// Parse transport header, the first supported one of the client's choices is taken
+	// Example transport: RTP/AVP;unicast;client_port=5000-5001, RTP/AVP/TCP;unicast;interleaved=0-1
+	transports, err := rtsp.ParseTransports(request.Header.Get("Transport"))
+	if err != nil {
+		return fail(rtsp.StatusUnsupportedTransport)
+	}
+	var transport *rtsp.Transport
+	for _, t := range transports {
+		if !t.Multicast && (t.IsTCP() || t.ClientPort[0] != 0) {
+			transport = t
 			break
 		}
 	}
-
-	if clientPort == "" {
-		response.StatusCode = rtsp.StatusBadRequest
-		response.StatusText = rtsp.StatusText(rtsp.StatusBadRequest)
-		return response, nil
+	if transport == nil {
+		return fail(rtsp.StatusUnsupportedTransport)
 	}
 
-	// Extract client IP from connection
-	// clientAddr := session.Conn.RemoteAddr().(*net.TCPAddr).IP.String()
-
-	// Parse client ports
-	portParts := strings.Split(clientPort, "-")
-	if len(portParts) != 2 {
-		response.StatusCode = rtsp.StatusBadRequest
-		response.StatusText = rtsp.StatusText(rtsp.StatusBadRequest)
-		return response, nil
+	var track *SessionTrack
+	name, id := splitURI(request.URI)
+	if m := session.publishedMount(); m != nil {
+		// the publisher sets up the medias of its SDP
+		if id = m.trackIndex(request.URI); id < 0 {
+			return fail(rtsp.StatusNotFound)
+		}
+		if track, err = session.setupRecordTrack(m, id, transport); err != nil {
+			fmt.Printf("Failed to set up record track %d: %v\n", id, err)
+			return fail(rtsp.StatusInternalServerError)
+		}
+	} else if m := session.Server.mount(name); m != nil {
+		if id == -1 && len(m.SDP.Medias) == 1 {
+			id = 0
+		}
+		if id < 0 || id >= len(m.SDP.Medias) {
+			return fail(rtsp.StatusNotFound)
+		}
+		if session.VideoPath != "" || session.Mount != nil && session.Mount != m {
+			return fail(rtsp.StatusAggregateOperationNotAllowed)
+		}
+		if track, err = session.setupTrack(id, transport); err != nil {
+			fmt.Printf("Failed to set up track %d: %v\n", id, err)
+			return fail(rtsp.StatusInternalServerError)
+		}
+		// viewers get the payload types of the publisher
+		track.packetizer.PayloadType = m.SDP.Medias[id].PayloadType
+		session.mu.Lock()
+		session.Mount = m
+		session.mu.Unlock()
+	} else if track, err = session.setupFileTrack(request.URI, transport); err != nil {
+		return fail(statusOf(err))
 	}
+	session.Transport = track.Transport.String()
 
-	// Store client address and ports in session
-	session.Transport = transport
-
-	// Set transport response
-	response.Header.Set("Transport", transport+";server_port=8000-8001")
+	response.Header.Set("Transport", session.Transport)
 	response.Header.Set("Session", session.ID)
-
 	return response, nil
 }
 
-// HandlePLAY handles PLAY requests
+// HandlePLAY handles PLAY requests, relaying the live packets of a mount, or streaming the tracks
+// set up in the session from the start of the file and starting over at the end
 func (h *DefaultHandler) HandlePLAY(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
 	response := &rtsp.Response{
 		Version:    "RTSP/1.0",
@@ -146,171 +161,61 @@ func (h *DefaultHandler) HandlePLAY(session *Session, request *rtsp.Request) (*r
 		Header:     make(rtsp.Header),
 	}
 	response.Header.Set("Session", session.ID)
-	response.Header.Set("RTP-Info", "url="+request.URI+";seq=0;rtptime=0")
+
+	tracks := session.setupTracks()
+	session.mu.Lock()
+	m, publishing := session.Mount, session.Publishing
+	session.mu.Unlock()
+	if session.VideoPath == "" && m == nil || publishing || len(tracks) == 0 {
+		response.StatusCode = rtsp.StatusMethodNotValidInThisState
+		response.StatusText = rtsp.StatusText(rtsp.StatusMethodNotValidInThisState)
+		return response, nil
+	}
 
 	// Start streaming if not already running
-	if !session.StreamRunning {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if !session.StreamRunning && m != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		session.cancel, session.done = cancel, done
 		session.StreamRunning = true
-
-		// Extract video filename from URI
-		videoFile := request.URI
-		if videoFile == "/" {
-			videoFile = "test"
-		} else {
-			// Remove leading slash
-			videoFile = videoFile[1:]
-		}
-
-		// Get server instance to access VideoDir
-		// server := &Server{}
-		// Note: In a real implementation, we would need to pass the server instance to the handler
-		// For simplicity, we'll use a relative path
-		videoPath := filepath.Join(".", "video", videoFile+".mp4")
-
-		// Check if video file exists
-		if _, err := os.Stat(videoPath); os.IsNotExist(err) {
-			// Try without extension
-			videoPath = filepath.Join(".", "video", videoFile)
-			if _, err := os.Stat(videoPath); os.IsNotExist(err) {
-				response.StatusCode = rtsp.StatusNotFound
-				response.StatusText = rtsp.StatusText(rtsp.StatusNotFound)
-				return response, nil
+		go func() {
+			defer close(done)
+			if err := session.relay(ctx, m, tracks); err != nil {
+				fmt.Printf("Failed to relay %s: %v\n", m.Path, err)
 			}
+		}()
+	} else if !session.StreamRunning {
+		f, err := mp4.Open(session.VideoPath)
+		if err != nil {
+			return nil, err
 		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		session.cancel, session.done = cancel, done
+		session.StreamRunning = true
 
-		session.VideoPath = videoPath
-
-		// Start streaming goroutine
+		videoPath := session.VideoPath
 		go func() {
-			// For demonstration purposes, we'll simulate H.264 video streaming
+			defer close(done)
+			defer f.Close()
 			fmt.Printf("Starting to stream video: %s\n", videoPath)
-
-			// Parse client address and port from transport header
-			clientAddr := session.Conn.RemoteAddr().(*net.TCPAddr).IP.String()
-			clientPort := ""
-			parts := strings.Split(session.Transport, ";")
-			for _, part := range parts {
-				if strings.HasPrefix(part, "client_port=") {
-					clientPort = strings.TrimPrefix(part, "client_port=")
-					break
-				}
+			if err := session.playFile(ctx, f, tracks); err != nil {
+				fmt.Printf("Failed to stream video %s: %v\n", videoPath, err)
 			}
-
-			// Parse client ports
-			portParts := strings.Split(clientPort, "-")
-			if len(portParts) != 2 {
-				fmt.Printf("Invalid client port format: %s\n", clientPort)
-				session.StreamRunning = false
-				return
-			}
-
-			// Create UDP connection for RTP packets
-			rtpAddr, err := net.ResolveUDPAddr("udp", clientAddr+":"+portParts[0])
-			if err != nil {
-				fmt.Printf("Failed to resolve UDP address: %v\n", err)
-				session.StreamRunning = false
-				return
-			}
-
-			udpConn, err := net.DialUDP("udp", nil, rtpAddr)
-			if err != nil {
-				fmt.Printf("Failed to create UDP connection: %v\n", err)
-				session.StreamRunning = false
-				return
-			}
-			defer udpConn.Close()
-
-			// Simulate video streaming
-			sequenceNum := uint16(0)
-			timestamp := uint32(0)
-			ssrc := uint32(time.Now().UnixNano() % 0xffffffff)
-
-			// Simulate 30 frames per second
-			frameInterval := time.Second / 30
-
-			// Stream for 30 seconds
-			for i := 0; i < 900 && session.StreamRunning; i++ {
-				// Create a dummy H.264 NALU
-				// In a real implementation, we would decode the video file to get actual H.264 data
-				naluType := byte(1) // Non-key frame
-				if i%30 == 0 {
-					naluType = byte(5) // Key frame every 30 frames
-				}
-
-				// Create dummy NALU data
-				naluData := make([]byte, 1000)
-				naluData[0] = naluType
-				for j := 1; j < len(naluData); j++ {
-					naluData[j] = byte(j % 256)
-				}
-
-				// Create RTP packet
-				rtpPacket := createRTPPacket(naluData, sequenceNum, timestamp, ssrc, naluType == 5)
-
-				// Send RTP packet to client
-				n, err := udpConn.Write(rtpPacket)
-				if err != nil {
-					fmt.Printf("Failed to send RTP packet: %v\n", err)
-					continue
-				}
-
-				fmt.Printf("Sent RTP packet: seq=%d, timestamp=%d, size=%d, bytes_sent=%d\n", sequenceNum, timestamp, len(rtpPacket), n)
-
-				// Increment sequence number and timestamp
-				sequenceNum++
-				timestamp += 3000 // 90000 Hz / 30 fps = 3000
-
-				// Sleep for frame interval
-				time.Sleep(frameInterval)
-			}
-
-			// Stop streaming
-			session.StreamRunning = false
 			fmt.Printf("Stopped streaming video: %s\n", videoPath)
 		}()
 	}
 
-	return response, nil
-}
-
-// createRTPPacket creates an RTP packet from H.264 NALU data
-func createRTPPacket(naluData []byte, sequenceNum uint16, timestamp uint32, ssrc uint32, isKeyFrame bool) []byte {
-	// RTP header size
-	headerSize := 12
-
-	// Total packet size
-	totalSize := headerSize + len(naluData)
-
-	// Create RTP packet
-	rtpPacket := make([]byte, totalSize)
-
-	// Set RTP header
-	rtpPacket[0] = 0x80 // Version 2, padding 0, extension 0, CSRC count 0
-	rtpPacket[1] = 96   // Payload type 96 (H.264)
-	if isKeyFrame {
-		rtpPacket[1] |= 0x80 // Set marker bit for key frames
+	info := []string{}
+	for _, track := range tracks {
+		info = append(info, fmt.Sprintf("url=%s;seq=%d;rtptime=%d",
+			rtsp.ControlURL(strings.TrimSuffix(request.URI, "/"), trackControl+strconv.Itoa(track.ID)),
+			track.packetizer.SequenceNumber, track.timestampBase))
 	}
-
-	// Set sequence number (big-endian)
-	rtpPacket[2] = byte(sequenceNum >> 8)
-	rtpPacket[3] = byte(sequenceNum)
-
-	// Set timestamp (big-endian)
-	rtpPacket[4] = byte(timestamp >> 24)
-	rtpPacket[5] = byte(timestamp >> 16)
-	rtpPacket[6] = byte(timestamp >> 8)
-	rtpPacket[7] = byte(timestamp)
-
-	// Set SSRC (big-endian)
-	rtpPacket[8] = byte(ssrc >> 24)
-	rtpPacket[9] = byte(ssrc >> 16)
-	rtpPacket[10] = byte(ssrc >> 8)
-	rtpPacket[11] = byte(ssrc)
-
-	// Copy NALU data
-	copy(rtpPacket[headerSize:], naluData)
-
-	return rtpPacket
+	response.Header.Set("RTP-Info", strings.Join(info, ","))
+	return response, nil
 }
 
 // HandlePAUSE handles PAUSE requests
@@ -324,8 +229,7 @@ func (h *DefaultHandler) HandlePAUSE(session *Session, request *rtsp.Request) (*
 	response.Header.Set("Session", session.ID)
 
 	// Pause streaming
-	if session.StreamRunning {
-		session.StreamRunning = false
+	if session.stopStream() {
 		fmt.Printf("Paused streaming for session: %s\n", session.ID)
 	}
 
@@ -342,20 +246,19 @@ func (h *DefaultHandler) HandleTEARDOWN(session *Session, request *rtsp.Request)
 	}
 
 	// Stop streaming and clean up resources
-	if session.StreamRunning {
-		session.StreamRunning = false
+	if session.stopStream() {
 		fmt.Printf("Stopped streaming for session: %s\n", session.ID)
 	}
 
+	// Remove the mount of a publisher
+	session.unpublish()
+
 	// Close RTP/RTCP connections if they exist
-	if session.RTPConn != nil {
-		session.RTPConn.Close()
-		session.RTPConn = nil
-	}
-	if session.RTCPConn != nil {
-		session.RTCPConn.Close()
-		session.RTCPConn = nil
-	}
+	session.closeTracks()
+	session.mu.Lock()
+	session.Mount = nil
+	session.mu.Unlock()
+	session.VideoPath = ""
 
 	// Stop streamer if it exists
 	if session.Streamer != nil {
@@ -366,7 +269,7 @@ func (h *DefaultHandler) HandleTEARDOWN(session *Session, request *rtsp.Request)
 	return response, nil
 }
 
-// HandleANNOUNCE handles ANNOUNCE requests
+// HandleANNOUNCE handles ANNOUNCE requests, publishing a mount with the SDP at the path of the URI
 func (h *DefaultHandler) HandleANNOUNCE(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
 	response := &rtsp.Response{
 		Version:    "RTSP/1.0",
@@ -374,10 +277,33 @@ func (h *DefaultHandler) HandleANNOUNCE(session *Session, request *rtsp.Request)
 		StatusText: rtsp.StatusText(rtsp.StatusOK),
 		Header:     make(rtsp.Header),
 	}
+	fail := func(status rtsp.StatusCode) (*rtsp.Response, error) {
+		response.StatusCode = status
+		response.StatusText = rtsp.StatusText(status)
+		return response, nil
+	}
+
+	if contentType := request.Header.Get("Content-Type"); contentType != "" && contentType != "application/sdp" {
+		return fail(rtsp.StatusUnsupportedMediaType)
+	}
+	sdp, err := rtsp.ParseSDP(request.Body)
+	if err != nil || len(sdp.Medias) == 0 {
+		return fail(rtsp.StatusBadRequest)
+	}
+	if session.StreamRunning || len(session.setupTracks()) > 0 {
+		return fail(rtsp.StatusMethodNotValidInThisState)
+	}
+
+	// a session publishes one mount
+	session.unpublish()
+	if _, ok := session.announce(request.URI, sdp); !ok {
+		return fail(rtsp.StatusForbidden)
+	}
+	response.Header.Set("Session", session.ID)
 	return response, nil
 }
 
-// HandleRECORD handles RECORD requests
+// HandleRECORD handles RECORD requests, starting to relay the packets of the publisher
 func (h *DefaultHandler) HandleRECORD(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
 	response := &rtsp.Response{
 		Version:    "RTSP/1.0",
@@ -386,6 +312,16 @@ func (h *DefaultHandler) HandleRECORD(session *Session, request *rtsp.Request) (
 		Header:     make(rtsp.Header),
 	}
 	response.Header.Set("Session", session.ID)
+
+	m := session.publishedMount()
+	if m == nil || len(session.setupTracks()) == 0 {
+		response.StatusCode = rtsp.StatusMethodNotValidInThisState
+		response.StatusText = rtsp.StatusText(rtsp.StatusMethodNotValidInThisState)
+		return response, nil
+	}
+	m.mu.Lock()
+	m.recording = true
+	m.mu.Unlock()
 	return response, nil
 }
 
@@ -439,6 +375,29 @@ type Session struct {
 	SequenceNum   uint16
 	Timestamp     uint32
 	SSRC          uint32
+	// Server is the server which accepted the connection
+	Server *Server
+	// Tracks are the tracks set up by SETUP by track ID
+	Tracks map[int]*SessionTrack
+	// Mount is the mount published by the session when Publishing, or the mount it plays
+	Mount      *Mount
+	Publishing bool
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	// writeMu serializes responses and interleaved frames on Conn
+	writeMu sync.Mutex
+	// nonce is the Digest nonce issued to the connection by PasswordAuthenticator
+	nonce string
+}
+
+// write writes data to the RTSP connection
+func (s *Session) write(data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.Conn.Write(data)
+	return err
 }
 
 // Server represents an RTSP server
@@ -446,7 +405,11 @@ type Server struct {
 	addr     string
 	listener net.Listener
 	handler  Handler
-	sessions map[string]*Session
+	// authenticator checks requests before the handler, nil to accept all
+	authenticator Authenticator
+	sessions      map[string]*Session
+	// mounts are the paths published with ANNOUNCE
+	mounts map[string]*Mount
 	sync.Mutex
 	running bool
 	// Video directory
@@ -459,6 +422,7 @@ func NewServer(addr string) *Server {
 		addr:     addr,
 		handler:  &DefaultHandler{},
 		sessions: make(map[string]*Session),
+		mounts:   make(map[string]*Mount),
 		VideoDir: ".", // Default to current directory
 	}
 }
@@ -473,6 +437,11 @@ func (s *Server) SetHandler(handler Handler) {
 	s.handler = handler
 }
 
+// SetAuthenticator sets the authenticator of requests, nil to accept all
+func (s *Server) SetAuthenticator(authenticator Authenticator) {
+	s.authenticator = authenticator
+}
+
 // Start starts the server
 func (s *Server) Start() error {
 	listener, err := net.Listen("tcp", s.addr)
@@ -480,15 +449,27 @@ func (s *Server) Start() error {
 		return err
 	}
 	s.listener = listener
+	s.Lock()
 	s.running = true
+	s.Unlock()
 
-	go s.acceptLoop()
+	go s.acceptLoop(listener)
 	return nil
 }
 
+// Addr returns the address the server listens on, nil before Start
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
 // Stop stops the server
 func (s *Server) Stop() error {
+	s.Lock()
 	s.running = false
+	s.Unlock()
 	if s.listener != nil {
 		return s.listener.Close()
 	}
@@ -496,9 +477,12 @@ func (s *Server) Stop() error {
 }
 
 // acceptLoop accepts incoming connections
-func (s *Server) acceptLoop() {
-	for s.running {
-		conn, err := s.listener.Accept()
+func (s *Server) acceptLoop(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			continue
 		}
@@ -526,6 +510,7 @@ func (s *Server) handleConnection(conn net.Conn) {
 		SequenceNum:   0,
 		Timestamp:     0,
 		SSRC:          uint32(time.Now().UnixNano() % 0xffffffff),
+		Server:        s,
 	}
 
 	// Add session
@@ -534,18 +519,13 @@ func (s *Server) handleConnection(conn net.Conn) {
 	s.Unlock()
 
 	defer func() {
-		// Stop streaming if running
-		if session.StreamRunning {
-			session.StreamRunning = false
-			if session.RTPConn != nil {
-				session.RTPConn.Close()
-			}
-			if session.RTCPConn != nil {
-				session.RTCPConn.Close()
-			}
-			if session.Streamer != nil {
-				session.Streamer.Stop()
-			}
+		// Stop streaming if running, closing the connection first unblocks interleaved writes
+		conn.Close()
+		session.stopStream()
+		session.unpublish()
+		session.closeTracks()
+		if session.Streamer != nil {
+			session.Streamer.Stop()
 		}
 		// Remove session
 		s.Lock()
@@ -554,6 +534,21 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}()
 
 	for {
+		// Interleaved frames share the connection with requests, RTP of publishers is relayed
+		// and RTCP of players is consumed here
+		isFrame, err := rtsp.IsInterleavedFrame(reader)
+		if err != nil {
+			break
+		}
+		if isFrame {
+			frame, err := rtsp.ReadInterleavedFrame(reader)
+			if err != nil {
+				break
+			}
+			session.handleFrame(frame)
+			continue
+		}
+
 		// Parse request
 		request, err := rtsp.ParseRequest(reader)
 		if err != nil {
@@ -589,8 +584,7 @@ func (s *Server) handleConnection(conn net.Conn) {
 			break
 		}
 
-		_, err = conn.Write(data)
-		if err != nil {
+		if err := session.write(data); err != nil {
 			break
 		}
 	}
@@ -598,6 +592,12 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 // handleRequest handles an RTSP request
 func (s *Server) handleRequest(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
+	if s.authenticator != nil {
+		if response := s.authenticator.Authenticate(session, request); response != nil {
+			return response, nil
+		}
+	}
+
 	switch request.Method {
 	case rtsp.MethodOPTIONS:
 		return s.handler.HandleOPTIONS(session, request)
@@ -0,0 +1,504 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/client"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+	"github.com/wwqdrh/gokit/media/rtsp/stream"
+)
+
+func startTestServer(t *testing.T) *Server {
+	s := NewServer("127.0.0.1:0")
+	s.SetVideoDir("../mp4/testdata")
+	if err := s.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+func TestSplitURI(t *testing.T) {
+	tests := []struct {
+		URI   string
+		Name  string
+		Track int
+	}{
+		{"rtsp://localhost:554/sample", "sample", -1},
+		{"rtsp://localhost:554/dir/sample/trackID=1", "dir/sample", 1},
+		{"/sample/", "sample", -1},
+		{"/", "test", -1},
+	}
+	for _, tt := range tests {
+		name, track := splitURI(tt.URI)
+		if name != tt.Name || track != tt.Track {
+			t.Errorf("%s: expected %s %d, got %s %d", tt.URI, tt.Name, tt.Track, name, track)
+		}
+	}
+	if _, ok := resolveVideo("../mp4/testdata", "../../server/server_test.go"); ok {
+		t.Error("Expected paths outside the video directory to be rejected")
+	}
+}
+
+func TestPlayFile(t *testing.T) {
+	s := startTestServer(t)
+	c := client.NewClient()
+	if err := c.Connect(s.Addr().String()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer c.Close()
+	uri := fmt.Sprintf("rtsp://%s/sample", s.Addr())
+
+	response, err := c.Describe(fmt.Sprintf("rtsp://%s/missing", s.Addr()))
+	if err != nil || response.StatusCode != rtsp.StatusNotFound {
+		t.Fatalf("Expected 404 for a missing file, got %v %v", response, err)
+	}
+
+	response, err = c.Describe(uri)
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("DESCRIBE failed: %v %v", response, err)
+	}
+	sdp, err := rtsp.ParseSDP(response.Body)
+	if err != nil {
+		t.Fatalf("Failed to parse SDP: %v", err)
+	}
+	if len(sdp.Medias) != 2 {
+		t.Fatalf("Expected 2 medias, got %d", len(sdp.Medias))
+	}
+	video, audio := sdp.Medias[0], sdp.Medias[1]
+	sps := base64.StdEncoding.EncodeToString([]byte{0x67, 0x64, 0x00, 0x1f, 0xac, 0xd9, 0x40, 0x50, 0x05, 0xbb, 0x01, 0x10})
+	if video.Encoding != "H264" || video.ClockRate != 90000 || video.FmtpParams()["profile-level-id"] != "64001F" ||
+		!strings.HasPrefix(video.FmtpParams()["sprop-parameter-sets"], sps+",") {
+		t.Errorf("Unexpected video media %+v", video)
+	}
+	if audio.Type != "audio" || audio.Encoding != "MPEG4-GENERIC" || audio.ClockRate != 44100 || audio.Channels != 2 ||
+		audio.FmtpParams()["config"] != "1210" {
+		t.Errorf("Unexpected audio media %+v", audio)
+	}
+
+	rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtpConn.Close()
+	rtcpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtcpConn.Close()
+	transport := fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", rtpConn.LocalAddr().(*net.UDPAddr).Port, rtcpConn.LocalAddr().(*net.UDPAddr).Port)
+	response, err = c.Setup(controlURL(uri, response.Header.Get("Content-Base"), video.Control), transport)
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("SETUP failed: %v %v", response, err)
+	}
+	if tr, err := rtsp.ParseTransport(response.Header.Get("Transport")); err != nil || tr.ServerPort[0] == 0 || tr.SSRC == "" {
+		t.Errorf("Unexpected transport %s", response.Header.Get("Transport"))
+	}
+
+	response, err = c.Play(uri)
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("PLAY failed: %v %v", response, err)
+	}
+	if !strings.Contains(response.Header.Get("RTP-Info"), uri+"/trackID=0;seq=") {
+		t.Errorf("Unexpected RTP-Info %s", response.Header.Get("RTP-Info"))
+	}
+
+	// the file lasts 464ms, read until the second keyframe of the second loop
+	packets := []*rtp.Packet{}
+	keyframes := 0
+	buf := make([]byte, 2048)
+	rtpConn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for keyframes < 4 {
+		n, err := rtpConn.Read(buf)
+		if err != nil {
+			t.Fatalf("Failed to read RTP after %d packets: %v", len(packets), err)
+		}
+		p, err := rtp.Unmarshal(append([]byte{}, buf[:n]...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Payload[0]&0x1f == 7 {
+			keyframes++
+		}
+		packets = append(packets, p)
+	}
+
+	// sample 0: SPS, PPS and 3 FU-A fragments of the IDR
+	first := packets[:5]
+	if first[2].Payload[0]&0x1f != rtp.H264NALUTypeFUA || first[2].Payload[1] != 0x85 || !first[4].Marker || first[3].Marker {
+		t.Errorf("Unexpected packets of the first keyframe")
+	}
+	for i, p := range packets {
+		if p.PayloadType != 96 {
+			t.Errorf("Packet %d: unexpected payload type %d", i, p.PayloadType)
+		}
+		if i == 0 {
+			continue
+		}
+		if p.SequenceNumber != packets[i-1].SequenceNumber+1 {
+			t.Errorf("Packet %d: sequence number %d after %d", i, p.SequenceNumber, packets[i-1].SequenceNumber)
+		}
+		if int32(p.Timestamp-packets[i-1].Timestamp) < 0 {
+			t.Errorf("Packet %d: timestamp %d goes back from %d", i, p.Timestamp, packets[i-1].Timestamp)
+		}
+	}
+	// sample 2 is 512 ticks of 12800 after samples 0 and 1, which have the same PTS
+	if d := packets[7].Timestamp - packets[0].Timestamp; d != 3600 {
+		t.Errorf("Expected timestamp difference 3600, got %d", d)
+	}
+
+	response, err = c.Teardown(uri)
+	if err != nil || response.StatusCode != rtsp.StatusOK {
+		t.Fatalf("TEARDOWN failed: %v %v", response, err)
+	}
+}
+
+func TestPullTransports(t *testing.T) {
+	s := startTestServer(t)
+	for _, transport := range []string{stream.TransportUDP, stream.TransportTCP, stream.TransportAuto} {
+		t.Run(transport, func(t *testing.T) {
+			rs := stream.NewRTSPStream(stream.RTSPConfig{URL: fmt.Sprintf("rtsp://%s/sample", s.Addr()), Transport: transport})
+			if err := rs.Start(); err != nil {
+				t.Fatalf("Failed to start: %v", err)
+			}
+			defer rs.Stop()
+
+			counts := map[int]int{}
+			payloadTypes := map[int]uint8{}
+			timeout := time.After(3 * time.Second)
+			for counts[0] < 20 || counts[1] < 5 {
+				select {
+				case p := <-rs.GetPacketChan():
+					counts[p.Track]++
+					payloadTypes[p.Track] = p.PayloadType
+				case <-timeout:
+					t.Fatalf("Timed out with packets %v", counts)
+				}
+			}
+			if payloadTypes[0] != 96 || payloadTypes[1] != 97 {
+				t.Errorf("Unexpected payload types %v", payloadTypes)
+			}
+			if tcp := strings.HasPrefix(rs.GetTransport(), "RTP/AVP/TCP"); tcp != (transport == stream.TransportTCP) {
+				t.Errorf("Unexpected transport %s", rs.GetTransport())
+			}
+			if stats := rs.GetStreamInfo().RTPStats; len(stats) != 2 || stats[0].Received < 20 || stats[0].Lost != 0 || stats[1].Dropped != 0 {
+				t.Errorf("Unexpected stats %+v", stats)
+			}
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	s := startTestServer(t)
+	auth := NewPasswordAuthenticator("camera", func(username string, method rtsp.Method, uri string) (string, bool) {
+		// viewer may only describe
+		if username == "viewer" && method == rtsp.MethodDESCRIBE {
+			return "view", true
+		}
+		return "secret", username == "admin"
+	})
+	auth.Public = func(method rtsp.Method, uri string) bool { return method == rtsp.MethodOPTIONS }
+	s.SetAuthenticator(auth)
+	uri := fmt.Sprintf("rtsp://%s/sample", s.Addr())
+
+	tests := []struct {
+		Name       string
+		Username   string
+		Password   string
+		Algorithms []string
+		Method     rtsp.Method
+		Status     rtsp.StatusCode
+	}{
+		{"no credentials", "", "", nil, rtsp.MethodDESCRIBE, rtsp.StatusUnauthorized},
+		{"public", "", "", nil, rtsp.MethodOPTIONS, rtsp.StatusOK},
+		{"sha-256", "admin", "secret", nil, rtsp.MethodDESCRIBE, rtsp.StatusOK},
+		{"md5", "admin", "secret", []string{rtsp.AlgorithmMD5}, rtsp.MethodDESCRIBE, rtsp.StatusOK},
+		{"wrong password", "admin", "guess", nil, rtsp.MethodDESCRIBE, rtsp.StatusUnauthorized},
+		{"per method", "viewer", "view", nil, rtsp.MethodDESCRIBE, rtsp.StatusOK},
+		{"method refused", "viewer", "view", nil, rtsp.MethodGET_PARAMETER, rtsp.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		auth.Algorithms = tt.Algorithms
+		c := client.NewClient()
+		if err := c.Connect(s.Addr().String()); err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		c.SetCredentials(tt.Username, tt.Password)
+		response, err := c.SendRequest(tt.Method, uri, nil, nil)
+		c.Close()
+		if err != nil || response.StatusCode != tt.Status {
+			t.Errorf("%s: expected %d, got %v %v", tt.Name, tt.Status, response, err)
+			continue
+		}
+		if tt.Status == rtsp.StatusUnauthorized && len(response.Header.Values("WWW-Authenticate")) == 0 {
+			t.Errorf("%s: expected challenges", tt.Name)
+		}
+	}
+
+	// a connection is challenged with the same nonce until it expires
+	c := client.NewClient()
+	if err := c.Connect(s.Addr().String()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	nonces := []string{}
+	for i := 0; i < 2; i++ {
+		response, err := c.SendRequest(rtsp.MethodDESCRIBE, uri, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		challenge, err := rtsp.ParseChallenge(response.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			t.Fatalf("Failed to parse challenge: %v", err)
+		}
+		nonces = append(nonces, challenge.Nonce)
+	}
+	c.Close()
+	if nonces[0] != nonces[1] {
+		t.Errorf("Expected one nonce per connection, got %v", nonces)
+	}
+	if fresh, known := auth.checkNonce(nonces[0]); !fresh || !known {
+		t.Errorf("Expected a fresh nonce, got %v %v", fresh, known)
+	}
+	forged := []byte(nonces[0])
+	forged[len(forged)-1] ^= 1
+	if _, known := auth.checkNonce(string(forged)); known {
+		t.Error("Expected a forged nonce to be unknown")
+	}
+	auth.NonceTimeout = time.Nanosecond
+	if fresh, known := auth.checkNonce(nonces[0]); fresh || !known {
+		t.Errorf("Expected a stale nonce, got %v %v", fresh, known)
+	}
+	auth.NonceTimeout = 0
+
+	// Basic is accepted only when enabled
+	basic := rtsp.Header{}
+	basic.Set("Authorization", (&rtsp.Authorization{Scheme: rtsp.AuthBasic, Username: "admin", Password: "secret"}).String())
+	for _, enabled := range []bool{false, true} {
+		auth.Algorithms, auth.Basic = nil, enabled
+		c := client.NewClient()
+		if err := c.Connect(s.Addr().String()); err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		response, err := c.SendRequest(rtsp.MethodDESCRIBE, uri, basic, nil)
+		c.Close()
+		if err != nil || (response.StatusCode == rtsp.StatusOK) != enabled {
+			t.Errorf("Basic enabled %v: unexpected response %v %v", enabled, response, err)
+		}
+	}
+
+	// credentials in the URL
+	auth.Basic = false
+	rs := stream.NewRTSPStream(stream.RTSPConfig{URL: fmt.Sprintf("rtsp://admin:secret@%s/sample", s.Addr()), Transport: stream.TransportTCP})
+	if err := rs.Start(); err != nil {
+		t.Fatalf("Failed to start with credentials: %v", err)
+	}
+	defer rs.Stop()
+	select {
+	case <-rs.GetPacketChan():
+	case <-time.After(3 * time.Second):
+		t.Fatal("Timed out waiting for a packet")
+	}
+}
+
+// controlURL resolves the control URL of a media
+func controlURL(uri, base, control string) string {
+	if base == "" {
+		base = uri
+	}
+	return rtsp.ControlURL(base, control)
+}
+
+// keepaliveHandler gives sessions a timeout of 1s and loses the session at the second GET_PARAMETER
+type keepaliveHandler struct {
+	*DefaultHandler
+	mu         sync.Mutex
+	keepalives int
+}
+
+func (h *keepaliveHandler) HandleSETUP(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
+	response, err := h.DefaultHandler.HandleSETUP(session, request)
+	if err == nil && response.Header.Get("Session") != "" {
+		response.Header.Set("Session", session.ID+";timeout=1")
+	}
+	return response, err
+}
+
+func (h *keepaliveHandler) HandleGET_PARAMETER(session *Session, request *rtsp.Request) (*rtsp.Response, error) {
+	h.mu.Lock()
+	h.keepalives++
+	lost := h.keepalives == 2
+	h.mu.Unlock()
+	response, err := h.DefaultHandler.HandleGET_PARAMETER(session, request)
+	if lost {
+		response.StatusCode = rtsp.StatusSessionNotFound
+		response.StatusText = rtsp.StatusText(rtsp.StatusSessionNotFound)
+	}
+	return response, err
+}
+
+// closeSessions closes the connections of the server like a reboot of a camera
+func closeSessions(s *Server) {
+	s.Stop()
+	s.Lock()
+	for _, session := range s.sessions {
+		session.Conn.Close()
+	}
+	s.Unlock()
+}
+
+func TestReconnect(t *testing.T) {
+	s := startTestServer(t)
+	handler := &keepaliveHandler{DefaultHandler: &DefaultHandler{}}
+	s.SetHandler(handler)
+	addr := s.Addr().String()
+
+	events := make(chan stream.StateEvent, 100)
+	rs := stream.NewRTSPStream(stream.RTSPConfig{
+		URL:           fmt.Sprintf("rtsp://%s/sample", addr),
+		Transport:     stream.TransportTCP,
+		RetryInterval: 100 * time.Millisecond,
+		MaxRetries:    3,
+		StallTimeout:  500 * time.Millisecond,
+		OnStateChange: func(event stream.StateEvent) { events <- event },
+	})
+	if err := rs.Start(); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer rs.Stop()
+
+	expect := func(state stream.StreamState, attempt int) stream.StateEvent {
+		t.Helper()
+		for {
+			select {
+			case event := <-events:
+				if event.State == state && event.Attempt == attempt {
+					return event
+				}
+				if event.State == stream.StateFailed || event.State == stream.StatePlaying && state != stream.StatePlaying {
+					t.Fatalf("Unexpected event %+v waiting for %s", event, state)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for %s", state)
+			}
+		}
+	}
+	// the packets continue on the same channel after the reconnections
+	receive := func() {
+		t.Helper()
+		timeout := time.After(3 * time.Second)
+		for count := 0; count < 10; {
+			select {
+			case _, ok := <-rs.GetPacketChan():
+				if !ok {
+					t.Fatal("Packet channel closed")
+				}
+				count++
+			case <-timeout:
+				t.Fatal("Timed out waiting for packets")
+			}
+		}
+	}
+	expect(stream.StateConnecting, 0)
+	expect(stream.StatePlaying, 0)
+	receive()
+
+	// the keepalives are sent within the session timeout, the server loses the session at the second one
+	if event := expect(stream.StateReconnecting, 0); event.Err == nil || !strings.Contains(event.Err.Error(), "keepalive failed: 454") {
+		t.Errorf("Unexpected cause %v", event.Err)
+	}
+	expect(stream.StatePlaying, 0)
+	receive()
+
+	// a reboot stalls the stream, the server is back after a failed attempt
+	closeSessions(s)
+	if event := expect(stream.StateReconnecting, 0); event.Err == nil {
+		t.Error("Expected the cause of the reconnection")
+	}
+	expect(stream.StateReconnecting, 1)
+	restarted := NewServer(addr)
+	restarted.SetVideoDir("../mp4/testdata")
+	if err := restarted.Start(); err != nil {
+		t.Fatalf("Failed to restart the server: %v", err)
+	}
+	expect(stream.StatePlaying, 0)
+	receive()
+	if info := rs.GetStreamInfo(); info.State != stream.StatePlaying || info.Reconnects != 2 {
+		t.Errorf("Unexpected state %s after %d reconnections", info.State, info.Reconnects)
+	}
+
+	// the stream fails after MaxRetries attempts and closes the packet channel
+	closeSessions(restarted)
+	expect(stream.StateReconnecting, 0)
+	expect(stream.StateReconnecting, 1)
+	expect(stream.StateReconnecting, 2)
+	if event := expect(stream.StateFailed, 0); event.Err == nil {
+		t.Error("Expected the cause of the failure")
+	}
+	for range rs.GetPacketChan() {
+	}
+	if rs.IsRunning() {
+		t.Error("Expected the stream to be stopped")
+	}
+}
+
+func TestStopWhileConnecting(t *testing.T) {
+	// a server accepting the connection without answering
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	rs := stream.NewRTSPStream(stream.RTSPConfig{URL: fmt.Sprintf("rtsp://%s/sample", listener.Addr())})
+	started := make(chan error, 1)
+	go func() { started <- rs.Start() }()
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the connection")
+	}
+	defer conn.Close()
+
+	// the lock of the stream isn't held by the attempt waiting for OPTIONS
+	done := make(chan struct{})
+	go func() {
+		if info := rs.GetStreamInfo(); info.State != stream.StateConnecting {
+			t.Errorf("Unexpected state %s", info.State)
+		}
+		rs.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for the connection attempt")
+	}
+
+	// the attempt fails once the connection is closed and Start gives up without retrying
+	conn.Close()
+	select {
+	case err := <-started:
+		if err == nil {
+			t.Error("Expected Start to fail once stopped")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Start")
+	}
+	if rs.IsRunning() {
+		t.Error("Expected the stream to be stopped")
+	}
+}
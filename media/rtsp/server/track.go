@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+// SessionTrack is a track set up by SETUP
+type SessionTrack struct {
+	ID        int
+	Transport *rtsp.Transport
+	// RTPConn and RTCPConn are nil when RTP is interleaved
+	RTPConn  net.Conn
+	RTCPConn net.Conn
+
+	session       *Session
+	packetizer    rtp.Packetizer
+	timestampBase uint32
+	// recording tracks receive the RTP of a publisher
+	recording bool
+}
+
+// writeRTP sends an RTP packet over UDP or on the RTP channel of the RTSP connection
+func (t *SessionTrack) writeRTP(data []byte) error {
+	if t.Transport.IsTCP() {
+		frame := &rtsp.InterleavedFrame{Channel: uint8(t.Transport.Interleaved[0]), Payload: data}
+		return t.session.write(frame.Marshal())
+	}
+	_, err := t.RTPConn.Write(data)
+	return err
+}
+
+func (t *SessionTrack) close() {
+	if t.RTPConn != nil {
+		t.RTPConn.Close()
+	}
+	if t.RTCPConn != nil {
+		t.RTCPConn.Close()
+	}
+}
+
+// setupTrack sets up a track with the transport chosen by the client
+func (s *Session) setupTrack(id int, transport *rtsp.Transport) (*SessionTrack, error) {
+	ssrc := s.SSRC + uint32(id)
+	track := &SessionTrack{
+		ID:      id,
+		session: s,
+		packetizer: rtp.Packetizer{
+			PayloadType:    uint8(96 + id),
+			SSRC:           ssrc,
+			SequenceNumber: uint16(ssrc >> 8),
+		},
+		// random initial timestamp (RFC 3550 5.1)
+		timestampBase: ssrc * 2654435761,
+	}
+	if transport.IsTCP() {
+		channels := transport.Interleaved
+		if channels[0] < 0 {
+			channels = [2]int{2 * id, 2*id + 1}
+		}
+		track.Transport = &rtsp.Transport{Protocol: transport.Protocol, Interleaved: channels, SSRC: fmt.Sprintf("%08X", ssrc)}
+	} else if err := track.dialUDP(transport); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Tracks == nil {
+		s.Tracks = map[int]*SessionTrack{}
+	}
+	if old := s.Tracks[id]; old != nil {
+		old.close()
+	}
+	s.Tracks[id] = track
+	if track.RTPConn != nil {
+		s.RTPConn, s.RTCPConn = track.RTPConn, track.RTCPConn
+	}
+	return track, nil
+}
+
+// dialUDP creates the connections to the client ports of the transport
+func (t *SessionTrack) dialUDP(transport *rtsp.Transport) error {
+	clientAddr := t.session.Conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	rtpConn, err := net.Dial("udp", net.JoinHostPort(clientAddr, strconv.Itoa(transport.ClientPort[0])))
+	if err != nil {
+		return err
+	}
+	rtcpConn, err := net.Dial("udp", net.JoinHostPort(clientAddr, strconv.Itoa(transport.ClientPort[1])))
+	if err != nil {
+		rtpConn.Close()
+		return err
+	}
+	t.RTPConn, t.RTCPConn = rtpConn, rtcpConn
+	t.Transport = &rtsp.Transport{
+		Protocol:   transport.Protocol,
+		ClientPort: transport.ClientPort,
+		ServerPort: [2]int{rtpConn.LocalAddr().(*net.UDPAddr).Port, rtcpConn.LocalAddr().(*net.UDPAddr).Port},
+		SSRC:       fmt.Sprintf("%08X", t.packetizer.SSRC),
+	}
+	return nil
+}
+
+// setupTracks returns the tracks set up in the session ordered by ID
+func (s *Session) setupTracks() []*SessionTrack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*SessionTrack, 0, len(s.Tracks))
+	for _, track := range s.Tracks {
+		res = append(res, track)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// stopStream stops streaming and waits for the streaming goroutine, returning whether it was running
+func (s *Session) stopStream() bool {
+	s.mu.Lock()
+	running, cancel, done := s.StreamRunning, s.cancel, s.done
+	s.StreamRunning, s.cancel, s.done = false, nil, nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return running
+}
+
+// closeTracks closes the connections of the tracks
+func (s *Session) closeTracks() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, track := range s.Tracks {
+		track.close()
+	}
+	s.Tracks = nil
+	s.RTPConn, s.RTCPConn = nil, nil
+}
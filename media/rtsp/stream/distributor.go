@@ -2,15 +2,23 @@ package stream
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp/webrtc"
 )
 
+// maxOfferSize limits the SDP offers of the WebRTC clients
+const maxOfferSize = 64 << 10
+
 // StreamDistributor implements Distributor interface
 type StreamDistributor struct {
 	addr        string
@@ -22,17 +30,22 @@ type StreamDistributor struct {
 	cancel      context.CancelFunc
 	hlsDir      string
 	clientCount int
+	// sessions are the WebRTC sessions by ID, sharing a certificate
+	sessions    map[string]*webrtc.Session
+	certificate *webrtc.Certificate
+	webrtcIP    net.IP
 }
 
 // NewStreamDistributor creates a new stream distributor
 func NewStreamDistributor(addr string) *StreamDistributor {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &StreamDistributor{
-		addr:    addr,
-		streams: make(map[string]Streamer),
-		ctx:     ctx,
-		cancel:  cancel,
-		hlsDir:  "/tmp/hls",
+		addr:     addr,
+		streams:  make(map[string]Streamer),
+		sessions: make(map[string]*webrtc.Session),
+		ctx:      ctx,
+		cancel:   cancel,
+		hlsDir:   "/tmp/hls",
 	}
 }
 
@@ -51,6 +64,7 @@ func (d *StreamDistributor) Start() error {
 	handler.HandleFunc("/hls/", d.hlsHandler)
 	handler.HandleFunc("/webrtc/", d.webrtcHandler)
 	handler.HandleFunc("/status", d.statusHandler)
+	handler.HandleFunc("/recordings", d.recordingsHandler)
 
 	d.server = &http.Server{
 		Addr:    d.addr,
@@ -92,6 +106,9 @@ func (d *StreamDistributor) Stop() error {
 	for _, stream := range d.streams {
 		stream.Stop()
 	}
+	for _, session := range d.sessions {
+		session.Close()
+	}
 
 	d.running = false
 	return nil
@@ -172,19 +189,9 @@ func (d *StreamDistributor) streamHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
-// flvHandler handles FLV stream requests
+// flvHandler handles FLV stream requests, a client starts with the header, the sequence headers
+// and the cached GOP, then receives the tags as they are muxed
 func (d *StreamDistributor) flvHandler(w http.ResponseWriter, r *http.Request) {
-	// Set headers for FLV streaming
-	w.Header().Set("Content-Type", "video/x-flv")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Transfer-Encoding", "chunked")
-
-	// Flush headers
-	if flusher, ok := w.(http.Flusher); ok {
-		flusher.Flush()
-	}
-
 	// Find FLV stream
 	var flvStream *TranscodedStream
 	d.mu.Lock()
@@ -203,15 +210,28 @@ func (d *StreamDistributor) flvHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer flvStream.DecrementClientCount()
 
+	// Set headers for FLV streaming
+	w.Header().Set("Content-Type", "video/x-flv")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	ch, initial := flvStream.subscribe()
+	defer flvStream.unsubscribe(ch)
+	if _, err := w.Write(initial); err != nil {
+		return
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
 	// Stream FLV data
-	outputChan := flvStream.GetOutputChan()
 	for {
 		select {
 		case <-d.ctx.Done():
 			return
 		case <-r.Context().Done():
 			return
-		case data, ok := <-outputChan:
+		case data, ok := <-ch:
 			if !ok {
 				return
 			}
@@ -263,13 +283,105 @@ func (d *StreamDistributor) hlsHandler(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, hlsPath)
 }
 
-// webrtcHandler handles WebRTC requests
+// webrtcHandler implements WHEP (RFC 9725): a POST of an SDP offer creates a session answered
+// with the resource URL in Location, a DELETE of the resource closes it
 func (d *StreamDistributor) webrtcHandler(w http.ResponseWriter, r *http.Request) {
-	// WebRTC implementation would typically use WebSocket
-	// For now, return a placeholder
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	io.WriteString(w, `{"status":"webrtc_available","message":"WebRTC streaming is available"}`)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "Location")
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPost:
+		d.createWebRTCSession(w, r)
+	case http.MethodDelete:
+		id := strings.TrimPrefix(r.URL.Path, "/webrtc/")
+		d.mu.Lock()
+		session, ok := d.sessions[id]
+		delete(d.sessions, id)
+		d.mu.Unlock()
+		if !ok || id == "" {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		session.Close()
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.Header().Set("Allow", "POST, DELETE, OPTIONS")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// createWebRTCSession answers the offer of a client with a session of the WebRTC stream
+func (d *StreamDistributor) createWebRTCSession(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/sdp") {
+		http.Error(w, "Expected an SDP offer", http.StatusUnsupportedMediaType)
+		return
+	}
+	offer, err := io.ReadAll(io.LimitReader(r.Body, maxOfferSize))
+	if err != nil {
+		http.Error(w, "Failed to read the offer", http.StatusBadRequest)
+		return
+	}
+
+	// Find WebRTC stream
+	var webrtcStream *TranscodedStream
+	d.mu.Lock()
+	for _, stream := range d.streams {
+		if transcoded, ok := stream.(*TranscodedStream); ok && transcoded.streamType == StreamTypeWebRTC {
+			webrtcStream = transcoded
+			break
+		}
+	}
+	if d.certificate == nil && webrtcStream != nil {
+		d.certificate, err = webrtc.GenerateCertificate()
+	}
+	certificate, ip := d.certificate, d.webrtcIP
+	d.mu.Unlock()
+
+	if webrtcStream == nil {
+		http.Error(w, "WebRTC stream not available", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate the certificate: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// the candidate defaults to the address the client reached the server on
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr); ok && ip == nil {
+		ip = addr.IP
+	}
+
+	session, err := webrtc.NewSession(offer, webrtc.Config{
+		IP:                ip,
+		Certificate:       certificate,
+		OnKeyframeRequest: webrtcStream.requestKeyframe,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid offer: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !webrtcStream.addPeer(session) {
+		session.Close()
+		http.Error(w, "WebRTC stream not available", http.StatusNotFound)
+		return
+	}
+	d.mu.Lock()
+	d.sessions[session.ID] = session
+	d.mu.Unlock()
+	go func() {
+		<-session.Done()
+		d.mu.Lock()
+		delete(d.sessions, session.ID)
+		d.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", "/webrtc/"+session.ID)
+	w.Header().Set("Access-Control-Expose-Headers", "Location")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(session.Answer())
 }
 
 // statusHandler handles status requests
@@ -278,38 +390,54 @@ func (d *StreamDistributor) statusHandler(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
+	// The streams are copied, GetStreamInfo may wait for a stream reconnecting
+	d.mu.Lock()
+	running := d.running
+	streams := make(map[string]Streamer, len(d.streams))
+	for id, stream := range d.streams {
+		streams[id] = stream
+	}
+	d.mu.Unlock()
+
 	// Build status
 	status := fmt.Sprintf(`{
 	"status": "%s",
 	"addr": "%s",
 	"streams": [`,
-		func() string { if d.running { return "running" } else { return "stopped" } }(),
+		func() string { if running { return "running" } else { return "stopped" } }(),
 		d.addr,
 	)
 
 	// Add stream statuses
-	d.mu.Lock()
-	for id, stream := range d.streams {
+	for id, stream := range streams {
 		info := stream.GetStreamInfo()
+		rtpStats, _ := json.Marshal(info.RTPStats)
+		recordError, _ := json.Marshal(info.RecordError)
+		if info.RTPStats == nil {
+			rtpStats = []byte("[]")
+		}
 		status += fmt.Sprintf(`
 		{
 			"id": "%s",
 			"type": "%s",
 			"url": "%s",
 			"clients": %d,
-			"last_active": "%s"
+			"last_active": "%s",
+			"record_error": %s,
+			"rtp": %s
 		},`,
 			id,
 			info.StreamType,
 			info.URL,
 			info.ClientCount,
 			info.LastActive.Format(time.RFC3339),
+			recordError,
+			rtpStats,
 		)
 	}
-	d.mu.Unlock()
 
 	// Remove trailing comma and close
-	if len(d.streams) > 0 {
+	if len(streams) > 0 {
 		status = status[:len(status)-1]
 	}
 	status += "\n	]\n}"
@@ -319,6 +447,56 @@ func (d *StreamDistributor) statusHandler(w http.ResponseWriter, r *http.Request
 	io.WriteString(w, status)
 }
 
+// recordingsHandler lists the recordings of the streams as JSON with the recording errors by stream id,
+// the from and to RFC 3339 parameters select the recordings overlapping the range
+func (d *StreamDistributor) recordingsHandler(w http.ResponseWriter, r *http.Request) {
+	var from, to time.Time
+	for name, bound := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := r.URL.Query().Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s time", name), http.StatusBadRequest)
+				return
+			}
+			*bound = t
+		}
+	}
+
+	d.mu.Lock()
+	streams := make(map[string]Streamer, len(d.streams))
+	for id, stream := range d.streams {
+		streams[id] = stream
+	}
+	d.mu.Unlock()
+
+	list := struct {
+		Recordings []Recording       `json:"recordings"`
+		Errors     map[string]string `json:"errors"`
+	}{Recordings: []Recording{}, Errors: map[string]string{}}
+	for id, stream := range streams {
+		if recorder, ok := stream.(interface {
+			Recordings(from, to time.Time) []Recording
+		}); ok {
+			list.Recordings = append(list.Recordings, recorder.Recordings(from, to)...)
+			if err := stream.GetStreamInfo().RecordError; err != "" {
+				list.Errors[id] = err
+			}
+		}
+	}
+	sort.Slice(list.Recordings, func(i, j int) bool { return list.Recordings[i].Start.Before(list.Recordings[j].Start) })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(list)
+}
+
+// SetWebRTCIP sets the IP of the WebRTC candidates, the local address of the HTTP request when unset
+func (d *StreamDistributor) SetWebRTCIP(ip net.IP) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.webrtcIP = ip
+}
+
 // SetHLSPath sets the HLS directory path
 func (d *StreamDistributor) SetHLSPath(path string) {
 	d.hlsDir = path
@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebRTCHandler(t *testing.T) {
+	d := NewStreamDistributor("127.0.0.1:0")
+	input := NewRTSPStream(RTSPConfig{URL: "rtsp://127.0.0.1:8554/live"})
+	webrtcStream := NewTranscodedStream(input, StreamTypeWebRTC)
+	webrtcStream.Start()
+	defer webrtcStream.Stop()
+	d.AddStream(webrtcStream)
+
+	offer := "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\n" +
+		"a=ice-ufrag:EsAw\r\na=ice-pwd:bP+XJMM09aR8AiX1jdukzR6Y\r\n" +
+		"a=fingerprint:sha-256 D7:3C:C5:0A:B4:A8:52:19:31:6F:1A:47:0E:71:61:4B:1C:9F:C9:7C:10:20:A8:4E:3A:58:2E:AB:E1:0F:AD:94\r\n" +
+		"m=video 9 UDP/TLS/RTP/SAVPF 102\r\nc=IN IP4 0.0.0.0\r\n" +
+		"a=setup:actpass\r\na=mid:0\r\na=recvonly\r\na=rtcp-mux\r\n" +
+		"a=rtpmap:102 H264/90000\r\na=fmtp:102 packetization-mode=1;profile-level-id=42e01f\r\n"
+	request := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}))
+		w := httptest.NewRecorder()
+		d.streamHandler(w, r)
+		return w
+	}
+
+	if w := request(http.MethodOptions, "/stream/webrtc", "", ""); w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Expose-Headers") != "Location" {
+		t.Errorf("Unexpected OPTIONS response %d %v", w.Code, w.Header())
+	}
+	if w := request(http.MethodPost, "/stream/webrtc", "text/plain", offer); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 without SDP, got %d", w.Code)
+	}
+	if w := request(http.MethodPost, "/stream/webrtc", "application/sdp", "v=0\r\n"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid offer, got %d", w.Code)
+	}
+
+	w := request(http.MethodPost, "/stream/webrtc", "application/sdp", offer)
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "application/sdp" {
+		t.Fatalf("Unexpected response %d %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, "/webrtc/") || !strings.Contains(w.Body.String(), "typ host\r\n") ||
+		!strings.Contains(w.Body.String(), "a=candidate:1 1 udp 2130706431 127.0.0.1 ") {
+		t.Errorf("Unexpected answer at %s\n%s", location, w.Body)
+	}
+	if info := webrtcStream.GetStreamInfo(); info.ClientCount != 1 {
+		t.Errorf("Expected 1 client, got %d", info.ClientCount)
+	}
+	w = httptest.NewRecorder()
+	d.statusHandler(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	var status struct {
+		Streams []struct {
+			Clients int        `json:"clients"`
+			RTP     []RTPStats `json:"rtp"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || len(status.Streams) != 1 || status.Streams[0].Clients != 1 || status.Streams[0].RTP == nil {
+		t.Errorf("Unexpected status %s (%v)", w.Body, err)
+	}
+
+	r := httptest.NewRequest(http.MethodDelete, location, nil)
+	w = httptest.NewRecorder()
+	d.webrtcHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for DELETE, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	d.webrtcHandler(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a closed session, got %d", w.Code)
+	}
+}
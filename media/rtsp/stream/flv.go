@@ -0,0 +1,146 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+)
+
+// FLV tag types
+const (
+	flvTagAudio = 8
+	flvTagVideo = 9
+)
+
+// maxGOPCacheSize bounds the tags cached for late joiners, a larger GOP is not cached
+const maxGOPCacheSize = 16 << 20
+
+// FLVMuxer muxes the H.264 and AAC RTP packets of an RTSP stream into FLV tags, it caches the
+// sequence headers and the tags since the last keyframe so that late joiners start with a decodable GOP
+type FLVMuxer struct {
+	reader *frameReader
+
+	videoHeader, audioHeader []byte
+	gop                      [][]byte
+	gopSize                  int
+	// keyframe is set while the tags since the last keyframe are cached
+	keyframe bool
+}
+
+// NewFLVMuxer creates a muxer of the first H264 and MPEG4-GENERIC medias of an SDP, the parameter
+// sets and the AAC config are taken from their fmtp
+func NewFLVMuxer(sdp *rtsp.SessionDescription) *FLVMuxer {
+	return &FLVMuxer{reader: newFrameReader(sdp, 1000)}
+}
+
+// Header returns the FLV file header with the first PreviousTagSize
+func (m *FLVMuxer) Header() []byte {
+	var flags byte
+	if m.reader.audio >= 0 {
+		flags |= 0x04
+	}
+	if m.reader.video >= 0 {
+		flags |= 0x01
+	}
+	return []byte{'F', 'L', 'V', 1, flags, 0, 0, 0, 9, 0, 0, 0, 0}
+}
+
+// Cache returns the header, the sequence headers and the tags of the current GOP for a new client
+func (m *FLVMuxer) Cache() []byte {
+	var buf bytes.Buffer
+	buf.Write(m.Header())
+	buf.Write(m.videoHeader)
+	buf.Write(m.audioHeader)
+	for _, tag := range m.gop {
+		buf.Write(tag)
+	}
+	return buf.Bytes()
+}
+
+// WritePacket adds an RTP packet of the stream, returning the FLV tags it completes
+func (m *FLVMuxer) WritePacket(packet RTPInfo) []byte {
+	var buf bytes.Buffer
+	videos, audios := m.reader.read(packet)
+	for _, frame := range videos {
+		m.writeVideo(&buf, frame)
+	}
+	for _, frame := range audios {
+		m.writeAudio(&buf, frame)
+	}
+	return buf.Bytes()
+}
+
+func (m *FLVMuxer) writeVideo(buf *bytes.Buffer, frame videoFrame) {
+	if m.videoHeader == nil || frame.ParamsChanged {
+		m.videoHeader = flvTag(flvTagVideo, frame.DTS, append([]byte{0x17, 0, 0, 0, 0}, m.avcConfig()...))
+		buf.Write(m.videoHeader)
+	}
+
+	data := []byte{0x27, 1, 0, 0, 0}
+	if frame.Keyframe {
+		data[0] = 0x17
+	}
+	cts := frame.PTS - frame.DTS
+	data[2], data[3], data[4] = byte(cts>>16), byte(cts>>8), byte(cts)
+	for _, nalu := range frame.NALUs {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(nalu)))
+		data = append(data, nalu...)
+	}
+	tag := flvTag(flvTagVideo, frame.DTS, data)
+	buf.Write(tag)
+	m.cache(tag, frame.Keyframe)
+}
+
+func (m *FLVMuxer) writeAudio(buf *bytes.Buffer, frame audioFrame) {
+	if m.audioHeader == nil {
+		m.audioHeader = flvTag(flvTagAudio, frame.PTS, append([]byte{0xaf, 0}, m.reader.audioConfig...))
+		buf.Write(m.audioHeader)
+	}
+	tag := flvTag(flvTagAudio, frame.PTS, append([]byte{0xaf, 1}, frame.Data...))
+	buf.Write(tag)
+	if m.reader.video < 0 || m.keyframe {
+		m.cache(tag, false)
+	}
+}
+
+// cache keeps the tags since the last keyframe, audio only streams keep none. A GOP growing over
+// maxGOPCacheSize is dropped and nothing is cached until the next keyframe, a partial GOP isn't decodable
+func (m *FLVMuxer) cache(tag []byte, keyframe bool) {
+	if keyframe {
+		m.keyframe = true
+		m.gop, m.gopSize = nil, 0
+	}
+	if m.reader.video < 0 || !m.keyframe {
+		return
+	}
+	if m.gopSize+len(tag) > maxGOPCacheSize {
+		m.keyframe = false
+		m.gop, m.gopSize = nil, 0
+		return
+	}
+	m.gop = append(m.gop, tag)
+	m.gopSize += len(tag)
+}
+
+// avcConfig returns the AVCDecoderConfigurationRecord of the parameter sets (ISO/IEC 14496-15 5.2.4.1)
+func (m *FLVMuxer) avcConfig() []byte {
+	sps, pps := m.reader.sps, m.reader.pps
+	record := []byte{1, sps[1], sps[2], sps[3], 0xff, 0xe1}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(sps)))
+	record = append(record, sps...)
+	record = append(record, 1)
+	record = binary.BigEndian.AppendUint16(record, uint16(len(pps)))
+	return append(record, pps...)
+}
+
+// flvTag builds a tag followed by its PreviousTagSize
+func flvTag(typ byte, ms int64, data []byte) []byte {
+	ts := uint32(ms)
+	tag := make([]byte, 11, 11+len(data)+4)
+	tag[0] = typ
+	tag[1], tag[2], tag[3] = byte(len(data)>>16), byte(len(data)>>8), byte(len(data))
+	tag[4], tag[5], tag[6], tag[7] = byte(ts>>16), byte(ts>>8), byte(ts), byte(ts>>24)
+	tag = append(tag, data...)
+	return binary.BigEndian.AppendUint32(tag, uint32(11+len(data)))
+}
@@ -0,0 +1,168 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+type flvTestTag struct {
+	Type      byte
+	Timestamp uint32
+	Data      []byte
+}
+
+// parseFLVTags splits tags checking their PreviousTagSize
+func parseFLVTags(t *testing.T, data []byte) []flvTestTag {
+	tags := []flvTestTag{}
+	for len(data) > 0 {
+		if len(data) < 15 {
+			t.Fatalf("Truncated tag %x", data)
+		}
+		size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		ts := uint32(data[7])<<24 | uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6])
+		if binary.BigEndian.Uint32(data[11+size:]) != uint32(11+size) {
+			t.Fatalf("Unexpected PreviousTagSize after a tag of %d bytes", size)
+		}
+		tags = append(tags, flvTestTag{Type: data[0], Timestamp: ts, Data: data[11 : 11+size]})
+		data = data[15+size:]
+	}
+	return tags
+}
+
+func TestFLVMuxer(t *testing.T) {
+	sps, pps := []byte{0x67, 0x64, 0x00, 0x1f, 0xac}, []byte{0x68, 0xee, 0x3c}
+	sdp := &rtsp.SessionDescription{Medias: []rtsp.MediaDescription{
+		{Type: "video", PayloadType: 96, Encoding: "H264", ClockRate: 90000, Fmtp: "packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO48"},
+		{Type: "audio", PayloadType: 97, Encoding: "MPEG4-GENERIC", ClockRate: 44100, Fmtp: "streamtype=5;mode=AAC-hbr;config=1210"},
+	}}
+	m := NewFLVMuxer(sdp)
+	if !bytes.Equal(m.Header(), []byte{'F', 'L', 'V', 1, 5, 0, 0, 0, 9, 0, 0, 0, 0}) {
+		t.Fatalf("Unexpected header %x", m.Header())
+	}
+
+	p := &rtp.Packetizer{PayloadType: 96, SSRC: 1}
+	video := func(nalu []byte, ts uint32) []byte {
+		var res []byte
+		for _, packet := range p.Packetize(rtp.H264Payloads([][]byte{nalu}, 1400), ts) {
+			res = append(res, m.WritePacket(RTPInfo{Track: 0, SequenceNumber: packet.SequenceNumber, Timestamp: packet.Timestamp,
+				Marker: packet.Marker, Payload: packet.Payload})...)
+		}
+		return res
+	}
+	idr := append([]byte{0x65}, make([]byte, 2000)...)
+
+	// decoding starts with a keyframe
+	if data := video([]byte{0x41, 0}, 0); len(data) != 0 {
+		t.Errorf("Expected no tag before a keyframe, got %x", data)
+	}
+	// IDR at 0.1s, then P-frames decoded before the B-frames they follow
+	tags := parseFLVTags(t, video(idr, 9000))
+	for i, ts := range []uint32{18000, 12000, 27000, 21000} {
+		typ := byte(0x41)
+		if i%2 == 1 {
+			typ = 0x01
+		}
+		tags = append(tags, parseFLVTags(t, video([]byte{typ, byte(i)}, ts))...)
+	}
+	if len(tags) != 6 {
+		t.Fatalf("Expected the sequence header and 5 frames, got %d tags", len(tags))
+	}
+
+	record := append([]byte{0x17, 0, 0, 0, 0, 1, 0x64, 0x00, 0x1f, 0xff, 0xe1, 0, 5}, sps...)
+	record = append(append(record, 1, 0, 3), pps...)
+	if tags[0].Type != 9 || !bytes.Equal(tags[0].Data, record) {
+		t.Errorf("Unexpected sequence header %x", tags[0].Data)
+	}
+	keyframe := tags[1]
+	if keyframe.Type != 9 || keyframe.Data[0] != 0x17 || keyframe.Data[1] != 1 || binary.BigEndian.Uint32(keyframe.Data[5:]) != uint32(len(idr)) {
+		t.Errorf("Unexpected keyframe tag %x", keyframe.Data[:9])
+	}
+	// the first B-frame gives the decoding delay, decoding timestamps don't go backwards
+	for i, tag := range tags[2:] {
+		cts := int(tag.Data[2])<<16 | int(tag.Data[3])<<8 | int(tag.Data[4])
+		if tag.Data[0] != 0x27 || cts > 100 || tag.Timestamp < tags[i+1].Timestamp {
+			t.Errorf("Unexpected inter frame %x at %d", tag.Data[:5], tag.Timestamp)
+		}
+	}
+	pts := func(tag flvTestTag) int {
+		return int(tag.Timestamp) + (int(tag.Data[2])<<16 | int(tag.Data[3])<<8 | int(tag.Data[4]))
+	}
+	if pts(tags[2])-pts(tags[1]) != 100 || pts(tags[4])-pts(tags[1]) != 200 || pts(tags[5])-pts(tags[1]) != 133 {
+		t.Errorf("Unexpected presentation timestamps %d %d %d %d", pts(tags[1]), pts(tags[2]), pts(tags[4]), pts(tags[5]))
+	}
+
+	// two AAC frames in a packet
+	audio := rtp.AACPayloads([][]byte{{0x21, 1}, {0x21, 2}}, 1400)[0]
+	tags = parseFLVTags(t, m.WritePacket(RTPInfo{Track: 1, Timestamp: 44100, Marker: true, Payload: audio}))
+	if len(tags) != 3 || !bytes.Equal(tags[0].Data, []byte{0xaf, 0, 0x12, 0x10}) ||
+		!bytes.Equal(tags[1].Data, []byte{0xaf, 1, 0x21, 1}) || !bytes.Equal(tags[2].Data, []byte{0xaf, 1, 0x21, 2}) {
+		t.Fatalf("Unexpected audio tags %+v", tags)
+	}
+	if tags[2].Timestamp-tags[1].Timestamp != 23 {
+		t.Errorf("Expected 1024 samples between frames, got %dms", tags[2].Timestamp-tags[1].Timestamp)
+	}
+
+	// a late joiner starts with the sequence headers and the last GOP
+	video([]byte{0x41, 5}, 30000)
+	video(idr, 33000)
+	video([]byte{0x41, 6}, 36000)
+	cache := m.Cache()
+	if !bytes.HasPrefix(cache, m.Header()) {
+		t.Fatal("Expected the cache to start with the header")
+	}
+	tags = parseFLVTags(t, cache[len(m.Header()):])
+	if len(tags) != 4 || !bytes.Equal(tags[0].Data, record) || tags[1].Data[1] != 0 || tags[2].Data[0] != 0x17 || tags[3].Data[0] != 0x27 {
+		t.Errorf("Unexpected cached tags %+v", tags)
+	}
+}
+
+func TestFLVMuxerGOPCache(t *testing.T) {
+	sdp := &rtsp.SessionDescription{Medias: []rtsp.MediaDescription{
+		{Type: "video", PayloadType: 96, Encoding: "H264", ClockRate: 90000, Fmtp: "packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO48"},
+		{Type: "audio", PayloadType: 97, Encoding: "MPEG4-GENERIC", ClockRate: 44100, Fmtp: "streamtype=5;mode=AAC-hbr;config=1210"},
+	}}
+	m := NewFLVMuxer(sdp)
+	p := &rtp.Packetizer{PayloadType: 96, SSRC: 1}
+	video := func(nalu []byte, ts uint32) {
+		for _, packet := range p.Packetize(rtp.H264Payloads([][]byte{nalu}, 1400), ts) {
+			m.WritePacket(RTPInfo{Track: 0, SequenceNumber: packet.SequenceNumber, Timestamp: packet.Timestamp,
+				Marker: packet.Marker, Payload: packet.Payload})
+		}
+	}
+	audio := func(ts uint32) {
+		m.WritePacket(RTPInfo{Track: 1, Timestamp: ts, Marker: true, Payload: rtp.AACPayloads([][]byte{{0x21, 1}}, 1400)[0]})
+	}
+	cached := func() []flvTestTag {
+		cache := m.Cache()
+		return parseFLVTags(t, cache[len(m.Header()):])
+	}
+	idr := append([]byte{0x65}, make([]byte, 2000)...)
+	// access units are smaller than the limit of the depacketizer
+	large := append([]byte{0x41}, make([]byte, maxGOPCacheSize/3)...)
+
+	video(idr, 0)
+	audio(0)
+	video(large, 3000)
+	video(large, 4500)
+	if tags := cached(); len(tags) != 6 {
+		t.Fatalf("Expected the sequence headers and 4 tags of the GOP, got %d tags", len(tags))
+	}
+
+	// the GOP over the limit is dropped, the next tags aren't cached until a keyframe
+	video(large, 6000)
+	audio(4410)
+	video([]byte{0x41, 1}, 9000)
+	if tags := cached(); len(tags) != 2 || tags[0].Data[1] != 0 || tags[1].Data[1] != 0 {
+		t.Errorf("Expected only the sequence headers, got %+v", tags)
+	}
+
+	video(idr, 12000)
+	audio(8820)
+	if tags := cached(); len(tags) != 4 || tags[2].Data[0] != 0x17 || tags[3].Type != flvTagAudio {
+		t.Errorf("Expected the sequence headers and the new GOP, got %d tags", len(tags))
+	}
+}
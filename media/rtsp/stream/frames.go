@@ -0,0 +1,223 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+// H.264 NALU types handled by the muxers
+const (
+	naluTypePPS = 8
+	naluTypeAUD = 9
+)
+
+// aacFrameSamples is the number of samples of an AAC frame
+const aacFrameSamples = 1024
+
+// maxTimestampJump is the jump of RTP timestamps in seconds restarting a track, like a new SSRC
+const maxTimestampJump = 10
+
+// videoFrame is an H.264 access unit without its parameter sets and delimiter
+type videoFrame struct {
+	PTS, DTS int64
+	Keyframe bool
+	NALUs    [][]byte
+	// ParamsChanged is set when the access unit carried new parameter sets
+	ParamsChanged bool
+}
+
+// audioFrame is a raw AAC frame
+type audioFrame struct {
+	PTS  int64
+	Data []byte
+}
+
+// frameReader reassembles the frames of the first H264 and MPEG4-GENERIC medias of an SDP from their RTP packets.
+// Timestamps are in 1/rate seconds, each track starts at the time of its first packet from the creation of the reader
+// and starts again when its source restarts, after a reconnection
+type frameReader struct {
+	rate                   int64
+	video, audio           int
+	videoClock, audioClock uint32
+	sps, pps               []byte
+	audioConfig            []byte
+	depacketizer           rtp.H264Depacketizer
+
+	start            time.Time
+	videoTS, audioTS timestampUnwrapper
+	// decoding timestamps are the presentation timestamps minus the reorder delay of the B-frames seen so far,
+	// the first B-frames are presented late until the delay is known
+	maxPTS, lastDTS int64
+	delay           int64
+	// keyframe is set by the first keyframe, decoding starts with it
+	keyframe bool
+}
+
+// timestampUnwrapper extends RTP timestamps to 64 bits relative to the first one
+type timestampUnwrapper struct {
+	started bool
+	ssrc    uint32
+	last    uint32
+	ticks   int64
+	// base is the time of the first packet from the start of the reader in 1/rate seconds
+	base int64
+}
+
+func (u *timestampUnwrapper) unwrap(ts uint32) int64 {
+	if !u.started {
+		u.started, u.last, u.ticks = true, ts, 0
+		return 0
+	}
+	u.ticks += int64(int32(ts - u.last))
+	u.last = ts
+	return u.ticks
+}
+
+// restart reports whether a packet starts a new source, with a new SSRC or after a timestamp jump,
+// the next timestamp is then rebased
+func (u *timestampUnwrapper) restart(ssrc, ts, clock uint32) bool {
+	if !u.started {
+		u.ssrc = ssrc
+		return false
+	}
+	d := int64(int32(ts - u.last))
+	if ssrc == u.ssrc && max(d, -d) <= maxTimestampJump*int64(clock) {
+		return false
+	}
+	u.started, u.ssrc = false, ssrc
+	return true
+}
+
+// newFrameReader creates a reader of the medias of an SDP, the parameter sets and the AAC config
+// are taken from their fmtp
+func newFrameReader(sdp *rtsp.SessionDescription, rate int64) *frameReader {
+	r := &frameReader{rate: rate, video: -1, audio: -1, start: time.Now()}
+	if sdp != nil {
+		for i, media := range sdp.Medias {
+			params := media.FmtpParams()
+			switch {
+			case r.video < 0 && strings.EqualFold(media.Encoding, "H264"):
+				r.video, r.videoClock = i, media.ClockRate
+				for _, s := range strings.Split(params["sprop-parameter-sets"], ",") {
+					nalu, err := base64.StdEncoding.DecodeString(s)
+					if err == nil && len(nalu) > 0 {
+						r.setParameterSet(nalu)
+					}
+				}
+			case r.audio < 0 && strings.EqualFold(media.Encoding, "MPEG4-GENERIC"):
+				config, err := hex.DecodeString(params["config"])
+				if err == nil && len(config) >= 2 {
+					r.audio, r.audioClock, r.audioConfig = i, media.ClockRate, config
+				}
+			}
+		}
+	}
+	if r.videoClock == 0 {
+		r.videoClock = 90000
+	}
+	if r.audioClock == 0 {
+		r.audioClock = 48000
+	}
+	return r
+}
+
+// read returns the frames completed by a packet, video frames start with a keyframe
+func (r *frameReader) read(packet RTPInfo) ([]videoFrame, []audioFrame) {
+	switch packet.Track {
+	case r.video:
+		if r.videoTS.restart(packet.SSRC, packet.Timestamp, r.videoClock) {
+			// decoding starts again with a keyframe of the new source
+			r.keyframe = false
+		}
+		aus, _ := r.depacketizer.Depacketize(&rtp.Packet{
+			Marker:         packet.Marker,
+			PayloadType:    packet.PayloadType,
+			SequenceNumber: packet.SequenceNumber,
+			Timestamp:      packet.Timestamp,
+			SSRC:           packet.SSRC,
+			Payload:        packet.Payload,
+		})
+		var frames []videoFrame
+		for _, au := range aus {
+			if frame, ok := r.videoFrame(au); ok {
+				frames = append(frames, frame)
+			}
+		}
+		return frames, nil
+	case r.audio:
+		r.audioTS.restart(packet.SSRC, packet.Timestamp, r.audioClock)
+		data, _ := rtp.AACFrames(packet.Payload)
+		pts := r.timestamp(&r.audioTS, packet.Timestamp, r.audioClock)
+		frames := make([]audioFrame, 0, len(data))
+		for i, frame := range data {
+			frames = append(frames, audioFrame{PTS: pts + int64(i*aacFrameSamples)*r.rate/int64(r.audioClock), Data: frame})
+		}
+		return nil, frames
+	}
+	return nil, nil
+}
+
+// timestamp converts an RTP timestamp to the rate of the reader, a restarted track continues after its last timestamp
+func (r *frameReader) timestamp(u *timestampUnwrapper, ts uint32, clock uint32) int64 {
+	if !u.started {
+		u.base = max(int64(time.Since(r.start))*r.rate/int64(time.Second), u.base+u.ticks*r.rate/int64(clock))
+	}
+	return u.base + u.unwrap(ts)*r.rate/int64(clock)
+}
+
+func (r *frameReader) setParameterSet(nalu []byte) bool {
+	switch nalu[0] & 0x1f {
+	case rtp.H264NALUTypeSPS:
+		if len(nalu) >= 4 && !bytes.Equal(r.sps, nalu) {
+			r.sps = append([]byte{}, nalu...)
+			return true
+		}
+	case naluTypePPS:
+		if !bytes.Equal(r.pps, nalu) {
+			r.pps = append([]byte{}, nalu...)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *frameReader) videoFrame(au *rtp.AccessUnit) (videoFrame, bool) {
+	frame := videoFrame{PTS: r.timestamp(&r.videoTS, au.Timestamp, r.videoClock)}
+	for _, nalu := range au.NALUs {
+		switch nalu[0] & 0x1f {
+		case rtp.H264NALUTypeSPS, naluTypePPS:
+			// parameter sets are sent apart by the muxers
+			if r.setParameterSet(nalu) {
+				frame.ParamsChanged = true
+			}
+			continue
+		case naluTypeAUD:
+			continue
+		case rtp.H264NALUTypeIDR:
+			frame.Keyframe = true
+		}
+		frame.NALUs = append(frame.NALUs, nalu)
+	}
+	if len(frame.NALUs) == 0 || r.sps == nil || r.pps == nil {
+		return frame, false
+	}
+	if !r.keyframe && !frame.Keyframe {
+		return frame, false
+	}
+	r.keyframe = true
+
+	if frame.PTS < r.maxPTS && r.maxPTS-frame.PTS > r.delay {
+		r.delay = r.maxPTS - frame.PTS
+	}
+	r.maxPTS = max(r.maxPTS, frame.PTS)
+	frame.DTS = max(frame.PTS-r.delay, r.lastDTS, 0)
+	frame.PTS = max(frame.PTS, frame.DTS)
+	r.lastDTS = frame.DTS
+	return frame, true
+}
@@ -0,0 +1,193 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+)
+
+// HLS defaults
+const (
+	DefaultHLSTargetDuration = 2 * time.Second
+	DefaultHLSPlaylistSize   = 6
+	// hlsPlaylistName is the playlist served by StreamDistributor.hlsHandler by default
+	hlsPlaylistName = "playlist.m3u8"
+	// hlsSegmentGrace is the number of segments kept after leaving the playlist,
+	// for the clients which loaded an older playlist
+	hlsSegmentGrace = 2
+)
+
+// HLSConfig configures an HLSSegmenter
+type HLSConfig struct {
+	// Dir is the directory of the playlist and the segments
+	Dir string
+	// TargetDuration is the minimum duration of a segment, segments are cut at the first keyframe after it
+	TargetDuration time.Duration
+	// PlaylistSize is the number of segments of the live playlist
+	PlaylistSize int
+}
+
+// HLSSegmenter muxes the H.264 and AAC RTP packets of an RTSP stream into MPEG-TS segments
+// listed by a sliding window live playlist
+type HLSSegmenter struct {
+	config HLSConfig
+	reader *frameReader
+	muxer  *tsMuxer
+
+	// the current segment, buffered until it's cut
+	buf     bytes.Buffer
+	started bool
+	start   int64
+	last    int64
+	// segments are the completed segments, the oldest first
+	segments []hlsSegment
+	sequence int
+	closed   bool
+}
+
+type hlsSegment struct {
+	Sequence int
+	Name     string
+	Duration float64
+}
+
+// NewHLSSegmenter creates a segmenter of the first H264 and MPEG4-GENERIC medias of an SDP
+func NewHLSSegmenter(config HLSConfig, sdp *rtsp.SessionDescription) *HLSSegmenter {
+	if config.TargetDuration <= 0 {
+		config.TargetDuration = DefaultHLSTargetDuration
+	}
+	if config.PlaylistSize <= 0 {
+		config.PlaylistSize = DefaultHLSPlaylistSize
+	}
+	reader := newFrameReader(sdp, 90000)
+	return &HLSSegmenter{
+		config: config,
+		reader: reader,
+		muxer:  newTSMuxer(reader.video >= 0, reader.audio >= 0, reader.audioConfig),
+	}
+}
+
+// WritePacket adds an RTP packet of the stream, writing the segment and the playlist when a segment is cut
+func (h *HLSSegmenter) WritePacket(packet RTPInfo) error {
+	if h.closed {
+		return fmt.Errorf("HLS segmenter is closed")
+	}
+	videos, audios := h.reader.read(packet)
+	for _, frame := range videos {
+		if err := h.cut(frame.DTS, frame.Keyframe); err != nil {
+			return err
+		}
+		h.muxer.writeVideo(&h.buf, frame, h.reader.sps, h.reader.pps)
+	}
+	if len(audios) > 0 {
+		// audio waits for the first video segment
+		if !h.started && h.reader.video >= 0 {
+			return nil
+		}
+		if err := h.cut(audios[0].PTS, h.reader.video < 0); err != nil {
+			return err
+		}
+		h.muxer.writeAudio(&h.buf, audios)
+	}
+	return nil
+}
+
+// cut starts a segment at a random access point once the current one reaches the target duration
+func (h *HLSSegmenter) cut(ts int64, randomAccess bool) error {
+	if h.started && ts > h.last {
+		h.last = ts
+	}
+	if !randomAccess {
+		return nil
+	}
+	if h.started {
+		if time.Duration(ts-h.start)*time.Second/90000 < h.config.TargetDuration {
+			return nil
+		}
+		if err := h.flush(float64(ts-h.start) / 90000); err != nil {
+			return err
+		}
+	}
+	h.started, h.start, h.last = true, ts, ts
+	h.buf.Reset()
+	h.muxer.writeTables(&h.buf)
+	return nil
+}
+
+// flush writes the current segment, updates the playlist and removes the expired segments
+func (h *HLSSegmenter) flush(duration float64) error {
+	if err := os.MkdirAll(h.config.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create HLS directory: %v", err)
+	}
+	segment := hlsSegment{Sequence: h.sequence, Name: fmt.Sprintf("segment%d.ts", h.sequence), Duration: duration}
+	if err := writeFileAtomic(filepath.Join(h.config.Dir, segment.Name), h.buf.Bytes()); err != nil {
+		return err
+	}
+	h.sequence++
+	h.segments = append(h.segments, segment)
+
+	for len(h.segments) > h.config.PlaylistSize+hlsSegmentGrace {
+		os.Remove(filepath.Join(h.config.Dir, h.segments[0].Name))
+		h.segments = h.segments[1:]
+	}
+	return h.writePlaylist(false)
+}
+
+// writePlaylist writes the last segments, ended when the stream is over
+func (h *HLSSegmenter) writePlaylist(end bool) error {
+	segments := h.segments
+	if len(segments) > h.config.PlaylistSize {
+		segments = segments[len(segments)-h.config.PlaylistSize:]
+	}
+	target := math.Ceil(h.config.TargetDuration.Seconds())
+	for _, segment := range segments {
+		target = max(target, math.Ceil(segment.Duration))
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(target))
+	media := 0
+	if len(segments) > 0 {
+		media = segments[0].Sequence
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", media)
+	for _, segment := range segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", segment.Duration, segment.Name)
+	}
+	if end {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return writeFileAtomic(filepath.Join(h.config.Dir, hlsPlaylistName), []byte(b.String()))
+}
+
+// Close writes the last segment and ends the playlist
+func (h *HLSSegmenter) Close() error {
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	if !h.started {
+		return nil
+	}
+	// the last frame lasts as long as the frame interval is unknown, a tenth of a second
+	if err := h.flush(float64(h.last-h.start)/90000 + 0.1); err != nil {
+		return err
+	}
+	return h.writePlaylist(true)
+}
+
+// writeFileAtomic writes a file through a temporary file so that readers never see it partially written
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,140 @@
+package stream
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+func TestHLSSegmenter(t *testing.T) {
+	dir := t.TempDir()
+	sdp := &rtsp.SessionDescription{Medias: []rtsp.MediaDescription{
+		{Type: "video", PayloadType: 96, Encoding: "H264", ClockRate: 90000, Fmtp: "packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO48"},
+		{Type: "audio", PayloadType: 97, Encoding: "MPEG4-GENERIC", ClockRate: 8000, Fmtp: "streamtype=5;mode=AAC-hbr;config=1588"},
+	}}
+	h := NewHLSSegmenter(HLSConfig{Dir: dir, TargetDuration: 2 * time.Second, PlaylistSize: 2}, sdp)
+
+	// 14s at 25 fps with a keyframe every second, 8 AAC frames a second
+	video := &rtp.Packetizer{PayloadType: 96, SSRC: 1}
+	audio := &rtp.Packetizer{PayloadType: 97, SSRC: 2}
+	for i := 0; i < 14*25; i++ {
+		nalu := []byte{0x41, byte(i)}
+		if i%25 == 0 {
+			nalu = append([]byte{0x65}, make([]byte, 500)...)
+		}
+		for _, p := range video.Packetize(rtp.H264Payloads([][]byte{nalu}, 1400), uint32(3600*i)) {
+			if err := h.WritePacket(RTPInfo{Track: 0, SequenceNumber: p.SequenceNumber, Timestamp: p.Timestamp, Marker: p.Marker, Payload: p.Payload}); err != nil {
+				t.Fatalf("Failed to write video: %v", err)
+			}
+		}
+		if i%25%3 == 0 && i%25 != 24 {
+			p := audio.Packetize(rtp.AACPayloads([][]byte{{0x21, byte(i)}}, 1400), uint32(1000*(i/25*8+i%25/3)))[0]
+			if err := h.WritePacket(RTPInfo{Track: 1, SequenceNumber: p.SequenceNumber, Timestamp: p.Timestamp, Marker: p.Marker, Payload: p.Payload}); err != nil {
+				t.Fatalf("Failed to write audio: %v", err)
+			}
+		}
+	}
+
+	playlist, err := os.ReadFile(filepath.Join(dir, "playlist.m3u8"))
+	if err != nil {
+		t.Fatalf("Failed to read the playlist: %v", err)
+	}
+	expected := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:4\n" +
+		"#EXTINF:2.000,\nsegment4.ts\n#EXTINF:2.000,\nsegment5.ts\n"
+	if string(playlist) != expected {
+		t.Errorf("Unexpected playlist\n%s", playlist)
+	}
+	// the expired segments are removed after the grace segments
+	for i, exists := range []bool{false, false, true, true, true, true, false} {
+		if _, err := os.Stat(filepath.Join(dir, "segment"+strconv.Itoa(i)+".ts")); (err == nil) != exists {
+			t.Errorf("Segment %d: expected exists %v, got %v", i, exists, err)
+		}
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	playlist, _ = os.ReadFile(filepath.Join(dir, "playlist.m3u8"))
+	if !strings.Contains(string(playlist), "#EXT-X-MEDIA-SEQUENCE:5\n") || !strings.HasSuffix(string(playlist), "segment6.ts\n#EXT-X-ENDLIST\n") {
+		t.Errorf("Unexpected ended playlist\n%s", playlist)
+	}
+	if h.WritePacket(RTPInfo{Track: 0}) == nil {
+		t.Error("Expected an error after Close")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "segment5.ts"))
+	if err != nil {
+		t.Fatalf("Failed to read the segment: %v", err)
+	}
+	checkTSSegment(t, data)
+}
+
+// checkTSSegment checks the tables, the continuity counters and the first PES of each stream
+func checkTSSegment(t *testing.T, data []byte) {
+	if len(data) == 0 || len(data)%tsPacketSize != 0 {
+		t.Fatalf("Unexpected segment size %d", len(data))
+	}
+	continuity := map[uint16]byte{}
+	pes := map[uint16][]byte{}
+	for i := 0; i < len(data); i += tsPacketSize {
+		packet := data[i : i+tsPacketSize]
+		pid := uint16(packet[1]&0x1f)<<8 | uint16(packet[2])
+		if packet[0] != 0x47 {
+			t.Fatalf("Packet %d: missing sync byte", i/tsPacketSize)
+		}
+		if cc, ok := continuity[pid]; ok && packet[3]&0x0f != (cc+1)&0x0f {
+			t.Errorf("Packet %d: continuity %d after %d on PID %x", i/tsPacketSize, packet[3]&0x0f, cc, pid)
+		}
+		continuity[pid] = packet[3] & 0x0f
+
+		payload := packet[4:]
+		if packet[3]&0x20 != 0 {
+			payload = payload[1+int(payload[0]):]
+		}
+		switch pid {
+		case tsPIDPAT, tsPIDPMT:
+			if i/tsPacketSize > 1 {
+				t.Errorf("Unexpected table at packet %d", i/tsPacketSize)
+			}
+			length := int(payload[2]&0x0f)<<8 | int(payload[3])
+			if crc32MPEG(payload[1:4+length]) != 0 {
+				t.Errorf("Invalid CRC of the table of PID %x", pid)
+			}
+		default:
+			if packet[1]&0x40 != 0 && pes[pid] == nil {
+				pes[pid] = []byte{}
+				if pid == tsPIDVideo && (packet[3]&0x20 == 0 || packet[5]&0x50 != 0x50) {
+					t.Error("Expected the PCR and the random access indicator in the first video packet")
+				}
+			} else if packet[1]&0x40 != 0 {
+				continue
+			}
+			if pes[pid] != nil && len(pes[pid]) < 64 {
+				pes[pid] = append(pes[pid], payload...)
+			}
+		}
+	}
+	if data[1] != 0x40 || data[2] != 0 || data[tsPacketSize+2] != 0 || data[tsPacketSize+1] != 0x50 {
+		t.Error("Expected the segment to start with the PAT and the PMT")
+	}
+
+	// PES header of 14 bytes with PTS only without B-frames, then a delimiter and the parameter sets
+	video := pes[tsPIDVideo]
+	if !bytes.HasPrefix(video, []byte{0, 0, 1, 0xe0}) || video[7] != 0x80 ||
+		!bytes.HasPrefix(video[14:], []byte{0, 0, 0, 1, 9, 0xf0, 0, 0, 0, 1, 0x67, 0x64, 0x00, 0x1f, 0xac, 0, 0, 0, 1, 0x68}) {
+		t.Errorf("Unexpected video PES %x", video)
+	}
+	// PES header of 14 bytes with PTS, then an ADTS header of AAC LC 8kHz mono
+	audio := pes[tsPIDAudio]
+	if !bytes.HasPrefix(audio, []byte{0, 0, 1, 0xc0}) || audio[7] != 0x80 ||
+		!bytes.HasPrefix(audio[14:], []byte{0xff, 0xf1, 0x6c, 0x40, 0x01, 0x3f, 0xfc, 0x21}) {
+		t.Errorf("Unexpected audio PES %x", audio)
+	}
+}
@@ -0,0 +1,227 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// MPEG-TS (ISO/IEC 13818-1) constants
+const (
+	tsPacketSize = 188
+	tsPIDPAT     = 0x0000
+	tsPIDPMT     = 0x1000
+	tsPIDVideo   = 0x0100
+	tsPIDAudio   = 0x0101
+
+	tsStreamTypeH264 = 0x1b
+	tsStreamTypeAAC  = 0x0f
+
+	// tsTimestampOffset delays the timestamps so that they start after the first PCR
+	tsTimestampOffset = 90000
+)
+
+// tsMuxer muxes H.264 and AAC frames with 90kHz timestamps into MPEG-TS packets, the PCR is carried by the
+// video PID, or by the audio PID without video
+type tsMuxer struct {
+	video, audio bool
+	// audioConfig is the AudioSpecificConfig giving the ADTS headers
+	audioConfig []byte
+	continuity  map[uint16]byte
+}
+
+func newTSMuxer(video, audio bool, audioConfig []byte) *tsMuxer {
+	return &tsMuxer{video: video, audio: audio, audioConfig: audioConfig, continuity: make(map[uint16]byte)}
+}
+
+func (m *tsMuxer) pcrPID() uint16 {
+	if m.video {
+		return tsPIDVideo
+	}
+	return tsPIDAudio
+}
+
+// writeTables writes the PAT and the PMT, which start each segment
+func (m *tsMuxer) writeTables(buf *bytes.Buffer) {
+	// program 1 in the PMT PID
+	pat := []byte{0, 1, 0xc1, 0, 0, 0, 1, 0xe0 | tsPIDPMT>>8, tsPIDPMT & 0xff}
+	m.writeSection(buf, tsPIDPAT, 0, pat)
+
+	pmt := []byte{0, 1, 0xc1, 0, 0, 0xe0 | byte(m.pcrPID()>>8), byte(m.pcrPID()), 0xf0, 0}
+	if m.video {
+		pmt = append(pmt, tsStreamTypeH264, 0xe0|tsPIDVideo>>8, tsPIDVideo&0xff, 0xf0, 0)
+	}
+	if m.audio {
+		pmt = append(pmt, tsStreamTypeAAC, 0xe0|tsPIDAudio>>8, tsPIDAudio&0xff, 0xf0, 0)
+	}
+	m.writeSection(buf, tsPIDPMT, 2, pmt)
+}
+
+// writeSection writes a PSI section in a single packet
+func (m *tsMuxer) writeSection(buf *bytes.Buffer, pid uint16, tableID byte, data []byte) {
+	// section_length covers the data and the CRC
+	length := len(data) + 4
+	section := []byte{0, tableID, 0xb0 | byte(length>>8), byte(length)}
+	section = append(section, data...)
+	section = binary.BigEndian.AppendUint32(section, crc32MPEG(section[1:]))
+
+	packet := make([]byte, tsPacketSize)
+	packet[0], packet[1], packet[2], packet[3] = 0x47, 0x40|byte(pid>>8), byte(pid), 0x10|m.nextContinuity(pid)
+	n := copy(packet[4:], section)
+	for i := 4 + n; i < tsPacketSize; i++ {
+		packet[i] = 0xff
+	}
+	buf.Write(packet)
+}
+
+// writeVideo writes an access unit in Annex B with a delimiter, the parameter sets precede keyframes
+func (m *tsMuxer) writeVideo(buf *bytes.Buffer, frame videoFrame, sps, pps []byte) {
+	startCode := []byte{0, 0, 0, 1}
+	data := append(startCode, naluTypeAUD, 0xf0)
+	if frame.Keyframe {
+		data = append(append(append(data, startCode...), sps...), startCode...)
+		data = append(data, pps...)
+	}
+	for _, nalu := range frame.NALUs {
+		data = append(append(data, startCode...), nalu...)
+	}
+	m.writePES(buf, tsPIDVideo, 0xe0, frame.PTS, frame.DTS, frame.Keyframe, data)
+}
+
+// writeAudio writes AAC frames in ADTS in one PES, with the timestamp of the first
+func (m *tsMuxer) writeAudio(buf *bytes.Buffer, frames []audioFrame) {
+	if len(frames) == 0 {
+		return
+	}
+	var data []byte
+	for _, frame := range frames {
+		data = append(append(data, adtsHeader(m.audioConfig, len(frame.Data))...), frame.Data...)
+	}
+	pts := frames[0].PTS
+	m.writePES(buf, tsPIDAudio, 0xc0, pts, pts, !m.video, data)
+}
+
+// writePES writes a PES packet, the first TS packet carries the PCR on the PCR PID
+func (m *tsMuxer) writePES(buf *bytes.Buffer, pid uint16, streamID byte, pts, dts int64, randomAccess bool, data []byte) {
+	pts, dts = pts+tsTimestampOffset, dts+tsTimestampOffset
+	header := []byte{0, 0, 1, streamID, 0, 0, 0x80, 0x80, 5}
+	header = appendTimestamp(header, 0x2, pts)
+	if dts != pts {
+		header[7], header[8] = 0xc0, 10
+		header[len(header)-5] |= 0x10
+		header = appendTimestamp(header, 0x1, dts)
+	}
+	// PES_packet_length is unbounded (0) for large video PES
+	if length := len(header) - 6 + len(data); length <= 0xffff && streamID != 0xe0 {
+		header[4], header[5] = byte(length>>8), byte(length)
+	}
+	payload := append(header, data...)
+
+	for first := true; len(payload) > 0; first = false {
+		packet := make([]byte, 4, tsPacketSize)
+		packet[0], packet[1], packet[2] = 0x47, byte(pid>>8), byte(pid)
+		if first {
+			packet[1] |= 0x40
+		}
+
+		var adaptation []byte
+		if first && pid == m.pcrPID() {
+			flags := byte(0x10)
+			if randomAccess {
+				flags |= 0x40
+			}
+			adaptation = append([]byte{flags}, pcr(dts)...)
+		} else if first && randomAccess {
+			adaptation = []byte{0x40}
+		}
+		// the adaptation field is stuffed to fill the last packet
+		space := tsPacketSize - 4
+		if adaptation != nil {
+			space -= 1 + len(adaptation)
+		}
+		if len(payload) < space {
+			stuffing := space - len(payload)
+			if adaptation == nil {
+				stuffing--
+				if stuffing > 0 {
+					adaptation = []byte{0}
+					stuffing--
+				} else {
+					// a single byte adaptation field with only its length
+					adaptation = []byte{}
+				}
+			}
+			adaptation = append(adaptation, bytes.Repeat([]byte{0xff}, stuffing)...)
+			space = len(payload)
+		}
+
+		if adaptation != nil {
+			packet[3] = 0x30 | m.nextContinuity(pid)
+			packet = append(packet, byte(len(adaptation)))
+			packet = append(packet, adaptation...)
+		} else {
+			packet[3] = 0x10 | m.nextContinuity(pid)
+		}
+		packet = append(packet, payload[:space]...)
+		payload = payload[space:]
+		buf.Write(packet)
+	}
+}
+
+func (m *tsMuxer) nextContinuity(pid uint16) byte {
+	cc := m.continuity[pid]
+	m.continuity[pid] = (cc + 1) & 0x0f
+	return cc
+}
+
+// appendTimestamp appends a 33 bit PES timestamp with its 4 bit prefix
+func appendTimestamp(b []byte, prefix byte, ts int64) []byte {
+	ts &= 1<<33 - 1
+	return append(b,
+		prefix<<4|byte(ts>>29)&0x0e|1,
+		byte(ts>>22),
+		byte(ts>>14)|1,
+		byte(ts>>7),
+		byte(ts<<1)|1,
+	)
+}
+
+// pcr encodes a program clock reference of a 90kHz base without extension
+func pcr(ts int64) []byte {
+	ts &= 1<<33 - 1
+	return []byte{byte(ts >> 25), byte(ts >> 17), byte(ts >> 9), byte(ts >> 1), byte(ts<<7) | 0x7e, 0}
+}
+
+// adtsHeader returns the ADTS header of an AAC frame of an AudioSpecificConfig (ISO/IEC 14496-3 1.A.2)
+func adtsHeader(config []byte, size int) []byte {
+	var objectType, frequency, channels byte = 2, 4, 2
+	if len(config) >= 2 {
+		objectType = config[0] >> 3
+		frequency = (config[0]&0x07)<<1 | config[1]>>7
+		channels = config[1] >> 3 & 0x0f
+	}
+	length := size + 7
+	return []byte{
+		0xff, 0xf1,
+		(objectType-1)<<6 | frequency<<2 | channels>>2,
+		channels<<6 | byte(length>>11),
+		byte(length >> 3),
+		byte(length<<5) | 0x1f,
+		0xfc,
+	}
+}
+
+// crc32MPEG computes the CRC of PSI sections, polynomial 0x04c11db7 without reflection
+func crc32MPEG(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
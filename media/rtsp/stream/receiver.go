@@ -0,0 +1,305 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+// DefaultJitterLatency is the time a packet waits for the packets missing before it
+const DefaultJitterLatency = 200 * time.Millisecond
+
+// ReceiverReportInterval is the interval of the receiver reports sent to the server
+var ReceiverReportInterval = 5 * time.Second
+
+// Sequence number jumps restarting the buffer and the statistics (RFC 3550 A.1)
+const (
+	maxDropout  = 3000
+	maxMisorder = 100
+)
+
+// maxJitterPackets limits the packets buffered behind a gap
+const maxJitterPackets = 1000
+
+// RTPStats are the reception statistics of a track of an RTSP stream
+type RTPStats struct {
+	// Track is the index of the media in the SDP
+	Track int    `json:"track"`
+	SSRC  uint32 `json:"ssrc"`
+	// Received counts the packets received, duplicates included
+	Received uint64 `json:"received"`
+	// Lost is the number of packets expected but not received
+	Lost int64 `json:"lost"`
+	// FractionLost is the fraction of the packets lost in the last report interval
+	FractionLost float64 `json:"fraction_lost"`
+	// Jitter is the interarrival jitter
+	Jitter time.Duration `json:"jitter_ns"`
+	// Reordered counts the packets put back in order, Late the packets arriving after their gap was skipped
+	// and Duplicates the packets received twice
+	Reordered  uint64 `json:"reordered"`
+	Late       uint64 `json:"late"`
+	Duplicates uint64 `json:"duplicates"`
+	// Dropped counts the packets dropped because the packet channel was full
+	Dropped uint64 `json:"dropped"`
+	// SenderTime is the wallclock time of SenderRTPTime in the last sender report, zero without one
+	SenderTime    time.Time `json:"sender_time,omitzero"`
+	SenderRTPTime uint32    `json:"sender_rtp_time,omitempty"`
+	// LastReport is the time of the last receiver report sent
+	LastReport time.Time `json:"last_report,omitzero"`
+}
+
+// jitterBuffer puts the packets of a track back in order of sequence number. A packet is released once
+// the packets before it are, or when the oldest packet buffered has waited for the latency
+type jitterBuffer struct {
+	latency time.Duration
+	started bool
+	// next is the sequence number of the next packet released
+	next    uint16
+	highest uint16
+	packets map[uint16]bufferedPacket
+
+	reordered, late, duplicates uint64
+}
+
+type bufferedPacket struct {
+	info    RTPInfo
+	arrival time.Time
+}
+
+func newJitterBuffer(latency time.Duration) *jitterBuffer {
+	if latency <= 0 {
+		latency = DefaultJitterLatency
+	}
+	return &jitterBuffer{latency: latency, packets: map[uint16]bufferedPacket{}}
+}
+
+// push buffers a packet and returns the packets released in order
+func (b *jitterBuffer) push(info RTPInfo, now time.Time) []RTPInfo {
+	seq := info.SequenceNumber
+	var released []RTPInfo
+	if d := int16(seq - b.next); !b.started || d > maxDropout || d < -maxMisorder {
+		// the source restarted, the packets buffered are released as is
+		released = b.flush()
+		b.started, b.next, b.highest = true, seq, seq
+	} else if d < 0 {
+		b.late++
+		return nil
+	}
+	if _, ok := b.packets[seq]; ok {
+		b.duplicates++
+		return released
+	}
+	if int16(seq-b.highest) > 0 {
+		b.highest = seq
+	} else if seq != b.highest {
+		b.reordered++
+	}
+	b.packets[seq] = bufferedPacket{info: info, arrival: now}
+	return append(released, b.expire(now)...)
+}
+
+// expire releases the packets in order, skipping the gaps waited for the latency
+func (b *jitterBuffer) expire(now time.Time) []RTPInfo {
+	released := b.drain()
+	for len(b.packets) > 0 {
+		oldest, first := b.oldest()
+		if len(b.packets) < maxJitterPackets && now.Sub(oldest) < b.latency {
+			break
+		}
+		b.next = first
+		released = append(released, b.drain()...)
+	}
+	return released
+}
+
+// drain releases the packets following the last one released
+func (b *jitterBuffer) drain() []RTPInfo {
+	var released []RTPInfo
+	for p, ok := b.packets[b.next]; ok; p, ok = b.packets[b.next] {
+		released = append(released, p.info)
+		delete(b.packets, b.next)
+		b.next++
+	}
+	return released
+}
+
+// oldest returns the arrival of the oldest packet buffered and the first sequence number buffered
+func (b *jitterBuffer) oldest() (time.Time, uint16) {
+	var arrival time.Time
+	first := b.highest
+	for seq, p := range b.packets {
+		if arrival.IsZero() || p.arrival.Before(arrival) {
+			arrival = p.arrival
+		}
+		if int16(seq-first) < 0 {
+			first = seq
+		}
+	}
+	return arrival, first
+}
+
+// deadline returns when the oldest packet buffered is released, zero when the buffer is empty
+func (b *jitterBuffer) deadline() time.Time {
+	if len(b.packets) == 0 {
+		return time.Time{}
+	}
+	arrival, _ := b.oldest()
+	return arrival.Add(b.latency)
+}
+
+// flush releases the packets buffered in order
+func (b *jitterBuffer) flush() []RTPInfo {
+	var released []RTPInfo
+	for len(b.packets) > 0 {
+		_, b.next = b.oldest()
+		released = append(released, b.drain()...)
+	}
+	return released
+}
+
+// receiverStats computes the statistics of the packets of a source and the reception reports (RFC 3550 A.1, A.3, A.8)
+type receiverStats struct {
+	clockRate uint32
+	ssrc      uint32
+	started   bool
+	baseSeq   uint16
+	maxSeq    uint16
+	cycles    uint32
+	// badSeq is the sequence number expected after a large jump for the source to restart, 1<<16+1 for none
+	badSeq   uint32
+	received uint64
+	// prior values of the last report
+	expectedPrior, receivedPrior uint64
+	fraction                     uint8
+	// reference is the time of the first packet, arrivals are converted to timestamp units from it
+	reference time.Time
+	transit   uint32
+	jitter    float64
+
+	// the last sender report and its arrival
+	senderNTP  uint64
+	senderRTP  uint32
+	senderAt   time.Time
+	lastReport time.Time
+}
+
+func newReceiverStats(clockRate uint32) *receiverStats {
+	if clockRate == 0 {
+		clockRate = 90000
+	}
+	return &receiverStats{clockRate: clockRate, badSeq: 1<<16 + 1}
+}
+
+// receive accounts for a packet of the source. After a large jump of the sequence numbers the source
+// restarts at the next packet if it follows, a stray packet isn't counted
+func (s *receiverStats) receive(ssrc uint32, seq uint16, timestamp uint32, arrival time.Time) {
+	delta := seq - s.maxSeq
+	switch {
+	case !s.started || ssrc != s.ssrc:
+		s.restart(ssrc, seq, timestamp, arrival)
+	case delta < maxDropout:
+		if seq < s.maxSeq {
+			s.cycles += 1 << 16
+		}
+		s.maxSeq = seq
+	case delta <= 1<<16-maxMisorder:
+		if uint32(seq) != s.badSeq {
+			s.badSeq = uint32(seq + 1)
+			return
+		}
+		// two sequential packets, the source restarted
+		s.restart(ssrc, seq, timestamp, arrival)
+	}
+	s.received++
+
+	transit := s.arrivalUnits(arrival) - timestamp
+	d := float64(int32(transit - s.transit))
+	if d < 0 {
+		d = -d
+	}
+	s.transit = transit
+	s.jitter += (d - s.jitter) / 16
+}
+
+// restart starts the statistics of a new source, or of a source restarting at seq
+func (s *receiverStats) restart(ssrc uint32, seq uint16, timestamp uint32, arrival time.Time) {
+	s.started, s.ssrc, s.reference = true, ssrc, arrival
+	s.baseSeq, s.maxSeq, s.cycles, s.badSeq = seq, seq, 0, 1<<16+1
+	s.received, s.expectedPrior, s.receivedPrior = 0, 0, 0
+	s.transit = s.arrivalUnits(arrival) - timestamp
+	s.jitter = 0
+}
+
+// arrivalUnits returns the arrival time of a packet in timestamp units
+func (s *receiverStats) arrivalUnits(arrival time.Time) uint32 {
+	d := arrival.Sub(s.reference)
+	return uint32(uint64(d/time.Second)*uint64(s.clockRate) + uint64(d%time.Second)*uint64(s.clockRate)/uint64(time.Second))
+}
+
+// senderReport records the sender report of the source for the reports and the wallclock of the timestamps
+func (s *receiverStats) senderReport(sr *rtp.SenderReport, arrival time.Time) {
+	s.senderNTP, s.senderRTP, s.senderAt = sr.NTPTime, sr.RTPTime, arrival
+}
+
+func (s *receiverStats) expected() uint64 {
+	return uint64(s.cycles) + uint64(s.maxSeq) - uint64(s.baseSeq) + 1
+}
+
+// report returns the reception report of the interval since the previous one
+func (s *receiverStats) report(now time.Time) rtp.ReceptionReport {
+	expected := s.expected()
+	expectedInterval := expected - s.expectedPrior
+	receivedInterval := s.received - s.receivedPrior
+	s.expectedPrior, s.receivedPrior = expected, s.received
+	s.fraction = 0
+	if expectedInterval > 0 && expectedInterval > receivedInterval {
+		s.fraction = uint8((expectedInterval - receivedInterval) << 8 / expectedInterval)
+	}
+	s.lastReport = now
+
+	lost := max(min(int64(expected)-int64(s.received), 0x7fffff), -0x800000)
+	report := rtp.ReceptionReport{
+		SSRC:            s.ssrc,
+		FractionLost:    s.fraction,
+		TotalLost:       int32(lost),
+		HighestSequence: s.cycles + uint32(s.maxSeq),
+		Jitter:          uint32(s.jitter),
+	}
+	if !s.senderAt.IsZero() {
+		report.LastSR = uint32(s.senderNTP >> 16)
+		report.DelaySinceLastSR = uint32(now.Sub(s.senderAt) * 65536 / time.Second)
+	}
+	return report
+}
+
+// wallclock returns the wallclock time of a timestamp from the last sender report
+func (s *receiverStats) wallclock(timestamp uint32) (time.Time, bool) {
+	if s.senderAt.IsZero() {
+		return time.Time{}, false
+	}
+	offset := time.Duration(int32(timestamp-s.senderRTP)) * time.Second / time.Duration(s.clockRate)
+	return rtp.NTPToTime(s.senderNTP).Add(offset), true
+}
+
+// stats returns the statistics of the source with the counters of the jitter buffer
+func (s *receiverStats) stats(track int, buffer *jitterBuffer, dropped uint64) RTPStats {
+	stats := RTPStats{
+		Track:        track,
+		SSRC:         s.ssrc,
+		Received:     s.received,
+		FractionLost: float64(s.fraction) / 256,
+		Jitter:       time.Duration(s.jitter * float64(time.Second) / float64(s.clockRate)),
+		Reordered:    buffer.reordered,
+		Late:         buffer.late,
+		Duplicates:   buffer.duplicates,
+		Dropped:      dropped,
+		LastReport:   s.lastReport,
+	}
+	if s.started {
+		stats.Lost = int64(s.expected()) - int64(s.received)
+	}
+	if !s.senderAt.IsZero() {
+		stats.SenderTime, stats.SenderRTPTime = rtp.NTPToTime(s.senderNTP), s.senderRTP
+	}
+	return stats
+}
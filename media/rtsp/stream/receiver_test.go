@@ -0,0 +1,182 @@
+package stream
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+func TestJitterBuffer(t *testing.T) {
+	b := newJitterBuffer(100 * time.Millisecond)
+	now := time.Now()
+	push := func(seq uint16, at time.Duration) []uint16 {
+		var seqs []uint16
+		for _, p := range b.push(RTPInfo{SequenceNumber: seq}, now.Add(at)) {
+			seqs = append(seqs, p.SequenceNumber)
+		}
+		return seqs
+	}
+	expect := func(name string, got []uint16, expected ...uint16) {
+		t.Helper()
+		if len(got) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+			return
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Errorf("%s: expected %v, got %v", name, expected, got)
+				return
+			}
+		}
+	}
+
+	expect("first", push(65534, 0), 65534)
+	expect("gap", push(0, 0))
+	expect("reordered", push(65535, 10*time.Millisecond), 65535, 0)
+	expect("late", push(65535, 10*time.Millisecond))
+	expect("gap of 1", push(2, 20*time.Millisecond))
+	expect("duplicate", push(2, 30*time.Millisecond))
+	if deadline := b.deadline(); !deadline.Equal(now.Add(120 * time.Millisecond)) {
+		t.Errorf("Unexpected deadline %v", deadline.Sub(now))
+	}
+	expect("waiting", push(3, 50*time.Millisecond))
+	var seqs []uint16
+	for _, p := range b.expire(now.Add(120 * time.Millisecond)) {
+		seqs = append(seqs, p.SequenceNumber)
+	}
+	expect("expired", seqs, 2, 3)
+	expect("skipped", push(1, 130*time.Millisecond))
+
+	// a restart releases the packets buffered
+	expect("buffered", push(5, 140*time.Millisecond))
+	expect("restart", push(30000, 150*time.Millisecond), 5, 30000)
+	if b.reordered != 1 || b.late != 2 || b.duplicates != 1 || !b.deadline().IsZero() {
+		t.Errorf("Unexpected counters %d reordered, %d late, %d duplicates", b.reordered, b.late, b.duplicates)
+	}
+}
+
+func TestReceiverStats(t *testing.T) {
+	s := newReceiverStats(90000)
+	now := time.Now()
+	// 25 fps across the wrap of the sequence numbers without 1, the packet 2 is 10ms late
+	for _, seq := range []uint16{65534, 65535, 0, 2, 3} {
+		arrival := now.Add(time.Duration(seq+2) * 40 * time.Millisecond)
+		if seq == 2 {
+			arrival = arrival.Add(10 * time.Millisecond)
+		}
+		s.receive(1234, seq, uint32(seq+2)*3600, arrival)
+	}
+	report := s.report(now)
+	if report.SSRC != 1234 || report.HighestSequence != 1<<16|3 || report.TotalLost != 1 || report.FractionLost != 256/6 ||
+		report.LastSR != 0 || report.DelaySinceLastSR != 0 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	// 900 units late then back on time, each difference is smoothed by 1/16
+	if expected := 900.0/16 + (900-900.0/16)/16; s.jitter != expected {
+		t.Errorf("Expected jitter %f, got %f", expected, s.jitter)
+	}
+	if report := s.report(now); report.FractionLost != 0 || report.TotalLost != 1 {
+		t.Errorf("Expected no loss in an empty interval, got %+v", report)
+	}
+
+	sr := &rtp.SenderReport{SSRC: 1234, NTPTime: rtp.NTPTime(now), RTPTime: 1000}
+	s.senderReport(sr, now)
+	if clock, ok := s.wallclock(1000 + 45000); !ok || clock.Sub(now.Add(500*time.Millisecond)).Abs() > time.Microsecond {
+		t.Errorf("Unexpected wallclock %v", clock)
+	}
+	report = s.report(now.Add(time.Second))
+	if report.LastSR != uint32(sr.NTPTime>>16) || report.DelaySinceLastSR != 65536 {
+		t.Errorf("Unexpected sender report fields %+v", report)
+	}
+
+	stats := s.stats(1, newJitterBuffer(0), 3)
+	if stats.Track != 1 || stats.Received != 5 || stats.Lost != 1 || stats.Dropped != 3 || stats.SenderRTPTime != 1000 ||
+		stats.Jitter != time.Duration(s.jitter*float64(time.Second)/90000) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// a stray packet far ahead isn't counted, two sequential ones restart the sequence (RFC 3550 A.1)
+	s.receive(1234, 40000, 0, now)
+	s.receive(1234, 4, 6*3600, now)
+	if s.maxSeq != 4 || s.received != 6 {
+		t.Errorf("Expected the stray packet to be ignored, got max %d and %d received", s.maxSeq, s.received)
+	}
+	s.receive(1234, 50000, 0, now)
+	s.receive(1234, 50001, 3600, now)
+	if report := s.report(now); report.HighestSequence != 50001 || report.TotalLost != 0 || s.received != 1 {
+		t.Errorf("Expected a restart at 50001, got %+v", report)
+	}
+}
+
+func TestRTSPStreamReceiver(t *testing.T) {
+	rtcpServer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer rtcpServer.Close()
+	interval := ReceiverReportInterval
+	ReceiverReportInterval = 50 * time.Millisecond
+	defer func() { ReceiverReportInterval = interval }()
+
+	s := NewRTSPStream(RTSPConfig{URL: "rtsp://127.0.0.1/live", JitterLatency: 50 * time.Millisecond})
+	track := &pullTrack{
+		channel:  -1,
+		received: make(chan struct{}, 1),
+		buffer:   newJitterBuffer(s.config.JitterLatency),
+		stats:    newReceiverStats(90000),
+		closed:   make(chan struct{}),
+		rtcpAddr: rtcpServer.LocalAddr().(*net.UDPAddr),
+	}
+	if track.rtcpConn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	s.tracks = []*pullTrack{track}
+	defer track.close()
+	go s.reportLoop(track, nil)
+
+	// 11 comes after 12, 13 never comes and 14 waits for the latency
+	for _, seq := range []uint16{10, 12, 11, 14} {
+		packet := rtp.Packet{PayloadType: 96, SequenceNumber: seq, Timestamp: uint32(seq) * 3600, SSRC: 5, Payload: []byte{1}}
+		s.handleRTP(track, packet.Marshal())
+	}
+	for _, expected := range []uint16{10, 11, 12, 14} {
+		select {
+		case p := <-s.GetPacketChan():
+			if p.SequenceNumber != expected {
+				t.Fatalf("Expected packet %d, got %d", expected, p.SequenceNumber)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for packet %d", expected)
+		}
+	}
+
+	sr := rtp.RTCPPacket{Type: rtp.RTCPTypeSR, Payload: binary.BigEndian.AppendUint32(nil, 5)}
+	sr.Payload = binary.BigEndian.AppendUint64(sr.Payload, rtp.NTPTime(time.Now()))
+	sr.Payload = append(sr.Payload, make([]byte, 12)...)
+	s.handleRTCP(track, sr.Marshal())
+	if _, ok := s.WallClock(0, 0); !ok {
+		t.Error("Expected the wallclock from the sender report")
+	}
+
+	// the receiver report of the track is sent to the RTCP port of the server
+	buffer := make([]byte, 1500)
+	rtcpServer.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := rtcpServer.Read(buffer)
+	if err != nil {
+		t.Fatalf("Failed to receive a receiver report: %v", err)
+	}
+	packets, err := rtp.UnmarshalRTCP(buffer[:n])
+	if err != nil || len(packets) != 1 || packets[0].Type != rtp.RTCPTypeRR || packets[0].Count != 1 ||
+		binary.BigEndian.Uint32(packets[0].Payload) != s.ssrc || binary.BigEndian.Uint32(packets[0].Payload[4:]) != 5 {
+		t.Fatalf("Unexpected receiver report %x (%v)", buffer[:n], err)
+	}
+
+	info := s.GetStreamInfo()
+	if len(info.RTPStats) != 1 || info.RTPStats[0].Received != 4 || info.RTPStats[0].Lost != 1 || info.RTPStats[0].Reordered != 1 ||
+		info.RTPStats[0].SenderTime.IsZero() || info.RTPStats[0].LastReport.IsZero() {
+		t.Errorf("Unexpected stats %+v", info.RTPStats)
+	}
+}
@@ -0,0 +1,479 @@
+package stream
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/mp4"
+)
+
+// Defaults of RecordConfig
+const (
+	DefaultRecordDir             = "/tmp/recordings"
+	DefaultRecordSegmentDuration = 10 * time.Minute
+)
+
+// recordRate is the timescale of the recorded tracks
+const recordRate = 90000
+
+// recordFragmentDuration is the duration of the fragments, cut at the next keyframe,
+// the fragment being written is lost on a crash
+const recordFragmentDuration = time.Second
+
+// recordIndexName is the index of the recordings in the directory of a stream
+const recordIndexName = "index.json"
+
+// RecordConfig configures the recording of a stream to fragmented MP4 files
+type RecordConfig struct {
+	// Dir holds a directory per stream with its recordings and their index, DefaultRecordDir when empty
+	Dir string
+	// Stream names the directory and the files of the stream, derived from the URL of the input when empty
+	Stream string
+	// SegmentDuration rotates the files at the first keyframe after it, DefaultRecordSegmentDuration when 0
+	SegmentDuration time.Duration
+	// SegmentSize rotates the files at the first keyframe above this size in bytes, unlimited when 0
+	SegmentSize int64
+	// MaxAge removes the recordings ended for longer, unlimited when 0
+	MaxAge time.Duration
+	// MaxTotalSize removes the oldest recordings of the stream above this size in bytes, unlimited when 0
+	MaxTotalSize int64
+}
+
+// Recording is a file of the recording index
+type Recording struct {
+	Stream string `json:"stream"`
+	// Path is the path of the file relative to the Dir of the config, the path of a VideoServer of Dir
+	Path  string    `json:"path"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Size  int64     `json:"size"`
+}
+
+// Recorder writes the H264 and AAC of an RTSP stream to fragmented MP4 files named by stream and start time
+type Recorder struct {
+	config RecordConfig
+	dir    string
+	reader *frameReader
+	tracks []*mp4.Track
+	// track indexes in tracks, -1 when absent
+	video, audio int
+
+	file    *os.File
+	writer  *mp4.FragmentWriter
+	current *Recording
+	// base is the DTS of the first sample of the file
+	base int64
+	// pending is the fragment being buffered by track, pendingSize its size
+	pending     [][]mp4.FragmentSample
+	pendingSize int64
+	pendingDTS  int64
+
+	index  []Recording
+	closed bool
+}
+
+// NewRecorder creates a recorder of the medias of an SDP. The index of the stream is loaded, completed with
+// the recordings interrupted by a crash, and the retention is enforced
+func NewRecorder(config RecordConfig, sdp *rtsp.SessionDescription) (*Recorder, error) {
+	if config.Dir == "" {
+		config.Dir = DefaultRecordDir
+	}
+	if config.SegmentDuration == 0 {
+		config.SegmentDuration = DefaultRecordSegmentDuration
+	}
+	config.Stream = sanitizeStreamName(config.Stream)
+	if config.Stream == "" {
+		return nil, fmt.Errorf("no stream name to record")
+	}
+	r := &Recorder{
+		config: config,
+		dir:    filepath.Join(config.Dir, config.Stream),
+		reader: newFrameReader(sdp, recordRate),
+		video:  -1,
+		audio:  -1,
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create the recording directory: %v", err)
+	}
+	if err := r.loadIndex(); err != nil {
+		return nil, err
+	}
+	if err := r.enforceRetention(); err != nil {
+		return nil, err
+	}
+	if r.reader.video >= 0 {
+		r.video = len(r.tracks)
+		r.tracks = append(r.tracks, &mp4.Track{Codec: mp4.CodecH264, TimeScale: recordRate})
+	}
+	if r.reader.audio >= 0 {
+		r.audio = len(r.tracks)
+		r.tracks = append(r.tracks, &mp4.Track{Codec: mp4.CodecAAC, TimeScale: recordRate, Config: r.reader.audioConfig})
+	}
+	if len(r.tracks) == 0 {
+		return nil, fmt.Errorf("no H264 or AAC media to record")
+	}
+	r.pending = make([][]mp4.FragmentSample, len(r.tracks))
+	return r, nil
+}
+
+// sanitizeStreamName keeps the letters, digits, '-' and '.' of a name, the others become '_'
+func sanitizeStreamName(name string) string {
+	name = strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' {
+			return c
+		}
+		return '_'
+	}, name)
+	return strings.Trim(name, "_.")
+}
+
+// recordStreamName derives the name of a stream from its RTSP URL, the host and the path without credentials
+func recordStreamName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return sanitizeStreamName(rawURL)
+	}
+	return sanitizeStreamName(u.Host + u.Path)
+}
+
+// WritePacket records a packet of the stream, the first file starts with the first keyframe
+func (r *Recorder) WritePacket(packet RTPInfo) error {
+	if r.closed {
+		return errors.New("recorder closed")
+	}
+	video, audio := r.reader.read(packet)
+	for _, frame := range video {
+		if err := r.writeVideo(frame); err != nil {
+			return err
+		}
+	}
+	for _, frame := range audio {
+		if err := r.writeAudio(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) writeVideo(frame videoFrame) error {
+	if r.video < 0 {
+		return nil
+	}
+	if frame.Keyframe {
+		duration := time.Duration(frame.DTS-r.base) * time.Second / recordRate
+		rotate := r.file != nil && (frame.ParamsChanged || duration >= r.config.SegmentDuration ||
+			r.config.SegmentSize > 0 && r.writer.Written()+r.pendingSize >= r.config.SegmentSize)
+		if rotate {
+			if err := r.closeFile(); err != nil {
+				return err
+			}
+		}
+		if r.file == nil {
+			if err := r.openFile(frame.DTS); err != nil {
+				return err
+			}
+		} else if time.Duration(frame.DTS-r.pendingDTS)*time.Second/recordRate >= recordFragmentDuration {
+			if err := r.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if r.file == nil {
+		return nil
+	}
+
+	var data []byte
+	for _, nalu := range frame.NALUs {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(nalu)))
+		data = append(data, nalu...)
+	}
+	r.add(r.video, mp4.FragmentSample{
+		Sample: mp4.Sample{DTS: uint64(frame.DTS - r.base), CTSOffset: int32(frame.PTS - frame.DTS), Keyframe: frame.Keyframe},
+		Data:   data,
+	})
+	return nil
+}
+
+func (r *Recorder) writeAudio(frame audioFrame) error {
+	if r.audio < 0 {
+		return nil
+	}
+	// audio-only streams are cut at any frame, otherwise at the keyframes
+	if r.video < 0 {
+		if r.file != nil && time.Duration(frame.PTS-r.base)*time.Second/recordRate >= r.config.SegmentDuration {
+			if err := r.closeFile(); err != nil {
+				return err
+			}
+		}
+		if r.file == nil {
+			if err := r.openFile(frame.PTS); err != nil {
+				return err
+			}
+		} else if time.Duration(frame.PTS-r.pendingDTS)*time.Second/recordRate >= recordFragmentDuration {
+			if err := r.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	// the audio before the first keyframe of the file is dropped
+	if r.file == nil || frame.PTS < r.base {
+		return nil
+	}
+	r.add(r.audio, mp4.FragmentSample{Sample: mp4.Sample{DTS: uint64(frame.PTS - r.base), Keyframe: true}, Data: frame.Data})
+	return nil
+}
+
+func (r *Recorder) add(track int, sample mp4.FragmentSample) {
+	if r.pendingSize == 0 {
+		r.pendingDTS = int64(sample.DTS) + r.base
+	}
+	r.pending[track] = append(r.pending[track], sample)
+	r.pendingSize += int64(len(sample.Data))
+}
+
+// openFile starts a file with the sample at dts
+func (r *Recorder) openFile(dts int64) error {
+	if r.video >= 0 {
+		r.tracks[r.video].SPS = [][]byte{r.reader.sps}
+		r.tracks[r.video].PPS = [][]byte{r.reader.pps}
+		r.tracks[r.video].Width, r.tracks[r.video].Height = 0, 0
+	}
+	start := r.reader.start.Add(time.Duration(dts) * time.Second / recordRate)
+	name := fmt.Sprintf("%s_%s.mp4", r.config.Stream, start.UTC().Format("20060102T150405.000Z"))
+	file, err := os.Create(filepath.Join(r.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %v", err)
+	}
+	writer, err := mp4.NewFragmentWriter(file, r.tracks)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	r.file, r.writer, r.base, r.pendingSize = file, writer, dts, 0
+	r.current = &Recording{
+		Stream: r.config.Stream,
+		Path:   filepath.ToSlash(filepath.Join(r.config.Stream, name)),
+		Start:  start,
+		End:    start,
+		Size:   writer.Written(),
+	}
+	// the index lists the file being written so that it's found after a crash
+	r.index = append(r.index, *r.current)
+	return r.writeIndex()
+}
+
+// flush writes the pending samples as a fragment
+func (r *Recorder) flush() error {
+	if r.pendingSize == 0 {
+		return nil
+	}
+	// the last sample lasts as long as the previous one, like in the fragment
+	end := int64(0)
+	for _, samples := range r.pending {
+		if n := len(samples); n > 1 {
+			end = max(end, int64(2*samples[n-1].DTS-samples[n-2].DTS))
+		} else if n == 1 {
+			end = max(end, int64(samples[0].DTS))
+		}
+	}
+	err := r.writer.WriteFragment(r.pending)
+	for i := range r.pending {
+		r.pending[i] = nil
+	}
+	r.pendingSize = 0
+	if err != nil {
+		return fmt.Errorf("failed to write recording: %v", err)
+	}
+	r.current.End = r.current.Start.Add(time.Duration(end) * time.Second / recordRate)
+	r.current.Size = r.writer.Written()
+	return nil
+}
+
+// closeFile writes the last fragment of the file, indexes it and enforces the retention
+func (r *Recorder) closeFile() error {
+	err := r.flush()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.index[len(r.index)-1] = *r.current
+	r.file, r.writer, r.current = nil, nil, nil
+	if err != nil {
+		return err
+	}
+	if err := r.enforceRetention(); err != nil {
+		return err
+	}
+	return r.writeIndex()
+}
+
+// Reset ends the current file after an error of WritePacket, the recording continues in a new file
+// from the next keyframe
+func (r *Recorder) Reset() error {
+	if r.file == nil {
+		for i := range r.pending {
+			r.pending[i] = nil
+		}
+		r.pendingSize = 0
+		return nil
+	}
+	return r.closeFile()
+}
+
+// recording reports whether a file is being written
+func (r *Recorder) recording() bool {
+	return r.file != nil
+}
+
+// Close ends the current file
+func (r *Recorder) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	return r.closeFile()
+}
+
+// Recordings returns the recordings overlapping [from, to] by start time, a zero time leaves the bound open
+func (r *Recorder) Recordings(from, to time.Time) []Recording {
+	var res []Recording
+	for _, recording := range r.index {
+		if r.current != nil && recording.Path == r.current.Path {
+			recording = *r.current
+		}
+		if (from.IsZero() || !recording.End.Before(from)) && (to.IsZero() || !recording.Start.After(to)) {
+			res = append(res, recording)
+		}
+	}
+	return res
+}
+
+// enforceRetention removes the recordings older than MaxAge, then the oldest ones above MaxTotalSize,
+// the file being written is kept
+func (r *Recorder) enforceRetention() error {
+	total := int64(0)
+	for _, recording := range r.index {
+		total += recording.Size
+	}
+	kept := r.index[:0]
+	removed := false
+	for i, recording := range r.index {
+		current := r.current != nil && recording.Path == r.current.Path
+		expired := r.config.MaxAge > 0 && time.Since(recording.End) > r.config.MaxAge
+		overQuota := r.config.MaxTotalSize > 0 && total > r.config.MaxTotalSize
+		if current || !expired && !overQuota {
+			kept = append(kept, r.index[i])
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.config.Dir, filepath.FromSlash(recording.Path))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove recording: %v", err)
+		}
+		total -= recording.Size
+		removed = true
+	}
+	r.index = kept
+	if removed {
+		return r.writeIndex()
+	}
+	return nil
+}
+
+// loadIndex reads the index of the stream, dropping the missing files and adding the files it lacks.
+// A file without end was interrupted, its end is recovered from its fragments
+func (r *Recorder) loadIndex() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, recordIndexName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read the recording index: %v", err)
+	}
+	var index []Recording
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &index); err != nil {
+			return fmt.Errorf("invalid recording index: %v", err)
+		}
+	}
+	indexed := map[string]Recording{}
+	for _, recording := range index {
+		indexed[filepath.Base(recording.Path)] = recording
+	}
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	prefix := r.config.Stream + "_"
+	changed := len(index) == 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || filepath.Ext(name) != ".mp4" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		recording, ok := indexed[name]
+		if !ok || !recording.End.After(recording.Start) || recording.Size != info.Size() {
+			start, err := time.Parse("20060102T150405.000Z", strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".mp4"))
+			if err != nil {
+				continue
+			}
+			recording = Recording{
+				Stream: r.config.Stream,
+				Path:   filepath.ToSlash(filepath.Join(r.config.Stream, name)),
+				Start:  start,
+				End:    recordingEnd(filepath.Join(r.dir, name), start, info.ModTime()),
+				Size:   info.Size(),
+			}
+			changed = true
+		}
+		r.index = append(r.index, recording)
+	}
+	sort.Slice(r.index, func(i, j int) bool { return r.index[i].Start.Before(r.index[j].Start) })
+	if changed || len(r.index) != len(index) {
+		return r.writeIndex()
+	}
+	return nil
+}
+
+// recordingEnd returns the end of the last sample of a recording, or its modification time when it can't be parsed
+func recordingEnd(path string, start, modTime time.Time) time.Time {
+	f, err := mp4.Open(path)
+	if err != nil {
+		return modTime
+	}
+	defer f.Close()
+	end := start
+	for _, t := range f.Tracks {
+		if t.TimeScale > 0 {
+			end = maxTime(end, start.Add(time.Duration(t.Duration)*time.Second/time.Duration(t.TimeScale)))
+		}
+	}
+	return end
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (r *Recorder) writeIndex() error {
+	data, err := json.MarshalIndent(r.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(r.dir, recordIndexName), data)
+}
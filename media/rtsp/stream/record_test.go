@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mediahttp "github.com/wwqdrh/gokit/media/http"
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/mp4"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	sdp := &rtsp.SessionDescription{Medias: []rtsp.MediaDescription{
+		{Type: "video", PayloadType: 96, Encoding: "H264", ClockRate: 90000, Fmtp: "packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO48"},
+		{Type: "audio", PayloadType: 97, Encoding: "MPEG4-GENERIC", ClockRate: 8000, Fmtp: "streamtype=5;mode=AAC-hbr;config=1588"},
+	}}
+	config := RecordConfig{Dir: dir, Stream: "cam/1", SegmentDuration: 4 * time.Second}
+	r, err := NewRecorder(config, sdp)
+	if err != nil {
+		t.Fatalf("Failed to create the recorder: %v", err)
+	}
+
+	// 14s at 25 fps with a keyframe every second, 8 AAC frames a second
+	video := &rtp.Packetizer{PayloadType: 96, SSRC: 1}
+	audio := &rtp.Packetizer{PayloadType: 97, SSRC: 2}
+	for i := 0; i < 14*25; i++ {
+		nalu := []byte{0x41, byte(i)}
+		if i%25 == 0 {
+			nalu = append([]byte{0x65}, make([]byte, 500)...)
+		}
+		for _, p := range video.Packetize(rtp.H264Payloads([][]byte{nalu}, 1400), uint32(3600*i)) {
+			if err := r.WritePacket(RTPInfo{Track: 0, SequenceNumber: p.SequenceNumber, Timestamp: p.Timestamp, Marker: p.Marker, Payload: p.Payload}); err != nil {
+				t.Fatalf("Failed to write video: %v", err)
+			}
+		}
+		if i%25%3 == 0 && i%25 != 24 {
+			p := audio.Packetize(rtp.AACPayloads([][]byte{{0x21, byte(i)}}, 1400), uint32(1000*(i/25*8+i%25/3)))[0]
+			if err := r.WritePacket(RTPInfo{Track: 1, SequenceNumber: p.SequenceNumber, Timestamp: p.Timestamp, Marker: p.Marker, Payload: p.Payload}); err != nil {
+				t.Fatalf("Failed to write audio: %v", err)
+			}
+		}
+	}
+
+	// the live file is listed with the fragments written so far
+	recordings := r.Recordings(time.Time{}, time.Time{})
+	if len(recordings) != 4 || recordings[3].End.Sub(recordings[3].Start).Round(10*time.Millisecond) != time.Second {
+		t.Fatalf("Unexpected recordings %+v", recordings)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if r.WritePacket(RTPInfo{Track: 0}) == nil {
+		t.Error("Expected an error after Close")
+	}
+
+	recordings = r.Recordings(time.Time{}, time.Time{})
+	start := recordings[0].Start
+	for i, recording := range recordings {
+		duration := 4 * time.Second
+		if i == 3 {
+			duration = 2 * time.Second
+		}
+		if recording.Stream != "cam_1" || !strings.HasPrefix(recording.Path, "cam_1/cam_1_") ||
+			recording.Start != start.Add(time.Duration(i)*4*time.Second) || recording.End.Sub(recording.Start).Round(10*time.Millisecond) != duration {
+			t.Errorf("Unexpected recording %d %+v", i, recording)
+		}
+		info, err := os.Stat(filepath.Join(dir, recording.Path))
+		if err != nil || info.Size() != recording.Size {
+			t.Errorf("Unexpected file of %+v: %v", recording, err)
+		}
+	}
+	if got := r.Recordings(start.Add(5*time.Second), start.Add(9*time.Second)); len(got) != 2 || got[0] != recordings[1] || got[1] != recordings[2] {
+		t.Errorf("Unexpected recordings of [5s, 9s] %+v", got)
+	}
+
+	f, err := mp4.Open(filepath.Join(dir, recordings[1].Path))
+	if err != nil {
+		t.Fatalf("Failed to open the recording: %v", err)
+	}
+	defer f.Close()
+	if len(f.Tracks) != 2 || len(f.Tracks[0].Samples) != 100 || !f.Tracks[0].Samples[0].Keyframe || f.Tracks[0].Samples[1].DTS != 3600 ||
+		f.Tracks[1].Codec != mp4.CodecAAC || f.Tracks[1].SampleRate != 8000 {
+		t.Errorf("Unexpected tracks %+v", f.Tracks)
+	}
+
+	// the index is rebuilt from the files
+	data, err := os.ReadFile(filepath.Join(dir, "cam_1", recordIndexName))
+	if err != nil {
+		t.Fatalf("Failed to read the index: %v", err)
+	}
+	var index []Recording
+	if err := json.Unmarshal(data, &index); err != nil || len(index) != 4 {
+		t.Fatalf("Unexpected index %s: %v", data, err)
+	}
+	os.Remove(filepath.Join(dir, "cam_1", recordIndexName))
+	config.MaxTotalSize = recordings[2].Size + recordings[3].Size
+	r, err = NewRecorder(config, sdp)
+	if err != nil {
+		t.Fatalf("Failed to reopen the recorder: %v", err)
+	}
+	got := r.Recordings(time.Time{}, time.Time{})
+	if len(got) != 2 || got[0].Path != recordings[2].Path || got[1].Path != recordings[3].Path ||
+		got[1].End.Sub(got[1].Start).Round(10*time.Millisecond) != 2*time.Second {
+		t.Errorf("Expected the 2 last recordings within the quota, got %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, recordings[1].Path)); !os.IsNotExist(err) {
+		t.Errorf("Expected the recording to be removed, got %v", err)
+	}
+	got[0].Start, got[0].End = got[0].Start.Add(-time.Hour), got[0].End.Add(-time.Hour)
+	data, _ = json.Marshal(got)
+	os.WriteFile(filepath.Join(dir, "cam_1", recordIndexName), data, 0644)
+	config.MaxAge = time.Minute
+	if r, err = NewRecorder(config, sdp); err != nil || len(r.Recordings(time.Time{}, time.Time{})) != 1 {
+		t.Errorf("Expected the expired recording to be removed: %v", err)
+	}
+
+	// the recordings are played by the video server with range requests
+	server := httptest.NewServer(&mediahttp.VideoServer{VideoDir: dir})
+	defer server.Close()
+	r, _ = NewRecorder(RecordConfig{Dir: dir, Stream: "cam_2"}, sdp)
+	for i := 0; i < 50; i++ {
+		for _, p := range video.Packetize(rtp.H264Payloads([][]byte{{0x65, byte(i)}}, 1400), uint32(3600*i)) {
+			r.WritePacket(RTPInfo{Track: 0, SequenceNumber: p.SequenceNumber, Timestamp: p.Timestamp, Marker: p.Marker, Payload: p.Payload})
+		}
+	}
+	r.Close()
+	recording := r.Recordings(time.Time{}, time.Time{})[0]
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/video/"+recording.Path, nil)
+	request.Header.Set("Range", "bytes=0-7")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to request the recording: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusPartialContent || response.Header.Get("Content-Type") != "video/mp4" || string(body[4:]) != "ftyp" {
+		t.Errorf("Unexpected response %d %v %q", response.StatusCode, response.Header, body)
+	}
+}
+
+func TestTranscodedStreamRecordError(t *testing.T) {
+	dir := t.TempDir()
+	input := NewRTSPStream(RTSPConfig{URL: "rtsp://127.0.0.1/cam"})
+	input.sdp = &rtsp.SessionDescription{Medias: []rtsp.MediaDescription{
+		{Type: "video", PayloadType: 96, Encoding: "H264", ClockRate: 90000, Fmtp: "packetization-mode=1;sprop-parameter-sets=Z2QAH6w=,aO48"},
+	}}
+	s := NewTranscodedStream(input, StreamTypeRecord)
+	s.recordConfig = RecordConfig{Dir: dir, Stream: "cam", SegmentDuration: time.Second}
+	s.Start()
+	defer s.Stop()
+
+	// a keyframe every second
+	video := &rtp.Packetizer{PayloadType: 96, SSRC: 1}
+	second := 0
+	write := func() {
+		for i := 0; i < 25; i++ {
+			nalu := []byte{0x41, byte(i)}
+			if i == 0 {
+				nalu = []byte{0x65, byte(second)}
+			}
+			for _, p := range video.Packetize(rtp.H264Payloads([][]byte{nalu}, 1400), uint32(3600*(25*second+i))) {
+				s.writeRecord(RTPInfo{Track: 0, SequenceNumber: p.SequenceNumber, Timestamp: p.Timestamp, Marker: p.Marker, Payload: p.Payload})
+			}
+		}
+		second++
+	}
+	write()
+	write()
+	if info := s.GetStreamInfo(); info.RecordError != "" {
+		t.Fatalf("Unexpected error %s", info.RecordError)
+	}
+
+	// the files can't be written without their directory, the recording goes on once it's back
+	os.RemoveAll(filepath.Join(dir, "cam"))
+	write()
+	write()
+	if info := s.GetStreamInfo(); info.RecordError == "" {
+		t.Fatal("Expected the recording error")
+	}
+	os.MkdirAll(filepath.Join(dir, "cam"), 0755)
+	write()
+	if info := s.GetStreamInfo(); info.RecordError != "" {
+		t.Errorf("Expected the recording to start again, got %s", info.RecordError)
+	}
+	write()
+	if files, _ := filepath.Glob(filepath.Join(dir, "cam", "*.mp4")); len(files) != 2 {
+		t.Errorf("Expected the 2 files recorded after the error, got %v", files)
+	}
+}
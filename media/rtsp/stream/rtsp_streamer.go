@@ -2,17 +2,27 @@ package stream
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/wwqdrh/gokit/media/rtsp"
 	"github.com/wwqdrh/gokit/media/rtsp/client"
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
 )
 
 // RTPInfo contains RTP packet information
 type RTPInfo struct {
+	// Track is the index of the media in the SDP
+	Track          int
 	SequenceNumber uint16
 	Timestamp      uint32
 	SSRC           uint32
@@ -24,26 +34,53 @@ type RTPInfo struct {
 // RTSPStream implements RTSPStreamer interface
 type RTSPStream struct {
 	config     RTSPConfig
-	client     *client.Client
-	conn       net.Conn
-	rtpConn    net.Conn
-	rtcpConn   net.Conn
-	transport  string
+	// pullSession is the session playing the stream, replaced by the reconnections
+	pullSession
 	streamInfo StreamInfo
 	running    bool
 	mu         sync.Mutex
 	ctx        context.Context
 	cancel     context.CancelFunc
 	packetChan chan RTPInfo
+	// packetMu guards sending to packetChan against closing it
+	packetMu   sync.RWMutex
+	stopped    bool
+	// lastPacket is the time of the last packet in unix nanoseconds
+	lastPacket atomic.Int64
 	clientCount int
+	// ssrc is the SSRC of the RTCP packets sent to the server
+	ssrc       uint32
 }
 
+// pullSession is an RTSP session pulling the stream, it's built without holding the lock of the stream
+type pullSession struct {
+	client *client.Client
+	// url is the URL of the config without credentials
+	url         string
+	sdp         *rtsp.SessionDescription
+	contentBase string
+	tracks      []*pullTrack
+	transport   string
+	received    chan struct{}
+	// keepaliveMethod keeps the session alive, GET_PARAMETER when the server supports it
+	keepaliveMethod rtsp.Method
+}
+
+// Transports of RTSPConfig, automatic when empty
+const (
+	TransportAuto = "auto"
+	TransportUDP  = "udp"
+	TransportTCP  = "tcp"
+)
+
+// UDPTimeout is the time to wait for the first RTP packet over UDP before falling back to TCP
+var UDPTimeout = 3 * time.Second
+
 // NewRTSPStream creates a new RTSP streamer
 func NewRTSPStream(config RTSPConfig) *RTSPStream {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &RTSPStream{
 		config:     config,
-		client:     client.NewClient(),
 		streamInfo: StreamInfo{
 			URL:        config.URL,
 			StreamType: StreamTypeRTSP,
@@ -53,86 +90,157 @@ func NewRTSPStream(config RTSPConfig) *RTSPStream {
 		ctx:        ctx,
 		cancel:     cancel,
 		packetChan: make(chan RTPInfo, 1024),
+		ssrc:       rand.Uint32(),
 	}
 }
 
-// Start starts the RTSP streamer
+// Start starts the RTSP streamer, retrying MaxRetries times after the first attempt.
+// Once playing, the stream is supervised and reconnected when it stalls or its session is lost
 func (s *RTSPStream) Start() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.running {
+	running := s.running
+	s.mu.Unlock()
+	if running {
 		return nil
 	}
 
+	s.setState(StateConnecting, nil, 0)
+	if err := s.retry(StateConnecting, 1+max(s.config.MaxRetries, 0)); err != nil {
+		s.setState(StateFailed, err, 0)
+		return err
+	}
+
+	s.mu.Lock()
+	if err := s.ctx.Err(); err != nil {
+		// stopped once connected
+		old := s.pullSession
+		s.pullSession = pullSession{}
+		s.mu.Unlock()
+		old.close()
+		return err
+	}
+	s.running = true
+	s.streamInfo.LastActive = time.Now()
+	s.mu.Unlock()
+	s.setState(StatePlaying, nil, 0)
+	go s.supervise()
+	return nil
+}
+
+// open connects p to the RTSP server and describes the stream, s.mu isn't held
+func (s *RTSPStream) open(p *pullSession) error {
 	// Parse RTSP URL to get address
 	addr := s.parseRTSPAddress(s.config.URL)
 	if addr == "" {
 		return fmt.Errorf("invalid RTSP URL: %s", s.config.URL)
 	}
 
-	// Connect to RTSP server
-	if err := s.client.Connect(addr); err != nil {
+	// Connect to RTSP server, answering challenges with the credentials of the config or the URL
+	p.client = client.NewClient()
+	p.url = s.config.URL
+	username, password := s.config.Username, s.config.Password
+	if u, err := url.Parse(s.config.URL); err == nil && u.User != nil {
+		if username == "" {
+			username = u.User.Username()
+			password, _ = u.User.Password()
+		}
+		u.User = nil
+		p.url = u.String()
+	}
+	if username != "" {
+		p.client.SetCredentials(username, password)
+	}
+	if err := p.client.ConnectContext(s.ctx, addr); err != nil {
 		return fmt.Errorf("failed to connect to RTSP server: %v", err)
 	}
 
 	// Send OPTIONS request
-	_, err := s.client.Options()
+	response, err := p.client.Options()
 	if err != nil {
-		s.client.Close()
+		p.client.Close()
 		return fmt.Errorf("failed to send OPTIONS: %v", err)
 	}
+	p.keepaliveMethod = rtsp.MethodOPTIONS
+	for _, method := range strings.Split(response.Header.Get("Public"), ",") {
+		if rtsp.Method(strings.TrimSpace(method)) == rtsp.MethodGET_PARAMETER {
+			p.keepaliveMethod = rtsp.MethodGET_PARAMETER
+		}
+	}
 
 	// Send DESCRIBE request
-	response, err := s.client.Describe(s.config.URL)
+	response, err = p.client.Describe(p.url)
 	if err != nil {
-		s.client.Close()
+		p.client.Close()
 		return fmt.Errorf("failed to send DESCRIBE: %v", err)
 	}
+	if response.StatusCode != rtsp.StatusOK {
+		p.client.Close()
+		return fmt.Errorf("DESCRIBE failed: %d %s", response.StatusCode, response.StatusText)
+	}
 
 	// Parse SDP to get stream information
+	s.mu.Lock()
 	s.parseSDP(string(response.Body))
-
-	// Start pulling the stream
-	if err := s.Pull(); err != nil {
-		s.client.Close()
-		return fmt.Errorf("failed to start pulling stream: %v", err)
+	s.mu.Unlock()
+	p.sdp, err = rtsp.ParseSDP(response.Body)
+	if err != nil {
+		p.client.Close()
+		return fmt.Errorf("failed to parse SDP: %v", err)
+	}
+	p.contentBase = response.Header.Get("Content-Base")
+	if p.contentBase == "" {
+		p.contentBase = p.url
 	}
-
-	s.running = true
-	s.streamInfo.LastActive = time.Now()
 	return nil
 }
 
-// Stop stops the RTSP streamer
+// Stop stops the RTSP streamer, a Start in progress fails
 func (s *RTSPStream) Stop() error {
+	// the attempts in progress give up and their sessions aren't installed once canceled
+	s.cancel()
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	running := s.running
+	old := s.shutdown()
+	s.mu.Unlock()
+	old.close()
+	if running {
+		s.setState(StateStopped, nil, 0)
+	}
+	return nil
+}
 
+// shutdown closes the packet channel and returns the session to close once s.mu is released, s.mu is held
+func (s *RTSPStream) shutdown() pullSession {
 	if !s.running {
-		return nil
+		return pullSession{}
 	}
 
 	s.cancel()
 
-	// Send TEARDOWN request
-	if s.client != nil {
-		_, _ = s.client.Teardown(s.config.URL)
-		_ = s.client.Close()
-	}
-
-	// Close RTP/RTCP connections
-	if s.rtpConn != nil {
-		_ = s.rtpConn.Close()
-	}
-	if s.rtcpConn != nil {
-		_ = s.rtcpConn.Close()
-	}
+	// The session is torn down by the caller
+	old := s.pullSession
+	s.pullSession = pullSession{}
 
+	s.packetMu.Lock()
+	s.stopped = true
 	close(s.packetChan)
+	s.packetMu.Unlock()
+
 	s.running = false
 	s.streamInfo.LastActive = time.Now()
-	return nil
+	return old
+}
+
+// close tears down the RTSP session and closes its connections
+func (p *pullSession) close() {
+	if p.client != nil {
+		_, _ = p.client.Teardown(p.url)
+		_ = p.client.Close()
+	}
+	for _, track := range p.tracks {
+		track.close()
+	}
+	p.tracks = nil
 }
 
 // IsRunning returns whether the streamer is running
@@ -146,54 +254,143 @@ func (s *RTSPStream) IsRunning() bool {
 func (s *RTSPStream) GetStreamInfo() StreamInfo {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.streamInfo
+	info := s.streamInfo
+	if last := time.Unix(0, s.lastPacket.Load()); last.After(info.LastActive) {
+		info.LastActive = last
+	}
+	info.RTPStats = nil
+	for _, track := range s.tracks {
+		track.mu.Lock()
+		info.RTPStats = append(info.RTPStats, track.stats.stats(track.index, track.buffer, track.dropped))
+		track.mu.Unlock()
+	}
+	return info
 }
 
-// Pull starts pulling the RTSP stream
-func (s *RTSPStream) Pull() error {
-	// Find available client ports for RTP/RTCP
-	rtpPort, rtcpPort, err := s.findAvailablePorts()
-	if err != nil {
-		return err
+// WallClock returns the wallclock time of a timestamp of a track from the last sender report of the server,
+// false before the first one
+func (s *RTSPStream) WallClock(track int, timestamp uint32) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tracks {
+		if t.index == track {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return t.stats.wallclock(timestamp)
+		}
 	}
+	return time.Time{}, false
+}
 
-	// Create transport string
-	transport := fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", rtpPort, rtcpPort)
+// Pull starts pulling the RTSP stream in a new session replacing the current one, see connect
+func (s *RTSPStream) Pull() error {
+	return s.connect()
+}
 
-	// Send SETUP request
-	response, err := s.client.Setup(s.config.URL, transport)
-	if err != nil {
-		return err
+// play sets up every media of the SDP of p with the transport of the configuration and plays them.
+// When it's automatic, RTP over UDP is preferred and the stream is pulled again interleaved on the RTSP connection
+// if the server refuses UDP or no packet arrives in UDPTimeout
+func (s *RTSPStream) play(p *pullSession) error {
+	switch strings.ToLower(s.config.Transport) {
+	case TransportUDP:
+		return s.pull(p, false)
+	case TransportTCP:
+		return s.pull(p, true)
 	}
 
-	// Parse transport response
-	s.transport = response.Header.Get("Transport")
+	udpErr := s.pull(p, false)
+	if udpErr == nil {
+		select {
+		case <-p.received:
+			return nil
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-time.After(UDPTimeout):
+			udpErr = fmt.Errorf("no RTP packet in %v", UDPTimeout)
+		}
+	}
+	p.close()
+	if err := s.open(p); err != nil {
+		return fmt.Errorf("failed to reconnect for TCP after UDP failed (%v): %v", udpErr, err)
+	}
+	return s.pull(p, true)
+}
 
-	// Create RTP and RTCP listeners
-	rtpListener, err := net.ListenUDP("udp", &net.UDPAddr{Port: rtpPort})
-	if err != nil {
-		return fmt.Errorf("failed to create RTP listener: %v", err)
+// pull sets up the medias of p over UDP or TCP then plays them
+func (s *RTSPStream) pull(p *pullSession, tcp bool) error {
+	if p.sdp == nil || len(p.sdp.Medias) == 0 {
+		return fmt.Errorf("no media to set up")
 	}
-	s.rtpConn = rtpListener
+	p.received = make(chan struct{}, 1)
+
+	for i, media := range p.sdp.Medias {
+		track := &pullTrack{
+			index:    i,
+			media:    media,
+			channel:  -1,
+			received: p.received,
+			buffer:   newJitterBuffer(s.config.JitterLatency),
+			stats:    newReceiverStats(media.ClockRate),
+			closed:   make(chan struct{}),
+		}
+		var transport string
+		if tcp {
+			transport = fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", 2*i, 2*i+1)
+		} else {
+			if err := track.listen(); err != nil {
+				return err
+			}
+			transport = fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", track.rtpPort(), track.rtpPort()+1)
+		}
+		p.tracks = append(p.tracks, track)
 
-	rtcpListener, err := net.ListenUDP("udp", &net.UDPAddr{Port: rtcpPort})
-	if err != nil {
-		rtpListener.Close()
-		return fmt.Errorf("failed to create RTCP listener: %v", err)
+		// Send SETUP request
+		response, err := p.client.Setup(rtsp.ControlURL(p.contentBase, media.Control), transport)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode != rtsp.StatusOK {
+			return fmt.Errorf("SETUP failed: %d %s", response.StatusCode, response.StatusText)
+		}
+
+		// Parse transport response
+		p.transport = response.Header.Get("Transport")
+		t, err := rtsp.ParseTransport(p.transport)
+		if tcp {
+			track.channel = 2 * i
+			if err == nil && t.IsTCP() && t.Interleaved[0] >= 0 {
+				track.channel = t.Interleaved[0]
+			}
+		} else if err == nil && t.ServerPort[1] > 0 {
+			host, _, _ := net.SplitHostPort(s.parseRTSPAddress(s.config.URL))
+			track.rtcpAddr, _ = net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(t.ServerPort[1])))
+		}
 	}
-	s.rtcpConn = rtcpListener
+
+	// Responses are read in the background as interleaved frames may come before them
+	tracks := p.tracks
+	p.client.SetFrameHandler(func(frame *rtsp.InterleavedFrame) {
+		s.handleFrame(tracks, frame)
+	})
+	p.client.StartReading()
 
 	// Send PLAY request
-	_, err = s.client.Play(s.config.URL)
+	response, err := p.client.Play(p.url)
 	if err != nil {
-		rtpListener.Close()
-		rtcpListener.Close()
 		return fmt.Errorf("failed to send PLAY: %v", err)
 	}
+	if response.StatusCode != rtsp.StatusOK {
+		return fmt.Errorf("PLAY failed: %d %s", response.StatusCode, response.StatusText)
+	}
 
 	// Start packet processing goroutines
-	go s.processRTPPackets()
-	go s.processRTCPPackets()
+	for _, track := range p.tracks {
+		if track.rtpConn != nil {
+			go s.processRTPPackets(track)
+			go s.processRTCPPackets(track)
+		}
+		go s.reportLoop(track, p.client)
+	}
 
 	return nil
 }
@@ -203,6 +400,20 @@ func (s *RTSPStream) GetRTSPConfig() RTSPConfig {
 	return s.config
 }
 
+// GetTransport returns the Transport header negotiated by Pull
+func (s *RTSPStream) GetTransport() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transport
+}
+
+// GetSessionDescription returns the SDP of the DESCRIBE, nil before Pull
+func (s *RTSPStream) GetSessionDescription() *rtsp.SessionDescription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sdp
+}
+
 // GetPacketChan returns the RTP packet channel
 func (s *RTSPStream) GetPacketChan() chan RTPInfo {
 	return s.packetChan
@@ -226,114 +437,200 @@ func (s *RTSPStream) DecrementClientCount() {
 	}
 }
 
-// processRTPPackets processes RTP packets
-func (s *RTSPStream) processRTPPackets() {
-	buffer := make([]byte, 1500)
+// RequestKeyframe asks the server for a keyframe of the H264 media with a PLI (RFC 4585),
+// servers without feedback support ignore it
+func (s *RTSPStream) RequestKeyframe() error {
+	s.mu.Lock()
+	tracks, c := s.tracks, s.client
+	s.mu.Unlock()
+	for _, track := range tracks {
+		if !strings.EqualFold(track.media.Encoding, "H264") {
+			continue
+		}
+		ssrc := track.ssrc.Load()
+		if ssrc == 0 {
+			return fmt.Errorf("no packet received yet")
+		}
+		// a feedback packet is sent in a compound packet starting with a report
+		rr := rtp.RTCPPacket{Type: rtp.RTCPTypeRR, Payload: binary.BigEndian.AppendUint32(nil, s.ssrc)}
+		data := append(rr.Marshal(), rtp.MarshalPLI(s.ssrc, ssrc)...)
+		if track.channel >= 0 {
+			return c.WriteFrame(uint8(track.channel+1), data)
+		}
+		if track.rtcpConn == nil || track.rtcpAddr == nil {
+			return fmt.Errorf("no RTCP port of the server")
+		}
+		_, err := track.rtcpConn.WriteToUDP(data, track.rtcpAddr)
+		return err
+	}
+	return fmt.Errorf("no H264 media")
+}
+
+// processRTPPackets processes RTP packets of a track over UDP
+func (s *RTSPStream) processRTPPackets(track *pullTrack) {
+	buffer := make([]byte, 65536)
 	for {
-		select {
-		case <-s.ctx.Done():
-			return
-		default:
-			if s.rtpConn == nil {
+		n, _, err := track.rtpConn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || s.ctx.Err() != nil {
 				return
 			}
+			continue
+		}
+		s.handleRTP(track, append([]byte{}, buffer[:n]...))
+	}
+}
 
-			n, _, err := s.rtpConn.(*net.UDPConn).ReadFromUDP(buffer)
-			if err != nil {
-				continue
+// processRTCPPackets processes RTCP packets of a track over UDP
+func (s *RTSPStream) processRTCPPackets(track *pullTrack) {
+	buffer := make([]byte, 1500)
+	for {
+		n, _, err := track.rtcpConn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || s.ctx.Err() != nil {
+				return
 			}
+			continue
+		}
+		s.handleRTCP(track, buffer[:n])
+	}
+}
 
-			if n < 12 {
-				continue
+// handleRTCP records the sender reports of a track
+func (s *RTSPStream) handleRTCP(track *pullTrack, data []byte) {
+	packets, err := rtp.UnmarshalRTCP(data)
+	if err != nil {
+		return
+	}
+	for _, packet := range packets {
+		if sr, err := packet.SenderReport(); err == nil {
+			track.mu.Lock()
+			if sr.SSRC == track.stats.ssrc {
+				track.stats.senderReport(sr, time.Now())
 			}
+			track.mu.Unlock()
+		}
+	}
+}
 
-			// Parse RTP header
-			header := buffer[:12]
-			version := (header[0] >> 6) & 0x03
-			if version != 2 {
+// reportLoop sends the receiver reports of a track until it's closed, over UDP to the RTCP port of the server
+// or on the interleaved RTCP channel
+func (s *RTSPStream) reportLoop(track *pullTrack, c *client.Client) {
+	ticker := time.NewTicker(ReceiverReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-track.closed:
+			return
+		case now := <-ticker.C:
+			track.mu.Lock()
+			if !track.stats.started {
+				track.mu.Unlock()
 				continue
 			}
-
-			padding := (header[0] >> 5) & 0x01
-			extension := (header[0] >> 4) & 0x01
-			csrcCount := header[0] & 0x0f
-			marker := (header[1] >> 7) & 0x01
-			payloadType := header[1] & 0x7f
-
-			sequenceNumber := uint16(header[2])<<8 | uint16(header[3])
-			timestamp := uint32(header[4])<<24 | uint32(header[5])<<16 | uint32(header[6])<<8 | uint32(header[7])
-			ssrc := uint32(header[8])<<24 | uint32(header[9])<<16 | uint32(header[10])<<8 | uint32(header[11])
-
-			// Calculate payload start and end
-			payloadStart := 12 + int(csrcCount)*4
-			if extension != 0 {
-				extensionLength := uint16(buffer[payloadStart])<<8 | uint16(buffer[payloadStart+1])
-				payloadStart += 2 + int(extensionLength)*4
-			}
-
-			if payloadStart >= n {
-				continue
+			data := rtp.MarshalReceiverReport(s.ssrc, []rtp.ReceptionReport{track.stats.report(now)})
+			track.mu.Unlock()
+			if track.channel >= 0 {
+				_ = c.WriteFrame(uint8(track.channel+1), data)
+			} else if track.rtcpConn != nil && track.rtcpAddr != nil {
+				_, _ = track.rtcpConn.WriteToUDP(data, track.rtcpAddr)
 			}
+		}
+	}
+}
 
-			payloadEnd := n
-			if padding != 0 {
-				paddingSize := int(buffer[n-1])
-				if paddingSize > 0 && paddingSize < payloadEnd-payloadStart {
-					payloadEnd -= paddingSize
-				}
-			}
+// handleFrame dispatches the interleaved frames of the RTSP connection
+func (s *RTSPStream) handleFrame(tracks []*pullTrack, frame *rtsp.InterleavedFrame) {
+	for _, track := range tracks {
+		if track.channel < 0 {
+			continue
+		}
+		// RTCP is on the odd channel after RTP
+		switch int(frame.Channel) {
+		case track.channel:
+			s.handleRTP(track, frame.Payload)
+			return
+		case track.channel + 1:
+			s.handleRTCP(track, frame.Payload)
+			return
+		}
+	}
+}
 
-			// Create RTP info
-		rtpInfo := RTPInfo{
-				SequenceNumber: sequenceNumber,
-				Timestamp:      timestamp,
-				SSRC:           ssrc,
-				PayloadType:    payloadType,
-				Marker:         marker != 0,
-				Payload:        buffer[payloadStart:payloadEnd],
-			}
+// handleRTP parses an RTP packet and sends it to the packet channel through the jitter buffer of the track
+func (s *RTSPStream) handleRTP(track *pullTrack, data []byte) {
+	packet, err := rtp.Unmarshal(data)
+	if err != nil || len(packet.Payload) == 0 {
+		return
+	}
+	track.ssrc.Store(packet.SSRC)
+	now := time.Now()
+
+	// Create RTP info
+	rtpInfo := RTPInfo{
+		Track:          track.index,
+		SequenceNumber: packet.SequenceNumber,
+		Timestamp:      packet.Timestamp,
+		SSRC:           packet.SSRC,
+		PayloadType:    packet.PayloadType,
+		Marker:         packet.Marker,
+		Payload:        packet.Payload,
+	}
 
-			// Send to channel
-			select {
-			case s.packetChan <- rtpInfo:
-			default:
-				// Channel full, drop packet
-			}
+	track.mu.Lock()
+	track.stats.receive(packet.SSRC, packet.SequenceNumber, packet.Timestamp, now)
+	s.sendPackets(track, track.buffer.push(rtpInfo, now))
+	s.scheduleExpiry(track)
+	track.mu.Unlock()
 
-			s.mu.Lock()
-			s.streamInfo.LastActive = time.Now()
-			s.mu.Unlock()
-		}
+	select {
+	case track.received <- struct{}{}:
+	default:
 	}
+
+	// the supervisor reads it without s.mu
+	s.lastPacket.Store(now.UnixNano())
 }
 
-// processRTCPPackets processes RTCP packets
-func (s *RTSPStream) processRTCPPackets() {
-	buffer := make([]byte, 1500)
-	for {
+// sendPackets sends the packets released by the jitter buffer of a track to the packet channel,
+// counting the packets dropped when it's full. track.mu is held to keep the order
+func (s *RTSPStream) sendPackets(track *pullTrack, packets []RTPInfo) {
+	// Send to channel unless it's closed by Stop
+	s.packetMu.RLock()
+	defer s.packetMu.RUnlock()
+	if s.stopped {
+		return
+	}
+	for _, packet := range packets {
 		select {
-		case <-s.ctx.Done():
-			return
+		case s.packetChan <- packet:
 		default:
-			if s.rtcpConn == nil {
-				return
-			}
-
-			n, _, err := s.rtcpConn.(*net.UDPConn).ReadFromUDP(buffer)
-			if err != nil {
-				continue
-			}
-
-			// RTCP packet processing (simplified)
-			if n < 4 {
-				continue
-			}
-
-			// Just acknowledge receipt for now
+			track.dropped++
 		}
 	}
 }
 
+// scheduleExpiry releases the packets of the jitter buffer of a track waiting for a gap once their latency
+// has passed, track.mu is held
+func (s *RTSPStream) scheduleExpiry(track *pullTrack) {
+	deadline := track.buffer.deadline()
+	if deadline.IsZero() || track.isClosed() {
+		return
+	}
+	if track.timer != nil {
+		track.timer.Reset(time.Until(deadline))
+		return
+	}
+	track.timer = time.AfterFunc(time.Until(deadline), func() {
+		track.mu.Lock()
+		defer track.mu.Unlock()
+		s.sendPackets(track, track.buffer.expire(time.Now()))
+		s.scheduleExpiry(track)
+	})
+}
+
 // parseRTSPAddress parses RTSP URL to get address
 func (s *RTSPStream) parseRTSPAddress(url string) string {
 	if !strings.HasPrefix(url, "rtsp://") {
@@ -350,6 +647,9 @@ func (s *RTSPStream) parseRTSPAddress(url string) string {
 	}
 
 	address := parts[0]
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		address = address[i+1:]
+	}
 
 	// Check if port is specified
 	if !strings.Contains(address, ":") {
@@ -359,28 +659,82 @@ func (s *RTSPStream) parseRTSPAddress(url string) string {
 	return address
 }
 
-// findAvailablePorts finds available UDP ports for RTP/RTCP
-func (s *RTSPStream) findAvailablePorts() (int, int, error) {
-	for i := 8000; i < 65535; i += 2 {
-		rtpAddr := &net.UDPAddr{Port: i}
-		rtpListener, err := net.ListenUDP("udp", rtpAddr)
+// pullTrack is a media of the SDP set up by Pull
+type pullTrack struct {
+	index int
+	media rtsp.MediaDescription
+	// channel is the interleaved RTP channel over TCP, -1 over UDP
+	channel  int
+	rtpConn  *net.UDPConn
+	rtcpConn *net.UDPConn
+	// rtcpAddr is the RTCP port of the server over UDP
+	rtcpAddr *net.UDPAddr
+	// received is signaled by the packets of the track
+	received chan struct{}
+	// ssrc is the SSRC of the last packet
+	ssrc atomic.Uint32
+
+	// mu guards the jitter buffer, the statistics and the packets dropped of the track
+	mu      sync.Mutex
+	buffer  *jitterBuffer
+	stats   *receiverStats
+	dropped uint64
+	// timer releases the packets of buffer waiting for a gap
+	timer  *time.Timer
+	closed chan struct{}
+}
+
+// listen listens on a pair of UDP ports for RTP and RTCP, RTP on the even one
+func (t *pullTrack) listen() error {
+	for i := 0; i < 100; i++ {
+		rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{})
 		if err != nil {
+			return fmt.Errorf("failed to create RTP listener: %v", err)
+		}
+		port := rtpConn.LocalAddr().(*net.UDPAddr).Port
+		if port%2 != 0 {
+			rtpConn.Close()
 			continue
 		}
-
-		rtcpAddr := &net.UDPAddr{Port: i + 1}
-		rtcpListener, err := net.ListenUDP("udp", rtcpAddr)
+		rtcpConn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port + 1})
 		if err != nil {
-			rtpListener.Close()
+			rtpConn.Close()
 			continue
 		}
+		t.rtpConn, t.rtcpConn = rtpConn, rtcpConn
+		return nil
+	}
+	return fmt.Errorf("no available UDP ports found")
+}
 
-		rtpListener.Close()
-		rtcpListener.Close()
-		return i, i + 1, nil
+func (t *pullTrack) isClosed() bool {
+	select {
+	case <-t.closed:
+		return true
+	default:
+		return false
 	}
+}
 
-	return 0, 0, fmt.Errorf("no available UDP ports found")
+func (t *pullTrack) rtpPort() int {
+	return t.rtpConn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func (t *pullTrack) close() {
+	t.mu.Lock()
+	if !t.isClosed() {
+		close(t.closed)
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.mu.Unlock()
+	if t.rtpConn != nil {
+		_ = t.rtpConn.Close()
+	}
+	if t.rtcpConn != nil {
+		_ = t.rtcpConn.Close()
+	}
 }
 
 // parseSDP parses SDP to get stream information
@@ -14,6 +14,8 @@ const (
 	StreamTypeFLV   StreamType = "flv"
 	StreamTypeHLS   StreamType = "hls"
 	StreamTypeWebRTC StreamType = "webrtc"
+	// StreamTypeRecord records the stream to fragmented MP4 files
+	StreamTypeRecord StreamType = "record"
 )
 
 // Streamer defines the interface for stream processing
@@ -40,6 +42,13 @@ type StreamInfo struct {
 	StartedAt      time.Time
 	LastActive     time.Time
 	ClientCount    int
+	// State is the connection state of an RTSP stream, Reconnects counts its reconnections
+	State          StreamState
+	Reconnects     int
+	// RecordError is the last error of a recording output, empty once it writes a file again
+	RecordError    string
+	// RTPStats are the reception statistics of the tracks of an RTSP stream or of the input of a transcoded stream
+	RTPStats       []RTPStats
 }
 
 // RTSPStreamer defines the interface for RTSP stream processing
@@ -56,10 +65,22 @@ type RTSPConfig struct {
 	URL               string
 	Username          string
 	Password          string
+	// Transport is TransportUDP, TransportTCP or TransportAuto (or empty) which falls back from UDP to TCP
 	Transport         string
 	BufferSize        int
+	// RetryInterval is the delay before the second connection attempt, doubled by the next ones,
+	// DefaultRetryInterval when 0
 	RetryInterval     time.Duration
+	// MaxRetries is the number of attempts after the first one in Start, and of the reconnection attempts
+	// in a row before the stream fails, 0 reconnects forever
 	MaxRetries        int
+	// StallTimeout reconnects the stream without RTP packet for this time, DefaultStallTimeout when 0
+	StallTimeout      time.Duration
+	// OnStateChange is called with the state changes of the stream and the failed connection attempts,
+	// from the goroutine of Start, Stop or the supervisor
+	OnStateChange     func(StateEvent)
+	// JitterLatency is the time a packet waits for the packets missing before it, DefaultJitterLatency when 0
+	JitterLatency     time.Duration
 }
 
 // Transcoder defines the interface for stream transcoding
@@ -90,17 +111,44 @@ type PipelineManager struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	running      bool
+	// formats are the outputs of the transcoder
+	formats      []StreamType
 }
 
-// NewPipelineManager creates a new pipeline manager
+// NewPipelineManager creates a new pipeline manager with the FLV and HLS outputs served on :8080
 func NewPipelineManager(rtspConfig RTSPConfig) *PipelineManager {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &PipelineManager{
-		ctx:    ctx,
-		cancel: cancel,
+		rtspStreamer: NewRTSPStream(rtspConfig),
+		transcoder:   NewStreamTranscoder(),
+		distributor:  NewStreamDistributor(":8080"),
+		ctx:          ctx,
+		cancel:       cancel,
+		formats:      []StreamType{StreamTypeFLV, StreamTypeHLS},
 	}
 }
 
+// EnableRecording adds the recording output of the pipeline, it must be called before Start
+func (pm *PipelineManager) EnableRecording(config RecordConfig) {
+	if transcoder, ok := pm.transcoder.(*StreamTranscoder); ok {
+		transcoder.SetRecordConfig(config)
+	}
+	for _, format := range pm.formats {
+		if format == StreamTypeRecord {
+			return
+		}
+	}
+	pm.formats = append(pm.formats, StreamTypeRecord)
+}
+
+// Recordings returns the recordings overlapping [from, to], a zero time leaves the bound open
+func (pm *PipelineManager) Recordings(from, to time.Time) []Recording {
+	if transcoder, ok := pm.transcoder.(*StreamTranscoder); ok {
+		return transcoder.Recordings(from, to)
+	}
+	return nil
+}
+
 // Start starts the entire stream processing pipeline
 func (pm *PipelineManager) Start() error {
 	// Start RTSP streamer
@@ -109,10 +157,22 @@ func (pm *PipelineManager) Start() error {
 	}
 
 	// Start transcoder
-	if err := pm.transcoder.Transcode(pm.rtspStreamer, []StreamType{StreamTypeFLV, StreamTypeHLS}); err != nil {
+	if err := pm.transcoder.(Streamer).Start(); err != nil {
+		pm.rtspStreamer.Stop()
+		return err
+	}
+	if err := pm.transcoder.Transcode(pm.rtspStreamer, pm.formats); err != nil {
 		pm.rtspStreamer.Stop()
+		pm.transcoder.(Streamer).Stop()
 		return err
 	}
+	if transcoder, ok := pm.transcoder.(*StreamTranscoder); ok {
+		for _, format := range pm.formats {
+			if output := transcoder.GetOutputStream(format); output != nil {
+				pm.distributor.AddStream(output)
+			}
+		}
+	}
 
 	// Start distributor
 	if err := pm.distributor.Distribute(); err != nil {
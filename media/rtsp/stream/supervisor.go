@@ -0,0 +1,183 @@
+package stream
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+)
+
+// Defaults of the supervision of RTSPConfig
+const (
+	DefaultRetryInterval = 2 * time.Second
+	DefaultStallTimeout  = 10 * time.Second
+	// DefaultSessionTimeout is the session timeout of RFC 2326 when the server doesn't give one
+	DefaultSessionTimeout = 60 * time.Second
+)
+
+// maxRetryInterval limits the backoff of the reconnections
+const maxRetryInterval = time.Minute
+
+// StreamState is the connection state of an RTSP stream
+type StreamState string
+
+const (
+	StateConnecting   StreamState = "connecting"
+	StatePlaying      StreamState = "playing"
+	StateReconnecting StreamState = "reconnecting"
+	StateStopped      StreamState = "stopped"
+	StateFailed       StreamState = "failed"
+)
+
+// StateEvent is a change of the state of an RTSP stream, or a failed attempt while connecting
+type StateEvent struct {
+	State StreamState
+	// Err is the cause of a reconnection, of a failed attempt or of the failure
+	Err error
+	// Attempt is the number of the failed attempt, 0 when the state changes
+	Attempt int
+	Time    time.Time
+}
+
+// setState changes the state of the stream and calls OnStateChange without holding s.mu
+func (s *RTSPStream) setState(state StreamState, err error, attempt int) {
+	s.mu.Lock()
+	s.streamInfo.State = state
+	s.mu.Unlock()
+	if s.config.OnStateChange != nil {
+		s.config.OnStateChange(StateEvent{State: state, Err: err, Attempt: attempt, Time: time.Now()})
+	}
+}
+
+// connect replaces the session with a new one playing the stream. s.mu is only held to swap the sessions,
+// the requests of the new one are sent without it and its dial gives up once the stream is stopped
+func (s *RTSPStream) connect() error {
+	s.mu.Lock()
+	old := s.pullSession
+	s.pullSession = pullSession{}
+	s.mu.Unlock()
+	old.close()
+
+	p := &pullSession{}
+	if err := s.open(p); err != nil {
+		return err
+	}
+	if err := s.play(p); err != nil {
+		p.close()
+		return fmt.Errorf("failed to start pulling stream: %v", err)
+	}
+
+	s.mu.Lock()
+	if err := s.ctx.Err(); err != nil {
+		s.mu.Unlock()
+		p.close()
+		return err
+	}
+	s.pullSession = *p
+	s.mu.Unlock()
+	s.lastPacket.Store(time.Now().UnixNano())
+	return nil
+}
+
+// retry connects until it succeeds, fails maxAttempts times in a row (never when 0) or the stream is stopped.
+// The interval between the attempts starts at RetryInterval and doubles up to maxRetryInterval
+func (s *RTSPStream) retry(state StreamState, maxAttempts int) error {
+	delay := s.config.RetryInterval
+	if delay <= 0 {
+		delay = DefaultRetryInterval
+	}
+	for attempt := 1; ; attempt++ {
+		err := s.ctx.Err()
+		if err == nil {
+			err = s.connect()
+		}
+		if err == nil || s.ctx.Err() != nil || maxAttempts > 0 && attempt >= maxAttempts {
+			return err
+		}
+		s.setState(state, err, attempt)
+
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxRetryInterval)
+	}
+}
+
+// supervise reconnects the stream when it stalls or its session is lost, the packet channel and its readers
+// are kept. The stream fails after MaxRetries reconnection attempts in a row, 0 retries forever
+func (s *RTSPStream) supervise() {
+	for {
+		cause := s.watch()
+		if cause == nil || s.ctx.Err() != nil {
+			return
+		}
+		s.setState(StateReconnecting, cause, 0)
+		err := s.retry(StateReconnecting, max(s.config.MaxRetries, 0))
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			s.mu.Lock()
+			old := s.shutdown()
+			s.mu.Unlock()
+			old.close()
+			s.setState(StateFailed, err, 0)
+			return
+		}
+		s.mu.Lock()
+		s.streamInfo.Reconnects++
+		s.mu.Unlock()
+		s.setState(StatePlaying, nil, 0)
+	}
+}
+
+// watch returns why the session is lost: no RTP packet for StallTimeout or a keepalive failure.
+// Keepalives are sent within half the session timeout with GET_PARAMETER when the server supports it,
+// OPTIONS otherwise. It returns nil once the stream is stopped
+func (s *RTSPStream) watch() error {
+	s.mu.Lock()
+	c, uri, method := s.client, s.url, s.keepaliveMethod
+	s.mu.Unlock()
+	if c == nil {
+		// torn down by Stop
+		return nil
+	}
+	timeout := c.SessionTimeout()
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
+	stall := s.config.StallTimeout
+	if stall <= 0 {
+		stall = DefaultStallTimeout
+	}
+
+	keepalive := time.NewTicker(timeout / 2)
+	defer keepalive.Stop()
+	check := time.NewTicker(stall / 4)
+	defer check.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-check.C:
+			if time.Since(time.Unix(0, s.lastPacket.Load())) > stall {
+				return fmt.Errorf("no RTP packet in %v", stall)
+			}
+		case <-keepalive.C:
+			response, err := c.SendRequest(method, uri, nil, nil)
+			if err != nil {
+				return fmt.Errorf("keepalive failed: %v", err)
+			}
+			if method == rtsp.MethodGET_PARAMETER &&
+				(response.StatusCode == rtsp.StatusMethodNotAllowed || response.StatusCode == rtsp.StatusNotImplemented) {
+				method = rtsp.MethodOPTIONS
+				continue
+			}
+			if response.StatusCode != rtsp.StatusOK {
+				return fmt.Errorf("keepalive failed: %d %s", response.StatusCode, response.StatusText)
+			}
+		}
+	}
+}
@@ -1,13 +1,14 @@
 package stream
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp"
+	"github.com/wwqdrh/gokit/media/rtsp/webrtc"
 )
 
 // TranscodedStream implements Streamer interface for transcoded streams
@@ -21,8 +22,34 @@ type TranscodedStream struct {
 	cancel        context.CancelFunc
 	outputChan    chan []byte
 	clientCount   int
+	// flv muxes the packets of the input stream for the FLV clients
+	flv           *FLVMuxer
+	subscribers   map[chan []byte]struct{}
+	// hls segments the packets of the input stream in hlsDir
+	hls           *HLSSegmenter
+	hlsDir        string
+	// frames reassembles the access units of the input for the WebRTC peers
+	frames        *frameReader
+	peers         map[*webrtc.Session]struct{}
+	// lastRequest is the time of the last keyframe request sent to the input
+	lastRequest   time.Time
+	// recorder writes the input to fragmented MP4 files of recordConfig, recordErr is its last error
+	// until a file is written again and recordRetry the time to create it again after a failure
+	recorder      *Recorder
+	recordConfig  RecordConfig
+	recordErr     error
+	recordRetry   time.Time
 }
 
+// subscriberQueueSize is the number of chunks queued for a client, a slower client is closed
+const subscriberQueueSize = 1024
+
+// keyframeRequestInterval limits the keyframe requests of the WebRTC peers sent to the input
+const keyframeRequestInterval = time.Second
+
+// recordRetryInterval is the delay before creating the recorder again after a failure
+const recordRetryInterval = 10 * time.Second
+
 // NewTranscodedStream creates a new transcoded stream
 func NewTranscodedStream(input Streamer, streamType StreamType) *TranscodedStream {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -66,6 +93,20 @@ func (s *TranscodedStream) Stop() error {
 
 	s.cancel()
 	close(s.outputChan)
+	for ch := range s.subscribers {
+		close(ch)
+	}
+	s.subscribers = nil
+	if s.hls != nil {
+		s.hls.Close()
+	}
+	for peer := range s.peers {
+		peer.Close()
+	}
+	s.peers = nil
+	if s.recorder != nil {
+		s.recorder.Close()
+	}
 	s.running = false
 	s.streamInfo.LastActive = time.Now()
 	return nil
@@ -78,11 +119,17 @@ func (s *TranscodedStream) IsRunning() bool {
 	return s.running
 }
 
-// GetStreamInfo returns stream information
+// GetStreamInfo returns stream information with the RTP statistics of the input
 func (s *TranscodedStream) GetStreamInfo() StreamInfo {
+	stats := s.inputStream.GetStreamInfo().RTPStats
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.streamInfo
+	info := s.streamInfo
+	info.RTPStats = stats
+	if s.recordErr != nil {
+		info.RecordError = s.recordErr.Error()
+	}
+	return info
 }
 
 // GetOutputChan returns the output channel
@@ -108,6 +155,203 @@ func (s *TranscodedStream) DecrementClientCount() {
 	}
 }
 
+// subscribe adds a client of the output, returning its channel and the data it starts with,
+// the FLV header, the sequence headers and the current GOP
+func (s *TranscodedStream) subscribe() (chan []byte, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan []byte, subscriberQueueSize)
+	if !s.running {
+		close(ch)
+		return ch, nil
+	}
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan []byte]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	return ch, s.muxer().Cache()
+}
+
+// unsubscribe removes a client which was not removed by Stop or for being too slow
+func (s *TranscodedStream) unsubscribe(ch chan []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// sessionDescription returns the SDP of the input, nil when it's not an RTSP stream
+func (s *TranscodedStream) sessionDescription() *rtsp.SessionDescription {
+	if input, ok := s.inputStream.(*RTSPStream); ok {
+		return input.GetSessionDescription()
+	}
+	return nil
+}
+
+// muxer returns the FLV muxer of the SDP of the input, created with the first client or packet
+func (s *TranscodedStream) muxer() *FLVMuxer {
+	if s.flv == nil {
+		s.flv = NewFLVMuxer(s.sessionDescription())
+	}
+	return s.flv
+}
+
+// writeHLS segments a packet of the input
+func (s *TranscodedStream) writeHLS(packet RTPInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	if s.hls == nil {
+		s.hls = NewHLSSegmenter(HLSConfig{Dir: s.hlsDir}, s.sessionDescription())
+	}
+	if err := s.hls.WritePacket(packet); err == nil {
+		s.streamInfo.LastActive = time.Now()
+	}
+}
+
+// writeRecord records a packet of the input, the recorder is created with the first packet.
+// An error ends the current file and the recording starts again with a new file at the next keyframe,
+// GetStreamInfo reports it until then
+func (s *TranscodedStream) writeRecord(packet RTPInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	if s.recorder == nil {
+		if time.Now().Before(s.recordRetry) {
+			return
+		}
+		config := s.recordConfig
+		if config.Stream == "" {
+			config.Stream = recordStreamName(s.inputStream.GetStreamInfo().URL)
+		}
+		recorder, err := NewRecorder(config, s.sessionDescription())
+		if err != nil {
+			s.recordErr, s.recordRetry = err, time.Now().Add(recordRetryInterval)
+			return
+		}
+		s.recorder = recorder
+	}
+	if err := s.recorder.WritePacket(packet); err != nil {
+		s.recordErr = err
+		_ = s.recorder.Reset()
+		return
+	}
+	if s.recorder.recording() {
+		s.recordErr = nil
+	}
+	s.streamInfo.LastActive = time.Now()
+}
+
+// Recordings returns the recordings of the stream overlapping [from, to]
+func (s *TranscodedStream) Recordings(from, to time.Time) []Recording {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recorder == nil {
+		return nil
+	}
+	return s.recorder.Recordings(from, to)
+}
+
+// writeFLV muxes a packet of the input and sends the tags to the clients
+func (s *TranscodedStream) writeFLV(packet RTPInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	data := s.muxer().WritePacket(packet)
+	if len(data) == 0 {
+		return
+	}
+	s.streamInfo.LastActive = time.Now()
+	select {
+	case s.outputChan <- data:
+	default:
+		// Channel full, drop data
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- data:
+		default:
+			// the client can't resume after a dropped tag
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// addPeer adds a WebRTC session receiving the video of the input until it's closed
+func (s *TranscodedStream) addPeer(peer *webrtc.Session) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return false
+	}
+	if s.peers == nil {
+		s.peers = make(map[*webrtc.Session]struct{})
+	}
+	s.peers[peer] = struct{}{}
+	s.clientCount++
+	s.streamInfo.ClientCount = s.clientCount
+	go func() {
+		<-peer.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.peers[peer]; ok {
+			delete(s.peers, peer)
+			s.clientCount--
+			s.streamInfo.ClientCount = s.clientCount
+		}
+	}()
+	return true
+}
+
+// requestKeyframe forwards the keyframe requests of the peers to an RTSP input, at most one a keyframeRequestInterval
+func (s *TranscodedStream) requestKeyframe() {
+	s.mu.Lock()
+	if time.Since(s.lastRequest) < keyframeRequestInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastRequest = time.Now()
+	s.mu.Unlock()
+	if input, ok := s.inputStream.(*RTSPStream); ok {
+		_ = input.RequestKeyframe()
+	}
+}
+
+// writeWebRTC reassembles the access units of a packet and sends them to the peers,
+// a keyframe carries the parameter sets
+func (s *TranscodedStream) writeWebRTC(packet RTPInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	if s.frames == nil {
+		s.frames = newFrameReader(s.sessionDescription(), 90000)
+	}
+	video, _ := s.frames.read(packet)
+	for _, frame := range video {
+		s.streamInfo.LastActive = time.Now()
+		nalus := frame.NALUs
+		if frame.Keyframe {
+			nalus = append([][]byte{s.frames.sps, s.frames.pps}, nalus...)
+		}
+		for peer := range s.peers {
+			if err := peer.WriteH264(nalus, uint32(frame.PTS), frame.Keyframe); err != nil {
+				peer.Close()
+			}
+		}
+	}
+}
+
 // StreamTranscoder implements Transcoder interface
 type StreamTranscoder struct {
 	inputStream   Streamer
@@ -118,6 +362,7 @@ type StreamTranscoder struct {
 	cancel        context.CancelFunc
 	outputURLs    map[StreamType]string
 	hlsDir        string
+	recordConfig  RecordConfig
 }
 
 // NewStreamTranscoder creates a new stream transcoder
@@ -194,6 +439,8 @@ func (t *StreamTranscoder) Transcode(input Streamer, outputFormats []StreamType)
 	// Create output streams
 	for _, format := range outputFormats {
 		stream := NewTranscodedStream(input, format)
+		stream.hlsDir = t.hlsDir
+		stream.recordConfig = t.recordConfig
 		if err := stream.Start(); err != nil {
 			return err
 		}
@@ -224,25 +471,13 @@ func (t *StreamTranscoder) transcodeLoop() {
 				for format, stream := range t.outputStreams {
 					switch format {
 					case StreamTypeFLV:
-						data := t.transcodeToFLV(packet)
-						if len(data) > 0 {
-							select {
-							case stream.outputChan <- data:
-							default:
-								// Channel full, drop data
-							}
-						}
+						stream.writeFLV(packet)
 					case StreamTypeHLS:
-						t.transcodeToHLS(packet)
+						stream.writeHLS(packet)
 					case StreamTypeWebRTC:
-						data := t.transcodeToWebRTC(packet)
-						if len(data) > 0 {
-							select {
-							case stream.outputChan <- data:
-							default:
-								// Channel full, drop data
-							}
-						}
+						stream.writeWebRTC(packet)
+					case StreamTypeRecord:
+						stream.writeRecord(packet)
 					}
 				}
 			}
@@ -250,99 +485,6 @@ func (t *StreamTranscoder) transcodeLoop() {
 	}
 }
 
-// transcodeToFLV transcodes RTP packet to FLV format
-func (t *StreamTranscoder) transcodeToFLV(packet RTPInfo) []byte {
-	// FLV header (9 bytes)
-	flvHeader := []byte{0x46, 0x4C, 0x56, 0x01, 0x05, 0x00, 0x00, 0x00, 0x09}
-	
-	// FLV body
-	var body bytes.Buffer
-	
-	// Create video tag
-	tagType := byte(0x09) // Video tag
-	dataSize := uint32(len(packet.Payload) + 5)
-	timestamp := uint32(packet.Timestamp)
-	timestampExtended := byte(timestamp >> 24)
-	
-	// Write tag header
-	body.WriteByte(tagType)
-	body.Write([]byte{byte(dataSize >> 16), byte(dataSize >> 8), byte(dataSize)})
-	body.Write([]byte{byte(timestamp >> 16), byte(timestamp >> 8), byte(timestamp)})
-	body.WriteByte(timestampExtended)
-	body.Write([]byte{0x00, 0x00, 0x00}) // Stream ID
-	
-	// Write video data
-	frameType := byte(0x10) // Key frame
-	codecID := byte(0x07)   // H264
-	body.WriteByte(frameType | codecID)
-	
-	// AVC packet type
-	avcPacketType := byte(0x01) // NAL unit
-	body.WriteByte(avcPacketType)
-	
-	// Composition time
-	compositionTime := int32(0)
-	body.Write([]byte{byte(compositionTime >> 16), byte(compositionTime >> 8), byte(compositionTime)})
-	
-	// Write NAL unit
-	body.Write(packet.Payload)
-	
-	// Write tag size
-	tagSize := uint32(body.Len())
-	tagSizeBytes := []byte{byte(tagSize >> 24), byte(tagSize >> 16), byte(tagSize >> 8), byte(tagSize)}
-	body.Write(tagSizeBytes)
-	
-	// Combine header and body
-	result := append(flvHeader, body.Bytes()...)
-	return result
-}
-
-// transcodeToHLS transcodes RTP packet to HLS format
-func (t *StreamTranscoder) transcodeToHLS(packet RTPInfo) {
-	// Simplified HLS implementation
-	// In real implementation, you would:
-	// 1. Collect packets into a TS segment
-	// 2. When segment reaches desired duration, write to file
-	// 3. Update m3u8 playlist
-	
-	// For now, just create dummy files
-	streamName := "test"
-	segmentName := fmt.Sprintf("%s_%d.ts", streamName, time.Now().Unix())
-	segmentPath := filepath.Join(t.hlsDir, segmentName)
-	
-	// Write dummy TS file
-	if err := os.WriteFile(segmentPath, packet.Payload, 0644); err != nil {
-		return
-	}
-	
-	// Update m3u8 playlist
-	playlistPath := filepath.Join(t.hlsDir, "playlist.m3u8")
-	playlistContent := `#EXTM3U
-#EXT-X-VERSION:3
-#EXT-X-TARGETDURATION:10
-#EXT-X-MEDIA-SEQUENCE:0
-`
-	
-	// Add segment
-	playlistContent += fmt.Sprintf("#EXTINF:10.0,\n%s\n", segmentName)
-	
-	if err := os.WriteFile(playlistPath, []byte(playlistContent), 0644); err != nil {
-		return
-	}
-}
-
-// transcodeToWebRTC transcodes RTP packet to WebRTC format
-func (t *StreamTranscoder) transcodeToWebRTC(packet RTPInfo) []byte {
-	// WebRTC uses RTP packets directly
-	// In real implementation, you would:
-	// 1. Encrypt the packet
-	// 2. Add WebRTC headers
-	// 3. Send over peer connection
-	
-	// For now, just return the RTP payload
-	return packet.Payload
-}
-
 // GetOutputURLs returns the output stream URLs
 func (t *StreamTranscoder) GetOutputURLs() map[StreamType]string {
 	t.mu.Lock()
@@ -355,6 +497,22 @@ func (t *StreamTranscoder) SetHLSPath(path string) {
 	t.hlsDir = path
 }
 
+// SetRecordConfig sets the configuration of the StreamTypeRecord outputs
+func (t *StreamTranscoder) SetRecordConfig(config RecordConfig) {
+	t.recordConfig = config
+}
+
+// Recordings returns the recordings of the StreamTypeRecord output overlapping [from, to]
+func (t *StreamTranscoder) Recordings(from, to time.Time) []Recording {
+	t.mu.Lock()
+	stream := t.outputStreams[StreamTypeRecord]
+	t.mu.Unlock()
+	if stream == nil {
+		return nil
+	}
+	return stream.Recordings(from, to)
+}
+
 // GetOutputStream returns the output stream for a specific format
 func (t *StreamTranscoder) GetOutputStream(streamType StreamType) Streamer {
 	t.mu.Lock()
@@ -0,0 +1,135 @@
+package rtsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Transport represents a transport specification of the Transport header
+type Transport struct {
+	// Protocol is RTP/AVP, RTP/AVP/UDP or RTP/AVP/TCP
+	Protocol   string
+	Multicast  bool
+	ClientPort [2]int
+	ServerPort [2]int
+	// Interleaved is the channels of RTP and RTCP on the RTSP connection for RTP/AVP/TCP,
+	// [-1, -1] when the client leaves them to the server
+	Interleaved [2]int
+	// SSRC is the ssrc parameter in hex, empty when absent
+	SSRC string
+	// Mode is PLAY or RECORD, empty when absent
+	Mode string
+}
+
+// ParseTransport parses the first transport specification of a Transport header
+func ParseTransport(header string) (*Transport, error) {
+	spec, _, _ := strings.Cut(header, ",")
+	return parseTransportSpec(spec)
+}
+
+// ParseTransports parses the transport specifications of a Transport header in the order of preference,
+// skipping the ones which are not RTP
+func ParseTransports(header string) ([]*Transport, error) {
+	res := []*Transport{}
+	var err error
+	for _, spec := range strings.Split(header, ",") {
+		t, e := parseTransportSpec(spec)
+		if e != nil {
+			err = e
+			continue
+		}
+		res = append(res, t)
+	}
+	if len(res) == 0 {
+		return nil, err
+	}
+	return res, nil
+}
+
+func parseTransportSpec(spec string) (*Transport, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ";")
+	t := &Transport{Protocol: strings.ToUpper(parts[0]), Interleaved: [2]int{-1, -1}}
+	if !strings.HasPrefix(t.Protocol, "RTP/AVP") {
+		return nil, fmt.Errorf("unsupported transport protocol: %s", parts[0])
+	}
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		var err error
+		switch strings.ToLower(key) {
+		case "multicast":
+			t.Multicast = true
+		case "client_port":
+			t.ClientPort, err = parsePortRange(value)
+		case "server_port":
+			t.ServerPort, err = parsePortRange(value)
+		case "interleaved":
+			t.Interleaved, err = parseChannelRange(value)
+		case "ssrc":
+			t.SSRC = value
+		case "mode":
+			t.Mode = strings.ToUpper(strings.Trim(value, `"`))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in transport: %v", key, err)
+		}
+	}
+	return t, nil
+}
+
+// parsePortRange parses "a-b" or "a", which means a-(a+1)
+func parsePortRange(s string) ([2]int, error) {
+	first, second, ok := strings.Cut(s, "-")
+	a, err := strconv.Atoi(first)
+	if err != nil {
+		return [2]int{}, err
+	}
+	if !ok {
+		return [2]int{a, a + 1}, nil
+	}
+	b, err := strconv.Atoi(second)
+	if err != nil {
+		return [2]int{}, err
+	}
+	return [2]int{a, b}, nil
+}
+
+// parseChannelRange parses "a-b" or "a", which means a-(a+1), of interleaved
+func parseChannelRange(s string) ([2]int, error) {
+	channels, err := parsePortRange(s)
+	if err == nil && (channels[0] < 0 || channels[0] > 255 || channels[1] < 0 || channels[1] > 255) {
+		err = fmt.Errorf("channel out of range")
+	}
+	return channels, err
+}
+
+// IsTCP reports whether RTP is interleaved on the RTSP connection
+func (t *Transport) IsTCP() bool {
+	return t.Protocol == "RTP/AVP/TCP"
+}
+
+// String serializes the transport specification
+func (t *Transport) String() string {
+	parts := []string{t.Protocol}
+	if t.Multicast {
+		parts = append(parts, "multicast")
+	} else {
+		parts = append(parts, "unicast")
+	}
+	if t.ClientPort[0] != 0 {
+		parts = append(parts, fmt.Sprintf("client_port=%d-%d", t.ClientPort[0], t.ClientPort[1]))
+	}
+	if t.ServerPort[0] != 0 {
+		parts = append(parts, fmt.Sprintf("server_port=%d-%d", t.ServerPort[0], t.ServerPort[1]))
+	}
+	if t.IsTCP() && t.Interleaved[0] >= 0 {
+		parts = append(parts, fmt.Sprintf("interleaved=%d-%d", t.Interleaved[0], t.Interleaved[1]))
+	}
+	if t.SSRC != "" {
+		parts = append(parts, "ssrc="+t.SSRC)
+	}
+	if t.Mode != "" {
+		parts = append(parts, "mode="+t.Mode)
+	}
+	return strings.Join(parts, ";")
+}
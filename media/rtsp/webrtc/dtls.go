@@ -0,0 +1,713 @@
+package webrtc
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DTLS 1.2 (RFC 6347) record and handshake types
+const (
+	dtlsVersion            = 0xfefd
+	dtlsRecordHeaderSize   = 13
+	dtlsHandshakeHeaderLen = 12
+	// dtlsMaxDatagramSize keeps the flights of the server below the path MTU
+	dtlsMaxDatagramSize = 1200
+
+	dtlsChangeCipherSpec = 20
+	dtlsAlert            = 21
+	dtlsHandshake        = 22
+
+	dtlsAlertLevelFatal  = 2
+	dtlsAlertCloseNotify = 0
+
+	handshakeClientHello        = 1
+	handshakeServerHello        = 2
+	handshakeCertificate        = 11
+	handshakeServerKeyExchange  = 12
+	handshakeCertificateRequest = 13
+	handshakeServerHelloDone    = 14
+	handshakeCertificateVerify  = 15
+	handshakeClientKeyExchange  = 16
+	handshakeFinished           = 20
+
+	extensionECPointFormats       = 11
+	extensionUseSRTP              = 14
+	extensionExtendedMasterSecret = 23
+	extensionRenegotiationInfo    = 0xff01
+	scsvEmptyRenegotiationInfo    = 0x00ff
+
+	signatureECDSAP256SHA256  = 0x0403
+	signatureRSAPKCS1SHA256   = 0x0401
+	signatureRSAPSSRSAESHA256 = 0x0804
+)
+
+// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 on P-256 is the only cipher suite
+const (
+	cipherSuiteECDHEECDSAAES128GCMSHA256 = 0xc02b
+	curveP256                            = 23
+	gcmKeySize                           = 16
+	gcmImplicitIVSize                    = 4
+	gcmExplicitNonceSize                 = 8
+	gcmTagSize                           = 16
+	masterSecretSize                     = 48
+	finishedVerifyDataSize               = 12
+	maxHandshakeMessageSize              = 1 << 16
+	certificateValidity                  = 30 * 24 * time.Hour
+	srtpKeyingMaterialLabel              = "EXTRACTOR-dtls_srtp"
+	srtpKeyingMaterialSize               = 2 * (srtpKeySize + srtpSaltSize)
+)
+
+// states of the handshake of the server
+const (
+	dtlsStateClientHello = iota
+	dtlsStateClientFlight
+	dtlsStateEstablished
+)
+
+// errDTLSClosed is returned once the peer closed the association
+var errDTLSClosed = errors.New("dtls: closed by peer")
+
+// Certificate is the self-signed ECDSA certificate of the DTLS server, browsers check its fingerprint against the SDP
+type Certificate struct {
+	der []byte
+	key *ecdsa.PrivateKey
+}
+
+// GenerateCertificate creates a self-signed ECDSA P-256 certificate
+func GenerateCertificate() (*Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "WebRTC"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certificateValidity),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{der: der, key: key}, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate in the format of a=fingerprint
+func (c *Certificate) Fingerprint() string {
+	return formatFingerprint(sha256.Sum256(c.der))
+}
+
+func formatFingerprint(sum [32]byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// dtlsServer is the server side of a DTLS 1.2 association negotiating DTLS-SRTP (RFC 5764),
+// the client retransmits its flights so the server only answers them again
+type dtlsServer struct {
+	certificate *Certificate
+	// fingerprint is the SHA-256 fingerprint of the client certificate announced in the SDP
+	fingerprint string
+	send        func([]byte) error
+
+	state        int
+	clientRandom []byte
+	serverRandom []byte
+	ecdhKey      *ecdh.PrivateKey
+	// extensions of the ClientHello echoed by the ServerHello
+	extendedMS, renegotiation, pointFormats bool
+	// transcript is the handshake messages with their unfragmented headers
+	transcript []byte
+	// receiveSeq is the message_seq of the next expected handshake message of the client
+	receiveSeq uint16
+	sendSeq    uint16
+	fragments  map[uint16]*dtlsFragments
+	clientCert *x509.Certificate
+	// certificateVerified is set by a valid CertificateVerify, the client proved it holds the key of clientCert
+	certificateVerified bool
+	// readEncrypted is set by the ChangeCipherSpec of the client
+	readEncrypted bool
+
+	master                   []byte
+	clientWrite, serverWrite cipher.AEAD
+	clientIV, serverIV       []byte
+	writeSeq                 [2]uint64
+	// flight is the last flight, sent again with new record numbers when the client retransmits
+	flight []dtlsRecord
+
+	// keyingMaterial is exported for SRTP once the handshake is over
+	keyingMaterial []byte
+}
+
+// dtlsRecord is a record of a flight before protection
+type dtlsRecord struct {
+	typ   byte
+	epoch uint16
+	data  []byte
+}
+
+// dtlsFragments reassembles a fragmented handshake message
+type dtlsFragments struct {
+	typ      byte
+	body     []byte
+	received []bool
+}
+
+func (f *dtlsFragments) complete() bool {
+	for _, received := range f.received {
+		if !received {
+			return false
+		}
+	}
+	return true
+}
+
+func newDTLSServer(certificate *Certificate, fingerprint string, send func([]byte) error) *dtlsServer {
+	return &dtlsServer{certificate: certificate, fingerprint: strings.ToUpper(fingerprint), send: send, fragments: map[uint16]*dtlsFragments{}}
+}
+
+// established reports whether the handshake is over
+func (d *dtlsServer) established() bool {
+	return d.state == dtlsStateEstablished
+}
+
+// handle processes a datagram of DTLS records
+func (d *dtlsServer) handle(datagram []byte) error {
+	retransmit := false
+	sendSeq := d.sendSeq
+	for len(datagram) > 0 {
+		if len(datagram) < dtlsRecordHeaderSize {
+			return fmt.Errorf("dtls: truncated record header")
+		}
+		typ := datagram[0]
+		epoch := binary.BigEndian.Uint16(datagram[3:])
+		length := int(binary.BigEndian.Uint16(datagram[11:]))
+		if len(datagram) < dtlsRecordHeaderSize+length {
+			return fmt.Errorf("dtls: truncated record")
+		}
+		header, fragment := datagram[:dtlsRecordHeaderSize], datagram[dtlsRecordHeaderSize:dtlsRecordHeaderSize+length]
+		datagram = datagram[dtlsRecordHeaderSize+length:]
+
+		if epoch > 0 {
+			if d.clientWrite == nil || epoch != 1 {
+				continue
+			}
+			plain, err := d.decrypt(header, fragment)
+			if err != nil {
+				// records failing authentication are dropped (RFC 6347 4.1.2.7)
+				continue
+			}
+			fragment = plain
+		}
+
+		switch typ {
+		case dtlsHandshake:
+			resend, err := d.handleHandshake(fragment, epoch)
+			if err != nil {
+				return err
+			}
+			retransmit = retransmit || resend
+		case dtlsChangeCipherSpec:
+			if d.clientWrite != nil {
+				d.readEncrypted = true
+			}
+		case dtlsAlert:
+			if len(fragment) >= 2 && (fragment[0] == dtlsAlertLevelFatal || fragment[1] == dtlsAlertCloseNotify) {
+				return errDTLSClosed
+			}
+		}
+	}
+	// a flight sent for the datagram already answers the messages retransmitted with the ones completing it
+	if retransmit && d.sendSeq == sendSeq {
+		return d.sendFlight()
+	}
+	return nil
+}
+
+// handleHandshake reassembles the handshake messages of a record, it reports whether the client retransmitted
+// a flight already answered
+func (d *dtlsServer) handleHandshake(data []byte, epoch uint16) (bool, error) {
+	retransmit := false
+	for len(data) > 0 {
+		if len(data) < dtlsHandshakeHeaderLen {
+			return false, fmt.Errorf("dtls: truncated handshake header")
+		}
+		typ := data[0]
+		length := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		seq := binary.BigEndian.Uint16(data[4:])
+		offset := int(data[6])<<16 | int(data[7])<<8 | int(data[8])
+		fragmentLength := int(data[9])<<16 | int(data[10])<<8 | int(data[11])
+		if len(data) < dtlsHandshakeHeaderLen+fragmentLength || offset+fragmentLength > length || length > maxHandshakeMessageSize {
+			return false, fmt.Errorf("dtls: invalid handshake fragment")
+		}
+		fragment := data[dtlsHandshakeHeaderLen : dtlsHandshakeHeaderLen+fragmentLength]
+		data = data[dtlsHandshakeHeaderLen+fragmentLength:]
+
+		if seq < d.receiveSeq {
+			retransmit = true
+			continue
+		}
+		// Finished is the only message under encryption
+		if (epoch > 0) != (typ == handshakeFinished) {
+			continue
+		}
+		f := d.fragments[seq]
+		if f == nil {
+			f = &dtlsFragments{typ: typ, body: make([]byte, length), received: make([]bool, length)}
+			d.fragments[seq] = f
+		}
+		if f.typ != typ || len(f.body) != length {
+			return false, fmt.Errorf("dtls: inconsistent handshake fragments")
+		}
+		copy(f.body[offset:], fragment)
+		for i := offset; i < offset+fragmentLength; i++ {
+			f.received[i] = true
+		}
+
+		// process the messages completed in sequence
+		for {
+			f := d.fragments[d.receiveSeq]
+			if f == nil || !f.complete() {
+				break
+			}
+			delete(d.fragments, d.receiveSeq)
+			d.receiveSeq++
+			if err := d.handleMessage(f.typ, f.body); err != nil {
+				return false, err
+			}
+		}
+	}
+	return retransmit, nil
+}
+
+// appendTranscript adds a message to the handshake hash with the header of an unfragmented message
+func (d *dtlsServer) appendTranscript(typ byte, seq uint16, body []byte) {
+	d.transcript = append(d.transcript, handshakeHeader(typ, seq, len(body))...)
+	d.transcript = append(d.transcript, body...)
+}
+
+func handshakeHeader(typ byte, seq uint16, length int) []byte {
+	return []byte{typ, byte(length >> 16), byte(length >> 8), byte(length), byte(seq >> 8), byte(seq), 0, 0, 0,
+		byte(length >> 16), byte(length >> 8), byte(length)}
+}
+
+func (d *dtlsServer) handleMessage(typ byte, body []byte) error {
+	seq := d.receiveSeq - 1
+	switch {
+	case typ == handshakeClientHello && d.clientRandom == nil:
+		d.appendTranscript(typ, seq, body)
+		if err := d.handleClientHello(body); err != nil {
+			return err
+		}
+		return d.sendServerFlight()
+	case typ == handshakeCertificate && d.clientRandom != nil && d.clientCert == nil:
+		d.appendTranscript(typ, seq, body)
+		return d.handleCertificate(body)
+	case typ == handshakeClientKeyExchange && d.clientRandom != nil && d.master == nil:
+		// the certificate was requested, the fingerprint of the SDP is the only authentication of the client
+		if d.clientCert == nil {
+			return fmt.Errorf("dtls: ClientKeyExchange without certificate")
+		}
+		d.appendTranscript(typ, seq, body)
+		return d.handleClientKeyExchange(body)
+	case typ == handshakeCertificateVerify && d.master != nil && !d.certificateVerified:
+		if err := d.handleCertificateVerify(body); err != nil {
+			return err
+		}
+		d.certificateVerified = true
+		d.appendTranscript(typ, seq, body)
+		return nil
+	case typ == handshakeFinished && d.readEncrypted:
+		if !d.certificateVerified {
+			return fmt.Errorf("dtls: Finished without CertificateVerify")
+		}
+		if err := d.handleFinished(body); err != nil {
+			return err
+		}
+		d.appendTranscript(typ, seq, body)
+		return d.sendFinished()
+	}
+	return fmt.Errorf("dtls: unexpected handshake message %d", typ)
+}
+
+func (d *dtlsServer) handleClientHello(body []byte) error {
+	r := &reader{data: body}
+	r.skip(2)
+	d.clientRandom = append([]byte{}, r.next(32)...)
+	r.vector(1) // session id
+	r.vector(1) // cookie
+	suites := r.vector(2)
+	r.vector(1) // compression methods
+	extensions := r.vector(2)
+	if r.err != nil {
+		return fmt.Errorf("dtls: invalid ClientHello")
+	}
+
+	supported := false
+	for i := 0; i+1 < len(suites); i += 2 {
+		switch binary.BigEndian.Uint16(suites[i:]) {
+		case cipherSuiteECDHEECDSAAES128GCMSHA256:
+			supported = true
+		case scsvEmptyRenegotiationInfo:
+			d.renegotiation = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("dtls: TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 is not offered")
+	}
+
+	srtp := false
+	for e := (&reader{data: extensions}); len(e.data) > 0 && e.err == nil; {
+		typ := binary.BigEndian.Uint16(e.next(2))
+		data := e.vector(2)
+		switch typ {
+		case extensionUseSRTP:
+			profiles := (&reader{data: data}).vector(2)
+			for i := 0; i+1 < len(profiles); i += 2 {
+				if binary.BigEndian.Uint16(profiles[i:]) == srtpProfileAES128CMHMACSHA180 {
+					srtp = true
+				}
+			}
+		case extensionExtendedMasterSecret:
+			d.extendedMS = true
+		case extensionRenegotiationInfo:
+			d.renegotiation = true
+		case extensionECPointFormats:
+			d.pointFormats = true
+		}
+	}
+	if !srtp {
+		return fmt.Errorf("dtls: SRTP_AES128_CM_HMAC_SHA1_80 is not offered")
+	}
+	return nil
+}
+
+// sendServerFlight sends ServerHello, Certificate, ServerKeyExchange, CertificateRequest and ServerHelloDone
+func (d *dtlsServer) sendServerFlight() error {
+	d.serverRandom = make([]byte, 32)
+	if _, err := rand.Read(d.serverRandom); err != nil {
+		return err
+	}
+	var err error
+	if d.ecdhKey, err = ecdh.P256().GenerateKey(rand.Reader); err != nil {
+		return err
+	}
+
+	hello := binary.BigEndian.AppendUint16(nil, dtlsVersion)
+	hello = append(hello, d.serverRandom...)
+	hello = append(hello, 0) // session id
+	hello = binary.BigEndian.AppendUint16(hello, cipherSuiteECDHEECDSAAES128GCMSHA256)
+	hello = append(hello, 0) // compression
+	extensions := []byte{0, extensionUseSRTP, 0, 5, 0, 2, 0, srtpProfileAES128CMHMACSHA180, 0}
+	if d.extendedMS {
+		extensions = append(extensions, 0, extensionExtendedMasterSecret, 0, 0)
+	}
+	if d.renegotiation {
+		extensions = append(extensions, 0xff, 0x01, 0, 1, 0)
+	}
+	if d.pointFormats {
+		extensions = append(extensions, 0, extensionECPointFormats, 0, 2, 1, 0)
+	}
+	hello = binary.BigEndian.AppendUint16(hello, uint16(len(extensions)))
+	hello = append(hello, extensions...)
+
+	der := d.certificate.der
+	certificate := []byte{byte((len(der) + 3) >> 16), byte((len(der) + 3) >> 8), byte(len(der) + 3),
+		byte(len(der) >> 16), byte(len(der) >> 8), byte(len(der))}
+	certificate = append(certificate, der...)
+
+	public := d.ecdhKey.PublicKey().Bytes()
+	params := append([]byte{3, 0, curveP256, byte(len(public))}, public...)
+	digest := sha256.Sum256(append(append(append([]byte{}, d.clientRandom...), d.serverRandom...), params...))
+	signature, err := ecdsa.SignASN1(rand.Reader, d.certificate.key, digest[:])
+	if err != nil {
+		return err
+	}
+	keyExchange := append(params, 0x04, 0x03)
+	keyExchange = binary.BigEndian.AppendUint16(keyExchange, uint16(len(signature)))
+	keyExchange = append(keyExchange, signature...)
+
+	// ecdsa_sign and rsa_sign certificates signed with SHA-256
+	request := []byte{2, 64, 1, 0, 6, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01, 0, 0}
+
+	d.flight = nil
+	for _, m := range []struct {
+		typ  byte
+		body []byte
+	}{
+		{handshakeServerHello, hello},
+		{handshakeCertificate, certificate},
+		{handshakeServerKeyExchange, keyExchange},
+		{handshakeCertificateRequest, request},
+		{handshakeServerHelloDone, nil},
+	} {
+		seq := d.sendSeq
+		d.sendSeq++
+		d.appendTranscript(m.typ, seq, m.body)
+		d.flight = append(d.flight, dtlsRecord{typ: dtlsHandshake, data: append(handshakeHeader(m.typ, seq, len(m.body)), m.body...)})
+	}
+	d.state = dtlsStateClientFlight
+	return d.sendFlight()
+}
+
+// packDatagrams puts records into datagrams below the MTU
+func packDatagrams(records [][]byte) [][]byte {
+	var datagrams [][]byte
+	var current []byte
+	for _, record := range records {
+		if len(current) > 0 && len(current)+len(record) > dtlsMaxDatagramSize {
+			datagrams = append(datagrams, current)
+			current = nil
+		}
+		current = append(current, record...)
+	}
+	if len(current) > 0 {
+		datagrams = append(datagrams, current)
+	}
+	return datagrams
+}
+
+func (d *dtlsServer) sendFlight() error {
+	records := make([][]byte, 0, len(d.flight))
+	for _, r := range d.flight {
+		records = append(records, d.record(r.typ, r.epoch, r.data))
+	}
+	for _, datagram := range packDatagrams(records) {
+		if err := d.send(datagram); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dtlsServer) handleCertificate(body []byte) error {
+	r := &reader{data: body}
+	list := &reader{data: r.vector(3)}
+	der := list.vector(3)
+	if r.err != nil || list.err != nil || len(der) == 0 {
+		return fmt.Errorf("dtls: the client sent no certificate")
+	}
+	if d.fingerprint == "" {
+		return fmt.Errorf("dtls: no fingerprint of the client certificate in the SDP")
+	}
+	if formatFingerprint(sha256.Sum256(der)) != d.fingerprint {
+		return fmt.Errorf("dtls: the certificate of the client doesn't match the fingerprint of the SDP")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("dtls: invalid client certificate: %v", err)
+	}
+	d.clientCert = cert
+	return nil
+}
+
+func (d *dtlsServer) handleClientKeyExchange(body []byte) error {
+	r := &reader{data: body}
+	point := r.vector(1)
+	if r.err != nil {
+		return fmt.Errorf("dtls: invalid ClientKeyExchange")
+	}
+	public, err := ecdh.P256().NewPublicKey(point)
+	if err != nil {
+		return fmt.Errorf("dtls: invalid ECDH public key: %v", err)
+	}
+	preMaster, err := d.ecdhKey.ECDH(public)
+	if err != nil {
+		return err
+	}
+	if d.extendedMS {
+		// the session hash covers the messages up to ClientKeyExchange (RFC 7627)
+		sessionHash := sha256.Sum256(d.transcript)
+		d.master = prf(preMaster, "extended master secret", sessionHash[:], masterSecretSize)
+	} else {
+		d.master = prf(preMaster, "master secret", append(append([]byte{}, d.clientRandom...), d.serverRandom...), masterSecretSize)
+	}
+
+	keys := prf(d.master, "key expansion", append(append([]byte{}, d.serverRandom...), d.clientRandom...),
+		2*(gcmKeySize+gcmImplicitIVSize))
+	if d.clientWrite, err = newGCM(keys[:gcmKeySize]); err != nil {
+		return err
+	}
+	if d.serverWrite, err = newGCM(keys[gcmKeySize : 2*gcmKeySize]); err != nil {
+		return err
+	}
+	d.clientIV = keys[2*gcmKeySize : 2*gcmKeySize+gcmImplicitIVSize]
+	d.serverIV = keys[2*gcmKeySize+gcmImplicitIVSize:]
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// handleCertificateVerify checks the signature of the transcript by the client certificate
+func (d *dtlsServer) handleCertificateVerify(body []byte) error {
+	if d.clientCert == nil {
+		return fmt.Errorf("dtls: CertificateVerify without certificate")
+	}
+	r := &reader{data: body}
+	algorithm := binary.BigEndian.Uint16(r.next(2))
+	signature := r.vector(2)
+	if r.err != nil {
+		return fmt.Errorf("dtls: invalid CertificateVerify")
+	}
+	digest := sha256.Sum256(d.transcript)
+	valid := false
+	switch key := d.clientCert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = algorithm == signatureECDSAP256SHA256 && ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		switch algorithm {
+		case signatureRSAPKCS1SHA256:
+			valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+		case signatureRSAPSSRSAESHA256:
+			valid = rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+	}
+	if !valid {
+		return fmt.Errorf("dtls: invalid CertificateVerify signature")
+	}
+	return nil
+}
+
+func (d *dtlsServer) handleFinished(body []byte) error {
+	digest := sha256.Sum256(d.transcript)
+	expected := prf(d.master, "client finished", digest[:], finishedVerifyDataSize)
+	if !hmac.Equal(expected, body) {
+		return fmt.Errorf("dtls: invalid Finished")
+	}
+	return nil
+}
+
+// sendFinished answers the Finished of the client with ChangeCipherSpec and Finished, the handshake is over
+func (d *dtlsServer) sendFinished() error {
+	digest := sha256.Sum256(d.transcript)
+	verify := prf(d.master, "server finished", digest[:], finishedVerifyDataSize)
+	seq := d.sendSeq
+	d.sendSeq++
+	d.appendTranscript(handshakeFinished, seq, verify)
+
+	d.flight = []dtlsRecord{
+		{typ: dtlsChangeCipherSpec, data: []byte{1}},
+		{typ: dtlsHandshake, epoch: 1, data: append(handshakeHeader(handshakeFinished, seq, len(verify)), verify...)},
+	}
+	d.state = dtlsStateEstablished
+	d.keyingMaterial = prf(d.master, srtpKeyingMaterialLabel,
+		append(append([]byte{}, d.clientRandom...), d.serverRandom...), srtpKeyingMaterialSize)
+	return d.sendFlight()
+}
+
+// srtpKeys returns the SRTP master keys and salts of the client and the server (RFC 5764 4.2)
+func (d *dtlsServer) srtpKeys() (clientKey, serverKey, clientSalt, serverSalt []byte) {
+	k := d.keyingMaterial
+	return k[:srtpKeySize], k[srtpKeySize : 2*srtpKeySize],
+		k[2*srtpKeySize : 2*srtpKeySize+srtpSaltSize], k[2*srtpKeySize+srtpSaltSize:]
+}
+
+// record builds a record with the next sequence number of an epoch, encrypted in epoch 1
+func (d *dtlsServer) record(typ byte, epoch uint16, data []byte) []byte {
+	seq := d.writeSeq[epoch]
+	d.writeSeq[epoch]++
+	header := []byte{typ, dtlsVersion >> 8, dtlsVersion & 0xff, byte(epoch >> 8), byte(epoch)}
+	header = append(header, byte(seq>>40), byte(seq>>32), byte(seq>>24), byte(seq>>16), byte(seq>>8), byte(seq))
+	if epoch == 0 {
+		header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+		return append(header, data...)
+	}
+
+	explicit := header[3:11]
+	nonce := append(append([]byte{}, d.serverIV...), explicit...)
+	aad := append(append([]byte{}, header[3:11]...), header[:3]...)
+	aad = binary.BigEndian.AppendUint16(aad, uint16(len(data)))
+	sealed := d.serverWrite.Seal(append([]byte{}, explicit...), nonce, data, aad)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(sealed)))
+	return append(header, sealed...)
+}
+
+// decrypt opens an AES-GCM record of the client
+func (d *dtlsServer) decrypt(header, fragment []byte) ([]byte, error) {
+	if len(fragment) < gcmExplicitNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("dtls: encrypted record too short")
+	}
+	nonce := append(append([]byte{}, d.clientIV...), fragment[:gcmExplicitNonceSize]...)
+	aad := append(append([]byte{}, header[3:11]...), header[:3]...)
+	aad = binary.BigEndian.AppendUint16(aad, uint16(len(fragment)-gcmExplicitNonceSize-gcmTagSize))
+	return d.clientWrite.Open(nil, nonce, fragment[gcmExplicitNonceSize:], aad)
+}
+
+// prf is the TLS 1.2 PRF with SHA-256 (RFC 5246 5)
+func prf(secret []byte, label string, seed []byte, n int) []byte {
+	seed = append([]byte(label), seed...)
+	res := make([]byte, 0, n+sha256.Size)
+	a := seed
+	for len(res) < n {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(a)
+		a = mac.Sum(nil)
+		mac.Reset()
+		mac.Write(a)
+		mac.Write(seed)
+		res = mac.Sum(res)
+	}
+	return res[:n]
+}
+
+// reader reads the fields of a handshake message, setting err when the message is too short
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || len(r.data) < n {
+		r.err = fmt.Errorf("message too short")
+		r.data = nil
+		return make([]byte, n)
+	}
+	res := r.data[:n]
+	r.data = r.data[n:]
+	return res
+}
+
+func (r *reader) skip(n int) {
+	r.next(n)
+}
+
+// vector reads a vector with a length of size bytes
+func (r *reader) vector(size int) []byte {
+	var length int
+	for _, b := range r.next(size) {
+		length = length<<8 | int(b)
+	}
+	if r.err != nil {
+		return nil
+	}
+	return r.next(length)
+}
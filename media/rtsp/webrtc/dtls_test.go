@@ -0,0 +1,407 @@
+package webrtc
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// testDTLSClient is the client side of a DTLS-SRTP handshake with a dtlsServer (RFC 5246, RFC 6347, RFC 7627)
+type testDTLSClient struct {
+	t           *testing.T
+	server      *dtlsServer
+	certificate *Certificate
+	extendedMS  bool
+	// datagrams are sent by the server
+	datagrams [][]byte
+
+	random, serverRandom     []byte
+	serverPublic             []byte
+	transcript               []byte
+	sendSeq                  uint16
+	writeSeq                 [2]uint64
+	master                   []byte
+	clientWrite, serverWrite cipher.AEAD
+	clientIV, serverIV       []byte
+}
+
+type testRecord struct {
+	typ   byte
+	epoch uint16
+	seq   uint64
+	data  []byte
+}
+
+// testFlight changes the flight of the client
+type testFlight struct {
+	noCertificate, noVerify bool
+	// wrongKey signs the CertificateVerify with a key other than the one of the certificate
+	wrongKey    bool
+	badFinished bool
+	// fragment splits the Certificate in fragments of this size
+	fragment int
+}
+
+func newTestDTLSClient(t *testing.T, extendedMS bool) *testDTLSClient {
+	serverCert, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("Failed to generate a certificate: %v", err)
+	}
+	clientCert, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("Failed to generate a certificate: %v", err)
+	}
+	c := &testDTLSClient{t: t, certificate: clientCert, extendedMS: extendedMS, random: make([]byte, 32)}
+	rand.Read(c.random)
+	// the fingerprints of the SDP are in lower case
+	c.server = newDTLSServer(serverCert, strings.ToLower(clientCert.Fingerprint()), func(datagram []byte) error {
+		c.datagrams = append(c.datagrams, append([]byte{}, datagram...))
+		return nil
+	})
+	return c
+}
+
+// send passes a datagram of records to the server
+func (c *testDTLSClient) send(records ...[]byte) error {
+	return c.server.handle(bytes.Join(records, nil))
+}
+
+// message returns a handshake message with the next message_seq and adds it to the transcript
+func (c *testDTLSClient) message(typ byte, body []byte) []byte {
+	message := append(handshakeHeader(typ, c.sendSeq, len(body)), body...)
+	c.sendSeq++
+	c.transcript = append(c.transcript, message...)
+	return message
+}
+
+// fragments splits a handshake message in fragments of size bytes
+func fragments(message []byte, size int) [][]byte {
+	body := message[dtlsHandshakeHeaderLen:]
+	var res [][]byte
+	for offset := 0; offset < len(body); offset += size {
+		n := min(size, len(body)-offset)
+		fragment := append([]byte{}, message[:6]...)
+		fragment = append(fragment, byte(offset>>16), byte(offset>>8), byte(offset), byte(n>>16), byte(n>>8), byte(n))
+		res = append(res, append(fragment, body[offset:offset+n]...))
+	}
+	return res
+}
+
+// record returns a record with the next sequence number of the epoch, encrypted with the client keys in epoch 1
+func (c *testDTLSClient) record(typ byte, epoch uint16, data []byte) []byte {
+	header := []byte{typ, 0xfe, 0xfd, byte(epoch >> 8), byte(epoch), 0, 0}
+	header = binary.BigEndian.AppendUint32(header, uint32(c.writeSeq[epoch]))
+	c.writeSeq[epoch]++
+	if epoch == 1 {
+		explicit := append([]byte{}, header[3:11]...)
+		aad := append(append(append([]byte{}, explicit...), header[:3]...), byte(len(data)>>8), byte(len(data)))
+		data = c.clientWrite.Seal(explicit, append(append([]byte{}, c.clientIV...), explicit...), data, aad)
+	}
+	return append(binary.BigEndian.AppendUint16(header, uint16(len(data))), data...)
+}
+
+// receive returns the records sent by the server since the last call, decrypted in epoch 1
+func (c *testDTLSClient) receive() []testRecord {
+	var records []testRecord
+	for _, datagram := range c.datagrams {
+		if len(datagram) > dtlsMaxDatagramSize {
+			c.t.Errorf("Datagram of %d bytes above the MTU", len(datagram))
+		}
+		for len(datagram) > 0 {
+			header := datagram[:dtlsRecordHeaderSize]
+			length := int(binary.BigEndian.Uint16(header[11:]))
+			r := testRecord{
+				typ:   header[0],
+				epoch: binary.BigEndian.Uint16(header[3:]),
+				seq:   binary.BigEndian.Uint64(header[3:]) & (1<<48 - 1),
+				data:  datagram[dtlsRecordHeaderSize : dtlsRecordHeaderSize+length],
+			}
+			if r.epoch == 1 {
+				size := length - gcmExplicitNonceSize - gcmTagSize
+				aad := append(append(append([]byte{}, header[3:11]...), header[:3]...), byte(size>>8), byte(size))
+				nonce := append(append([]byte{}, c.serverIV...), r.data[:gcmExplicitNonceSize]...)
+				plain, err := c.serverWrite.Open(nil, nonce, r.data[gcmExplicitNonceSize:], aad)
+				if err != nil {
+					c.t.Fatalf("Failed to decrypt a record of the server: %v", err)
+				}
+				r.data = plain
+			}
+			records = append(records, r)
+			datagram = datagram[dtlsRecordHeaderSize+length:]
+		}
+	}
+	c.datagrams = nil
+	return records
+}
+
+// hello returns the record of a ClientHello offering the cipher suite and the SRTP profile of the server
+func (c *testDTLSClient) hello() []byte {
+	body := binary.BigEndian.AppendUint16(nil, dtlsVersion)
+	body = append(body, c.random...)
+	body = append(body, 0, 0)                      // session id and cookie
+	body = append(body, 0, 4, 0xc0, 0x2b, 0, 0xff) // cipher suite and renegotiation SCSV
+	body = append(body, 1, 0)                      // null compression
+	extensions := []byte{0, extensionUseSRTP, 0, 5, 0, 2, 0, srtpProfileAES128CMHMACSHA180, 0}
+	if c.extendedMS {
+		extensions = append(extensions, 0, extensionExtendedMasterSecret, 0, 0)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(len(extensions)))
+	body = append(body, extensions...)
+	return c.record(dtlsHandshake, 0, c.message(handshakeClientHello, body))
+}
+
+// serverFlight checks the flight answering the ClientHello, with the signature of the ECDH key by the server certificate
+func (c *testDTLSClient) serverFlight() []testRecord {
+	records := c.receive()
+	types := []byte{handshakeServerHello, handshakeCertificate, handshakeServerKeyExchange, handshakeCertificateRequest, handshakeServerHelloDone}
+	if len(records) != len(types) {
+		c.t.Fatalf("Expected %d records in the server flight, got %d", len(types), len(records))
+	}
+	var params, signature []byte
+	var certificate *x509.Certificate
+	for i, r := range records {
+		if r.typ != dtlsHandshake || r.epoch != 0 || len(r.data) < dtlsHandshakeHeaderLen || r.data[0] != types[i] ||
+			binary.BigEndian.Uint16(r.data[4:]) != uint16(i) {
+			c.t.Fatalf("Unexpected record %d of the server flight %x", i, r.data)
+		}
+		c.transcript = append(c.transcript, r.data...)
+		body := r.data[dtlsHandshakeHeaderLen:]
+		switch types[i] {
+		case handshakeServerHello:
+			c.serverRandom = body[2:34]
+			extendedMS := false
+			for e := body[2+32+1+2+1+2:]; len(e) >= 4; e = e[4+int(binary.BigEndian.Uint16(e[2:])):] {
+				extendedMS = extendedMS || binary.BigEndian.Uint16(e) == extensionExtendedMasterSecret
+			}
+			if extendedMS != c.extendedMS {
+				c.t.Errorf("Expected the extended master secret %v in the ServerHello", c.extendedMS)
+			}
+		case handshakeCertificate:
+			var err error
+			if certificate, err = x509.ParseCertificate(body[6:]); err != nil {
+				c.t.Fatalf("Invalid certificate of the server: %v", err)
+			}
+		case handshakeServerKeyExchange:
+			n := 4 + int(body[3])
+			params, c.serverPublic, signature = body[:n], body[4:n], body[n+4:]
+		}
+	}
+	digest := sha256.Sum256(bytes.Join([][]byte{c.random, c.serverRandom, params}, nil))
+	if !ecdsa.VerifyASN1(certificate.PublicKey.(*ecdsa.PublicKey), digest[:], signature) {
+		c.t.Error("Invalid signature of the ServerKeyExchange")
+	}
+	return records
+}
+
+// clientFlight returns the records of Certificate, ClientKeyExchange, CertificateVerify, ChangeCipherSpec and Finished
+func (c *testDTLSClient) clientFlight(f testFlight) [][]byte {
+	var records [][]byte
+	if !f.noCertificate {
+		der := c.certificate.der
+		body := []byte{byte((len(der) + 3) >> 16), byte((len(der) + 3) >> 8), byte(len(der) + 3),
+			byte(len(der) >> 16), byte(len(der) >> 8), byte(len(der))}
+		message := c.message(handshakeCertificate, append(body, der...))
+		if f.fragment == 0 {
+			f.fragment = len(message)
+		}
+		for _, fragment := range fragments(message, f.fragment) {
+			records = append(records, c.record(dtlsHandshake, 0, fragment))
+		}
+	}
+
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		c.t.Fatalf("Failed to generate the ECDH key: %v", err)
+	}
+	public := key.PublicKey().Bytes()
+	records = append(records, c.record(dtlsHandshake, 0, c.message(handshakeClientKeyExchange, append([]byte{byte(len(public))}, public...))))
+	serverPublic, err := ecdh.P256().NewPublicKey(c.serverPublic)
+	if err != nil {
+		c.t.Fatalf("Invalid ECDH key of the server: %v", err)
+	}
+	preMaster, err := key.ECDH(serverPublic)
+	if err != nil {
+		c.t.Fatalf("Failed to compute the premaster secret: %v", err)
+	}
+	if c.extendedMS {
+		sessionHash := sha256.Sum256(c.transcript)
+		c.master = prf(preMaster, "extended master secret", sessionHash[:], masterSecretSize)
+	} else {
+		c.master = prf(preMaster, "master secret", bytes.Join([][]byte{c.random, c.serverRandom}, nil), masterSecretSize)
+	}
+	keys := prf(c.master, "key expansion", bytes.Join([][]byte{c.serverRandom, c.random}, nil), 2*(gcmKeySize+gcmImplicitIVSize))
+	c.clientWrite, _ = newGCM(keys[:gcmKeySize])
+	c.serverWrite, _ = newGCM(keys[gcmKeySize : 2*gcmKeySize])
+	c.clientIV, c.serverIV = keys[2*gcmKeySize:2*gcmKeySize+gcmImplicitIVSize], keys[2*gcmKeySize+gcmImplicitIVSize:]
+
+	if !f.noVerify {
+		signer := c.certificate.key
+		if f.wrongKey {
+			other, _ := GenerateCertificate()
+			signer = other.key
+		}
+		digest := sha256.Sum256(c.transcript)
+		signature, err := ecdsa.SignASN1(rand.Reader, signer, digest[:])
+		if err != nil {
+			c.t.Fatalf("Failed to sign: %v", err)
+		}
+		body := binary.BigEndian.AppendUint16([]byte{0x04, 0x03}, uint16(len(signature)))
+		records = append(records, c.record(dtlsHandshake, 0, c.message(handshakeCertificateVerify, append(body, signature...))))
+	}
+
+	records = append(records, c.record(dtlsChangeCipherSpec, 0, []byte{1}))
+	digest := sha256.Sum256(c.transcript)
+	verify := prf(c.master, "client finished", digest[:], finishedVerifyDataSize)
+	if f.badFinished {
+		verify[0] ^= 1
+	}
+	return append(records, c.record(dtlsHandshake, 1, c.message(handshakeFinished, verify)))
+}
+
+// serverFinished checks the ChangeCipherSpec and the Finished answering the flight of the client
+func (c *testDTLSClient) serverFinished() {
+	records := c.receive()
+	if len(records) != 2 || records[0].typ != dtlsChangeCipherSpec || records[1].typ != dtlsHandshake || records[1].epoch != 1 {
+		c.t.Fatalf("Unexpected answer to the client flight %+v", records)
+	}
+	digest := sha256.Sum256(c.transcript)
+	expected := append(handshakeHeader(handshakeFinished, 5, finishedVerifyDataSize), prf(c.master, "server finished", digest[:], finishedVerifyDataSize)...)
+	if !bytes.Equal(records[1].data, expected) {
+		c.t.Errorf("Expected the Finished %x, got %x", expected, records[1].data)
+	}
+}
+
+func TestPRF(t *testing.T) {
+	// the TLS 1.2 PRF with SHA-256 test vector of the IETF TLS working group
+	secret := unhex(t, "9bbe436ba940f017b17652849a71db35")
+	seed := unhex(t, "a0ba9f936cda311827a6f796ffd5198c")
+	expected := unhex(t, "e3f229ba727be17b8d122620557cd453c2aab21d07c3d495329b52d4e61edb5a6b301791e90d35c9c9a46b4e14baf9af0fa022f7077def17abfd3797c0564bab4fbc91666e9def9b97fce34f796789baa48082d122ee42c5a72e5a5110fff70187347b66")
+	if got := prf(secret, "test label", seed, len(expected)); !bytes.Equal(got, expected) {
+		t.Errorf("Expected %x, got %x", expected, got)
+	}
+}
+
+func TestDTLSHandshake(t *testing.T) {
+	for _, extendedMS := range []bool{false, true} {
+		name := "master secret"
+		if extendedMS {
+			name = "extended master secret"
+		}
+		t.Run(name, func(t *testing.T) {
+			c := newTestDTLSClient(t, extendedMS)
+			hello := c.hello()
+			if err := c.send(hello); err != nil {
+				t.Fatalf("Failed to handle the ClientHello: %v", err)
+			}
+			flight := c.serverFlight()
+
+			// a retransmitted ClientHello gets the same flight in new records
+			if err := c.send(hello); err != nil {
+				t.Fatalf("Failed to handle the retransmitted ClientHello: %v", err)
+			}
+			retransmitted := c.receive()
+			if len(retransmitted) != len(flight) {
+				t.Fatalf("Expected the server flight again, got %d records", len(retransmitted))
+			}
+			for i, r := range retransmitted {
+				if !bytes.Equal(r.data, flight[i].data) || r.seq != flight[i].seq+uint64(len(flight)) {
+					t.Errorf("Unexpected retransmitted record %d of sequence number %d", i, r.seq)
+				}
+			}
+
+			// the fragments of the certificate come in reverse order and the first one is lost
+			records := c.clientFlight(testFlight{fragment: 100})
+			fragments := len(records) - 4
+			if fragments < 3 {
+				t.Fatalf("Expected the certificate in fragments, got %d", fragments)
+			}
+			for i := fragments - 1; i > 0; i-- {
+				if err := c.send(records[i]); err != nil {
+					t.Fatalf("Failed to handle fragment %d: %v", i, err)
+				}
+			}
+			if err := c.send(records[fragments:]...); err != nil {
+				t.Fatalf("Failed to handle the client flight: %v", err)
+			}
+			if c.server.established() || len(c.datagrams) > 0 {
+				t.Fatal("Expected the server to wait for the missing fragment")
+			}
+
+			// the client retransmits its flight
+			for _, datagram := range packDatagrams(records) {
+				if err := c.send(datagram); err != nil {
+					t.Fatalf("Failed to handle the retransmitted client flight: %v", err)
+				}
+			}
+			c.serverFinished()
+			if !c.server.established() {
+				t.Fatal("Expected the handshake to be over")
+			}
+			clientKey, serverKey, clientSalt, serverSalt := c.server.srtpKeys()
+			expected := prf(c.master, srtpKeyingMaterialLabel, bytes.Join([][]byte{c.random, c.serverRandom}, nil), srtpKeyingMaterialSize)
+			if got := bytes.Join([][]byte{clientKey, serverKey, clientSalt, serverSalt}, nil); !bytes.Equal(got, expected) {
+				t.Errorf("Expected the SRTP keying material %x, got %x", expected, got)
+			}
+
+			// a retransmitted Finished means the Finished of the server was lost
+			if err := c.send(records[len(records)-1]); err != nil {
+				t.Fatalf("Failed to handle the retransmitted Finished: %v", err)
+			}
+			c.serverFinished()
+		})
+	}
+}
+
+func TestDTLSHandshakeRejected(t *testing.T) {
+	other, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("Failed to generate a certificate: %v", err)
+	}
+	for _, test := range []struct {
+		name   string
+		flight testFlight
+		// fingerprint replaces the fingerprint of the SDP when set
+		fingerprint func(c *testDTLSClient) string
+		err         string
+	}{
+		{name: "no certificate", flight: testFlight{noCertificate: true}, err: "ClientKeyExchange without certificate"},
+		{name: "no CertificateVerify", flight: testFlight{noVerify: true}, err: "Finished without CertificateVerify"},
+		{name: "invalid CertificateVerify", flight: testFlight{wrongKey: true}, err: "invalid CertificateVerify signature"},
+		{name: "invalid Finished", flight: testFlight{badFinished: true}, err: "invalid Finished"},
+		{
+			name:        "other certificate",
+			fingerprint: func(*testDTLSClient) string { return other.Fingerprint() },
+			err:         "doesn't match the fingerprint",
+		},
+		{
+			name:        "no fingerprint",
+			fingerprint: func(*testDTLSClient) string { return "" },
+			err:         "no fingerprint",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestDTLSClient(t, true)
+			if test.fingerprint != nil {
+				c.server.fingerprint = test.fingerprint(c)
+			}
+			if err := c.send(c.hello()); err != nil {
+				t.Fatalf("Failed to handle the ClientHello: %v", err)
+			}
+			c.serverFlight()
+			err := c.send(c.clientFlight(test.flight)...)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected %q, got %v", test.err, err)
+			}
+			if c.server.established() || len(c.datagrams) > 0 {
+				t.Error("Expected the handshake to fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package webrtc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// offerMedia is an m= section of an offer
+type offerMedia struct {
+	Type     string
+	Protocol string
+	Formats  []string
+	Mid      string
+	// Direction is sendrecv, recvonly, sendonly or inactive
+	Direction string
+	Rtpmap    map[string]string
+	Fmtp      map[string]string
+}
+
+// offer is the subset of a WebRTC offer (RFC 8829) needed by a send-only ICE-lite answerer
+type offer struct {
+	Ufrag, Pwd  string
+	Fingerprint string
+	Setup       string
+	Medias      []*offerMedia
+}
+
+// parseOffer parses an SDP offer, the ICE credentials and the fingerprint may be at the session or media level
+func parseOffer(data []byte) (*offer, error) {
+	o := &offer{}
+	var media *offerMedia
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 2 || line[1] != '=' {
+			continue
+		}
+		key, value := line[0], line[2:]
+		if key == 'm' {
+			fields := strings.Fields(value)
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid media line: %s", line)
+			}
+			media = &offerMedia{Type: fields[0], Protocol: fields[2], Formats: fields[3:], Direction: "sendrecv",
+				Rtpmap: map[string]string{}, Fmtp: map[string]string{}}
+			o.Medias = append(o.Medias, media)
+			continue
+		}
+		if key != 'a' {
+			continue
+		}
+		name, attr, _ := strings.Cut(value, ":")
+		switch name {
+		case "ice-ufrag":
+			o.Ufrag = attr
+		case "ice-pwd":
+			o.Pwd = attr
+		case "fingerprint":
+			hash, fingerprint, _ := strings.Cut(attr, " ")
+			if strings.EqualFold(hash, "sha-256") {
+				o.Fingerprint = strings.ToUpper(strings.TrimSpace(fingerprint))
+			}
+		case "setup":
+			o.Setup = attr
+		}
+		if media == nil {
+			continue
+		}
+		switch name {
+		case "mid":
+			media.Mid = attr
+		case "sendrecv", "recvonly", "sendonly", "inactive":
+			media.Direction = name
+		case "rtpmap":
+			pt, format, _ := strings.Cut(attr, " ")
+			media.Rtpmap[pt] = format
+		case "fmtp":
+			pt, params, _ := strings.Cut(attr, " ")
+			media.Fmtp[pt] = params
+		}
+	}
+	if o.Ufrag == "" || o.Pwd == "" {
+		return nil, fmt.Errorf("offer without ICE credentials")
+	}
+	if o.Fingerprint == "" {
+		return nil, fmt.Errorf("offer without sha-256 fingerprint")
+	}
+	if o.Setup == "passive" {
+		return nil, fmt.Errorf("offer with setup:passive, the answerer is the DTLS server")
+	}
+	return o, nil
+}
+
+// h264PayloadType returns the payload type of H.264 in packetization mode 1 preferred by the offer,
+// constrained baseline first as every browser decodes it
+func (m *offerMedia) h264PayloadType() (string, bool) {
+	best, bestScore := "", -1
+	for _, pt := range m.Formats {
+		encoding, _, _ := strings.Cut(m.Rtpmap[pt], "/")
+		if !strings.EqualFold(encoding, "H264") {
+			continue
+		}
+		params := fmtpParams(m.Fmtp[pt])
+		if params["packetization-mode"] != "1" {
+			continue
+		}
+		score := 0
+		if strings.HasPrefix(strings.ToLower(params["profile-level-id"]), "42e0") {
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = pt, score
+		}
+	}
+	return best, best != ""
+}
+
+func fmtpParams(fmtp string) map[string]string {
+	res := map[string]string{}
+	for _, param := range strings.Split(fmtp, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key != "" {
+			res[strings.ToLower(key)] = value
+		}
+	}
+	return res
+}
+
+// answer is the send-only answer of a session, with a single host candidate
+type answer struct {
+	Ufrag, Pwd  string
+	Fingerprint string
+	Candidate   *net.UDPAddr
+	SSRC        uint32
+	CNAME       string
+}
+
+// marshal answers the medias of an offer, the video media given by index is sent with the payload type,
+// the others are rejected
+func (a *answer) marshal(o *offer, index int, payloadType string, sessionID uint64) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "v=0\r\no=- %d 1 IN IP4 %s\r\ns=-\r\nt=0 0\r\n", sessionID, a.Candidate.IP)
+	if mid := o.Medias[index].Mid; mid != "" {
+		fmt.Fprintf(&b, "a=group:BUNDLE %s\r\n", mid)
+	}
+	b.WriteString("a=ice-lite\r\n")
+	for i, m := range o.Medias {
+		if i != index {
+			fmt.Fprintf(&b, "m=%s 0 %s %s\r\nc=IN IP4 0.0.0.0\r\n", m.Type, m.Protocol, strings.Join(m.Formats, " "))
+			if m.Mid != "" {
+				fmt.Fprintf(&b, "a=mid:%s\r\n", m.Mid)
+			}
+			b.WriteString("a=inactive\r\n")
+			continue
+		}
+		fmt.Fprintf(&b, "m=%s %d %s %s\r\n", m.Type, a.Candidate.Port, m.Protocol, payloadType)
+		fmt.Fprintf(&b, "c=IN IP4 %s\r\n", a.Candidate.IP)
+		if m.Mid != "" {
+			fmt.Fprintf(&b, "a=mid:%s\r\n", m.Mid)
+		}
+		fmt.Fprintf(&b, "a=ice-ufrag:%s\r\na=ice-pwd:%s\r\n", a.Ufrag, a.Pwd)
+		fmt.Fprintf(&b, "a=fingerprint:sha-256 %s\r\na=setup:passive\r\n", a.Fingerprint)
+		b.WriteString("a=sendonly\r\na=rtcp-mux\r\n")
+		fmt.Fprintf(&b, "a=rtpmap:%s %s\r\n", payloadType, m.Rtpmap[payloadType])
+		if fmtp := m.Fmtp[payloadType]; fmtp != "" {
+			fmt.Fprintf(&b, "a=fmtp:%s %s\r\n", payloadType, fmtp)
+		}
+		fmt.Fprintf(&b, "a=rtcp-fb:%s nack\r\na=rtcp-fb:%s nack pli\r\na=rtcp-fb:%s ccm fir\r\n", payloadType, payloadType, payloadType)
+		fmt.Fprintf(&b, "a=ssrc:%d cname:%s\r\n", a.SSRC, a.CNAME)
+		fmt.Fprintf(&b, "a=candidate:1 1 udp 2130706431 %s %d typ host\r\na=end-of-candidates\r\n", a.Candidate.IP, a.Candidate.Port)
+	}
+	return []byte(b.String())
+}
+
+// videoMedia returns the index of the first video media the offer can receive and its H.264 payload type
+func (o *offer) videoMedia() (int, string, error) {
+	for i, m := range o.Medias {
+		if m.Type != "video" || (m.Direction != "recvonly" && m.Direction != "sendrecv") {
+			continue
+		}
+		if pt, ok := m.h264PayloadType(); ok {
+			return i, pt, nil
+		}
+	}
+	return -1, "", fmt.Errorf("offer without a video media receiving H264 in packetization mode 1")
+}
+
+// parsePayloadType parses a payload type of the offer
+func parsePayloadType(pt string) (uint8, error) {
+	v, err := strconv.ParseUint(pt, 10, 7)
+	return uint8(v), err
+}
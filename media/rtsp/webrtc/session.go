@@ -0,0 +1,348 @@
+// Package webrtc sends H.264 to browsers as a send-only ICE-lite peer with DTLS-SRTP,
+// the offer comes from a WHEP client (RFC 9725) and is answered with a single host candidate
+package webrtc
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwqdrh/gokit/media/rtsp/rtp"
+)
+
+// DefaultTimeout closes a session without connectivity checks for this long (RFC 7675)
+const DefaultTimeout = 30 * time.Second
+
+// packetSize is the RTP payload size, small enough for the SRTP overhead within the usual MTUs
+const packetSize = 1200
+
+// historySize is the number of sent packets kept for retransmission on NACK
+const historySize = 512
+
+// ErrSessionClosed is returned by the writes of a closed session
+var ErrSessionClosed = errors.New("webrtc: session closed")
+
+// Config configures a session
+type Config struct {
+	// IP is the address of the host candidate, it must be reachable by the browser
+	IP net.IP
+	// Certificate is the DTLS certificate, generated for the session when nil
+	Certificate *Certificate
+	// Timeout closes the session without connectivity checks, DefaultTimeout when 0
+	Timeout time.Duration
+	// OnKeyframeRequest is called on a PLI or FIR of the browser and when the session starts,
+	// the session drops the frames until the next keyframe anyway
+	OnKeyframeRequest func()
+}
+
+// Session is the media session of a browser
+type Session struct {
+	// ID identifies the session in the WHEP resource URL
+	ID string
+
+	config      Config
+	conn        *net.UDPConn
+	answer      []byte
+	localUfrag  string
+	localPwd    string
+	remoteUfrag string
+
+	mu sync.Mutex
+	// remote is the address nominated by the browser
+	remote      *net.UDPAddr
+	lastCheck   time.Time
+	dtls        *dtlsServer
+	srtp, srtcp *srtpContext
+	packetizer  rtp.Packetizer
+	// timestampBase is the random offset of the RTP timestamps
+	timestampBase uint32
+	// waitKeyframe drops the frames until a keyframe, the browser can't decode them
+	waitKeyframe bool
+	history      [historySize][]byte
+	closed       bool
+	done         chan struct{}
+}
+
+// NewSession answers an offer and listens for the connectivity checks of the browser
+func NewSession(offer []byte, config Config) (*Session, error) {
+	o, err := parseOffer(offer)
+	if err != nil {
+		return nil, err
+	}
+	index, pt, err := o.videoMedia()
+	if err != nil {
+		return nil, err
+	}
+	payloadType, err := parsePayloadType(pt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload type %s: %v", pt, err)
+	}
+	if config.IP == nil || config.IP.IsUnspecified() {
+		return nil, fmt.Errorf("no IP for the host candidate")
+	}
+	if config.Certificate == nil {
+		if config.Certificate, err = GenerateCertificate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: config.IP})
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, 36)
+	if _, err := rand.Read(random); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s := &Session{
+		ID:            hex.EncodeToString(random[:8]),
+		config:        config,
+		conn:          conn,
+		localUfrag:    hex.EncodeToString(random[8:12]),
+		localPwd:      hex.EncodeToString(random[12:24]),
+		remoteUfrag:   o.Ufrag,
+		lastCheck:     time.Now(),
+		packetizer:    rtp.Packetizer{PayloadType: payloadType, SSRC: binary.BigEndian.Uint32(random[24:]), SequenceNumber: binary.BigEndian.Uint16(random[28:])},
+		timestampBase: binary.BigEndian.Uint32(random[30:]),
+		waitKeyframe:  true,
+		done:          make(chan struct{}),
+	}
+	s.dtls = newDTLSServer(config.Certificate, o.Fingerprint, s.send)
+	a := &answer{
+		Ufrag:       s.localUfrag,
+		Pwd:         s.localPwd,
+		Fingerprint: config.Certificate.Fingerprint(),
+		Candidate:   conn.LocalAddr().(*net.UDPAddr),
+		SSRC:        s.packetizer.SSRC,
+		CNAME:       s.ID,
+	}
+	s.answer = a.marshal(o, index, pt, binary.BigEndian.Uint64(random[:8])>>1)
+
+	go s.readLoop()
+	go s.watch()
+	return s, nil
+}
+
+// Answer returns the SDP answer of the offer
+func (s *Session) Answer() []byte {
+	return s.answer
+}
+
+// Done is closed with the session
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close closes the session
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	return s.conn.Close()
+}
+
+// WriteH264 sends an access unit with its 90kHz timestamp, a keyframe carries its parameter sets.
+// Frames are dropped until the DTLS handshake is over and a keyframe is written
+func (s *Session) WriteH264(nalus [][]byte, timestamp uint32, keyframe bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSessionClosed
+	}
+	if s.srtp == nil {
+		return nil
+	}
+	if keyframe {
+		s.waitKeyframe = false
+	}
+	if s.waitKeyframe {
+		return nil
+	}
+	for _, packet := range s.packetizer.Packetize(rtp.H264Payloads(nalus, packetSize), s.timestampBase+timestamp) {
+		data, err := s.srtp.encryptRTP(packet.Marshal())
+		if err != nil {
+			return err
+		}
+		s.history[packet.SequenceNumber%historySize] = data
+		if err := s.send(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a datagram to the nominated address
+func (s *Session) send(data []byte) error {
+	if s.remote == nil {
+		return fmt.Errorf("no nominated candidate")
+	}
+	_, err := s.conn.WriteToUDP(data, s.remote)
+	return err
+}
+
+// readLoop demultiplexes STUN, DTLS and SRTCP (RFC 7983)
+func (s *Session) readLoop() {
+	defer s.Close()
+	buffer := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if n == 0 {
+			// an empty datagram has no first byte to demultiplex
+			continue
+		}
+		data := buffer[:n]
+		switch {
+		case isSTUN(data):
+			s.handleSTUN(data, addr)
+		case data[0] >= 20 && data[0] <= 63:
+			// a failed handshake or a closed association ends the session
+			if err := s.handleDTLS(data, addr); err != nil {
+				return
+			}
+		case data[0] >= 128 && data[0] <= 191 && n > 1 && data[1] >= 192 && data[1] <= 223:
+			s.handleRTCP(data, addr)
+		}
+	}
+}
+
+// handleSTUN answers the connectivity checks of the browser, the pair with USE-CANDIDATE is nominated
+func (s *Session) handleSTUN(data []byte, addr *net.UDPAddr) {
+	m, err := parseSTUN(data)
+	if err != nil || m.Type != stunBindingRequest {
+		return
+	}
+	local, remote, _ := strings.Cut(string(m.Attributes[stunAttrUsername]), ":")
+	if local != s.localUfrag || remote != s.remoteUfrag || !m.checkIntegrity(s.localPwd) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := m.Attributes[stunAttrUseCandidate]; ok || s.remote == nil {
+		s.remote = addr
+	}
+	if addr.String() == s.remote.String() {
+		s.lastCheck = time.Now()
+	}
+	_, _ = s.conn.WriteToUDP(stunBindingResponse(m, addr, s.localPwd), addr)
+}
+
+// handleDTLS runs the handshake, SRTP starts with its keys once it's over
+func (s *Session) handleDTLS(data []byte, addr *net.UDPAddr) error {
+	s.mu.Lock()
+	if s.remote == nil || addr.String() != s.remote.String() {
+		s.mu.Unlock()
+		return nil
+	}
+	err := s.dtls.handle(data)
+	started := false
+	if err == nil && s.dtls.established() && s.srtp == nil {
+		clientKey, serverKey, clientSalt, serverSalt := s.dtls.srtpKeys()
+		if s.srtp, err = newSRTPContext(serverKey, serverSalt); err == nil {
+			s.srtcp, err = newSRTPContext(clientKey, clientSalt)
+		}
+		if err != nil {
+			s.srtp = nil
+		}
+		started = err == nil
+	}
+	s.mu.Unlock()
+	if started {
+		s.requestKeyframe()
+	}
+	return err
+}
+
+// handleRTCP handles the feedback of the browser, a NACK retransmits the packets still in the history
+func (s *Session) handleRTCP(data []byte, addr *net.UDPAddr) {
+	s.mu.Lock()
+	if s.srtcp == nil || addr.String() != s.remote.String() {
+		s.mu.Unlock()
+		return
+	}
+	data, err := s.srtcp.decryptRTCP(data)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	packets, err := rtp.UnmarshalRTCP(data)
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	keyframe := false
+	for _, packet := range packets {
+		if ssrc, err := packet.MediaSSRC(); err != nil || ssrc != s.packetizer.SSRC {
+			continue
+		}
+		if packet.IsKeyframeRequest() {
+			keyframe = true
+			continue
+		}
+		seqs, err := packet.NACKs()
+		if err != nil {
+			continue
+		}
+		for _, seq := range seqs {
+			// the history only has the packets of the last historySize sequence numbers
+			age := s.packetizer.SequenceNumber - seq
+			if data := s.history[seq%historySize]; data != nil && age >= 1 && age <= historySize {
+				_ = s.send(data)
+			} else {
+				keyframe = true
+			}
+		}
+	}
+	if keyframe {
+		s.waitKeyframe = true
+	}
+	s.mu.Unlock()
+	if keyframe {
+		s.requestKeyframe()
+	}
+}
+
+func (s *Session) requestKeyframe() {
+	if s.config.OnKeyframeRequest != nil {
+		s.config.OnKeyframeRequest()
+	}
+}
+
+// watch closes the session when the connectivity checks stop for the timeout
+func (s *Session) watch() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			expired := time.Since(s.lastCheck) > s.config.Timeout
+			s.mu.Unlock()
+			if expired {
+				s.Close()
+				return
+			}
+		}
+	}
+}
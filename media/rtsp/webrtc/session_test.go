@@ -0,0 +1,149 @@
+package webrtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testOffer = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\nt=0 0\r\n" +
+	"a=group:BUNDLE 0 1\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=ice-ufrag:EsAw\r\na=ice-pwd:bP+XJMM09aR8AiX1jdukzR6Y\r\n" +
+	"a=fingerprint:sha-256 d7:3c:c5:0a:b4:a8:52:19:31:6f:1a:47:0e:71:61:4b:1c:9f:c9:7c:10:20:a8:4e:3a:58:2e:ab:e1:0f:ad:94\r\n" +
+	"a=setup:actpass\r\na=mid:0\r\na=recvonly\r\na=rtcp-mux\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96 102 104\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=ice-ufrag:EsAw\r\na=ice-pwd:bP+XJMM09aR8AiX1jdukzR6Y\r\n" +
+	"a=fingerprint:sha-256 d7:3c:c5:0a:b4:a8:52:19:31:6f:1a:47:0e:71:61:4b:1c:9f:c9:7c:10:20:a8:4e:3a:58:2e:ab:e1:0f:ad:94\r\n" +
+	"a=setup:actpass\r\na=mid:1\r\na=recvonly\r\na=rtcp-mux\r\n" +
+	"a=rtpmap:96 VP8/90000\r\n" +
+	"a=rtpmap:102 H264/90000\r\n" +
+	"a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032\r\n" +
+	"a=rtpmap:104 H264/90000\r\n" +
+	"a=fmtp:104 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f\r\n"
+
+func TestSessionAnswer(t *testing.T) {
+	s, err := NewSession([]byte(testOffer), Config{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to create the session: %v", err)
+	}
+	defer s.Close()
+
+	answer := string(s.Answer())
+	port := s.conn.LocalAddr().(*net.UDPAddr).Port
+	for _, line := range []string{
+		"a=group:BUNDLE 1", "a=ice-lite",
+		"m=audio 0 UDP/TLS/RTP/SAVPF 111",
+		"m=video " + strconv.Itoa(port) + " UDP/TLS/RTP/SAVPF 104",
+		"a=mid:1", "a=setup:passive", "a=sendonly", "a=rtcp-mux",
+		"a=rtpmap:104 H264/90000", "a=rtcp-fb:104 nack pli",
+		"a=fmtp:104 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		"a=ice-ufrag:" + s.localUfrag,
+		"a=fingerprint:sha-256 " + s.config.Certificate.Fingerprint(),
+		"a=candidate:1 1 udp 2130706431 127.0.0.1 " + strconv.Itoa(port) + " typ host",
+	} {
+		if !strings.Contains(answer, line+"\r\n") {
+			t.Errorf("Expected %q in the answer\n%s", line, answer)
+		}
+	}
+
+	if _, err := NewSession([]byte(strings.ReplaceAll(testOffer, "packetization-mode=1", "packetization-mode=0")), Config{IP: net.IPv4(127, 0, 0, 1)}); err == nil {
+		t.Error("Expected an error without H264 in packetization mode 1")
+	}
+	if _, err := NewSession([]byte(strings.ReplaceAll(testOffer, "a=fingerprint", "a=x")), Config{IP: net.IPv4(127, 0, 0, 1)}); err == nil {
+		t.Error("Expected an error without fingerprint")
+	}
+}
+
+func TestSessionConnectivityCheck(t *testing.T) {
+	s, err := NewSession([]byte(testOffer), Config{IP: net.IPv4(127, 0, 0, 1), Timeout: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create the session: %v", err)
+	}
+	defer s.Close()
+	conn, err := net.DialUDP("udp", nil, s.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	check := func(username, password string) *stunMessage {
+		request := make([]byte, stunHeaderSize)
+		binary.BigEndian.PutUint16(request, stunBindingRequest)
+		binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+		copy(request[8:], "transaction1")
+		request = appendSTUNAttribute(request, stunAttrUsername, []byte(username))
+		request = appendSTUNAttribute(request, stunAttrUseCandidate, nil)
+		binary.BigEndian.PutUint16(request[2:], uint16(len(request)-stunHeaderSize+24))
+		mac := hmac.New(sha1.New, []byte(password))
+		mac.Write(request)
+		request = appendSTUNAttribute(request, stunAttrIntegrity, mac.Sum(nil))
+		conn.Write(request)
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		buffer := make([]byte, 1500)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return nil
+		}
+		m, err := parseSTUN(buffer[:n])
+		if err != nil {
+			t.Fatalf("Invalid response: %v", err)
+		}
+		return m
+	}
+
+	// an empty datagram is ignored
+	if _, err := conn.Write(nil); err != nil {
+		t.Fatalf("Failed to send an empty datagram: %v", err)
+	}
+	if check(s.localUfrag+":EsAw", "wrong") != nil {
+		t.Error("Expected no response with a wrong password")
+	}
+	if check("wrong:EsAw", s.localPwd) != nil {
+		t.Error("Expected no response with a wrong username")
+	}
+	m := check(s.localUfrag+":EsAw", s.localPwd)
+	if m == nil || m.Type != stunBindingSuccess || !m.checkIntegrity(s.localPwd) || string(m.TransactionID[:]) != "transaction1" {
+		t.Fatalf("Unexpected response %+v", m)
+	}
+	if _, ok := m.Attributes[stunAttrFingerprint]; !ok {
+		t.Error("Expected a fingerprint")
+	}
+	address := m.Attributes[stunAttrXORAddress]
+	local := conn.LocalAddr().(*net.UDPAddr)
+	if len(address) != 8 || int(binary.BigEndian.Uint16(address[2:])^stunMagicCookie>>16) != local.Port {
+		t.Errorf("Unexpected mapped address %x of %v", address, local)
+	}
+	s.mu.Lock()
+	remote := s.remote
+	s.mu.Unlock()
+	if remote == nil || remote.Port != local.Port {
+		t.Errorf("Expected the nominated address %v, got %v", local, remote)
+	}
+
+	// frames are dropped before the handshake
+	if err := s.WriteH264([][]byte{{0x65, 1}}, 0, true); err != nil {
+		t.Errorf("Failed to write: %v", err)
+	}
+
+	// the session is closed without checks
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the session to time out")
+	}
+	if err := s.WriteH264([][]byte{{0x65, 1}}, 0, true); err != ErrSessionClosed {
+		t.Errorf("Expected ErrSessionClosed, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package webrtc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+)
+
+// SRTP_AES128_CM_HMAC_SHA1_80 (RFC 3711, RFC 5764)
+const (
+	srtpProfileAES128CMHMACSHA180 = 0x0001
+	srtpKeySize                   = 16
+	srtpSaltSize                  = 14
+	srtpAuthKeySize               = 20
+	srtpAuthTagSize               = 10
+	// srtcpIndexSize is the E flag and the SRTCP index
+	srtcpIndexSize = 4
+)
+
+// srtpContext protects the RTP and RTCP of one direction
+type srtpContext struct {
+	rtpBlock, rtcpBlock cipher.Block
+	rtpSalt, rtcpSalt   []byte
+	rtpAuth, rtcpAuth   []byte
+	// rollover counters and last sequence numbers by SSRC
+	roc     map[uint32]uint32
+	lastSeq map[uint32]uint16
+	// rtcpIndex is the index of the next SRTCP packet
+	rtcpIndex uint32
+}
+
+// newSRTPContext derives the session keys of a master key and salt (RFC 3711 4.3) with a key derivation rate of 0
+func newSRTPContext(masterKey, masterSalt []byte) (*srtpContext, error) {
+	if len(masterKey) != srtpKeySize || len(masterSalt) != srtpSaltSize {
+		return nil, fmt.Errorf("invalid SRTP master key or salt length")
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	derive := func(label byte, n int) []byte {
+		iv := make([]byte, 16)
+		copy(iv, masterSalt)
+		iv[7] ^= label
+		out := make([]byte, n)
+		cipher.NewCTR(block, iv).XORKeyStream(out, out)
+		return out
+	}
+	c := &srtpContext{
+		rtpSalt:  derive(2, srtpSaltSize),
+		rtpAuth:  derive(1, srtpAuthKeySize),
+		rtcpSalt: derive(5, srtpSaltSize),
+		rtcpAuth: derive(4, srtpAuthKeySize),
+		roc:      map[uint32]uint32{},
+		lastSeq:  map[uint32]uint16{},
+	}
+	if c.rtpBlock, err = aes.NewCipher(derive(0, srtpKeySize)); err != nil {
+		return nil, err
+	}
+	if c.rtcpBlock, err = aes.NewCipher(derive(3, srtpKeySize)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// keystream XORs data with the AES-CM keystream of a packet index
+func keystream(block cipher.Block, salt []byte, ssrc uint32, index uint64, data []byte) {
+	iv := make([]byte, 16)
+	copy(iv, salt)
+	var ssrcIndex [16]byte
+	binary.BigEndian.PutUint32(ssrcIndex[4:], ssrc)
+	binary.BigEndian.PutUint64(ssrcIndex[8:], index<<16)
+	for i := range iv {
+		iv[i] ^= ssrcIndex[i]
+	}
+	cipher.NewCTR(block, iv).XORKeyStream(data, data)
+}
+
+func authTag(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha1.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)[:srtpAuthTagSize]
+}
+
+// rtpHeaderSize returns the size of the header of an RTP packet with its CSRCs and extension
+func rtpHeaderSize(packet []byte) (int, error) {
+	if len(packet) < 12 {
+		return 0, fmt.Errorf("RTP packet too short")
+	}
+	size := 12 + 4*int(packet[0]&0x0f)
+	if packet[0]&0x10 != 0 {
+		if len(packet) < size+4 {
+			return 0, fmt.Errorf("truncated RTP extension")
+		}
+		size += 4 + 4*int(binary.BigEndian.Uint16(packet[size+2:]))
+	}
+	if len(packet) < size {
+		return 0, fmt.Errorf("truncated RTP header")
+	}
+	return size, nil
+}
+
+// encryptRTP returns the SRTP packet of an RTP packet, the packets of an SSRC are in sequence
+func (c *srtpContext) encryptRTP(packet []byte) ([]byte, error) {
+	headerSize, err := rtpHeaderSize(packet)
+	if err != nil {
+		return nil, err
+	}
+	seq := binary.BigEndian.Uint16(packet[2:])
+	ssrc := binary.BigEndian.Uint32(packet[8:])
+	if last, ok := c.lastSeq[ssrc]; ok && seq < last && last-seq > 0x8000 {
+		c.roc[ssrc]++
+	}
+	c.lastSeq[ssrc] = seq
+	roc := c.roc[ssrc]
+
+	out := make([]byte, len(packet), len(packet)+srtpAuthTagSize)
+	copy(out, packet)
+	keystream(c.rtpBlock, c.rtpSalt, ssrc, uint64(roc)<<16|uint64(seq), out[headerSize:])
+	return append(out, authTag(c.rtpAuth, out, binary.BigEndian.AppendUint32(nil, roc))...), nil
+}
+
+// encryptRTCP returns the SRTCP packet of a compound RTCP packet
+func (c *srtpContext) encryptRTCP(packet []byte) ([]byte, error) {
+	if len(packet) < 8 {
+		return nil, fmt.Errorf("RTCP packet too short")
+	}
+	index := c.rtcpIndex
+	c.rtcpIndex = (c.rtcpIndex + 1) & 0x7fffffff
+	out := make([]byte, len(packet), len(packet)+srtcpIndexSize+srtpAuthTagSize)
+	copy(out, packet)
+	keystream(c.rtcpBlock, c.rtcpSalt, binary.BigEndian.Uint32(packet[4:]), uint64(index), out[8:])
+	out = binary.BigEndian.AppendUint32(out, 0x80000000|index)
+	return append(out, authTag(c.rtcpAuth, out)...), nil
+}
+
+// decryptRTCP authenticates and decrypts an SRTCP packet
+func (c *srtpContext) decryptRTCP(packet []byte) ([]byte, error) {
+	if len(packet) < 8+srtcpIndexSize+srtpAuthTagSize {
+		return nil, fmt.Errorf("SRTCP packet too short")
+	}
+	tagOffset := len(packet) - srtpAuthTagSize
+	if !hmac.Equal(authTag(c.rtcpAuth, packet[:tagOffset]), packet[tagOffset:]) {
+		return nil, fmt.Errorf("SRTCP authentication failed")
+	}
+	indexOffset := tagOffset - srtcpIndexSize
+	eIndex := binary.BigEndian.Uint32(packet[indexOffset:])
+	out := append([]byte{}, packet[:indexOffset]...)
+	if eIndex&0x80000000 != 0 {
+		keystream(c.rtcpBlock, c.rtcpSalt, binary.BigEndian.Uint32(packet[4:]), uint64(eIndex&0x7fffffff), out[8:])
+	}
+	return out, nil
+}
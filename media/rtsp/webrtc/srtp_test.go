@@ -0,0 +1,89 @@
+package webrtc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid hex %s: %v", s, err)
+	}
+	return data
+}
+
+func TestSRTPKeyDerivation(t *testing.T) {
+	// RFC 3711 B.3
+	masterKey := unhex(t, "E1F97A0D3E018BE0D64FA32C06DE4139")
+	masterSalt := unhex(t, "0EC675AD498AFEEBB6960B3AABE6")
+	c, err := newSRTPContext(masterKey, masterSalt)
+	if err != nil {
+		t.Fatalf("Failed to derive the keys: %v", err)
+	}
+	if expected := unhex(t, "30CBBC08863D8C85D49DB34A9AE1"); !bytes.Equal(c.rtpSalt, expected) {
+		t.Errorf("Expected salt %x, got %x", expected, c.rtpSalt)
+	}
+	if expected := unhex(t, "CEBE321F6FF7716B6FD4AB49AF256A156D38BAA4"); !bytes.Equal(c.rtpAuth, expected) {
+		t.Errorf("Expected auth key %x, got %x", expected, c.rtpAuth)
+	}
+	// the cipher key encrypts like the expected one
+	block, _ := aes.NewCipher(unhex(t, "C61E7A93744F39EE10734AFE3FF7A087"))
+	expected, got := make([]byte, 16), make([]byte, 16)
+	block.Encrypt(expected, make([]byte, 16))
+	c.rtpBlock.Encrypt(got, make([]byte, 16))
+	if !bytes.Equal(got, expected) {
+		t.Error("Unexpected cipher key")
+	}
+}
+
+func TestSRTPKeystream(t *testing.T) {
+	// RFC 3711 B.2, the session salt is XORed with the SSRC and index which are 0
+	block, _ := aes.NewCipher(unhex(t, "2B7E151628AED2A6ABF7158809CF4F3C"))
+	data := make([]byte, 32)
+	keystream(block, unhex(t, "F0F1F2F3F4F5F6F7F8F9FAFBFCFD"), 0, 0, data)
+	if expected := unhex(t, "E03EAD0935C95E80E166B16DD92B4EB4D23513162B02D0F72A43A2FE4A5F97AB"); !bytes.Equal(data, expected) {
+		t.Errorf("Expected keystream %x, got %x", expected, data)
+	}
+}
+
+func TestSRTCP(t *testing.T) {
+	key, salt := bytes.Repeat([]byte{1}, srtpKeySize), bytes.Repeat([]byte{2}, srtpSaltSize)
+	sender, _ := newSRTPContext(key, salt)
+	receiver, _ := newSRTPContext(key, salt)
+	pli := []byte{0x81, 206, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2}
+	for i := 0; i < 2; i++ {
+		packet, err := sender.encryptRTCP(pli)
+		if err != nil {
+			t.Fatalf("Failed to encrypt: %v", err)
+		}
+		if len(packet) != len(pli)+srtcpIndexSize+srtpAuthTagSize || bytes.Equal(packet[8:12], pli[8:]) {
+			t.Fatalf("Unexpected SRTCP packet %x", packet)
+		}
+		plain, err := receiver.decryptRTCP(packet)
+		if err != nil || !bytes.Equal(plain, pli) {
+			t.Fatalf("Expected %x, got %x (%v)", pli, plain, err)
+		}
+		packet[9] ^= 1
+		if _, err := receiver.decryptRTCP(packet); err == nil {
+			t.Error("Expected an authentication error")
+		}
+	}
+}
+
+func TestSRTPRolloverCounter(t *testing.T) {
+	c, _ := newSRTPContext(bytes.Repeat([]byte{1}, srtpKeySize), bytes.Repeat([]byte{2}, srtpSaltSize))
+	packet := []byte{0x80, 96, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 7, 0xaa}
+	if _, err := c.encryptRTP(packet); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	packet[2], packet[3] = 0, 0
+	if _, err := c.encryptRTP(packet); err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if c.roc[7] != 1 {
+		t.Errorf("Expected the rollover counter 1 after the wrap, got %d", c.roc[7])
+	}
+}
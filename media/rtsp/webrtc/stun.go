@@ -0,0 +1,125 @@
+package webrtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+)
+
+// STUN (RFC 5389) message types and attributes used by ICE (RFC 8445)
+const (
+	stunBindingRequest   = 0x0001
+	stunBindingSuccess   = 0x0101
+	stunMagicCookie      = 0x2112a442
+	stunHeaderSize       = 20
+	stunFingerprintXOR   = 0x5354554e
+	stunAttrUsername     = 0x0006
+	stunAttrIntegrity    = 0x0008
+	stunAttrXORAddress   = 0x0020
+	stunAttrUseCandidate = 0x0025
+	stunAttrFingerprint  = 0x8028
+)
+
+// stunMessage is a parsed STUN message, Raw keeps the message for the integrity check
+type stunMessage struct {
+	Type          uint16
+	TransactionID [12]byte
+	Attributes    map[uint16][]byte
+	Raw           []byte
+	// integrityOffset is the offset of MESSAGE-INTEGRITY, 0 when absent
+	integrityOffset int
+}
+
+// isSTUN reports whether a datagram is a STUN message (RFC 7983)
+func isSTUN(data []byte) bool {
+	return len(data) >= stunHeaderSize && data[0] < 4 && binary.BigEndian.Uint32(data[4:]) == stunMagicCookie
+}
+
+// parseSTUN parses a STUN message
+func parseSTUN(data []byte) (*stunMessage, error) {
+	if !isSTUN(data) {
+		return nil, fmt.Errorf("not a STUN message")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if stunHeaderSize+length > len(data) || length%4 != 0 {
+		return nil, fmt.Errorf("invalid STUN message length %d", length)
+	}
+	m := &stunMessage{Type: binary.BigEndian.Uint16(data), Attributes: map[uint16][]byte{}, Raw: data[:stunHeaderSize+length]}
+	copy(m.TransactionID[:], data[8:20])
+	for offset := stunHeaderSize; offset < len(m.Raw); {
+		if offset+4 > len(m.Raw) {
+			return nil, fmt.Errorf("truncated STUN attribute")
+		}
+		typ, size := binary.BigEndian.Uint16(m.Raw[offset:]), int(binary.BigEndian.Uint16(m.Raw[offset+2:]))
+		if offset+4+size > len(m.Raw) {
+			return nil, fmt.Errorf("truncated STUN attribute %x", typ)
+		}
+		if _, ok := m.Attributes[typ]; !ok {
+			m.Attributes[typ] = m.Raw[offset+4 : offset+4+size]
+		}
+		if typ == stunAttrIntegrity && m.integrityOffset == 0 {
+			m.integrityOffset = offset
+		}
+		offset += 4 + (size+3)&^3
+	}
+	return m, nil
+}
+
+// checkIntegrity verifies MESSAGE-INTEGRITY with the short-term credential password
+func (m *stunMessage) checkIntegrity(password string) bool {
+	if m.integrityOffset == 0 || len(m.Attributes[stunAttrIntegrity]) != sha1.Size {
+		return false
+	}
+	// the length of the header covers the attributes up to MESSAGE-INTEGRITY
+	data := append([]byte{}, m.Raw[:m.integrityOffset]...)
+	binary.BigEndian.PutUint16(data[2:], uint16(m.integrityOffset-stunHeaderSize+4+sha1.Size))
+	mac := hmac.New(sha1.New, []byte(password))
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), m.Attributes[stunAttrIntegrity])
+}
+
+// stunBindingResponse builds the success response to a binding request from addr,
+// with MESSAGE-INTEGRITY of the password and FINGERPRINT
+func stunBindingResponse(request *stunMessage, addr *net.UDPAddr, password string) []byte {
+	msg := make([]byte, stunHeaderSize, 64)
+	binary.BigEndian.PutUint16(msg, stunBindingSuccess)
+	binary.BigEndian.PutUint32(msg[4:], stunMagicCookie)
+	copy(msg[8:], request.TransactionID[:])
+
+	// XOR-MAPPED-ADDRESS
+	ip := addr.IP.To4()
+	family := byte(1)
+	if ip == nil {
+		ip, family = addr.IP.To16(), 2
+	}
+	value := []byte{0, family, 0, 0}
+	binary.BigEndian.PutUint16(value[2:], uint16(addr.Port)^stunMagicCookie>>16)
+	xor := msg[4:20]
+	for i := range ip {
+		value = append(value, ip[i]^xor[i])
+	}
+	msg = appendSTUNAttribute(msg, stunAttrXORAddress, value)
+
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)-stunHeaderSize+4+sha1.Size))
+	mac := hmac.New(sha1.New, []byte(password))
+	mac.Write(msg)
+	msg = appendSTUNAttribute(msg, stunAttrIntegrity, mac.Sum(nil))
+
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)-stunHeaderSize+8))
+	fingerprint := binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(msg)^stunFingerprintXOR)
+	return appendSTUNAttribute(msg, stunAttrFingerprint, fingerprint)
+}
+
+func appendSTUNAttribute(msg []byte, typ uint16, value []byte) []byte {
+	msg = binary.BigEndian.AppendUint16(msg, typ)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(value)))
+	msg = append(msg, value...)
+	for len(msg)%4 != 0 {
+		msg = append(msg, 0)
+	}
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)-stunHeaderSize))
+	return msg
+}